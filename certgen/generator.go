@@ -9,6 +9,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"math/big"
+	"net"
 	"os"
 	"path/filepath"
 	"time"
@@ -18,6 +19,7 @@ import (
 type Generator struct {
 	certsDir   string
 	baseDomain string
+	extraNames []string // Additional DNS names or IPs for the server certificate
 }
 
 // NewGenerator creates a new certificate generator
@@ -28,6 +30,12 @@ func NewGenerator(certsDir, baseDomain string) *Generator {
 	}
 }
 
+// AddNames adds DNS names or IP addresses to the server certificate.
+// Certificates generated by roji that lack them are reissued.
+func (g *Generator) AddNames(names ...string) {
+	g.extraNames = append(g.extraNames, names...)
+}
+
 // CertPaths returns the paths to certificate files
 func (g *Generator) CertPaths() (caCert, caKey, serverCert, serverKey string) {
 	return filepath.Join(g.certsDir, "ca.pem"),
@@ -51,7 +59,19 @@ func (g *Generator) EnsureCerts() error {
 	serverKeyExists := fileExists(serverKeyPath)
 
 	// If server cert/key exist, use them (likely from mkcert or manual setup)
+	// unless roji issued them and they miss the base domain or extra names
 	if serverCertExists && serverKeyExists {
+		names := append([]string{g.baseDomain, "*." + g.baseDomain}, g.extraNames...)
+		if !fileExists(caKeyPath) || certCovers(serverCertPath, names) {
+			return nil
+		}
+		caCert, caKey, err := loadCA(caCertPath, caKeyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load existing CA: %w", err)
+		}
+		if err := g.generateServerCert(caCert, caKey, serverCertPath, serverKeyPath); err != nil {
+			return fmt.Errorf("failed to reissue server certificate: %w", err)
+		}
 		return nil
 	}
 
@@ -184,6 +204,15 @@ func (g *Generator) generateServerCert(caCert *x509.Certificate, caKey *ecdsa.Pr
 		dnsNames = append(dnsNames, "*.*."+g.baseDomain)
 	}
 
+	var ipAddresses []net.IP
+	for _, name := range g.extraNames {
+		if ip := net.ParseIP(name); ip != nil {
+			ipAddresses = append(ipAddresses, ip)
+		} else {
+			dnsNames = append(dnsNames, name)
+		}
+	}
+
 	template := &x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
@@ -195,6 +224,7 @@ func (g *Generator) generateServerCert(caCert *x509.Certificate, caKey *ecdsa.Pr
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		DNSNames:              dnsNames,
+		IPAddresses:           ipAddresses,
 		BasicConstraintsValid: true,
 	}
 
@@ -257,6 +287,46 @@ func loadCA(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, err
 	return cert, key, nil
 }
 
+// certCovers reports whether the certificate at certPath lists every name
+// (DNS names literally, IPs as IP SANs)
+func certCovers(certPath string, names []string) bool {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return false
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+
+	for _, name := range names {
+		found := false
+		if ip := net.ParseIP(name); ip != nil {
+			for _, certIP := range cert.IPAddresses {
+				if certIP.Equal(ip) {
+					found = true
+					break
+				}
+			}
+		} else {
+			for _, dnsName := range cert.DNSNames {
+				if dnsName == name {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // saveCertificate saves a certificate to a PEM file
 func saveCertificate(path string, cert *x509.Certificate) error {
 	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
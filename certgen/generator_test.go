@@ -221,3 +221,79 @@ func TestGenerator_LocalhostDomain(t *testing.T) {
 		t.Error("localhost domain should not have *.*.localhost DNS name")
 	}
 }
+
+func TestGenerator_AddNames(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Existing roji-issued certificate without the extra names
+	if err := NewGenerator(tempDir, "test.localhost").EnsureCerts(); err != nil {
+		t.Fatalf("EnsureCerts() error = %v", err)
+	}
+	certPath := filepath.Join(tempDir, "cert.pem")
+	if certCovers(certPath, []string{"*.10.0.2.2.nip.io"}) {
+		t.Fatal("initial certificate should not cover emulator names")
+	}
+
+	gen := NewGenerator(tempDir, "test.localhost")
+	gen.AddNames("*.10.0.2.2.nip.io", "10.0.2.2")
+	if err := gen.EnsureCerts(); err != nil {
+		t.Fatalf("EnsureCerts() error = %v", err)
+	}
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.VerifyHostname("web.10.0.2.2.nip.io"); err != nil {
+		t.Errorf("reissued certificate should cover emulator hostnames: %v", err)
+	}
+	if err := cert.VerifyHostname("10.0.2.2"); err != nil {
+		t.Errorf("reissued certificate should cover the bridge IP: %v", err)
+	}
+	if err := cert.VerifyHostname("app.test.localhost"); err != nil {
+		t.Errorf("reissued certificate should keep the base domain: %v", err)
+	}
+}
+
+func TestGenerator_NewBaseDomain(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// --magic-dns moves the base domain with the LAN address
+	if err := NewGenerator(tempDir, "192-168-1-20.sslip.io").EnsureCerts(); err != nil {
+		t.Fatalf("EnsureCerts() error = %v", err)
+	}
+	if err := NewGenerator(tempDir, "10-0-0-5.sslip.io").EnsureCerts(); err != nil {
+		t.Fatalf("EnsureCerts() error = %v", err)
+	}
+
+	certPath := filepath.Join(tempDir, "cert.pem")
+	if !certCovers(certPath, []string{"10-0-0-5.sslip.io", "*.10-0-0-5.sslip.io", "*.*.10-0-0-5.sslip.io"}) {
+		t.Error("certificate wasn't reissued for the new base domain")
+	}
+}
+
+func TestGenerator_AddNames_ExternalCerts(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Certificates without a roji CA key (e.g., mkcert) are never replaced
+	if err := os.WriteFile(filepath.Join(tempDir, "cert.pem"), []byte("dummy cert"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "key.pem"), []byte("dummy key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	gen := NewGenerator(tempDir, "test.localhost")
+	gen.AddNames("*.10.0.2.2.nip.io")
+	if err := gen.EnsureCerts(); err != nil {
+		t.Fatalf("EnsureCerts() error = %v", err)
+	}
+	if cert, _ := os.ReadFile(filepath.Join(tempDir, "cert.pem")); string(cert) != "dummy cert" {
+		t.Error("external cert.pem should be preserved")
+	}
+}
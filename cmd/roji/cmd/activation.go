@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// listenFDsStart is the first file descriptor systemd passes (SD_LISTEN_FDS_START)
+const listenFDsStart = 3
+
+// serverListeners returns the HTTP listener (nil with --http-mode off) and the
+// HTTPS ones (--https-port first, then --extra-https-ports; none with
+// --no-tls): sockets passed by systemd or by the roji process being upgraded,
+// else newly bound ports. A taken HTTP or HTTPS port is replaced by its
+// fallback port, if configured, and cfg is switched to it.
+func serverListeners(cfg *Config) (net.Listener, []net.Listener, error) {
+	ports := servedPorts(*cfg)
+	activated, err := systemdListeners(cfg.HTTPPort, cfg.HTTPSPort, ports)
+	if err != nil {
+		return nil, nil, err
+	}
+	for port, ln := range activated {
+		slog.Info("using inherited socket", "port", port, "address", ln.Addr().String())
+		// An upgraded roji keeps the fallback ports of the previous one
+		bound := listenerPort(ln)
+		if port == cfg.HTTPPort && bound == cfg.HTTPFallbackPort && bound != 0 {
+			cfg.HTTPPort, cfg.PortsFellBack = bound, true
+		}
+		if port == cfg.HTTPSPort && bound == cfg.HTTPSFallbackPort && bound != 0 {
+			cfg.HTTPSPort, cfg.PortsFellBack = bound, true
+		}
+	}
+
+	listeners := make([]net.Listener, 0, len(ports))
+	for _, port := range ports {
+		ln, ok := activated[port]
+		if !ok {
+			if ln, err = listen(cfg.ListenAddress, port, cfg.ListenIPv6); err != nil {
+				ln, err = listenFallback(cfg, port, err)
+			}
+			if err != nil {
+				for _, l := range listeners {
+					l.Close()
+				}
+				closeListeners(activated)
+				return nil, nil, err
+			}
+		}
+		delete(activated, port)
+		listeners = append(listeners, ln)
+	}
+	if cfg.HTTPMode == "off" && !cfg.NoTLS {
+		return nil, listeners, nil
+	}
+	return listeners[0], listeners[1:], nil
+}
+
+// listenFallback binds the fallback port of the HTTP or HTTPS port that
+// failed to bind with err, and switches cfg to it
+func listenFallback(cfg *Config, port int, err error) (net.Listener, error) {
+	fallback, flag, fallbackFlag := 0, "--extra-https-ports", ""
+	switch port {
+	case cfg.HTTPPort:
+		fallback, flag, fallbackFlag = cfg.HTTPFallbackPort, "--http-port", "--http-fallback-port"
+	case cfg.HTTPSPort:
+		fallback, flag, fallbackFlag = cfg.HTTPSFallbackPort, "--https-port", "--https-fallback-port"
+	}
+	if fallback == 0 {
+		return nil, bindError(port, flag, fallbackFlag, err)
+	}
+
+	ln, fallbackErr := listen(cfg.ListenAddress, fallback, cfg.ListenIPv6)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("port %d is unavailable (%v), and roji %w", port, err, bindError(fallback, fallbackFlag, "", fallbackErr))
+	}
+	slog.Warn("port unavailable, using fallback port", "port", port, "fallback", fallback, "error", err)
+	if port == cfg.HTTPPort {
+		cfg.HTTPPort = fallback
+	} else {
+		cfg.HTTPSPort = fallback
+	}
+	cfg.PortsFellBack = true
+	return ln, nil
+}
+
+// portsFile is where a server that fell back to other ports records them,
+// in the certs directory, for CLI commands to reach it
+const portsFile = "ports.json"
+
+// fallbackPorts are the contents of the ports file
+type fallbackPorts struct {
+	HTTPPort  int `json:"http_port"`
+	HTTPSPort int `json:"https_port"`
+}
+
+// writePortsFile records the ports in use when a fallback port replaced one,
+// and otherwise removes a file a killed server left behind
+func writePortsFile(cfg Config) error {
+	path := filepath.Join(cfg.CertsDir, portsFile)
+	if !cfg.PortsFellBack {
+		return removePortsFile(cfg)
+	}
+	data, _ := json.Marshal(fallbackPorts{HTTPPort: cfg.HTTPPort, HTTPSPort: cfg.HTTPSPort})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to record the fallback ports: %w", err)
+	}
+	return nil
+}
+
+// removePortsFile removes the ports file, on shutdown
+func removePortsFile(cfg Config) error {
+	err := os.Remove(filepath.Join(cfg.CertsDir, portsFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// useFallbackPorts points CLI commands at the ports the server fell back
+// to, unless --http-port or --https-port is given on the command line or in
+// the environment
+func useFallbackPorts(cmd *cobra.Command) {
+	data, err := os.ReadFile(filepath.Join(certsDir, portsFile))
+	if err != nil {
+		return
+	}
+	var ports fallbackPorts
+	if err := json.Unmarshal(data, &ports); err != nil {
+		slog.Debug("ignoring invalid ports file", "error", err)
+		return
+	}
+	if ports.HTTPPort != 0 && !portGiven(cmd, "http-port") {
+		httpPort = ports.HTTPPort
+	}
+	if ports.HTTPSPort != 0 && !portGiven(cmd, "https-port") {
+		httpsPort = ports.HTTPSPort
+	}
+}
+
+// portGiven reports whether a port flag was set on the command line or
+// through its environment variable
+func portGiven(cmd *cobra.Command, name string) bool {
+	if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+		return true
+	}
+	return os.Getenv("ROJI_"+strings.ToUpper(strings.ReplaceAll(name, "-", "_"))) != ""
+}
+
+// bindError explains a port that failed to bind, naming the process holding
+// it where possible, with the fixes roji doctor suggests
+func bindError(port int, flag, fallbackFlag string, err error) error {
+	message, fixes := diagnoseBindError(port, flag, fallbackFlag, err)
+	var hints strings.Builder
+	for _, fix := range fixes {
+		hints.WriteString("\n  → " + fix)
+	}
+	return fmt.Errorf("failed to listen on port %d: %s (%w)%s", port, message, err, hints.String())
+}
+
+// dnsListener returns the socket of the DNS server (--dns-port): the one the
+// roji process being upgraded passed, else one bound like the ports (on
+// --listen, or loopback with --local-only)
+func dnsListener(cfg Config) (net.PacketConn, error) {
+	if fd, err := strconv.Atoi(os.Getenv(upgradeDNSEnv)); err == nil {
+		os.Unsetenv(upgradeDNSEnv)
+		file := os.NewFile(uintptr(fd), "dns")
+		conn, err := net.FilePacketConn(file)
+		file.Close() // FilePacketConn keeps a duplicate
+		if err == nil {
+			slog.Info("using inherited socket", "port", cfg.DNSPort, "address", conn.LocalAddr().String())
+			return conn, nil
+		}
+		slog.Warn("ignoring inherited DNS socket", "error", err)
+	}
+
+	network, address := "udp4", cfg.ListenAddress
+	switch {
+	case address != "":
+		network = "udp"
+	case cfg.LocalOnly:
+		address = "127.0.0.1"
+	case cfg.ListenIPv6:
+		network = "udp"
+	}
+	conn, err := net.ListenPacket(network, net.JoinHostPort(address, strconv.Itoa(cfg.DNSPort)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on DNS port %d: %w", cfg.DNSPort, err)
+	}
+	return conn, nil
+}
+
+// listenerPort returns the TCP port a listener is bound to, or 0
+func listenerPort(ln net.Listener) int {
+	if addr, ok := ln.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+// servedPorts lists the HTTP port, then the HTTPS ones
+func servedPorts(cfg Config) []int {
+	if cfg.NoTLS {
+		return []int{cfg.HTTPPort}
+	}
+	https := append([]int{cfg.HTTPSPort}, cfg.ExtraHTTPSPorts...)
+	if cfg.HTTPMode == "off" {
+		return https
+	}
+	return append([]int{cfg.HTTPPort}, https...)
+}
+
+// systemdListeners returns the sockets passed with the systemd socket
+// activation protocol (LISTEN_FDS), keyed by the port roji serves on them:
+// sockets named "http" or "https" (FileDescriptorName=) go to those ports,
+// others by the port they are bound to, which must be one of ports. An
+// upgrading roji passes its sockets the same way (see upgrade_unix.go). It
+// returns nil when no sockets were passed.
+func systemdListeners(httpPort, httpsPort int, ports []int) (map[int]net.Listener, error) {
+	// The child of an upgrade can't know its pid in advance; it gets its parent's
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	parent, _ := strconv.Atoi(os.Getenv(upgradeParentEnv))
+	if (err != nil || pid != os.Getpid()) && (parent == 0 || parent != os.Getppid()) {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// The sockets are roji's; child processes (e.g. ssh for DOCKER_HOST) must not see them
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	os.Unsetenv(upgradeParentEnv)
+
+	listeners := make(map[int]net.Listener, count)
+	for i := range count {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(listenFDsStart+i), name)
+		ln, err := net.FileListener(file)
+		file.Close() // FileListener keeps a duplicate
+		if err != nil {
+			closeListeners(listeners)
+			return nil, fmt.Errorf("socket %d (%s) from systemd is not a TCP listener: %w", listenFDsStart+i, name, err)
+		}
+
+		port := 0
+		switch name {
+		case "http":
+			port = httpPort
+		case "https":
+			port = httpsPort
+		default:
+			port = listenerPort(ln)
+		}
+		if !slices.Contains(ports, port) {
+			slog.Warn("ignoring socket from systemd", "fd", listenFDsStart+i, "name", name, "address", ln.Addr().String())
+			ln.Close()
+			continue
+		}
+		if _, ok := listeners[port]; ok {
+			closeListeners(listeners)
+			ln.Close()
+			return nil, fmt.Errorf("systemd passed several sockets for port %d", port)
+		}
+		listeners[port] = ln
+	}
+	return listeners, nil
+}
+
+func closeListeners(listeners map[int]net.Listener) {
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
+
+const (
+	// upgradeParentEnv holds the pid of the roji process handing over its sockets
+	upgradeParentEnv = "ROJI_UPGRADE_PARENT"
+	// upgradeReadyEnv holds the descriptor to report readiness on to that process
+	upgradeReadyEnv = "ROJI_UPGRADE_READY_FD"
+	// upgradeDNSEnv holds the descriptor of the DNS socket it passes (--dns-port)
+	upgradeDNSEnv = "ROJI_UPGRADE_DNS_FD"
+)
+
+// notifyReady tells the roji process being upgraded, and systemd, that this
+// process serves the routes now. The old process then stops accepting
+// connections and finishes the open ones.
+func notifyReady() {
+	// With NotifyAccess=all, systemd follows the new process instead of
+	// stopping the service when the old one exits
+	if socket := os.Getenv("NOTIFY_SOCKET"); socket != "" && os.Getenv(upgradeReadyEnv) != "" {
+		conn, err := net.Dial("unixgram", socket)
+		if err == nil {
+			fmt.Fprintf(conn, "MAINPID=%d\nREADY=1", os.Getpid())
+			conn.Close()
+		} else {
+			slog.Warn("failed to notify systemd", "error", err)
+		}
+	}
+
+	fd, err := strconv.Atoi(os.Getenv(upgradeReadyEnv))
+	if err != nil {
+		return
+	}
+	os.Unsetenv(upgradeReadyEnv)
+	ready := os.NewFile(uintptr(fd), "upgrade-ready")
+	if _, err := ready.Write([]byte{1}); err != nil {
+		slog.Warn("failed to notify the previous roji process", "error", err)
+	}
+	ready.Close()
+}
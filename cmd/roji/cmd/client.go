@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// newAPIClient creates an HTTP client for talking to the running roji server.
+// The roji CA from the certs directory is trusted in addition to the system
+// roots; certificate verification is only skipped with --insecure.
+func newAPIClient(timeout time.Duration) (*http.Client, error) {
+	tlsConfig, err := clientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext:     dialRoji,
+	}
+	if apiToken != "" {
+		transport = &tokenTransport{base: transport, token: apiToken}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+// dialRoji dials *.localhost names at the loopback address without a DNS
+// lookup (RFC 6761); resolvers without systemd-resolved, as on many CI
+// runners, don't know them. Names under a custom --domain fall back to the
+// loopback address when they don't resolve.
+func dialRoji(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err == nil && dialsLoopback(ctx, host) {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	}
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// dialsLoopback reports whether a hostname is reached at 127.0.0.1
+func dialsLoopback(ctx context.Context, host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if host != baseDomain && !strings.HasSuffix(host, "."+baseDomain) {
+		return false
+	}
+	_, err := net.DefaultResolver.LookupHost(ctx, host)
+	return err != nil
+}
+
+// tokenTransport sends --api-token with every API request. Redirects to
+// another host (a route's backend, say) don't get it.
+type tokenTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	first := req
+	for first.Response != nil && first.Response.Request != nil {
+		first = first.Response.Request
+	}
+	req = req.Clone(req.Context())
+	if req.URL.Host == first.URL.Host {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	} else {
+		// http.Client keeps headers on redirects to subdomains
+		req.Header.Del("Authorization")
+	}
+	return t.base.RoundTrip(req)
+}
+
+// dashboardHostname is the dashboard host CLI commands talk to
+func dashboardHostname() string {
+	if dashboardHost == "" {
+		return "roji." + baseDomain
+	}
+	return dashboardHost
+}
+
+// dashboardAPIURL returns the URL of a management API endpoint on the dashboard host
+func dashboardAPIURL(path string) string {
+	return serverOrigin(dashboardHostname()) + path
+}
+
+// serverOrigin returns the scheme and host roji serves a hostname on: HTTPS,
+// or plain HTTP with --no-tls
+func serverOrigin(host string) string {
+	if noTLS {
+		if httpPort != 80 {
+			host = fmt.Sprintf("%s:%d", host, httpPort)
+		}
+		return "http://" + host
+	}
+	if httpsPort != 443 {
+		host = fmt.Sprintf("%s:%d", host, httpsPort)
+	}
+	return "https://" + host
+}
+
+// clientTLSConfig builds the TLS configuration used by CLI subcommands
+func clientTLSConfig() (*tls.Config, error) {
+	if insecure {
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	caPath := filepath.Join(certsDir, "ca.pem")
+	caPEM, err := os.ReadFile(caPath)
+	switch {
+	case err == nil:
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("invalid CA certificate in %s", caPath)
+		}
+	case os.IsNotExist(err):
+		// No roji CA (e.g., mkcert setup): rely on the system trust store
+	default:
+		return nil, fmt.Errorf("failed to read CA certificate: %w (use --insecure to skip verification)", err)
+	}
+
+	return &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
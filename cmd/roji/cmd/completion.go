@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kan/roji/config"
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish",
+	Short: "Generate shell completion scripts",
+	Long: `Prints a completion script for your shell. Besides commands and flags, it
+completes the hostnames and services of the running roji server for commands
+like roji wait, roji url and roji curl; without a running server, they just
+complete nothing.
+
+  # bash (needs bash-completion)
+  source <(roji completion bash)
+  roji completion bash > /etc/bash_completion.d/roji
+
+  # zsh
+  roji completion zsh > "${fpath[1]}/_roji"
+
+  # fish
+  roji completion fish > ~/.config/fish/completions/roji.fish`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		}
+		return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", args[0])
+	},
+}
+
+func init() {
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfile)
+	rootCmd.AddCommand(completionCmd)
+}
+
+// completionTimeout keeps the shell responsive when roji isn't running
+const completionTimeout = time.Second
+
+// completionRoutes fetches the routes for a completion, or nil when the
+// server can't be reached
+func completionRoutes() []proxy.RouteInfo {
+	client, err := newAPIClient(completionTimeout)
+	if err != nil {
+		return nil
+	}
+	routes, err := fetchRoutesWith(client)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), false)
+		return nil
+	}
+	return routes
+}
+
+// completeHostname completes the one hostname argument of commands like
+// roji share
+func completeHostname(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return hostnameCompletions(completionRoutes()), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRouteURL completes hostnames and their path prefixes for commands
+// taking URLs; the shell adds no space, so a path can follow
+func completeRouteURL(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if cmd.Args != nil && cmd.Args(cmd, append(args, toComplete)) != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	seen := make(map[string]bool)
+	var completions []cobra.Completion
+	for _, r := range completionRoutes() {
+		u := r.Hostname + r.PathPrefix
+		if r.PathPrefix == "" {
+			u += "/"
+		}
+		if !seen[u] {
+			seen[u] = true
+			completions = append(completions, cobra.CompletionWithDesc(u, routeDescription(r)))
+		}
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeService completes compose services and container names (roji url)
+func completeService(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	project, _ := cmd.Flags().GetString("project")
+	seen := make(map[string]bool)
+	var completions []cobra.Completion
+	for _, r := range completionRoutes() {
+		if r.RedirectTo != "" || (project != "" && r.ProjectName != project) {
+			continue
+		}
+		for _, name := range []string{r.ServiceName, r.ContainerName} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				completions = append(completions, cobra.CompletionWithDesc(name, r.Hostname))
+			}
+		}
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProject completes compose project names (--project)
+func completeProject(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	seen := make(map[string]bool)
+	var completions []cobra.Completion
+	for _, r := range completionRoutes() {
+		if r.ProjectName != "" && !seen[r.ProjectName] {
+			seen[r.ProjectName] = true
+			completions = append(completions, r.ProjectName)
+		}
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfile completes the profiles of the configuration file (--profile)
+func completeProfile(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if configFile == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	f, err := config.LoadFile(configFile)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), false)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return f.ProfileNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+func hostnameCompletions(routes []proxy.RouteInfo) []cobra.Completion {
+	seen := make(map[string]bool)
+	var completions []cobra.Completion
+	for _, r := range routes {
+		if !seen[r.Hostname] {
+			seen[r.Hostname] = true
+			completions = append(completions, cobra.CompletionWithDesc(r.Hostname, routeDescription(r)))
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// routeDescription is shown next to a completion by zsh and fish
+func routeDescription(r proxy.RouteInfo) string {
+	if r.RedirectTo != "" {
+		return "alias of " + r.RedirectTo
+	}
+	parts := []string{r.ContainerName}
+	if r.ProjectName != "" {
+		parts = append(parts, "("+r.ProjectName+")")
+	}
+	return strings.Join(parts, " ")
+}
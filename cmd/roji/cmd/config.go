@@ -0,0 +1,461 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kan/roji/config"
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var configFingerprint bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Print the running configuration",
+	Long: `Prints the effective configuration of the running roji server as canonical
+JSON: the settings that shape routing and the routes derived from labels.
+Container IPs and names are left out, so teammates running the same projects
+get identical output:
+
+  roji config --fingerprint     # compare one line
+  roji config > roji.json       # save as a manifest (--manifest roji.json)
+  diff <(roji config) roji.json
+
+roji config validate checks a configuration file (--config) before roji
+uses it; roji config show prints the settings this machine would start with
+and where each one comes from.`,
+	Args: cobra.NoArgs,
+	RunE: runConfig,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Check a configuration file",
+	Long: `Checks a configuration file (default: --config) without starting roji:
+unknown keys and invalid values, roji's ports clashing with each other or
+with host routes, hostnames claimed by several routes or by the dashboard,
+and middleware that isn't a roji label or only makes sense per container.
+
+Exits with 1 when it finds problems.
+
+  roji config validate roji.yaml`,
+	Args: cobra.MaximumNArgs(1),
+	// The file is loaded here, reporting every problem instead of the first
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	Run: func(cmd *cobra.Command, args []string) {
+		path := configFile
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if path == "" {
+			fmt.Fprintln(os.Stderr, "no configuration file: pass one or set --config")
+			os.Exit(1)
+		}
+		problems := validateConfigFile(cmd, path)
+		if len(problems) > 0 {
+			fmt.Printf("❌ %s has %d problem(s):\n", path, len(problems))
+			for _, p := range problems {
+				fmt.Printf("   • %v\n", p)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("✅ %s is valid\n", path)
+	},
+}
+
+var configShowJSON bool
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each setting comes from",
+	Long: `Prints every setting roji would start with on this machine, resolved the
+way the server resolves it: command line flags over ROJI_* environment
+variables over the configuration file (--config) over built-in defaults.
+Each setting names its source, which answers "why is roji using this
+domain?". Routes, middleware, project domains and TLS options from the file
+follow. Settings from a profile (--profile) name it. --api-token is never
+printed.
+
+Unlike roji config, this reads no running server.
+
+  roji config show --config roji.yaml
+  roji config show --config roji.yaml --profile work
+  ROJI_DOMAIN=test.localhost roji config show`,
+	Args: cobra.NoArgs,
+	RunE: runConfigShow,
+}
+
+func init() {
+	configCmd.Flags().BoolVar(&configFingerprint, "fingerprint", false, "Print only the configuration fingerprint")
+	configShowCmd.Flags().BoolVar(&configShowJSON, "json", false, "Print the effective configuration as JSON")
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfig(cmd *cobra.Command, args []string) error {
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	doc, err := fetchConfig(client)
+	if err != nil {
+		return err
+	}
+	if configFingerprint {
+		fmt.Println(doc.Fingerprint())
+		return nil
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// fetchConfig gets the running server's effective configuration
+func fetchConfig(client *http.Client) (proxy.ConfigDocument, error) {
+	var doc proxy.ConfigDocument
+	resp, err := client.Get(dashboardAPIURL("/_api/v1/config"))
+	if err != nil {
+		return doc, fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return doc, fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return doc, fmt.Errorf("failed to parse configuration: %w", err)
+	}
+	return doc, nil
+}
+
+// validateConfigFile loads a configuration file and returns everything wrong
+// with it, or with the settings it produces
+func validateConfigFile(cmd *cobra.Command, path string) []error {
+	f, err := config.LoadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+	// Settings of every profile, not just the selected one
+	var problems []error
+	for _, name := range f.ProfileNames() {
+		for setting := range f.Profiles[name].Flags {
+			if lookupSetting(cmd, setting) == nil {
+				problems = append(problems, fmt.Errorf("profiles.%s: unknown setting %q", name, setting))
+			}
+		}
+	}
+	if f, err = withProfile(f); err != nil {
+		return append(problems, err)
+	}
+	problems = append(problems, applyConfigFile(cmd, f)...)
+	problems = append(problems, f.Validate()...)
+
+	// Ports
+	for _, p := range []struct {
+		name string
+		port int
+	}{{"http-port", httpPort}, {"https-port", httpsPort}} {
+		if p.port < 1 || p.port > 65535 {
+			problems = append(problems, fmt.Errorf("%s %d is not a valid port", p.name, p.port))
+		}
+	}
+	if httpPort == httpsPort {
+		problems = append(problems, fmt.Errorf("http-port and https-port are both %d", httpPort))
+	}
+	extraPorts, err := config.ParsePorts(extraHTTPSPorts)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("extra-https-ports: %w", err))
+	}
+	for _, port := range extraPorts {
+		if port == httpPort || port == httpsPort {
+			problems = append(problems, fmt.Errorf("extra-https-ports: %d is already the HTTP or HTTPS port", port))
+		}
+	}
+	if err := checkFallbackPorts(extraPorts); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := config.ParseListenAddress(listenAddress); err != nil {
+		problems = append(problems, fmt.Errorf("listen: %w", err))
+	}
+	if _, err := config.ParseHostPatterns(allowedHosts); err != nil {
+		problems = append(problems, fmt.Errorf("allowed-hosts: %w", err))
+	}
+	if _, err := config.ParsePrefixes(trustedProxies); err != nil {
+		problems = append(problems, fmt.Errorf("trusted-proxies: %w", err))
+	}
+	if lanIP != "" {
+		if _, err := netip.ParseAddr(lanIP); err != nil {
+			problems = append(problems, fmt.Errorf("lan-ip %q is not an IP address", lanIP))
+		}
+	}
+	if service, err := config.ParseMagicDNS(magicDNS); err != nil {
+		problems = append(problems, fmt.Errorf("magic-dns: %w", err))
+	} else if service != "" && fileFlags["domain"] {
+		problems = append(problems, fmt.Errorf("magic-dns derives the domain from the LAN address; drop domain"))
+	}
+	if lanMode && localOnly {
+		problems = append(problems, fmt.Errorf("lan serves other devices; local-only rejects them"))
+	}
+	if mdns && localOnly {
+		problems = append(problems, fmt.Errorf("mdns advertises routes to other devices; local-only rejects them"))
+	}
+	if httpMode != "redirect" && httpMode != "proxy" && httpMode != "off" {
+		problems = append(problems, fmt.Errorf("http-mode %q is not redirect, proxy or off", httpMode))
+	}
+	if noTLS && httpMode == "off" {
+		problems = append(problems, fmt.Errorf("http-mode off leaves nothing to serve with no-tls"))
+	}
+	switch httpRedirectCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		problems = append(problems, fmt.Errorf("http-redirect-code %d is not 301, 302, 307 or 308", httpRedirectCode))
+	}
+	if readHeaderTimeout < 0 {
+		problems = append(problems, fmt.Errorf("read-header-timeout %s is negative", readHeaderTimeout))
+	}
+	if idleTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("idle-timeout %s is not positive", idleTimeout))
+	}
+	if maxHeaderBytes < 4<<10 {
+		problems = append(problems, fmt.Errorf("max-header-bytes %d is below 4096", maxHeaderBytes))
+	}
+	if backendMaxIdleConns <= 0 {
+		problems = append(problems, fmt.Errorf("backend-max-idle-conns %d is not positive", backendMaxIdleConns))
+	}
+	if backendIdleTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("backend-idle-timeout %s is not positive", backendIdleTimeout))
+	}
+	if backendConnLifetime < 0 {
+		problems = append(problems, fmt.Errorf("backend-conn-lifetime %s is negative", backendConnLifetime))
+	}
+	if _, err := config.ParsePathPrefixes(plainHTTPPaths); err != nil {
+		problems = append(problems, fmt.Errorf("plain-http-paths: %w", err))
+	}
+	if forwardProxy && httpMode == "off" {
+		problems = append(problems, fmt.Errorf("forward-proxy needs the HTTP port; http-mode is off"))
+	}
+	if dnsPort < 0 || dnsPort > 65535 {
+		problems = append(problems, fmt.Errorf("dns-port %d is not a port", dnsPort))
+	}
+
+	// Routes of the flags and of the file together
+	hosts, err := config.ParseHostRoutes(hostRoutes, baseDomain)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("host-routes: %w", err))
+	}
+	statics, err := config.ParseStaticRoutes(staticRoutes, baseDomain)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("static-routes: %w", err))
+	}
+	externals, err := config.ParseExternalRoutes(externalRoutes, baseDomain)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("external-routes: %w", err))
+	}
+	fileHosts, fileStatics, fileExternals := f.SplitRoutes(baseDomain)
+	hosts = append(hosts, fileHosts...)
+	statics = append(statics, fileStatics...)
+	externals = append(externals, fileExternals...)
+
+	// A host route to roji's own port on this machine loops back into roji
+	if hostGateway == "127.0.0.1" || hostGateway == "localhost" {
+		for _, r := range hosts {
+			if r.Port == httpPort || r.Port == httpsPort || slices.Contains(extraPorts, r.Port) {
+				problems = append(problems, fmt.Errorf("host route %s goes to port %d, which roji itself listens on", r.Hostname, r.Port))
+			}
+		}
+	}
+	for _, hostname := range config.DuplicateHostnames(hosts, statics, externals) {
+		problems = append(problems, fmt.Errorf("hostname %s is claimed by several routes", hostname))
+	}
+
+	dashboard := dashboardHost
+	if dashboard == "" {
+		dashboard = baseDomain
+	}
+	if slices.Contains(config.DuplicateHostnames(hosts, statics, append(externals, config.ExternalRoute{Hostname: dashboard})), dashboard) {
+		problems = append(problems, fmt.Errorf("a route claims the dashboard hostname %s", dashboard))
+	}
+	for project, domain := range f.ProjectDomains() {
+		if domain == dashboard {
+			problems = append(problems, fmt.Errorf("projects.%s: domain %s is the dashboard hostname", project, domain))
+		}
+	}
+	return problems
+}
+
+// configSetting is one resolved setting of roji config show
+type configSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // "flag", "env ROJI_...", "file <path> [(profile <name>)]" or "default"
+}
+
+// effectiveConfig is the output of roji config show
+type effectiveConfig struct {
+	File     string                       `json:"file,omitempty"`
+	Settings []configSetting              `json:"settings"`
+	Routes   []config.FileRoute           `json:"routes,omitempty"`
+	Defaults map[string]map[string]string `json:"middleware,omitempty"` // roji.* labels by project ("" = all)
+	Domains  map[string]string            `json:"project_domains,omitempty"`
+	TLS      *config.TLSConfig            `json:"tls,omitempty"`
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	effective := effectiveConfig{File: configFile}
+
+	seen := make(map[string]bool)
+	collect := func(flag *pflag.Flag) {
+		if seen[flag.Name] || flag.Hidden || flag.Name == "help" {
+			return
+		}
+		seen[flag.Name] = true
+
+		setting := configSetting{Name: flag.Name, Value: flag.Value.String(), Source: "default"}
+		switch {
+		case flag.Changed:
+			setting.Source = "flag"
+		case fileFlags[flag.Name]:
+			setting.Source = "file " + configFile
+			if _, ok := fileConfig.Profiles[fileConfig.DefaultProfile()].Flags[flag.Name]; ok {
+				setting.Source += " (profile " + fileConfig.DefaultProfile() + ")"
+			}
+		case fromEnv(flag.DefValue, flag.Name):
+			setting.Source = "env ROJI_" + strings.ToUpper(strings.ReplaceAll(flag.Name, "-", "_"))
+		}
+		switch {
+		case flag.Name == "api-token" && setting.Value != "":
+			setting.Value = config.RedactedValue
+		case flag.Name == "hostname-registry":
+			setting.Value = redactLocation(setting.Value)
+		}
+		effective.Settings = append(effective.Settings, setting)
+	}
+	cmd.Root().PersistentFlags().VisitAll(collect)
+	cmd.Root().Flags().VisitAll(collect)
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		source := "env DOCKER_HOST"
+		if dockerHostFromFile {
+			source = "file " + configFile
+			if fileConfig.Profiles[fileConfig.DefaultProfile()].DockerHost != "" {
+				source += " (profile " + fileConfig.DefaultProfile() + ")"
+			}
+		}
+		effective.Settings = append(effective.Settings, configSetting{Name: "docker-host", Value: host, Source: source})
+	}
+	sort.Slice(effective.Settings, func(i, j int) bool { return effective.Settings[i].Name < effective.Settings[j].Name })
+
+	if fileConfig != nil {
+		effective.Routes = fileConfig.Routes
+		// Middleware may carry credentials (roji.tunnel-auth)
+		effective.Defaults = make(map[string]map[string]string)
+		for project, labels := range fileConfig.DefaultLabels() {
+			redacted := make(map[string]string, len(labels))
+			for key, value := range labels {
+				redacted[key] = config.RedactLabel(key, value)
+			}
+			effective.Defaults[project] = redacted
+		}
+		effective.Domains = fileConfig.ProjectDomains()
+		if fileConfig.TLS.MinVersion != "" || len(fileConfig.TLS.Names) > 0 {
+			effective.TLS = &fileConfig.TLS
+		}
+	}
+
+	if configShowJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(effective)
+	}
+	printEffectiveConfig(effective)
+	return nil
+}
+
+func printEffectiveConfig(e effectiveConfig) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range e.Settings {
+		value := s.Value
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, value, s.Source)
+	}
+	tw.Flush()
+	if e.File == "" {
+		return
+	}
+
+	if len(e.Routes) > 0 {
+		fmt.Printf("\nRoutes (%s):\n", e.File)
+		for _, r := range e.Routes {
+			switch {
+			case r.Port != 0:
+				fmt.Printf("  %s → host port %d\n", r.Host, r.Port)
+			case r.Dir != "":
+				spa := ""
+				if r.SPA {
+					spa = " (spa)"
+				}
+				fmt.Printf("  %s → %s%s\n", r.Host, r.Dir, spa)
+			default:
+				fmt.Printf("  %s → %s\n", r.Host, r.URL)
+			}
+		}
+	}
+	if len(e.Defaults) > 0 {
+		fmt.Printf("\nMiddleware (%s):\n", e.File)
+		projects := make([]string, 0, len(e.Defaults))
+		for project := range e.Defaults {
+			projects = append(projects, project)
+		}
+		sort.Strings(projects)
+		for _, project := range projects {
+			name := "every container"
+			if project != "" {
+				name = "project " + project
+			}
+			labels := make([]string, 0, len(e.Defaults[project]))
+			for key, value := range e.Defaults[project] {
+				labels = append(labels, key+"="+value)
+			}
+			sort.Strings(labels)
+			fmt.Printf("  %s: %s\n", name, strings.Join(labels, ", "))
+		}
+	}
+	if len(e.Domains) > 0 {
+		fmt.Printf("\nProject domains (%s):\n", e.File)
+		projects := make([]string, 0, len(e.Domains))
+		for project := range e.Domains {
+			projects = append(projects, project)
+		}
+		sort.Strings(projects)
+		for _, project := range projects {
+			fmt.Printf("  %s → %s\n", project, e.Domains[project])
+		}
+	}
+	if e.TLS != nil {
+		fmt.Printf("\nTLS (%s):\n", e.File)
+		if e.TLS.MinVersion != "" {
+			fmt.Printf("  min-version: %s\n", e.TLS.MinVersion)
+		}
+		if len(e.TLS.Names) > 0 {
+			fmt.Printf("  certificate names: %s\n", strings.Join(e.TLS.Names, ", "))
+		}
+	}
+}
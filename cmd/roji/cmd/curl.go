@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// exitHTTPError is curl's exit code for --fail on a 4xx/5xx response
+const exitHTTPError = 22
+
+var (
+	curlMethod   string
+	curlHeaders  []string
+	curlData     string
+	curlInclude  bool
+	curlLocation bool
+	curlOutput   string
+	curlFail     bool
+	curlMaxTime  time.Duration
+)
+
+var curlCmd = &cobra.Command{
+	Use:     "curl <url>",
+	Aliases: []string{"request"},
+	Short:   "Send an HTTP request to a route, trusting the roji CA",
+	Long: `Sends a request like curl, with the roji CA trusted and *.localhost (and
+names under --domain that don't resolve) reached at 127.0.0.1, so scripts need
+neither -k nor a CA bundle. URLs without a scheme use https, and URLs without
+a port use --https-port.
+
+The response body goes to stdout; requests to the dashboard host carry
+--api-token.
+
+  roji curl api.myapp.dev.localhost/users
+  roji curl -X POST -H "Content-Type: application/json" -d '{"name":"a"}' api.myapp.dev.localhost/users
+  roji curl -d @payload.json -i --fail web.myapp.dev.localhost/upload`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeRouteURL,
+	Run: func(cmd *cobra.Command, args []string) {
+		code, err := runCurl(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "roji curl: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(code)
+	},
+}
+
+func init() {
+	curlCmd.Flags().StringVarP(&curlMethod, "request", "X", "", "Request method (default GET, or POST with --data)")
+	curlCmd.Flags().StringArrayVarP(&curlHeaders, "header", "H", nil, `Request header, e.g. "Accept: application/json" (repeatable)`)
+	curlCmd.Flags().StringVarP(&curlData, "data", "d", "", "Request body; @file reads it from a file, @- from stdin")
+	curlCmd.Flags().BoolVarP(&curlInclude, "include", "i", false, "Print the status line and response headers before the body")
+	curlCmd.Flags().BoolVarP(&curlLocation, "location", "L", false, "Follow redirects")
+	curlCmd.Flags().StringVarP(&curlOutput, "output", "o", "", "Write the body to a file instead of stdout")
+	curlCmd.Flags().BoolVarP(&curlFail, "fail", "f", false, "Exit with 22 on 4xx/5xx responses, without printing the body")
+	curlCmd.Flags().DurationVarP(&curlMaxTime, "max-time", "m", 30*time.Second, "Give up after this long (0 = never)")
+	rootCmd.AddCommand(curlCmd)
+}
+
+// runCurl sends the request and returns the exit code
+func runCurl(rawURL string) (int, error) {
+	target, err := curlURL(rawURL)
+	if err != nil {
+		return 0, err
+	}
+
+	var body io.Reader
+	if curlData != "" {
+		body, err = curlBody(curlData)
+		if err != nil {
+			return 0, err
+		}
+	}
+	method := curlMethod
+	if method == "" {
+		method = http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+	}
+	req, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return 0, err
+	}
+	headers, err := parseHeaderArgs(curlHeaders)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded") // like curl -d
+	}
+	for name, value := range headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("User-Agent", "roji/"+Version)
+
+	client, err := curlClient(target.Hostname())
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if curlInclude {
+		fmt.Printf("%s %s\r\n", resp.Proto, resp.Status)
+		resp.Header.Write(os.Stdout)
+		fmt.Print("\r\n")
+	}
+
+	// Like curl, --fail doesn't print error pages
+	if curlFail && resp.StatusCode >= 400 {
+		fmt.Fprintf(os.Stderr, "roji curl: the requested URL returned error: %d\n", resp.StatusCode)
+		return exitHTTPError, nil
+	}
+
+	out := io.Writer(os.Stdout)
+	if curlOutput != "" {
+		f, err := os.Create(curlOutput)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		out = f
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return 0, nil
+}
+
+// curlURL adds the scheme and the roji port to URLs that omit them
+func curlURL(rawURL string) (*url.URL, error) {
+	if !strings.Contains(rawURL, "://") {
+		scheme := "https://"
+		if noTLS {
+			scheme = "http://"
+		}
+		rawURL = scheme + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Port() == "" {
+		port := httpsPort
+		if u.Scheme == "http" {
+			port = httpPort
+		}
+		if (u.Scheme == "https" && port != 443) || (u.Scheme == "http" && port != 80) {
+			u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(port))
+		}
+	}
+	return u, nil
+}
+
+// curlBody reads --data: a literal, @file or @- for stdin
+func curlBody(data string) (io.Reader, error) {
+	name, ok := strings.CutPrefix(data, "@")
+	if !ok {
+		return strings.NewReader(data), nil
+	}
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
+
+// curlClient trusts the roji CA and only sends --api-token to the dashboard
+func curlClient(hostname string) (*http.Client, error) {
+	var client *http.Client
+	if hostname == dashboardHostname() {
+		var err error
+		client, err = newAPIClient(curlMaxTime)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		tlsConfig, err := clientTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		client = &http.Client{
+			Timeout:   curlMaxTime,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, DialContext: dialRoji},
+		}
+	}
+	if !curlLocation {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client, nil
+}
@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	debugHeaders []string
+	debugMethod  string
+	debugClient  string
+	debugJSON    bool
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Debugging tools for the running server",
+}
+
+var debugRouteCmd = &cobra.Command{
+	Use:   "route [url]",
+	Short: "Show how a request would be routed",
+	Long: `Evaluates requests against the live routing table of the running roji
+server without sending them: the matched route, what runs on the way (LAN
+mode, tunnel credentials, roji.compose mounts, ...) and the final upstream
+URL after rewrites.
+
+With a URL, explains one request. Without one, reads requests line by line,
+interactively or from a pipe:
+
+  [METHOD] URL [Header: value]...
+
+  > web.app.localhost/api/users
+  > POST https://shop.localhost/checkout X-Roji-Secret: s3cret Cf-Ray: 1
+  > quit
+
+  roji debug route web.app.localhost/api -H "Cookie: session=abc"
+  cat requests.txt | roji debug route --json`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeRouteURL,
+	RunE:              runDebugRoute,
+}
+
+var debugTableCmd = &cobra.Command{
+	Use:   "table [url]",
+	Short: "Dump the route table, or trace how a URL is looked up",
+	Long: `Prints the running server's route table: every hostname with its path
+routes in the order they are tried (longest prefix first), then the hostname
+route, and the backend addresses behind each.
+
+With a URL, traces the lookup instead: which routes were tried, why each one
+matched or not, and the full explanation of roji debug route.
+
+  roji debug table
+  roji debug table web.app.localhost/apis/v2`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeRouteURL,
+	RunE:              runDebugTable,
+}
+
+func init() {
+	debugRouteCmd.Flags().StringArrayVarP(&debugHeaders, "header", "H", nil,
+		`Request header, e.g. "Cookie: session=abc" (repeatable)`)
+	debugRouteCmd.Flags().StringVarP(&debugMethod, "method", "X", "GET", "Request method")
+	debugRouteCmd.Flags().StringVar(&debugClient, "client", "",
+		"Client IP to evaluate LAN mode for (default: this machine)")
+	debugRouteCmd.Flags().BoolVar(&debugJSON, "json", false, "Print explanations as JSON")
+	debugTableCmd.Flags().BoolVar(&debugJSON, "json", false, "Print the table or trace as JSON")
+	debugCmd.AddCommand(debugRouteCmd)
+	debugCmd.AddCommand(debugTableCmd)
+	rootCmd.AddCommand(debugCmd)
+}
+
+func runDebugRoute(cmd *cobra.Command, args []string) error {
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		req := proxy.ExplainRequest{Method: debugMethod, URL: args[0], Client: debugClient}
+		req.Headers, err = parseHeaderArgs(debugHeaders)
+		if err != nil {
+			return err
+		}
+		return explainRoute(client, req)
+	}
+
+	interactive := isTerminal(os.Stdin)
+	if interactive {
+		fmt.Println("Type [METHOD] URL [Header: value]... to see how roji routes it; \"quit\" to exit.")
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		if interactive {
+			fmt.Print("> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case "quit", "exit":
+			return nil
+		}
+		if strings.HasPrefix(line, "#") {
+			continue // comments in scripted input
+		}
+
+		req, err := parseRouteLine(line)
+		if err == nil {
+			req.Client = debugClient
+			err = explainRoute(client, req)
+		}
+		if err != nil {
+			if !interactive {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+	}
+	if interactive {
+		fmt.Println()
+	}
+	return scanner.Err()
+}
+
+// parseRouteLine parses "[METHOD] URL [Header: value]...". Header values run
+// until the next token ending in a colon.
+func parseRouteLine(line string) (proxy.ExplainRequest, error) {
+	fields := strings.Fields(line)
+	req := proxy.ExplainRequest{Method: http.MethodGet, Headers: make(map[string]string)}
+	if len(fields) > 1 && fields[0] == strings.ToUpper(fields[0]) && !strings.ContainsAny(fields[0], ".:/") {
+		req.Method, fields = fields[0], fields[1:]
+	}
+	req.URL, fields = fields[0], fields[1:]
+
+	var name string
+	for _, field := range fields {
+		if strings.HasSuffix(field, ":") {
+			name = strings.TrimSuffix(field, ":")
+			req.Headers[name] = ""
+			continue
+		}
+		if name == "" {
+			return req, fmt.Errorf("expected a header name ending in \":\", got %q", field)
+		}
+		req.Headers[name] = strings.TrimSpace(req.Headers[name] + " " + field)
+	}
+	return req, nil
+}
+
+// parseHeaderArgs parses -H "Name: value" flags
+func parseHeaderArgs(args []string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q (want \"Name: value\")", arg)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// explainRoute asks the running server to explain a request and prints the answer
+func explainRoute(client *http.Client, req proxy.ExplainRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(dashboardAPIURL("/_api/v1/explain"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var e proxy.Explanation
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return fmt.Errorf("failed to parse explanation: %w", err)
+	}
+	if debugJSON {
+		return json.NewEncoder(os.Stdout).Encode(e)
+	}
+	printExplanation(&e)
+	return nil
+}
+
+func printExplanation(e *proxy.Explanation) {
+	fmt.Printf("%s%s → %s\n", e.Hostname, e.Path, e.Outcome)
+	if e.Route != nil {
+		fmt.Printf("  route:    %s\n", e.Route.String())
+	}
+	for _, step := range e.Steps {
+		fmt.Printf("  • %s\n", step)
+	}
+	if e.Upstream != "" {
+		fmt.Printf("  upstream: %s\n", e.Upstream)
+		names := make([]string, 0, len(e.UpstreamHeaders))
+		for name := range e.UpstreamHeaders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("            %s: %s\n", name, e.UpstreamHeaders[name])
+		}
+	}
+}
+
+func runDebugTable(cmd *cobra.Command, args []string) error {
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	endpoint := "/_api/v1/debug"
+	if len(args) == 1 {
+		endpoint += "?explain=" + url.QueryEscape(args[0])
+	}
+	resp, err := client.Get(dashboardAPIURL(endpoint))
+	if err != nil {
+		return fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	if len(args) == 0 {
+		var table proxy.RouteTable
+		if err := json.NewDecoder(resp.Body).Decode(&table); err != nil {
+			return fmt.Errorf("failed to parse route table: %w", err)
+		}
+		if debugJSON {
+			return json.NewEncoder(os.Stdout).Encode(table)
+		}
+		printRouteTable(table)
+		return nil
+	}
+
+	var d proxy.DebugExplain
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return fmt.Errorf("failed to parse lookup trace: %w", err)
+	}
+	if debugJSON {
+		return json.NewEncoder(os.Stdout).Encode(d)
+	}
+	printLookupTrace(d.Lookup)
+	if d.Explanation != nil {
+		fmt.Println()
+		printExplanation(d.Explanation)
+	}
+	return nil
+}
+
+func printRouteTable(table proxy.RouteTable) {
+	if len(table.Hosts) == 0 {
+		fmt.Println("No routes")
+		return
+	}
+	for _, host := range table.Hosts {
+		fmt.Println(host.Hostname)
+		for _, route := range host.Routes {
+			prefix := route.PathPrefix
+			if prefix == "" {
+				prefix = "(hostname)"
+			}
+			for i, b := range route.Backends {
+				label := ""
+				if i == 0 {
+					label = fmt.Sprintf("%d. %s", route.Priority, prefix)
+				}
+				state := ""
+				if b.Paused {
+					state = " [paused]"
+				}
+				fmt.Printf("  %-24s %s (%s) → %s%s\n", label, b.Container, b.ContainerID, b.Address, state)
+			}
+		}
+	}
+}
+
+func printLookupTrace(t proxy.LookupTrace) {
+	fmt.Printf("%s%s: %s\n", t.Hostname, t.Path, t.Result)
+	for _, check := range t.Checks {
+		mark := "✗"
+		if check.Matched {
+			mark = "✓"
+		}
+		prefix := check.PathPrefix
+		if prefix == "" {
+			prefix = "(hostname)"
+		}
+		fmt.Printf("  %s %d. %-20s %s\n", mark, check.Priority, prefix, check.Reason)
+	}
+	if len(t.Similar) > 0 {
+		fmt.Printf("  did you mean: %s\n", strings.Join(t.Similar, ", "))
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kan/roji/docker"
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+// doctorProbeLabel is resolved and matched against the certificate as an
+// arbitrary subdomain, standing in for *.{domain}
+const doctorProbeLabel = "roji-doctor"
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose common setup problems",
+	Long: `Checks the pieces roji depends on and prints concrete fixes for each
+failed check:
+
+  - the Docker daemon is reachable
+  - the roji network exists
+  - the HTTP and HTTPS ports are free (or used by roji)
+  - the roji CA is trusted by the operating system
+  - *.{domain} resolves
+  - the server certificate covers the domain, the dashboard and every route
+
+Run it on the machine your browser runs on. Pass --certs-dir (or
+ROJI_CERTS_DIR) when the certificates aren't in /certs. While roji runs,
+its network, domain and ports are checked; otherwise pass the ones it will
+use (--network, --domain, --http-port, --https-port or their ROJI_*
+variables). Exits with 1 if a check fails.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checks := runDoctorChecks(context.Background())
+		if failed := printDoctorChecks(checks); failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	// The server's flags (see root.go), for checking a setup before roji runs
+	doctorCmd.Flags().StringVarP(&networkName, "network", "n", getEnv("ROJI_NETWORK", "roji"),
+		"Docker network roji watches")
+	doctorCmd.Flags().StringVarP(&baseDomain, "domain", "d", getEnv("ROJI_DOMAIN", "dev.localhost"),
+		"Base domain roji serves")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// doctorCheck is the outcome of one `roji doctor` check
+type doctorCheck struct {
+	Name    string
+	OK      bool
+	Skipped bool // a check it depends on failed
+	Message string
+	Fixes   []string // printed under failed checks
+}
+
+func runDoctorChecks(ctx context.Context) []doctorCheck {
+	// A running server answers for the ports it holds and lists the
+	// hostnames the certificate has to cover
+	_, healthErr := checkHealth(false)
+	rojiRunning := healthErr == nil
+	var routes []proxy.RouteInfo
+	if rojiRunning {
+		useServerSettings()
+		routes, _ = fetchRoutes()
+	}
+
+	client, dockerCheck := checkDockerDaemon(ctx)
+	if client != nil {
+		defer client.Close()
+	}
+	checks := []doctorCheck{
+		dockerCheck,
+		checkDockerNetwork(ctx, client),
+		checkPort(httpPort, "--http-port", rojiRunning),
+		checkPort(httpsPort, "--https-port", rojiRunning),
+		checkCATrust(),
+		checkDNS(ctx, routes),
+		checkCertificateNames(routes),
+	}
+	return checks
+}
+
+// useServerSettings replaces the network, domain and ports with the ones the
+// running server uses, so the checks match it whatever flags doctor got
+func useServerSettings() {
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return
+	}
+	doc, err := fetchConfig(client)
+	if err != nil {
+		return
+	}
+	if network := doc.Settings["network"]; network != "" {
+		networkName = network
+	}
+	if domain := doc.Settings["domain"]; domain != "" {
+		baseDomain = domain
+	}
+	if port, err := strconv.Atoi(doc.Settings["http-port"]); err == nil {
+		httpPort = port
+	}
+	if port, err := strconv.Atoi(doc.Settings["https-port"]); err == nil {
+		httpsPort = port
+	}
+}
+
+// printDoctorChecks prints the results with fixes and returns how many failed
+func printDoctorChecks(checks []doctorCheck) int {
+	fmt.Println()
+	fmt.Println("🩺 roji doctor")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	failed := 0
+	for _, check := range checks {
+		mark := "✅"
+		switch {
+		case check.Skipped:
+			mark = "⏭️ "
+		case !check.OK:
+			mark = "❌"
+			failed++
+		}
+		fmt.Printf("  %s %-18s %s\n", mark, check.Name, check.Message)
+		if check.OK || check.Skipped {
+			continue
+		}
+		for _, fix := range check.Fixes {
+			fmt.Printf("       → %s\n", fix)
+		}
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	if failed > 0 {
+		fmt.Printf("%d of %d checks failed\n", failed, len(checks))
+	} else {
+		fmt.Println("All checks passed")
+	}
+	fmt.Println()
+	return failed
+}
+
+// checkDockerDaemon connects to the daemon the docker CLI would use
+func checkDockerDaemon(ctx context.Context) (*docker.Client, doctorCheck) {
+	check := doctorCheck{Name: "Docker daemon"}
+	client, err := docker.NewClient(networkName, baseDomain)
+	if err == nil {
+		err = client.Ping(ctx)
+		if err != nil {
+			client.Close()
+			client = nil
+		}
+	}
+	if err != nil {
+		check.Message = err.Error()
+		check.Fixes = []string{
+			"Start Docker (Docker Desktop, `colima start` or `sudo systemctl start docker`)",
+			"Check DOCKER_HOST and `docker context ls`: roji talks to the same daemon as the docker CLI",
+			"When roji runs in a container, mount the socket: -v /var/run/docker.sock:/var/run/docker.sock:ro",
+		}
+		return nil, check
+	}
+	check.OK = true
+	check.Message = "reachable"
+	return client, check
+}
+
+// checkDockerNetwork verifies that the network roji watches exists
+func checkDockerNetwork(ctx context.Context, client *docker.Client) doctorCheck {
+	check := doctorCheck{Name: fmt.Sprintf("Network %q", networkName)}
+	if client == nil {
+		check.Skipped = true
+		check.Message = "needs the Docker daemon"
+		return check
+	}
+	exists, err := client.NetworkExists(ctx)
+	switch {
+	case err != nil:
+		check.Message = err.Error()
+	case !exists:
+		check.Message = "not found"
+		check.Fixes = []string{
+			"docker network create " + networkName,
+			"Or point --network (ROJI_NETWORK) at the external network your compose files use",
+		}
+	default:
+		check.OK = true
+		check.Message = "exists"
+	}
+	return check
+}
+
+// checkPort verifies that roji can listen on a port, or already does
+func checkPort(port int, flag string, rojiRunning bool) doctorCheck {
+	check := doctorCheck{Name: fmt.Sprintf("Port %d", port)}
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	switch {
+	case err == nil:
+		ln.Close()
+		check.OK = true
+		check.Message = "available"
+	case rojiRunning:
+		check.OK = true
+		check.Message = "in use by roji"
+	default:
+		check.Message, check.Fixes = diagnoseBindError(port, flag, "", err)
+	}
+	return check
+}
+
+// checkCATrust verifies the server certificate against the operating
+// system's trust store, like browsers (except Firefox) do
+func checkCATrust() doctorCheck {
+	check := doctorCheck{Name: "CA trust"}
+	cert, err := loadServerCertificate()
+	if err != nil {
+		check.Message = err.Error()
+		check.Fixes = []string{
+			"Start roji once to generate certificates",
+			"Or point --certs-dir (ROJI_CERTS_DIR) at the certificate directory mounted into roji (e.g., ./certs)",
+		}
+		return check
+	}
+
+	_, err = cert.Verify(x509.VerifyOptions{})
+	if err == nil {
+		check.OK = true
+		check.Message = "trusted by the system"
+		return check
+	}
+	var expired x509.CertificateInvalidError
+	if errors.As(err, &expired) && expired.Reason == x509.Expired {
+		check.Message = fmt.Sprintf("certificate expired on %s", cert.NotAfter.Format("2006-01-02"))
+		check.Fixes = []string{reissueFix()}
+		return check
+	}
+
+	check.Message = "the certificate's CA is not trusted"
+	caPEM := filepath.Join(certsDir, "ca.pem")
+	if _, err := os.Stat(caPEM); err != nil {
+		// Not issued by roji; mkcert is the usual alternative
+		check.Fixes = []string{"If you use mkcert: mkcert -install", "Otherwise install the CA that issued " + filepath.Join(certsDir, "cert.pem")}
+		return check
+	}
+	check.Fixes = systemTrustCommands()
+	if runtime.GOOS != "windows" {
+		check.Fixes = append(check.Fixes, "Firefox (and Chrome on Linux) use their own NSS stores: roji trust")
+	}
+	return check
+}
+
+// checkDNS verifies that the domain and an arbitrary subdomain resolve
+func checkDNS(ctx context.Context, routes []proxy.RouteInfo) doctorCheck {
+	check := doctorCheck{Name: "DNS"}
+	probe := doctorProbeLabel + "." + baseDomain
+
+	var unresolved []string
+	var remote []string
+	for _, name := range []string{baseDomain, probe} {
+		lookupCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		addrs, err := net.DefaultResolver.LookupHost(lookupCtx, name)
+		cancel()
+		if err != nil || len(addrs) == 0 {
+			unresolved = append(unresolved, name)
+			continue
+		}
+		if ip := net.ParseIP(addrs[0]); ip == nil || !ip.IsLoopback() {
+			remote = append(remote, name+" → "+addrs[0])
+		}
+	}
+
+	if len(unresolved) == 0 {
+		check.OK = true
+		check.Message = fmt.Sprintf("%s and *.%s resolve", baseDomain, baseDomain)
+		if len(remote) > 0 {
+			check.Message += " (not to this machine: " + strings.Join(remote, ", ") + ")"
+		}
+		return check
+	}
+
+	check.Message = "can't resolve " + strings.Join(unresolved, ", ")
+	if strings.HasSuffix(baseDomain, ".localhost") || baseDomain == "localhost" {
+		check.Message += " (browsers resolve *.localhost themselves; curl and other tools don't)"
+	}
+	hosts := []string{baseDomain}
+	for _, r := range routes {
+		hosts = append(hosts, r.Hostname)
+	}
+	switch runtime.GOOS {
+	case "windows":
+		check.Fixes = []string{"Add to C:\\Windows\\System32\\drivers\\etc\\hosts: 127.0.0.1 " + strings.Join(uniqueStrings(hosts), " ")}
+	case "darwin":
+		check.Fixes = []string{
+			"Start roji with --dns-port 5354, then: sudo roji resolver install " + baseDomain + " --dns-port 5354",
+			"Or brew install dnsmasq, add address=/" + baseDomain + "/127.0.0.1 to its config, and create /etc/resolver/" + baseDomain + " with nameserver 127.0.0.1",
+			"Or add to /etc/hosts: 127.0.0.1 " + strings.Join(uniqueStrings(hosts), " "),
+		}
+	default:
+		check.Fixes = []string{
+			"Resolve every subdomain with dnsmasq: address=/" + baseDomain + "/127.0.0.1 (systemd-resolved already resolves *.localhost)",
+			"Or add to /etc/hosts: echo \"127.0.0.1 " + strings.Join(uniqueStrings(hosts), " ") + "\" | sudo tee -a /etc/hosts",
+		}
+	}
+	return check
+}
+
+// checkCertificateNames verifies that the server certificate is valid for
+// the domain, any subdomain, the dashboard and each route's hostname.
+// Wildcards only cover one label, so api.myapp.dev.localhost needs
+// *.myapp.dev.localhost.
+func checkCertificateNames(routes []proxy.RouteInfo) doctorCheck {
+	check := doctorCheck{Name: "Certificate names"}
+	cert, err := loadServerCertificate()
+	if err != nil {
+		check.Skipped = true
+		check.Message = "needs the certificate"
+		return check
+	}
+
+	names := []string{baseDomain, doctorProbeLabel + "." + baseDomain}
+	if dashboardHost != "" {
+		names = append(names, dashboardHost)
+	}
+	for _, r := range routes {
+		names = append(names, r.Hostname)
+	}
+	names = uniqueStrings(names)
+
+	var uncovered, missing []string
+	for _, name := range names {
+		if cert.VerifyHostname(name) == nil {
+			continue
+		}
+		display, san := name, certificateName(name)
+		if strings.HasPrefix(name, doctorProbeLabel+".") {
+			display = san
+		}
+		uncovered = append(uncovered, display)
+		missing = append(missing, san)
+	}
+	if len(uncovered) == 0 {
+		check.OK = true
+		check.Message = fmt.Sprintf("covers %d names", len(names))
+		if len(routes) == 0 {
+			check.Message += " (start roji to check route hostnames too)"
+		}
+		return check
+	}
+
+	check.Message = "doesn't cover " + strings.Join(uncovered, ", ")
+	sans := uniqueStrings(append(append([]string{}, cert.DNSNames...), missing...))
+	check.Fixes = []string{
+		"Issue a certificate that lists them, e.g. with mkcert (after `mkcert -install`):",
+		fmt.Sprintf("  mkcert -cert-file %s -key-file %s %s", filepath.Join(certsDir, "cert.pem"), filepath.Join(certsDir, "key.pem"), strings.Join(sans, " ")),
+		"roji picks up the new cert.pem and key.pem within 30 seconds",
+	}
+	return check
+}
+
+// certificateName is the SAN that covers a hostname under the base domain:
+// a wildcard of its parent, or the name itself outside the domain
+func certificateName(hostname string) string {
+	if hostname == baseDomain {
+		return hostname
+	}
+	_, parent, ok := strings.Cut(hostname, ".")
+	if ok && (parent == baseDomain || strings.HasSuffix(parent, "."+baseDomain)) {
+		return "*." + parent
+	}
+	return hostname
+}
+
+// reissueFix tells how to replace an expired server certificate
+func reissueFix() string {
+	if _, err := os.Stat(filepath.Join(certsDir, "ca-key.pem")); err == nil {
+		return fmt.Sprintf("Delete %s and %s and restart roji to issue a new one", filepath.Join(certsDir, "cert.pem"), filepath.Join(certsDir, "key.pem"))
+	}
+	return "Issue a new cert.pem and key.pem (e.g., with mkcert); roji picks them up within 30 seconds"
+}
+
+// loadServerCertificate parses the leaf of cert.pem in the certs directory
+func loadServerCertificate() (*x509.Certificate, error) {
+	path := filepath.Join(certsDir, "cert.pem")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no certificate: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s is not a PEM certificate", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate %s: %w", path, err)
+	}
+	return cert, nil
+}
+
+// uniqueStrings removes duplicates, keeping the first occurrence
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var emulatorCmd = &cobra.Command{
+	Use:   "emulator",
+	Short: "Show how to reach routes from iOS/Android emulators",
+	Long: `Detects installed emulators and prints per-platform setup instructions:
+how to trust the roji CA and which URLs reach each route.
+
+Emulators can't resolve *.localhost to the host machine, so the server must
+run with --emulator (ROJI_EMULATOR=true). Routes are then also served under
+<ip>.nip.io for each emulator's host bridge address, e.g.
+web.app.dev.localhost -> web.app.10.0.2.2.nip.io on the Android emulator.`,
+	RunE: runEmulator,
+}
+
+func init() {
+	rootCmd.AddCommand(emulatorCmd)
+}
+
+func runEmulator(cmd *cobra.Command, args []string) error {
+	routes, err := fetchRoutes()
+	if err != nil {
+		fmt.Printf("⚠️  %v\n   Route URLs are not listed.\n", err)
+	}
+
+	for _, bridge := range proxy.EmulatorBridges {
+		fmt.Println()
+		fmt.Printf("📱 %s (%s)%s\n", bridge.Platform, bridge.IP, detectEmulator(bridge.Platform))
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		for _, line := range emulatorSetup(bridge.Platform) {
+			fmt.Printf("  %s\n", line)
+		}
+		if len(routes) > 0 {
+			fmt.Println()
+		}
+		for _, r := range routes {
+			if r.RedirectTo != "" {
+				continue
+			}
+			fmt.Printf("  https://%s%s\n", emulatorHost(proxy.EmulatorHostname(r.Hostname, baseDomain, bridge.IP)), r.PathPrefix)
+		}
+	}
+	fmt.Println()
+	fmt.Println("nip.io names resolve through public DNS, so the emulator needs internet access.")
+	fmt.Println("If routes don't load, check that roji runs with --emulator.")
+	return nil
+}
+
+// emulatorHost appends the HTTPS port when it isn't the default
+func emulatorHost(hostname string) string {
+	if httpsPort != 443 {
+		return fmt.Sprintf("%s:%d", hostname, httpsPort)
+	}
+	return hostname
+}
+
+// emulatorSetup returns the steps to trust the roji CA on a platform
+func emulatorSetup(platform string) []string {
+	caCrt := filepath.Join(certsDir, "ca.crt")
+	caPEM := filepath.Join(certsDir, "ca.pem")
+
+	switch platform {
+	case "iOS Simulator":
+		return []string{
+			"Trust the roji CA in the booted simulator:",
+			"  xcrun simctl keychain booted add-root-cert " + caPEM,
+		}
+	default:
+		return []string{
+			"Copy the roji CA to the device and install it:",
+			"  adb push " + caCrt + " /sdcard/Download/roji-ca.crt",
+			"  Settings → Security → Encryption & credentials → Install a certificate → CA certificate",
+			"Chrome trusts user CAs; your own app needs a network_security_config that allows them.",
+		}
+	}
+}
+
+// detectEmulator reports whether tooling or a running instance was found
+func detectEmulator(platform string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	switch platform {
+	case "Android Emulator":
+		out, err := exec.CommandContext(ctx, "adb", "devices").Output()
+		if err != nil {
+			return ""
+		}
+		if strings.Contains(string(out), "emulator-") {
+			return " — running"
+		}
+		return " — adb found, no emulator running"
+	case "Genymotion":
+		if _, err := exec.LookPath("gmtool"); err == nil {
+			return " — installed"
+		}
+	case "iOS Simulator":
+		if runtime.GOOS != "darwin" {
+			return " — macOS only"
+		}
+		out, err := exec.CommandContext(ctx, "xcrun", "simctl", "list", "devices", "booted").Output()
+		if err != nil {
+			return ""
+		}
+		if strings.Contains(string(out), "Booted") {
+			return " — running"
+		}
+		return " — no simulator booted"
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kan/roji/config"
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var explainJSON bool
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <url>",
+	Short: "Explain which route serves a URL and why",
+	Long: `Asks the running roji server how it routes a URL: the matched route, why
+it won over the other path prefixes of the hostname (longest prefix first),
+the backend it forwards to and the roji.* labels that produced the route.
+
+Useful when path prefixes of several containers overlap:
+
+  roji explain https://app.localhost/api/v2/users
+
+roji debug table <url> prints the same lookup without the summary.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeRouteURL,
+	RunE:              runExplain,
+}
+
+func init() {
+	explainCmd.Flags().BoolVar(&explainJSON, "json", false, "Print the lookup and explanation as JSON")
+	rootCmd.AddCommand(explainCmd)
+}
+
+// routingLabels are the labels that decide where a request goes, with what
+// they did for the matched route
+var routingLabels = []struct {
+	key  string
+	what string
+}{
+	{config.LabelHost, "sets the hostname"},
+	{config.LabelPath, "sets the path prefix"},
+	{config.LabelPort, "picks the backend port"},
+	{config.LabelCanonicalHost, "moves the route to another hostname"},
+	{config.LabelAllPorts, "routes every exposed port on its own hostname"},
+	{config.LabelCompose, "mounts other routes under path prefixes"},
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Get(dashboardAPIURL("/_api/v1/debug?explain=" + url.QueryEscape(args[0])))
+	if err != nil {
+		return fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var d proxy.DebugExplain
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return fmt.Errorf("failed to parse lookup trace: %w", err)
+	}
+	if explainJSON {
+		return json.NewEncoder(os.Stdout).Encode(d)
+	}
+
+	printExplainSummary(d.Lookup)
+	fmt.Println()
+	fmt.Println("Lookup:")
+	printLookupTrace(d.Lookup)
+	if d.Explanation != nil {
+		fmt.Println()
+		fmt.Println("Handling:")
+		printExplanation(d.Explanation)
+	}
+	return nil
+}
+
+// printExplainSummary prints the matched route, why it matched, its backends
+// and the labels behind it
+func printExplainSummary(t proxy.LookupTrace) {
+	if t.Match == nil {
+		fmt.Printf("%s%s: no route (%s)\n", t.Hostname, t.Path, t.Result)
+		return
+	}
+	m := t.Match
+
+	prefix := m.PathPrefix
+	if prefix == "" {
+		prefix = "(hostname)"
+	}
+	fmt.Printf("Route:   %s %s (priority %d of %d)\n", t.Hostname, prefix, m.Priority, len(t.Checks))
+	if m.PathPrefix == "" {
+		fmt.Println("Why:     the host matched and no path route covers the path")
+	} else {
+		fmt.Printf("Why:     the host matched and %q is the longest prefix of %q\n", m.PathPrefix, t.Path)
+	}
+
+	for i, b := range m.Backends {
+		heading := ""
+		if i == 0 {
+			heading = "Backend:"
+		}
+		state := ""
+		if b.Paused {
+			state = " [paused]"
+		}
+		target := b.Address
+		if b.RedirectTo != "" {
+			target = "redirect to " + b.RedirectTo
+		}
+		fmt.Printf("%-8s %s (%s) → %s%s\n", heading, b.Container, b.ContainerID, target, state)
+	}
+	if len(m.Backends) > 1 {
+		fmt.Printf("         %d replicas, served round-robin\n", len(m.Backends))
+	}
+
+	// Replicas share their labels; explain the first one
+	if len(m.Backends) == 0 {
+		return
+	}
+	b := m.Backends[0]
+	fmt.Println("Labels:")
+	if len(b.Labels) == 0 {
+		fmt.Println("  (none)")
+	} else {
+		keys := make([]string, 0, len(b.Labels))
+		for key := range b.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			note := ""
+			for _, l := range routingLabels {
+				if l.key == key {
+					note = "  ← " + l.what
+				}
+			}
+			fmt.Printf("  %s=%s%s\n", key, b.Labels[key], note)
+		}
+	}
+	if _, ok := b.Labels[config.LabelHost]; !ok && b.Service != "" {
+		fmt.Println("  no roji.host: the hostname comes from the service and project names")
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var exposeCmd = &cobra.Command{
+	Use:   "expose <hostname> <port>",
+	Short: "Give a local port a temporary HTTPS hostname",
+	Long: `Routes a hostname to a port on this machine until you press Ctrl-C, so
+one-off dev servers get HTTPS without labels or config edits. A hostname
+without dots gets the base domain appended.
+
+roji reaches the port through its --host-gateway (host.docker.internal by
+default), so the dev server must listen on an address the gateway can reach
+(e.g., vite --host). The route disappears within 30s if this command is
+killed without cleaning up.
+
+  roji expose myapp.localhost 3000
+  roji expose docs 8000`,
+	Args: cobra.ExactArgs(2),
+	RunE: runExpose,
+}
+
+func init() {
+	rootCmd.AddCommand(exposeCmd)
+}
+
+func runExpose(cmd *cobra.Command, args []string) error {
+	port, err := strconv.Atoi(args[1])
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %q", args[1])
+	}
+
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	exposed, err := exposeRoute(client, args[0], port)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s → localhost:%d\n", exposed.URL, port)
+	fmt.Println("Press Ctrl-C to remove the route")
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	ticker := time.NewTicker(proxy.ExposeLease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sigCh:
+			fmt.Println()
+			return unexposeRoute(client, exposed.Hostname)
+		case <-ticker.C:
+			// Renew the lease; a restarted roji gets the route back this way too
+			if _, err := exposeRoute(client, exposed.Hostname, port); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to renew route: %v\n", err)
+			}
+		}
+	}
+}
+
+// exposedRoute is the response of POST /_api/expose
+type exposedRoute struct {
+	Hostname string `json:"hostname"`
+	URL      string `json:"url"`
+}
+
+// exposeRoute creates or renews a temporary route on the running server
+func exposeRoute(client *http.Client, hostname string, port int) (*exposedRoute, error) {
+	body, err := json.Marshal(map[string]any{"hostname": hostname, "port": port})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post(dashboardAPIURL("/_api/v1/expose"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var route exposedRoute
+	if err := json.NewDecoder(resp.Body).Decode(&route); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &route, nil
+}
+
+// unexposeRoute removes a temporary route from the running server
+func unexposeRoute(client *http.Client, hostname string) error {
+	req, err := http.NewRequest(http.MethodDelete, dashboardAPIURL("/_api/v1/expose?hostname="+url.QueryEscape(hostname)), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to remove route (it expires in %s): %w", proxy.ExposeLease, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	fmt.Printf("Removed %s\n", hostname)
+	return nil
+}
@@ -1,51 +1,141 @@
 package cmd
 
 import (
-	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/kan/roji/proxy"
 	"github.com/spf13/cobra"
 )
 
+// Exit codes for `roji health`; with --deep the first failing check decides
+const (
+	exitHealthy      = 0
+	exitUnreachable  = 1
+	exitDockerEvents = 2
+	exitRoutes       = 3
+	exitCertificates = 4
+	exitListeners    = 5
+)
+
+// checkExitCodes maps deep check names to exit codes
+var checkExitCodes = map[string]int{
+	proxy.CheckDockerEvents: exitDockerEvents,
+	proxy.CheckRoutes:       exitRoutes,
+	proxy.CheckCertificates: exitCertificates,
+	proxy.CheckListeners:    exitListeners,
+}
+
+var (
+	healthDeep bool
+	healthJSON bool
+)
+
 var healthCmd = &cobra.Command{
 	Use:   "health",
 	Short: "Check if roji is healthy",
-	Long:  "Performs a health check against the local roji instance. Exits with 0 if healthy, 1 otherwise.",
+	Long: `Performs a health check against the local roji instance. Exits with 0 if healthy.
+
+With --deep, the server also verifies the Docker event stream, that the route
+table matches a fresh discovery, that certificates are unexpired, and that
+listeners are bound. The exit code identifies the first failing check:
+
+  1  server unreachable or unexpected response
+  2  Docker event stream disconnected
+  3  route table out of sync with discovery
+  4  certificates missing or expired
+  5  listeners not bound`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := checkHealth(); err != nil {
-			fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
-			os.Exit(1)
+		health, err := checkHealth(healthDeep)
+		if err != nil {
+			if healthJSON {
+				json.NewEncoder(os.Stdout).Encode(map[string]string{"status": "unreachable", "error": err.Error()})
+			} else {
+				fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+			}
+			os.Exit(exitUnreachable)
+		}
+
+		code := healthExitCode(health)
+		if healthJSON {
+			json.NewEncoder(os.Stdout).Encode(health)
+		} else {
+			printHealth(health)
 		}
-		fmt.Println("healthy")
-		os.Exit(0)
+		os.Exit(code)
 	},
 }
 
 func init() {
+	healthCmd.Flags().BoolVar(&healthDeep, "deep", false, "Run deep checks (Docker events, routes, certificates, listeners)")
+	healthCmd.Flags().BoolVar(&healthJSON, "json", false, "Output the result as JSON")
 	rootCmd.AddCommand(healthCmd)
 }
 
-func checkHealth() error {
-	// Health check via HTTPS (allow self-signed certificates)
-	client := &http.Client{
-		Timeout: 3 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+func checkHealth(deep bool) (*proxy.HealthResponse, error) {
+	// Health check via HTTPS, trusting the roji CA
+	timeout := 3 * time.Second
+	if deep {
+		timeout = 10 * time.Second
+	}
+	client, err := newAPIClient(timeout)
+	if err != nil {
+		return nil, err
 	}
 
-	resp, err := client.Get("https://localhost/_api/health")
+	url := serverOrigin("localhost") + "/_api/v1/health"
+	if deep {
+		url += "?deep=true"
+	}
+
+	resp, err := client.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	// Deep checks report failures with 503 and a body describing them
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var health proxy.HealthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, fmt.Errorf("failed to parse health response: %w", err)
+	}
+	return &health, nil
+}
+
+// healthExitCode returns the exit code for the first failing check
+func healthExitCode(health *proxy.HealthResponse) int {
+	for _, check := range health.Checks {
+		if !check.OK {
+			if code, ok := checkExitCodes[check.Name]; ok {
+				return code
+			}
+			return exitUnreachable
+		}
+	}
+	if health.Status != "healthy" {
+		return exitUnreachable
 	}
+	return exitHealthy
+}
 
-	return nil
+func printHealth(health *proxy.HealthResponse) {
+	fmt.Println(health.Status)
+	for _, check := range health.Checks {
+		mark := "ok"
+		if !check.OK {
+			mark = "FAIL"
+		}
+		if check.Message != "" {
+			fmt.Printf("  %-4s %-14s %s\n", mark, check.Name, check.Message)
+		} else {
+			fmt.Printf("  %-4s %s\n", mark, check.Name)
+		}
+	}
 }
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kan/roji/docker"
+	"github.com/kan/roji/proxy"
+)
+
+// dockerEventsCheck verifies that the Docker event stream is connected
+func dockerEventsCheck(watcher *docker.Watcher) proxy.HealthCheckFunc {
+	return func(ctx context.Context) proxy.HealthCheck {
+		if !watcher.Connected() {
+			return proxy.HealthCheck{Name: proxy.CheckDockerEvents, Message: "docker event stream is disconnected (reconnecting)"}
+		}
+		return proxy.HealthCheck{Name: proxy.CheckDockerEvents, OK: true}
+	}
+}
+
+// routesCheck verifies that the route table matches a fresh discovery: the
+// same hostnames, served by the same containers
+func routesCheck(client *docker.Client, router *proxy.Router) proxy.HealthCheckFunc {
+	return func(ctx context.Context) proxy.HealthCheck {
+		backends, err := client.DiscoverBackends(ctx)
+		if err != nil {
+			return proxy.HealthCheck{Name: proxy.CheckRoutes, Message: fmt.Sprintf("discovery failed: %v", err)}
+		}
+		discovered := make(map[string]bool, len(backends))
+		for _, b := range backends {
+			discovered[routeCheckKey(b.Hostname, b.ContainerID)] = true
+		}
+		routed := make(map[string]bool)
+		for _, r := range router.ListRoutes() {
+			routed[routeCheckKey(r.Hostname, r.ContainerID)] = true
+		}
+
+		var problems []string
+		if missing := setDifference(discovered, routed); len(missing) > 0 {
+			problems = append(problems, "not routed: "+strings.Join(missing, ", "))
+		}
+		if stale := setDifference(routed, discovered); len(stale) > 0 {
+			problems = append(problems, "no longer discovered: "+strings.Join(stale, ", "))
+		}
+		if len(problems) > 0 {
+			return proxy.HealthCheck{Name: proxy.CheckRoutes, Message: strings.Join(problems, "; ")}
+		}
+		return proxy.HealthCheck{Name: proxy.CheckRoutes, OK: true, Message: fmt.Sprintf("%d routes", len(routed))}
+	}
+}
+
+// routeCheckKey names a route and the container serving it, e.g.
+// "web.localhost (0123456789ab)"
+func routeCheckKey(hostname, containerID string) string {
+	if len(containerID) > 12 {
+		containerID = containerID[:12]
+	}
+	return strings.ToLower(hostname) + " (" + containerID + ")"
+}
+
+// setDifference returns the sorted keys of a that b doesn't have
+func setDifference(a, b map[string]bool) []string {
+	var diff []string
+	for key := range a {
+		if !b[key] {
+			diff = append(diff, key)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// listenersCheck verifies that the HTTP and HTTPS ports accept connections
+// on the --listen address (loopback when roji binds every interface)
+func listenersCheck(address string, ports ...int) proxy.HealthCheckFunc {
+	host := "127.0.0.1"
+	if ip := net.ParseIP(address); ip != nil && !ip.IsUnspecified() {
+		host = address
+	}
+	return func(ctx context.Context) proxy.HealthCheck {
+		var down []string
+		for _, port := range ports {
+			dialer := net.Dialer{Timeout: time.Second}
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+			if err != nil {
+				down = append(down, strconv.Itoa(port))
+				continue
+			}
+			conn.Close()
+		}
+		if len(down) > 0 {
+			return proxy.HealthCheck{Name: proxy.CheckListeners, Message: "not listening on port " + strings.Join(down, ", ")}
+		}
+		return proxy.HealthCheck{Name: proxy.CheckListeners, OK: true}
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var historyLimit int
+
+var historyCmd = &cobra.Command{
+	Use:   "history [hostname]",
+	Short: "Show when routes appeared, changed and went away",
+	Long: `Lists the route changes recorded by the running roji server, newest first,
+with the container that caused each one. Pass a hostname to answer questions
+like "when did api.localhost stop existing?".
+
+The history is kept in memory unless roji runs with --history-file.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeHostname,
+	RunE:              runHistory,
+}
+
+func init() {
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "l", 50, "Maximum number of changes to show (0 = all)")
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	query := url.Values{"limit": {strconv.Itoa(historyLimit)}}
+	if len(args) == 1 {
+		query.Set("hostname", args[0])
+	}
+
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Get(dashboardAPIURL("/_api/v1/history?" + query.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var changes []proxy.RouteChange
+	if err := json.NewDecoder(resp.Body).Decode(&changes); err != nil {
+		return fmt.Errorf("failed to parse history: %w", err)
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No route changes recorded")
+		return nil
+	}
+	for _, c := range changes {
+		target := c.Target
+		if c.PreviousTarget != "" {
+			target = c.PreviousTarget + " -> " + c.Target
+		}
+		fmt.Printf("%s  %-8s %s%s  %s", c.Time.Local().Format("2006-01-02 15:04:05"), c.Action(), c.Hostname, c.PathPrefix, target)
+		if c.ContainerName != "" {
+			fmt.Printf("  (%s %s)", c.ContainerName, c.ShortContainerID())
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/kan/roji/config"
+	"github.com/spf13/cobra"
+)
+
+// lanAddress returns the address other devices reach this machine on, and
+// its mDNS name (--lan). An address given with --lan-ip is used as is;
+// otherwise it's detected, which a container can't do: it only sees its own
+// bridge address.
+func lanAddress(override string) (ip, mdnsName string, err error) {
+	if override != "" {
+		addr, err := netip.ParseAddr(override)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid --lan-ip %q", override)
+		}
+		return addr.String(), "", nil
+	}
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		slog.Warn("LAN URLs need the host's address in a container; set --lan-ip (ROJI_LAN_IP)")
+		return "", "", nil
+	}
+
+	addr, ok := detectLANIP()
+	if !ok {
+		slog.Warn("no LAN address found; set --lan-ip (ROJI_LAN_IP) for LAN URLs")
+		return "", "", nil
+	}
+	return addr.String(), mdnsHostname(), nil
+}
+
+// detectLANIP returns the private IPv4 address of the default route, else of
+// the first interface that is up. Connecting a UDP socket sends nothing.
+func detectLANIP() (netip.Addr, bool) {
+	if conn, err := net.Dial("udp4", "192.0.2.1:9"); err == nil {
+		local, _ := netip.ParseAddrPort(conn.LocalAddr().String())
+		conn.Close()
+		if addr := local.Addr(); addr.IsPrivate() {
+			return addr, true
+		}
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	for _, iface := range ifaces {
+		// Docker's bridges aren't reachable from other machines
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 ||
+			strings.HasPrefix(iface.Name, "docker") || strings.HasPrefix(iface.Name, "br-") || strings.HasPrefix(iface.Name, "veth") {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			prefix, err := netip.ParsePrefix(a.String())
+			if err == nil && prefix.Addr().Is4() && prefix.Addr().IsPrivate() {
+				return prefix.Addr(), true
+			}
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// mdnsHostname returns the name Bonjour/Avahi announce this machine as
+// (e.g., "laptop.local"), or "" when the hostname is unknown
+func mdnsHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return ""
+	}
+	name, _, _ := strings.Cut(strings.ToLower(hostname), ".")
+	return name + ".local"
+}
+
+// applyMagicDNS replaces the base domain with one under the LAN address
+// (--magic-dns). CLI commands apply it too, so they reach the server's
+// dashboard under the same domain.
+func applyMagicDNS(cmd *cobra.Command) error {
+	service, err := config.ParseMagicDNS(magicDNS)
+	if err != nil {
+		return fmt.Errorf("invalid --magic-dns: %w", err)
+	}
+	magicDNS = service
+	if service == "" {
+		return nil
+	}
+	if flag := cmd.Root().Flags().Lookup("domain"); flag.Changed || fileFlags["domain"] || os.Getenv("ROJI_DOMAIN") != "" {
+		return fmt.Errorf("--magic-dns derives the domain from the LAN address; drop --domain")
+	}
+
+	ip, _, err := lanAddress(lanIP)
+	if err != nil {
+		return err
+	}
+	if ip == "" {
+		if cmd != cmd.Root() {
+			return nil // CLI commands keep --domain; lanAddress warned
+		}
+		return fmt.Errorf("--magic-dns needs this machine's LAN address; set --lan-ip (ROJI_LAN_IP)")
+	}
+	domain, err := config.MagicDNSDomain(netip.MustParseAddr(ip), service)
+	if err != nil {
+		return fmt.Errorf("invalid --magic-dns: %w", err)
+	}
+	baseDomain = domain
+	return nil
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// portOwner describes the process listening on a TCP port
+type portOwner struct {
+	PID  int
+	Name string
+}
+
+func (o portOwner) String() string {
+	return fmt.Sprintf("%s (pid %d)", o.Name, o.PID)
+}
+
+// findPortOwner looks up the process listening on a TCP port: in /proc on
+// Linux, else with lsof. It reports false when the process can't be seen,
+// e.g. one of another user without root.
+func findPortOwner(port int) (portOwner, bool) {
+	if runtime.GOOS == "linux" {
+		return procPortOwner(port)
+	}
+	if runtime.GOOS == "windows" {
+		return portOwner{}, false
+	}
+	return lsofPortOwner(port)
+}
+
+// procPortOwner finds the listening socket's inode in /proc/net/tcp{,6},
+// then the process holding a descriptor for it
+func procPortOwner(port int) (portOwner, bool) {
+	inodes := make(map[string]bool)
+	for _, name := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // Header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != "0A" { // 0A = LISTEN
+				continue
+			}
+			_, hexPort, _ := strings.Cut(fields[1], ":")
+			if p, err := strconv.ParseInt(hexPort, 16, 32); err == nil && int(p) == port {
+				inodes["socket:["+fields[9]+"]"] = true
+			}
+		}
+		f.Close()
+	}
+	if len(inodes) == 0 {
+		return portOwner{}, false
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil || !inodes[link] {
+			continue
+		}
+		dir := filepath.Dir(filepath.Dir(fd))
+		pid, _ := strconv.Atoi(filepath.Base(dir))
+		comm, _ := os.ReadFile(filepath.Join(dir, "comm"))
+		return portOwner{PID: pid, Name: strings.TrimSpace(string(comm))}, true
+	}
+	return portOwner{}, false
+}
+
+// lsofPortOwner asks lsof (-F output: "p<pid>" then "c<command>" lines)
+func lsofPortOwner(port int) (portOwner, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "lsof", "-nP", fmt.Sprintf("-iTCP:%d", port), "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		return portOwner{}, false
+	}
+	var owner portOwner
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "p") && owner.PID == 0:
+			owner.PID, _ = strconv.Atoi(line[1:])
+		case strings.HasPrefix(line, "c") && owner.Name == "":
+			owner.Name = line[1:]
+		}
+	}
+	return owner, owner.PID != 0
+}
+
+// portOwnerCommand tells how to find the process listening on a port
+func portOwnerCommand(port int) string {
+	if runtime.GOOS == "windows" {
+		return fmt.Sprintf("Find the process: netstat -ano | findstr :%d", port)
+	}
+	return fmt.Sprintf("Find the process: sudo lsof -nP -iTCP:%d -sTCP:LISTEN", port)
+}
+
+// diagnoseBindError explains why a port failed to bind and how to fix it.
+// flag is the option that sets the port; fallbackFlag, if any, the option
+// that lets roji start on another port instead.
+func diagnoseBindError(port int, flag, fallbackFlag string, err error) (string, []string) {
+	if errors.Is(err, os.ErrPermission) {
+		fixes := []string{
+			"Run roji with Docker, which publishes the port for it (see Installation)",
+			"Or allow the binary to bind low ports: sudo setcap cap_net_bind_service=+ep $(command -v roji)",
+		}
+		if runtime.GOOS == "linux" {
+			fixes = append(fixes, "Or start roji as root and let it switch to your user after binding: sudo roji --user $USER")
+		}
+		return "binding needs privileges", append(fixes, fmt.Sprintf("Or use a port above 1024 with %s", flag))
+	}
+
+	message := "in use by another process"
+	fixes := []string{portOwnerCommand(port)}
+	if owner, ok := findPortOwner(port); ok {
+		message = "in use by " + owner.String()
+		fixes = nil
+		switch owner.Name {
+		case "docker-proxy", "com.docker.backend", "com.docker.vpnkit", "vpnkit":
+			// Docker holds ports published by containers
+			fixes = append(fixes, fmt.Sprintf("A container publishes it; find it with: docker ps --filter publish=%d", port))
+		case "roji":
+			fixes = append(fixes, "Another roji is running; stop it (roji service status shows an installed service)")
+		}
+	}
+	fixes = append(fixes, fmt.Sprintf("Stop it, or move roji to another port with %s (e.g., ports: \"8443:443\" in compose)", flag))
+	if fallbackFlag != "" {
+		fixes = append(fixes, fmt.Sprintf("Or let roji pick another port when this one is taken with %s", fallbackFlag))
+	}
+	return message, fixes
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// dropPrivileges switches roji to the named user once root has bound the
+// ports, with the user's groups (e.g. docker, for the socket) and home
+// directory (for the docker CLI config and contexts)
+func dropPrivileges(name string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return fmt.Errorf("--user: %w", err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("--user: invalid uid %q", u.Uid)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("--user: invalid gid %q", u.Gid)
+	}
+	if os.Getuid() == uid && os.Geteuid() == uid {
+		return nil // Already, e.g. the new process of an upgrade
+	}
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("--user %s needs roji to start as root", name)
+	}
+
+	var groups []int
+	ids, err := u.GroupIds()
+	if err != nil {
+		return fmt.Errorf("--user: groups of %s: %w", name, err)
+	}
+	for _, id := range ids {
+		if g, err := strconv.Atoi(id); err == nil {
+			groups = append(groups, g)
+		}
+	}
+	// Groups and gid first: without root they can't be changed anymore
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("failed to set groups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("failed to set gid %d: %w", gid, err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("failed to set uid %d: %w", uid, err)
+	}
+	os.Setenv("HOME", u.HomeDir)
+	os.Setenv("USER", u.Username)
+
+	slog.Info("dropped privileges", "user", u.Username, "uid", uid, "gid", gid)
+	return nil
+}
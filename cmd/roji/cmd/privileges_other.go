@@ -0,0 +1,11 @@
+//go:build !linux
+
+package cmd
+
+import "errors"
+
+// dropPrivileges is Linux only; elsewhere roji binds low ports without root
+// (macOS) or needs no user switch (Windows)
+func dropPrivileges(name string) error {
+	return errors.New("--user is only supported on Linux")
+}
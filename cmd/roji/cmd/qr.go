@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+	"rsc.io/qr"
+)
+
+var (
+	qrProject string
+	qrCA      bool
+	qrInvert  bool
+)
+
+var qrCmd = &cobra.Command{
+	Use:   "qr <service>",
+	Short: "Print a QR code to open a route on a phone",
+	Long: `Prints a QR code of the URL a phone opens a compose service, container or
+hostname on, to scan with its camera. With --lan, that is the route under the
+machine's LAN address (<route>.<ip>.nip.io); otherwise the route's own URL,
+which phones can only open with a custom --domain they resolve.
+
+The dashboard shows the codes of every route at /qr. Phones must trust the
+roji CA first: --ca prints the code of its download instead.
+
+  roji qr web
+  roji qr --ca`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if qrCA {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: completeService,
+	RunE:              runQR,
+}
+
+func init() {
+	qrCmd.Flags().StringVarP(&qrProject, "project", "p", "", "Compose project of the service (default: the current directory's)")
+	qrCmd.Flags().BoolVar(&qrCA, "ca", false, "Print the code of the CA certificate download")
+	qrCmd.Flags().BoolVar(&qrInvert, "invert", false, "Draw dark modules in the text color (for light terminal themes)")
+	qrCmd.RegisterFlagCompletionFunc("project", completeProject)
+	rootCmd.AddCommand(qrCmd)
+}
+
+func runQR(cmd *cobra.Command, args []string) error {
+	links, err := fetchDeviceLinks()
+	if err != nil {
+		return err
+	}
+
+	if qrCA {
+		if links.CAURL == "" {
+			return fmt.Errorf("roji has no generated CA certificate to download")
+		}
+		return printQR(links.CAURL)
+	}
+
+	routes, err := fetchRoutes()
+	if err != nil {
+		return err
+	}
+	route, err := findServiceRoute(routes, args[0], qrProject, currentComposeProject())
+	if err != nil {
+		return err
+	}
+	for _, link := range links.Routes {
+		if link.Hostname == route.Hostname && link.PathPrefix == route.PathPrefix {
+			if err := printQR(link.URL); err != nil {
+				return err
+			}
+			if links.CAURL != "" {
+				fmt.Printf("Install the CA on the phone first: roji qr --ca (%s)\n", links.CAURL)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no route for %q (see `roji routes`)", args[0])
+}
+
+// fetchDeviceLinks gets the URLs other devices open the routes on
+func fetchDeviceLinks() (proxy.DeviceLinks, error) {
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return proxy.DeviceLinks{}, err
+	}
+
+	resp, err := client.Get(dashboardAPIURL("/_api/v1/qr"))
+	if err != nil {
+		return proxy.DeviceLinks{}, fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return proxy.DeviceLinks{}, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var links proxy.DeviceLinks
+	if err := json.NewDecoder(resp.Body).Decode(&links); err != nil {
+		return proxy.DeviceLinks{}, fmt.Errorf("failed to parse device links: %w", err)
+	}
+	return links, nil
+}
+
+// printQR prints url as a QR code and below it as text
+func printQR(url string) error {
+	code, err := qr.Encode(url, qr.M)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	fmt.Print(renderQR(code, qrInvert))
+	fmt.Println(url)
+	return nil
+}
+
+// renderQR draws a code with half blocks, two modules per character cell,
+// inside a quiet zone of 2 modules. Terminals are mostly dark, so by default
+// light modules are drawn in the text color and dark ones left blank.
+func renderQR(code *qr.Code, invert bool) string {
+	const quiet = 2
+	end := code.Size + quiet
+	drawn := func(x, y int) bool {
+		return y < end && code.Black(x, y) == invert // Outside the code is light
+	}
+
+	var b strings.Builder
+	for y := -quiet; y < end; y += 2 {
+		for x := -quiet; x < end; x++ {
+			switch top, bottom := drawn(x, y), drawn(x, y+1); {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
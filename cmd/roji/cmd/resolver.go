@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// resolverDir holds macOS's per-domain resolver configuration (man 5 resolver)
+const resolverDir = "/etc/resolver"
+
+// resolverMarker starts the files roji writes, so it only removes its own
+const resolverMarker = "# Written by roji"
+
+var resolverCmd = &cobra.Command{
+	Use:   "resolver",
+	Short: "Resolve roji's domain through its DNS server (macOS)",
+	Long: `Points macOS at roji's DNS server (--dns-port) for the base domain by
+writing /etc/resolver/<domain>, so custom domains like *.test resolve to this
+machine in every app without /etc/hosts entries or dnsmasq. roji must run
+with the same --dns-port. Writing /etc/resolver needs sudo.
+
+  roji --domain test --dns-port 5354
+  sudo roji resolver install test --dns-port 5354
+  sudo roji resolver uninstall test
+
+Without arguments, the base domain of ROJI_DOMAIN or the configuration file
+(--config) is configured.`,
+}
+
+var resolverInstallCmd = &cobra.Command{
+	Use:   "install [domain...]",
+	Short: "Write /etc/resolver files pointing at roji's DNS server",
+	RunE:  runResolverInstall,
+}
+
+var resolverUninstallCmd = &cobra.Command{
+	Use:   "uninstall [domain...]",
+	Short: "Remove the /etc/resolver files roji wrote",
+	RunE:  runResolverUninstall,
+}
+
+func init() {
+	resolverCmd.AddCommand(resolverInstallCmd)
+	resolverCmd.AddCommand(resolverUninstallCmd)
+	rootCmd.AddCommand(resolverCmd)
+}
+
+// resolverDomains returns the domains to configure: the arguments, or else
+// the base domain (--domain is a server flag; CLI commands get it from
+// ROJI_DOMAIN or the configuration file)
+func resolverDomains(args []string) ([]string, error) {
+	if runtime.GOOS != "darwin" {
+		return nil, fmt.Errorf("/etc/resolver is macOS only; on %s, forward %s to 127.0.0.1 port --dns-port in your resolver (e.g., dnsmasq, systemd-resolved)", runtime.GOOS, baseDomain)
+	}
+	domains := args
+	if len(domains) == 0 {
+		domains = []string{baseDomain}
+	}
+	for i, domain := range domains {
+		domain = strings.ToLower(strings.Trim(domain, "."))
+		if domain == "" || strings.ContainsAny(domain, "/\\ ") {
+			return nil, fmt.Errorf("invalid domain %q", domains[i])
+		}
+		domains[i] = domain
+	}
+	return domains, nil
+}
+
+// ownResolverFile reports whether roji wrote the resolver file at path. It
+// returns os.ErrNotExist when there is none.
+func ownResolverFile(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(string(data), resolverMarker), nil
+}
+
+// rojiResolverDomains returns the domains of the resolver files roji wrote
+func rojiResolverDomains() []string {
+	entries, _ := os.ReadDir(resolverDir)
+	var domains []string
+	for _, entry := range entries {
+		if own, err := ownResolverFile(filepath.Join(resolverDir, entry.Name())); err == nil && own {
+			domains = append(domains, entry.Name())
+		}
+	}
+	return domains
+}
+
+func runResolverInstall(cmd *cobra.Command, args []string) error {
+	domains, err := resolverDomains(args)
+	if err != nil {
+		return err
+	}
+	if dnsPort == 0 {
+		return fmt.Errorf("set --dns-port to the port roji's DNS server listens on (start roji with it too, e.g., --dns-port 5354)")
+	}
+	if err := os.MkdirAll(resolverDir, 0o755); err != nil {
+		return resolverError(err)
+	}
+
+	for _, domain := range domains {
+		path := filepath.Join(resolverDir, domain)
+		if own, err := ownResolverFile(path); err == nil && !own {
+			return fmt.Errorf("%s exists and wasn't written by roji; remove it first", path)
+		}
+		contents := fmt.Sprintf("%s; roji resolver uninstall removes it\nnameserver 127.0.0.1\nport %d\n", resolverMarker, dnsPort)
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			return resolverError(err)
+		}
+		fmt.Printf("✅ Wrote %s (*.%s → 127.0.0.1:%d)\n", path, domain, dnsPort)
+	}
+	fmt.Printf("   Check with: scutil --dns | grep -A3 %s\n", domains[0])
+	return nil
+}
+
+func runResolverUninstall(cmd *cobra.Command, args []string) error {
+	domains, err := resolverDomains(args)
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		path := filepath.Join(resolverDir, domain)
+		own, err := ownResolverFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			fmt.Printf("No resolver for %s\n", domain)
+			continue
+		case err != nil:
+			return resolverError(err)
+		case !own:
+			fmt.Printf("⚠️  Kept %s: it wasn't written by roji\n", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return resolverError(err)
+		}
+		fmt.Printf("🗑️  Removed %s\n", path)
+	}
+	return nil
+}
+
+// resolverError adds the usual fix to permission errors
+func resolverError(err error) error {
+	if errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("%w (run it with sudo)", err)
+	}
+	return err
+}
+
+// systemHostsFile returns the path of the OS's hosts file (--sync-hosts)
+func systemHostsFile() string {
+	if runtime.GOOS == "windows" {
+		root := os.Getenv("SystemRoot")
+		if root == "" {
+			root = `C:\Windows`
+		}
+		return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
@@ -2,26 +2,148 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/kan/roji/config"
+	"github.com/kan/roji/docker"
+	"github.com/kan/roji/proxy"
 )
 
 var (
 	// Config flags
-	networkName   string
-	baseDomain    string
-	httpPort      int
-	httpsPort     int
-	certsDir      string
-	autoCert      bool
-	dashboardHost string
-	logLevel      string
+	networkName       string
+	baseDomain        string
+	httpPort          int
+	httpsPort         int
+	certsDir          string
+	autoCert          bool
+	dashboardHost     string
+	logLevel          string
+	accessLogFields   string
+	insecure          bool
+	probePorts        bool
+	routeAllPorts     bool
+	backendAddress    string
+	target            string
+	hostnameStability string
+	shutdownTimeout   time.Duration
+	upgradeTimeout    time.Duration
+	readHeaderTimeout time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	listenIPv6        bool
+	listenAddress     string
+	noTLS             bool
+	localOnly         bool
+	allowedHosts      string
+	overridesDir      string
+	trustedProxies    string
+	httpMode          string
+	httpRedirectCode  int
+	plainHTTPPaths    string
+	forwardProxy      bool
+	dnsPort           int
+	syncHosts         bool
+	hostsFile         string
+	runAsUser         string
+	httpFallbackPort  int
+	httpsFallbackPort int
+	extraHTTPSPorts   string
+	eventDebounce     time.Duration
+	inspectCacheTTL   time.Duration
+	allowHSTS         bool
+	publishedHost     string
+	snapshotDelay     time.Duration
+	healthInterval    time.Duration
+	slowRequest       time.Duration
+	autoConnect       bool
+	emulator          bool
+	dockerWait        time.Duration
+
+	// LAN mode flags
+	lanMode   bool
+	lanTrust  string
+	lanIP     string
+	magicDNS  string
+	mdns      bool
+	tailscale bool
+
+	// Host process flags
+	hostRoutes  string
+	hostGateway string
+
+	// Static file routes
+	staticRoutes string
+
+	// External upstream routes
+	externalRoutes string
+
+	// Hostname registry flags
+	hostnameRegistry         string
+	hostnameRegistryInterval time.Duration
+
+	// Drift detection flags
+	manifest string
+
+	// Mock responses
+	mocks string
+
+	// HTML banner for every route
+	banner string
+
+	// Persisted route history
+	historyFile string
+
+	// Token required for the dashboard and /_api/* (sent by CLI commands)
+	apiToken string
+
+	// Error rate alerting flags
+	errorAlertThreshold   string
+	errorAlertWindow      time.Duration
+	errorAlertMinRequests int
+	errorAlertWebhook     string
+	errorAlertDesktop     bool
+
+	// Runtime profiles of roji itself
+	enablePprof bool
+
+	// Configuration file (flags > env > file)
+	configFile  string
+	profileName string       // Profile of the configuration file (--profile)
+	fileConfig  *config.File // Loaded --config with its profile applied; nil without one
+
+	// Outlier detection flags
+	outlierDetection    bool
+	outlierFailures     int
+	outlierLatency      time.Duration
+	outlierEjectionTime time.Duration
+
+	// Backend connection flags
+	backendKeepAlive    bool
+	backendMaxIdleConns int
+	backendIdleTimeout  time.Duration
+	backendConnLifetime time.Duration
 )
 
+// loopbackProxies are the proxies --no-tls trusts without --trusted-proxies
+var loopbackProxies = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "roji",
@@ -29,6 +151,15 @@ var rootCmd = &cobra.Command{
 	Long: `roji - Reverse proxy for local development
 
 Automatically discovers Docker Compose services and makes them accessible via *.localhost with HTTPS.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfigFile(cmd); err != nil {
+			return err
+		}
+		if cmd != cmd.Root() {
+			useFallbackPorts(cmd)
+		}
+		return applyMagicDNS(cmd)
+	},
 	RunE: runServer,
 }
 
@@ -43,18 +174,300 @@ func init() {
 		"Docker network name to watch")
 	rootCmd.Flags().StringVarP(&baseDomain, "domain", "d", getEnv("ROJI_DOMAIN", "dev.localhost"),
 		"Base domain for auto-generated hostnames")
-	rootCmd.Flags().IntVar(&httpPort, "http-port", 80,
-		"HTTP port (for redirect)")
-	rootCmd.Flags().IntVar(&httpsPort, "https-port", 443,
-		"HTTPS port")
-	rootCmd.Flags().StringVar(&certsDir, "certs-dir", getEnv("ROJI_CERTS_DIR", "/certs"),
-		"Directory for TLS certificates")
+	rootCmd.PersistentFlags().IntVar(&httpPort, "http-port", getEnvInt("ROJI_HTTP_PORT", 80),
+		"HTTP port (for redirect; CLI commands use it too)")
+	rootCmd.PersistentFlags().IntVar(&httpsPort, "https-port", getEnvInt("ROJI_HTTPS_PORT", 443),
+		"HTTPS port (CLI commands use it too)")
+	rootCmd.Flags().StringVar(&runAsUser, "user", getEnv("ROJI_USER", ""),
+		"Start as root to bind the ports, then run as this user (Linux)")
+	rootCmd.Flags().IntVar(&httpFallbackPort, "http-fallback-port", getEnvInt("ROJI_HTTP_FALLBACK_PORT", 0),
+		"HTTP port to use when --http-port is taken (e.g., 8080; 0 = fail instead)")
+	rootCmd.Flags().IntVar(&httpsFallbackPort, "https-fallback-port", getEnvInt("ROJI_HTTPS_FALLBACK_PORT", 0),
+		"HTTPS port to use when --https-port is taken (e.g., 8443; 0 = fail instead)")
+	rootCmd.Flags().StringVar(&httpMode, "http-mode", getEnv("ROJI_HTTP_MODE", "redirect"),
+		"What --http-port does: redirect (to HTTPS), proxy (serve every route over HTTP too) or off (don't listen)")
+	rootCmd.Flags().IntVar(&httpRedirectCode, "http-redirect-code", http.StatusMovedPermanently,
+		"Status of the HTTP to HTTPS redirect: 301, 302, 307 or 308 (307/308 keep the method and body)")
+	rootCmd.Flags().StringVar(&plainHTTPPaths, "plain-http-paths", getEnv("ROJI_PLAIN_HTTP_PATHS", ""),
+		"Comma-separated path prefixes proxied over HTTP on every hostname instead of redirected (e.g., /.well-known/acme-challenge/,/hooks/)")
+	rootCmd.Flags().BoolVar(&forwardProxy, "forward-proxy", getEnv("ROJI_FORWARD_PROXY", "false") == "true",
+		"Let browsers use the HTTP port as a proxy for roji's hostnames (CONNECT, PAC file at /proxy.pac), so custom domains need no DNS setup")
+	rootCmd.PersistentFlags().IntVar(&dnsPort, "dns-port", getEnvInt("ROJI_DNS_PORT", 0),
+		"Answer DNS queries for roji's domains with 127.0.0.1 on this UDP port, for roji resolver (e.g., 5354; 0 = off)")
+	rootCmd.Flags().BoolVar(&syncHosts, "sync-hosts", getEnv("ROJI_SYNC_HOSTS", "false") == "true",
+		"Keep a block mapping every route hostname to 127.0.0.1 in --hosts-file, removed on shutdown (needs root; see --user)")
+	rootCmd.Flags().StringVar(&hostsFile, "hosts-file", getEnv("ROJI_HOSTS_FILE", systemHostsFile()),
+		"Hosts file --sync-hosts maintains (e.g., the host's /etc/hosts mounted into roji's container)")
+	rootCmd.PersistentFlags().StringVar(&certsDir, "certs-dir", getEnv("ROJI_CERTS_DIR", "/certs"),
+		"Directory for TLS certificates (CLI commands trust the CA found here)")
 	rootCmd.Flags().BoolVar(&autoCert, "auto-cert", true,
 		"Auto-generate certificates if not present")
 	rootCmd.Flags().StringVar(&dashboardHost, "dashboard", getEnv("ROJI_DASHBOARD", ""),
 		"Dashboard hostname (e.g., dev.localhost)")
+	rootCmd.PersistentFlags().StringVar(&apiToken, "api-token", getEnv("ROJI_API_TOKEN", ""),
+		"Token required for the dashboard and /_api/* (CLI commands send it)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", getEnv("ROJI_CONFIG", ""),
+		"YAML file with settings by flag name, plus routes, middleware, per-project domains and TLS options")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", getEnv("ROJI_PROFILE", ""),
+		"Profile of the configuration file to use (e.g., work); its settings replace the top-level ones")
+	rootCmd.PersistentFlags().BoolVar(&insecure, "insecure", getEnv("ROJI_INSECURE", "false") == "true",
+		"Skip TLS certificate verification when CLI commands talk to the server")
 	rootCmd.Flags().StringVar(&logLevel, "log-level", getEnv("ROJI_LOG_LEVEL", "info"),
 		"Log level (debug, info, warn, error)")
+	rootCmd.Flags().StringVar(&accessLogFields, "access-log-fields", getEnv("ROJI_ACCESS_LOG_FIELDS", ""),
+		"Comma-separated fields of request log lines for routes without roji.access-log-fields (default method,host,path,status,duration,target)")
+	rootCmd.Flags().BoolVar(&probePorts, "probe-ports", getEnv("ROJI_PROBE_PORTS", "false") == "true",
+		"Probe exposed ports of multi-port containers and route to the one that is listening")
+	rootCmd.Flags().BoolVar(&routeAllPorts, "route-all-ports", getEnv("ROJI_ROUTE_ALL_PORTS", "false") == "true",
+		"Route every exposed port of multi-port containers (e.g., web-9229.app.localhost)")
+	rootCmd.Flags().StringVar(&backendAddress, "backend-address", getEnv("ROJI_BACKEND_ADDRESS", defaultBackendAddress()),
+		"How to address backends: ip (container IP), dns (container name via Docker DNS; survives IP changes) or published (published ports on the Docker host; for remote daemons and Docker Desktop hosts)")
+	rootCmd.Flags().StringVar(&target, "target", "", "Alias of --backend-address")
+	rootCmd.Flags().MarkHidden("target")
+	rootCmd.Flags().StringVar(&publishedHost, "published-host", getEnv("ROJI_PUBLISHED_HOST", ""),
+		"Host to dial with --backend-address=published (default: host from DOCKER_HOST or the docker context)")
+	rootCmd.Flags().StringVar(&hostnameStability, "hostname-stability", getEnv("ROJI_HOSTNAME_STABILITY", "dynamic"),
+		"Hostname assignment mode: dynamic (follow project shape) or sticky (keep first hostname until remapped via API)")
+	rootCmd.Flags().BoolVar(&listenIPv6, "ipv6", getEnv("ROJI_IPV6", "true") == "true",
+		"Listen on IPv6 as well as IPv4 (false binds 0.0.0.0 only)")
+	rootCmd.Flags().StringVar(&listenAddress, "listen", getEnv("ROJI_LISTEN", ""),
+		"Address to bind, e.g. 127.0.0.1 to keep roji off the network (default: every interface)")
+	rootCmd.Flags().BoolVar(&localOnly, "local-only", getEnv("ROJI_LOCAL_ONLY", "false") == "true",
+		"Close connections from other machines whatever the bind address, e.g. on a laptop roaming between networks")
+	rootCmd.Flags().StringVar(&allowedHosts, "allowed-hosts", getEnv("ROJI_ALLOWED_HOSTS", ""),
+		"Comma-separated hostnames served besides routes, the dashboard and the base domain (e.g., *.corp.internal; * = any); others get 421")
+	rootCmd.Flags().StringVar(&overridesDir, "overrides-dir", getEnv("ROJI_OVERRIDES_DIR", ""),
+		"Directory roji.overrides may serve files from (e.g., /overrides; empty = overrides off); never the certs dir")
+	rootCmd.Flags().StringVar(&trustedProxies, "trusted-proxies", getEnv("ROJI_TRUSTED_PROXIES", ""),
+		"Comma-separated IPs or CIDRs of proxies in front of roji whose X-Forwarded-For/Forwarded name the client; stripped from others")
+	rootCmd.PersistentFlags().BoolVar(&noTLS, "no-tls", getEnv("ROJI_NO_TLS", "false") == "true",
+		"Serve plain HTTP on --http-port for a TLS-terminating proxy in front of roji, trusting X-Forwarded-Proto from --trusted-proxies (default: loopback; CLI commands use HTTP too)")
+	rootCmd.Flags().StringVar(&extraHTTPSPorts, "extra-https-ports", getEnv("ROJI_EXTRA_HTTPS_PORTS", ""),
+		"Comma-separated additional HTTPS ports serving the same routes (e.g., 8443 for tools that can't use 443)")
+	rootCmd.Flags().DurationVar(&eventDebounce, "event-debounce", 250*time.Millisecond,
+		"Batch container events arriving within this window into one route update (0 = disabled)")
+	rootCmd.Flags().DurationVar(&inspectCacheTTL, "inspect-cache-ttl", 2*time.Second,
+		"Cache Docker inspect results for this long; invalidated by container events (0 = disabled)")
+	rootCmd.Flags().BoolVar(&allowHSTS, "allow-hsts", getEnv("ROJI_ALLOW_HSTS", "false") == "true",
+		"Pass Strict-Transport-Security headers from backends through for *.localhost (stripped by default)")
+	rootCmd.Flags().BoolVar(&autoConnect, "auto-connect", getEnv("ROJI_AUTO_CONNECT", "false") == "true",
+		"Connect running containers labeled roji.enable=true to the roji network automatically")
+	rootCmd.Flags().BoolVar(&emulator, "emulator", getEnv("ROJI_EMULATOR", "false") == "true",
+		"Serve routes to iOS/Android emulators under <bridge-ip>.nip.io (see `roji emulator`)")
+	rootCmd.Flags().BoolVar(&lanMode, "lan", getEnv("ROJI_LAN", "false") == "true",
+		"LAN mode: serve phones and teammates under <lan-ip>.nip.io; unknown client IPs pair with the code shown on the dashboard")
+	rootCmd.Flags().StringVar(&lanIP, "lan-ip", getEnv("ROJI_LAN_IP", ""),
+		"This machine's address on the LAN for --lan URLs and certificates (default: detected; set it when roji runs in a container)")
+	rootCmd.PersistentFlags().StringVar(&magicDNS, "magic-dns", getEnv("ROJI_MAGIC_DNS", ""),
+		"Derive --domain from the LAN address through sslip.io or nip.io (e.g., *.192-168-1-20.sslip.io) so other devices resolve it without DNS setup (CLI commands derive it too)")
+	rootCmd.Flags().BoolVar(&mdns, "mdns", getEnv("ROJI_MDNS", "false") == "true",
+		"Advertise routes on the LAN as .local names over mDNS (web.myproject.dev.localhost -> web.myproject.local)")
+	rootCmd.Flags().BoolVar(&tailscale, "tailscale", getEnv("ROJI_TAILSCALE", "false") == "true",
+		"Serve routes to your tailnet as Tailscale services with Tailscale-issued certificates (web.myproject.dev.localhost -> web-myproject.<tailnet>.ts.net)")
+	rootCmd.Flags().StringVar(&lanTrust, "lan-trust", getEnv("ROJI_LAN_TRUST", ""),
+		"Comma-separated IPs or CIDRs always allowed in LAN mode (loopback and Docker bridge gateways are trusted)")
+	rootCmd.Flags().StringVar(&hostRoutes, "host-routes", getEnv("ROJI_HOST_ROUTES", ""),
+		"Comma-separated hostname=port routes to processes on the Docker host (e.g., vite=5173,api=3000)")
+	rootCmd.Flags().StringVar(&hostGateway, "host-gateway", getEnv("ROJI_HOST_GATEWAY", docker.DefaultHostGateway),
+		"Address of the Docker host for --host-routes (127.0.0.1 when roji runs on the host)")
+	rootCmd.Flags().StringVar(&staticRoutes, "static-routes", getEnv("ROJI_STATIC_ROUTES", ""),
+		"Comma-separated hostname=dir routes served from local directories; append :spa for index.html fallback (e.g., app=/srv/app:spa)")
+	rootCmd.Flags().StringVar(&externalRoutes, "external-routes", getEnv("ROJI_EXTERNAL_ROUTES", ""),
+		"Comma-separated hostname=URL routes to external upstreams (e.g., api=https://api.staging.example.com)")
+	rootCmd.Flags().StringVar(&hostnameRegistry, "hostname-registry", getEnv("ROJI_HOSTNAME_REGISTRY", ""),
+		"Shared hostname reservations to respect: http(s) URL or file path (e.g., in a team git repo)")
+	rootCmd.Flags().DurationVar(&hostnameRegistryInterval, "hostname-registry-interval", 5*time.Minute,
+		"How often to reload the hostname registry (0 = load once at startup)")
+	rootCmd.Flags().DurationVar(&snapshotDelay, "snapshot-delay", 2*time.Second,
+		"Wait this long after a container starts before capturing its roji.snapshot endpoints")
+	rootCmd.Flags().DurationVar(&slowRequest, "slow-request", 2*time.Second,
+		"Log a warning for requests slower than this, with method, path and target (0 = never)")
+	rootCmd.Flags().DurationVar(&healthInterval, "health-interval", 10*time.Second,
+		"How often to probe backends for the dashboard's health dots (0 = disabled)")
+	rootCmd.Flags().DurationVar(&dockerWait, "docker-wait", 2*time.Minute,
+		"How long to wait for the Docker daemon at startup, serving the dashboard meanwhile (0 = fail immediately)")
+	rootCmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", getEnvDuration("ROJI_SHUTDOWN_TIMEOUT", 10*time.Second),
+		"How long to wait for in-flight requests to finish on shutdown")
+	rootCmd.Flags().DurationVar(&upgradeTimeout, "upgrade-timeout", time.Hour,
+		"How long the old process keeps open WebSockets and SSE streams after an upgrade (SIGUSR2)")
+	rootCmd.Flags().DurationVar(&readHeaderTimeout, "read-header-timeout", 10*time.Second,
+		"How long a client may take for the TLS handshake and request headers (0 = no limit)")
+	rootCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 2*time.Minute,
+		"How long a keep-alive connection may wait for its next request")
+	rootCmd.Flags().IntVar(&maxHeaderBytes, "max-header-bytes", 64<<10,
+		"Largest request line and headers accepted, in bytes")
+
+	rootCmd.Flags().StringVar(&manifest, "manifest", getEnv("ROJI_MANIFEST", ""),
+		"Declared configuration (saved from `roji config`) to warn about drift from")
+	rootCmd.Flags().StringVar(&mocks, "mocks", getEnv("ROJI_MOCKS", ""),
+		"JSON file of canned responses per hostname and path, served ahead of routes (reloaded on change)")
+	rootCmd.Flags().StringVar(&banner, "banner", getEnv("ROJI_BANNER", ""),
+		`Banner injected into every route's HTML pages: "true" for project, service and container, or custom text`)
+	rootCmd.Flags().StringVar(&historyFile, "history-file", getEnv("ROJI_HISTORY_FILE", ""),
+		"File the route history is appended to as JSON lines, so it survives restarts; compacted to the latest 1000 (default: kept in memory)")
+	rootCmd.Flags().BoolVar(&enablePprof, "enable-pprof", getEnv("ROJI_ENABLE_PPROF", "false") == "true",
+		"Serve Go runtime profiles of roji at /debug/pprof/ on the dashboard host (the CPU profile and trace need --api-token)")
+
+	// Error rate alerting flags
+	rootCmd.Flags().StringVar(&errorAlertThreshold, "error-alert-threshold", getEnv("ROJI_ERROR_ALERT_THRESHOLD", "0"),
+		"Alert when a route answers at least this share of requests with 5xx, e.g. 0.25 or 25% (0 = disabled)")
+	rootCmd.Flags().DurationVar(&errorAlertWindow, "error-alert-window", getEnvDuration("ROJI_ERROR_ALERT_WINDOW", time.Minute),
+		"Period the 5xx share is computed over (10s to 5m)")
+	rootCmd.Flags().IntVar(&errorAlertMinRequests, "error-alert-min-requests", getEnvInt("ROJI_ERROR_ALERT_MIN_REQUESTS", 10),
+		"Requests a route must serve within the window before it can alert")
+	rootCmd.Flags().StringVar(&errorAlertWebhook, "error-alert-webhook", getEnv("ROJI_ERROR_ALERT_WEBHOOK", ""),
+		"URL to POST error rate alerts to as JSON")
+	rootCmd.Flags().BoolVar(&errorAlertDesktop, "error-alert-desktop", getEnv("ROJI_ERROR_ALERT_DESKTOP", "false") == "true",
+		"Show error rate alerts as desktop notifications (when roji runs on the host)")
+
+	// Load balancing flags
+	rootCmd.Flags().BoolVar(&outlierDetection, "outlier-detection", getEnv("ROJI_OUTLIER_DETECTION", "true") == "true",
+		"Eject misbehaving replicas of scaled services from load balancing")
+	rootCmd.Flags().IntVar(&outlierFailures, "outlier-failures", 5,
+		"Consecutive failures (5xx or connection errors) before a replica is ejected")
+	rootCmd.Flags().DurationVar(&outlierLatency, "outlier-latency", 0,
+		"Treat responses slower than this as failures (0 = disabled)")
+	rootCmd.Flags().DurationVar(&outlierEjectionTime, "outlier-ejection-time", 30*time.Second,
+		"Base ejection duration (doubles on repeated ejections)")
+
+	// Backend connection flags
+	rootCmd.Flags().BoolVar(&backendKeepAlive, "backend-keep-alive", getEnv("ROJI_BACKEND_KEEP_ALIVE", "true") == "true",
+		"Reuse connections to backends (roji.keep-alive=false turns it off per route)")
+	rootCmd.Flags().IntVar(&backendMaxIdleConns, "backend-max-idle-conns", 10,
+		"Idle connections kept open per backend (roji.max-idle-conns)")
+	rootCmd.Flags().DurationVar(&backendIdleTimeout, "backend-idle-timeout", 90*time.Second,
+		"Close connections to backends idle for this long")
+	rootCmd.Flags().DurationVar(&backendConnLifetime, "backend-conn-lifetime", 0,
+		"Stop reusing connections to backends once this old (0 = no limit; roji.conn-lifetime)")
+}
+
+// defaultBackendAddress picks how to reach containers. Docker Desktop keeps
+// bridge IPs inside its VM, so the roji binary on a macOS or Windows host
+// dials published ports instead; inside a container (Linux) IPs work.
+func defaultBackendAddress() string {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		return "published"
+	}
+	return "ip"
+}
+
+// loadConfigFile reads --config and applies the flags it sets that were
+// given neither on the command line nor in the environment. Root flags apply
+// to subcommands too, so CLI commands find the server's --domain and ports.
+func loadConfigFile(cmd *cobra.Command) error {
+	if configFile == "" {
+		if profileName != "" {
+			return fmt.Errorf("--profile %s needs a configuration file (--config)", profileName)
+		}
+		return nil
+	}
+	f, err := config.LoadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load --config: %w", err)
+	}
+	if f, err = withProfile(f); err != nil {
+		return fmt.Errorf("%s: %w", configFile, err)
+	}
+	if problems := applyConfigFile(cmd, f); len(problems) > 0 {
+		return fmt.Errorf("%s: %w", configFile, problems[0])
+	}
+	// The Docker client reads DOCKER_HOST; the environment wins over the file
+	if f.DockerHost != "" && os.Getenv("DOCKER_HOST") == "" {
+		os.Setenv("DOCKER_HOST", f.DockerHost)
+		dockerHostFromFile = true
+	}
+	fileConfig = f
+	return nil
+}
+
+// dockerHostFromFile is set when DOCKER_HOST came from the configuration file
+var dockerHostFromFile bool
+
+// withProfile applies the selected profile to a configuration file: --profile,
+// or else the file's own profile key
+func withProfile(f *config.File) (*config.File, error) {
+	name := profileName
+	if name == "" {
+		name = f.DefaultProfile()
+	}
+	if name == "" {
+		return f, nil
+	}
+	return f.WithProfile(name)
+}
+
+// fileFlags are the flags set from the configuration file
+var fileFlags = make(map[string]bool)
+
+// applyConfigFile sets the flags a configuration file names, returning a
+// problem for each unknown or invalid setting
+func applyConfigFile(cmd *cobra.Command, f *config.File) []error {
+	values := f.FlagValues()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []error
+	for _, name := range names {
+		flag := lookupSetting(cmd, name)
+		if flag == nil {
+			problems = append(problems, fmt.Errorf("unknown setting %q", name))
+			continue
+		}
+		if flag.Changed || fromEnv(flag.DefValue, name) {
+			continue
+		}
+		if err := flag.Value.Set(values[name]); err != nil {
+			problems = append(problems, fmt.Errorf("invalid %s: %w", name, err))
+			continue
+		}
+		fileFlags[name] = true
+	}
+	return problems
+}
+
+// lookupSetting returns the flag a configuration file setting names, or nil
+func lookupSetting(cmd *cobra.Command, name string) *pflag.Flag {
+	if name == "config" || name == "help" {
+		return nil
+	}
+	if flag := cmd.Flags().Lookup(name); flag != nil {
+		return flag
+	}
+	return cmd.Root().Flags().Lookup(name)
+}
+
+// fromEnv reports whether a flag's default came from its environment
+// variable (e.g., ROJI_LOG_LEVEL for --log-level). Flags without one keep
+// their built-in default whatever the environment holds.
+func fromEnv(defValue, flag string) bool {
+	value := os.Getenv("ROJI_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_")))
+	return value != "" && value == defValue
+}
+
+// checkFallbackPorts rejects fallback ports that collide with the ports roji
+// listens on
+func checkFallbackPorts(extraPorts []int) error {
+	for _, p := range []struct {
+		name string
+		port int
+	}{{"--http-fallback-port", httpFallbackPort}, {"--https-fallback-port", httpsFallbackPort}} {
+		if p.port == 0 {
+			continue
+		}
+		if p.port < 1 || p.port > 65535 {
+			return fmt.Errorf("invalid %s %d", p.name, p.port)
+		}
+		if p.port == httpPort || p.port == httpsPort || slices.Contains(extraPorts, p.port) {
+			return fmt.Errorf("invalid %s: %d is already one of roji's ports", p.name, p.port)
+		}
+	}
+	if httpFallbackPort != 0 && httpFallbackPort == httpsFallbackPort {
+		return fmt.Errorf("--http-fallback-port and --https-fallback-port are both %d", httpFallbackPort)
+	}
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -64,10 +477,213 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvInt is getEnv for integer flags; malformed values are reported and
+// ignored
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ignoring %s=%q: not a number\n", key, value)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvDuration is getEnv for duration flags (e.g., "30s"); malformed
+// values are reported and ignored
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ignoring %s=%q: not a duration (e.g., 30s)\n", key, value)
+		return defaultValue
+	}
+	return d
+}
+
 func runServer(cmd *cobra.Command, args []string) error {
 	// Import here to avoid circular dependencies
 	setupLogging(logLevel)
 
+	if hostnameStability != "dynamic" && hostnameStability != "sticky" {
+		return fmt.Errorf("invalid --hostname-stability %q (want dynamic or sticky)", hostnameStability)
+	}
+	if target != "" {
+		backendAddress = target
+	}
+	if backendAddress != "ip" && backendAddress != "dns" && backendAddress != "published" {
+		return fmt.Errorf("invalid --backend-address %q (want ip, dns or published)", backendAddress)
+	}
+
+	routes, err := config.ParseHostRoutes(hostRoutes, baseDomain)
+	if err != nil {
+		return fmt.Errorf("invalid --host-routes: %w", err)
+	}
+	statics, err := config.ParseStaticRoutes(staticRoutes, baseDomain)
+	if err != nil {
+		return fmt.Errorf("invalid --static-routes: %w", err)
+	}
+	externals, err := config.ParseExternalRoutes(externalRoutes, baseDomain)
+	if err != nil {
+		return fmt.Errorf("invalid --external-routes: %w", err)
+	}
+
+	// What only the configuration file can express
+	var (
+		defaultLabels  map[string]map[string]string
+		projectDomains map[string]string
+		tlsMinVersion  uint16 = tls.VersionTLS12
+		certNames      []string
+	)
+	if fileConfig != nil {
+		fileHosts, fileStatics, fileExternals := fileConfig.SplitRoutes(baseDomain)
+		routes = append(routes, fileHosts...)
+		statics = append(statics, fileStatics...)
+		externals = append(externals, fileExternals...)
+		defaultLabels = fileConfig.DefaultLabels()
+		projectDomains = fileConfig.ProjectDomains()
+		if fileConfig.TLS.MinVersion == "1.3" {
+			tlsMinVersion = tls.VersionTLS13
+		}
+		certNames = fileConfig.TLS.Names
+	}
+	var logFields []string
+	if accessLogFields != "" {
+		if logFields, err = config.ParseAccessLogFields(accessLogFields); err != nil {
+			return fmt.Errorf("invalid --access-log-fields: %w", err)
+		}
+	}
+	alertThreshold, err := config.ParseErrorRate(errorAlertThreshold)
+	if err != nil {
+		return fmt.Errorf("invalid --error-alert-threshold: %w", err)
+	}
+	if alertThreshold > 0 && (errorAlertWindow < 10*time.Second || errorAlertWindow > 5*time.Minute) {
+		return fmt.Errorf("invalid --error-alert-window %s: must be between 10s and 5m", errorAlertWindow)
+	}
+
+	listenAddr, err := config.ParseListenAddress(listenAddress)
+	if err != nil {
+		return fmt.Errorf("invalid --listen: %w", err)
+	}
+	hostPatterns, err := config.ParseHostPatterns(allowedHosts)
+	if err != nil {
+		return fmt.Errorf("invalid --allowed-hosts: %w", err)
+	}
+	proxies, err := config.ParsePrefixes(trustedProxies)
+	if err != nil {
+		return fmt.Errorf("invalid --trusted-proxies: %w", err)
+	}
+	// --no-tls serves X-Forwarded-Proto: https as HTTPS; without a list of
+	// proxies, only a TLS terminator on this machine may send it
+	if noTLS && len(proxies) == 0 {
+		proxies = loopbackProxies
+	}
+	if lanMode && localOnly {
+		return fmt.Errorf("--lan serves other devices; --local-only rejects them")
+	}
+	if lanMode && (listenAddr == "127.0.0.1" || listenAddr == "::1") {
+		slog.Warn("--lan has no effect: --listen only accepts connections from this machine", "listen", listenAddr)
+	}
+	var lanAddr, lanName string
+	if lanMode {
+		if lanAddr, lanName, err = lanAddress(lanIP); err != nil {
+			return err
+		}
+	}
+	if mdns && localOnly {
+		return fmt.Errorf("--mdns advertises routes to other devices; --local-only rejects them")
+	}
+	var mdnsAddr string
+	if mdns {
+		if mdnsAddr = lanAddr; !lanMode {
+			if mdnsAddr, _, err = lanAddress(lanIP); err != nil {
+				return err
+			}
+		}
+		if mdnsAddr == "" {
+			return fmt.Errorf("--mdns needs this machine's LAN address; set --lan-ip (ROJI_LAN_IP)")
+		}
+		if !netip.MustParseAddr(mdnsAddr).Is4() {
+			return fmt.Errorf("--mdns advertises IPv4 addresses; set an IPv4 --lan-ip")
+		}
+	}
+	var tailnet string
+	if tailscale {
+		if tailnet, err = proxy.TailnetDomain(context.Background()); err != nil {
+			return fmt.Errorf("--tailscale: %w", err)
+		}
+	}
+	extraPorts, err := config.ParsePorts(extraHTTPSPorts)
+	if err != nil {
+		return fmt.Errorf("invalid --extra-https-ports: %w", err)
+	}
+	if noTLS && len(extraPorts) > 0 {
+		return fmt.Errorf("--extra-https-ports needs HTTPS; drop --no-tls")
+	}
+	if httpMode != "redirect" && httpMode != "proxy" && httpMode != "off" {
+		return fmt.Errorf("invalid --http-mode %q (want redirect, proxy or off)", httpMode)
+	}
+	if noTLS && httpMode == "off" {
+		return fmt.Errorf("--http-mode off leaves nothing to serve with --no-tls")
+	}
+	switch httpRedirectCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("invalid --http-redirect-code %d (want 301, 302, 307 or 308)", httpRedirectCode)
+	}
+	if readHeaderTimeout < 0 {
+		return fmt.Errorf("invalid --read-header-timeout %s: must not be negative", readHeaderTimeout)
+	}
+	if idleTimeout <= 0 {
+		return fmt.Errorf("invalid --idle-timeout %s: must be positive", idleTimeout)
+	}
+	if maxHeaderBytes < 4<<10 {
+		return fmt.Errorf("invalid --max-header-bytes %d: must be at least 4096", maxHeaderBytes)
+	}
+	if backendMaxIdleConns <= 0 {
+		return fmt.Errorf("invalid --backend-max-idle-conns %d: must be positive", backendMaxIdleConns)
+	}
+	if backendIdleTimeout <= 0 {
+		return fmt.Errorf("invalid --backend-idle-timeout %s: must be positive", backendIdleTimeout)
+	}
+	if backendConnLifetime < 0 {
+		return fmt.Errorf("invalid --backend-conn-lifetime %s: must not be negative", backendConnLifetime)
+	}
+	plainPaths, err := config.ParsePathPrefixes(plainHTTPPaths)
+	if err != nil {
+		return fmt.Errorf("invalid --plain-http-paths: %w", err)
+	}
+	if len(plainPaths) > 0 && httpMode == "off" {
+		return fmt.Errorf("--plain-http-paths needs the HTTP port; drop --http-mode off")
+	}
+	if forwardProxy && httpMode == "off" {
+		return fmt.Errorf("--forward-proxy needs the HTTP port; drop --http-mode off")
+	}
+	if dnsPort < 0 || dnsPort > 65535 {
+		return fmt.Errorf("invalid --dns-port %d", dnsPort)
+	}
+	var syncedHostsFile string
+	if syncHosts {
+		if hostsFile == "" {
+			return fmt.Errorf("--sync-hosts needs a --hosts-file")
+		}
+		syncedHostsFile = hostsFile
+	}
+	if err := checkFallbackPorts(extraPorts); err != nil {
+		return err
+	}
+	for _, port := range extraPorts {
+		if port == httpPort || port == httpsPort {
+			return fmt.Errorf("invalid --extra-https-ports: %d is already the HTTP or HTTPS port", port)
+		}
+	}
+
 	// Default dashboard hostname
 	if dashboardHost == "" {
 		// Use the base domain itself as dashboard
@@ -75,14 +691,102 @@ func runServer(cmd *cobra.Command, args []string) error {
 	}
 
 	cfg := Config{
-		NetworkName:   networkName,
-		BaseDomain:    baseDomain,
-		HTTPPort:      httpPort,
-		HTTPSPort:     httpsPort,
-		CertsDir:      certsDir,
-		AutoCert:      autoCert,
-		DashboardHost: dashboardHost,
-		LogLevel:      logLevel,
+		NetworkName:       networkName,
+		BaseDomain:        baseDomain,
+		HTTPPort:          httpPort,
+		HTTPSPort:         httpsPort,
+		CertsDir:          certsDir,
+		AutoCert:          autoCert && !noTLS, // No certificates without TLS
+		DashboardHost:     dashboardHost,
+		LogLevel:          logLevel,
+		AccessLogFields:   logFields,
+		ProbePorts:        probePorts,
+		RouteAllPorts:     routeAllPorts,
+		BackendAddress:    backendAddress,
+		HostnameStability: hostnameStability,
+		ShutdownTimeout:   shutdownTimeout,
+		UpgradeTimeout:    upgradeTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+		ListenIPv6:        listenIPv6,
+		ListenAddress:     listenAddr,
+		NoTLS:             noTLS,
+		HTTPMode:          httpMode,
+		HTTPRedirectCode:  httpRedirectCode,
+		PlainHTTPPaths:    plainPaths,
+		ForwardProxy:      forwardProxy,
+		DNSPort:           dnsPort,
+		HostsFile:         syncedHostsFile,
+		User:              runAsUser,
+		LocalOnly:         localOnly,
+		AllowedHosts:      hostPatterns,
+		OverridesDir:      overridesDir,
+		TrustedProxies:    proxies,
+		HTTPFallbackPort:  httpFallbackPort,
+		HTTPSFallbackPort: httpsFallbackPort,
+		ExtraHTTPSPorts:   extraPorts,
+		EventDebounce:     eventDebounce,
+		InspectCacheTTL:   inspectCacheTTL,
+		AllowHSTS:         allowHSTS,
+		PublishedHost:     publishedHost,
+		SnapshotDelay:     snapshotDelay,
+		HealthInterval:    healthInterval,
+		SlowRequest:       slowRequest,
+		AutoConnect:       autoConnect,
+		Emulator:          emulator,
+		DockerWait:        dockerWait,
+
+		LANMode:   lanMode,
+		LANTrust:  strings.Split(lanTrust, ","),
+		LANIP:     lanAddr,
+		LANName:   lanName,
+		MagicDNS:  magicDNS,
+		MDNS:      mdnsAddr,
+		Tailscale: tailnet,
+
+		HostRoutes:  routes,
+		HostGateway: hostGateway,
+
+		StaticRoutes: statics,
+
+		ExternalRoutes: externals,
+
+		DefaultLabels:  defaultLabels,
+		ProjectDomains: projectDomains,
+		TLSMinVersion:  tlsMinVersion,
+		CertNames:      certNames,
+
+		HostnameRegistry:         hostnameRegistry,
+		HostnameRegistryInterval: hostnameRegistryInterval,
+
+		Manifest: manifest,
+
+		Mocks: mocks,
+
+		Banner: banner,
+
+		HistoryFile: historyFile,
+
+		APIToken: apiToken,
+
+		ErrorAlertThreshold:   alertThreshold,
+		ErrorAlertWindow:      errorAlertWindow,
+		ErrorAlertMinRequests: errorAlertMinRequests,
+		ErrorAlertWebhook:     errorAlertWebhook,
+		ErrorAlertDesktop:     errorAlertDesktop,
+
+		EnablePprof: enablePprof,
+
+		OutlierDetection:    outlierDetection,
+		OutlierFailures:     outlierFailures,
+		OutlierLatency:      outlierLatency,
+		OutlierEjectionTime: outlierEjectionTime,
+
+		BackendKeepAlive:    backendKeepAlive,
+		BackendMaxIdleConns: backendMaxIdleConns,
+		BackendIdleTimeout:  backendIdleTimeout,
+		BackendConnLifetime: backendConnLifetime,
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -97,5 +801,9 @@ func runServer(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
+	// Errors from here on aren't about the flags; usage would bury them.
+	// main prints them.
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
 	return run(ctx, cfg)
 }
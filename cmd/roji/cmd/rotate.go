@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate <hostname>",
+	Short: "Move a route to a fresh browser origin",
+	Long: `Rotates a route's hostname by appending a generation suffix
+(e.g., web.app.localhost -> web-g1.app.localhost). The browser treats the new
+hostname as a brand-new origin, so cookies, localStorage and service workers
+start from scratch without touching browser settings.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHostname,
+	RunE:              runRotate,
+}
+
+func init() {
+	rootCmd.AddCommand(rotateCmd)
+}
+
+func runRotate(cmd *cobra.Command, args []string) error {
+	body, err := json.Marshal(map[string]string{"hostname": args[0]})
+	if err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(10 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(dashboardAPIURL("/_api/v1/rotate"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		Hostname    string `json:"hostname"`
+		NewHostname string `json:"new_hostname"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	fmt.Printf("%s -> https://%s\n", result.Hostname, result.NewHostname)
+	return nil
+}
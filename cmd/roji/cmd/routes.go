@@ -1,52 +1,246 @@
 package cmd
 
 import (
-	"crypto/tls"
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/kan/roji/proxy"
 	"github.com/spf13/cobra"
 )
 
+var (
+	routesJSON  bool
+	routesWide  bool
+	routesWatch bool
+)
+
 var routesCmd = &cobra.Command{
 	Use:   "routes",
 	Short: "List registered routes",
-	Long:  `Display all currently registered routes from the running roji server.`,
-	RunE:  runRoutes,
+	Long: `Display all currently registered routes from the running roji server.
+
+--wide adds container IDs, ports and compose projects; --json prints the
+routes as the API returns them. --watch re-renders whenever a route is added,
+updated or removed (with --json, one array per line), until you press Ctrl-C.
+
+  roji routes --wide
+  roji routes --json | jq -r '.[].Hostname'
+  roji routes --watch`,
+	Args: cobra.NoArgs,
+	RunE: runRoutes,
 }
 
 func init() {
+	routesCmd.Flags().BoolVar(&routesJSON, "json", false, "Print routes as JSON")
+	routesCmd.Flags().BoolVar(&routesWide, "wide", false, "Show container IDs, ports and compose projects")
+	routesCmd.Flags().BoolVarP(&routesWatch, "watch", "w", false, "Re-render when routes change")
 	rootCmd.AddCommand(routesCmd)
 }
 
 func runRoutes(cmd *cobra.Command, args []string) error {
-	// Build API URL
-	apiURL := fmt.Sprintf("https://%s/_api/routes", dashboardHost)
-	if dashboardHost == "" {
-		// Use default
-		apiURL = fmt.Sprintf("https://roji.%s/_api/routes", baseDomain)
-	}
-	if httpsPort != 443 {
-		if dashboardHost == "" {
-			apiURL = fmt.Sprintf("https://roji.%s:%d/_api/routes", baseDomain, httpsPort)
-		} else {
-			apiURL = fmt.Sprintf("https://%s:%d/_api/routes", dashboardHost, httpsPort)
+	if routesWatch {
+		return watchRoutes()
+	}
+	routes, err := fetchRoutes()
+	if err != nil {
+		return err
+	}
+	if routesJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(routes)
+	}
+	fmt.Print(renderRoutes(routes, routesWide))
+	return nil
+}
+
+// renderRoutes formats the route list, as a table with --wide
+func renderRoutes(routes []proxy.RouteInfo, wide bool) string {
+	var b bytes.Buffer
+	if len(routes) == 0 {
+		b.WriteString("No routes registered\n")
+		return b.String()
+	}
+
+	if wide {
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "HOSTNAME\tPATH\tADDRESS\tPORT\tCONTAINER\tID\tSERVICE\tPROJECT\tSTATE")
+		for _, r := range routes {
+			path := r.PathPrefix
+			if path == "" {
+				path = "/"
+			}
+			address, port := r.Target, "-"
+			if host, p, err := net.SplitHostPort(r.Target); err == nil {
+				address, port = host, p
+			}
+			if r.RedirectTo != "" {
+				address = "→ " + r.RedirectTo
+			}
+			id := r.ContainerID
+			if len(id) > 12 {
+				id = id[:12] // like docker ps
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				r.Hostname, path, address, port, dash(r.ContainerName), dash(id),
+				dash(r.ServiceName), dash(r.ProjectName), routeState(r))
 		}
+		tw.Flush()
+		return b.String()
+	}
+
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "📋 Registered Routes (%d):\n", len(routes))
+	b.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	for _, r := range routes {
+		fmt.Fprintf(&b, "  %s\n", r.String())
+	}
+	b.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	b.WriteString("\n")
+	return b.String()
+}
+
+// routeState summarizes why a route might not serve normally
+func routeState(r proxy.RouteInfo) string {
+	var states []string
+	if r.Paused {
+		states = append(states, "paused")
 	}
+	if r.Maintenance {
+		states = append(states, "maintenance")
+	}
+	if r.Tunnel {
+		states = append(states, "tunnel")
+	}
+	if r.Health != nil && r.Health.State != proxy.HealthUp {
+		states = append(states, r.Health.State)
+	}
+	if len(states) == 0 {
+		return "ok"
+	}
+	return strings.Join(states, ",")
+}
 
-	// Fetch routes from API (skip TLS verification for self-signed certs)
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-		Timeout: 5 * time.Second,
+func dash(s string) string {
+	if s == "" {
+		return "-"
 	}
+	return s
+}
 
-	resp, err := client.Get(apiURL)
+// watchRoutes renders the routes, then again after every route event from
+// /_api/events, reconnecting when the stream drops
+func watchRoutes() error {
+	apiClient, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+	// The event stream stays open; only the dial and TLS handshake time out
+	streamClient, err := newAPIClient(0)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	changed := make(chan struct{}, 1)
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- followRouteEvents(ctx, streamClient, changed)
+	}()
+
+	terminal := isTerminal(os.Stdout)
+	for {
+		routes, err := fetchRoutesWith(apiClient)
+		if err != nil {
+			return err
+		}
+		if err := printWatchedRoutes(routes, terminal); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-streamErr:
+			return err
+		case <-changed:
+			// Containers often change together (compose up); render once
+			time.Sleep(200 * time.Millisecond)
+			select {
+			case <-changed:
+			default:
+			}
+		}
+	}
+}
+
+func printWatchedRoutes(routes []proxy.RouteInfo, terminal bool) error {
+	if routesJSON {
+		return json.NewEncoder(os.Stdout).Encode(routes)
+	}
+	var b bytes.Buffer
+	if terminal {
+		b.WriteString("\033[H\033[2J") // home, clear screen
+	}
+	fmt.Fprintf(&b, "roji routes — %s (watching, Ctrl-C to quit)\n", time.Now().Format("15:04:05"))
+	if routesWide {
+		b.WriteString("\n")
+	}
+	b.WriteString(renderRoutes(routes, routesWide))
+	if !terminal {
+		b.WriteString("\n")
+	}
+	fmt.Print(b.String())
+	return nil
+}
+
+// followRouteEvents signals changed for every route event until ctx is done.
+// A dropped stream is resumed from the last event seen; only a failure to
+// connect at all is returned.
+func followRouteEvents(ctx context.Context, client *http.Client, changed chan<- struct{}) error {
+	var lastID uint64
+	connected := false
+	for {
+		err := readRouteEvents(ctx, client, &lastID, changed, &connected)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !connected {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func readRouteEvents(ctx context.Context, client *http.Client, lastID *uint64, changed chan<- struct{}, connected *bool) error {
+	endpoint := "/_api/v1/events?format=ndjson"
+	if *lastID > 0 {
+		endpoint += "&since=" + strconv.FormatUint(*lastID, 10)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dashboardAPIURL(endpoint), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to connect to roji (is it running?): %w", err)
 	}
@@ -54,29 +248,63 @@ func runRoutes(cmd *cobra.Command, args []string) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	if *connected {
+		// Events may have been missed while disconnected
+		notify(changed)
 	}
+	*connected = true
 
-	// Parse JSON response
-	var routes []proxy.RouteInfo
-	if err := json.NewDecoder(resp.Body).Decode(&routes); err != nil {
-		return fmt.Errorf("failed to parse routes: %w", err)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e proxy.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		*lastID = e.ID
+		switch e.Type {
+		case proxy.EventRouteAdded, proxy.EventRouteUpdated, proxy.EventRouteRemoved:
+			notify(changed)
+		}
 	}
+	return scanner.Err()
+}
 
-	// Display routes
-	if len(routes) == 0 {
-		fmt.Println("No routes registered")
-		return nil
+// notify sends on a buffered channel without blocking
+func notify(ch chan<- struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
 	}
+}
 
-	fmt.Println()
-	fmt.Printf("📋 Registered Routes (%d):\n", len(routes))
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	for _, r := range routes {
-		fmt.Printf("  %s\n", r.String())
+// fetchRoutes gets the route table from the running server, trusting the roji CA
+func fetchRoutes() ([]proxy.RouteInfo, error) {
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return nil, err
 	}
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Println()
+	return fetchRoutesWith(client)
+}
 
-	return nil
+// fetchRoutesWith gets the route table with an existing API client
+func fetchRoutesWith(client *http.Client) ([]proxy.RouteInfo, error) {
+	resp, err := client.Get(dashboardAPIURL("/_api/v1/routes"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var routes []proxy.RouteInfo
+	if err := json.NewDecoder(resp.Body).Decode(&routes); err != nil {
+		return nil, fmt.Errorf("failed to parse routes: %w", err)
+	}
+	return routes, nil
 }
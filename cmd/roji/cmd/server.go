@@ -2,28 +2,143 @@ package cmd
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kan/roji/certgen"
+	"github.com/kan/roji/config"
 	"github.com/kan/roji/docker"
 	"github.com/kan/roji/proxy"
 )
 
 // Config holds the server configuration
 type Config struct {
-	NetworkName   string
-	BaseDomain    string
-	HTTPPort      int
-	HTTPSPort     int
-	CertsDir      string
-	AutoCert      bool
-	DashboardHost string
-	LogLevel      string
+	NetworkName       string
+	BaseDomain        string
+	HTTPPort          int
+	HTTPSPort         int
+	CertsDir          string
+	AutoCert          bool
+	DashboardHost     string
+	LogLevel          string
+	AccessLogFields   []string // Request log fields of routes without roji.access-log-fields (nil = default)
+	ProbePorts        bool
+	RouteAllPorts     bool
+	BackendAddress    string         // "ip", "dns" or "published"
+	PublishedHost     string         // Host for published-port addressing (default: from DOCKER_HOST)
+	HostnameStability string         // "dynamic" or "sticky"
+	ShutdownTimeout   time.Duration  // How long to wait for in-flight requests on shutdown
+	UpgradeTimeout    time.Duration  // How long the old process of an upgrade keeps open connections
+	ReadHeaderTimeout time.Duration  // How long clients may take for the TLS handshake and headers (0 = no limit)
+	IdleTimeout       time.Duration  // How long keep-alive connections wait for the next request
+	MaxHeaderBytes    int            // Largest request line and headers accepted
+	ListenIPv6        bool           // Listen on IPv6 as well as IPv4
+	ListenAddress     string         // Address to bind ("" = every interface)
+	ExtraHTTPSPorts   []int          // More HTTPS ports sharing the handler and TLS config
+	NoTLS             bool           // Plain HTTP only, behind a TLS-terminating proxy
+	HTTPMode          string         // HTTP port: "redirect" to HTTPS, "proxy" every route, or "off"
+	HTTPRedirectCode  int            // Status of the HTTP to HTTPS redirect
+	PlainHTTPPaths    []string       // Path prefixes proxied over HTTP instead of redirected
+	ForwardProxy      bool           // The HTTP port also proxies roji's hostnames (CONNECT, PAC file)
+	DNSPort           int            // UDP port answering roji's domains with 127.0.0.1 (0 = off)
+	HostsFile         string         // Hosts file kept mapping route hostnames to 127.0.0.1 (--sync-hosts; "" = off)
+	User              string         // Switch to this user after binding the ports (Linux)
+	LocalOnly         bool           // Close connections from non-loopback addresses
+	AllowedHosts      []string       // Hostname patterns served besides roji's own
+	OverridesDir      string         // Root of roji.overrides files ("" = overrides off)
+	TrustedProxies    []netip.Prefix // Proxies whose forwarding headers name the client
+	HTTPFallbackPort  int            // Bound when HTTPPort is taken (0 = fail instead)
+	HTTPSFallbackPort int            // Bound when HTTPSPort is taken (0 = fail instead)
+	PortsFellBack     bool           // A fallback port replaced HTTPPort or HTTPSPort
+	EventDebounce     time.Duration  // Window for batching container events
+	InspectCacheTTL   time.Duration  // How long inspect results are reused
+	AllowHSTS         bool           // Don't strip backend HSTS headers for *.localhost
+	SnapshotDelay     time.Duration  // Wait before capturing roji.snapshot endpoints after a start
+	HealthInterval    time.Duration  // How often backends are probed (0 = never)
+	SlowRequest       time.Duration  // Warn about requests slower than this (0 = never)
+	AutoConnect       bool           // Attach roji.enable=true containers to the network
+	Emulator          bool           // Serve routes under emulator bridge domains (10.0.2.2.nip.io)
+	DockerWait        time.Duration  // How long to retry the Docker daemon at startup
+
+	// LAN mode: unknown client IPs must pair via the dashboard
+	LANMode  bool
+	LANTrust []string // Extra always-allowed IPs or CIDRs
+	LANIP    string   // Address other devices reach roji on ("" = unknown)
+	LANName  string   // mDNS name of this machine (e.g., "laptop.local"; "" = unknown)
+	MagicDNS string   // Wildcard DNS service BaseDomain was derived from (--magic-dns; "" = off)
+	MDNS     string   // Address routes are advertised on as .local names (--mdns; "" = off)
+
+	Tailscale string // MagicDNS suffix of the tailnet routes are served to (--tailscale; "" = off)
+
+	// Processes on the Docker host routed alongside containers
+	HostRoutes  []config.HostRoute
+	HostGateway string
+
+	// Local directories served by roji (built frontends)
+	StaticRoutes []config.StaticRoute
+
+	// Hostnames proxied to external URLs (e.g., staging APIs)
+	ExternalRoutes []config.ExternalRoute
+
+	// From the configuration file (--config)
+	DefaultLabels  map[string]map[string]string // roji.* labels by compose project ("" = all)
+	ProjectDomains map[string]string            // Compose projects served on their own domain
+	TLSMinVersion  uint16
+	CertNames      []string // Extra names for the generated certificate
+
+	// Shared team hostname registry (URL or file path; empty = disabled)
+	HostnameRegistry         string
+	HostnameRegistryInterval time.Duration
+
+	// Declared configuration (from `roji config`) to detect drift against
+	Manifest string
+
+	// JSON file of canned responses (empty = disabled)
+	Mocks string
+
+	// Banner for the HTML pages of routes without roji.banner (empty = none)
+	Banner string
+
+	// JSON lines file of route changes (empty = in memory only)
+	HistoryFile string
+
+	// Token required for the dashboard and /_api/* (empty = open)
+	APIToken string
+
+	// Alerts on routes answering mostly 5xx
+	ErrorAlertThreshold   float64 // Share of 5xx responses that fires an alert (0 = disabled)
+	ErrorAlertWindow      time.Duration
+	ErrorAlertMinRequests int
+	ErrorAlertWebhook     string // URL alerts are POSTed to (empty = none)
+	ErrorAlertDesktop     bool
+
+	// Serve net/http/pprof at /debug/pprof/ on the dashboard host
+	EnablePprof bool
+
+	// Outlier detection for load-balanced (scaled) services
+	OutlierDetection    bool
+	OutlierFailures     int
+	OutlierLatency      time.Duration
+	OutlierEjectionTime time.Duration
+
+	// Connections to backends
+	BackendKeepAlive    bool
+	BackendMaxIdleConns int
+	BackendIdleTimeout  time.Duration
+	BackendConnLifetime time.Duration
 }
 
 func setupLogging(level string) {
@@ -46,19 +161,90 @@ func setupLogging(level string) {
 }
 
 func run(ctx context.Context, cfg Config) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	// Sockets from systemd or a previous roji (upgrade) replace binding the
+	// ports. Bound first, as a fallback port changes the URLs shown.
+	httpLn, httpsLns, err := serverListeners(&cfg)
+	if err != nil {
+		return err
+	}
+	// Shutting down the servers closes them too; this covers failing earlier
+	defer func() {
+		if httpLn != nil {
+			httpLn.Close()
+		}
+		for _, ln := range httpsLns {
+			ln.Close()
+		}
+	}()
+	// The DNS server for resolver files (roji resolver), bound with the ports
+	var dnsConn net.PacketConn
+	if cfg.DNSPort != 0 {
+		if dnsConn, err = dnsListener(cfg); err != nil {
+			return err
+		}
+		defer dnsConn.Close()
+	}
+	// So is the hosts file, which only root may write (--sync-hosts)
+	var hostsFile *os.File
+	if cfg.HostsFile != "" {
+		if hostsFile, err = os.OpenFile(cfg.HostsFile, os.O_RDWR, 0); err != nil {
+			return fmt.Errorf("--sync-hosts can't write the hosts file (start roji with sudo and --user $USER): %w", err)
+		}
+		defer hostsFile.Close()
+	}
+	// Root was only needed for the ports; certificates and state are the user's
+	if cfg.User != "" {
+		if err := dropPrivileges(cfg.User); err != nil {
+			return err
+		}
+	}
+
 	printBanner(cfg)
 
 	// Auto-generate certificates if enabled
 	if cfg.AutoCert {
 		certGen := certgen.NewGenerator(cfg.CertsDir, cfg.BaseDomain)
+		if cfg.Emulator {
+			certGen.AddNames(proxy.EmulatorCertNames()...)
+		}
+		if cfg.LANIP != "" {
+			certGen.AddNames(proxy.LANCertNames(cfg.LANIP, cfg.LANName)...)
+		}
+		if cfg.MDNS != "" {
+			certGen.AddNames(proxy.MDNSCertNames()...)
+		}
+		for _, domain := range cfg.ProjectDomains {
+			certGen.AddNames(domain, "*."+domain)
+		}
+		certGen.AddNames(cfg.CertNames...)
 		if err := certGen.EnsureCerts(); err != nil {
 			return fmt.Errorf("failed to ensure certificates: %w", err)
 		}
 		slog.Info("certificates ready", "dir", cfg.CertsDir)
 	}
+	// CLI commands find a fallback port through the ports file
+	if err := writePortsFile(cfg); err != nil {
+		slog.Warn("CLI commands need --http-port/--https-port to reach roji", "error", err)
+	}
 
 	// Initialize Docker client
-	dockerClient, err := docker.NewClient(cfg.NetworkName, cfg.BaseDomain)
+	dockerClient, err := docker.NewClient(cfg.NetworkName, cfg.BaseDomain,
+		docker.WithPortProbing(cfg.ProbePorts),
+		docker.WithAllPorts(cfg.RouteAllPorts),
+		docker.WithBackendAddressing(cfg.BackendAddress),
+		docker.WithPublishedHost(cfg.PublishedHost),
+		docker.WithInspectCache(cfg.InspectCacheTTL),
+		docker.WithHostnameStability(cfg.HostnameStability),
+		docker.WithAutoConnect(cfg.AutoConnect),
+		docker.WithHostRoutes(cfg.HostRoutes, cfg.HostGateway),
+		docker.WithStaticRoutes(cfg.StaticRoutes),
+		docker.WithExternalRoutes(cfg.ExternalRoutes),
+		docker.WithDefaultLabels(cfg.DefaultLabels),
+		docker.WithProjectDomains(cfg.ProjectDomains),
+		registryOpt(cfg.HostnameRegistry))
 	if err != nil {
 		return fmt.Errorf("failed to create docker client: %w", err)
 	}
@@ -87,52 +273,397 @@ func run(ctx context.Context, cfg Config) error {
 		BaseDomain:    cfg.BaseDomain,
 		HTTPPort:      cfg.HTTPPort,
 		HTTPSPort:     cfg.HTTPSPort,
+		NoTLS:         cfg.NoTLS,
+	}
+
+	var handlerOpts []proxy.HandlerOption
+	if cfg.OutlierDetection {
+		outlierCfg := proxy.DefaultOutlierConfig()
+		outlierCfg.ConsecutiveFailures = cfg.OutlierFailures
+		outlierCfg.LatencyThreshold = cfg.OutlierLatency
+		outlierCfg.BaseEjectionTime = cfg.OutlierEjectionTime
+		outliers := proxy.NewOutlierDetector(outlierCfg)
+		outliers.Watch(ctx, router)
+		handlerOpts = append(handlerOpts, proxy.WithOutlierDetector(outliers))
+	}
+
+	handlerOpts = append(handlerOpts, proxy.WithBackendTransport(proxy.TransportConfig{
+		DisableKeepAlives:   !cfg.BackendKeepAlive,
+		MaxIdleConnsPerHost: cfg.BackendMaxIdleConns,
+		IdleConnTimeout:     cfg.BackendIdleTimeout,
+		MaxConnLifetime:     cfg.BackendConnLifetime,
+	}))
+
+	if cfg.HostnameStability == docker.HostnameStabilitySticky {
+		handlerOpts = append(handlerOpts, proxy.WithHostnameRemapper(&hostnameRemapper{client: dockerClient, router: router}))
+	}
+
+	handlerOpts = append(handlerOpts, proxy.WithHSTS(cfg.AllowHSTS))
+	handlerOpts = append(handlerOpts, proxy.WithAllowedHosts(allowedHostPatterns(cfg)))
+	if cfg.OverridesDir != "" {
+		handlerOpts = append(handlerOpts, proxy.WithOverridesDir(cfg.OverridesDir, cfg.CertsDir))
+	}
+	if cfg.Emulator {
+		handlerOpts = append(handlerOpts, proxy.WithEmulatorAccess(true))
+		slog.Info("emulator access enabled; run `roji emulator` for setup instructions")
+	}
+	handlerOpts = append(handlerOpts, proxy.WithHostnameRotator(&hostnameRotator{client: dockerClient, router: router}))
+	handlerOpts = append(handlerOpts, proxy.WithRouteExposer(&routeExposer{client: dockerClient, router: router}))
+
+	// Endpoint snapshots across restarts (roji.snapshot)
+	snapshots := proxy.NewSnapshotter(cfg.SnapshotDelay)
+	handlerOpts = append(handlerOpts, proxy.WithSnapshots(snapshots))
+
+	// Expiring share links (roji share)
+	shareKey, err := loadShareKey(filepath.Join(cfg.CertsDir, "share.key"))
+	if err != nil {
+		slog.Warn("share links won't survive a restart", "error", err)
+	}
+	handlerOpts = append(handlerOpts, proxy.WithShareLinks(proxy.NewShareSigner(shareKey)))
+
+	var devices *proxy.DeviceAllowList
+	if cfg.LANMode {
+		devices, err = newDeviceAllowList(cfg)
+		if err != nil {
+			return err
+		}
+		handlerOpts = append(handlerOpts, proxy.WithLANAccess(devices))
+		if cfg.LANIP != "" {
+			handlerOpts = append(handlerOpts, proxy.WithLANHostnames(cfg.LANIP, cfg.LANName))
+		}
+	}
+
+	if cfg.MDNS != "" {
+		handlerOpts = append(handlerOpts, proxy.WithMDNSHostnames(true))
+	}
+	var tailscaleServe *proxy.TailscaleServe
+	if cfg.Tailscale != "" {
+		tailscaleServe = proxy.NewTailscaleServe(router, cfg.BaseDomain, cfg.Tailscale, tailscaleOrigin(cfg))
+		handlerOpts = append(handlerOpts, proxy.WithTailscale(tailscaleServe))
+	}
+
+	// Dashboard timeline of image revisions (org.opencontainers.image.*)
+	changelog := proxy.NewChangelog()
+	handlerOpts = append(handlerOpts, proxy.WithChangelog(changelog))
+
+	// Request inspector for roji.record routes
+	handlerOpts = append(handlerOpts, proxy.WithRecorder(proxy.NewRecorder()))
+
+	// Reload roji.live-reload pages when their container restarts
+	liveReload := proxy.NewLiveReload()
+	handlerOpts = append(handlerOpts, proxy.WithLiveReload(liveReload))
+	starts := &startObserver{snapshots: snapshots, changelog: changelog, liveReload: liveReload}
+
+	// Route, health and certificate changes for editor extensions and scripts
+	events := proxy.NewEventHub()
+	handlerOpts = append(handlerOpts, proxy.WithEvents(events))
+
+	// When routes appeared and went away (/_api/history)
+	history, err := proxy.NewRouteHistory(cfg.HistoryFile)
+	if err != nil {
+		return err
+	}
+	handlerOpts = append(handlerOpts, proxy.WithRouteHistory(history))
+
+	// Backend health dots on the dashboard
+	var prober *proxy.HealthProber
+	if cfg.HealthInterval > 0 {
+		prober = proxy.NewHealthProber(router, cfg.HealthInterval)
+		prober.PublishTo(events)
+		handlerOpts = append(handlerOpts, proxy.WithHealthProber(prober))
+	}
+
+	// Deep health checks (/_api/health?deep=true)
+	watcher := docker.NewWatcher(dockerClient)
+	handlerOpts = append(handlerOpts, proxy.WithHealthChecks(
+		dockerEventsCheck(watcher),
+		routesCheck(dockerClient, router),
+		listenersCheck(cfg.ListenAddress, servedPorts(cfg)...),
+	))
+
+	// Configuration fingerprint and drift from the declared manifest
+	handlerOpts = append(handlerOpts, proxy.WithSettings(configSettings(cfg)))
+	if cfg.Manifest != "" {
+		manifest, err := proxy.LoadManifest(cfg.Manifest)
+		if err != nil {
+			return err
+		}
+		handlerOpts = append(handlerOpts, proxy.WithManifest(manifest))
+	}
+
+	// Canned responses for endpoints that don't exist yet
+	if cfg.Mocks != "" {
+		mocks, err := proxy.LoadMocks(cfg.Mocks, cfg.BaseDomain)
+		if err != nil {
+			return err
+		}
+		handlerOpts = append(handlerOpts, proxy.WithMocks(mocks))
+		go mocks.Run(ctx, mocksReloadInterval)
+	}
+
+	if cfg.Banner != "" {
+		handlerOpts = append(handlerOpts, proxy.WithBanner(cfg.Banner))
+	}
+
+	if cfg.APIToken != "" {
+		handlerOpts = append(handlerOpts, proxy.WithAPIToken(cfg.APIToken))
+	}
+
+	if cfg.EnablePprof {
+		handlerOpts = append(handlerOpts, proxy.WithPprof())
+		slog.Info("profiling enabled", "url", "https://"+cfg.DashboardHost+proxy.PprofPath)
+		if cfg.APIToken == "" {
+			slog.Warn("the CPU profile and the trace need --api-token; serving the other profiles only")
+		}
+	}
+
+	if cfg.SlowRequest > 0 {
+		handlerOpts = append(handlerOpts, proxy.WithSlowRequests(cfg.SlowRequest))
+	}
+
+	if cfg.ErrorAlertThreshold > 0 {
+		handlerOpts = append(handlerOpts, proxy.WithErrorAlerts(proxy.ErrorAlertConfig{
+			Threshold:   cfg.ErrorAlertThreshold,
+			Window:      cfg.ErrorAlertWindow,
+			MinRequests: cfg.ErrorAlertMinRequests,
+			Webhook:     cfg.ErrorAlertWebhook,
+			Desktop:     cfg.ErrorAlertDesktop,
+		}))
+	}
+
+	if cfg.AccessLogFields != nil {
+		handlerOpts = append(handlerOpts, proxy.WithAccessLogFields(cfg.AccessLogFields))
 	}
 
-	handler := proxy.NewHandler(router, cfg.DashboardHost, statusConfig)
+	handler := proxy.NewHandler(router, cfg.DashboardHost, statusConfig, handlerOpts...)
+
+	if dnsConn != nil {
+		dns := proxy.NewDNSServer(servedDomains(cfg)...)
+		go func() {
+			if err := dns.Serve(dnsConn); err != nil {
+				slog.Warn("DNS server stopped", "error", err)
+			}
+		}()
+	}
+
+	// Start HTTP and HTTPS servers
+	// They come up before Docker so the dashboard can explain a slow daemon
+	var certs *proxy.CertReloader
+	var servers []*http.Server
+	var forward *proxy.ForwardProxy
+	if cfg.ForwardProxy {
+		forward = proxy.NewForwardProxy(handler)
+	}
+	if httpLn != nil {
+		servers = append(servers, startHTTPServer(cfg, router, handler, forward, guardListener(cfg, httpLn)))
+	}
+	if forward != nil {
+		// CONNECT tunnels to the HTTP port (e.g., ws://)
+		servers = append(servers, startHTTPServer(cfg, router, handler, forward, forward.PlainListener()))
+	}
+	if !cfg.NoTLS {
+		certs, err = proxy.NewCertReloader(cfg.CertsDir, events)
+		if err != nil {
+			for _, server := range servers {
+				server.Close()
+			}
+			return fmt.Errorf("failed to load TLS config: %w", err)
+		}
+		// Every HTTPS port serves the same handler with the same certificates
+		tlsConfig := loadTLSConfig(certs, cfg.TLSMinVersion)
+		for _, ln := range httpsLns {
+			servers = append(servers, startHTTPSServer(cfg, trustProxies(cfg, handler), tlsConfig, guardListener(cfg, ln)))
+		}
+		if forward != nil {
+			servers = append(servers, startHTTPSServer(cfg, trustProxies(cfg, handler), tlsConfig, forward.TLSListener()))
+		}
+	}
+
+	if err := waitForDocker(ctx, cfg, dockerClient, handler); err != nil {
+		shutdownServers(context.Background(), cfg.ShutdownTimeout, handler, false, servers...)
+		return err
+	}
+
+	// Shared team hostname reservations; an unreachable registry isn't fatal
+	if _, err := dockerClient.RefreshHostnameRegistry(ctx); err != nil {
+		slog.Warn("failed to load hostname registry", "error", err)
+	}
+	if devices != nil {
+		trustBridgeGateways(ctx, devices, dockerClient)
+	}
 
 	// Discover existing containers
-	if err := discoverExisting(ctx, dockerClient, router); err != nil {
+	if err := dockerClient.ConnectLabeledContainers(ctx); err != nil {
+		slog.Warn("failed to auto-connect containers", "error", err)
+	}
+	if err := discoverExisting(ctx, dockerClient, router, starts); err != nil {
+		shutdownServers(context.Background(), cfg.ShutdownTimeout, handler, false, servers...)
 		return fmt.Errorf("failed to discover containers: %w", err)
 	}
 
+	history.Follow(ctx, events)
+	events.WatchRoutes(ctx, router)
+	var hostsSync *proxy.HostsFileSync
+	if hostsFile != nil {
+		hostsSync = proxy.NewHostsFileSync(hostsFile, router, cfg.DashboardHost)
+		hostsSync.Watch(ctx)
+	}
+	var responder *proxy.MDNSResponder
+	if cfg.MDNS != "" {
+		// After discovery, so the first announcement has every route
+		responder = proxy.NewMDNSResponder(router, cfg.BaseDomain, netip.MustParseAddr(cfg.MDNS), mdnsHostname())
+		go func() {
+			if err := responder.Run(ctx); err != nil {
+				slog.Warn("mDNS advertisement stopped", "error", err)
+			}
+		}()
+	}
+	if tailscaleServe != nil {
+		tailscaleServe.Watch(ctx)
+	}
+	if certs != nil {
+		go certs.Run(ctx, certReloadInterval)
+	}
+
 	// Start watching for container events
-	watcher := docker.NewWatcher(dockerClient)
+	// Bursts of events (e.g., docker compose up) are applied as one update
 	eventCh := watcher.Watch(ctx)
 
-	go handleEvents(ctx, dockerClient, router, eventCh)
+	go handleEvents(ctx, dockerClient, router, starts, docker.Debounce(ctx, eventCh, cfg.EventDebounce))
 
-	// Start HTTP and HTTPS servers
-	httpServer := startHTTPServer(cfg)
-	httpsServer, err := startHTTPSServer(cfg, handler)
-	if err != nil {
-		return err
+	if cfg.HostnameRegistry != "" {
+		go refreshHostnameRegistry(ctx, dockerClient, router, cfg.HostnameRegistryInterval)
+	}
+	if cfg.Manifest != "" {
+		go watchDrift(ctx, handler, driftInterval)
+	}
+	if prober != nil {
+		go prober.Run(ctx)
+	}
+	if cfg.ErrorAlertThreshold > 0 {
+		go watchErrorRates(ctx, handler, errorAlertInterval)
 	}
 
 	// Print registered routes
 	printRoutes(router)
+	printLANRoutes(cfg, router)
+	notifyReady()
 
-	// Wait for shutdown
-	<-ctx.Done()
+	// SIGUSR2 starts the new roji binary on the same sockets
+	upgraded := watchUpgrades(ctx, httpLn, httpsLns, dnsConn)
 
-	// Graceful shutdown
-	shutdownServers(context.Background(), httpServer, httpsServer)
+	// Wait for shutdown
+	select {
+	case <-ctx.Done():
+		shutdownServers(context.Background(), cfg.ShutdownTimeout, handler, false, servers...)
+		if err := removePortsFile(cfg); err != nil {
+			slog.Warn("failed to remove the ports file", "error", err)
+		}
+		if hostsSync != nil {
+			hostsSync.Remove()
+		}
+		if tailscaleServe != nil {
+			tailscaleServe.Remove()
+		}
+	case <-upgraded:
+		// The hosts file block, the mDNS names, the tailnet services and the
+		// ports file stay for the new process
+		if responder != nil {
+			responder.Handoff()
+		}
+		// The new process serves new connections; open WebSockets and SSE
+		// streams stay here until they close
+		stop()
+		shutdownServers(context.Background(), cfg.UpgradeTimeout, handler, true, servers...)
+	}
 
 	slog.Info("shutdown complete")
 	return nil
 }
 
-func startHTTPServer(cfg Config) *http.Server {
+// listen binds a TCP port on address, or else on all interfaces; with ipv6
+// disabled only IPv4 is used then
+func listen(address string, port int, ipv6 bool) (net.Listener, error) {
+	if address != "" {
+		return net.Listen("tcp", net.JoinHostPort(address, strconv.Itoa(port)))
+	}
+	if ipv6 {
+		return net.Listen("tcp", fmt.Sprintf(":%d", port))
+	}
+	return net.Listen("tcp4", fmt.Sprintf("0.0.0.0:%d", port))
+}
+
+// allowedHostPatterns lists the hostnames the handler serves besides routes,
+// the dashboard and the base domain: --allowed-hosts, per-project domains
+// and extra certificate names
+func allowedHostPatterns(cfg Config) []string {
+	patterns := slices.Clone(cfg.AllowedHosts)
+	for _, domain := range cfg.ProjectDomains {
+		patterns = append(patterns, domain, "*."+domain)
+	}
+	for _, name := range cfg.CertNames {
+		patterns = append(patterns, strings.ToLower(name))
+	}
+	return patterns
+}
+
+// trustProxies honors forwarding headers from --trusted-proxies only. It
+// wraps a server's whole handler, so headers from others are gone before
+// --no-tls looks at X-Forwarded-Proto.
+func trustProxies(cfg Config, handler http.Handler) http.Handler {
+	if len(cfg.TrustedProxies) == 0 {
+		return handler
+	}
+	return proxy.TrustProxies(cfg.TrustedProxies, handler)
+}
+
+// guardListener applies --local-only to a listener a server accepts on. Upgrades
+// hand over the unwrapped TCP listeners.
+func guardListener(cfg Config, ln net.Listener) net.Listener {
+	if !cfg.LocalOnly {
+		return ln
+	}
+	return proxy.LoopbackOnly(ln)
+}
+
+// startHTTPServer serves the redirect to HTTPS, or with --http-mode proxy
+// and --no-tls every route; with --forward-proxy also CONNECT and the PAC file
+func startHTTPServer(cfg Config, router *proxy.Router, handler http.Handler, forward *proxy.ForwardProxy, ln net.Listener) *http.Server {
 	httpServer := &http.Server{
-		Addr:        fmt.Sprintf(":%d", cfg.HTTPPort),
-		Handler:     &proxy.RedirectHandler{HTTPSPort: cfg.HTTPSPort},
-		ReadTimeout: 10 * time.Second, // Short timeout for redirect server
-		IdleTimeout: 60 * time.Second,
+		Addr: fmt.Sprintf(":%d", cfg.HTTPPort),
+		Handler: &proxy.RedirectHandler{
+			HTTPSPort:      cfg.HTTPSPort,
+			StatusCode:     cfg.HTTPRedirectCode,
+			Router:         router,
+			Proxy:          handler,
+			PlainHTTPPaths: cfg.PlainHTTPPaths,
+		},
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       10 * time.Second, // Short timeout for redirect server
+		IdleTimeout:       min(cfg.IdleTimeout, 60*time.Second),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
 	}
+	mode := cfg.HTTPMode
+	switch {
+	case cfg.NoTLS:
+		// The proxy in front terminated TLS; serve like the HTTPS server
+		httpServer.Handler = proxy.TrustForwardedProto(handler)
+		mode = "proxy"
+	case mode == "proxy":
+		httpServer.Handler = handler
+	}
+	if mode == "proxy" {
+		httpServer.ReadTimeout = 0
+		httpServer.IdleTimeout = cfg.IdleTimeout
+	}
+	if forward != nil {
+		httpServer.Handler = forward.Wrap(httpServer.Handler)
+	}
+	httpServer.Handler = trustProxies(cfg, httpServer.Handler)
 
 	go func() {
-		slog.Info("starting HTTP redirect server", "port", cfg.HTTPPort)
-		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		slog.Info("starting HTTP server", "port", cfg.HTTPPort, "address", ln.Addr().String(), "mode", mode)
+		if err := httpServer.Serve(ln); err != http.ErrServerClosed {
 			slog.Error("HTTP server error", "error", err)
 		}
 	}()
@@ -140,55 +671,109 @@ func startHTTPServer(cfg Config) *http.Server {
 	return httpServer
 }
 
-func startHTTPSServer(cfg Config, handler http.Handler) (*http.Server, error) {
-	tlsConfig, err := loadTLSConfig(cfg.CertsDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load TLS config: %w", err)
-	}
+func startHTTPSServer(cfg Config, handler http.Handler, tlsConfig *tls.Config, ln net.Listener) *http.Server {
+	port := ln.Addr().(*net.TCPAddr).Port
 
 	httpsServer := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.HTTPSPort),
-		Handler:      handler,
-		TLSConfig:    tlsConfig,
-		ReadTimeout:  0,                  // No limit (support large uploads)
-		WriteTimeout: 0,                  // No limit (support SSE/Long Polling)
-		IdleTimeout:  120 * time.Second,
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       0, // No limit (support large uploads)
+		WriteTimeout:      0, // No limit (support SSE/Long Polling)
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
 	}
 
 	go func() {
-		slog.Info("starting HTTPS server", "port", cfg.HTTPSPort)
-		if err := httpsServer.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+		slog.Info("starting HTTPS server", "port", port, "address", ln.Addr().String())
+		if err := httpsServer.ServeTLS(ln, "", ""); err != http.ErrServerClosed {
 			slog.Error("HTTPS server error", "error", err)
 		}
 	}()
 
-	return httpsServer, nil
+	return httpsServer
 }
 
-func shutdownServers(ctx context.Context, httpServer, httpsServer *http.Server) {
-	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 10*time.Second)
+// shutdownServers stops accepting connections and waits for in-flight requests,
+// logging drain progress until done or the timeout expires.
+// Upgraded connections (WebSockets) are only waited for with waitUpgraded.
+func shutdownServers(ctx context.Context, timeout time.Duration, handler *proxy.Handler, waitUpgraded bool, servers ...*http.Server) {
+	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, timeout)
 	defer shutdownCancel()
 
-	httpServer.Shutdown(shutdownCtx)
-	httpsServer.Shutdown(shutdownCtx)
-}
+	handler.BeginDrain(time.Now().Add(timeout))
+	slog.Info("draining connections",
+		"in_flight", handler.DrainStatus().InFlight,
+		"timeout", timeout)
 
-func loadTLSConfig(certsDir string) (*tls.Config, error) {
-	certFile := certsDir + "/cert.pem"
-	keyFile := certsDir + "/key.pem"
+	// Open WebSockets can keep an upgraded process around for long
+	interval := time.Second
+	if waitUpgraded {
+		interval = 30 * time.Second
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				logDrainStatus(slog.LevelInfo, "draining", handler.DrainStatus())
+			}
+		}
+	}()
 
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	for _, server := range servers {
+		server.Shutdown(shutdownCtx)
+	}
+	// Shutdown doesn't track hijacked connections; their requests stay in flight
+	for waitUpgraded && handler.DrainStatus().InFlight > 0 && shutdownCtx.Err() == nil {
+		select {
+		case <-shutdownCtx.Done():
+		case <-time.After(250 * time.Millisecond):
+		}
 	}
+	close(done)
 
+	final := handler.DrainStatus()
+	if final.InFlight > 0 {
+		logDrainStatus(slog.LevelWarn, "drain timeout exceeded, abandoning in-flight requests", final)
+		return
+	}
+	slog.Info("drain complete")
+}
+
+// logDrainStatus logs overall and per-route in-flight counts
+func logDrainStatus(level slog.Level, msg string, status proxy.DrainStatus) {
+	attrs := []any{"in_flight", status.InFlight}
+	if status.RemainingSecs != nil {
+		attrs = append(attrs, "remaining", time.Duration(*status.RemainingSecs*float64(time.Second)).Round(time.Second))
+	}
+	for _, host := range status.Hostnames() {
+		attrs = append(attrs, "route."+host, status.PerRoute[host])
+	}
+	slog.Log(context.Background(), level, msg, attrs...)
+}
+
+// certReloadInterval is how often cert.pem and key.pem are checked for a
+// new certificate
+const certReloadInterval = 30 * time.Second
+
+// mocksReloadInterval is how often the --mocks file is checked for edits
+const mocksReloadInterval = 2 * time.Second
+
+func loadTLSConfig(certs *proxy.CertReloader, minVersion uint16) *tls.Config {
+	// certs also serves clients that don't send SNI (see GetCertificate)
 	return &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		MinVersion:   tls.VersionTLS12,
-	}, nil
+		GetCertificate: certs.GetCertificate,
+		MinVersion:     minVersion,
+	}
 }
 
-func discoverExisting(ctx context.Context, client *docker.Client, router *proxy.Router) error {
+func discoverExisting(ctx context.Context, client *docker.Client, router *proxy.Router, starts *startObserver) error {
 	backends, err := client.DiscoverBackends(ctx)
 	if err != nil {
 		return err
@@ -196,88 +781,514 @@ func discoverExisting(ctx context.Context, client *docker.Client, router *proxy.
 
 	for _, backend := range backends {
 		router.AddBackend(backend)
+		starts.baseline(ctx, backend)
 	}
 
 	slog.Info("discovered existing containers", "count", len(backends))
 	return nil
 }
 
-func handleEvents(ctx context.Context, client *docker.Client, router *proxy.Router, eventCh <-chan docker.ContainerEvent) {
+// resyncRoutes rediscovers all backends and atomically replaces the route table
+func resyncRoutes(ctx context.Context, client *docker.Client, router *proxy.Router) error {
+	backends, err := client.DiscoverBackends(ctx)
+	if err != nil {
+		return err
+	}
+	router.SetBackends(backends)
+	printRoutes(router)
+	return nil
+}
+
+// newDeviceAllowList builds the LAN mode allow list: loopback, the Docker
+// bridge gateways (the host, when roji runs in a container) and --lan-trust
+func newDeviceAllowList(cfg Config) (*proxy.DeviceAllowList, error) {
+	var trusted []netip.Prefix
+	for _, entry := range cfg.LANTrust {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid --lan-trust entry %q: %w", entry, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		trusted = append(trusted, prefix)
+	}
+
+	devices, err := proxy.NewDeviceAllowList(trusted, filepath.Join(cfg.CertsDir, "paired-devices.json"))
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("LAN mode enabled: unknown devices must pair via the dashboard",
+		"trusted", len(trusted),
+		"paired", len(devices.Devices()))
+	return devices, nil
+}
+
+// loadShareKey reads the share link signing key, creating it on first use.
+// Deleting the file revokes every outstanding share link. When the key can't
+// be persisted (e.g., read-only certs mount), a fresh key is still returned.
+func loadShareKey(path string) ([]byte, error) {
+	if key, err := os.ReadFile(path); err == nil && len(key) >= 32 {
+		return key, nil
+	}
+
+	key := make([]byte, 32)
+	rand.Read(key) // crypto/rand doesn't fail since Go 1.24
+	if err := os.WriteFile(path, key, 0o600); err != nil {
+		return key, fmt.Errorf("failed to save share key: %w", err)
+	}
+	return key, nil
+}
+
+// trustBridgeGateways allows containers (via the docker bridge gateways) in
+// LAN mode. It needs a reachable daemon, so it runs after waitForDocker.
+func trustBridgeGateways(ctx context.Context, devices *proxy.DeviceAllowList, client *docker.Client) {
+	gateways, err := client.BridgeGateways(ctx)
+	if err != nil {
+		slog.Warn("failed to find docker bridge gateways", "error", err)
+		return
+	}
+	for _, gw := range gateways {
+		devices.Trust(netip.PrefixFrom(gw, gw.BitLen()))
+	}
+}
+
+// waitForDocker blocks until the Docker daemon responds (e.g., while Docker
+// Desktop boots), showing a banner on the dashboard in the meantime
+func waitForDocker(ctx context.Context, cfg Config, client *docker.Client, handler *proxy.Handler) error {
+	err := client.WaitForDaemon(ctx, cfg.DockerWait, func(err error, next time.Duration) {
+		slog.Warn("docker daemon not reachable, retrying", "error", err, "retry_in", next)
+		handler.SetStartupNotice(fmt.Sprintf(
+			"Waiting for the Docker daemon (is Docker running?). Routes appear once it responds. Last error: %v", err))
+	})
+	handler.SetStartupNotice("")
+	return err
+}
+
+// driftInterval is how often the running configuration is compared with the
+// manifest. Container events settle well within it.
+const driftInterval = 15 * time.Second
+
+// watchDrift periodically compares the running configuration with the
+// manifest; the handler logs when the differences change
+func watchDrift(ctx context.Context, handler *proxy.Handler, interval time.Duration) {
+	handler.CheckDrift()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-ticker.C:
+			handler.CheckDrift()
+		}
+	}
+}
 
-		case event, ok := <-eventCh:
-			if !ok {
-				return
-			}
+// errorAlertInterval is how often routes' error rates are checked; the
+// traffic history they are computed from has 10-second buckets
+const errorAlertInterval = 10 * time.Second
 
-			switch event.Type {
-			case docker.EventStart:
-				handleStartEvent(ctx, client, router, event.ContainerID)
-			case docker.EventStop:
-				handleStopEvent(ctx, client, router, event.ContainerID)
-			}
+func watchErrorRates(ctx context.Context, handler *proxy.Handler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			handler.CheckErrorRates(now)
 		}
 	}
 }
 
-func handleStartEvent(ctx context.Context, client *docker.Client, router *proxy.Router, containerID string) {
-	backend, err := client.GetBackend(ctx, containerID)
-	if err != nil {
-		slog.Error("failed to get backend", "error", err)
-		return
+// redactLocation hides credentials in a URL setting: the user info and the
+// query, where tokens usually go. File paths are returned unchanged.
+func redactLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return location
+	}
+	if u.User != nil {
+		u.User = url.User(config.RedactedValue)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = config.RedactedValue
+	}
+	return u.String()
+}
+
+// configSettings lists the settings that shape routing, for the configuration
+// fingerprint. Paths and timings that only matter locally are left out.
+func configSettings(cfg Config) map[string]string {
+	hostRoutes := make([]string, 0, len(cfg.HostRoutes))
+	for _, r := range cfg.HostRoutes {
+		hostRoutes = append(hostRoutes, fmt.Sprintf("%s=%d", r.Hostname, r.Port))
+	}
+	// Directories are local paths; only the hostnames and modes are shared
+	staticRoutes := make([]string, 0, len(cfg.StaticRoutes))
+	for _, r := range cfg.StaticRoutes {
+		entry := r.Hostname
+		if r.SPA {
+			entry += ":spa"
+		}
+		staticRoutes = append(staticRoutes, entry)
+	}
+	externalRoutes := make([]string, 0, len(cfg.ExternalRoutes))
+	for _, r := range cfg.ExternalRoutes {
+		externalRoutes = append(externalRoutes, r.Hostname+"="+r.URL)
+	}
+	return map[string]string{
+		"network":             cfg.NetworkName,
+		"domain":              cfg.BaseDomain,
+		"dashboard":           cfg.DashboardHost,
+		"http-port":           strconv.Itoa(cfg.HTTPPort),
+		"https-port":          strconv.Itoa(cfg.HTTPSPort),
+		"auto-cert":           strconv.FormatBool(cfg.AutoCert),
+		"probe-ports":         strconv.FormatBool(cfg.ProbePorts),
+		"route-all-ports":     strconv.FormatBool(cfg.RouteAllPorts),
+		"hostname-stability":  cfg.HostnameStability,
+		"allow-hsts":          strconv.FormatBool(cfg.AllowHSTS),
+		"auto-connect":        strconv.FormatBool(cfg.AutoConnect),
+		"emulator":            strconv.FormatBool(cfg.Emulator),
+		"lan":                 strconv.FormatBool(cfg.LANMode),
+		"forward-proxy":       strconv.FormatBool(cfg.ForwardProxy),
+		"dns-port":            strconv.Itoa(cfg.DNSPort),
+		"sync-hosts":          strconv.FormatBool(cfg.HostsFile != ""),
+		"magic-dns":           cfg.MagicDNS,
+		"mdns":                strconv.FormatBool(cfg.MDNS != ""),
+		"tailscale":           strconv.FormatBool(cfg.Tailscale != ""),
+		"host-routes":         strings.Join(hostRoutes, ","),
+		"host-gateway":        cfg.HostGateway,
+		"static-routes":       strings.Join(staticRoutes, ","),
+		"external-routes":     strings.Join(externalRoutes, ","),
+		"banner":              cfg.Banner,
+		"hostname-registry":   redactLocation(cfg.HostnameRegistry),
+		"outlier-detection":   strconv.FormatBool(cfg.OutlierDetection),
+		"no-tls":              strconv.FormatBool(cfg.NoTLS),
+		"local-only":          strconv.FormatBool(cfg.LocalOnly),
+		"allowed-hosts":       strings.Join(cfg.AllowedHosts, ","),
+		"overrides-dir":       cfg.OverridesDir,
+		"trusted-proxies":     joinPrefixes(cfg.TrustedProxies),
+		"http-mode":           cfg.HTTPMode,
+		"http-redirect-code":  strconv.Itoa(cfg.HTTPRedirectCode),
+		"plain-http-paths":    strings.Join(cfg.PlainHTTPPaths, ","),
+		"http-fallback-port":  strconv.Itoa(cfg.HTTPFallbackPort),
+		"https-fallback-port": strconv.Itoa(cfg.HTTPSFallbackPort),
+	}
+}
+
+func joinPrefixes(prefixes []netip.Prefix) string {
+	strs := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		strs[i] = p.String()
+	}
+	return strings.Join(strs, ",")
+}
+
+// registryOpt enables the shared hostname registry when a location is configured
+func registryOpt(location string) docker.ClientOption {
+	if location == "" {
+		return func(*docker.Client) {}
 	}
-	if backend == nil {
+	return docker.WithHostnameRegistry(docker.NewReservationSource(location))
+}
+
+// refreshHostnameRegistry periodically reloads the hostname registry and
+// re-resolves routes when reservations change
+func refreshHostnameRegistry(ctx context.Context, client *docker.Client, router *proxy.Router, interval time.Duration) {
+	if interval <= 0 {
 		return
 	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	// If this is a compose project, update all backends for the project
-	// (hostnames may change based on service count)
-	if backend.ProjectName != "" {
-		router.RemoveProject(backend.ProjectName)
-		backends, err := client.GetProjectBackends(ctx, backend.ProjectName)
-		if err != nil {
-			slog.Error("failed to get project backends", "error", err)
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
+			changed, err := client.RefreshHostnameRegistry(ctx)
+			if err != nil {
+				slog.Warn("failed to refresh hostname registry", "error", err)
+				continue
+			}
+			if changed {
+				if err := resyncRoutes(ctx, client, router); err != nil {
+					slog.Error("failed to resync routes", "error", err)
+				}
+			}
 		}
-		for _, b := range backends {
-			router.AddBackend(b)
+	}
+}
+
+// hostnameRemapper applies explicit remaps of sticky hostnames from the management API
+type hostnameRemapper struct {
+	client *docker.Client
+	router *proxy.Router
+}
+
+func (m *hostnameRemapper) PinnedHostnames() map[string]string {
+	return m.client.PinnedHostnames()
+}
+
+func (m *hostnameRemapper) RemapHostname(ctx context.Context, service, hostname string) error {
+	if _, ok := m.client.PinnedHostnames()[service]; !ok {
+		return fmt.Errorf("unknown service %q", service)
+	}
+	m.client.PinHostname(service, hostname)
+	return resyncRoutes(ctx, m.client, m.router)
+}
+
+// routeExposer registers `roji expose` routes with the client, so they
+// survive resyncs, and applies them to the router right away
+type routeExposer struct {
+	client *docker.Client
+	router *proxy.Router
+}
+
+func (e *routeExposer) Expose(hostname string, port int) error {
+	backend := e.client.ExposeHostRoute(config.HostRoute{Hostname: hostname, Port: port})
+	e.router.ReplaceContainer(backend.ContainerID, []*docker.Backend{backend})
+	return nil
+}
+
+func (e *routeExposer) Unexpose(hostname string) {
+	if id, ok := e.client.UnexposeHostRoute(hostname); ok {
+		e.router.RemoveBackend(id)
+	}
+}
+
+// hostnameRotator moves routes to fresh origins on request from the management API
+type hostnameRotator struct {
+	client *docker.Client
+	router *proxy.Router
+}
+
+func (r *hostnameRotator) RotateHostname(ctx context.Context, hostname string) (string, error) {
+	newHostname := r.client.RotateHostname(hostname)
+	return newHostname, resyncRoutes(ctx, r.client, r.router)
+}
+
+func handleEvents(ctx context.Context, client *docker.Client, router *proxy.Router, starts *startObserver, batchCh <-chan []docker.ContainerEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case batch, ok := <-batchCh:
+			if !ok {
+				return
+			}
+			applyEvents(ctx, client, router, batch)
+			starts.observe(ctx, router, batch)
 		}
-	} else {
-		router.AddBackend(backend)
 	}
-	printRoutes(router)
 }
 
-func handleStopEvent(ctx context.Context, client *docker.Client, router *proxy.Router, containerID string) {
-	// Get the backend info before removing to check project
-	backend, _ := client.GetBackend(ctx, containerID)
-	router.RemoveBackend(containerID)
+// applyEvents applies a debounced batch of container events. Each affected
+// compose project is rediscovered once and swapped in atomically (hostnames
+// may change based on service count); standalone containers are updated
+// individually.
+func applyEvents(ctx context.Context, client *docker.Client, router *proxy.Router, batch []docker.ContainerEvent) {
+	var projects []string
+	seen := make(map[string]bool)
+
+	for _, event := range batch {
+		if event.Type == docker.EventStart || event.Type == docker.EventRestart {
+			if _, err := client.EnsureConnected(ctx, event.ContainerID); err != nil {
+				slog.Warn("failed to auto-connect container",
+					"container", event.ContainerID,
+					"error", err)
+			}
+		}
 
-	// If this was part of a project, update remaining siblings' hostnames
-	if backend != nil && backend.ProjectName != "" {
-		router.RemoveProject(backend.ProjectName)
-		backends, err := client.GetProjectBackends(ctx, backend.ProjectName)
+		project, err := client.ContainerProject(ctx, event.ContainerID)
 		if err != nil {
-			slog.Error("failed to get project backends", "error", err)
-		} else {
-			for _, b := range backends {
-				router.AddBackend(b)
+			slog.Debug("failed to inspect container",
+				"container", event.ContainerID,
+				"error", err)
+		}
+		if project != "" {
+			if !seen[project] {
+				seen[project] = true
+				projects = append(projects, project)
 			}
+			continue
+		}
+
+		switch event.Type {
+		case docker.EventStart:
+			handleStartEvent(ctx, client, router, event.ContainerID)
+		case docker.EventStop:
+			router.RemoveBackend(event.ContainerID)
+		case docker.EventRestart, docker.EventRefresh:
+			handleRefreshEvent(ctx, client, router, event.ContainerID)
+		}
+	}
+
+	for _, project := range projects {
+		backends, err := client.GetProjectBackends(ctx, project)
+		if err != nil {
+			slog.Error("failed to get project backends", "project", project, "error", err)
+			continue
 		}
+		router.ReplaceProject(project, backends)
 	}
+
+	slog.Debug("applied container events", "events", len(batch), "projects", len(projects))
 	printRoutes(router)
 }
 
+// startObserver records what changed when containers (re)start: endpoint
+// snapshots (roji.snapshot) and image revisions for the dashboard timeline.
+// It also reloads open pages of roji.live-reload routes.
+type startObserver struct {
+	snapshots  *proxy.Snapshotter
+	changelog  *proxy.Changelog
+	liveReload *proxy.LiveReload
+}
+
+// baseline records containers that were already running when roji started
+func (o *startObserver) baseline(ctx context.Context, backend *docker.Backend) {
+	o.changelog.Baseline(backend)
+	go o.snapshots.Capture(ctx, backend)
+}
+
+// observe records containers started in the batch
+func (o *startObserver) observe(ctx context.Context, router *proxy.Router, batch []docker.ContainerEvent) {
+	for _, event := range batch {
+		if event.Type != docker.EventStart && event.Type != docker.EventRestart {
+			continue
+		}
+		for _, backend := range router.ContainerBackends(event.ContainerID) {
+			o.changelog.Record(backend)
+			go o.snapshots.Capture(ctx, backend)
+			go o.liveReload.Started(ctx, backend)
+		}
+	}
+}
+
+// handleStartEvent adds the routes of a standalone (non-compose) container
+func handleStartEvent(ctx context.Context, client *docker.Client, router *proxy.Router, containerID string) {
+	backends, err := client.GetBackends(ctx, containerID)
+	if err != nil {
+		slog.Error("failed to get backend", "error", err)
+		return
+	}
+	for _, b := range backends {
+		router.AddBackend(b)
+	}
+}
+
+// handleRefreshEvent re-inspects a standalone container after a restart,
+// kill, OOM, rename or (un)pause and swaps in its current routes, dropping
+// stale addresses and hostnames
+func handleRefreshEvent(ctx context.Context, client *docker.Client, router *proxy.Router, containerID string) {
+	backends, err := client.GetBackends(ctx, containerID)
+	if err != nil {
+		// Gone (e.g., removed after the kill); its routes are stale either way
+		slog.Debug("failed to refresh container", "container", containerID, "error", err)
+	}
+	router.ReplaceContainer(containerID, backends)
+}
+
+// tailscaleOrigin is where tailscaled reaches roji (--tailscale). roji's
+// certificate comes from its own CA, which tailscaled doesn't know.
+func tailscaleOrigin(cfg Config) string {
+	host := "127.0.0.1"
+	if ip := net.ParseIP(cfg.ListenAddress); ip != nil && !ip.IsUnspecified() {
+		host = cfg.ListenAddress
+	}
+	if cfg.NoTLS {
+		return "http://" + net.JoinHostPort(host, strconv.Itoa(cfg.HTTPPort))
+	}
+	return "https+insecure://" + net.JoinHostPort(host, strconv.Itoa(cfg.HTTPSPort))
+}
+
+// servedDomains lists the domains roji serves every subdomain of, and the
+// dashboard when it's outside them
+func servedDomains(cfg Config) []string {
+	domains := []string{cfg.BaseDomain}
+	for _, project := range slices.Sorted(maps.Keys(cfg.ProjectDomains)) {
+		domains = append(domains, cfg.ProjectDomains[project])
+	}
+	dashboard := cfg.DashboardHost
+	if dashboard != "" && !slices.ContainsFunc(domains, func(domain string) bool {
+		return dashboard == domain || strings.HasSuffix(dashboard, "."+domain)
+	}) {
+		domains = append(domains, dashboard)
+	}
+	return domains
+}
+
 func printBanner(cfg Config) {
 	fmt.Println()
 	fmt.Println("  roji - reverse proxy for local development")
 	fmt.Println("  ─────────────────────────────────────────")
 	fmt.Printf("  Network:   %s\n", cfg.NetworkName)
-	fmt.Printf("  Domain:    *.%s\n", cfg.BaseDomain)
-	fmt.Printf("  Dashboard: https://%s\n", cfg.DashboardHost)
+	if cfg.MagicDNS != "" {
+		fmt.Printf("  Domain:    *.%s (resolves to this machine through %s)\n", cfg.BaseDomain, cfg.MagicDNS)
+	} else {
+		fmt.Printf("  Domain:    *.%s\n", cfg.BaseDomain)
+	}
+	if cfg.NoTLS {
+		fmt.Printf("  Dashboard: http://%s (TLS terminated upstream)\n", cfg.DashboardHost)
+	} else {
+		fmt.Printf("  Dashboard: https://%s\n", cfg.DashboardHost)
+	}
+	if cfg.ListenAddress != "" {
+		fmt.Printf("  Listen:    %s\n", cfg.ListenAddress)
+	}
+	if cfg.LocalOnly {
+		fmt.Println("  Clients:   this machine only (--local-only)")
+	}
+	if cfg.LANIP != "" {
+		fmt.Printf("  LAN:       %s (dashboard; routes at <route>.%s)\n",
+			lanURL(cfg, proxy.EmulatorDomain(cfg.LANIP)), proxy.EmulatorDomain(cfg.LANIP))
+		if cfg.LANName != "" {
+			fmt.Printf("             %s (dashboard, where mDNS works)\n", lanURL(cfg, cfg.LANName))
+		}
+	}
+	if cfg.MDNS != "" {
+		fmt.Printf("  mDNS:      routes at <route>.%s on %s\n", proxy.MDNSDomain, cfg.MDNS)
+	}
+	if cfg.Tailscale != "" {
+		fmt.Printf("  Tailnet:   routes at https://<service>.%s (see `tailscale serve status`)\n", cfg.Tailscale)
+	}
+	if cfg.DNSPort != 0 {
+		fmt.Printf("  DNS:       port %d answers %s (see `roji resolver`)\n", cfg.DNSPort, strings.Join(servedDomains(cfg), ", "))
+	}
+	if cfg.HostsFile != "" {
+		fmt.Printf("  Hosts:     route hostnames kept in %s\n", cfg.HostsFile)
+	}
+	if cfg.ForwardProxy {
+		fmt.Printf("  Proxy:     http://127.0.0.1:%d%s (PAC file for the browser's proxy settings)\n", cfg.HTTPPort, proxy.PACPath)
+	}
+	if cfg.PortsFellBack {
+		fmt.Printf("  Ports:     %d (HTTP), %d (HTTPS); the configured ones were taken\n", cfg.HTTPPort, cfg.HTTPSPort)
+	}
+	switch {
+	case cfg.NoTLS:
+	case cfg.HTTPMode == "proxy":
+		fmt.Printf("  HTTP:      %d serves every route (no redirect)\n", cfg.HTTPPort)
+	case cfg.HTTPMode == "off":
+		fmt.Println("  HTTP:      off")
+	}
+	if len(cfg.ExtraHTTPSPorts) > 0 {
+		ports := make([]string, len(cfg.ExtraHTTPSPorts))
+		for i, port := range cfg.ExtraHTTPSPorts {
+			ports[i] = strconv.Itoa(port)
+		}
+		fmt.Printf("  HTTPS:     %d, %s\n", cfg.HTTPSPort, strings.Join(ports, ", "))
+	}
 	fmt.Println()
 
 	// Show CA certificate install hint if auto-cert is enabled
@@ -288,6 +1299,38 @@ func printBanner(cfg Config) {
 	}
 }
 
+// lanURL returns the URL of a hostname as devices on the LAN use it
+func lanURL(cfg Config, hostname string) string {
+	scheme, port, defaultPort := "https", cfg.HTTPSPort, 443
+	if cfg.NoTLS {
+		scheme, port, defaultPort = "http", cfg.HTTPPort, 80
+	}
+	if port != defaultPort {
+		hostname = net.JoinHostPort(hostname, strconv.Itoa(port))
+	}
+	return scheme + "://" + hostname
+}
+
+// printLANRoutes lists the URLs other devices reach the routes on (--lan)
+func printLANRoutes(cfg Config, router *proxy.Router) {
+	if cfg.LANIP == "" {
+		return
+	}
+	routes := router.ListRoutes()
+	if len(routes) == 0 {
+		return
+	}
+	fmt.Println("📱 On the LAN (devices pair first; install the CA on phones):")
+	for _, r := range routes {
+		if r.RedirectTo != "" {
+			continue
+		}
+		hostname := proxy.EmulatorHostname(r.Hostname, cfg.BaseDomain, cfg.LANIP)
+		fmt.Printf("  %s%s\n", lanURL(cfg, hostname), r.PathPrefix)
+	}
+	fmt.Println()
+}
+
 func printRoutes(router *proxy.Router) {
 	routes := router.ListRoutes()
 	if len(routes) == 0 {
@@ -0,0 +1,501 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// serviceLabel names the launchd job; the systemd unit is roji.service
+const serviceLabel = "com.github.kan.roji"
+
+var (
+	serviceSystem  bool
+	serviceNoStart bool
+)
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run roji in the background at login (systemd, launchd)",
+	Long: `Installs roji as a service of the operating system, so the proxy is always
+there like Docker Desktop is: a systemd user unit on Linux, a launchd agent on
+macOS. The service runs this roji binary with the configuration file
+(--config, --profile) and certificates directory it was installed with;
+arguments after -- are added to its command line.
+
+  roji service install --config ~/roji.yaml
+  roji service install -- --domain test.localhost --dashboard test.localhost
+  roji service status
+  roji service uninstall
+
+Without --certs-dir, certificates go to roji's data directory instead of
+/certs. Running install again rewrites and restarts the service.`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install [-- roji flags]",
+	Short: "Install and start the roji service",
+	Long: `Writes the service definition and starts it (unless --no-start).
+
+On Linux, a user unit can't bind ports below 1024 unless the kernel allows it
+for everyone; roji tells you when that's the case. --system installs a system
+unit instead (run it with sudo), which runs roji as your user with only the
+capability to bind those ports, from boot rather than login.`,
+	RunE: runServiceInstall,
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop the roji service and remove it",
+	Args:  cobra.NoArgs,
+	RunE:  runServiceUninstall,
+}
+
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the roji service is installed and running",
+	Args:  cobra.NoArgs,
+	RunE:  runServiceStatus,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{serviceInstallCmd, serviceUninstallCmd, serviceStatusCmd} {
+		c.Flags().BoolVar(&serviceSystem, "system", false, "Linux: a system unit started at boot instead of a user unit (needs root)")
+	}
+	serviceInstallCmd.Flags().BoolVar(&serviceNoStart, "no-start", false, "Only write the service definition")
+	serviceCmd.AddCommand(serviceInstallCmd)
+	serviceCmd.AddCommand(serviceUninstallCmd)
+	serviceCmd.AddCommand(serviceStatusCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
+
+// serviceSpec describes the roji service to install
+type serviceSpec struct {
+	Executable string
+	Args       []string
+	User       string // Account running a system unit
+	Home       string // Home directory of that account
+	LogFile    string // launchd only; systemd logs to the journal
+}
+
+// serviceManager is systemd or launchd
+type serviceManager interface {
+	Path() string                   // Service definition file
+	Render(spec serviceSpec) string // Contents of that file
+	Start() error                   // Enable and start the written service
+	Stop() error                    // Stop and disable it
+	Status() (running bool, detail string)
+}
+
+func newServiceManager() (serviceManager, error) {
+	switch runtime.GOOS {
+	case "linux":
+		return newSystemd(serviceSystem)
+	case "darwin":
+		if serviceSystem {
+			return nil, errors.New("--system is Linux only; launchd agents already start at login")
+		}
+		return newLaunchd()
+	}
+	return nil, fmt.Errorf("roji service supports Linux (systemd) and macOS (launchd), not %s", runtime.GOOS)
+}
+
+func runServiceInstall(cmd *cobra.Command, args []string) error {
+	manager, err := newServiceManager()
+	if err != nil {
+		return err
+	}
+	spec, err := newServiceSpec(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	path := manager.Path()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(manager.Render(spec)), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Printf("✅ Wrote %s\n", path)
+	fmt.Printf("   %s %s\n", spec.Executable, strings.Join(spec.Args, " "))
+
+	if runtime.GOOS == "linux" && !serviceSystem {
+		if start, ok := unprivilegedPortStart(); ok && (httpPort < start || httpsPort < start) {
+			fmt.Printf("⚠️  Ports below %d need privileges a user unit doesn't have. Either:\n", start)
+			fmt.Printf("   sudo setcap cap_net_bind_service=+ep %s\n", spec.Executable)
+			fmt.Println("   sudo roji service install --system")
+		}
+	}
+	if serviceNoStart {
+		return nil
+	}
+	if err := manager.Start(); err != nil {
+		return fmt.Errorf("failed to start the service: %w", err)
+	}
+	fmt.Println("🚀 roji is running and starts at login")
+	if certsFlagDefault(cmd) {
+		fmt.Printf("   CLI commands trust its CA with: export ROJI_CERTS_DIR=%s\n", serviceCertsDir(spec.Home))
+	}
+	if spec.LogFile != "" {
+		fmt.Printf("   Logs: tail -f %s\n", spec.LogFile)
+	} else if serviceSystem {
+		fmt.Println("   Logs: journalctl -u roji -f")
+	} else {
+		fmt.Println("   Logs: journalctl --user -u roji -f")
+	}
+	return nil
+}
+
+func runServiceUninstall(cmd *cobra.Command, args []string) error {
+	manager, err := newServiceManager()
+	if err != nil {
+		return err
+	}
+	path := manager.Path()
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		fmt.Println("roji service is not installed")
+		return nil
+	}
+	if err := manager.Stop(); err != nil {
+		fmt.Printf("⚠️  failed to stop the service: %v\n", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	fmt.Printf("🗑️  Removed %s\n", path)
+	return nil
+}
+
+func runServiceStatus(cmd *cobra.Command, args []string) error {
+	manager, err := newServiceManager()
+	if err != nil {
+		return err
+	}
+	path := manager.Path()
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		fmt.Println("Installed: no (roji service install)")
+		return nil
+	}
+	fmt.Printf("Installed: %s\n", path)
+
+	running, detail := manager.Status()
+	state := "stopped"
+	if running {
+		state = "running"
+	}
+	if detail != "" {
+		state += " (" + detail + ")"
+	}
+	fmt.Printf("Service:   %s\n", state)
+
+	// The service keeps its certificates in the data directory by default
+	if certsFlagDefault(cmd) {
+		spec, err := newServiceSpec(cmd, nil)
+		if err == nil {
+			certsDir = serviceCertsDir(spec.Home)
+		}
+	}
+	if health, err := checkHealth(false); err != nil {
+		fmt.Printf("Proxy:     not answering (%v)\n", err)
+	} else {
+		fmt.Printf("Proxy:     %s, %d routes\n", health.Status, health.Routes)
+	}
+	return nil
+}
+
+// newServiceSpec resolves the binary, account and arguments of the service
+func newServiceSpec(cmd *cobra.Command, extra []string) (serviceSpec, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return serviceSpec{}, err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	spec := serviceSpec{Executable: exe}
+
+	// sudo roji service install --system runs roji as the invoking user
+	account, err := user.Current()
+	if err != nil {
+		return serviceSpec{}, err
+	}
+	if name := os.Getenv("SUDO_USER"); serviceSystem && name != "" {
+		if account, err = user.Lookup(name); err != nil {
+			return serviceSpec{}, err
+		}
+	}
+	spec.User = account.Username
+	spec.Home = account.HomeDir
+
+	dir := certsDir
+	if certsFlagDefault(cmd) {
+		dir = serviceCertsDir(spec.Home)
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return serviceSpec{}, err
+	}
+	spec.Args = append(spec.Args, "--certs-dir", dir)
+	if configFile != "" {
+		path, err := filepath.Abs(configFile)
+		if err != nil {
+			return serviceSpec{}, err
+		}
+		spec.Args = append(spec.Args, "--config", path)
+	}
+	// A profile the file selects itself is left to the file
+	if profileName != "" && !fileFlags["profile"] {
+		spec.Args = append(spec.Args, "--profile", profileName)
+	}
+	spec.Args = append(spec.Args, extra...)
+
+	if runtime.GOOS == "darwin" {
+		spec.LogFile = filepath.Join(spec.Home, "Library", "Logs", "roji.log")
+	}
+	return spec, nil
+}
+
+// certsFlagDefault reports whether --certs-dir is still the container default
+func certsFlagDefault(cmd *cobra.Command) bool {
+	flag := cmd.Flags().Lookup("certs-dir")
+	return flag != nil && !flag.Changed && !fileFlags["certs-dir"] && os.Getenv("ROJI_CERTS_DIR") == ""
+}
+
+// serviceCertsDir is where a service keeps its certificates: /certs is
+// meant for the container image
+func serviceCertsDir(home string) string {
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, "Library", "Application Support", "roji", "certs")
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" && !serviceSystem {
+		return filepath.Join(dir, "roji", "certs")
+	}
+	return filepath.Join(home, ".local", "share", "roji", "certs")
+}
+
+// unprivilegedPortStart returns the lowest port any process may bind
+func unprivilegedPortStart() (int, bool) {
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start")
+	if err != nil {
+		return 0, false
+	}
+	port, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return port, err == nil
+}
+
+// systemd manages roji.service as a user unit, or a system unit with --system
+type systemd struct {
+	system bool
+	dir    string
+}
+
+func newSystemd(system bool) (*systemd, error) {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return nil, errors.New("systemctl not found: roji service needs systemd on Linux")
+	}
+	if system {
+		return &systemd{system: true, dir: "/etc/systemd/system"}, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return &systemd{dir: filepath.Join(dir, "systemd", "user")}, nil
+}
+
+func (s *systemd) Path() string {
+	return filepath.Join(s.dir, "roji.service")
+}
+
+func (s *systemd) Render(spec serviceSpec) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=roji reverse proxy for local development\n")
+	b.WriteString("Documentation=https://github.com/kan/roji\n")
+	if s.system {
+		b.WriteString("After=network-online.target docker.service\n")
+		b.WriteString("Wants=network-online.target\n")
+	}
+	b.WriteString("\n[Service]\n")
+	args := []string{systemdQuote(spec.Executable)}
+	for _, arg := range spec.Args {
+		args = append(args, systemdQuote(arg))
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(args, " "))
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=5\n")
+	// systemctl reload upgrades to a new binary without dropping connections
+	b.WriteString("ExecReload=/bin/kill -USR2 $MAINPID\n")
+	b.WriteString("NotifyAccess=all\n")
+	if s.system {
+		fmt.Fprintf(&b, "User=%s\n", spec.User)
+		// Ports 80 and 443 without running as root
+		b.WriteString("AmbientCapabilities=CAP_NET_BIND_SERVICE\n")
+		b.WriteString("CapabilityBoundingSet=CAP_NET_BIND_SERVICE\n")
+		b.WriteString("NoNewPrivileges=true\n")
+	}
+	b.WriteString("\n[Install]\n")
+	if s.system {
+		b.WriteString("WantedBy=multi-user.target\n")
+	} else {
+		b.WriteString("WantedBy=default.target\n")
+	}
+	return b.String()
+}
+
+// systemdQuote quotes an ExecStart argument; % starts a specifier
+func systemdQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\;$") {
+		return arg
+	}
+	return strconv.Quote(arg)
+}
+
+func (s *systemd) systemctl(args ...string) error {
+	if !s.system {
+		args = append([]string{"--user"}, args...)
+	}
+	c := exec.Command("systemctl", args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+func (s *systemd) Start() error {
+	if err := s.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if err := s.systemctl("enable", "roji.service"); err != nil {
+		return err
+	}
+	// restart picks up a changed unit when roji was already running
+	return s.systemctl("restart", "roji.service")
+}
+
+func (s *systemd) Stop() error {
+	if err := s.systemctl("disable", "--now", "roji.service"); err != nil {
+		return err
+	}
+	return s.systemctl("daemon-reload")
+}
+
+func (s *systemd) Status() (bool, string) {
+	args := []string{"show", "roji.service", "--property=ActiveState,SubState,MainPID", "--value"}
+	if !s.system {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := exec.Command("systemctl", args...).Output()
+	if err != nil {
+		return false, err.Error()
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 {
+		return false, strings.TrimSpace(string(out))
+	}
+	if fields[0] != "active" {
+		return false, fields[0] + "/" + fields[1]
+	}
+	return true, "pid " + fields[2]
+}
+
+// launchd manages roji as a launch agent of the logged-in user
+type launchd struct {
+	dir    string
+	domain string // gui/<uid>
+}
+
+func newLaunchd() (*launchd, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return &launchd{
+		dir:    filepath.Join(home, "Library", "LaunchAgents"),
+		domain: "gui/" + strconv.Itoa(os.Getuid()),
+	}, nil
+}
+
+func (l *launchd) Path() string {
+	return filepath.Join(l.dir, serviceLabel+".plist")
+}
+
+func (l *launchd) Render(spec serviceSpec) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", plistEscape(serviceLabel))
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{spec.Executable}, spec.Args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", plistEscape(arg))
+	}
+	b.WriteString("\t</array>\n")
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	// Restart after crashes, not after a clean exit
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	fmt.Fprintf(&b, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n", plistEscape(spec.LogFile))
+	fmt.Fprintf(&b, "\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", plistEscape(spec.LogFile))
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func plistEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (l *launchd) Start() error {
+	// bootout first so a changed plist is read again
+	exec.Command("launchctl", "bootout", l.domain+"/"+serviceLabel).Run()
+	c := exec.Command("launchctl", "bootstrap", l.domain, l.Path())
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+func (l *launchd) Stop() error {
+	c := exec.Command("launchctl", "bootout", l.domain+"/"+serviceLabel)
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+func (l *launchd) Status() (bool, string) {
+	out, err := exec.Command("launchctl", "print", l.domain+"/"+serviceLabel).Output()
+	if err != nil {
+		return false, "not loaded"
+	}
+	var state, pid string
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "state":
+			state = value
+		case "pid":
+			pid = value
+		}
+	}
+	if state != "running" {
+		return false, state
+	}
+	return true, "pid " + pid
+}
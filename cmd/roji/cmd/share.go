@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	shareExpires time.Duration
+	shareURL     string
+	shareTunnel  string
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share <hostname>",
+	Short: "Create an expiring link to a route",
+	Long: `Mints a signed link that grants access to one route until it expires.
+Opening the link lets the visitor past LAN mode pairing and roji.tunnel-auth /
+roji.tunnel-secret credentials, without an account.
+
+Share links stop working at expiry. To revoke all of them early, delete
+share.key in the certs directory and restart roji.
+
+--tunnel opens a public tunnel to the route with cloudflared (a quick tunnel,
+no account needed) or ngrok, and prints the link under its URL. The tunnel
+stays open until Ctrl-C.
+
+  roji share web.app.localhost --expires 2h
+  roji share web.app.localhost --url https://demo.trycloudflare.com
+  roji share web.app.localhost --tunnel cloudflared`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHostname,
+	RunE:              runShare,
+}
+
+func init() {
+	shareCmd.Flags().DurationVar(&shareExpires, "expires", time.Hour,
+		fmt.Sprintf("How long the link stays valid (max %s)", proxy.MaxShareDuration))
+	shareCmd.Flags().StringVar(&shareURL, "url", "",
+		"Public base URL the route is reachable at (e.g., a tunnel URL); default: the route's own URL")
+	shareCmd.Flags().StringVar(&shareTunnel, "tunnel", "",
+		"Open a public tunnel to the route with cloudflared or ngrok and share its URL, until Ctrl-C")
+	shareCmd.RegisterFlagCompletionFunc("tunnel", cobra.FixedCompletions([]string{"cloudflared", "ngrok"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.AddCommand(shareCmd)
+}
+
+func runShare(cmd *cobra.Command, args []string) error {
+	if shareTunnel != "" && shareURL != "" {
+		return fmt.Errorf("--tunnel shares the tunnel's URL; drop --url")
+	}
+	body, err := json.Marshal(map[string]string{"hostname": args[0], "expires": shareExpires.String()})
+	if err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(10 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(dashboardAPIURL("/_api/v1/share"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		URL       string    `json:"url"`
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// The tunnel lives as long as this command
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var tunnelDone <-chan error
+	if shareTunnel != "" {
+		if shareURL, tunnelDone, err = startTunnel(ctx, shareTunnel, args[0]); err != nil {
+			return err
+		}
+	}
+
+	link := result.URL
+	if shareURL != "" {
+		base, err := url.Parse(strings.TrimSuffix(shareURL, "/") + "/")
+		if err != nil {
+			return fmt.Errorf("invalid --url: %w", err)
+		}
+		query := base.Query()
+		query.Set(proxy.ShareParam, result.Token)
+		base.RawQuery = query.Encode()
+		link = base.String()
+	}
+
+	fmt.Println(link)
+	fmt.Printf("Expires %s (in %s)\n", result.ExpiresAt.Local().Format("2006-01-02 15:04"), shareExpires)
+	if tunnelDone == nil {
+		return nil
+	}
+
+	fmt.Printf("Tunnel open through %s; Ctrl-C closes it\n", shareTunnel)
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-tunnelDone:
+		return fmt.Errorf("%s exited, closing the tunnel: %v", shareTunnel, err)
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tunnelStartTimeout bounds how long a provider may take to print its URL
+const tunnelStartTimeout = 30 * time.Second
+
+// tunnelProvider opens a public tunnel to a route with a provider's CLI
+type tunnelProvider interface {
+	// Command runs the tunnel to origin (roji's port on this machine),
+	// sending hostname as the Host header so roji picks the route
+	Command(ctx context.Context, origin, hostname string) *exec.Cmd
+	// PublicURL finds the tunnel's URL in a line of the command's output
+	PublicURL(line string) (string, bool)
+}
+
+// tunnelProviders are the supported --tunnel values
+var tunnelProviders = map[string]tunnelProvider{
+	"cloudflared": cloudflaredTunnel{},
+	"ngrok":       ngrokTunnel{},
+}
+
+// cloudflaredTunnel opens a Cloudflare quick tunnel (*.trycloudflare.com),
+// which needs no account
+type cloudflaredTunnel struct{}
+
+var trycloudflareURL = regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`)
+
+func (cloudflaredTunnel) Command(ctx context.Context, origin, hostname string) *exec.Cmd {
+	// roji's certificate is issued by its own CA, unknown to cloudflared
+	return exec.CommandContext(ctx, "cloudflared", "tunnel", "--no-autoupdate",
+		"--url", origin, "--http-host-header", hostname, "--origin-server-name", hostname, "--no-tls-verify")
+}
+
+func (cloudflaredTunnel) PublicURL(line string) (string, bool) {
+	url := trycloudflareURL.FindString(line)
+	return url, url != ""
+}
+
+// ngrokTunnel opens an ngrok tunnel (needs `ngrok config add-authtoken`)
+type ngrokTunnel struct{}
+
+var ngrokURL = regexp.MustCompile(`"url":"(https://[^"]+)"`)
+
+func (ngrokTunnel) Command(ctx context.Context, origin, hostname string) *exec.Cmd {
+	return exec.CommandContext(ctx, "ngrok", "http", origin,
+		"--host-header="+hostname, "--log=stdout", "--log-format=json")
+}
+
+func (ngrokTunnel) PublicURL(line string) (string, bool) {
+	if m := ngrokURL.FindStringSubmatch(line); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// tunnelOrigin is the URL tunnels reach roji at on this machine
+func tunnelOrigin() string {
+	if noTLS {
+		return "http://127.0.0.1:" + strconv.Itoa(httpPort)
+	}
+	return "https://127.0.0.1:" + strconv.Itoa(httpsPort)
+}
+
+// startTunnel runs a provider's tunnel to hostname and returns its public
+// URL once printed. The tunnel runs until ctx is done; done is closed when
+// the command exits.
+func startTunnel(ctx context.Context, name, hostname string) (publicURL string, done <-chan error, err error) {
+	provider, ok := tunnelProviders[name]
+	if !ok {
+		names := make([]string, 0, len(tunnelProviders))
+		for name := range tunnelProviders {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return "", nil, fmt.Errorf("invalid --tunnel %q (want %s)", name, strings.Join(names, " or "))
+	}
+
+	cmd := provider.Command(ctx, tunnelOrigin(), hostname)
+	if cmd.Err != nil {
+		return "", nil, fmt.Errorf("--tunnel %s needs the %s command: %w", name, cmd.Args[0], cmd.Err)
+	}
+	// Providers log to either stream
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", nil, err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return "", nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+
+	found := make(chan string, 1)
+	exited := make(chan error, 1)
+	var logs []string // shown when no URL appears
+	go func() {
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if url, ok := provider.PublicURL(line); ok {
+				select {
+				case found <- url:
+				default:
+				}
+			} else if len(logs) < 20 {
+				logs = append(logs, line)
+			}
+		}
+		io.Copy(io.Discard, out)
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case url := <-found:
+		return url, exited, nil
+	case err := <-exited:
+		return "", nil, fmt.Errorf("%s exited before opening the tunnel (%v):\n%s", name, err, strings.Join(logs, "\n"))
+	case <-time.After(tunnelStartTimeout):
+		cmd.Process.Kill()
+		return "", nil, fmt.Errorf("%s printed no tunnel URL within %s", name, tunnelStartTimeout)
+	}
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var topInterval time.Duration
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show live per-route traffic",
+	Long: `Shows request rates, status code counts and p95 latency for each route
+of the running roji server, refreshed until you press Ctrl-C. Status counts
+are totals since roji started; p95 covers the last minute.
+
+  roji top
+  roji top --interval 5s`,
+	Args: cobra.NoArgs,
+	RunE: runTop,
+}
+
+func init() {
+	topCmd.Flags().DurationVar(&topInterval, "interval", time.Second, "Refresh interval")
+	rootCmd.AddCommand(topCmd)
+}
+
+func runTop(cmd *cobra.Command, args []string) error {
+	if topInterval < 100*time.Millisecond {
+		return fmt.Errorf("--interval must be at least 100ms")
+	}
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	ticker := time.NewTicker(topInterval)
+	defer ticker.Stop()
+	var previous *proxy.MetricsSnapshot
+	for {
+		snap, err := fetchMetrics(client, 0)
+		if err != nil {
+			return err
+		}
+		fmt.Print(renderTop(snap, previous, isTerminal(os.Stdout)))
+		previous = snap
+
+		select {
+		case <-sigCh:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchMetrics gets the per-route traffic counters from the running server,
+// with up to logLimit recent requests
+func fetchMetrics(client *http.Client, logLimit int) (*proxy.MetricsSnapshot, error) {
+	path := "/_api/v1/metrics"
+	if logLimit > 0 {
+		path += "?log=" + strconv.Itoa(logLimit)
+	}
+	resp, err := client.Get(dashboardAPIURL(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to roji (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var snap proxy.MetricsSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	return &snap, nil
+}
+
+// topRow is one line of roji top
+type topRow struct {
+	proxy.RouteMetrics
+	rate float64 // requests per second since the previous snapshot; -1 if unknown
+}
+
+// renderTop formats a snapshot, with rates derived from the previous one. On
+// a terminal each frame replaces the last.
+func renderTop(snap, previous *proxy.MetricsSnapshot, terminal bool) string {
+	var elapsed float64
+	before := make(map[string]int64)
+	if previous != nil {
+		elapsed = snap.Time.Sub(previous.Time).Seconds()
+		for _, m := range previous.Routes {
+			before[m.Hostname+m.PathPrefix] = m.Requests
+		}
+	}
+
+	rows := make([]topRow, 0, len(snap.Routes))
+	for _, m := range snap.Routes {
+		row := topRow{RouteMetrics: m, rate: -1}
+		if elapsed > 0 {
+			row.rate = float64(m.Requests-before[m.Hostname+m.PathPrefix]) / elapsed
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].rate > rows[j].rate })
+
+	var b bytes.Buffer
+	if terminal {
+		b.WriteString("\033[H\033[2J") // home, clear screen
+	}
+	fmt.Fprintf(&b, "roji top — %s (every %s, Ctrl-C to quit)\n\n", snap.Time.Local().Format("15:04:05"), topInterval)
+	if len(rows) == 0 {
+		b.WriteString("No requests yet\n")
+		return b.String()
+	}
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROUTE\tREQ/S\t2XX\t3XX\t4XX\t5XX\tP95\tSLOW")
+	for _, row := range rows {
+		rate := "-"
+		if row.rate >= 0 {
+			rate = fmt.Sprintf("%.1f", row.rate)
+		}
+		p95 := "-"
+		if row.Recent > 0 {
+			p95 = fmt.Sprintf("%.0fms", row.P95)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%d\n",
+			row.Hostname+row.PathPrefix, rate,
+			row.Status["2xx"], row.Status["3xx"], row.Status["4xx"], row.Status["5xx"], p95, row.Slow)
+	}
+	tw.Flush()
+	if !terminal {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// nssNicknamePrefix starts the nickname of every roji CA in NSS databases
+const nssNicknamePrefix = "roji CA"
+
+var trustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Trust the roji CA in Firefox and other NSS certificate stores",
+	Long: `Installs the roji CA (ca.pem in --certs-dir) into the NSS databases found
+for your user: Firefox profiles (including snap and Flatpak installs) and
+~/.pki/nssdb, which Chrome and Chromium use on Linux. These don't read the
+operating system's store, so trusting the CA there isn't enough for them.
+
+It runs NSS's certutil (libnss3-tools on Debian/Ubuntu, nss-tools on Fedora,
+brew install nss on macOS). Run it as yourself, not with sudo, and restart
+Firefox afterwards. Firefox on Windows uses the Windows store already.
+
+The system store needs the command roji prints (see also roji doctor).
+roji untrust removes the CA again.`,
+	Args: cobra.NoArgs,
+	RunE: runTrust,
+}
+
+var untrustCmd = &cobra.Command{
+	Use:   "untrust",
+	Short: "Remove roji CAs from Firefox and other NSS certificate stores",
+	Long: `Removes every roji CA, including ones from earlier certificate
+directories, from the NSS databases roji trust installs into.
+
+On macOS, it also removes the /etc/resolver files roji resolver install
+wrote. That needs sudo; without it, roji prints the command to run.`,
+	Args: cobra.NoArgs,
+	RunE: runUntrust,
+}
+
+func init() {
+	rootCmd.AddCommand(trustCmd)
+	rootCmd.AddCommand(untrustCmd)
+}
+
+// nssDatabase is a certificate database of NSS (Firefox, Chrome on Linux)
+type nssDatabase struct {
+	Name string // e.g., "Firefox profile abcd.default-release"
+	Dir  string // certutil -d argument, with the sql: or dbm: prefix
+}
+
+// nssDatabases finds the NSS databases of the current user
+func nssDatabases() []nssDatabase {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var stores []nssDatabase
+	add := func(name, dir string) {
+		switch {
+		case fileExists(filepath.Join(dir, "cert9.db")):
+			stores = append(stores, nssDatabase{Name: name, Dir: "sql:" + dir})
+		case fileExists(filepath.Join(dir, "cert8.db")):
+			stores = append(stores, nssDatabase{Name: name, Dir: "dbm:" + dir})
+		}
+	}
+	addProfiles := func(pattern string) {
+		dirs, _ := filepath.Glob(pattern)
+		for _, dir := range dirs {
+			add("Firefox profile "+filepath.Base(dir), dir)
+		}
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		addProfiles(filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles", "*"))
+	case "windows":
+		// Firefox trusts the Windows store (security.enterprise_roots.enabled)
+	default:
+		add("Chrome/Chromium", filepath.Join(home, ".pki", "nssdb"))
+		add("Chromium (snap)", filepath.Join(home, "snap", "chromium", "current", ".pki", "nssdb"))
+		addProfiles(filepath.Join(home, ".mozilla", "firefox", "*"))
+		addProfiles(filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox", "*"))
+		addProfiles(filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox", "*"))
+	}
+	return stores
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// findCertutil returns the path of NSS's certutil
+func findCertutil() (string, error) {
+	if path, err := exec.LookPath("certutil"); err == nil {
+		return path, nil
+	}
+	// Homebrew's nss is keg-only
+	for _, path := range []string{"/opt/homebrew/opt/nss/bin/certutil", "/usr/local/opt/nss/bin/certutil"} {
+		if fileExists(path) {
+			return path, nil
+		}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return "", errors.New("certutil not found: brew install nss")
+	default:
+		return "", errors.New("certutil not found: install libnss3-tools (Debian/Ubuntu) or nss-tools (Fedora)")
+	}
+}
+
+// loadCA reads the roji CA and names it for NSS. The nickname includes the
+// fingerprint, so a regenerated CA doesn't collide with an older one.
+func loadCA() (path, nickname string, err error) {
+	path = filepath.Join(certsDir, "ca.pem")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("no roji CA in %s (start roji once, or set --certs-dir): %w", certsDir, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", "", fmt.Errorf("%s is not a PEM certificate", path)
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return "", "", fmt.Errorf("invalid CA certificate %s: %w", path, err)
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return path, fmt.Sprintf("%s (%s)", nssNicknamePrefix, hex.EncodeToString(sum[:4])), nil
+}
+
+func runTrust(cmd *cobra.Command, args []string) error {
+	caPath, nickname, err := loadCA()
+	if err != nil {
+		return err
+	}
+
+	stores := nssDatabases()
+	if runtime.GOOS == "windows" {
+		fmt.Println("Firefox on Windows trusts the Windows store; no NSS databases to update")
+	} else if len(stores) == 0 {
+		fmt.Println("No NSS databases found (Firefox profiles, ~/.pki/nssdb); start Firefox once to create its profile")
+	} else {
+		certutil, err := findCertutil()
+		if err != nil {
+			return err
+		}
+		for _, store := range stores {
+			if exec.Command(certutil, "-L", "-d", store.Dir, "-n", nickname).Run() == nil {
+				fmt.Printf("✅ %s: already trusted\n", store.Name)
+				continue
+			}
+			out, err := exec.Command(certutil, "-A", "-d", store.Dir, "-t", "C,,", "-n", nickname, "-i", caPath).CombinedOutput()
+			if err != nil {
+				fmt.Printf("❌ %s: %s\n", store.Name, strings.TrimSpace(string(out)))
+				continue
+			}
+			fmt.Printf("✅ %s: trusted (restart the browser)\n", store.Name)
+		}
+	}
+
+	fmt.Println("\nThe operating system's store (other browsers, curl):")
+	for _, command := range systemTrustCommands() {
+		fmt.Println("  " + command)
+	}
+	return nil
+}
+
+func runUntrust(cmd *cobra.Command, args []string) error {
+	err := untrustNSS()
+	if resolverErr := untrustResolvers(cmd); err == nil {
+		err = resolverErr
+	}
+	return err
+}
+
+// untrustNSS removes the roji CAs from the user's NSS databases
+func untrustNSS() error {
+	stores := nssDatabases()
+	if len(stores) == 0 {
+		fmt.Println("No NSS databases found")
+		return nil
+	}
+	certutil, err := findCertutil()
+	if err != nil {
+		return err
+	}
+
+	for _, store := range stores {
+		out, err := exec.Command(certutil, "-L", "-d", store.Dir).Output()
+		if err != nil {
+			fmt.Printf("❌ %s: failed to list certificates: %v\n", store.Name, err)
+			continue
+		}
+		removed := 0
+		for _, nickname := range rojiNicknames(string(out)) {
+			if out, err := exec.Command(certutil, "-D", "-d", store.Dir, "-n", nickname).CombinedOutput(); err != nil {
+				fmt.Printf("❌ %s: %s\n", store.Name, strings.TrimSpace(string(out)))
+				continue
+			}
+			removed++
+		}
+		if removed > 0 {
+			fmt.Printf("🗑️  %s: removed %d roji CA(s)\n", store.Name, removed)
+		} else {
+			fmt.Printf("%s: no roji CA\n", store.Name)
+		}
+	}
+	return nil
+}
+
+// untrustResolvers removes the /etc/resolver files roji wrote (macOS), so
+// nothing is sent to a DNS server that's gone. The NSS databases are the
+// user's own while /etc/resolver needs root, so without permission it prints
+// the command to run instead of failing.
+func untrustResolvers(cmd *cobra.Command) error {
+	if runtime.GOOS != "darwin" {
+		return nil
+	}
+	domains := rojiResolverDomains()
+	if len(domains) == 0 {
+		return nil
+	}
+	err := runResolverUninstall(cmd, domains)
+	if errors.Is(err, os.ErrPermission) {
+		fmt.Printf("⚠️  Remove roji's resolver files with: sudo roji resolver uninstall %s\n", strings.Join(domains, " "))
+		return nil
+	}
+	return err
+}
+
+// rojiNicknames picks the roji CAs from certutil -L output, whose lines end
+// in the trust attributes after the nickname (e.g., "roji CA (1a2b3c4d)  C,,")
+func rojiNicknames(listing string) []string {
+	var nicknames []string
+	for _, line := range strings.Split(listing, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, nssNicknamePrefix) {
+			continue
+		}
+		if i := strings.LastIndexAny(line, " \t"); i > 0 {
+			nicknames = append(nicknames, strings.TrimSpace(line[:i]))
+		}
+	}
+	return nicknames
+}
+
+// systemTrustCommands returns the commands that install the roji CA into
+// the operating system's trust store
+func systemTrustCommands() []string {
+	caPEM := filepath.Join(certsDir, "ca.pem")
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain " + caPEM}
+	case "windows":
+		return []string{"certutil -addstore -f Root " + filepath.Join(certsDir, "ca.crt") + " (as administrator)"}
+	default:
+		return []string{"sudo cp " + caPEM + " /usr/local/share/ca-certificates/roji.crt && sudo update-ca-certificates"}
+	}
+}
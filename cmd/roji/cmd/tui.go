@@ -0,0 +1,390 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// tuiLogSize is how many recent requests the TUI fetches (all roji keeps)
+const tuiLogSize = 200
+
+var tuiInterval time.Duration
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Terminal dashboard of routes, backend state and recent requests",
+	Long: `Shows the dashboard in the terminal: every route with its target, state,
+request rate, 5xx count and p95 latency, and the most recent requests.
+
+Keys: j/k or arrows select a route, f shows only its requests (again for
+all routes), r refreshes now, q or Ctrl-C quits.`,
+	Args: cobra.NoArgs,
+	RunE: runTUI,
+}
+
+func init() {
+	tuiCmd.Flags().DurationVar(&tuiInterval, "interval", time.Second, "Refresh interval")
+	rootCmd.AddCommand(tuiCmd)
+}
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return fmt.Errorf("roji tui needs a terminal (try roji top or roji routes)")
+	}
+	if tuiInterval < 100*time.Millisecond {
+		return fmt.Errorf("--interval must be at least 100ms")
+	}
+	client, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	// Fail before taking over the screen if roji isn't reachable
+	state := &tuiState{}
+	if err := state.refresh(client); err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+	defer term.Restore(fd, oldState)
+	// Alternate screen, hidden cursor, no line wrapping
+	fmt.Print("\033[?1049h\033[?25l\033[?7l")
+	defer fmt.Print("\033[?7h\033[?25h\033[?1049l")
+
+	keys := make(chan string)
+	go readKeys(keys)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM)
+
+	ticker := time.NewTicker(tuiInterval)
+	defer ticker.Stop()
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Print(state.render(width, height))
+
+		select {
+		case key, ok := <-keys:
+			if !ok || !state.handleKey(key) {
+				return nil
+			}
+			if key == "r" {
+				state.err = state.refresh(client)
+			}
+		case <-ticker.C:
+			state.err = state.refresh(client)
+		case <-sigCh:
+			return nil
+		}
+	}
+}
+
+// readKeys sends each read from the raw terminal; a read holds one key or
+// escape sequence
+func readKeys(keys chan<- string) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		keys <- string(buf[:n])
+	}
+}
+
+// tuiRoute is one row of the route table: a hostname and path prefix, with
+// all of its replicas
+type tuiRoute struct {
+	name     string // hostname + path prefix
+	hostname string
+	targets  []string
+	redirect string
+	paused   int    // replicas paused
+	maint    bool   // in maintenance mode (answers 503)
+	health   string // worst probe result of the replicas ("" if not probed)
+	metrics  proxy.RouteMetrics
+	rate     float64 // requests per second; -1 before the second refresh
+	errors   bool    // 5xx responses since the previous refresh
+}
+
+// state describes the route for the STATE column
+func (r tuiRoute) state() (string, string) {
+	switch {
+	case r.redirect != "":
+		return "alias", ""
+	case r.maint:
+		return "maint", ansiYellow
+	case r.paused == len(r.targets):
+		return "paused", ansiYellow
+	case r.health == proxy.HealthDown:
+		return "down", ansiRed
+	case r.errors:
+		return "errors", ansiRed
+	case r.paused > 0 || r.health == proxy.HealthDegraded:
+		return "degraded", ansiYellow
+	}
+	return "ok", ansiGreen
+}
+
+type tuiState struct {
+	routes   []tuiRoute
+	log      []proxy.RequestLogEntry
+	previous *proxy.MetricsSnapshot
+	updated  time.Time
+	err      error // of the last refresh; the previous data stays on screen
+
+	selected int
+	filter   string // hostname whose requests are listed; "" for all
+}
+
+// refresh fetches routes and metrics from the running server
+func (s *tuiState) refresh(client *http.Client) error {
+	infos, err := fetchRoutes()
+	if err != nil {
+		return err
+	}
+	snap, err := fetchMetrics(client, tuiLogSize)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]*tuiRoute)
+	var names []string
+	for _, info := range infos {
+		name := info.Hostname + info.PathPrefix
+		r, ok := byName[name]
+		if !ok {
+			r = &tuiRoute{name: name, hostname: info.Hostname, redirect: info.RedirectTo, rate: -1}
+			byName[name] = r
+			names = append(names, name)
+		}
+		r.targets = append(r.targets, info.Target)
+		if info.Paused {
+			r.paused++
+		}
+		r.maint = r.maint || info.Maintenance
+		if info.Health != nil && healthRank(info.Health.State) > healthRank(r.health) {
+			r.health = info.Health.State
+		}
+	}
+
+	var elapsed float64
+	before := make(map[string]proxy.RouteMetrics)
+	if s.previous != nil {
+		elapsed = snap.Time.Sub(s.previous.Time).Seconds()
+		for _, m := range s.previous.Routes {
+			before[m.Hostname+m.PathPrefix] = m
+		}
+	}
+	for _, m := range snap.Routes {
+		r, ok := byName[m.Hostname+m.PathPrefix]
+		if !ok {
+			continue // the route went away
+		}
+		r.metrics = m
+		prev := before[r.name]
+		if elapsed > 0 {
+			r.rate = float64(m.Requests-prev.Requests) / elapsed
+			r.errors = m.Status["5xx"] > prev.Status["5xx"]
+		}
+	}
+	for _, r := range byName {
+		if r.rate < 0 && elapsed > 0 {
+			r.rate = 0
+		}
+	}
+
+	sort.Strings(names)
+	selectedName := ""
+	if s.selected < len(s.routes) {
+		selectedName = s.routes[s.selected].name
+	}
+	s.routes = s.routes[:0]
+	for i, name := range names {
+		s.routes = append(s.routes, *byName[name])
+		if name == selectedName {
+			s.selected = i // keep the selection when routes come and go
+		}
+	}
+	s.selected = min(s.selected, max(len(s.routes)-1, 0))
+	s.log = snap.Log
+	s.previous = snap
+	s.updated = snap.Time
+	return nil
+}
+
+// healthRank orders probe results from best to worst
+func healthRank(state string) int {
+	switch state {
+	case proxy.HealthDown:
+		return 2
+	case proxy.HealthDegraded:
+		return 1
+	}
+	return 0
+}
+
+// handleKey applies a key press; it returns false to quit
+func (s *tuiState) handleKey(key string) bool {
+	switch key {
+	case "q", "\x03", "\x04": // q, Ctrl-C, Ctrl-D
+		return false
+	case "j", "\x1b[B":
+		s.selected = min(s.selected+1, max(len(s.routes)-1, 0))
+	case "k", "\x1b[A":
+		s.selected = max(s.selected-1, 0)
+	case "f":
+		switch {
+		case s.filter != "":
+			s.filter = ""
+		case s.selected < len(s.routes):
+			s.filter = s.routes[s.selected].hostname
+		}
+	}
+	return true
+}
+
+const (
+	ansiReset   = "\033[0m"
+	ansiBold    = "\033[1m"
+	ansiDim     = "\033[2m"
+	ansiReverse = "\033[7m"
+	ansiRed     = "\033[31m"
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+	ansiCyan    = "\033[36m"
+)
+
+// render draws a full frame of the given size. Lines longer than the
+// terminal are cut off by it (line wrapping is disabled).
+func (s *tuiState) render(width, height int) string {
+	var lines []string
+	title := fmt.Sprintf(" roji · %d routes · %s", len(s.routes), s.updated.Local().Format("15:04:05"))
+	keys := "j/k select · f filter · r refresh · q quit "
+	gap := max(width-utf8.RuneCountInString(title)-utf8.RuneCountInString(keys), 1)
+	lines = append(lines, ansiReverse+title+strings.Repeat(" ", gap)+keys+ansiReset, "")
+
+	// Route table: up to half the screen, scrolled to the selection
+	nameWidth, targetWidth := 5, 6
+	for _, r := range s.routes {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(r.name))
+		targetWidth = max(targetWidth, utf8.RuneCountInString(tuiTarget(r)))
+	}
+	nameWidth, targetWidth = min(nameWidth, 40), min(targetWidth, 30)
+	lines = append(lines, ansiBold+fmt.Sprintf("  %s  %s  %-8s  %7s  %5s  %7s",
+		fit("ROUTE", nameWidth), fit("TARGET", targetWidth), "STATE", "REQ/S", "5XX", "P95")+ansiReset)
+
+	tableRows := max((height-4)/2, 1)
+	first := max(s.selected-tableRows+1, 0)
+	if len(s.routes) == 0 {
+		lines = append(lines, ansiDim+"  No routes registered"+ansiReset)
+	}
+	for i := first; i < len(s.routes) && i < first+tableRows; i++ {
+		r := s.routes[i]
+		state, color := r.state()
+		rate, p95 := "-", "-"
+		if r.rate >= 0 {
+			rate = fmt.Sprintf("%.1f", r.rate)
+		}
+		if r.metrics.Recent > 0 {
+			p95 = fmt.Sprintf("%.0fms", r.metrics.P95)
+		}
+		marker, style := "  ", ""
+		if i == s.selected {
+			marker, style = "▸ ", ansiBold
+		}
+		lines = append(lines, fmt.Sprintf("%s%s%s  %s  %s%-8s%s  %7s  %5d  %7s%s",
+			style, marker, fit(r.name, nameWidth), fit(tuiTarget(r), targetWidth),
+			color, state, ansiReset+style, rate, r.metrics.Status["5xx"], p95, ansiReset))
+	}
+
+	// Recent requests fill the rest
+	scope := "all routes"
+	if s.filter != "" {
+		scope = s.filter
+	}
+	lines = append(lines, "", ansiBold+"  RECENT REQUESTS ("+scope+")"+ansiReset)
+	logRows := height - len(lines) - 1
+	shown := 0
+	for _, e := range s.log {
+		if shown >= logRows {
+			break
+		}
+		if s.filter != "" && e.Hostname != s.filter {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %s  %-7s %s%d%s  %7s  %s%s",
+			e.Time.Local().Format("15:04:05"), e.Method, statusColor(e.Status), e.Status, ansiReset,
+			e.Duration.Round(time.Millisecond), e.Hostname, e.Path))
+		shown++
+	}
+	if shown == 0 {
+		lines = append(lines, ansiDim+"  No requests yet"+ansiReset)
+	}
+
+	if s.err != nil {
+		for len(lines) < height-1 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, ansiRed+" "+s.err.Error()+ansiReset)
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	// Home, then clear the rest of each line and the screen below
+	return "\033[H" + strings.Join(lines, "\033[K\r\n") + "\033[K\033[J"
+}
+
+// tuiTarget summarizes the targets of a route's replicas
+func tuiTarget(r tuiRoute) string {
+	switch {
+	case r.redirect != "":
+		return "→ " + r.redirect
+	case len(r.targets) > 1:
+		return fmt.Sprintf("%s (+%d)", r.targets[0], len(r.targets)-1)
+	case len(r.targets) == 1:
+		return r.targets[0]
+	}
+	return ""
+}
+
+// statusColor colors a status code by class
+func statusColor(status int) string {
+	switch {
+	case status >= 500:
+		return ansiRed
+	case status >= 400:
+		return ansiYellow
+	case status >= 300:
+		return ansiCyan
+	}
+	return ansiGreen
+}
+
+// fit pads or truncates s to exactly width runes
+func fit(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
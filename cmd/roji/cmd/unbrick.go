@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var unbrickCmd = &cobra.Command{
+	Use:   "unbrick <hostname>",
+	Short: "Show how to clear stuck browser state for a hostname",
+	Long: `Prints the URL of roji's cleanup page for a hostname. Its reset button
+clears cookies, storage, caches and service workers for that origin in the
+browser, and the page explains how to remove HSTS pins, which a page cannot
+clear itself.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHostname,
+	Run: func(cmd *cobra.Command, args []string) {
+		hostname := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(args[0], "https://"), "/"))
+
+		host := hostname
+		if httpsPort != 443 {
+			host = fmt.Sprintf("%s:%d", hostname, httpsPort)
+		}
+
+		fmt.Printf("Open this page in the affected browser:\n\n  https://%s%s\n\n", host, proxy.UnbrickPath)
+		fmt.Println("Its reset button clears cookies, storage, caches and service workers for the origin.")
+		fmt.Println("HSTS pins must be removed by hand:")
+		fmt.Printf("  Chrome/Edge: chrome://net-internals/#hsts -> Delete domain security policies -> %s\n", hostname)
+		fmt.Println("  Firefox:     History -> right-click the site -> Forget About This Site")
+		fmt.Printf("\nFor a guaranteed clean origin, run: roji rotate %s\n", hostname)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(unbrickCmd)
+}
@@ -0,0 +1,158 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// upgradeReadyTimeout bounds how long the new process may take to serve,
+// including waiting for Docker and discovering containers
+const upgradeReadyTimeout = 5 * time.Minute
+
+// watchUpgrades starts the roji binary again on SIGUSR2, passing it the
+// listening sockets: the HTTP one (if any), then the HTTPS ones, and the DNS
+// socket (--dns-port; nil = none) after the readiness pipe. The returned
+// channel is closed once the new process serves; a new process that fails to
+// start leaves this one serving.
+func watchUpgrades(ctx context.Context, httpLn net.Listener, httpsLns []net.Listener, dnsConn net.PacketConn) <-chan struct{} {
+	// Named like systemd sockets; the extra HTTPS ports go by their port
+	var listeners []net.Listener
+	var names []string
+	if httpLn != nil {
+		listeners = append(listeners, httpLn)
+		names = append(names, "http")
+	}
+	if len(httpsLns) > 0 {
+		names = append(names, "https")
+	}
+	listeners = append(listeners, httpsLns...)
+
+	upgraded := make(chan struct{})
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(sigCh)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigCh:
+			}
+			slog.Info("upgrade requested, starting new roji process")
+			pid, err := upgrade(ctx, listeners, names, dnsConn)
+			if err != nil {
+				slog.Error("upgrade failed, still serving", "error", err)
+				continue
+			}
+			slog.Info("upgrade complete, finishing open connections", "new_pid", pid)
+			close(upgraded)
+			return
+		}
+	}()
+	return upgraded
+}
+
+// upgrade starts the roji binary with the same arguments and sockets, and
+// waits until it reports that it serves
+func upgrade(ctx context.Context, listeners []net.Listener, names []string, dnsConn net.PacketConn) (int, error) {
+	exe, err := os.Executable() // The path, so a replaced binary is picked up
+	if err != nil {
+		return 0, err
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, ln := range listeners {
+		tcp, ok := ln.(*net.TCPListener)
+		if !ok {
+			return 0, fmt.Errorf("can't pass listener %s", ln.Addr())
+		}
+		f, err := tcp.File()
+		if err != nil {
+			return 0, err
+		}
+		files = append(files, f)
+	}
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer ready.Close()
+	files = append(files, readyW)
+	var dnsEnv []string
+	if dnsConn != nil {
+		udp, ok := dnsConn.(*net.UDPConn)
+		if !ok {
+			return 0, fmt.Errorf("can't pass DNS socket %s", dnsConn.LocalAddr())
+		}
+		f, err := udp.File()
+		if err != nil {
+			return 0, err
+		}
+		files = append(files, f)
+		dnsEnv = append(dnsEnv, upgradeDNSEnv+"="+strconv.Itoa(listenFDsStart+len(files)-1))
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		"LISTEN_FDS="+strconv.Itoa(len(listeners)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+		upgradeParentEnv+"="+strconv.Itoa(os.Getpid()),
+		upgradeReadyEnv+"="+strconv.Itoa(listenFDsStart+len(listeners)),
+	)
+	cmd.Env = append(cmd.Env, dnsEnv...)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	readyW.Close() // The child holds the write end now
+
+	readyCh := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if _, err := ready.Read(buf); err != nil {
+			readyCh <- errors.New("new process exited before serving")
+			return
+		}
+		readyCh <- nil
+	}()
+
+	select {
+	case err := <-readyCh:
+		if err != nil {
+			cmd.Wait()
+			return 0, fmt.Errorf("%w (%v)", err, cmd.ProcessState)
+		}
+	case <-time.After(upgradeReadyTimeout):
+		cmd.Process.Kill()
+		cmd.Wait()
+		return 0, fmt.Errorf("new process didn't serve within %s", upgradeReadyTimeout)
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		cmd.Wait()
+		return 0, ctx.Err()
+	}
+	// The new process outlives this one; nobody waits for it here
+	go cmd.Wait()
+	return cmd.Process.Pid, nil
+}
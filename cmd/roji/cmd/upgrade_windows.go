@@ -0,0 +1,14 @@
+//go:build windows
+
+package cmd
+
+import (
+	"context"
+	"net"
+)
+
+// watchUpgrades never fires on Windows: there is no SIGUSR2, and sockets
+// can't be passed to a new process the same way
+func watchUpgrades(ctx context.Context, httpLn net.Listener, httpsLns []net.Listener, dnsConn net.PacketConn) <-chan struct{} {
+	return nil
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var urlProject string
+
+var urlCmd = &cobra.Command{
+	Use:   "url <service>",
+	Short: "Print the https URL of a compose service or container",
+	Long: `Prints the canonical URL the running roji server routes to a compose
+service, container or hostname, for scripts, Makefiles and editor tasks:
+
+  open "$(roji url web)"
+  API_URL=$(roji url api) npm test
+
+When several compose projects have the service, the project of the current
+directory (COMPOSE_PROJECT_NAME, or the directory name like docker compose)
+wins; --project picks another.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeService,
+	RunE:              runURL,
+}
+
+func init() {
+	urlCmd.Flags().StringVarP(&urlProject, "project", "p", "", "Compose project of the service (default: the current directory's)")
+	urlCmd.RegisterFlagCompletionFunc("project", completeProject)
+	rootCmd.AddCommand(urlCmd)
+}
+
+func runURL(cmd *cobra.Command, args []string) error {
+	routes, err := fetchRoutes()
+	if err != nil {
+		return err
+	}
+	route, err := findServiceRoute(routes, args[0], urlProject, currentComposeProject())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(serverOrigin(route.Hostname) + route.PathPrefix)
+	return nil
+}
+
+// findServiceRoute picks the canonical route of a service, container or
+// hostname. Aliases are skipped; of the remaining routes, one without a path
+// prefix and with the shortest hostname wins (e.g., web.app.localhost over
+// web-9229.app.localhost).
+func findServiceRoute(routes []proxy.RouteInfo, name, project, cwdProject string) (proxy.RouteInfo, error) {
+	name = strings.ToLower(name)
+	var matches []proxy.RouteInfo
+	for _, r := range routes {
+		if r.RedirectTo != "" {
+			continue
+		}
+		if project != "" && r.ProjectName != project {
+			continue
+		}
+		if r.ServiceName == name || r.ContainerName == name || r.Hostname == name {
+			matches = append(matches, r)
+		}
+	}
+	if len(matches) == 0 {
+		if project != "" {
+			return proxy.RouteInfo{}, fmt.Errorf("no route for %q in project %q (see `roji routes`)", name, project)
+		}
+		return proxy.RouteInfo{}, fmt.Errorf("no route for %q (see `roji routes`)", name)
+	}
+
+	// The same service in several projects
+	projects := make(map[string]bool)
+	for _, r := range matches {
+		projects[r.ProjectName] = true
+	}
+	if len(projects) > 1 {
+		if !projects[cwdProject] {
+			names := make([]string, 0, len(projects))
+			for p := range projects {
+				names = append(names, p)
+			}
+			sort.Strings(names)
+			return proxy.RouteInfo{}, fmt.Errorf("%q exists in several projects (%s); pick one with --project", name, strings.Join(names, ", "))
+		}
+		var own []proxy.RouteInfo
+		for _, r := range matches {
+			if r.ProjectName == cwdProject {
+				own = append(own, r)
+			}
+		}
+		matches = own
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if (a.PathPrefix == "") != (b.PathPrefix == "") {
+			return a.PathPrefix == ""
+		}
+		if len(a.Hostname) != len(b.Hostname) {
+			return len(a.Hostname) < len(b.Hostname)
+		}
+		return a.Hostname+a.PathPrefix < b.Hostname+b.PathPrefix
+	})
+	return matches[0], nil
+}
+
+// currentComposeProject returns the project docker compose would use in the
+// current directory: COMPOSE_PROJECT_NAME or the normalized directory name
+func currentComposeProject() string {
+	if name := os.Getenv("COMPOSE_PROJECT_NAME"); name != "" {
+		return name
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(filepath.Base(dir)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimLeft(b.String(), "_-")
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kan/roji/proxy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	waitTimeout  time.Duration
+	waitInterval time.Duration
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait <hostname[/path]>...",
+	Short: "Wait until routes exist and their backends respond",
+	Long: `Blocks until each route is registered and a GET through roji answers 2xx,
+so scripts and e2e tests can start once a compose stack is really up:
+
+  docker compose up -d
+  roji wait api.myproject.dev.localhost/healthz web.myproject.dev.localhost --timeout 60s
+
+Exits with 1 when --timeout passes first, printing what was still missing.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeRouteURL,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := waitForRoutes(args, waitTimeout, waitInterval); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	waitCmd.Flags().DurationVarP(&waitTimeout, "timeout", "t", 60*time.Second, "Give up after this long")
+	waitCmd.Flags().DurationVar(&waitInterval, "interval", time.Second, "Time between checks")
+	rootCmd.AddCommand(waitCmd)
+}
+
+// waitTarget is a route to wait for and the path its backend is checked at
+type waitTarget struct {
+	hostname string
+	path     string
+}
+
+// parseWaitTarget accepts "api.localhost", "api.localhost/healthz" or a URL
+func parseWaitTarget(arg string) waitTarget {
+	arg = strings.TrimPrefix(strings.TrimPrefix(arg, "https://"), "http://")
+	hostname, path, _ := strings.Cut(arg, "/")
+	return waitTarget{hostname: strings.ToLower(hostname), path: "/" + path}
+}
+
+func (t waitTarget) url() string {
+	return serverOrigin(t.hostname) + t.path
+}
+
+// waitForRoutes polls until every target is up or the timeout passes
+func waitForRoutes(args []string, timeout, interval time.Duration) error {
+	pending := make([]waitTarget, len(args))
+	for i, arg := range args {
+		pending[i] = parseWaitTarget(arg)
+	}
+
+	apiClient, err := newAPIClient(5 * time.Second)
+	if err != nil {
+		return err
+	}
+	// Backends get their own client: the API token is only for roji
+	tlsConfig, err := clientTLSConfig()
+	if err != nil {
+		return err
+	}
+	backendClient := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, DialContext: dialRoji},
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	reasons := make(map[waitTarget]string)
+	for {
+		routes, routesErr := fetchRoutesWith(apiClient)
+
+		var still []waitTarget
+		for _, target := range pending {
+			reason := waitReason(backendClient, target, routes, routesErr)
+			if reason == "" {
+				fmt.Printf("✅ %s is up after %s\n", target.url(), time.Since(start).Round(100*time.Millisecond))
+				continue
+			}
+			if reasons[target] != reason {
+				fmt.Printf("⏳ %s: %s\n", target.url(), reason)
+				reasons[target] = reason
+			}
+			still = append(still, target)
+		}
+		pending = still
+		if len(pending) == 0 {
+			return nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			var missing []string
+			for _, target := range pending {
+				missing = append(missing, fmt.Sprintf("%s (%s)", target.url(), reasons[target]))
+			}
+			return fmt.Errorf("timed out after %s waiting for %s", timeout, strings.Join(missing, ", "))
+		}
+		time.Sleep(interval)
+	}
+}
+
+// waitReason returns why a target isn't up yet, or "" when it is
+func waitReason(client *http.Client, target waitTarget, routes []proxy.RouteInfo, routesErr error) string {
+	if routesErr != nil {
+		return routesErr.Error()
+	}
+	routed := false
+	for _, r := range routes {
+		if r.Hostname == target.hostname && strings.HasPrefix(target.path, r.PathPrefix) {
+			routed = true
+			break
+		}
+	}
+	if !routed {
+		return "no route yet"
+	}
+
+	resp, err := client.Get(target.url())
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Sprintf("backend answered %d", resp.StatusCode)
+	}
+	return ""
+}
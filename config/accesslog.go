@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// AccessLogFields are the fields a request log line can carry
+// (roji.access-log-fields, --access-log-fields)
+var AccessLogFields = []string{"method", "host", "path", "query", "status", "duration", "target", "client", "user_agent", "referer"}
+
+// DefaultAccessLogFields are logged for routes that don't choose fields
+var DefaultAccessLogFields = []string{"method", "host", "path", "status", "duration", "target"}
+
+// AccessLogPolicy controls the request log lines of a route
+type AccessLogPolicy struct {
+	Off    bool     // roji.access-log=false: no request lines at all
+	Fields []string // nil: the --access-log-fields default
+	Sample float64  // fraction of successful requests logged, in (0, 1); 0 logs all
+}
+
+// IsZero reports whether the route logs like every other route
+func (p AccessLogPolicy) IsZero() bool {
+	return !p.Off && p.Fields == nil && p.Sample == 0
+}
+
+// ParseAccessLogFields parses comma-separated field names, e.g.
+// "method,path,status"
+func ParseAccessLogFields(value string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(AccessLogFields, field) {
+			return nil, fmt.Errorf("unknown access log field %q (want %s)", field, strings.Join(AccessLogFields, ", "))
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no access log fields in %q", value)
+	}
+	return fields, nil
+}
+
+// parseAccessLogSample reads a sampling rate as a fraction ("0.1") or a
+// percentage ("10%"). Rates of 1 or more log everything.
+func parseAccessLogSample(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	percent := strings.HasSuffix(value, "%")
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || rate <= 0 {
+		return 0, false
+	}
+	if percent {
+		rate /= 100
+	}
+	if rate >= 1 {
+		return 0, true
+	}
+	return rate, true
+}
+
+// parseAccessLogPolicy reads roji.access-log*, skipping invalid values
+func parseAccessLogPolicy(labels map[string]string) AccessLogPolicy {
+	policy := AccessLogPolicy{Off: isFalse(labels, LabelAccessLog)}
+	if value, ok := labels[LabelAccessLogFields]; ok {
+		if fields, err := ParseAccessLogFields(value); err == nil {
+			policy.Fields = fields
+		}
+	}
+	if value, ok := labels[LabelAccessLogSample]; ok {
+		policy.Sample, _ = parseAccessLogSample(value)
+	}
+	return policy
+}
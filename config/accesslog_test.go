@@ -0,0 +1,52 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAccessLogFields(t *testing.T) {
+	fields, err := ParseAccessLogFields(" Method, path ,status,,path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"method", "path", "status"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+
+	for _, value := range []string{"method,bytes", "", " , "} {
+		if _, err := ParseAccessLogFields(value); err == nil {
+			t.Errorf("ParseAccessLogFields(%q) succeeded", value)
+		}
+	}
+}
+
+func TestParseLabels_AccessLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   AccessLogPolicy
+	}{
+		{"none", map[string]string{}, AccessLogPolicy{}},
+		{"off", map[string]string{"roji.access-log": "false"}, AccessLogPolicy{Off: true}},
+		{"on", map[string]string{"roji.access-log": "true"}, AccessLogPolicy{}},
+		{"fields", map[string]string{"roji.access-log-fields": "path,status"}, AccessLogPolicy{Fields: []string{"path", "status"}}},
+		{"unknown field", map[string]string{"roji.access-log-fields": "path,bytes"}, AccessLogPolicy{}},
+		{"fraction", map[string]string{"roji.access-log-sample": "0.1"}, AccessLogPolicy{Sample: 0.1}},
+		{"percentage", map[string]string{"roji.access-log-sample": " 5% "}, AccessLogPolicy{Sample: 0.05}},
+		{"everything", map[string]string{"roji.access-log-sample": "100%"}, AccessLogPolicy{}},
+		{"invalid sample", map[string]string{"roji.access-log-sample": "often"}, AccessLogPolicy{}},
+		{"zero sample", map[string]string{"roji.access-log-sample": "0"}, AccessLogPolicy{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseLabels(tt.labels).AccessLog
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AccessLog = %+v, want %+v", got, tt.want)
+			}
+			if got.IsZero() != tt.want.IsZero() {
+				t.Errorf("IsZero() = %v", got.IsZero())
+			}
+		})
+	}
+}
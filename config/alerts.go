@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseErrorRate parses the 5xx share that fires an error-rate alert
+// (--error-alert-threshold), as a fraction ("0.25") or a percentage ("25%").
+// "0" disables alerting.
+func ParseErrorRate(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	percent := strings.HasSuffix(value, "%")
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid error rate %q (want e.g. 0.5 or 50%%)", value)
+	}
+	if percent {
+		rate /= 100
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("error rate %q is outside 0-100%%", value)
+	}
+	return rate, nil
+}
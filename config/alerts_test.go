@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestParseErrorRate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"0.5", 0.5, false},
+		{"50%", 0.5, false},
+		{" 25% ", 0.25, false},
+		{"1", 1, false},
+		{"100%", 1, false},
+		{"0", 0, false},
+		{"150%", 0, true},
+		{"-0.1", 0, true},
+		{"half", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseErrorRate(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseErrorRate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseErrorRate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
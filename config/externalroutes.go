@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ExternalRoute proxies a local hostname to a URL outside Docker (e.g., a
+// staging API)
+type ExternalRoute struct {
+	Hostname string // Full hostname, or a name expanded with the base domain
+	URL      string // http(s) URL; a path is prepended to request paths
+}
+
+// ParseExternalRoutes parses comma-separated "hostname=url" entries
+// (e.g., "api=https://api.staging.example.com"). Hostnames without a dot get
+// the base domain appended.
+func ParseExternalRoutes(value, baseDomain string) ([]ExternalRoute, error) {
+	var routes []ExternalRoute
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, raw, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		raw = strings.TrimSpace(raw)
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid external route %q: want hostname=url", entry)
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid external route %q: want an http(s) URL", entry)
+		}
+		if !strings.Contains(host, ".") {
+			host = DefaultHostname(host, baseDomain)
+		}
+		routes = append(routes, ExternalRoute{Hostname: host, URL: strings.TrimSuffix(u.String(), "/")})
+	}
+	return routes, nil
+}
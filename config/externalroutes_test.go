@@ -0,0 +1,26 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExternalRoutes(t *testing.T) {
+	routes, err := ParseExternalRoutes("api=https://api.staging.example.com/, cms.example.localhost=http://10.0.0.5:8080/v2", "dev.localhost")
+	if err != nil {
+		t.Fatalf("ParseExternalRoutes() error = %v", err)
+	}
+	want := []ExternalRoute{
+		{Hostname: "api.dev.localhost", URL: "https://api.staging.example.com"},
+		{Hostname: "cms.example.localhost", URL: "http://10.0.0.5:8080/v2"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("ParseExternalRoutes() = %+v, want %+v", routes, want)
+	}
+
+	for _, value := range []string{"api", "api=staging.example.com", "api=ftp://example.com", "=https://example.com"} {
+		if _, err := ParseExternalRoutes(value, "dev.localhost"); err == nil {
+			t.Errorf("ParseExternalRoutes(%q) error = nil, want error", value)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a roji.yaml configuration file (--config). Top-level keys named
+// like flags set those flags; the sections below hold what flags can't
+// express.
+//
+//	domain: dev.localhost
+//	lan-trust: [192.168.1.0/24]
+//	routes:
+//	  - {host: docs, dir: /srv/docs, spa: true}
+//	  - {host: vite, port: 5173}
+//	middleware:
+//	  cookie-secure: "true"
+//	projects:
+//	  shop:
+//	    domain: shop.localhost
+//	    middleware: {banner: "Shop preview"}
+//	tls:
+//	  min-version: "1.3"
+//	profiles:
+//	  work: {domain: dev.corp.test, docker-host: "ssh://dev@build-box"}
+type File struct {
+	// Flags are settings by flag name ("domain", "https-port", ...)
+	Flags map[string]any `yaml:",inline"`
+
+	// Routes to host processes, directories and external URLs, with the
+	// options the comma-separated flags leave out
+	Routes []FileRoute `yaml:"routes"`
+
+	// Middleware are roji.* labels (without the prefix) every container gets
+	// unless it sets them itself, e.g. "banner" or "cookie-secure"
+	Middleware map[string]string `yaml:"middleware"`
+
+	// Projects override domains and middleware per compose project
+	Projects map[string]ProjectConfig `yaml:"projects"`
+
+	TLS TLSConfig `yaml:"tls"`
+
+	// DockerHost is the Docker daemon to watch when DOCKER_HOST isn't set,
+	// e.g. "ssh://dev@build-box"
+	DockerHost string `yaml:"docker-host"`
+
+	// Profiles are named sets of settings applied over the top-level ones
+	// (--profile, or a top-level "profile" key for the default)
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile is a named setup, e.g. "work" with a corporate domain and a remote
+// Docker host, and "personal" on localhost
+type Profile struct {
+	Flags      map[string]any `yaml:",inline"` // Settings by flag name, over the top-level ones
+	DockerHost string         `yaml:"docker-host"`
+}
+
+// FileRoute is a route of the routes section: a host process (port), a
+// directory (dir) or an external URL (url)
+type FileRoute struct {
+	Host string `yaml:"host"` // Full hostname, or a name expanded with the base domain
+	Port int    `yaml:"port"`
+	Dir  string `yaml:"dir"`
+	SPA  bool   `yaml:"spa"` // With dir: serve index.html for unknown paths
+	URL  string `yaml:"url"`
+}
+
+// ProjectConfig is the configuration of one compose project
+type ProjectConfig struct {
+	// Domain replaces {project}.{base domain}: a single-service project is
+	// served on the domain itself, others on {service}.{domain}
+	Domain     string            `yaml:"domain"`
+	Middleware map[string]string `yaml:"middleware"` // Added to (and overriding) the global middleware
+}
+
+// TLSConfig tunes the HTTPS listener and the generated certificate
+type TLSConfig struct {
+	MinVersion string   `yaml:"min-version"` // "1.2" (default) or "1.3"
+	Names      []string `yaml:"names"`       // Extra names for the generated certificate
+}
+
+// LoadFile reads and validates a configuration file
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFile(data)
+}
+
+// ParseFile parses and validates a YAML configuration
+func ParseFile(data []byte) (*File, error) {
+	f := &File{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	for i, route := range f.Routes {
+		if err := route.validate(); err != nil {
+			return nil, fmt.Errorf("routes[%d]: %w", i, err)
+		}
+	}
+	for name, project := range f.Projects {
+		if strings.ContainsAny(project.Domain, " /:") {
+			return nil, fmt.Errorf("projects.%s: invalid domain %q", name, project.Domain)
+		}
+	}
+	for name, profile := range f.Profiles {
+		if _, ok := profile.Flags["profile"]; ok {
+			return nil, fmt.Errorf("profiles.%s: a profile can't select another profile", name)
+		}
+	}
+	if name := f.DefaultProfile(); name != "" {
+		if _, ok := f.Profiles[name]; !ok {
+			return nil, fmt.Errorf("profile: %q is not defined under profiles", name)
+		}
+	}
+	switch f.TLS.MinVersion {
+	case "", "1.2", "1.3":
+	default:
+		return nil, fmt.Errorf("tls.min-version: %q is not supported (want 1.2 or 1.3)", f.TLS.MinVersion)
+	}
+	return f, nil
+}
+
+func (r FileRoute) validate() error {
+	if strings.TrimSpace(r.Host) == "" {
+		return fmt.Errorf("host is required")
+	}
+	targets := 0
+	if r.Port != 0 {
+		targets++
+		if r.Port < 1 || r.Port > 65535 {
+			return fmt.Errorf("invalid port %d", r.Port)
+		}
+	}
+	if r.Dir != "" {
+		targets++
+	}
+	if r.URL != "" {
+		targets++
+		u, err := url.Parse(r.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid url %q: want an http(s) URL", r.URL)
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("route %q needs exactly one of port, dir or url", r.Host)
+	}
+	if r.SPA && r.Dir == "" {
+		return fmt.Errorf("route %q: spa needs dir", r.Host)
+	}
+	return nil
+}
+
+// ProfileNames returns the names of the file's profiles, sorted
+func (f *File) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultProfile returns the profile the file selects itself, if any
+func (f *File) DefaultProfile() string {
+	if name, ok := f.Flags["profile"]; ok {
+		return flagString(name)
+	}
+	return ""
+}
+
+// WithProfile returns the file with a profile's settings applied over the
+// top-level ones
+func (f *File) WithProfile(name string) (*File, error) {
+	profile, ok := f.Profiles[name]
+	if !ok {
+		if len(f.Profiles) == 0 {
+			return nil, fmt.Errorf("unknown profile %q: the file defines no profiles", name)
+		}
+		return nil, fmt.Errorf("unknown profile %q (want one of %s)", name, strings.Join(f.ProfileNames(), ", "))
+	}
+	merged := *f
+	merged.Flags = make(map[string]any, len(f.Flags)+len(profile.Flags))
+	maps.Copy(merged.Flags, f.Flags)
+	maps.Copy(merged.Flags, profile.Flags)
+	merged.Flags["profile"] = name
+	if profile.DockerHost != "" {
+		merged.DockerHost = profile.DockerHost
+	}
+	return &merged, nil
+}
+
+// FlagValues returns the flag settings as strings for flag.Value.Set. Lists
+// are joined with commas, like the comma-separated flags expect.
+func (f *File) FlagValues() map[string]string {
+	values := make(map[string]string, len(f.Flags))
+	for name, value := range f.Flags {
+		values[name] = flagString(value)
+	}
+	return values
+}
+
+func flagString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = flagString(item)
+		}
+		return strings.Join(parts, ",")
+	case map[string]any:
+		// e.g. host-routes: {vite: 5173, api: 3000}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = key + "=" + flagString(v[key])
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// SplitRoutes returns the routes section as host, static and external routes.
+// Hostnames without a dot get the base domain appended.
+func (f *File) SplitRoutes(baseDomain string) ([]HostRoute, []StaticRoute, []ExternalRoute) {
+	var hosts []HostRoute
+	var statics []StaticRoute
+	var externals []ExternalRoute
+	for _, r := range f.Routes {
+		host := strings.ToLower(strings.TrimSpace(r.Host))
+		if !strings.Contains(host, ".") {
+			host = DefaultHostname(host, baseDomain)
+		}
+		switch {
+		case r.Port != 0:
+			hosts = append(hosts, HostRoute{Hostname: host, Port: r.Port})
+		case r.Dir != "":
+			statics = append(statics, StaticRoute{Hostname: host, Dir: filepath.Clean(r.Dir), SPA: r.SPA})
+		default:
+			externals = append(externals, ExternalRoute{Hostname: host, URL: strings.TrimSuffix(r.URL, "/")})
+		}
+	}
+	return hosts, statics, externals
+}
+
+// DefaultLabels returns the middleware as roji.* labels: "" for every
+// container, and each project with its own middleware merged in
+func (f *File) DefaultLabels() map[string]map[string]string {
+	defaults := make(map[string]map[string]string)
+	if len(f.Middleware) > 0 {
+		defaults[""] = prefixLabels(f.Middleware)
+	}
+	for name, project := range f.Projects {
+		if len(project.Middleware) == 0 {
+			continue
+		}
+		labels := prefixLabels(f.Middleware)
+		for key, value := range prefixLabels(project.Middleware) {
+			labels[key] = value
+		}
+		defaults[name] = labels
+	}
+	return defaults
+}
+
+func prefixLabels(middleware map[string]string) map[string]string {
+	labels := make(map[string]string, len(middleware))
+	for key, value := range middleware {
+		labels[LabelPrefix+strings.TrimPrefix(key, LabelPrefix)] = value
+	}
+	return labels
+}
+
+// ProjectDomains returns the domains of projects that set one
+func (f *File) ProjectDomains() map[string]string {
+	domains := make(map[string]string)
+	for name, project := range f.Projects {
+		if project.Domain != "" {
+			domains[name] = strings.ToLower(project.Domain)
+		}
+	}
+	return domains
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testFile = `
+domain: dev.localhost
+https-port: 8443
+lan: true
+lan-trust: [10.0.0.0/8, 192.168.1.5]
+host-routes: {vite: 5173, api: 3000}
+
+routes:
+  - {host: docs, dir: /srv/docs/, spa: true}
+  - {host: vite.example.localhost, port: 5173}
+  - {host: staging, url: "https://api.staging.example.com/"}
+
+middleware:
+  cookie-secure: "true"
+  banner: "true"
+
+projects:
+  shop:
+    domain: Shop.localhost
+    middleware: {banner: "Shop preview"}
+  blog:
+    domain: blog.localhost
+
+tls:
+  min-version: "1.3"
+  names: [laptop.lan]
+`
+
+func TestParseFile(t *testing.T) {
+	f, err := ParseFile([]byte(testFile))
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+
+	wantFlags := map[string]string{
+		"domain":      "dev.localhost",
+		"https-port":  "8443",
+		"lan":         "true",
+		"lan-trust":   "10.0.0.0/8,192.168.1.5",
+		"host-routes": "api=3000,vite=5173",
+	}
+	if flags := f.FlagValues(); !reflect.DeepEqual(flags, wantFlags) {
+		t.Errorf("FlagValues() = %v, want %v", flags, wantFlags)
+	}
+
+	hosts, statics, externals := f.SplitRoutes("dev.localhost")
+	if want := []HostRoute{{Hostname: "vite.example.localhost", Port: 5173}}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("host routes = %+v, want %+v", hosts, want)
+	}
+	if want := []StaticRoute{{Hostname: "docs.dev.localhost", Dir: "/srv/docs", SPA: true}}; !reflect.DeepEqual(statics, want) {
+		t.Errorf("static routes = %+v, want %+v", statics, want)
+	}
+	if want := []ExternalRoute{{Hostname: "staging.dev.localhost", URL: "https://api.staging.example.com"}}; !reflect.DeepEqual(externals, want) {
+		t.Errorf("external routes = %+v, want %+v", externals, want)
+	}
+
+	wantLabels := map[string]map[string]string{
+		"":     {"roji.cookie-secure": "true", "roji.banner": "true"},
+		"shop": {"roji.cookie-secure": "true", "roji.banner": "Shop preview"},
+	}
+	if labels := f.DefaultLabels(); !reflect.DeepEqual(labels, wantLabels) {
+		t.Errorf("DefaultLabels() = %v, want %v", labels, wantLabels)
+	}
+	wantDomains := map[string]string{"shop": "shop.localhost", "blog": "blog.localhost"}
+	if domains := f.ProjectDomains(); !reflect.DeepEqual(domains, wantDomains) {
+		t.Errorf("ProjectDomains() = %v, want %v", domains, wantDomains)
+	}
+	if f.TLS.MinVersion != "1.3" || !reflect.DeepEqual(f.TLS.Names, []string{"laptop.lan"}) {
+		t.Errorf("TLS = %+v, want min-version 1.3 and laptop.lan", f.TLS)
+	}
+}
+
+func TestParseFile_Empty(t *testing.T) {
+	f, err := ParseFile(nil)
+	if err != nil {
+		t.Fatalf("ParseFile(nil) error = %v", err)
+	}
+	if len(f.FlagValues()) != 0 || len(f.DefaultLabels()) != 0 || len(f.ProjectDomains()) != 0 {
+		t.Errorf("ParseFile(nil) = %+v, want nothing set", f)
+	}
+}
+
+func TestParseFile_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"no target":       "routes:\n  - {host: docs}\n",
+		"two targets":     "routes:\n  - {host: docs, port: 3000, dir: /srv}\n",
+		"no host":         "routes:\n  - {port: 3000}\n",
+		"bad port":        "routes:\n  - {host: api, port: 70000}\n",
+		"bad url":         "routes:\n  - {host: api, url: ftp://example.com}\n",
+		"spa without dir": "routes:\n  - {host: api, port: 3000, spa: true}\n",
+		"unknown field":   "routes:\n  - {host: api, port: 3000, tls: true}\n",
+		"bad domain":      "projects:\n  shop: {domain: \"shop.localhost/x\"}\n",
+		"tls version":     "tls:\n  min-version: \"1.1\"\n",
+		"not yaml":        "domain: [\n",
+		"nested profile":  "profiles:\n  work: {profile: home}\n",
+		"missing profile": "profile: work\n",
+	}
+	for name, data := range tests {
+		if _, err := ParseFile([]byte(data)); err == nil {
+			t.Errorf("%s: ParseFile() error = nil, want error", name)
+		}
+	}
+}
+
+func TestFile_WithProfile(t *testing.T) {
+	f, err := ParseFile([]byte(`
+domain: dev.localhost
+network: roji
+profiles:
+  work:
+    domain: dev.corp.test
+    certs-dir: /certs/work
+    docker-host: ssh://dev@build-box
+  personal:
+    domain: localhost
+`))
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	if got := f.ProfileNames(); len(got) != 2 || got[0] != "personal" || got[1] != "work" {
+		t.Errorf("ProfileNames() = %v, want [personal work]", got)
+	}
+
+	work, err := f.WithProfile("work")
+	if err != nil {
+		t.Fatalf("WithProfile(work) error = %v", err)
+	}
+	values := work.FlagValues()
+	for name, want := range map[string]string{
+		"domain":    "dev.corp.test",
+		"certs-dir": "/certs/work",
+		"network":   "roji",
+		"profile":   "work",
+	} {
+		if values[name] != want {
+			t.Errorf("WithProfile(work) %s = %q, want %q", name, values[name], want)
+		}
+	}
+	if work.DockerHost != "ssh://dev@build-box" {
+		t.Errorf("WithProfile(work) DockerHost = %q", work.DockerHost)
+	}
+	if work.DefaultProfile() != "work" {
+		t.Errorf("WithProfile(work) DefaultProfile() = %q", work.DefaultProfile())
+	}
+	// The file itself is left alone
+	if f.FlagValues()["domain"] != "dev.localhost" {
+		t.Errorf("WithProfile() changed the file: domain = %q", f.FlagValues()["domain"])
+	}
+
+	if _, err := f.WithProfile("home"); err == nil {
+		t.Error("WithProfile(home) error = nil, want unknown profile")
+	}
+}
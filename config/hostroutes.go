@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HostRoute sends a hostname to a process listening on the Docker host
+// (Vite, Rails, a Go binary) rather than to a container
+type HostRoute struct {
+	Hostname string // Full hostname, or a name expanded with the base domain
+	Port     int    // Port the process listens on
+}
+
+// ParseHostRoutes parses comma-separated "hostname=port" entries
+// (e.g., "vite=5173,api.dev.localhost=3000"). Hostnames without a dot get the
+// base domain appended.
+func ParseHostRoutes(value, baseDomain string) ([]HostRoute, error) {
+	var routes []HostRoute
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, portStr, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid host route %q: want hostname=port", entry)
+		}
+		port, err := strconv.Atoi(strings.TrimSpace(portStr))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid host route %q: bad port %q", entry, portStr)
+		}
+		if !strings.Contains(host, ".") {
+			host = DefaultHostname(host, baseDomain)
+		}
+		routes = append(routes, HostRoute{Hostname: host, Port: port})
+	}
+	return routes, nil
+}
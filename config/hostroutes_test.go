@@ -0,0 +1,28 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHostRoutes(t *testing.T) {
+	routes, err := ParseHostRoutes(" vite=5173, API.example.localhost = 3000 ,", "dev.localhost")
+	if err != nil {
+		t.Fatalf("ParseHostRoutes() error = %v", err)
+	}
+	want := []HostRoute{
+		{Hostname: "vite.dev.localhost", Port: 5173},
+		{Hostname: "api.example.localhost", Port: 3000},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("ParseHostRoutes() = %+v, want %+v", routes, want)
+	}
+}
+
+func TestParseHostRoutes_Invalid(t *testing.T) {
+	for _, value := range []string{"vite", "=5173", "vite=http", "vite=70000"} {
+		if _, err := ParseHostRoutes(value, "dev.localhost"); err == nil {
+			t.Errorf("ParseHostRoutes(%q) error = nil, want error", value)
+		}
+	}
+}
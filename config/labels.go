@@ -2,8 +2,10 @@ package config
 
 import (
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -11,16 +13,249 @@ const (
 	LabelPrefix = "roji."
 
 	// Supported labels
+	LabelEnable = LabelPrefix + "enable" // Connect the container to the roji network (with --auto-connect)
+
 	LabelHost = LabelPrefix + "host" // Custom hostname (default: {service}.{domain})
 	LabelPort = LabelPrefix + "port" // Target port when multiple ports exposed
 	LabelPath = LabelPrefix + "path" // Path prefix for routing (optional)
+
+	LabelCanonicalHost = LabelPrefix + "canonical-host" // Hostname to serve on; the default hostname redirects to it (optional)
+
+	LabelCoalesce = LabelPrefix + "coalesce"  // Collapse identical concurrent GET requests (optional)
+	LabelAllPorts = LabelPrefix + "all-ports" // Route every exposed port on its own hostname (optional)
+
+	LabelLegacy    = LabelPrefix + "legacy"     // HTTP/1.0 compatibility for old clients (optional)
+	LabelPlainHTTP = LabelPrefix + "plain-http" // Serve over plain HTTP instead of redirecting to HTTPS (optional)
+
+	LabelBlockServiceWorkers = LabelPrefix + "block-service-workers" // Refuse service worker registrations (optional)
+	LabelSnapshot            = LabelPrefix + "snapshot"              // Comma-separated paths compared across restarts (optional)
+
+	LabelEmbed   = LabelPrefix + "embed"   // Relax or strip CSP/X-Frame-Options for embedding (optional)
+	LabelCompose = LabelPrefix + "compose" // Serve other routes under path prefixes: "/checkout=checkout.localhost,..." (optional)
+	LabelESI     = LabelPrefix + "esi"     // Resolve <esi:include> tags in HTML responses (optional)
+	LabelBanner  = LabelPrefix + "banner"  // Inject a "dev preview" banner into HTML pages: "true" or custom text (optional)
+
+	LabelOverrides  = LabelPrefix + "overrides"   // Serve local files for paths: "/assets/main.js=/overrides/main.js,..." (optional)
+	LabelLiveReload = LabelPrefix + "live-reload" // Reload HTML pages when the container restarts (optional)
+
+	LabelRewriteRedirects = LabelPrefix + "rewrite-redirects" // "false" keeps Location headers naming the container (default: rewrite)
+
+	LabelCookieDomain   = LabelPrefix + "cookie-domain"   // Rewrite Set-Cookie Domain: "strip" or a domain (optional)
+	LabelCookieSecure   = LabelPrefix + "cookie-secure"   // Add Secure to Set-Cookie (optional)
+	LabelCookieSameSite = LabelPrefix + "cookie-samesite" // Force SameSite on Set-Cookie: lax, strict or none (optional)
+
+	LabelRecord = LabelPrefix + "record" // Capture recent requests and responses for the dashboard inspector: true (headers) or bodies (optional)
+
+	LabelHealthPath = LabelPrefix + "health-path" // HTTP path probed for the dashboard's health dot (default: TCP connect)
+
+	LabelMaxConcurrent     = LabelPrefix + "max-concurrent"      // Requests a container serves at once; the rest wait (optional)
+	LabelMaxConcurrentWait = LabelPrefix + "max-concurrent-wait" // How long requests wait for roji.max-concurrent, e.g. "5s"; "0" rejects at once (default: 10s)
+
+	LabelKeepAlive    = LabelPrefix + "keep-alive"     // "false" opens a connection per request to the container (default: reuse)
+	LabelMaxIdleConns = LabelPrefix + "max-idle-conns" // Idle connections kept to the container (default: --backend-max-idle-conns)
+	LabelConnLifetime = LabelPrefix + "conn-lifetime"  // Connections to the container aren't reused once this old, e.g. "30s" (optional)
+
+	LabelAccessLog       = LabelPrefix + "access-log"        // "false" turns off request logging for the route (default: on)
+	LabelAccessLogFields = LabelPrefix + "access-log-fields" // Comma-separated fields of request log lines (optional)
+	LabelAccessLogSample = LabelPrefix + "access-log-sample" // Fraction ("0.1") or percentage ("10%") of successful requests logged (optional)
+
+	LabelTunnel       = LabelPrefix + "tunnel"        // Route is shared through a public tunnel: noindex everything (optional)
+	LabelTunnelAuth   = LabelPrefix + "tunnel-auth"   // "user:password" basic auth for tunneled requests (optional)
+	LabelTunnelSecret = LabelPrefix + "tunnel-secret" // Shared secret tunneled requests must send in X-Roji-Secret (optional)
 )
 
+// Embed policies for roji.embed
+const (
+	EmbedRelax = "relax" // Allow framing by other routes under the base domain
+	EmbedStrip = "strip" // Drop CSP and X-Frame-Options entirely
+)
+
+// DefaultMaxConcurrentWait is how long requests wait for a slot of
+// roji.max-concurrent without roji.max-concurrent-wait
+const DefaultMaxConcurrentWait = 10 * time.Second
+
+// OCI image annotations, inherited by containers from their image
+const (
+	LabelImageRevision    = "org.opencontainers.image.revision"    // Source revision (e.g., git commit)
+	LabelImageDescription = "org.opencontainers.image.description" // Human-readable description
+	LabelImageSource      = "org.opencontainers.image.source"      // Source repository URL
+	LabelImageURL         = "org.opencontainers.image.url"         // Project URL, used when source is missing
+)
+
+// ImageInfo holds the OCI metadata describing what a container is running
+type ImageInfo struct {
+	Revision    string
+	Description string
+	SourceURL   string
+}
+
+// ParseImageLabels extracts OCI image metadata from container labels
+func ParseImageLabels(labels map[string]string) ImageInfo {
+	info := ImageInfo{
+		Revision:    strings.TrimSpace(labels[LabelImageRevision]),
+		Description: strings.TrimSpace(labels[LabelImageDescription]),
+		SourceURL:   strings.TrimSpace(labels[LabelImageSource]),
+	}
+	if info.SourceURL == "" {
+		info.SourceURL = strings.TrimSpace(labels[LabelImageURL])
+	}
+	return info
+}
+
+// RedactedValue stands in for the values of labels carrying credentials
+const RedactedValue = "(set)"
+
+// secretLabels carry credentials, which roji never shows
+var secretLabels = map[string]bool{
+	LabelTunnelAuth:   true,
+	LabelTunnelSecret: true,
+}
+
+// RedactLabel returns a label's value for display: RedactedValue for
+// labels carrying credentials
+func RedactLabel(key, value string) string {
+	if secretLabels[key] && value != "" {
+		return RedactedValue
+	}
+	return value
+}
+
+// RojiLabels returns the roji.* labels of a container, e.g. to explain
+// how it is routed. Credentials are redacted (see RedactLabel).
+func RojiLabels(labels map[string]string) map[string]string {
+	var result map[string]string
+	for key, value := range labels {
+		if !strings.HasPrefix(key, LabelPrefix) {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[key] = RedactLabel(key, value)
+	}
+	return result
+}
+
+// Mount hands requests under Path to the route serving Host (roji.compose)
+type Mount struct {
+	Path string // e.g., "/checkout"
+	Host string // e.g., "checkout.localhost"
+}
+
+// CookieDomainStrip drops the Domain attribute, scoping cookies to the
+// hostname that set them
+const CookieDomainStrip = "strip"
+
+// CookiePolicy rewrites the Set-Cookie headers of a route, so apps set up
+// for production domains keep their sessions on local hostnames
+type CookiePolicy struct {
+	Domain   string // "strip", or the domain that replaces Domain attributes
+	Secure   bool   // Add the Secure attribute
+	SameSite string // "Lax", "Strict" or "None" replaces the SameSite attribute
+}
+
+// IsZero reports whether the policy leaves cookies untouched
+func (p CookiePolicy) IsZero() bool {
+	return p == CookiePolicy{}
+}
+
+// Override serves a local file instead of proxying requests for Path
+// (roji.overrides). A Path ending in "*" maps the rest of the request path
+// under File, which is then a directory.
+type Override struct {
+	Path string // e.g., "/assets/main.js" or "/assets/*"
+	File string // e.g., "/overrides/main.js", as seen by roji
+}
+
 // RouteConfig holds the configuration for a single route
 type RouteConfig struct {
 	Host       string // e.g., "myapp.localhost"
 	Port       int    // Target port
 	PathPrefix string // e.g., "/api" (optional)
+	Coalesce   bool   // Collapse identical in-flight GET requests into one upstream call
+	AllPorts   bool   // Route every exposed port (e.g., web-9229.app.localhost)
+	Legacy     bool   // Accept Host-less HTTP/1.0 requests and close connections after each response
+	PlainHTTP  bool   // Expose the route on the HTTP port without redirecting
+	Enable     bool   // Opted in to automatic network attachment
+
+	// BlockServiceWorkers refuses service worker script fetches and strips
+	// Service-Worker-Allowed, so no worker outlives the dev session
+	BlockServiceWorkers bool
+
+	// CanonicalHost is the hostname the route is served on; the hostname it
+	// would otherwise get (roji.host or auto-generated) 301-redirects to it
+	CanonicalHost string
+
+	// SnapshotPaths are endpoints whose responses are compared before and
+	// after each container restart (e.g., "/,/api/health")
+	SnapshotPaths []string
+
+	// Embed relaxes ("relax") or strips ("strip") CSP and X-Frame-Options
+	// so the route can be framed by other local apps (micro-frontends)
+	Embed string
+
+	// Mounts stitch other routes into this hostname by path (micro-frontend
+	// composition), longest path first
+	Mounts []Mount
+
+	// ESI resolves <esi:include src="..."/> tags in HTML responses by
+	// fetching the fragments from other local routes
+	ESI bool
+
+	// Banner is injected into HTML pages for shared demos: "true" for the
+	// default "Dev preview" text, or custom text where {revision} is replaced
+	// by the image's short revision. "false" turns off the --banner default.
+	Banner string
+
+	// Overrides serve local files for some paths and proxy everything else,
+	// like browser devtools overrides (debugging production bundles)
+	Overrides []Override
+
+	// LiveReload injects a script into HTML pages that reloads them once a
+	// restarted container accepts connections again
+	LiveReload bool
+
+	// KeepRedirects leaves Location and Content-Location headers that name
+	// the container's internal address as the backend sent them
+	// (roji.rewrite-redirects=false)
+	KeepRedirects bool
+
+	// Cookies rewrites Set-Cookie Domain, Secure and SameSite attributes
+	Cookies CookiePolicy
+
+	// Record captures recent requests and responses (headers, without
+	// credentials) for /_api/requests and the dashboard inspector;
+	// RecordBodies keeps the start of each body too (roji.record=bodies)
+	Record       bool
+	RecordBodies bool
+
+	// HealthPath is requested (GET) to check the backend's health; without
+	// it, the check only connects to the port
+	HealthPath string
+
+	// MaxConcurrent caps the requests proxied to the container at once, for
+	// single-threaded dev servers; excess requests wait up to
+	// MaxConcurrentWait for a slot, then get 503 (0 = no limit)
+	MaxConcurrent     int
+	MaxConcurrentWait time.Duration
+
+	// NoKeepAlive, MaxIdleConns and ConnLifetime tune the connections roji
+	// keeps to the container, for dev servers that break on reused
+	// connections, e.g. after a hot reload (0 = --backend-* default)
+	NoKeepAlive  bool
+	MaxIdleConns int
+	ConnLifetime time.Duration
+
+	// AccessLog turns off, samples or trims the route's request log lines,
+	// e.g. for noisy health checks or high-volume asset routes
+	AccessLog AccessLogPolicy
+
+	// Tunnel marks the route as shared publicly; tunneled requests are also
+	// detected per request. Public responses are never indexed, and
+	// TunnelAuth ("user:password") or TunnelSecret gate access when set.
+	Tunnel       bool
+	TunnelAuth   string
+	TunnelSecret string
 }
 
 // ParseLabels extracts roji configuration from container labels
@@ -48,11 +283,190 @@ func ParseLabels(labels map[string]string) *RouteConfig {
 		}
 	}
 
+	if host, ok := labels[LabelCanonicalHost]; ok {
+		cfg.CanonicalHost = strings.TrimSpace(host)
+	}
+
+	cfg.Enable = parseBool(labels, LabelEnable)
+	cfg.Coalesce = parseBool(labels, LabelCoalesce)
+	cfg.AllPorts = parseBool(labels, LabelAllPorts)
+	cfg.Legacy = parseBool(labels, LabelLegacy)
+	cfg.PlainHTTP = parseBool(labels, LabelPlainHTTP)
+	cfg.BlockServiceWorkers = parseBool(labels, LabelBlockServiceWorkers)
+	cfg.ESI = parseBool(labels, LabelESI)
+	cfg.LiveReload = parseBool(labels, LabelLiveReload)
+	if strings.EqualFold(strings.TrimSpace(labels[LabelRecord]), "bodies") {
+		cfg.Record, cfg.RecordBodies = true, true
+	} else {
+		cfg.Record = parseBool(labels, LabelRecord)
+	}
+	cfg.KeepRedirects = isFalse(labels, LabelRewriteRedirects)
+	cfg.Cookies = parseCookiePolicy(labels)
+	cfg.AccessLog = parseAccessLogPolicy(labels)
+	cfg.Tunnel = parseBool(labels, LabelTunnel)
+	cfg.TunnelAuth = strings.TrimSpace(labels[LabelTunnelAuth])
+	cfg.TunnelSecret = strings.TrimSpace(labels[LabelTunnelSecret])
+
+	if embed, ok := labels[LabelEmbed]; ok {
+		switch embed = strings.ToLower(strings.TrimSpace(embed)); embed {
+		case EmbedRelax, EmbedStrip:
+			cfg.Embed = embed
+		}
+	}
+
+	if banner, ok := labels[LabelBanner]; ok {
+		cfg.Banner = parseBanner(banner)
+	}
+
+	if mounts, ok := labels[LabelCompose]; ok {
+		cfg.Mounts = parseMounts(mounts)
+	}
+
+	if overrides, ok := labels[LabelOverrides]; ok {
+		cfg.Overrides = parseOverrides(overrides)
+	}
+
+	cfg.MaxConcurrent, cfg.MaxConcurrentWait = parseConcurrencyLimit(labels)
+	cfg.NoKeepAlive = isFalse(labels, LabelKeepAlive)
+	if n, err := strconv.Atoi(strings.TrimSpace(labels[LabelMaxIdleConns])); err == nil && n > 0 {
+		cfg.MaxIdleConns = n
+	}
+	if d, err := time.ParseDuration(strings.TrimSpace(labels[LabelConnLifetime])); err == nil && d > 0 {
+		cfg.ConnLifetime = d
+	}
+
+	if path := strings.TrimSpace(labels[LabelHealthPath]); path != "" {
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		cfg.HealthPath = path
+	}
+
+	if paths, ok := labels[LabelSnapshot]; ok {
+		for _, path := range strings.Split(paths, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+			cfg.SnapshotPaths = append(cfg.SnapshotPaths, path)
+		}
+	}
+
 	return cfg
 }
 
+// parseConcurrencyLimit reads roji.max-concurrent and its wait, skipping
+// invalid values
+func parseConcurrencyLimit(labels map[string]string) (int, time.Duration) {
+	limit, err := strconv.Atoi(strings.TrimSpace(labels[LabelMaxConcurrent]))
+	if err != nil || limit <= 0 {
+		return 0, 0
+	}
+	wait := DefaultMaxConcurrentWait
+	if value := strings.TrimSpace(labels[LabelMaxConcurrentWait]); value != "" {
+		if value == "0" {
+			wait = 0
+		} else if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+			wait = d
+		}
+	}
+	return limit, wait
+}
+
+// parseBanner normalizes roji.banner: boolean values enable ("true") or
+// disable ("false") the banner, anything else is custom text
+func parseBanner(value string) string {
+	value = strings.TrimSpace(value)
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		return strconv.FormatBool(enabled)
+	}
+	return value
+}
+
+// parseMounts parses comma-separated "path=hostname" pairs, skipping
+// malformed entries, and orders them longest path first
+func parseMounts(value string) []Mount {
+	var mounts []Mount
+	for _, entry := range strings.Split(value, ",") {
+		path, host, ok := strings.Cut(entry, "=")
+		path = strings.TrimSpace(path)
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" || strings.Contains(path, "..") {
+			continue
+		}
+		mounts = append(mounts, Mount{Path: filepath.Clean("/" + path), Host: host})
+	}
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return len(mounts[i].Path) > len(mounts[j].Path)
+	})
+	return mounts
+}
+
+// parseBool reads a boolean label, treating missing or invalid values as false
+func parseBool(labels map[string]string, key string) bool {
+	value, ok := labels[key]
+	if !ok {
+		return false
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	return err == nil && b
+}
+
+// isFalse reports whether a label explicitly disables a default-on feature
+func isFalse(labels map[string]string, key string) bool {
+	value, ok := labels[key]
+	if !ok {
+		return false
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	return err == nil && !b
+}
+
 // DefaultHostname generates a default hostname from service name and base domain
 // e.g., ("myapp", "kan.localhost") -> "myapp.kan.localhost"
 func DefaultHostname(serviceName, baseDomain string) string {
 	return serviceName + "." + baseDomain
 }
+
+// parseCookiePolicy reads roji.cookie-*, skipping invalid values
+func parseCookiePolicy(labels map[string]string) CookiePolicy {
+	policy := CookiePolicy{Secure: parseBool(labels, LabelCookieSecure)}
+
+	domain := strings.ToLower(strings.TrimSpace(labels[LabelCookieDomain]))
+	if domain == CookieDomainStrip || (domain != "" && !strings.ContainsAny(domain, " ;,=")) {
+		policy.Domain = domain
+	}
+
+	switch strings.ToLower(strings.TrimSpace(labels[LabelCookieSameSite])) {
+	case "lax":
+		policy.SameSite = "Lax"
+	case "strict":
+		policy.SameSite = "Strict"
+	case "none":
+		policy.SameSite = "None"
+		policy.Secure = true // browsers reject SameSite=None without Secure
+	}
+	return policy
+}
+
+// parseOverrides parses comma-separated "path=file" pairs, skipping
+// malformed entries. Earlier entries win, so list exact paths first.
+func parseOverrides(value string) []Override {
+	var overrides []Override
+	for _, entry := range strings.Split(value, ",") {
+		path, file, ok := strings.Cut(entry, "=")
+		path = strings.TrimSpace(path)
+		file = strings.TrimSpace(file)
+		if !ok || path == "" || file == "" || strings.Contains(path, "..") {
+			continue
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		overrides = append(overrides, Override{Path: path, File: filepath.Clean(file)})
+	}
+	return overrides
+}
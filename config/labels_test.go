@@ -1,7 +1,9 @@
 package config
 
 import (
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParseLabels(t *testing.T) {
@@ -160,3 +162,333 @@ func TestDefaultHostname(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLabels_Coalesce(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"true", true},
+		{" 1 ", true},
+		{"false", false},
+		{"yes", false}, // invalid values are ignored
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			cfg := ParseLabels(map[string]string{"roji.coalesce": tt.value})
+			if cfg.Coalesce != tt.expected {
+				t.Errorf("Coalesce = %v, want %v", cfg.Coalesce, tt.expected)
+			}
+		})
+	}
+
+	if ParseLabels(map[string]string{}).Coalesce {
+		t.Error("Coalesce should default to false")
+	}
+}
+
+func TestParseLabels_MaxConcurrent(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		wantLimit int
+		wantWait  time.Duration
+	}{
+		{"unset", map[string]string{}, 0, 0},
+		{"default wait", map[string]string{"roji.max-concurrent": "1"}, 1, DefaultMaxConcurrentWait},
+		{"custom wait", map[string]string{"roji.max-concurrent": " 10 ", "roji.max-concurrent-wait": "2s"}, 10, 2 * time.Second},
+		{"shed", map[string]string{"roji.max-concurrent": "4", "roji.max-concurrent-wait": "0"}, 4, 0},
+		{"invalid wait", map[string]string{"roji.max-concurrent": "4", "roji.max-concurrent-wait": "soon"}, 4, DefaultMaxConcurrentWait},
+		{"zero", map[string]string{"roji.max-concurrent": "0"}, 0, 0},
+		{"invalid", map[string]string{"roji.max-concurrent": "many"}, 0, 0},
+		{"wait alone", map[string]string{"roji.max-concurrent-wait": "5s"}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseLabels(tt.labels)
+			if cfg.MaxConcurrent != tt.wantLimit || cfg.MaxConcurrentWait != tt.wantWait {
+				t.Errorf("MaxConcurrent, MaxConcurrentWait = %d, %s, want %d, %s",
+					cfg.MaxConcurrent, cfg.MaxConcurrentWait, tt.wantLimit, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestParseLabels_Connections(t *testing.T) {
+	cfg := ParseLabels(map[string]string{
+		"roji.keep-alive":     "false",
+		"roji.max-idle-conns": "2",
+		"roji.conn-lifetime":  "30s",
+	})
+	if !cfg.NoKeepAlive || cfg.MaxIdleConns != 2 || cfg.ConnLifetime != 30*time.Second {
+		t.Errorf("NoKeepAlive, MaxIdleConns, ConnLifetime = %v, %d, %s, want true, 2, 30s",
+			cfg.NoKeepAlive, cfg.MaxIdleConns, cfg.ConnLifetime)
+	}
+
+	// Defaults, and invalid values ignored
+	cfg = ParseLabels(map[string]string{
+		"roji.keep-alive":     "true",
+		"roji.max-idle-conns": "-1",
+		"roji.conn-lifetime":  "forever",
+	})
+	if cfg.NoKeepAlive || cfg.MaxIdleConns != 0 || cfg.ConnLifetime != 0 {
+		t.Errorf("NoKeepAlive, MaxIdleConns, ConnLifetime = %v, %d, %s, want false, 0, 0s",
+			cfg.NoKeepAlive, cfg.MaxIdleConns, cfg.ConnLifetime)
+	}
+}
+
+func TestParseLabels_AllPorts(t *testing.T) {
+	if !ParseLabels(map[string]string{"roji.all-ports": "true"}).AllPorts {
+		t.Error("AllPorts should be true for roji.all-ports=true")
+	}
+	if ParseLabels(map[string]string{}).AllPorts {
+		t.Error("AllPorts should default to false")
+	}
+}
+
+func TestParseLabels_Legacy(t *testing.T) {
+	cfg := ParseLabels(map[string]string{"roji.legacy": "true", "roji.plain-http": "true"})
+	if !cfg.Legacy || !cfg.PlainHTTP {
+		t.Errorf("Legacy = %v, PlainHTTP = %v, want both true", cfg.Legacy, cfg.PlainHTTP)
+	}
+	cfg = ParseLabels(map[string]string{})
+	if cfg.Legacy || cfg.PlainHTTP {
+		t.Error("Legacy and PlainHTTP should default to false")
+	}
+}
+
+func TestParseLabels_CanonicalHost(t *testing.T) {
+	cfg := ParseLabels(map[string]string{"roji.canonical-host": " app.localhost "})
+	if cfg.CanonicalHost != "app.localhost" {
+		t.Errorf("CanonicalHost = %q, want %q", cfg.CanonicalHost, "app.localhost")
+	}
+}
+
+func TestParseLabels_BlockServiceWorkers(t *testing.T) {
+	if !ParseLabels(map[string]string{"roji.block-service-workers": "true"}).BlockServiceWorkers {
+		t.Error("BlockServiceWorkers should be true for roji.block-service-workers=true")
+	}
+	if ParseLabels(map[string]string{}).BlockServiceWorkers {
+		t.Error("BlockServiceWorkers should default to false")
+	}
+}
+
+func TestParseLabels_Snapshot(t *testing.T) {
+	cfg := ParseLabels(map[string]string{"roji.snapshot": "/, api/health ,,/version"})
+	want := []string{"/", "/api/health", "/version"}
+	if len(cfg.SnapshotPaths) != len(want) {
+		t.Fatalf("SnapshotPaths = %v, want %v", cfg.SnapshotPaths, want)
+	}
+	for i := range want {
+		if cfg.SnapshotPaths[i] != want[i] {
+			t.Errorf("SnapshotPaths[%d] = %q, want %q", i, cfg.SnapshotPaths[i], want[i])
+		}
+	}
+}
+
+func TestParseImageLabels(t *testing.T) {
+	info := ParseImageLabels(map[string]string{
+		"org.opencontainers.image.revision":    "abc123",
+		"org.opencontainers.image.description": "Web frontend",
+		"org.opencontainers.image.url":         "https://example.com",
+		"org.opencontainers.image.source":      "https://github.com/kan/roji",
+	})
+	if info.Revision != "abc123" || info.Description != "Web frontend" {
+		t.Errorf("ParseImageLabels() = %+v", info)
+	}
+	if info.SourceURL != "https://github.com/kan/roji" {
+		t.Errorf("SourceURL = %q, want source label to take precedence", info.SourceURL)
+	}
+
+	info = ParseImageLabels(map[string]string{"org.opencontainers.image.url": "https://example.com"})
+	if info.SourceURL != "https://example.com" {
+		t.Errorf("SourceURL = %q, want fallback to url label", info.SourceURL)
+	}
+}
+
+func TestParseLabels_Enable(t *testing.T) {
+	if !ParseLabels(map[string]string{"roji.enable": "true"}).Enable {
+		t.Error("Enable should be true for roji.enable=true")
+	}
+	if ParseLabels(map[string]string{}).Enable {
+		t.Error("Enable should default to false")
+	}
+}
+
+func TestParseLabels_Embed(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"relax", "relax"},
+		{" Strip ", "strip"},
+		{"yes", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseLabels(map[string]string{"roji.embed": tt.value}).Embed; got != tt.want {
+			t.Errorf("roji.embed=%q: Embed = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseLabels_Compose(t *testing.T) {
+	cfg := ParseLabels(map[string]string{
+		"roji.compose": "/account=Account.localhost, checkout = checkout.localhost,/checkout/cart=cart.localhost,/bad=,/../etc=evil.localhost,nope",
+	})
+
+	want := []Mount{
+		{Path: "/checkout/cart", Host: "cart.localhost"},
+		{Path: "/checkout", Host: "checkout.localhost"},
+		{Path: "/account", Host: "account.localhost"},
+	}
+	if !reflect.DeepEqual(cfg.Mounts, want) {
+		t.Errorf("Mounts = %+v, want %+v", cfg.Mounts, want)
+	}
+}
+
+func TestParseLabels_ESI(t *testing.T) {
+	if !ParseLabels(map[string]string{"roji.esi": "true"}).ESI {
+		t.Error("roji.esi=true: ESI = false, want true")
+	}
+	if ParseLabels(map[string]string{}).ESI {
+		t.Error("ESI should default to false")
+	}
+}
+
+func TestParseLabels_Banner(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"true", "true"},
+		{"1", "true"},
+		{"false", "false"},
+		{"", ""},
+		{" Demo build {revision} ", "Demo build {revision}"},
+	}
+	for _, tt := range tests {
+		if got := ParseLabels(map[string]string{"roji.banner": tt.value}).Banner; got != tt.want {
+			t.Errorf("roji.banner=%q: Banner = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseLabels_LiveReload(t *testing.T) {
+	if !ParseLabels(map[string]string{"roji.live-reload": "true"}).LiveReload {
+		t.Error("roji.live-reload=true: LiveReload = false, want true")
+	}
+	if ParseLabels(map[string]string{}).LiveReload {
+		t.Error("LiveReload should default to false")
+	}
+}
+
+func TestParseLabels_RewriteRedirects(t *testing.T) {
+	tests := map[string]bool{"false": true, "0": true, "true": false, "nope": false}
+	for value, want := range tests {
+		if got := ParseLabels(map[string]string{"roji.rewrite-redirects": value}).KeepRedirects; got != want {
+			t.Errorf("roji.rewrite-redirects=%q: KeepRedirects = %v, want %v", value, got, want)
+		}
+	}
+	if ParseLabels(map[string]string{}).KeepRedirects {
+		t.Error("redirects should be rewritten by default")
+	}
+}
+
+func TestParseLabels_Cookies(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   CookiePolicy
+	}{
+		{"none", map[string]string{}, CookiePolicy{}},
+		{"strip", map[string]string{"roji.cookie-domain": " Strip "}, CookiePolicy{Domain: "strip"}},
+		{"domain", map[string]string{"roji.cookie-domain": ".shop.localhost"}, CookiePolicy{Domain: ".shop.localhost"}},
+		{"invalid domain", map[string]string{"roji.cookie-domain": "a; Secure"}, CookiePolicy{}},
+		{"secure", map[string]string{"roji.cookie-secure": "true"}, CookiePolicy{Secure: true}},
+		{"samesite", map[string]string{"roji.cookie-samesite": "LAX"}, CookiePolicy{SameSite: "Lax"}},
+		{"samesite none", map[string]string{"roji.cookie-samesite": "none"}, CookiePolicy{SameSite: "None", Secure: true}},
+		{"invalid samesite", map[string]string{"roji.cookie-samesite": "sometimes"}, CookiePolicy{}},
+	}
+	for _, tt := range tests {
+		if got := ParseLabels(tt.labels).Cookies; got != tt.want {
+			t.Errorf("%s: Cookies = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLabels_Record(t *testing.T) {
+	if !ParseLabels(map[string]string{"roji.record": "true"}).Record {
+		t.Error("roji.record=true: Record = false, want true")
+	}
+	if ParseLabels(map[string]string{}).Record {
+		t.Error("Record should default to false")
+	}
+	if cfg := ParseLabels(map[string]string{"roji.record": "true"}); cfg.RecordBodies {
+		t.Error("roji.record=true: RecordBodies = true, want headers only")
+	}
+	if cfg := ParseLabels(map[string]string{"roji.record": "bodies"}); !cfg.Record || !cfg.RecordBodies {
+		t.Errorf("roji.record=bodies: Record = %v, RecordBodies = %v, want both", cfg.Record, cfg.RecordBodies)
+	}
+}
+
+func TestParseLabels_HealthPath(t *testing.T) {
+	tests := map[string]string{
+		"/healthz": "/healthz",
+		" up ":     "/up",
+		"":         "",
+	}
+	for value, want := range tests {
+		if got := ParseLabels(map[string]string{"roji.health-path": value}).HealthPath; got != want {
+			t.Errorf("roji.health-path=%q: HealthPath = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestParseLabels_Overrides(t *testing.T) {
+	cfg := ParseLabels(map[string]string{
+		"roji.overrides": "/assets/main.js=/overrides/dist/main.js, assets/css/*=/overrides/css/ ,bad,/../x=/y,/z=",
+	})
+	want := []Override{
+		{Path: "/assets/main.js", File: "/overrides/dist/main.js"},
+		{Path: "/assets/css/*", File: "/overrides/css"},
+	}
+	if !reflect.DeepEqual(cfg.Overrides, want) {
+		t.Errorf("Overrides = %+v, want %+v", cfg.Overrides, want)
+	}
+}
+
+func TestParseLabels_Tunnel(t *testing.T) {
+	cfg := ParseLabels(map[string]string{
+		"roji.tunnel":        "true",
+		"roji.tunnel-auth":   " demo:hunter2 ",
+		"roji.tunnel-secret": "s3cret",
+	})
+	if !cfg.Tunnel || cfg.TunnelAuth != "demo:hunter2" || cfg.TunnelSecret != "s3cret" {
+		t.Errorf("ParseLabels() = %+v, want tunnel settings", cfg)
+	}
+}
+
+func TestRojiLabels(t *testing.T) {
+	labels := map[string]string{
+		"roji.host":                         "api.localhost",
+		"roji.path":                         "/api",
+		"com.docker.compose.service":        "api",
+		"org.opencontainers.image.revision": "abc123",
+		"roji.tunnel-auth":                  "demo:hunter2",
+		"roji.tunnel-secret":                "s3cret",
+	}
+	want := map[string]string{
+		"roji.host":          "api.localhost",
+		"roji.path":          "/api",
+		"roji.tunnel-auth":   RedactedValue,
+		"roji.tunnel-secret": RedactedValue,
+	}
+	if got := RojiLabels(labels); !reflect.DeepEqual(got, want) {
+		t.Errorf("RojiLabels() = %v, want %v", got, want)
+	}
+	if got := RojiLabels(map[string]string{"com.docker.compose.service": "api"}); got != nil {
+		t.Errorf("RojiLabels() without roji labels = %v, want nil", got)
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ParseListenAddress parses the address roji binds (--listen): an IP, or
+// "localhost" for 127.0.0.1. Empty means every interface.
+func ParseListenAddress(value string) (string, error) {
+	value = strings.Trim(strings.TrimSpace(value), "[]")
+	switch value {
+	case "":
+		return "", nil
+	case "localhost":
+		return "127.0.0.1", nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return "", fmt.Errorf("invalid listen address %q (want an IP, e.g. 127.0.0.1 or 0.0.0.0)", value)
+	}
+	return ip.String(), nil
+}
+
+// ParsePorts parses a comma-separated list of ports (--extra-https-ports)
+func ParsePorts(value string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		port, err := strconv.Atoi(part)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		if seen[port] {
+			return nil, fmt.Errorf("port %d is listed twice", port)
+		}
+		seen[port] = true
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// ParsePathPrefixes parses a comma-separated list of path prefixes
+// (--plain-http-paths), e.g. "/.well-known/acme-challenge/,/healthz"
+func ParsePathPrefixes(value string) ([]string, error) {
+	var prefixes []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.HasPrefix(part, "/") {
+			return nil, fmt.Errorf("path %q must start with /", part)
+		}
+		if slices.Contains(prefixes, part) {
+			return nil, fmt.Errorf("path %s is listed twice", part)
+		}
+		prefixes = append(prefixes, part)
+	}
+	return prefixes, nil
+}
+
+// ParseHostPatterns parses a comma-separated list of hostnames roji serves
+// besides its own (--allowed-hosts): exact names, "*.example.test" for
+// subdomains, or "*" for any
+func ParseHostPatterns(value string) ([]string, error) {
+	var patterns []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		name := strings.TrimPrefix(part, "*.")
+		if part != "*" && (name == "" || strings.ContainsAny(name, "*:/ ")) {
+			return nil, fmt.Errorf("invalid host pattern %q (want a hostname, *.domain or *)", part)
+		}
+		patterns = append(patterns, part)
+	}
+	return patterns, nil
+}
+
+// ParsePrefixes parses a comma-separated list of IPs and CIDRs
+// (--trusted-proxies); an IP stands for itself
+func ParsePrefixes(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(part)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid IP or CIDR %q", part)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// MagicDNSServices are the wildcard DNS services --magic-dns can use. Both
+// resolve any name ending in <a-b-c-d>.<service> to the IPv4 address a.b.c.d.
+var MagicDNSServices = []string{"sslip.io", "nip.io"}
+
+// ParseMagicDNS parses the wildcard DNS service of --magic-dns; empty means off
+func ParseMagicDNS(value string) (string, error) {
+	service := strings.ToLower(strings.TrimSpace(value))
+	if service != "" && !slices.Contains(MagicDNSServices, service) {
+		return "", fmt.Errorf("unknown service %q (want %s)", value, strings.Join(MagicDNSServices, " or "))
+	}
+	return service, nil
+}
+
+// MagicDNSDomain returns the base domain that resolves to ip through a
+// wildcard DNS service (e.g., 192-168-1-20.sslip.io). Dashes keep the address
+// one label, so the certificate's *.*.<domain> covers project hostnames.
+func MagicDNSDomain(ip netip.Addr, service string) (string, error) {
+	ip = ip.Unmap()
+	if !ip.Is4() {
+		return "", fmt.Errorf("%s is not an IPv4 address", ip)
+	}
+	return strings.ReplaceAll(ip.String(), ".", "-") + "." + service, nil
+}
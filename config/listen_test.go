@@ -0,0 +1,178 @@
+package config
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestParseListenAddress(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"127.0.0.1", "127.0.0.1", false},
+		{" 0.0.0.0 ", "0.0.0.0", false},
+		{"localhost", "127.0.0.1", false},
+		{"::1", "::1", false},
+		{"[::]", "::", false},
+		{"192.168.1.20", "192.168.1.20", false},
+		{"example.com", "", true},
+		{"127.0.0.1:443", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseListenAddress(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseListenAddress(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseListenAddress(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"8443", []int{8443}, false},
+		{"8443, 9443", []int{8443, 9443}, false},
+		{"8443,", []int{8443}, false},
+		{"0", nil, true},
+		{"70000", nil, true},
+		{"https", nil, true},
+		{"8443,8443", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePorts(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePorts(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParsePathPrefixes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"/healthz", []string{"/healthz"}, false},
+		{"/.well-known/acme-challenge/, /hooks/", []string{"/.well-known/acme-challenge/", "/hooks/"}, false},
+		{"healthz", nil, true},
+		{"/hooks/,/hooks/", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePathPrefixes(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePathPrefixes(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParsePathPrefixes(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseHostPatterns(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"tools.lan", []string{"tools.lan"}, false},
+		{"*.Corp.Internal, tools.lan", []string{"*.corp.internal", "tools.lan"}, false},
+		{"*", []string{"*"}, false},
+		{"*.", nil, true},
+		{"tools.lan:8443", nil, true},
+		{"*corp.internal", nil, true},
+		{"https://tools.lan", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHostPatterns(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHostPatterns(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseHostPatterns(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"10.0.0.0/8", []string{"10.0.0.0/8"}, false},
+		{"172.18.0.5, 10.1.2.3/8, ::1", []string{"172.18.0.5/32", "10.0.0.0/8", "::1/128"}, false},
+		{"proxy.local", nil, true},
+		{"10.0.0.0/33", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePrefixes(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePrefixes(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		var strs []string
+		for _, p := range got {
+			strs = append(strs, p.String())
+		}
+		if !slices.Equal(strs, tt.want) {
+			t.Errorf("ParsePrefixes(%q) = %v, want %v", tt.value, strs, tt.want)
+		}
+	}
+}
+
+func TestParseMagicDNS(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"sslip.io", "sslip.io", false},
+		{" NIP.io ", "nip.io", false},
+		{"xip.io", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMagicDNS(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMagicDNS(%q) = %q, %v; want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMagicDNSDomain(t *testing.T) {
+	tests := []struct {
+		ip      string
+		want    string
+		wantErr bool
+	}{
+		{"192.168.1.20", "192-168-1-20.sslip.io", false},
+		{"::ffff:10.0.0.5", "10-0-0-5.sslip.io", false},
+		{"fd00::1", "", true},
+	}
+	for _, tt := range tests {
+		got, err := MagicDNSDomain(netip.MustParseAddr(tt.ip), "sslip.io")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("MagicDNSDomain(%s) = %q, %v; want %q, error %v", tt.ip, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// StaticRoute serves a local directory under a hostname (built frontends)
+type StaticRoute struct {
+	Hostname string // Full hostname, or a name expanded with the base domain
+	Dir      string // Directory served, as seen by roji
+	SPA      bool   // Serve index.html for unknown paths (client-side routing)
+}
+
+// ParseStaticRoutes parses comma-separated "hostname=dir" entries; a ":spa"
+// suffix enables the single-page app fallback (e.g., "docs=/srv/docs,app=/srv/app:spa").
+// Hostnames without a dot get the base domain appended.
+func ParseStaticRoutes(value, baseDomain string) ([]StaticRoute, error) {
+	var routes []StaticRoute
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, dir, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		dir = strings.TrimSpace(dir)
+		if !ok || host == "" || dir == "" {
+			return nil, fmt.Errorf("invalid static route %q: want hostname=dir", entry)
+		}
+		dir, spa := strings.CutSuffix(dir, ":spa")
+		if !strings.Contains(host, ".") {
+			host = DefaultHostname(host, baseDomain)
+		}
+		routes = append(routes, StaticRoute{Hostname: host, Dir: filepath.Clean(dir), SPA: spa})
+	}
+	return routes, nil
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStaticRoutes(t *testing.T) {
+	routes, err := ParseStaticRoutes("docs=/srv/docs/, App.example.localhost=/srv/app:spa", "dev.localhost")
+	if err != nil {
+		t.Fatalf("ParseStaticRoutes() error = %v", err)
+	}
+	want := []StaticRoute{
+		{Hostname: "docs.dev.localhost", Dir: "/srv/docs"},
+		{Hostname: "app.example.localhost", Dir: "/srv/app", SPA: true},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("ParseStaticRoutes() = %+v, want %+v", routes, want)
+	}
+
+	for _, value := range []string{"docs", "docs=", "=/srv/docs"} {
+		if _, err := ParseStaticRoutes(value, "dev.localhost"); err == nil {
+			t.Errorf("ParseStaticRoutes(%q) error = nil, want error", value)
+		}
+	}
+}
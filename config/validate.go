@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// middlewareLabels are the labels a configuration file may give every
+// container. Routing labels (roji.host, roji.path, ...) only make sense on
+// one container and are left out.
+var middlewareLabels = []string{
+	LabelCoalesce, LabelAllPorts, LabelLegacy, LabelPlainHTTP,
+	LabelBlockServiceWorkers, LabelSnapshot, LabelEmbed, LabelESI, LabelBanner,
+	LabelOverrides, LabelLiveReload, LabelRewriteRedirects,
+	LabelCookieDomain, LabelCookieSecure, LabelCookieSameSite,
+	LabelRecord, LabelHealthPath, LabelMaxConcurrent, LabelMaxConcurrentWait,
+	LabelKeepAlive, LabelMaxIdleConns, LabelConnLifetime,
+	LabelAccessLog, LabelAccessLogFields, LabelAccessLogSample,
+	LabelTunnel, LabelTunnelAuth, LabelTunnelSecret,
+}
+
+// routingLabels are roji labels that can't be middleware
+var routingLabels = []string{LabelEnable, LabelHost, LabelPort, LabelPath, LabelCanonicalHost, LabelCompose}
+
+// Validate reports what a parsed file gets wrong beyond syntax: middleware
+// that isn't a roji label or only makes sense per container, and projects
+// sharing a domain
+func (f *File) Validate() []error {
+	var problems []error
+	check := func(section string, middleware map[string]string) {
+		keys := make([]string, 0, len(middleware))
+		for key := range middleware {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			label := LabelPrefix + strings.TrimPrefix(key, LabelPrefix)
+			switch {
+			case slices.Contains(routingLabels, label):
+				problems = append(problems, fmt.Errorf("%s: %s routes a single container and can't be a default", section, label))
+			case !slices.Contains(middlewareLabels, label):
+				problems = append(problems, fmt.Errorf("%s: unknown label %s", section, label))
+			}
+		}
+	}
+	check("middleware", f.Middleware)
+
+	names := make([]string, 0, len(f.Projects))
+	for name := range f.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	owners := make(map[string]string)
+	for _, name := range names {
+		check("projects."+name+".middleware", f.Projects[name].Middleware)
+		domain := strings.ToLower(f.Projects[name].Domain)
+		if domain == "" {
+			continue
+		}
+		if owner, ok := owners[domain]; ok {
+			problems = append(problems, fmt.Errorf("projects.%s: domain %s is already used by project %s", name, domain, owner))
+			continue
+		}
+		owners[domain] = name
+	}
+	return problems
+}
+
+// DuplicateHostnames returns hostnames claimed by more than one route,
+// lowercased: hostnames differing only in case are the same
+func DuplicateHostnames(hosts []HostRoute, statics []StaticRoute, externals []ExternalRoute) []string {
+	counts := make(map[string]int)
+	for _, r := range hosts {
+		counts[strings.ToLower(r.Hostname)]++
+	}
+	for _, r := range statics {
+		counts[strings.ToLower(r.Hostname)]++
+	}
+	for _, r := range externals {
+		counts[strings.ToLower(r.Hostname)]++
+	}
+	var duplicates []string
+	for hostname, n := range counts {
+		if n > 1 {
+			duplicates = append(duplicates, hostname)
+		}
+	}
+	sort.Strings(duplicates)
+	return duplicates
+}
+
+// ValidateHostname checks a hostname assigned to a route: a name under the
+// base domain made of DNS labels (letters, digits and inner hyphens), with no
+// wildcards or empty labels
+func ValidateHostname(hostname, baseDomain string) error {
+	name, ok := strings.CutSuffix(hostname, "."+baseDomain)
+	if !ok || name == "" {
+		return fmt.Errorf("%q is not under the base domain %s", hostname, baseDomain)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !dnsLabel.MatchString(label) {
+			return fmt.Errorf("%q has an invalid label %q", hostname, label)
+		}
+	}
+	return nil
+}
+
+// dnsLabel is one label of a hostname
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
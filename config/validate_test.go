@@ -0,0 +1,69 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFile_Validate(t *testing.T) {
+	f, err := ParseFile([]byte(`
+middleware:
+  banner: "true"
+  roji.record: "true"
+  host: app.localhost
+  bogus: x
+projects:
+  a: {domain: shop.localhost, middleware: {path: /api}}
+  b: {domain: Shop.localhost}
+`))
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	var got []string
+	for _, problem := range f.Validate() {
+		got = append(got, problem.Error())
+	}
+	want := []string{
+		"middleware: unknown label roji.bogus",
+		"middleware: roji.host routes a single container and can't be a default",
+		"projects.a.middleware: roji.path routes a single container and can't be a default",
+		"projects.b: domain shop.localhost is already used by project a",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %q, want %q", got, want)
+	}
+}
+
+func TestDuplicateHostnames(t *testing.T) {
+	got := DuplicateHostnames(
+		[]HostRoute{{Hostname: "vite.localhost"}, {Hostname: "api.localhost"}},
+		[]StaticRoute{{Hostname: "docs.localhost"}, {Hostname: "vite.localhost"}},
+		[]ExternalRoute{{Hostname: "api.localhost"}, {Hostname: "Docs.localhost"}},
+	)
+	if want := []string{"api.localhost", "docs.localhost", "vite.localhost"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DuplicateHostnames() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateHostname(t *testing.T) {
+	tests := map[string]bool{
+		"web.shop.localhost":                   true,
+		"api-v2.localhost":                     true,
+		"localhost":                            false,
+		"shop.example.com":                     false,
+		"evillocalhost":                        false,
+		"*.shop.localhost":                     false,
+		"web..localhost":                       false,
+		".web.localhost":                       false,
+		"-web.localhost":                       false,
+		"web_1.localhost":                      false,
+		"web.localhost:8443":                   false,
+		strings.Repeat("a", 64) + ".localhost": false,
+	}
+	for hostname, valid := range tests {
+		if err := ValidateHostname(hostname, "localhost"); (err == nil) != valid {
+			t.Errorf("ValidateHostname(%q) error = %v, want valid = %v", hostname, err, valid)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/kan/roji/config"
+)
+
+// WithAutoConnect attaches running containers labeled roji.enable=true to the
+// watched network, so compose files don't need the external network
+func WithAutoConnect(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.autoConnect = enabled
+	}
+}
+
+// EnsureConnected attaches a roji.enable=true container to the watched network
+// if it isn't on it yet, and reports whether it did. It is a no-op unless
+// auto-connect is enabled.
+func (c *Client) EnsureConnected(ctx context.Context, containerID string) (bool, error) {
+	if !c.autoConnect {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	info, err := c.inspect(ctx, containerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if info.Config == nil || !config.ParseLabels(info.Config.Labels).Enable {
+		return false, nil
+	}
+	if _, ok := info.NetworkSettings.Networks[c.networkName]; ok {
+		return false, nil
+	}
+
+	if err := c.docker.NetworkConnect(ctx, c.networkName, containerID, nil); err != nil {
+		return false, fmt.Errorf("failed to connect container to %s: %w", c.networkName, err)
+	}
+	// The cached inspect result predates the new endpoint
+	c.InvalidateContainer(containerID)
+
+	slog.Info("connected container to network",
+		"container", shortID(containerID),
+		"name", info.Name,
+		"network", c.networkName)
+	return true, nil
+}
+
+// ConnectLabeledContainers attaches every running roji.enable=true container
+// that isn't on the watched network yet. Used once at startup.
+func (c *Client) ConnectLabeledContainers(ctx context.Context) error {
+	if !c.autoConnect {
+		return nil
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	containers, err := c.docker.ContainerList(listCtx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", config.LabelEnable)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	for _, ctr := range containers {
+		if _, err := c.EnsureConnected(ctx, ctr.ID); err != nil {
+			slog.Warn("failed to auto-connect container",
+				"container", shortID(ctr.ID),
+				"error", err)
+		}
+	}
+	return nil
+}
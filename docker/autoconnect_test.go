@@ -0,0 +1,67 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestClient_EnsureConnected(t *testing.T) {
+	labeled := createMockContainerJSON("labeled", "api", "", "", 80, "bridge")
+	labeled.Config.Labels["roji.enable"] = "true"
+	attached := createMockContainerJSON("attached", "web", "", "", 80, "roji")
+	attached.Config.Labels["roji.enable"] = "true"
+	unlabeled := createMockContainerJSON("unlabeled", "db", "", "", 5432, "bridge")
+
+	mock := &mockDockerAPI{inspectMap: map[string]types.ContainerJSON{
+		"labeled":   labeled,
+		"attached":  attached,
+		"unlabeled": unlabeled,
+	}}
+	client := NewClientWithAPI(mock, "roji", "localhost", WithAutoConnect(true))
+
+	for _, id := range []string{"labeled", "attached", "unlabeled"} {
+		connected, err := client.EnsureConnected(context.Background(), id)
+		if err != nil {
+			t.Fatalf("EnsureConnected(%s) error = %v", id, err)
+		}
+		if want := id == "labeled"; connected != want {
+			t.Errorf("EnsureConnected(%s) = %v, want %v", id, connected, want)
+		}
+	}
+	if len(mock.connected) != 1 || mock.connected[0] != "labeled" {
+		t.Errorf("NetworkConnect calls = %v, want [labeled]", mock.connected)
+	}
+}
+
+func TestClient_EnsureConnected_Disabled(t *testing.T) {
+	labeled := createMockContainerJSON("labeled", "api", "", "", 80, "bridge")
+	labeled.Config.Labels["roji.enable"] = "true"
+	mock := &mockDockerAPI{inspectMap: map[string]types.ContainerJSON{"labeled": labeled}}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	if connected, _ := client.EnsureConnected(context.Background(), "labeled"); connected {
+		t.Error("EnsureConnected() should be a no-op without WithAutoConnect")
+	}
+	if len(mock.connected) != 0 {
+		t.Errorf("NetworkConnect calls = %v, want none", mock.connected)
+	}
+}
+
+func TestClient_ConnectLabeledContainers(t *testing.T) {
+	labeled := createMockContainerJSON("labeled", "api", "", "", 80, "bridge")
+	labeled.Config.Labels["roji.enable"] = "true"
+	mock := &mockDockerAPI{
+		containers: []types.Container{createMockContainer("labeled", "api", "", "", 80, "bridge")},
+		inspectMap: map[string]types.ContainerJSON{"labeled": labeled},
+	}
+	client := NewClientWithAPI(mock, "roji", "localhost", WithAutoConnect(true))
+
+	if err := client.ConnectLabeledContainers(context.Background()); err != nil {
+		t.Fatalf("ConnectLabeledContainers() error = %v", err)
+	}
+	if len(mock.connected) != 1 {
+		t.Errorf("NetworkConnect calls = %v, want [labeled]", mock.connected)
+	}
+}
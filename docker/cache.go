@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// inspectCache keeps recent ContainerInspect results so bursts of discovery
+// (project rescans, event batches, health checks) don't re-inspect the same
+// containers. Entries expire after ttl and are invalidated by container events.
+type inspectCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]inspectEntry // key: container ID
+}
+
+type inspectEntry struct {
+	info    types.ContainerJSON
+	expires time.Time
+}
+
+func newInspectCache(ttl time.Duration) *inspectCache {
+	return &inspectCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]inspectEntry),
+	}
+}
+
+func (c *inspectCache) get(id string) (types.ContainerJSON, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok || !c.now().Before(entry.expires) {
+		return types.ContainerJSON{}, false
+	}
+	return entry.info, true
+}
+
+func (c *inspectCache) put(id string, info types.ContainerJSON) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	// Drop expired entries so removed containers don't accumulate
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[id] = inspectEntry{info: info, expires: now.Add(c.ttl)}
+}
+
+func (c *inspectCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, id)
+}
+
+// WithInspectCache caches container inspect results for ttl (0 = disabled).
+// Entries are invalidated as soon as the watcher sees an event for the container.
+func WithInspectCache(ttl time.Duration) ClientOption {
+	return func(c *Client) {
+		if ttl > 0 {
+			c.inspects = newInspectCache(ttl)
+		}
+	}
+}
+
+// inspect returns the container's inspect result, from the cache when fresh.
+// The result may be shared and must not be modified. Default labels
+// (WithDefaultLabels) are applied.
+func (c *Client) inspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	if c.inspects == nil {
+		info, err := c.docker.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return info, err
+		}
+		return c.applyDefaultLabels(info), nil
+	}
+	if info, ok := c.inspects.get(containerID); ok {
+		return c.applyDefaultLabels(info), nil
+	}
+
+	info, err := c.docker.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return info, err
+	}
+	c.inspects.put(containerID, info)
+	return c.applyDefaultLabels(info), nil
+}
+
+// InvalidateContainer drops any cached inspect result for the container
+func (c *Client) InvalidateContainer(containerID string) {
+	if c.inspects != nil {
+		c.inspects.invalidate(containerID)
+	}
+}
@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+func TestClient_InspectCache(t *testing.T) {
+	info := createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji")
+	calls := 0
+	mock := &mockDockerAPI{
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			calls++
+			return info, nil
+		},
+	}
+
+	client := NewClientWithAPI(mock, "roji", "localhost", WithInspectCache(time.Minute))
+	now := time.Now()
+	client.inspects.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.inspect(context.Background(), "abc123"); err != nil {
+			t.Fatalf("inspect() error = %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("ContainerInspect called %d times, want 1", calls)
+	}
+
+	// Events invalidate the entry
+	NewWatcher(client).processEvent(events.Message{Action: "stop", Actor: events.Actor{ID: "abc123"}})
+	client.inspect(context.Background(), "abc123")
+	if calls != 2 {
+		t.Errorf("ContainerInspect called %d times after invalidation, want 2", calls)
+	}
+
+	// Entries expire after the TTL
+	now = now.Add(2 * time.Minute)
+	client.inspect(context.Background(), "abc123")
+	if calls != 3 {
+		t.Errorf("ContainerInspect called %d times after expiry, want 3", calls)
+	}
+}
+
+func TestClient_InspectCacheDisabled(t *testing.T) {
+	calls := 0
+	mock := &mockDockerAPI{
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			calls++
+			return types.ContainerJSON{}, nil
+		},
+	}
+
+	client := NewClientWithAPI(mock, "roji", "localhost", WithInspectCache(0))
+	client.inspect(context.Background(), "abc123")
+	client.inspect(context.Background(), "abc123")
+	if calls != 2 {
+		t.Errorf("ContainerInspect called %d times, want 2 without caching", calls)
+	}
+}
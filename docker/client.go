@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -32,6 +35,9 @@ type DockerAPI interface {
 	ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error)
 	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
 	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+	NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+	NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
+	Ping(ctx context.Context) (types.Ping, error)
 	Close() error
 }
 
@@ -41,10 +47,59 @@ type Backend struct {
 	ContainerName string
 	ServiceName   string // docker-compose service name
 	ProjectName   string // docker-compose project name
-	Host          string // Container IP in the shared network
+	Host          string // Container IP (IPv4 or IPv6) or name in the shared network
 	Port          int
 	Hostname      string // The hostname to route to this backend
 	PathPrefix    string // Optional path prefix
+	Coalesce      bool   // Collapse identical in-flight GET requests (roji.coalesce)
+	Legacy        bool   // HTTP/1.0 compatibility mode (roji.legacy)
+	PlainHTTP     bool   // Served over plain HTTP without redirect (roji.plain-http)
+	RedirectTo    string // Canonical hostname this alias redirects to (roji.canonical-host)
+
+	BlockServiceWorkers bool     // Refuse service worker registrations (roji.block-service-workers)
+	SnapshotPaths       []string // Endpoints compared across restarts (roji.snapshot)
+
+	Image config.ImageInfo // OCI revision/description/source of the running image
+	Embed string           // CSP/X-Frame-Options policy for embedding (roji.embed)
+
+	Mounts []config.Mount // Other routes served under path prefixes (roji.compose)
+	ESI    bool           // Resolve <esi:include> tags in HTML responses (roji.esi)
+	Banner string         // "true", "false" or custom text injected into HTML pages (roji.banner)
+
+	Overrides  []config.Override // Local files served instead of proxying (roji.overrides)
+	LiveReload bool              // Reload HTML pages when the container restarts (roji.live-reload)
+
+	KeepRedirects bool                   // Leave Location headers naming the container alone (roji.rewrite-redirects=false)
+	Cookies       config.CookiePolicy    // Set-Cookie rewriting (roji.cookie-*)
+	Record        bool                   // Capture requests for the inspector (roji.record)
+	RecordBodies  bool                   // Capture the start of their bodies too (roji.record=bodies)
+	HealthPath    string                 // Path probed for health instead of a TCP connect (roji.health-path)
+	AccessLog     config.AccessLogPolicy // Request logging (roji.access-log*)
+
+	MaxConcurrent     int           // Requests proxied at once; 0 = no limit (roji.max-concurrent)
+	MaxConcurrentWait time.Duration // How long excess requests wait for a slot (roji.max-concurrent-wait)
+
+	NoKeepAlive  bool          // Open a connection per request (roji.keep-alive=false)
+	MaxIdleConns int           // Idle connections kept; 0 = default (roji.max-idle-conns)
+	ConnLifetime time.Duration // Connections aren't reused once this old; 0 = default (roji.conn-lifetime)
+
+	Paused bool // Container is paused (docker pause); requests would hang
+
+	Labels map[string]string // roji.* labels of the container, for explanations
+
+	StaticDir string // Directory served instead of proxying (--static-routes)
+	StaticSPA bool   // Serve index.html for unknown paths
+
+	ExternalURL string // URL outside Docker proxied instead of Host:Port (--external-routes)
+
+	Tunnel       bool   // Shared through a public tunnel (roji.tunnel)
+	TunnelAuth   string // "user:password" required from tunneled requests (roji.tunnel-auth)
+	TunnelSecret string // X-Roji-Secret required from tunneled requests (roji.tunnel-secret)
+}
+
+// Address returns the host:port to dial, bracketing IPv6 literals (e.g., "[fd00::2]:80")
+func (b *Backend) Address() string {
+	return net.JoinHostPort(b.Host, strconv.Itoa(b.Port))
 }
 
 // Client wraps the Docker client for container discovery
@@ -52,26 +107,140 @@ type Client struct {
 	docker      DockerAPI
 	networkName string // The shared network to watch (e.g., "roji")
 	baseDomain  string // Base domain for auto-generated hostnames (e.g., "kan.localhost")
+	probePorts  bool   // Probe exposed ports to find the one actually listening
+	dial        dialFunc
+	pins        *hostnamePins // Sticky hostname assignments (nil = dynamic)
+	allPorts    bool          // Route every exposed port, not just the primary one
+	dialByName  bool          // Address backends by container DNS name instead of IP
+	generations *hostnameGenerations
+	inspects    *inspectCache // Short-lived inspect results (nil = disabled)
+
+	// Published-port addressing for remote Docker hosts
+	published     bool   // Dial published ports on the Docker host instead of container IPs
+	publishedHost string // Host dialed for published ports
+
+	registry    *hostnameRegistry // Shared team hostname reservations (nil = disabled)
+	autoConnect bool              // Attach roji.enable=true containers to the network
+
+	// Processes on the Docker host, served alongside containers
+	hostRoutes  []config.HostRoute
+	hostGateway string
+	hostMu      sync.Mutex
+	exposed     map[string]config.HostRoute // Temporary routes from roji expose, by hostname
+
+	staticRoutes   []config.StaticRoute   // Local directories served by roji itself
+	externalRoutes []config.ExternalRoute // URLs outside Docker (e.g., staging APIs)
+
+	defaultLabels  map[string]map[string]string // roji.* labels by compose project ("" = all; nil = none)
+	projectDomains map[string]string            // Domains of compose projects that have their own
+}
+
+// Backend addressing modes
+const (
+	AddressIP  = "ip"  // Dial the container IP captured at discovery time
+	AddressDNS = "dns" // Dial the container name, resolved by Docker's embedded DNS
+)
+
+// ClientOption configures optional Client behaviour
+type ClientOption func(*Client)
+
+// WithBackendAddressing selects how backends are addressed. DNS addressing
+// survives IP changes after restarts but requires roji to run on the network;
+// published addressing works with remote Docker hosts.
+func WithBackendAddressing(mode string) ClientOption {
+	return func(c *Client) {
+		c.dialByName = mode == AddressDNS
+		c.published = mode == AddressPublished
+	}
+}
+
+// WithPortProbing enables live probing of exposed ports when a container
+// exposes more than one and no roji.port label is set
+func WithPortProbing(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.probePorts = enabled
+	}
+}
+
+// WithAllPorts routes every exposed port of multi-port containers by default
+// (individual containers can opt in with the roji.all-ports label)
+func WithAllPorts(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.allPorts = enabled
+	}
+}
+
+// WithHostnameStability selects how auto-generated hostnames evolve.
+// In sticky mode a service keeps its first hostname even as its project grows or shrinks.
+func WithHostnameStability(mode string) ClientOption {
+	return func(c *Client) {
+		if mode == HostnameStabilitySticky {
+			c.pins = newHostnamePins()
+		}
+	}
 }
 
 // NewClient creates a new Docker client wrapper
-func NewClient(networkName, baseDomain string) (*Client, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+func NewClient(networkName, baseDomain string, opts ...ClientOption) (*Client, error) {
+	clientOpts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+
+	// DOCKER_HOST wins; otherwise follow the active docker context
+	// (e.g., `docker context use colima`), like the docker CLI
+	dockerHost := os.Getenv("DOCKER_HOST")
+	if dockerHost == "" {
+		dockerCtx, err := currentDockerContext(dockerConfigDir())
+		if err != nil {
+			return nil, err
+		}
+		if dockerCtx != nil {
+			slog.Info("using docker context", "context", dockerCtx.Name, "host", dockerCtx.Host)
+			dockerHost = dockerCtx.Host
+			if !strings.HasPrefix(dockerHost, "ssh://") {
+				ctxOpts, err := dockerCtx.clientOpts()
+				if err != nil {
+					return nil, err
+				}
+				clientOpts = append(clientOpts, ctxOpts...)
+			}
+		}
+	}
+
+	// Remote daemons: ssh:// needs a dialer; tcp:// (+TLS) is covered by FromEnv
+	remoteOpts, err := remoteClientOpts(dockerHost)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts = append(clientOpts, remoteOpts...)
+
+	cli, err := client.NewClientWithOpts(clientOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create docker client: %w", err)
 	}
 
-	return NewClientWithAPI(cli, networkName, baseDomain), nil
+	c := NewClientWithAPI(cli, networkName, baseDomain, opts...)
+	if c.published && c.publishedHost == "" {
+		c.publishedHost = dockerHostname(dockerHost)
+		if c.publishedHost == "" {
+			c.publishedHost = "127.0.0.1" // local daemon
+		}
+	}
+	return c, nil
 }
 
 // NewClientWithAPI creates a new client with a custom DockerAPI implementation
 // This is useful for testing with mock implementations
-func NewClientWithAPI(api DockerAPI, networkName, baseDomain string) *Client {
-	return &Client{
+func NewClientWithAPI(api DockerAPI, networkName, baseDomain string, opts ...ClientOption) *Client {
+	c := &Client{
 		docker:      api,
 		networkName: networkName,
 		baseDomain:  baseDomain,
+		dial:        (&net.Dialer{}).DialContext,
+		generations: newHostnameGenerations(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 // Close closes the Docker client
@@ -89,21 +258,65 @@ func (c *Client) BaseDomain() string {
 	return c.baseDomain
 }
 
-// buildProjectServiceCounts counts services per project from a list of containers
+// announcedStates are container states that count towards a project's shape.
+// "created" matters during `docker compose up`: every container of the project is
+// created before the first one starts, so siblings are visible from the first event.
+var announcedStates = map[string]bool{
+	"created":    true,
+	"running":    true,
+	"restarting": true,
+	"paused":     true,
+}
+
+// routableStates are container states that get routes. Paused containers keep
+// theirs so the proxy can explain why they don't answer.
+var routableStates = map[string]bool{
+	"running": true,
+	"paused":  true,
+}
+
+// buildProjectServiceCounts counts services per project from a list of containers.
+// Replicas of a scaled service count once, so they share the same hostname.
 func buildProjectServiceCounts(containers []types.Container) map[string]int {
+	seen := make(map[string]bool)
 	counts := make(map[string]int)
 	for _, ctr := range containers {
 		// Skip roji itself
 		if ctr.Labels["roji.self"] == "true" {
 			continue
 		}
-		if project := ctr.Labels["com.docker.compose.project"]; project != "" {
-			counts[project]++
+		if !announcedStates[ctr.State] {
+			continue
+		}
+		project := ctr.Labels["com.docker.compose.project"]
+		if project == "" {
+			continue
+		}
+		key := project + "/" + ctr.Labels["com.docker.compose.service"]
+		if seen[key] {
+			continue
 		}
+		seen[key] = true
+		counts[project]++
 	}
 	return counts
 }
 
+// listNetworkContainers lists all containers (including created but not yet
+// started ones) attached to the shared network, optionally filtered by label
+func (c *Client) listNetworkContainers(ctx context.Context, labelFilter string) ([]types.Container, error) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("network", c.networkName)
+	if labelFilter != "" {
+		filterArgs.Add("label", labelFilter)
+	}
+
+	return c.docker.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filterArgs,
+	})
+}
+
 // DiscoverBackends finds all containers connected to the shared network
 func (c *Client) DiscoverBackends(ctx context.Context) ([]*Backend, error) {
 	// Add timeout for Docker API call
@@ -111,12 +324,7 @@ func (c *Client) DiscoverBackends(ctx context.Context) ([]*Backend, error) {
 	defer cancel()
 
 	// Filter containers by network
-	filterArgs := filters.NewArgs()
-	filterArgs.Add("network", c.networkName)
-
-	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
-		Filters: filterArgs,
-	})
+	containers, err := c.listNetworkContainers(ctx, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
@@ -127,32 +335,51 @@ func (c *Client) DiscoverBackends(ctx context.Context) ([]*Backend, error) {
 	// Create backends with correct hostnames
 	var backends []*Backend
 	for _, ctr := range containers {
-		backend, err := c.containerToBackend(ctx, ctr, projectServiceCount)
+		if !routableStates[ctr.State] {
+			continue
+		}
+		ctrBackends, err := c.containerToBackends(ctx, ctr, projectServiceCount)
 		if err != nil {
 			slog.Warn("failed to process container",
 				"container", shortID(ctr.ID),
 				"error", err)
 			continue
 		}
-		if backend != nil {
-			backends = append(backends, backend)
-		}
+		backends = append(backends, ctrBackends...)
 	}
+	backends = append(backends, c.hostBackends()...)
+	backends = append(backends, c.staticBackends()...)
+	backends = append(backends, c.externalBackends()...)
 
 	return backends, nil
 }
 
-// GetBackend gets a single backend by container ID
+// GetBackend gets the primary backend of a container by container ID
 func (c *Client) GetBackend(ctx context.Context, containerID string) (*Backend, error) {
+	backends, err := c.GetBackends(ctx, containerID)
+	if err != nil || len(backends) == 0 {
+		return nil, err
+	}
+	return backends[0], nil
+}
+
+// GetBackends gets all backends of a container by container ID: the primary
+// backend first, followed by per-port backends when all ports are routed
+func (c *Client) GetBackends(ctx context.Context, containerID string) ([]*Backend, error) {
 	// Add timeout for Docker API call
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	ctr, err := c.docker.ContainerInspect(ctx, containerID)
+	ctr, err := c.inspect(ctx, containerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
+	// A container that exited again (e.g., crash loop) keeps a stale address
+	if ctr.State != nil && !ctr.State.Running {
+		return nil, nil
+	}
+
 	// Check if container is on our network
 	net, ok := ctr.NetworkSettings.Networks[c.networkName]
 	if !ok {
@@ -170,7 +397,11 @@ func (c *Client) GetBackend(ctx context.Context, containerID string) (*Backend,
 		projectServiceCount[project] = count
 	}
 
-	return c.inspectToBackend(ctr, net, projectServiceCount)
+	backend, err := c.inspectToBackend(ctr, net, projectServiceCount)
+	if err != nil || backend == nil {
+		return nil, err
+	}
+	return c.routeBackends(ctr, backend), nil
 }
 
 // countProjectServices counts how many services from the same project are on the network
@@ -179,28 +410,15 @@ func (c *Client) countProjectServices(ctx context.Context, projectName string) (
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	filterArgs := filters.NewArgs()
-	filterArgs.Add("network", c.networkName)
-	filterArgs.Add("label", "com.docker.compose.project="+projectName)
-
-	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
-		Filters: filterArgs,
-	})
+	containers, err := c.listNetworkContainers(ctx, "com.docker.compose.project="+projectName)
 	if err != nil {
 		return 0, err
 	}
 
-	// Exclude roji itself
-	count := 0
-	for _, ctr := range containers {
-		if ctr.Labels["roji.self"] != "true" {
-			count++
-		}
-	}
-	return count, nil
+	return buildProjectServiceCounts(containers)[projectName], nil
 }
 
-func (c *Client) containerToBackend(ctx context.Context, ctr types.Container, projectServiceCount map[string]int) (*Backend, error) {
+func (c *Client) containerToBackends(ctx context.Context, ctr types.Container, projectServiceCount map[string]int) ([]*Backend, error) {
 	// Get the container's IP in our network
 	net, ok := ctr.NetworkSettings.Networks[c.networkName]
 	if !ok {
@@ -208,12 +426,16 @@ func (c *Client) containerToBackend(ctx context.Context, ctr types.Container, pr
 	}
 
 	// Get full container info for labels
-	info, err := c.docker.ContainerInspect(ctx, ctr.ID)
+	info, err := c.inspect(ctx, ctr.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
-	return c.inspectToBackend(info, net, projectServiceCount)
+	backend, err := c.inspectToBackend(info, net, projectServiceCount)
+	if err != nil || backend == nil {
+		return nil, err
+	}
+	return c.routeBackends(info, backend), nil
 }
 
 func (c *Client) inspectToBackend(info types.ContainerJSON, net *network.EndpointSettings, projectServiceCount map[string]int) (*Backend, error) {
@@ -228,7 +450,7 @@ func (c *Client) inspectToBackend(info types.ContainerJSON, net *network.Endpoin
 	// Determine the port
 	port := labelCfg.Port
 	if port == 0 {
-		port = c.detectPort(info)
+		port = c.detectPort(info, endpointIP(net))
 	}
 	if port == 0 {
 		slog.Debug("no port found for container",
@@ -249,38 +471,143 @@ func (c *Client) inspectToBackend(info types.ContainerJSON, net *network.Endpoin
 	if hostname == "" {
 		hostname = c.detectHostname(info, projectServiceCount)
 	}
+	if c.registry != nil {
+		c.registry.check(hostnameKey(info), hostname)
+	}
 
 	return &Backend{
 		ContainerID:   info.ID,
 		ContainerName: strings.TrimPrefix(info.Name, "/"),
 		ServiceName:   serviceName,
 		ProjectName:   projectName,
-		Host:          net.IPAddress,
+		Host:          c.backendAddress(info, net),
 		Port:          port,
 		Hostname:      hostname,
 		PathPrefix:    labelCfg.PathPrefix,
+		Coalesce:      labelCfg.Coalesce,
+		Legacy:        labelCfg.Legacy,
+		PlainHTTP:     labelCfg.PlainHTTP,
+
+		BlockServiceWorkers: labelCfg.BlockServiceWorkers,
+		SnapshotPaths:       labelCfg.SnapshotPaths,
+
+		Image: config.ParseImageLabels(info.Config.Labels),
+		Embed: labelCfg.Embed,
+
+		Mounts: labelCfg.Mounts,
+		ESI:    labelCfg.ESI,
+		Banner: labelCfg.Banner,
+
+		Overrides:  labelCfg.Overrides,
+		LiveReload: labelCfg.LiveReload,
+
+		KeepRedirects: labelCfg.KeepRedirects,
+		Cookies:       labelCfg.Cookies,
+		Record:        labelCfg.Record,
+		RecordBodies:  labelCfg.RecordBodies,
+		HealthPath:    labelCfg.HealthPath,
+		AccessLog:     labelCfg.AccessLog,
+
+		MaxConcurrent:     labelCfg.MaxConcurrent,
+		MaxConcurrentWait: labelCfg.MaxConcurrentWait,
+
+		NoKeepAlive:  labelCfg.NoKeepAlive,
+		MaxIdleConns: labelCfg.MaxIdleConns,
+		ConnLifetime: labelCfg.ConnLifetime,
+
+		Paused: info.State != nil && info.State.Paused,
+		Labels: config.RojiLabels(info.Config.Labels),
+
+		Tunnel:       labelCfg.Tunnel,
+		TunnelAuth:   labelCfg.TunnelAuth,
+		TunnelSecret: labelCfg.TunnelSecret,
 	}, nil
 }
 
-// detectPort finds the first exposed port from the container config
-func (c *Client) detectPort(info types.ContainerJSON) int {
-	// First, check ExposedPorts from image/container config
-	for portSpec := range info.Config.ExposedPorts {
-		portStr := strings.Split(string(portSpec), "/")[0]
-		if port, err := strconv.Atoi(portStr); err == nil {
-			return port
+// endpointIP returns the container's address on the network, falling back to
+// the global IPv6 address on IPv6-only networks
+func endpointIP(net *network.EndpointSettings) string {
+	if net.IPAddress != "" {
+		return net.IPAddress
+	}
+	return net.GlobalIPv6Address
+}
+
+// routeBackends expands a container's primary backend into everything it
+// serves: per-port backends plus a redirecting alias when roji.canonical-host
+// moves the route away from its default hostname
+func (c *Client) routeBackends(info types.ContainerJSON, primary *Backend) []*Backend {
+	alias := primary.Hostname
+	canonical := config.ParseLabels(info.Config.Labels).CanonicalHost
+	if canonical == "" || strings.EqualFold(canonical, alias) {
+		return c.publishPorts(info, c.applyGenerations(c.expandPorts(info, primary)))
+	}
+
+	primary.Hostname = canonical
+	backends := c.expandPorts(info, primary)
+
+	redirect := *primary
+	redirect.Hostname = alias
+	redirect.RedirectTo = canonical
+	return c.publishPorts(info, c.applyGenerations(append(backends, &redirect)))
+}
+
+// applyGenerations moves backends to their rotated hostnames (see RotateHostname)
+func (c *Client) applyGenerations(backends []*Backend) []*Backend {
+	for _, b := range backends {
+		b.Hostname = c.generations.apply(b.Hostname)
+		if b.RedirectTo != "" {
+			b.RedirectTo = c.generations.apply(b.RedirectTo)
 		}
 	}
+	return backends
+}
+
+// backendAddress returns the host to dial for a container on the shared network
+func (c *Client) backendAddress(info types.ContainerJSON, net *network.EndpointSettings) string {
+	name := strings.TrimPrefix(info.Name, "/")
+	if c.dialByName {
+		return name
+	}
+	if ip := endpointIP(net); ip != "" {
+		return ip
+	}
+	// Docker's embedded DNS resolves container names on user-defined networks
+	slog.Warn("container has no IP on the network; dialing it by name",
+		"container", shortID(info.ID), "name", name)
+	return name
+}
+
+// detectPort picks the port to route to from the container's exposed ports.
+// Common HTTP ports are preferred; with probing enabled, ports that are
+// actually accepting connections win over ones that are not.
+func (c *Client) detectPort(info types.ContainerJSON, host string) int {
+	ports := candidatePorts(info)
+	if len(ports) == 0 {
+		return 0
+	}
+	if len(ports) == 1 {
+		return ports[0]
+	}
 
-	// Fallback: check published ports (less preferred for internal routing)
-	for portSpec := range info.NetworkSettings.Ports {
-		portStr := strings.Split(string(portSpec), "/")[0]
-		if port, err := strconv.Atoi(portStr); err == nil {
-			return port
+	reason := "preferred"
+	port := ports[0]
+	if c.probePorts && host != "" {
+		if listening := probeListening(c.dial, host, ports); len(listening) > 0 {
+			port = listening[0]
+			reason = "probed"
+		} else {
+			reason = "preferred (no port answered probe)"
 		}
 	}
 
-	return 0
+	slog.Info("selected port for multi-port container",
+		"container", shortID(info.ID),
+		"name", strings.TrimPrefix(info.Name, "/"),
+		"port", port,
+		"reason", reason,
+		"candidates", ports)
+	return port
 }
 
 // detectHostname generates a hostname based on project/service context
@@ -288,6 +615,18 @@ func (c *Client) detectPort(info types.ContainerJSON) int {
 // - Multiple services in project: service.project.localhost
 // - Non-compose container: container-name.localhost
 func (c *Client) detectHostname(info types.ContainerJSON, projectServiceCount map[string]int) string {
+	generated := c.generateHostname(info, projectServiceCount)
+	if c.registry != nil {
+		generated = c.registry.avoid(hostnameKey(info), generated)
+	}
+	if c.pins == nil {
+		return generated
+	}
+	return c.pins.resolve(hostnameKey(info), generated)
+}
+
+// generateHostname derives a hostname from the project's current shape
+func (c *Client) generateHostname(info types.ContainerJSON, projectServiceCount map[string]int) string {
 	projectName := info.Config.Labels["com.docker.compose.project"]
 	serviceName := info.Config.Labels["com.docker.compose.service"]
 
@@ -296,10 +635,10 @@ func (c *Client) detectHostname(info types.ContainerJSON, projectServiceCount ma
 		count := projectServiceCount[projectName]
 		if count <= 1 {
 			// Single service: use project name only
-			return config.DefaultHostname(projectName, c.baseDomain)
+			return c.projectDomain(projectName)
 		}
 		// Multiple services: use service.project format
-		return config.DefaultHostname(serviceName+"."+projectName, c.baseDomain)
+		return config.DefaultHostname(serviceName, c.projectDomain(projectName))
 	}
 
 	// Fall back to container name for non-compose containers
@@ -307,19 +646,30 @@ func (c *Client) detectHostname(info types.ContainerJSON, projectServiceCount ma
 	return config.DefaultHostname(name, c.baseDomain)
 }
 
+// ContainerProject returns the compose project of a container on the shared
+// network, or "" for standalone containers and containers on other networks.
+// Stopped containers can still be inspected, so this works for stop events.
+func (c *Client) ContainerProject(ctx context.Context, containerID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	info, err := c.inspect(ctx, containerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if _, ok := info.NetworkSettings.Networks[c.networkName]; !ok {
+		return "", nil
+	}
+	return info.Config.Labels["com.docker.compose.project"], nil
+}
+
 // GetProjectBackends gets all backends for a specific project
 func (c *Client) GetProjectBackends(ctx context.Context, projectName string) ([]*Backend, error) {
 	// Add timeout for Docker API call
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	filterArgs := filters.NewArgs()
-	filterArgs.Add("network", c.networkName)
-	filterArgs.Add("label", "com.docker.compose.project="+projectName)
-
-	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
-		Filters: filterArgs,
-	})
+	containers, err := c.listNetworkContainers(ctx, "com.docker.compose.project="+projectName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
@@ -329,16 +679,17 @@ func (c *Client) GetProjectBackends(ctx context.Context, projectName string) ([]
 
 	var backends []*Backend
 	for _, ctr := range containers {
-		backend, err := c.containerToBackend(ctx, ctr, projectServiceCount)
+		if !routableStates[ctr.State] {
+			continue
+		}
+		ctrBackends, err := c.containerToBackends(ctx, ctr, projectServiceCount)
 		if err != nil {
 			slog.Warn("failed to process container",
 				"container", shortID(ctr.ID),
 				"error", err)
 			continue
 		}
-		if backend != nil {
-			backends = append(backends, backend)
-		}
+		backends = append(backends, ctrBackends...)
 	}
 
 	return backends, nil
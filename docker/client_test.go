@@ -19,6 +19,9 @@ type mockDockerAPI struct {
 	containerList  func(ctx context.Context, options container.ListOptions) ([]types.Container, error)
 	containerInspect func(ctx context.Context, containerID string) (types.ContainerJSON, error)
 	events         func(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+	networks       []network.Summary
+	connected      []string // container IDs passed to NetworkConnect
+	ping           func(ctx context.Context) (types.Ping, error)
 }
 
 func (m *mockDockerAPI) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
@@ -49,6 +52,22 @@ func (m *mockDockerAPI) Events(ctx context.Context, options events.ListOptions)
 	return msgCh, errCh
 }
 
+func (m *mockDockerAPI) NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error) {
+	return m.networks, nil
+}
+
+func (m *mockDockerAPI) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	m.connected = append(m.connected, containerID)
+	return nil
+}
+
+func (m *mockDockerAPI) Ping(ctx context.Context) (types.Ping, error) {
+	if m.ping != nil {
+		return m.ping(ctx)
+	}
+	return types.Ping{}, nil
+}
+
 func (m *mockDockerAPI) Close() error {
 	return nil
 }
@@ -67,6 +86,7 @@ func createMockContainer(id, name, serviceName, projectName string, port int, ne
 		ID:     id,
 		Names:  []string{"/" + name},
 		Labels: labels,
+		State:  "running",
 		NetworkSettings: &types.SummaryNetworkSettings{
 			Networks: map[string]*network.EndpointSettings{
 				networkName: {
@@ -305,6 +325,47 @@ func TestClient_GetBackend(t *testing.T) {
 	}
 }
 
+func TestClient_GetBackends_Exited(t *testing.T) {
+	// A restarted container that crashed again must not keep its old address
+	inspectData := createMockContainerJSON("abc123", "web-1", "", "", 80, "roji")
+	inspectData.State = &types.ContainerState{Status: "exited", Running: false}
+
+	mock := &mockDockerAPI{
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			return inspectData, nil
+		},
+	}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	backends, err := client.GetBackends(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetBackends() error = %v", err)
+	}
+	if len(backends) != 0 {
+		t.Errorf("GetBackends() returned %d backends for an exited container, want 0", len(backends))
+	}
+}
+
+func TestClient_GetBackends_Paused(t *testing.T) {
+	inspectData := createMockContainerJSON("abc123", "web-1", "", "", 80, "roji")
+	inspectData.State = &types.ContainerState{Status: "paused", Running: true, Paused: true}
+
+	mock := &mockDockerAPI{
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			return inspectData, nil
+		},
+	}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	backends, err := client.GetBackends(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetBackends() error = %v", err)
+	}
+	if len(backends) != 1 || !backends[0].Paused {
+		t.Errorf("GetBackends() = %+v, want one paused backend", backends)
+	}
+}
+
 func TestClient_detectPort(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -328,7 +389,7 @@ func TestClient_detectPort(t *testing.T) {
 			mock := &mockDockerAPI{}
 			client := NewClientWithAPI(mock, "roji", "localhost")
 
-			got := client.detectPort(tt.info)
+			got := client.detectPort(tt.info, "")
 			if got != tt.wantPort {
 				t.Errorf("detectPort() = %v, want %v", got, tt.wantPort)
 			}
@@ -453,3 +514,183 @@ func TestClient_detectHostname(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildProjectServiceCounts_Replicas(t *testing.T) {
+	containers := []types.Container{
+		createMockContainer("web1", "shop-web-1", "web", "shop", 80, "roji"),
+		createMockContainer("web2", "shop-web-2", "web", "shop", 80, "roji"),
+		createMockContainer("api1", "blog-api-1", "api", "blog", 80, "roji"),
+		createMockContainer("db1", "blog-db-1", "db", "blog", 80, "roji"),
+	}
+
+	counts := buildProjectServiceCounts(containers)
+	if counts["shop"] != 1 {
+		t.Errorf("shop services = %d, want 1 (replicas count once)", counts["shop"])
+	}
+	if counts["blog"] != 2 {
+		t.Errorf("blog services = %d, want 2", counts["blog"])
+	}
+}
+
+func TestClient_DiscoverBackends_CreatedSiblings(t *testing.T) {
+	// During `docker compose up`, siblings exist in "created" state before they start.
+	// The first started service must already get the multi-service hostname.
+	api := createMockContainer("def456", "myproject-api-1", "api", "myproject", 3000, "roji")
+	api.State = "created"
+
+	mock := &mockDockerAPI{
+		containers: []types.Container{
+			createMockContainer("abc123", "myproject-web-1", "web", "myproject", 80, "roji"),
+			api,
+		},
+		inspectMap: map[string]types.ContainerJSON{
+			"abc123": createMockContainerJSON("abc123", "myproject-web-1", "web", "myproject", 80, "roji"),
+		},
+	}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	backends, err := client.DiscoverBackends(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverBackends() error = %v", err)
+	}
+	if len(backends) != 1 {
+		t.Fatalf("DiscoverBackends() got %d backends, want 1 (created containers are not routed)", len(backends))
+	}
+	if backends[0].Hostname != "web.myproject.localhost" {
+		t.Errorf("hostname = %q, want %q", backends[0].Hostname, "web.myproject.localhost")
+	}
+}
+
+func TestClient_DiscoverBackends_Paused(t *testing.T) {
+	// Listing every container (for created siblings) must not drop paused
+	// ones: they keep their routes, which explain why they don't answer
+	api := createMockContainer("def456", "myproject-api-1", "api", "myproject", 3000, "roji")
+	api.State = "paused"
+	apiInfo := createMockContainerJSON("def456", "myproject-api-1", "api", "myproject", 3000, "roji")
+	apiInfo.State = &types.ContainerState{Status: "paused", Running: true, Paused: true}
+
+	mock := &mockDockerAPI{
+		containers: []types.Container{
+			createMockContainer("abc123", "myproject-web-1", "web", "myproject", 80, "roji"),
+			api,
+		},
+		inspectMap: map[string]types.ContainerJSON{
+			"abc123": createMockContainerJSON("abc123", "myproject-web-1", "web", "myproject", 80, "roji"),
+			"def456": apiInfo,
+		},
+	}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	backends, err := client.DiscoverBackends(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverBackends() error = %v", err)
+	}
+	if len(backends) != 2 {
+		t.Fatalf("DiscoverBackends() got %d backends, want 2 (paused containers stay routed)", len(backends))
+	}
+	for _, b := range backends {
+		if b.Paused != (b.ContainerID == "def456") {
+			t.Errorf("%s: Paused = %v", b.Hostname, b.Paused)
+		}
+	}
+}
+
+func TestBuildProjectServiceCounts_IgnoresExited(t *testing.T) {
+	exited := createMockContainer("api1", "shop-api-1", "api", "shop", 80, "roji")
+	exited.State = "exited"
+
+	counts := buildProjectServiceCounts([]types.Container{
+		createMockContainer("web1", "shop-web-1", "web", "shop", 80, "roji"),
+		exited,
+	})
+	if counts["shop"] != 1 {
+		t.Errorf("shop services = %d, want 1 (exited services don't count)", counts["shop"])
+	}
+}
+
+func TestClient_BackendAddressing(t *testing.T) {
+	info := createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji")
+	mock := &mockDockerAPI{inspectMap: map[string]types.ContainerJSON{"abc123": info}}
+
+	tests := []struct {
+		name     string
+		mode     string
+		wantHost string
+	}{
+		{"ip", AddressIP, "172.18.0.2"},
+		{"dns", AddressDNS, "shop-web-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClientWithAPI(mock, "roji", "localhost", WithBackendAddressing(tt.mode))
+			backend, err := client.GetBackend(context.Background(), "abc123")
+			if err != nil || backend == nil {
+				t.Fatalf("GetBackend() = %v, %v", backend, err)
+			}
+			if backend.Host != tt.wantHost {
+				t.Errorf("Host = %q, want %q", backend.Host, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestClient_IPv6OnlyNetwork(t *testing.T) {
+	info := createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji")
+	info.NetworkSettings.Networks["roji"].IPAddress = ""
+	info.NetworkSettings.Networks["roji"].GlobalIPv6Address = "fd00::2"
+	mock := &mockDockerAPI{inspectMap: map[string]types.ContainerJSON{"abc123": info}}
+
+	client := NewClientWithAPI(mock, "roji", "localhost")
+	backend, err := client.GetBackend(context.Background(), "abc123")
+	if err != nil || backend == nil {
+		t.Fatalf("GetBackend() = %v, %v", backend, err)
+	}
+	if backend.Host != "fd00::2" {
+		t.Errorf("Host = %q, want %q", backend.Host, "fd00::2")
+	}
+	if got := backend.Address(); got != "[fd00::2]:80" {
+		t.Errorf("Address() = %q, want %q", got, "[fd00::2]:80")
+	}
+}
+
+func TestClient_CanonicalHost(t *testing.T) {
+	info := createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji")
+	info.Config.Labels["roji.canonical-host"] = "store.localhost"
+	mock := &mockDockerAPI{inspectMap: map[string]types.ContainerJSON{"abc123": info}}
+
+	client := NewClientWithAPI(mock, "roji", "localhost")
+	backends, err := client.GetBackends(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetBackends() error = %v", err)
+	}
+	if len(backends) != 2 {
+		t.Fatalf("GetBackends() returned %d backends, want 2", len(backends))
+	}
+
+	if backends[0].Hostname != "store.localhost" || backends[0].RedirectTo != "" {
+		t.Errorf("primary = %q (redirect %q), want store.localhost serving", backends[0].Hostname, backends[0].RedirectTo)
+	}
+	if backends[0].Labels["roji.canonical-host"] != "store.localhost" || len(backends[0].Labels) != 1 {
+		t.Errorf("primary labels = %v, want only roji.canonical-host", backends[0].Labels)
+	}
+	alias := backends[1]
+	if alias.Hostname == "store.localhost" || alias.RedirectTo != "store.localhost" {
+		t.Errorf("alias = %q -> %q, want default hostname redirecting to store.localhost", alias.Hostname, alias.RedirectTo)
+	}
+}
+
+func TestClient_ContainerProject(t *testing.T) {
+	mock := &mockDockerAPI{inspectMap: map[string]types.ContainerJSON{
+		"abc123": createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji"),
+		"def456": createMockContainerJSON("def456", "other-web-1", "web", "other", 80, "bridge"),
+	}}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	if project, err := client.ContainerProject(context.Background(), "abc123"); err != nil || project != "shop" {
+		t.Errorf("ContainerProject() = %q, %v, want shop", project, err)
+	}
+	if project, _ := client.ContainerProject(context.Background(), "def456"); project != "" {
+		t.Errorf("ContainerProject() = %q for a container on another network, want empty", project)
+	}
+}
@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+// dockerContext is the docker endpoint of a docker CLI context
+// (as selected with `docker context use`)
+type dockerContext struct {
+	Name          string
+	Host          string
+	SkipTLSVerify bool
+	TLSDir        string // ca.pem, cert.pem and key.pem, when the context has TLS material
+}
+
+// contextMeta is the subset of a context's meta.json roji needs
+type contextMeta struct {
+	Name      string `json:"Name"`
+	Endpoints map[string]struct {
+		Host          string `json:"Host"`
+		SkipTLSVerify bool   `json:"SkipTLSVerify"`
+	} `json:"Endpoints"`
+}
+
+// dockerConfigDir returns the docker CLI configuration directory
+// ($DOCKER_CONFIG, or ~/.docker)
+func dockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// currentDockerContext resolves the active docker context the way the docker
+// CLI does: $DOCKER_CONTEXT, then currentContext in config.json. It returns
+// nil for the built-in "default" context, which means DOCKER_HOST or the
+// local socket.
+func currentDockerContext(configDir string) (*dockerContext, error) {
+	if configDir == "" {
+		return nil, nil
+	}
+
+	name := os.Getenv("DOCKER_CONTEXT")
+	if name == "" {
+		data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read docker config: %w", err)
+		}
+		var cfg struct {
+			CurrentContext string `json:"currentContext"`
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse docker config: %w", err)
+		}
+		name = cfg.CurrentContext
+	}
+	if name == "" || name == "default" {
+		return nil, nil
+	}
+
+	// Context directories are named after the SHA-256 of the context name
+	sum := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(sum[:])
+
+	data, err := os.ReadFile(filepath.Join(configDir, "contexts", "meta", id, "meta.json"))
+	if err != nil {
+		return nil, fmt.Errorf("docker context %q not found: %w", name, err)
+	}
+	var meta contextMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse docker context %q: %w", name, err)
+	}
+	endpoint, ok := meta.Endpoints["docker"]
+	if !ok || endpoint.Host == "" {
+		return nil, fmt.Errorf("docker context %q has no docker endpoint", name)
+	}
+
+	ctx := &dockerContext{
+		Name:          name,
+		Host:          endpoint.Host,
+		SkipTLSVerify: endpoint.SkipTLSVerify,
+	}
+	tlsDir := filepath.Join(configDir, "contexts", "tls", id, "docker")
+	if _, err := os.Stat(tlsDir); err == nil {
+		ctx.TLSDir = tlsDir
+	}
+	return ctx, nil
+}
+
+// clientOpts returns the Docker client options for the context's endpoint.
+// ssh:// hosts are handled separately by remoteClientOpts.
+func (d *dockerContext) clientOpts() ([]client.Opt, error) {
+	var opts []client.Opt
+
+	if d.TLSDir != "" || d.SkipTLSVerify {
+		options := tlsconfig.Options{
+			InsecureSkipVerify: d.SkipTLSVerify,
+			ExclusiveRootPools: true,
+		}
+		if d.TLSDir != "" {
+			options.CAFile = existingFile(filepath.Join(d.TLSDir, "ca.pem"))
+			options.CertFile = existingFile(filepath.Join(d.TLSDir, "cert.pem"))
+			options.KeyFile = existingFile(filepath.Join(d.TLSDir, "key.pem"))
+		}
+		tlsConfig, err := tlsconfig.Client(options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS material for docker context %q: %w", d.Name, err)
+		}
+		// Must precede WithHost, which configures the transport for the host
+		opts = append(opts, client.WithHTTPClient(&http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		}))
+	}
+
+	return append(opts, client.WithHost(d.Host)), nil
+}
+
+// existingFile returns path if it exists, or ""
+func existingFile(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,108 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+// writeDockerContext creates a context the way `docker context create` stores it
+func writeDockerContext(t *testing.T, configDir, name, meta string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(sum[:])
+
+	dir := filepath.Join(configDir, "contexts", "meta", id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte(meta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestCurrentDockerContext(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	// No config.json: default context
+	ctx, err := currentDockerContext(configDir)
+	if err != nil || ctx != nil {
+		t.Fatalf("currentDockerContext() = %v, %v; want nil, nil", ctx, err)
+	}
+
+	writeDockerContext(t, configDir, "colima",
+		`{"Name":"colima","Endpoints":{"docker":{"Host":"unix:///Users/kan/.colima/default/docker.sock","SkipTLSVerify":false}}}`)
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext":"colima"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err = currentDockerContext(configDir)
+	if err != nil {
+		t.Fatalf("currentDockerContext() error = %v", err)
+	}
+	if ctx == nil || ctx.Name != "colima" || ctx.Host != "unix:///Users/kan/.colima/default/docker.sock" {
+		t.Fatalf("currentDockerContext() = %+v", ctx)
+	}
+	if ctx.TLSDir != "" {
+		t.Errorf("TLSDir = %q, want empty", ctx.TLSDir)
+	}
+}
+
+func TestCurrentDockerContext_Env(t *testing.T) {
+	configDir := t.TempDir()
+	id := writeDockerContext(t, configDir, "remote",
+		`{"Name":"remote","Endpoints":{"docker":{"Host":"tcp://build.example.com:2376","SkipTLSVerify":true}}}`)
+	tlsDir := filepath.Join(configDir, "contexts", "tls", id, "docker")
+	if err := os.MkdirAll(tlsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// DOCKER_CONTEXT overrides config.json
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext":"colima"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONTEXT", "remote")
+
+	ctx, err := currentDockerContext(configDir)
+	if err != nil {
+		t.Fatalf("currentDockerContext() error = %v", err)
+	}
+	if ctx.Host != "tcp://build.example.com:2376" || !ctx.SkipTLSVerify || ctx.TLSDir != tlsDir {
+		t.Errorf("currentDockerContext() = %+v", ctx)
+	}
+
+	opts, err := ctx.clientOpts()
+	if err != nil {
+		t.Fatalf("clientOpts() error = %v", err)
+	}
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		t.Fatalf("NewClientWithOpts() error = %v", err)
+	}
+	if cli.DaemonHost() != "tcp://build.example.com:2376" {
+		t.Errorf("DaemonHost() = %q", cli.DaemonHost())
+	}
+}
+
+func TestCurrentDockerContext_Default(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONTEXT", "default")
+
+	ctx, err := currentDockerContext(configDir)
+	if err != nil || ctx != nil {
+		t.Errorf("currentDockerContext() = %v, %v; want nil, nil", ctx, err)
+	}
+}
+
+func TestCurrentDockerContext_Missing(t *testing.T) {
+	t.Setenv("DOCKER_CONTEXT", "gone")
+	if _, err := currentDockerContext(t.TempDir()); err == nil {
+		t.Error("currentDockerContext() should fail for an unknown context")
+	}
+}
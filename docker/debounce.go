@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"context"
+	"time"
+)
+
+// Debounce groups container events into batches so bursts (e.g., `docker
+// compose up` starting many services) can be applied as a single update.
+// A batch is emitted once no event has arrived for window, or at most
+// 10*window after its first event. Repeated events for the same container
+// collapse into the latest one. A zero window emits every event on its own.
+func Debounce(ctx context.Context, in <-chan ContainerEvent, window time.Duration) <-chan []ContainerEvent {
+	out := make(chan []ContainerEvent)
+
+	go func() {
+		defer close(out)
+
+		var (
+			batch    []ContainerEvent
+			index    = make(map[string]int) // container ID -> position in batch
+			quiet    <-chan time.Time
+			deadline <-chan time.Time
+		)
+
+		flush := func() bool {
+			if len(batch) > 0 {
+				select {
+				case out <- batch:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			batch, index = nil, make(map[string]int)
+			quiet, deadline = nil, nil
+			return true
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				if i, seen := index[event.ContainerID]; seen {
+					batch[i] = event
+				} else {
+					index[event.ContainerID] = len(batch)
+					batch = append(batch, event)
+				}
+				if window <= 0 {
+					if !flush() {
+						return
+					}
+					continue
+				}
+				quiet = time.After(window)
+				if deadline == nil {
+					deadline = time.After(10 * window)
+				}
+
+			case <-quiet:
+				if !flush() {
+					return
+				}
+
+			case <-deadline:
+				if !flush() {
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDebounce_BatchesBurst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan ContainerEvent)
+	out := Debounce(ctx, in, 20*time.Millisecond)
+
+	// stop and die both arrive for the same container
+	in <- ContainerEvent{Type: EventStart, ContainerID: "a"}
+	in <- ContainerEvent{Type: EventStart, ContainerID: "b"}
+	in <- ContainerEvent{Type: EventStop, ContainerID: "a"}
+	in <- ContainerEvent{Type: EventStop, ContainerID: "a"}
+
+	select {
+	case batch := <-out:
+		if len(batch) != 2 {
+			t.Fatalf("batch = %+v, want 2 events", batch)
+		}
+		if batch[0].ContainerID != "a" || batch[0].Type != EventStop {
+			t.Errorf("batch[0] = %+v, want latest event for a (stop)", batch[0])
+		}
+		if batch[1].ContainerID != "b" || batch[1].Type != EventStart {
+			t.Errorf("batch[1] = %+v, want start for b", batch[1])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no batch emitted")
+	}
+}
+
+func TestDebounce_ZeroWindow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan ContainerEvent)
+	out := Debounce(ctx, in, 0)
+
+	go func() {
+		in <- ContainerEvent{Type: EventStart, ContainerID: "a"}
+	}()
+
+	select {
+	case batch := <-out:
+		if len(batch) != 1 || batch[0].ContainerID != "a" {
+			t.Errorf("batch = %+v, want single event for a", batch)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no batch emitted")
+	}
+}
+
+func TestDebounce_FlushesOnClose(t *testing.T) {
+	in := make(chan ContainerEvent, 1)
+	out := Debounce(context.Background(), in, time.Hour)
+
+	in <- ContainerEvent{Type: EventStart, ContainerID: "a"}
+	close(in)
+
+	batch, ok := <-out
+	if !ok || len(batch) != 1 {
+		t.Errorf("batch = %+v, ok = %v, want pending event flushed", batch, ok)
+	}
+	if _, ok := <-out; ok {
+		t.Error("output channel should be closed")
+	}
+}
@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"maps"
+
+	"github.com/docker/docker/api/types"
+)
+
+// WithDefaultLabels gives containers roji.* labels they don't set
+// themselves, by compose project; "" applies to every container (e.g.,
+// middleware from roji.yaml)
+func WithDefaultLabels(defaults map[string]map[string]string) ClientOption {
+	return func(c *Client) {
+		if len(defaults) > 0 {
+			c.defaultLabels = defaults
+		}
+	}
+}
+
+// WithProjectDomains serves compose projects on their own domain instead of
+// {project}.{baseDomain}
+func WithProjectDomains(domains map[string]string) ClientOption {
+	return func(c *Client) {
+		if len(domains) > 0 {
+			c.projectDomains = domains
+		}
+	}
+}
+
+// applyDefaultLabels returns info with the default labels of its project
+// added. Inspect results may be shared, so labels are copied, never modified.
+func (c *Client) applyDefaultLabels(info types.ContainerJSON) types.ContainerJSON {
+	if c.defaultLabels == nil || info.Config == nil {
+		return info
+	}
+	defaults, ok := c.defaultLabels[info.Config.Labels["com.docker.compose.project"]]
+	if !ok {
+		defaults = c.defaultLabels[""]
+	}
+	if len(defaults) == 0 {
+		return info
+	}
+
+	labels := maps.Clone(defaults)
+	maps.Copy(labels, info.Config.Labels)
+	cfg := *info.Config
+	cfg.Labels = labels
+	info.Config = &cfg
+	return info
+}
+
+// projectDomain returns the domain of a project's hostnames: its own, or
+// {project}.{baseDomain}
+func (c *Client) projectDomain(projectName string) string {
+	if domain, ok := c.projectDomains[projectName]; ok {
+		return domain
+	}
+	return projectName + "." + c.baseDomain
+}
@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestClient_DefaultLabels(t *testing.T) {
+	shop := createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji")
+	shop.Config.Labels["roji.banner"] = "false"
+	blog := createMockContainerJSON("def456", "blog-web-1", "web", "blog", 80, "roji")
+	mock := &mockDockerAPI{
+		containers: []types.Container{
+			createMockContainer("abc123", "shop-web-1", "web", "shop", 80, "roji"),
+			createMockContainer("def456", "blog-web-1", "web", "blog", 80, "roji"),
+		},
+		inspectMap: map[string]types.ContainerJSON{"abc123": shop, "def456": blog},
+	}
+	client := NewClientWithAPI(mock, "roji", "localhost", WithInspectCache(time.Minute), WithDefaultLabels(map[string]map[string]string{
+		"":     {"roji.banner": "true", "roji.record": "true"},
+		"shop": {"roji.banner": "true", "roji.cookie-secure": "true"},
+	}))
+
+	backends, err := client.DiscoverBackends(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverBackends() error = %v", err)
+	}
+	labels := make(map[string]map[string]string)
+	for _, b := range backends {
+		labels[b.ProjectName] = b.Labels
+	}
+
+	// The container's own label wins over the project default
+	if got := labels["shop"]; got["roji.banner"] != "false" || got["roji.cookie-secure"] != "true" || got["roji.record"] != "" {
+		t.Errorf("shop labels = %v, want own banner and the shop defaults only", got)
+	}
+	if got := labels["blog"]; got["roji.banner"] != "true" || got["roji.record"] != "true" {
+		t.Errorf("blog labels = %v, want the global defaults", got)
+	}
+	// Cached inspect results are left alone
+	if _, ok := shop.Config.Labels["roji.cookie-secure"]; ok {
+		t.Error("default labels were written into the inspect result")
+	}
+}
+
+func TestClient_ProjectDomains(t *testing.T) {
+	mock := &mockDockerAPI{
+		containers: []types.Container{
+			createMockContainer("abc123", "shop-web-1", "web", "shop", 80, "roji"),
+			createMockContainer("def456", "shop-api-1", "api", "shop", 3000, "roji"),
+			createMockContainer("ghi789", "blog-web-1", "web", "blog", 80, "roji"),
+			createMockContainer("jkl012", "docs-web-1", "web", "docs", 80, "roji"),
+		},
+		inspectMap: map[string]types.ContainerJSON{
+			"abc123": createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji"),
+			"def456": createMockContainerJSON("def456", "shop-api-1", "api", "shop", 3000, "roji"),
+			"ghi789": createMockContainerJSON("ghi789", "blog-web-1", "web", "blog", 80, "roji"),
+			"jkl012": createMockContainerJSON("jkl012", "docs-web-1", "web", "docs", 80, "roji"),
+		},
+	}
+	client := NewClientWithAPI(mock, "roji", "dev.localhost", WithProjectDomains(map[string]string{
+		"shop": "shop.localhost",
+		"blog": "blog.localhost",
+	}))
+
+	backends, err := client.DiscoverBackends(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverBackends() error = %v", err)
+	}
+	var hostnames []string
+	for _, b := range backends {
+		hostnames = append(hostnames, b.Hostname)
+	}
+	sort.Strings(hostnames)
+	want := []string{"api.shop.localhost", "blog.localhost", "docs.dev.localhost", "web.shop.localhost"}
+	if !slices.Equal(hostnames, want) {
+		t.Errorf("hostnames = %v, want %v", hostnames, want)
+	}
+}
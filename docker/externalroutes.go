@@ -0,0 +1,31 @@
+package docker
+
+import "github.com/kan/roji/config"
+
+// externalRouteService is the service name shown for external routes
+const externalRouteService = "external"
+
+// WithExternalRoutes proxies hostnames to URLs outside Docker. Like host
+// routes, they are returned by DiscoverBackends alongside containers.
+func WithExternalRoutes(routes []config.ExternalRoute) ClientOption {
+	return func(c *Client) {
+		c.externalRoutes = routes
+	}
+}
+
+// externalBackends returns the backends of the configured external routes
+func (c *Client) externalBackends() []*Backend {
+	backends := make([]*Backend, 0, len(c.externalRoutes))
+	for _, route := range c.externalRoutes {
+		backends = append(backends, &Backend{
+			ContainerID:   externalRouteService + ":" + route.Hostname,
+			ContainerName: route.URL,
+			ServiceName:   externalRouteService,
+			Hostname:      route.Hostname,
+			ExternalURL:   route.URL,
+			// Cookies for the external domain stick to the local hostname
+			Cookies: config.CookiePolicy{Domain: config.CookieDomainStrip},
+		})
+	}
+	return backends
+}
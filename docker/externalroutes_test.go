@@ -0,0 +1,28 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kan/roji/config"
+)
+
+func TestClient_DiscoverBackends_ExternalRoutes(t *testing.T) {
+	routes := []config.ExternalRoute{{Hostname: "api.localhost", URL: "https://api.staging.example.com"}}
+	client := NewClientWithAPI(&mockDockerAPI{}, "roji", "localhost", WithExternalRoutes(routes))
+
+	backends, err := client.DiscoverBackends(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverBackends() error = %v", err)
+	}
+	if len(backends) != 1 {
+		t.Fatalf("DiscoverBackends() returned %d backends, want 1", len(backends))
+	}
+	b := backends[0]
+	if b.Hostname != "api.localhost" || b.ExternalURL != "https://api.staging.example.com" || b.ContainerID != "external:api.localhost" {
+		t.Errorf("external backend = %+v", b)
+	}
+	if b.Cookies.Domain != config.CookieDomainStrip {
+		t.Errorf("Cookies.Domain = %q, want cookies kept on the local hostname", b.Cookies.Domain)
+	}
+}
@@ -0,0 +1,36 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+// BridgeGateways returns the host-side gateway addresses of bridge networks.
+// When roji runs in a container, requests from the Docker host arrive from
+// these addresses (via docker-proxy), so LAN mode trusts them like loopback.
+func (c *Client) BridgeGateways(ctx context.Context) ([]netip.Addr, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	networks, err := c.docker.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+
+	var gateways []netip.Addr
+	for _, n := range networks {
+		if n.Driver != "bridge" {
+			continue
+		}
+		for _, cfg := range n.IPAM.Config {
+			if addr, err := netip.ParseAddr(cfg.Gateway); err == nil {
+				gateways = append(gateways, addr)
+			}
+		}
+	}
+	return gateways, nil
+}
@@ -0,0 +1,36 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestClient_BridgeGateways(t *testing.T) {
+	mock := &mockDockerAPI{networks: []network.Summary{
+		{Name: "bridge", Driver: "bridge", IPAM: network.IPAM{Config: []network.IPAMConfig{{Gateway: "172.17.0.1"}}}},
+		{Name: "roji", Driver: "bridge", IPAM: network.IPAM{Config: []network.IPAMConfig{{Gateway: "172.20.0.1"}, {Gateway: "fd00::1"}}}},
+		{Name: "host", Driver: "host"},
+		{Name: "overlay", Driver: "overlay", IPAM: network.IPAM{Config: []network.IPAMConfig{{Gateway: "10.0.0.1"}}}},
+	}}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	gateways, err := client.BridgeGateways(context.Background())
+	if err != nil {
+		t.Fatalf("BridgeGateways() error = %v", err)
+	}
+	var got []string
+	for _, g := range gateways {
+		got = append(got, g.String())
+	}
+	want := []string{"172.17.0.1", "172.20.0.1", "fd00::1"}
+	if len(got) != len(want) {
+		t.Fatalf("BridgeGateways() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("BridgeGateways()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
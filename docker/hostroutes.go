@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"strconv"
+
+	"github.com/kan/roji/config"
+)
+
+// DefaultHostGateway resolves to the Docker host from inside containers
+// (built into Docker Desktop; add `extra_hosts: host.docker.internal:host-gateway`
+// on Linux)
+const DefaultHostGateway = "host.docker.internal"
+
+// Service names shown for host routes
+const (
+	hostRouteService    = "host"   // --host-routes
+	exposedRouteService = "expose" // roji expose
+)
+
+// WithHostRoutes routes hostnames to processes on the Docker host, dialed
+// through gateway. Host routes are returned by DiscoverBackends alongside
+// containers.
+func WithHostRoutes(routes []config.HostRoute, gateway string) ClientOption {
+	return func(c *Client) {
+		c.hostRoutes = routes
+		c.hostGateway = gateway
+		if c.hostGateway == "" {
+			c.hostGateway = DefaultHostGateway
+		}
+	}
+}
+
+// ExposeHostRoute adds (or re-targets) a temporary host route, as created by
+// `roji expose`, and returns its backend. Like --host-routes, it survives
+// route resyncs until UnexposeHostRoute.
+func (c *Client) ExposeHostRoute(route config.HostRoute) *Backend {
+	c.hostMu.Lock()
+	defer c.hostMu.Unlock()
+
+	if c.exposed == nil {
+		c.exposed = make(map[string]config.HostRoute)
+	}
+	c.exposed[route.Hostname] = route
+	return c.hostBackend(route, exposedRouteService)
+}
+
+// UnexposeHostRoute removes a temporary host route and returns the container
+// ID its backend was registered under
+func (c *Client) UnexposeHostRoute(hostname string) (string, bool) {
+	c.hostMu.Lock()
+	defer c.hostMu.Unlock()
+
+	route, ok := c.exposed[hostname]
+	if !ok {
+		return "", false
+	}
+	delete(c.exposed, hostname)
+	return c.hostBackend(route, exposedRouteService).ContainerID, true
+}
+
+// hostBackends returns the backends of the configured and exposed host
+// routes. Their ContainerID is "<service>:<hostname>", so container events
+// never touch them.
+func (c *Client) hostBackends() []*Backend {
+	c.hostMu.Lock()
+	defer c.hostMu.Unlock()
+
+	backends := make([]*Backend, 0, len(c.hostRoutes)+len(c.exposed))
+	for _, route := range c.hostRoutes {
+		backends = append(backends, c.hostBackend(route, hostRouteService))
+	}
+	for _, route := range c.exposed {
+		backends = append(backends, c.hostBackend(route, exposedRouteService))
+	}
+	return backends
+}
+
+func (c *Client) hostBackend(route config.HostRoute, service string) *Backend {
+	gateway := c.hostGateway
+	if gateway == "" {
+		gateway = DefaultHostGateway
+	}
+	return &Backend{
+		ContainerID:   service + ":" + route.Hostname,
+		ContainerName: gateway + ":" + strconv.Itoa(route.Port),
+		ServiceName:   service,
+		Host:          gateway,
+		Port:          route.Port,
+		Hostname:      route.Hostname,
+	}
+}
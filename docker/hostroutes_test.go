@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/kan/roji/config"
+)
+
+func TestClient_DiscoverBackends_HostRoutes(t *testing.T) {
+	mock := &mockDockerAPI{
+		containers: []types.Container{
+			createMockContainer("abc123", "myproject-web-1", "web", "myproject", 80, "roji"),
+		},
+		inspectMap: map[string]types.ContainerJSON{
+			"abc123": createMockContainerJSON("abc123", "myproject-web-1", "web", "myproject", 80, "roji"),
+		},
+	}
+	routes := []config.HostRoute{{Hostname: "vite.localhost", Port: 5173}}
+	client := NewClientWithAPI(mock, "roji", "localhost", WithHostRoutes(routes, ""))
+
+	backends, err := client.DiscoverBackends(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverBackends() error = %v", err)
+	}
+	if len(backends) != 2 {
+		t.Fatalf("DiscoverBackends() returned %d backends, want 2", len(backends))
+	}
+	host := backends[1]
+	if host.Hostname != "vite.localhost" || host.Address() != "host.docker.internal:5173" {
+		t.Errorf("host backend = %s -> %s, want vite.localhost -> host.docker.internal:5173", host.Hostname, host.Address())
+	}
+	if host.ContainerID != "host:vite.localhost" {
+		t.Errorf("ContainerID = %q, want host:vite.localhost", host.ContainerID)
+	}
+}
+
+func TestClient_ExposeHostRoute(t *testing.T) {
+	client := NewClientWithAPI(&mockDockerAPI{}, "roji", "localhost")
+
+	backend := client.ExposeHostRoute(config.HostRoute{Hostname: "myapp.localhost", Port: 3000})
+	if backend.ContainerID != "expose:myapp.localhost" || backend.Address() != "host.docker.internal:3000" {
+		t.Errorf("ExposeHostRoute() = %s -> %s", backend.ContainerID, backend.Address())
+	}
+
+	backends, err := client.DiscoverBackends(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverBackends() error = %v", err)
+	}
+	if len(backends) != 1 || backends[0].Hostname != "myapp.localhost" {
+		t.Errorf("DiscoverBackends() = %v, want the exposed route", backends)
+	}
+
+	if id, ok := client.UnexposeHostRoute("myapp.localhost"); !ok || id != backend.ContainerID {
+		t.Errorf("UnexposeHostRoute() = %q, %v", id, ok)
+	}
+	if _, ok := client.UnexposeHostRoute("myapp.localhost"); ok {
+		t.Error("UnexposeHostRoute() twice = true, want false")
+	}
+}
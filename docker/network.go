@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+)
+
+// NetworkExists reports whether the network roji watches has been created
+// (`docker network create roji`)
+func (c *Client) NetworkExists(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	networks, err := c.docker.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", c.networkName)),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list networks: %w", err)
+	}
+	// The name filter also matches substrings (e.g., "roji" finds "roji-test")
+	for _, n := range networks {
+		if n.Name == c.networkName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
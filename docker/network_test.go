@@ -0,0 +1,26 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestClient_NetworkExists(t *testing.T) {
+	mock := &mockDockerAPI{networks: []network.Summary{
+		{Name: "bridge", Driver: "bridge"},
+		{Name: "roji-test", Driver: "bridge"},
+	}}
+
+	exists, err := NewClientWithAPI(mock, "roji", "localhost").NetworkExists(context.Background())
+	if err != nil || exists {
+		t.Errorf("NetworkExists() with only roji-test = %v, %v; want false", exists, err)
+	}
+
+	mock.networks = append(mock.networks, network.Summary{Name: "roji", Driver: "bridge"})
+	exists, err = NewClientWithAPI(mock, "roji", "localhost").NetworkExists(context.Background())
+	if err != nil || !exists {
+		t.Errorf("NetworkExists() = %v, %v; want true", exists, err)
+	}
+}
@@ -0,0 +1,86 @@
+package docker
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Hostname stability modes
+const (
+	HostnameStabilityDynamic = "dynamic" // Hostnames follow the project's current shape
+	HostnameStabilitySticky  = "sticky"  // First assigned hostname is kept for the process lifetime
+)
+
+// hostnamePins remembers auto-generated hostnames per service in sticky mode
+type hostnamePins struct {
+	mu   sync.Mutex
+	pins map[string]string // key: hostnameKey
+}
+
+func newHostnamePins() *hostnamePins {
+	return &hostnamePins{pins: make(map[string]string)}
+}
+
+// hostnameKey identifies a service independently of its container instances:
+// "project/service" for compose services, the container name otherwise
+func hostnameKey(info types.ContainerJSON) string {
+	projectName := info.Config.Labels["com.docker.compose.project"]
+	serviceName := info.Config.Labels["com.docker.compose.service"]
+	if projectName != "" && serviceName != "" {
+		return projectName + "/" + serviceName
+	}
+	return strings.TrimPrefix(info.Name, "/")
+}
+
+// resolve returns the pinned hostname for key, pinning generated on first use
+func (p *hostnamePins) resolve(key string, generated string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pinned, ok := p.pins[key]; ok {
+		return pinned
+	}
+	p.pins[key] = generated
+	return generated
+}
+
+// HostnameStability returns the configured hostname stability mode
+func (c *Client) HostnameStability() string {
+	if c.pins != nil {
+		return HostnameStabilitySticky
+	}
+	return HostnameStabilityDynamic
+}
+
+// PinnedHostnames returns a copy of the sticky hostname assignments
+// (nil when sticky mode is disabled)
+func (c *Client) PinnedHostnames() map[string]string {
+	if c.pins == nil {
+		return nil
+	}
+
+	c.pins.mu.Lock()
+	defer c.pins.mu.Unlock()
+
+	result := make(map[string]string, len(c.pins.pins))
+	for k, v := range c.pins.pins {
+		result[k] = v
+	}
+	return result
+}
+
+// PinHostname explicitly remaps a service key ("project/service" or container
+// name) to a hostname. It reports false when sticky mode is disabled.
+func (c *Client) PinHostname(key, hostname string) bool {
+	if c.pins == nil {
+		return false
+	}
+
+	c.pins.mu.Lock()
+	defer c.pins.mu.Unlock()
+
+	c.pins.pins[key] = strings.ToLower(hostname)
+	return true
+}
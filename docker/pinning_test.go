@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"testing"
+)
+
+func TestClient_detectHostname_Sticky(t *testing.T) {
+	client := NewClientWithAPI(&mockDockerAPI{}, "roji", "localhost",
+		WithHostnameStability(HostnameStabilitySticky))
+	info := createMockContainerJSON("abc", "shop-web-1", "web", "shop", 80, "roji")
+
+	// First assignment: single-service project
+	if got := client.detectHostname(info, map[string]int{"shop": 1}); got != "shop.localhost" {
+		t.Fatalf("first hostname = %q, want %q", got, "shop.localhost")
+	}
+
+	// Project grows: sticky mode keeps the original hostname
+	if got := client.detectHostname(info, map[string]int{"shop": 2}); got != "shop.localhost" {
+		t.Errorf("hostname after growth = %q, want pinned %q", got, "shop.localhost")
+	}
+
+	// Explicit remap wins
+	if !client.PinHostname("shop/web", "web.shop.localhost") {
+		t.Fatal("PinHostname() = false in sticky mode")
+	}
+	if got := client.detectHostname(info, map[string]int{"shop": 2}); got != "web.shop.localhost" {
+		t.Errorf("hostname after remap = %q, want %q", got, "web.shop.localhost")
+	}
+
+	pins := client.PinnedHostnames()
+	if pins["shop/web"] != "web.shop.localhost" {
+		t.Errorf("PinnedHostnames()[shop/web] = %q", pins["shop/web"])
+	}
+}
+
+func TestClient_detectHostname_Dynamic(t *testing.T) {
+	client := NewClientWithAPI(&mockDockerAPI{}, "roji", "localhost")
+	info := createMockContainerJSON("abc", "shop-web-1", "web", "shop", 80, "roji")
+
+	client.detectHostname(info, map[string]int{"shop": 1})
+	if got := client.detectHostname(info, map[string]int{"shop": 2}); got != "web.shop.localhost" {
+		t.Errorf("dynamic hostname = %q, want %q", got, "web.shop.localhost")
+	}
+	if client.PinHostname("shop/web", "x.localhost") {
+		t.Error("PinHostname() should be rejected in dynamic mode")
+	}
+	if client.HostnameStability() != HostnameStabilityDynamic {
+		t.Errorf("HostnameStability() = %q", client.HostnameStability())
+	}
+}
+
+func TestHostnameKey(t *testing.T) {
+	if got := hostnameKey(createMockContainerJSON("a", "shop-web-1", "web", "shop", 80, "roji")); got != "shop/web" {
+		t.Errorf("compose key = %q, want %q", got, "shop/web")
+	}
+	if got := hostnameKey(createMockContainerJSON("a", "standalone", "", "", 80, "roji")); got != "standalone" {
+		t.Errorf("container key = %q, want %q", got, "standalone")
+	}
+}
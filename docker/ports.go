@@ -0,0 +1,148 @@
+package docker
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/kan/roji/config"
+)
+
+// preferredHTTPPorts are common HTTP application ports, most likely first.
+// When a container exposes several ports, these win over debug/admin ports.
+var preferredHTTPPorts = []int{80, 8080, 3000, 8000, 5000, 4000, 5173, 8081, 8888, 9000}
+
+// portProbeTimeout bounds each TCP probe of an exposed port
+const portProbeTimeout = 500 * time.Millisecond
+
+// portRank returns the preference rank of a port (lower is better)
+func portRank(port int) int {
+	for i, p := range preferredHTTPPorts {
+		if p == port {
+			return i
+		}
+	}
+	return len(preferredHTTPPorts)
+}
+
+// parsePorts extracts TCP port numbers from a port set/map key list
+func parsePorts(specs []nat.Port) []int {
+	var ports []int
+	for _, spec := range specs {
+		if proto := spec.Proto(); proto != "" && proto != "tcp" {
+			continue
+		}
+		if port, err := strconv.Atoi(strings.Split(string(spec), "/")[0]); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// sortPorts orders ports by HTTP preference, then numerically
+func sortPorts(ports []int) {
+	sort.Slice(ports, func(i, j int) bool {
+		ri, rj := portRank(ports[i]), portRank(ports[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return ports[i] < ports[j]
+	})
+}
+
+// candidatePorts returns the container's exposed TCP ports in preference order.
+// Published ports are used as a fallback when nothing is exposed.
+func candidatePorts(info types.ContainerJSON) []int {
+	var specs []nat.Port
+	if info.Config != nil {
+		for spec := range info.Config.ExposedPorts {
+			specs = append(specs, spec)
+		}
+	}
+	if len(specs) == 0 && info.NetworkSettings != nil {
+		for spec := range info.NetworkSettings.Ports {
+			specs = append(specs, spec)
+		}
+	}
+
+	ports := parsePorts(specs)
+	sortPorts(ports)
+	return ports
+}
+
+// dialFunc dials a network address (replaceable in tests)
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// probeListening returns the subset of ports accepting TCP connections on host,
+// preserving the input order
+func probeListening(dial dialFunc, host string, ports []int) []int {
+	listening := make([]bool, len(ports))
+
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), portProbeTimeout)
+			defer cancel()
+			conn, err := dial(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+			if err != nil {
+				return
+			}
+			conn.Close()
+			listening[i] = true
+		}(i, port)
+	}
+	wg.Wait()
+
+	var result []int
+	for i, port := range ports {
+		if listening[i] {
+			result = append(result, port)
+		}
+	}
+	return result
+}
+
+// portHostname derives the hostname for a secondary port by suffixing the
+// first label: ("web.app.localhost", 9229) -> "web-9229.app.localhost"
+func portHostname(hostname string, port int) string {
+	first, rest, found := strings.Cut(hostname, ".")
+	if !found {
+		return hostname + "-" + strconv.Itoa(port)
+	}
+	return first + "-" + strconv.Itoa(port) + "." + rest
+}
+
+// expandPorts returns the primary backend followed by one backend per
+// additional exposed port when all-ports routing is enabled for the container
+func (c *Client) expandPorts(info types.ContainerJSON, primary *Backend) []*Backend {
+	backends := []*Backend{primary}
+
+	enabled := c.allPorts
+	if value, ok := info.Config.Labels[config.LabelAllPorts]; ok {
+		enabled = config.ParseLabels(map[string]string{config.LabelAllPorts: value}).AllPorts
+	}
+	if !enabled {
+		return backends
+	}
+
+	for _, port := range candidatePorts(info) {
+		if port == primary.Port {
+			continue
+		}
+		extra := *primary
+		extra.Port = port
+		extra.Hostname = portHostname(primary.Hostname, port)
+		extra.PathPrefix = "" // secondary ports get their own hostname
+		backends = append(backends, &extra)
+	}
+	return backends
+}
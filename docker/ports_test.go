@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestCandidatePorts_Order(t *testing.T) {
+	info := createMockContainerJSON("abc", "test", "", "", 0, "roji")
+	info.Config.ExposedPorts = nat.PortSet{
+		"9229/tcp": {},
+		"9000/tcp": {},
+		"8080/tcp": {},
+		"53/udp":   {},
+	}
+
+	got := candidatePorts(info)
+	want := []int{8080, 9000, 9229}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("candidatePorts() = %v, want %v", got, want)
+	}
+}
+
+func TestProbeListening(t *testing.T) {
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == "172.18.0.2:9229" {
+			c1, c2 := net.Pipe()
+			c2.Close()
+			return c1, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	got := probeListening(dial, "172.18.0.2", []int{8080, 9229})
+	if !reflect.DeepEqual(got, []int{9229}) {
+		t.Errorf("probeListening() = %v, want [9229]", got)
+	}
+}
+
+func TestClient_detectPort_Probing(t *testing.T) {
+	info := createMockContainerJSON("abc", "test", "", "", 0, "roji")
+	info.Config.ExposedPorts = nat.PortSet{
+		"8080/tcp": {},
+		"9000/tcp": {},
+	}
+
+	client := NewClientWithAPI(&mockDockerAPI{}, "roji", "localhost", WithPortProbing(true))
+	client.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == "172.18.0.2:9000" {
+			c1, c2 := net.Pipe()
+			c2.Close()
+			return c1, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	if got := client.detectPort(info, "172.18.0.2"); got != 9000 {
+		t.Errorf("detectPort() with probing = %d, want 9000 (only listening port)", got)
+	}
+
+	// Without probing, the preferred HTTP port wins
+	client.probePorts = false
+	if got := client.detectPort(info, "172.18.0.2"); got != 8080 {
+		t.Errorf("detectPort() without probing = %d, want 8080", got)
+	}
+}
+
+func TestPortHostname(t *testing.T) {
+	tests := []struct {
+		hostname string
+		port     int
+		expected string
+	}{
+		{"web.app.localhost", 9229, "web-9229.app.localhost"},
+		{"app.localhost", 8081, "app-8081.localhost"},
+		{"localhost", 3000, "localhost-3000"},
+	}
+
+	for _, tt := range tests {
+		if got := portHostname(tt.hostname, tt.port); got != tt.expected {
+			t.Errorf("portHostname(%q, %d) = %q, want %q", tt.hostname, tt.port, got, tt.expected)
+		}
+	}
+}
+
+func TestClient_GetBackends_AllPorts(t *testing.T) {
+	info := createMockContainerJSON("abc123", "app-web-1", "web", "app", 0, "roji")
+	info.Config.ExposedPorts = nat.PortSet{
+		"3000/tcp": {},
+		"9229/tcp": {},
+	}
+	info.Config.Labels["roji.all-ports"] = "true"
+
+	mock := &mockDockerAPI{
+		containers: []types.Container{createMockContainer("abc123", "app-web-1", "web", "app", 0, "roji")},
+		inspectMap: map[string]types.ContainerJSON{"abc123": info},
+	}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	backends, err := client.GetBackends(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetBackends() error = %v", err)
+	}
+	if len(backends) != 2 {
+		t.Fatalf("GetBackends() = %d backends, want 2", len(backends))
+	}
+	if backends[0].Hostname != "app.localhost" || backends[0].Port != 3000 {
+		t.Errorf("primary = %s:%d, want app.localhost:3000", backends[0].Hostname, backends[0].Port)
+	}
+	if backends[1].Hostname != "app-9229.localhost" || backends[1].Port != 9229 {
+		t.Errorf("secondary = %s:%d, want app-9229.localhost:9229", backends[1].Hostname, backends[1].Port)
+	}
+
+	// Label can opt out even when the flag enables all ports
+	info.Config.Labels["roji.all-ports"] = "false"
+	mock.inspectMap["abc123"] = info
+	client = NewClientWithAPI(mock, "roji", "localhost", WithAllPorts(true))
+	backends, _ = client.GetBackends(context.Background(), "abc123")
+	if len(backends) != 1 {
+		t.Errorf("GetBackends() with roji.all-ports=false = %d backends, want 1", len(backends))
+	}
+}
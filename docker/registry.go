@@ -0,0 +1,200 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReservationSource loads a team's shared hostname reservations.
+// Reservations map a hostname to the service that owns it ("project/service"
+// for compose services, the container name otherwise).
+type ReservationSource interface {
+	Reservations(ctx context.Context) (map[string]string, error)
+}
+
+// NewReservationSource returns a source for an http(s):// URL or a file path
+// (e.g., a file in a shared git repository)
+func NewReservationSource(location string) ReservationSource {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return &httpReservations{url: location, client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	return fileReservations(location)
+}
+
+// httpReservations fetches the registry file from a web server
+type httpReservations struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpReservations) Reservations(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch hostname registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch hostname registry: unexpected status %d", resp.StatusCode)
+	}
+	return parseReservations(resp.Body)
+}
+
+// fileReservations reads the registry from a local file
+type fileReservations string
+
+func (s fileReservations) Reservations(ctx context.Context) (map[string]string, error) {
+	f, err := os.Open(string(s))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hostname registry: %w", err)
+	}
+	defer f.Close()
+	return parseReservations(f)
+}
+
+// parseReservations parses one "hostname owner" pair per line. Blank lines
+// and lines starting with # are ignored.
+//
+//	# hostname      owner
+//	api             shop/api
+//	admin.shop      shop/admin
+func parseReservations(r io.Reader) (map[string]string, error) {
+	reservations := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("hostname registry line %d: want \"hostname owner\", got %q", line, text)
+		}
+		reservations[strings.ToLower(fields[0])] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read hostname registry: %w", err)
+	}
+	return reservations, nil
+}
+
+// hostnameRegistry holds the latest reservations and the warnings already
+// logged for them
+type hostnameRegistry struct {
+	source ReservationSource
+
+	mu       sync.Mutex
+	byHost   map[string]string // hostname -> owner
+	byOwner  map[string]string // owner -> hostname
+	warnings map[string]bool   // already-logged warnings
+}
+
+// WithHostnameRegistry consults a shared registry before using auto-generated
+// hostnames. Registry hostnames are relative to the base domain unless they
+// already end with it, so team members can use different domains.
+func WithHostnameRegistry(source ReservationSource) ClientOption {
+	return func(c *Client) {
+		c.registry = &hostnameRegistry{source: source, warnings: make(map[string]bool)}
+	}
+}
+
+// RefreshHostnameRegistry reloads the shared hostname registry and reports
+// whether the reservations changed. It is a no-op when no registry is configured.
+func (c *Client) RefreshHostnameRegistry(ctx context.Context) (bool, error) {
+	if c.registry == nil {
+		return false, nil
+	}
+
+	reservations, err := c.registry.source.Reservations(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	byHost := make(map[string]string, len(reservations))
+	byOwner := make(map[string]string, len(reservations))
+	for hostname, owner := range reservations {
+		if hostname != c.baseDomain && !strings.HasSuffix(hostname, "."+c.baseDomain) {
+			hostname += "." + c.baseDomain
+		}
+		byHost[hostname] = owner
+		byOwner[owner] = hostname
+	}
+
+	r := c.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if maps.Equal(r.byHost, byHost) {
+		return false, nil
+	}
+	r.byHost = byHost
+	r.byOwner = byOwner
+	r.warnings = make(map[string]bool)
+
+	slog.Info("loaded hostname registry", "reservations", len(byHost))
+	return true, nil
+}
+
+// avoid returns hostname, or an alternative when the registry reserves it
+// for another service (web.app.localhost -> web-local.app.localhost)
+func (r *hostnameRegistry) avoid(key, hostname string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	owner, ok := r.byHost[hostname]
+	if !ok || owner == key {
+		return hostname
+	}
+
+	label, rest, _ := strings.Cut(hostname, ".")
+	alternative := label + "-local"
+	if rest != "" {
+		alternative += "." + rest
+	}
+	r.warnOnce("avoid:"+key+"\x00"+hostname, "hostname reserved in team registry, using an alternative",
+		"service", key,
+		"hostname", hostname,
+		"reserved_for", owner,
+		"using", alternative)
+	return alternative
+}
+
+// check warns when a service's hostname diverges from the registry
+func (r *hostnameRegistry) check(key, hostname string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if owner, ok := r.byHost[hostname]; ok && owner != key {
+		r.warnOnce("taken:"+key+"\x00"+hostname, "hostname is reserved for another service in team registry",
+			"service", key,
+			"hostname", hostname,
+			"reserved_for", owner)
+	}
+	if reserved, ok := r.byOwner[key]; ok && reserved != hostname {
+		r.warnOnce("diverge:"+key+"\x00"+hostname, "hostname differs from team registry",
+			"service", key,
+			"hostname", hostname,
+			"registry", reserved)
+	}
+}
+
+// warnOnce logs a warning the first time id is seen since the last refresh
+func (r *hostnameRegistry) warnOnce(id, msg string, args ...any) {
+	if r.warnings[id] {
+		return
+	}
+	r.warnings[id] = true
+	slog.Warn(msg, args...)
+}
@@ -0,0 +1,102 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// staticReservations is a ReservationSource backed by a map
+type staticReservations map[string]string
+
+func (s staticReservations) Reservations(ctx context.Context) (map[string]string, error) {
+	return s, nil
+}
+
+func TestParseReservations(t *testing.T) {
+	got, err := parseReservations(strings.NewReader(`
+# hostname   owner
+api          shop/api
+Admin.Shop   shop/admin
+`))
+	if err != nil {
+		t.Fatalf("parseReservations() error = %v", err)
+	}
+	if len(got) != 2 || got["api"] != "shop/api" || got["admin.shop"] != "shop/admin" {
+		t.Errorf("parseReservations() = %v", got)
+	}
+
+	if _, err := parseReservations(strings.NewReader("api")); err == nil {
+		t.Error("parseReservations() should reject lines without an owner")
+	}
+}
+
+func TestReservationSources(t *testing.T) {
+	const registry = "api shop/api\n"
+
+	path := filepath.Join(t.TempDir(), "hostnames.txt")
+	if err := os.WriteFile(path, []byte(registry), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(registry))
+	}))
+	defer server.Close()
+
+	for _, location := range []string{path, server.URL} {
+		got, err := NewReservationSource(location).Reservations(context.Background())
+		if err != nil {
+			t.Fatalf("Reservations(%q) error = %v", location, err)
+		}
+		if got["api"] != "shop/api" {
+			t.Errorf("Reservations(%q) = %v", location, got)
+		}
+	}
+}
+
+func TestClient_HostnameRegistry(t *testing.T) {
+	client := NewClientWithAPI(&mockDockerAPI{}, "roji", "localhost",
+		WithHostnameRegistry(staticReservations{
+			"shop":     "shop/web",
+			"blog":     "blog/web",
+			"api.shop": "shop/api",
+		}))
+
+	changed, err := client.RefreshHostnameRegistry(context.Background())
+	if err != nil || !changed {
+		t.Fatalf("RefreshHostnameRegistry() = %v, %v; want true, nil", changed, err)
+	}
+	if changed, _ := client.RefreshHostnameRegistry(context.Background()); changed {
+		t.Error("RefreshHostnameRegistry() should report no change for identical reservations")
+	}
+
+	// The owner gets its reserved hostname
+	web := createMockContainerJSON("a", "shop-web-1", "web", "shop", 80, "roji")
+	if got := client.detectHostname(web, map[string]int{"shop": 1}); got != "shop.localhost" {
+		t.Errorf("owner hostname = %q, want %q", got, "shop.localhost")
+	}
+
+	// Another service avoids a reserved hostname
+	other := createMockContainerJSON("b", "blog-app-1", "app", "blog", 80, "roji")
+	if got := client.detectHostname(other, map[string]int{"blog": 1}); got != "blog-local.localhost" {
+		t.Errorf("hostname = %q, want %q", got, "blog-local.localhost")
+	}
+
+	// Unreserved hostnames are left alone
+	standalone := createMockContainerJSON("c", "wiki", "", "", 80, "roji")
+	if got := client.detectHostname(standalone, nil); got != "wiki.localhost" {
+		t.Errorf("hostname = %q, want %q", got, "wiki.localhost")
+	}
+}
+
+func TestClient_HostnameRegistry_Disabled(t *testing.T) {
+	client := NewClientWithAPI(&mockDockerAPI{}, "roji", "localhost")
+	changed, err := client.RefreshHostnameRegistry(context.Background())
+	if changed || err != nil {
+		t.Errorf("RefreshHostnameRegistry() = %v, %v; want false, nil", changed, err)
+	}
+}
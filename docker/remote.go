@@ -0,0 +1,206 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+)
+
+// AddressPublished dials backends through their published ports on the Docker
+// host, for remote daemons whose container IPs are not reachable from roji and
+// for roji running on the host of Docker Desktop (macOS/Windows), where bridge
+// IPs live inside a VM
+const AddressPublished = "published"
+
+// WithPublishedHost sets the host dialed in published-port addressing mode.
+// By default it is derived from DOCKER_HOST.
+func WithPublishedHost(host string) ClientOption {
+	return func(c *Client) {
+		c.publishedHost = host
+	}
+}
+
+// remoteClientOpts returns extra Docker client options for daemon hosts the
+// Docker SDK cannot dial by itself (ssh://). tcp:// with TLS is handled by
+// client.FromEnv via DOCKER_TLS_VERIFY and DOCKER_CERT_PATH.
+func remoteClientOpts(dockerHost string) ([]client.Opt, error) {
+	if !strings.HasPrefix(dockerHost, "ssh://") {
+		return nil, nil
+	}
+
+	args, err := sshArgs(dockerHost)
+	if err != nil {
+		return nil, err
+	}
+	return []client.Opt{
+		// The host is a placeholder; every connection goes through the dialer
+		client.WithHost("http://docker.example.com"),
+		client.WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return newCommandConn("ssh", args...)
+		}),
+	}, nil
+}
+
+// sshArgs builds the ssh command line that bridges to the remote daemon with
+// `docker system dial-stdio` (the same mechanism the docker CLI uses)
+func sshArgs(dockerHost string) ([]string, error) {
+	u, err := url.Parse(dockerHost)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCKER_HOST %q: %w", dockerHost, err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid DOCKER_HOST %q: missing host", dockerHost)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("invalid DOCKER_HOST %q: paths are not supported", dockerHost)
+	}
+
+	args := []string{"-o", "ConnectTimeout=30", "-o", "BatchMode=yes"}
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	return append(args, "--", u.Hostname(), "docker", "system", "dial-stdio"), nil
+}
+
+// dockerHostname returns the hostname of a remote DOCKER_HOST, or "" for local sockets
+func dockerHostname(dockerHost string) string {
+	u, err := url.Parse(dockerHost)
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "tcp", "ssh", "http", "https":
+		return u.Hostname()
+	}
+	return ""
+}
+
+// commandConn is a net.Conn over a subprocess's stdin/stdout
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func newCommandConn(name string, args ...string) (net.Conn, error) {
+	// Not bound to the dial context: the connection outlives the dial
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+func (c *commandConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *commandConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	c.stdout.Close()
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+	c.cmd.Wait()
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return commandAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr { return commandAddr{} }
+
+// Deadlines are not supported on pipes; the Docker client relies on contexts
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string { return "command" }
+func (commandAddr) String() string  { return "command" }
+
+// publishPorts rewrites backends to dial the Docker host's published port for
+// their container port. Backends whose port is not published are dropped.
+func (c *Client) publishPorts(info types.ContainerJSON, backends []*Backend) []*Backend {
+	if !c.published {
+		return backends
+	}
+
+	// On the Docker host itself, loopback-only bindings (127.0.0.1:8080:80) work too
+	local := isLoopbackHost(c.publishedHost)
+
+	var result []*Backend
+	for _, b := range backends {
+		hostIP, hostPort := publishedPort(info, b.Port, local)
+		if hostPort == 0 {
+			slog.Warn("container port is not published, skipping (publish it or use --backend-address=ip)",
+				"container", shortID(info.ID),
+				"name", info.Name,
+				"port", b.Port)
+			continue
+		}
+		b.Host = c.publishedHost
+		if hostIP != "" {
+			b.Host = hostIP
+		}
+		b.Port = hostPort
+		result = append(result, b)
+	}
+	return result
+}
+
+// publishedPort returns the host port a container TCP port is published on
+// (0 if none). Loopback bindings are only used when local is set, and then
+// their address is returned so the exact binding (127.0.0.1 or ::1) is dialed.
+func publishedPort(info types.ContainerJSON, port int, local bool) (string, int) {
+	if info.NetworkSettings == nil {
+		return "", 0
+	}
+	for _, binding := range info.NetworkSettings.Ports[nat.Port(strconv.Itoa(port)+"/tcp")] {
+		hostPort, err := strconv.Atoi(binding.HostPort)
+		if err != nil || hostPort <= 0 {
+			continue
+		}
+		// Bindings to loopback are unreachable from another machine
+		if ip := net.ParseIP(binding.HostIP); ip != nil && ip.IsLoopback() {
+			if !local {
+				continue
+			}
+			return ip.String(), hostPort
+		}
+		return "", hostPort
+	}
+	return "", 0
+}
+
+// isLoopbackHost reports whether host names the local machine
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
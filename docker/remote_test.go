@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestSSHArgs(t *testing.T) {
+	args, err := sshArgs("ssh://dev@build.example.com:2222")
+	if err != nil {
+		t.Fatalf("sshArgs() error = %v", err)
+	}
+	want := []string{
+		"-o", "ConnectTimeout=30", "-o", "BatchMode=yes",
+		"-l", "dev", "-p", "2222",
+		"--", "build.example.com", "docker", "system", "dial-stdio",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("sshArgs() = %v, want %v", args, want)
+	}
+
+	if _, err := sshArgs("ssh://build.example.com/var/run/docker.sock"); err == nil {
+		t.Error("sshArgs() should reject paths")
+	}
+}
+
+func TestDockerHostname(t *testing.T) {
+	tests := map[string]string{
+		"ssh://dev@build.example.com": "build.example.com",
+		"tcp://10.0.0.5:2376":         "10.0.0.5",
+		"unix:///var/run/docker.sock": "",
+		"":                            "",
+	}
+	for host, expected := range tests {
+		if got := dockerHostname(host); got != expected {
+			t.Errorf("dockerHostname(%q) = %q, want %q", host, got, expected)
+		}
+	}
+}
+
+func TestCommandConn(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+
+	conn, err := newCommandConn("cat")
+	if err != nil {
+		t.Fatalf("newCommandConn() error = %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("read %q, want %q", buf, "ping")
+	}
+}
+
+func TestClient_PublishedAddressing(t *testing.T) {
+	published := createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji")
+	published.NetworkSettings.Ports = nat.PortMap{
+		"80/tcp": {
+			{HostIP: "127.0.0.1", HostPort: "9080"}, // loopback-only, unreachable remotely
+			{HostIP: "0.0.0.0", HostPort: "8080"},
+		},
+	}
+	unpublished := createMockContainerJSON("def456", "shop-api-1", "api", "shop", 80, "roji")
+
+	mock := &mockDockerAPI{inspectMap: map[string]types.ContainerJSON{
+		"abc123": published,
+		"def456": unpublished,
+	}}
+	client := NewClientWithAPI(mock, "roji", "localhost",
+		WithBackendAddressing(AddressPublished),
+		WithPublishedHost("build.example.com"))
+
+	backend, err := client.GetBackend(context.Background(), "abc123")
+	if err != nil || backend == nil {
+		t.Fatalf("GetBackend() = %v, %v", backend, err)
+	}
+	if backend.Address() != "build.example.com:8080" {
+		t.Errorf("Address() = %q, want %q", backend.Address(), "build.example.com:8080")
+	}
+
+	backend, err = client.GetBackend(context.Background(), "def456")
+	if err != nil || backend != nil {
+		t.Errorf("GetBackend() = %v, %v for an unpublished port, want nil", backend, err)
+	}
+}
+
+func TestClient_PublishedAddressing_Local(t *testing.T) {
+	// roji on the Docker Desktop host: loopback-only bindings are reachable
+	ctr := createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji")
+	ctr.NetworkSettings.Ports = nat.PortMap{
+		"80/tcp": {{HostIP: "::1", HostPort: "9080"}},
+	}
+
+	mock := &mockDockerAPI{inspectMap: map[string]types.ContainerJSON{"abc123": ctr}}
+	client := NewClientWithAPI(mock, "roji", "localhost",
+		WithBackendAddressing(AddressPublished),
+		WithPublishedHost("127.0.0.1"))
+
+	backend, err := client.GetBackend(context.Background(), "abc123")
+	if err != nil || backend == nil {
+		t.Fatalf("GetBackend() = %v, %v", backend, err)
+	}
+	if backend.Address() != "[::1]:9080" {
+		t.Errorf("Address() = %q, want %q", backend.Address(), "[::1]:9080")
+	}
+}
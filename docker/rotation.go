@@ -0,0 +1,67 @@
+package docker
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hostnameGenerations tracks origin rotations: each rotation of a hostname
+// bumps its generation, giving the browser a brand-new origin with no
+// cookies, localStorage or service workers
+type hostnameGenerations struct {
+	mu   sync.Mutex
+	gens map[string]int // key: base hostname (lowercase)
+}
+
+func newHostnameGenerations() *hostnameGenerations {
+	return &hostnameGenerations{gens: make(map[string]int)}
+}
+
+// generationHostname suffixes the first label of hostname with its generation:
+// ("web.app.localhost", 2) -> "web-g2.app.localhost"
+func generationHostname(hostname string, gen int) string {
+	if gen == 0 {
+		return hostname
+	}
+	suffix := "-g" + strconv.Itoa(gen)
+	first, rest, found := strings.Cut(hostname, ".")
+	if !found {
+		return hostname + suffix
+	}
+	return first + suffix + "." + rest
+}
+
+// apply returns the current generation of hostname
+func (g *hostnameGenerations) apply(hostname string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return generationHostname(hostname, g.gens[strings.ToLower(hostname)])
+}
+
+// rotate bumps the generation of hostname, which may be either the base
+// hostname or its current rotated form, and returns the new hostname
+func (g *hostnameGenerations) rotate(hostname string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	base := strings.ToLower(hostname)
+	for b, gen := range g.gens {
+		if generationHostname(b, gen) == base {
+			base = b
+			break
+		}
+	}
+
+	g.gens[base]++
+	return generationHostname(base, g.gens[base])
+}
+
+// RotateHostname moves a route to a fresh origin by bumping its hostname
+// generation (e.g., web.app.localhost -> web-g1.app.localhost). hostname may
+// be the original or the currently rotated hostname. The new hostname takes
+// effect on the next discovery.
+func (c *Client) RotateHostname(hostname string) string {
+	return c.generations.rotate(hostname)
+}
@@ -0,0 +1,58 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestGenerationHostname(t *testing.T) {
+	tests := []struct {
+		hostname string
+		gen      int
+		expected string
+	}{
+		{"web.app.localhost", 0, "web.app.localhost"},
+		{"web.app.localhost", 2, "web-g2.app.localhost"},
+		{"localhost", 1, "localhost-g1"},
+	}
+
+	for _, tt := range tests {
+		if got := generationHostname(tt.hostname, tt.gen); got != tt.expected {
+			t.Errorf("generationHostname(%q, %d) = %q, want %q", tt.hostname, tt.gen, got, tt.expected)
+		}
+	}
+}
+
+func TestClient_RotateHostname(t *testing.T) {
+	info := createMockContainerJSON("abc123", "shop-web-1", "web", "shop", 80, "roji")
+	mock := &mockDockerAPI{inspectMap: map[string]types.ContainerJSON{"abc123": info}}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	backend, err := client.GetBackend(context.Background(), "abc123")
+	if err != nil || backend == nil {
+		t.Fatalf("GetBackend() = %v, %v", backend, err)
+	}
+	original := backend.Hostname
+
+	first := client.RotateHostname(original)
+	if first == original {
+		t.Fatalf("RotateHostname() returned the original hostname %q", first)
+	}
+
+	backend, _ = client.GetBackend(context.Background(), "abc123")
+	if backend.Hostname != first {
+		t.Errorf("Hostname = %q after rotation, want %q", backend.Hostname, first)
+	}
+
+	// Rotating the rotated hostname moves to the next generation
+	second := client.RotateHostname(first)
+	if second == first || second == original {
+		t.Errorf("second rotation = %q, want a new hostname", second)
+	}
+	backend, _ = client.GetBackend(context.Background(), "abc123")
+	if backend.Hostname != second {
+		t.Errorf("Hostname = %q after second rotation, want %q", backend.Hostname, second)
+	}
+}
@@ -0,0 +1,30 @@
+package docker
+
+import "github.com/kan/roji/config"
+
+// staticRouteService is the service name shown for static routes
+const staticRouteService = "static"
+
+// WithStaticRoutes serves local directories under hostnames. Like host
+// routes, they are returned by DiscoverBackends alongside containers.
+func WithStaticRoutes(routes []config.StaticRoute) ClientOption {
+	return func(c *Client) {
+		c.staticRoutes = routes
+	}
+}
+
+// staticBackends returns the backends of the configured static routes
+func (c *Client) staticBackends() []*Backend {
+	backends := make([]*Backend, 0, len(c.staticRoutes))
+	for _, route := range c.staticRoutes {
+		backends = append(backends, &Backend{
+			ContainerID:   staticRouteService + ":" + route.Hostname,
+			ContainerName: route.Dir,
+			ServiceName:   staticRouteService,
+			Hostname:      route.Hostname,
+			StaticDir:     route.Dir,
+			StaticSPA:     route.SPA,
+		})
+	}
+	return backends
+}
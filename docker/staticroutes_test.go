@@ -0,0 +1,25 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kan/roji/config"
+)
+
+func TestClient_DiscoverBackends_StaticRoutes(t *testing.T) {
+	routes := []config.StaticRoute{{Hostname: "app.localhost", Dir: "/srv/app", SPA: true}}
+	client := NewClientWithAPI(&mockDockerAPI{}, "roji", "localhost", WithStaticRoutes(routes))
+
+	backends, err := client.DiscoverBackends(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverBackends() error = %v", err)
+	}
+	if len(backends) != 1 {
+		t.Fatalf("DiscoverBackends() returned %d backends, want 1", len(backends))
+	}
+	b := backends[0]
+	if b.Hostname != "app.localhost" || b.StaticDir != "/srv/app" || !b.StaticSPA || b.ContainerID != "static:app.localhost" {
+		t.Errorf("static backend = %+v", b)
+	}
+}
@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// waitInitialBackoff is the delay before the first retry
+	waitInitialBackoff = time.Second
+	// waitMaxBackoff caps the delay between retries
+	waitMaxBackoff = 15 * time.Second
+)
+
+// Ping checks once that the Docker daemon responds
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := c.docker.Ping(ctx)
+	return err
+}
+
+// WaitForDaemon pings the Docker daemon until it responds, retrying with
+// exponential backoff for up to maxWait (e.g., while Docker Desktop boots).
+// onRetry is called after each failed attempt with the delay until the next.
+func (c *Client) WaitForDaemon(ctx context.Context, maxWait time.Duration, onRetry func(err error, next time.Duration)) error {
+	deadline := time.Now().Add(maxWait)
+	backoff := waitInitialBackoff
+
+	for {
+		err := c.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("docker daemon not reachable after %s: %w", maxWait, err)
+		}
+		next := min(backoff, remaining)
+		if onRetry != nil {
+			onRetry(err, next)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(next):
+		}
+		backoff = min(backoff*2, waitMaxBackoff)
+	}
+}
@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestClient_WaitForDaemon(t *testing.T) {
+	attempts := 0
+	mock := &mockDockerAPI{ping: func(ctx context.Context) (types.Ping, error) {
+		attempts++
+		if attempts < 2 {
+			return types.Ping{}, errors.New("connection refused")
+		}
+		return types.Ping{}, nil
+	}}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	retries := 0
+	err := client.WaitForDaemon(context.Background(), 10*time.Second, func(err error, next time.Duration) {
+		retries++
+		if next != waitInitialBackoff {
+			t.Errorf("first retry delay = %s, want %s", next, waitInitialBackoff)
+		}
+	})
+	if err != nil {
+		t.Fatalf("WaitForDaemon() error = %v", err)
+	}
+	if attempts != 2 || retries != 1 {
+		t.Errorf("attempts = %d, retries = %d; want 2, 1", attempts, retries)
+	}
+}
+
+func TestClient_WaitForDaemon_GivesUp(t *testing.T) {
+	mock := &mockDockerAPI{ping: func(ctx context.Context) (types.Ping, error) {
+		return types.Ping{}, errors.New("connection refused")
+	}}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	// No wait: fail after the first attempt
+	if err := client.WaitForDaemon(context.Background(), 0, nil); err == nil {
+		t.Fatal("WaitForDaemon() should fail when the daemon never responds")
+	}
+}
+
+func TestClient_WaitForDaemon_Cancelled(t *testing.T) {
+	mock := &mockDockerAPI{ping: func(ctx context.Context) (types.Ping, error) {
+		return types.Ping{}, errors.New("connection refused")
+	}}
+	client := NewClientWithAPI(mock, "roji", "localhost")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := client.WaitForDaemon(ctx, time.Minute, func(error, time.Duration) { cancel() })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitForDaemon() error = %v, want context.Canceled", err)
+	}
+}
@@ -3,6 +3,8 @@ package docker
 import (
 	"context"
 	"log/slog"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/docker/api/types/events"
@@ -15,9 +17,15 @@ type EventType int
 const (
 	EventStart EventType = iota
 	EventStop
+	// EventRestart is a completed restart; the container may have a new address
+	EventRestart
+	// EventRefresh means the container may have changed state or name
+	// without stopping (kill with a non-fatal signal, OOM in a child
+	// process, rename, pause/unpause)
+	EventRefresh
 )
 
-// ContainerEvent represents a container start/stop event
+// ContainerEvent represents a container lifecycle event
 type ContainerEvent struct {
 	Type        EventType
 	ContainerID string
@@ -25,7 +33,8 @@ type ContainerEvent struct {
 
 // Watcher watches for container events on the shared network
 type Watcher struct {
-	client *Client
+	client    *Client
+	connected atomic.Bool // true while an Events stream is open
 }
 
 // NewWatcher creates a new container watcher
@@ -33,6 +42,11 @@ func NewWatcher(client *Client) *Watcher {
 	return &Watcher{client: client}
 }
 
+// Connected reports whether the Docker event stream is currently connected
+func (w *Watcher) Connected() bool {
+	return w.connected.Load()
+}
+
 // Watch starts watching for container events and returns a channel of events.
 // Automatically reconnects if the connection is lost.
 func (w *Watcher) Watch(ctx context.Context) <-chan ContainerEvent {
@@ -70,10 +84,18 @@ func (w *Watcher) watchLoop(ctx context.Context, eventCh chan<- ContainerEvent)
 	filterArgs.Add("event", "start")
 	filterArgs.Add("event", "stop")
 	filterArgs.Add("event", "die")
+	filterArgs.Add("event", "restart")
+	filterArgs.Add("event", "kill")
+	filterArgs.Add("event", "oom")
+	filterArgs.Add("event", "rename")
+	filterArgs.Add("event", "pause")
+	filterArgs.Add("event", "unpause")
 
 	msgCh, errCh := w.client.DockerClient().Events(ctx, events.ListOptions{
 		Filters: filterArgs,
 	})
+	w.connected.Store(true)
+	defer w.connected.Store(false)
 
 	for {
 		select {
@@ -102,6 +124,9 @@ func (w *Watcher) watchLoop(ctx context.Context, eventCh chan<- ContainerEvent)
 func (w *Watcher) processEvent(msg events.Message) *ContainerEvent {
 	containerID := msg.Actor.ID
 
+	// The container's state changed; never serve a stale inspect result for it
+	w.client.InvalidateContainer(containerID)
+
 	switch msg.Action {
 	case "start":
 		slog.Debug("container started",
@@ -120,6 +145,47 @@ func (w *Watcher) processEvent(msg events.Message) *ContainerEvent {
 			Type:        EventStop,
 			ContainerID: containerID,
 		}
+
+	case "restart":
+		slog.Debug("container restarted",
+			"container", shortID(containerID),
+			"name", msg.Actor.Attributes["name"])
+		return &ContainerEvent{
+			Type:        EventRestart,
+			ContainerID: containerID,
+		}
+
+	case "kill", "oom":
+		slog.Debug("container signalled",
+			"container", shortID(containerID),
+			"name", msg.Actor.Attributes["name"],
+			"action", msg.Action)
+		return &ContainerEvent{
+			Type:        EventRefresh,
+			ContainerID: containerID,
+		}
+
+	case "pause", "unpause":
+		// Paused backends stay routed but answer with a placeholder page
+		slog.Debug("container pause state changed",
+			"container", shortID(containerID),
+			"name", msg.Actor.Attributes["name"],
+			"action", msg.Action)
+		return &ContainerEvent{
+			Type:        EventRefresh,
+			ContainerID: containerID,
+		}
+
+	case "rename":
+		// Hostnames of standalone containers derive from the name
+		slog.Debug("container renamed",
+			"container", shortID(containerID),
+			"old_name", strings.TrimPrefix(msg.Actor.Attributes["oldName"], "/"),
+			"name", msg.Actor.Attributes["name"])
+		return &ContainerEvent{
+			Type:        EventRefresh,
+			ContainerID: containerID,
+		}
 	}
 
 	return nil
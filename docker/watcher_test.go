@@ -1,7 +1,10 @@
 package docker
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types/events"
 )
@@ -69,6 +72,92 @@ func TestWatcher_processEvent(t *testing.T) {
 			wantEvent: true,
 			wantType:  EventStop,
 		},
+		{
+			name: "restart event",
+			msg: events.Message{
+				Action: "restart",
+				Actor: events.Actor{
+					ID: "abc123",
+					Attributes: map[string]string{
+						"name": "test-container",
+					},
+				},
+			},
+			wantEvent: true,
+			wantType:  EventRestart,
+		},
+		{
+			name: "kill event",
+			msg: events.Message{
+				Action: "kill",
+				Actor: events.Actor{
+					ID: "abc123",
+					Attributes: map[string]string{
+						"name":   "test-container",
+						"signal": "1",
+					},
+				},
+			},
+			wantEvent: true,
+			wantType:  EventRefresh,
+		},
+		{
+			name: "oom event",
+			msg: events.Message{
+				Action: "oom",
+				Actor: events.Actor{
+					ID: "abc123",
+					Attributes: map[string]string{
+						"name": "test-container",
+					},
+				},
+			},
+			wantEvent: true,
+			wantType:  EventRefresh,
+		},
+		{
+			name: "rename event",
+			msg: events.Message{
+				Action: "rename",
+				Actor: events.Actor{
+					ID: "abc123",
+					Attributes: map[string]string{
+						"name":    "new-name",
+						"oldName": "/old-name",
+					},
+				},
+			},
+			wantEvent: true,
+			wantType:  EventRefresh,
+		},
+		{
+			name: "pause event",
+			msg: events.Message{
+				Action: "pause",
+				Actor: events.Actor{
+					ID: "abc123",
+					Attributes: map[string]string{
+						"name": "test-container",
+					},
+				},
+			},
+			wantEvent: true,
+			wantType:  EventRefresh,
+		},
+		{
+			name: "unpause event",
+			msg: events.Message{
+				Action: "unpause",
+				Actor: events.Actor{
+					ID: "abc123",
+					Attributes: map[string]string{
+						"name": "test-container",
+					},
+				},
+			},
+			wantEvent: true,
+			wantType:  EventRefresh,
+		},
 		{
 			name: "unknown event",
 			msg: events.Message{
@@ -106,3 +195,39 @@ func TestWatcher_processEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestWatcher_Connected(t *testing.T) {
+	msgCh := make(chan events.Message)
+	errCh := make(chan error)
+	mock := &mockDockerAPI{
+		events: func(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+			return msgCh, errCh
+		},
+	}
+	watcher := NewWatcher(NewClientWithAPI(mock, "roji", "localhost"))
+
+	if watcher.Connected() {
+		t.Fatal("Connected() = true before watching")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher.Watch(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for !watcher.Connected() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !watcher.Connected() {
+		t.Fatal("Connected() = false while the event stream is open")
+	}
+
+	errCh <- errors.New("connection lost")
+	deadline = time.Now().Add(time.Second)
+	for watcher.Connected() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if watcher.Connected() {
+		t.Error("Connected() = true after the event stream failed")
+	}
+}
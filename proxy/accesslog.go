@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/kan/roji/config"
+)
+
+// WithAccessLogFields sets the fields of request log lines for routes
+// without roji.access-log-fields (--access-log-fields)
+func WithAccessLogFields(fields []string) HandlerOption {
+	return func(h *Handler) {
+		h.accessLogFields = fields
+	}
+}
+
+// WithSlowRequests logs a warning for requests slower than threshold and
+// counts them in /_api/metrics (--slow-request)
+func WithSlowRequests(threshold time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.slowRequest = threshold
+		h.metrics.slow = threshold
+	}
+}
+
+// accessLogEntry is one request for the access log
+type accessLogEntry struct {
+	host     string // requested hostname
+	status   int
+	duration time.Duration
+	target   string // service, directory, file or "mock" that answered
+}
+
+// logRequest writes the request log line, following the route's
+// roji.access-log* policy. Sampled routes still log every error (4xx/5xx),
+// and slow requests are logged whatever the policy.
+func (h *Handler) logRequest(policy config.AccessLogPolicy, r *http.Request, entry accessLogEntry) {
+	if h.slowRequest > 0 && entry.duration > h.slowRequest {
+		slog.Warn("slow request",
+			"method", r.Method,
+			"host", entry.host,
+			"path", r.URL.Path,
+			"status", entry.status,
+			"duration", entry.duration.Round(time.Millisecond),
+			"target", entry.target,
+			"threshold", h.slowRequest)
+	}
+	if policy.Off {
+		return
+	}
+	if policy.Sample > 0 && entry.status < 400 && rand.Float64() >= policy.Sample {
+		return
+	}
+
+	fields := policy.Fields
+	if fields == nil {
+		fields = h.accessLogFields
+	}
+	if fields == nil {
+		fields = config.DefaultAccessLogFields
+	}
+	args := make([]any, 0, 2*len(fields))
+	for _, field := range fields {
+		var value any
+		switch field {
+		case "method":
+			value = r.Method
+		case "host":
+			value = entry.host
+		case "path":
+			value = r.URL.Path
+		case "query":
+			value = r.URL.RawQuery
+		case "status":
+			value = entry.status
+		case "duration":
+			value = entry.duration.Round(time.Millisecond)
+		case "target":
+			value = entry.target
+		case "client":
+			value = r.RemoteAddr
+		case "user_agent":
+			value = r.UserAgent()
+		case "referer":
+			value = r.Referer()
+		default:
+			continue
+		}
+		args = append(args, field, value)
+	}
+	slog.Info("request", args...)
+}
@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kan/roji/config"
+)
+
+// captureLog sends log lines to a buffer for the rest of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// requestLines returns the access log lines of a captured log
+func requestLines(buf *bytes.Buffer) []string {
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "msg=request") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestHandler_AccessLogPolicy(t *testing.T) {
+	backend := newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+	get := func(path string) {
+		req := httptest.NewRequest("GET", "https://api.localhost"+path, nil)
+		req.Header.Set("User-Agent", "probe/1.0")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name   string
+		policy config.AccessLogPolicy
+		paths  []string
+		lines  int
+		want   []string // in every line
+		absent []string
+	}{
+		{"default", config.AccessLogPolicy{}, []string{"/"}, 1,
+			[]string{"method=GET", "host=api.localhost", "path=/", "status=200", "duration=", "target="}, []string{"user_agent"}},
+		{"off", config.AccessLogPolicy{Off: true}, []string{"/", "/fail"}, 0, nil, nil},
+		{"fields", config.AccessLogPolicy{Fields: []string{"path", "user_agent"}}, []string{"/x?q=1"}, 1,
+			[]string{"path=/x", "user_agent=probe/1.0"}, []string{"method=", "status="}},
+		// A tiny rate drops successes but keeps errors
+		{"sampled", config.AccessLogPolicy{Sample: 1e-9}, []string{"/", "/", "/", "/fail"}, 1,
+			[]string{"status=500"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend.AccessLog = tt.policy
+			buf := captureLog(t)
+			for _, path := range tt.paths {
+				get(path)
+			}
+			lines := requestLines(buf)
+			if len(lines) != tt.lines {
+				t.Fatalf("got %d request lines, want %d:\n%s", len(lines), tt.lines, buf)
+			}
+			for _, line := range lines {
+				for _, want := range tt.want {
+					if !strings.Contains(line, want) {
+						t.Errorf("line %q lacks %s", line, want)
+					}
+				}
+				for _, absent := range tt.absent {
+					if strings.Contains(line, absent) {
+						t.Errorf("line %q has %s", line, absent)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_AccessLogDefaultFields(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {}))
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithAccessLogFields([]string{"host", "query"}))
+
+	buf := captureLog(t)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://api.localhost/?page=2", nil))
+	lines := requestLines(buf)
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], `msg=request host=api.localhost query="page=2"`) {
+		t.Errorf("lines = %q, want host and query only", lines)
+	}
+}
+
+func TestHandler_SlowRequests(t *testing.T) {
+	router := NewRouter()
+	backend := newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(30 * time.Millisecond)
+		}
+	})
+	backend.ServiceName = "api"
+	backend.AccessLog = config.AccessLogPolicy{Off: true}
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithSlowRequests(20*time.Millisecond))
+
+	buf := captureLog(t)
+	for _, path := range []string{"/", "/slow"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "https://api.localhost"+path, nil))
+	}
+
+	// Logged even though the route's access log is off
+	var warnings []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, `msg="slow request"`) {
+			warnings = append(warnings, line)
+		}
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("got %d slow request warnings, want 1:\n%s", len(warnings), buf)
+	}
+	for _, want := range []string{"level=WARN", "method=POST", "path=/slow", "target=api", "threshold=20ms"} {
+		if !strings.Contains(warnings[0], want) {
+			t.Errorf("warning %q lacks %s", warnings[0], want)
+		}
+	}
+	if slow := handler.metrics.snapshot(time.Now()).Routes[0].Slow; slow != 1 {
+		t.Errorf("metrics slow = %d, want 1", slow)
+	}
+}
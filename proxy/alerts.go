@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Error-rate alert states
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// webhookTimeout bounds each alert webhook delivery
+const webhookTimeout = 5 * time.Second
+
+// ErrorAlertConfig controls when a route's 5xx responses raise an alert
+type ErrorAlertConfig struct {
+	Threshold   float64       // share of 5xx responses that fires an alert (0.5 = 50%)
+	Window      time.Duration // period the share is computed over, in 10-second steps up to 5 minutes
+	MinRequests int           // routes with fewer requests in the window never alert
+	Webhook     string        // optional; URL alerts are POSTed to as JSON
+	Desktop     bool          // show desktop notifications (roji running on the host)
+}
+
+// ErrorRateAlert is a route starting or stopping to fail; it is the JSON
+// body of alert webhooks
+type ErrorRateAlert struct {
+	State      string    `json:"state"` // AlertFiring or AlertResolved
+	Hostname   string    `json:"hostname"`
+	PathPrefix string    `json:"path_prefix,omitempty"`
+	Requests   int       `json:"requests"` // in the window
+	Errors     int       `json:"errors"`   // 5xx responses and proxy errors in the window
+	Rate       float64   `json:"rate"`
+	Threshold  float64   `json:"threshold"`
+	Window     string    `json:"window"`
+	Time       time.Time `json:"time"`
+}
+
+// Summary describes the alert in one line
+func (a ErrorRateAlert) Summary() string {
+	if a.State == AlertResolved {
+		return fmt.Sprintf("%s%s recovered: %d of %d requests failed in the last %s", a.Hostname, a.PathPrefix, a.Errors, a.Requests, a.Window)
+	}
+	return fmt.Sprintf("%s%s is failing: %d of %d requests (%.0f%%) answered 5xx in the last %s", a.Hostname, a.PathPrefix, a.Errors, a.Requests, a.Rate*100, a.Window)
+}
+
+// errorAlerter remembers which routes are over the threshold, so each
+// route alerts once when it starts failing and once when it recovers
+type errorAlerter struct {
+	cfg    ErrorAlertConfig
+	client *http.Client
+	notify func(title, message string) error
+
+	mu     sync.Mutex
+	firing map[string]bool // key: hostname + path prefix
+}
+
+// WithErrorAlerts logs a warning, and optionally calls a webhook and shows a
+// desktop notification, when a route's share of 5xx responses reaches
+// the threshold (--error-alert-*). CheckErrorRates evaluates the routes.
+func WithErrorAlerts(cfg ErrorAlertConfig) HandlerOption {
+	return func(h *Handler) {
+		h.errorAlerts = &errorAlerter{
+			cfg:    cfg,
+			client: &http.Client{Timeout: webhookTimeout},
+			notify: notifyDesktop,
+			firing: make(map[string]bool),
+		}
+	}
+}
+
+// CheckErrorRates compares each route's share of 5xx responses over the
+// alert window with the threshold. Routes that start or stop exceeding it
+// are alerted about and returned.
+func (h *Handler) CheckErrorRates(now time.Time) []ErrorRateAlert {
+	a := h.errorAlerts
+	if a == nil || a.cfg.Threshold <= 0 {
+		return nil
+	}
+	window := windowText(a.cfg.Window)
+
+	a.mu.Lock()
+	var alerts []ErrorRateAlert
+	for _, re := range h.metrics.windowErrors(now, a.cfg.Window) {
+		key := re.hostname + re.pathPrefix
+		alert := ErrorRateAlert{
+			Hostname:   re.hostname,
+			PathPrefix: re.pathPrefix,
+			Requests:   re.requests,
+			Errors:     re.errors,
+			Threshold:  a.cfg.Threshold,
+			Window:     window,
+			Time:       now,
+		}
+		if re.requests > 0 {
+			alert.Rate = float64(re.errors) / float64(re.requests)
+		}
+		failing := re.requests >= a.cfg.MinRequests && re.requests > 0 && alert.Rate >= a.cfg.Threshold
+		switch {
+		case failing && !a.firing[key]:
+			a.firing[key] = true
+			alert.State = AlertFiring
+		case !failing && a.firing[key]:
+			delete(a.firing, key)
+			alert.State = AlertResolved
+		default:
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	a.mu.Unlock()
+
+	for _, alert := range alerts {
+		a.deliver(alert)
+		h.events.Publish(Event{Type: EventErrorRate, Hostname: alert.Hostname, PathPrefix: alert.PathPrefix, Alert: &alert})
+	}
+	return alerts
+}
+
+// deliver sends an alert to the log, the webhook and the desktop
+func (a *errorAlerter) deliver(alert ErrorRateAlert) {
+	if alert.State == AlertFiring {
+		slog.Warn("error rate alert",
+			"hostname", alert.Hostname+alert.PathPrefix,
+			"errors", alert.Errors,
+			"requests", alert.Requests,
+			"rate", fmt.Sprintf("%.0f%%", alert.Rate*100),
+			"window", alert.Window)
+	} else {
+		slog.Info("error rate recovered",
+			"hostname", alert.Hostname+alert.PathPrefix,
+			"errors", alert.Errors,
+			"requests", alert.Requests,
+			"window", alert.Window)
+	}
+
+	if a.cfg.Webhook != "" {
+		body, err := json.Marshal(alert)
+		if err == nil {
+			var resp *http.Response
+			resp, err = a.client.Post(a.cfg.Webhook, "application/json", bytes.NewReader(body))
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode >= 300 {
+					err = fmt.Errorf("webhook returned status %d", resp.StatusCode)
+				}
+			}
+		}
+		if err != nil {
+			slog.Warn("failed to deliver error rate alert", "webhook", a.cfg.Webhook, "error", err)
+		}
+	}
+
+	if a.cfg.Desktop {
+		if err := a.notify("roji", alert.Summary()); err != nil {
+			slog.Warn("failed to show desktop notification", "error", err)
+		}
+	}
+}
+
+// notifyDesktop shows a notification with the platform's tool: osascript
+// on macOS, notify-send on Linux
+func notifyDesktop(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title %q", message, title))
+	case "linux":
+		cmd = exec.Command("notify-send", title, message)
+	default:
+		return fmt.Errorf("desktop notifications aren't supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", cmd.Path, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// windowText formats an alert window: "1m", "90s"
+func windowText(d time.Duration) string {
+	if d >= time.Minute && d%time.Minute == 0 {
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
+
+// routeErrors is the traffic of one route over an alert window
+type routeErrors struct {
+	hostname   string
+	pathPrefix string
+	requests   int
+	errors     int
+}
+
+// windowErrors sums each route's history over the window before now,
+// rounded up to whole history buckets
+func (m *metrics) windowErrors(now time.Time, window time.Duration) []routeErrors {
+	buckets := int((window + historyBucketSize - 1) / historyBucketSize)
+	buckets = max(1, min(buckets, historyBuckets))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]routeErrors, 0, len(m.routes))
+	for _, c := range m.routes {
+		re := routeErrors{hostname: c.hostname, pathPrefix: c.pathPrefix}
+		for _, bucket := range c.historyAt(now)[historyBuckets-buckets:] {
+			re.requests += bucket.Requests
+			re.errors += bucket.Errors
+		}
+		result = append(result, re)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].hostname+result[i].pathPrefix < result[j].hostname+result[j].pathPrefix
+	})
+	return result
+}
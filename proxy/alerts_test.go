@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHandler_CheckErrorRates(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []ErrorRateAlert
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert ErrorRateAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("webhook body: %v", err)
+		}
+		mu.Lock()
+		delivered = append(delivered, alert)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithErrorAlerts(ErrorAlertConfig{
+		Threshold:   0.5,
+		Window:      time.Minute,
+		MinRequests: 4,
+		Webhook:     webhook.URL,
+		Desktop:     true,
+	}))
+	var notifications []string
+	handler.errorAlerts.notify = func(title, message string) error {
+		notifications = append(notifications, message)
+		return nil
+	}
+
+	api := &Route{Hostname: "api.localhost"}
+	web := &Route{Hostname: "web.localhost"}
+	serve := func(route *Route, status, n int) {
+		for range n {
+			handler.metrics.observe(route, httptest.NewRequest("GET", "/", nil), status, time.Millisecond)
+		}
+	}
+
+	// Too few requests to judge, even if they all fail
+	serve(api, http.StatusBadGateway, 3)
+	if alerts := handler.CheckErrorRates(time.Now()); len(alerts) != 0 {
+		t.Fatalf("alerts below min requests = %+v", alerts)
+	}
+
+	serve(api, http.StatusBadGateway, 1)
+	serve(web, http.StatusOK, 10)
+	serve(web, http.StatusInternalServerError, 2)
+	alerts := handler.CheckErrorRates(time.Now())
+	if len(alerts) != 1 || alerts[0].Hostname != "api.localhost" || alerts[0].State != AlertFiring {
+		t.Fatalf("alerts = %+v, want api.localhost firing", alerts)
+	}
+	if alerts[0].Errors != 4 || alerts[0].Requests != 4 || alerts[0].Rate != 1 || alerts[0].Window != "1m" {
+		t.Errorf("alert = %+v, want 4 of 4 in 1m", alerts[0])
+	}
+
+	// Alerts once, not on every check
+	if alerts := handler.CheckErrorRates(time.Now()); len(alerts) != 0 {
+		t.Errorf("repeated alerts = %+v", alerts)
+	}
+
+	// Recovers once successes bring the rate under the threshold
+	serve(api, http.StatusOK, 5)
+	alerts = handler.CheckErrorRates(time.Now())
+	if len(alerts) != 1 || alerts[0].State != AlertResolved {
+		t.Fatalf("alerts after recovery = %+v, want resolved", alerts)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(delivered) != 2 || delivered[0].State != AlertFiring || delivered[1].State != AlertResolved {
+		t.Errorf("webhook deliveries = %+v, want firing then resolved", delivered)
+	}
+	if len(notifications) != 2 || !strings.Contains(notifications[0], "api.localhost is failing") {
+		t.Errorf("notifications = %q", notifications)
+	}
+}
+
+func TestHandler_CheckErrorRatesWindow(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithErrorAlerts(ErrorAlertConfig{
+		Threshold: 0.5,
+		Window:    20 * time.Second,
+	}))
+	route := &Route{Hostname: "api.localhost"}
+	handler.metrics.observe(route, httptest.NewRequest("GET", "/", nil), http.StatusBadGateway, time.Millisecond)
+
+	// Errors older than the window no longer count
+	if alerts := handler.CheckErrorRates(time.Now()); len(alerts) != 1 {
+		t.Fatalf("alerts = %+v, want 1", alerts)
+	}
+	alerts := handler.CheckErrorRates(time.Now().Add(time.Minute))
+	if len(alerts) != 1 || alerts[0].State != AlertResolved || alerts[0].Requests != 0 {
+		t.Errorf("alerts a minute later = %+v, want resolved with no requests", alerts)
+	}
+}
+
+func TestHandler_CheckErrorRatesDisabled(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig())
+	handler.metrics.observe(&Route{Hostname: "api.localhost"}, httptest.NewRequest("GET", "/", nil), http.StatusBadGateway, time.Millisecond)
+	if alerts := handler.CheckErrorRates(time.Now()); alerts != nil {
+		t.Errorf("alerts without WithErrorAlerts = %+v", alerts)
+	}
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// LoginPath accepts the dashboard login form (POST token=)
+	LoginPath = "/_roji/login"
+	// authCookie keeps browsers logged in to the dashboard
+	authCookie = "roji_auth"
+)
+
+// WithAPIToken requires the token for the dashboard and /_api/*: as
+// "Authorization: Bearer <token>" from scripts and the CLI, or once in the
+// login form from browsers. /healthz stays open for container health checks.
+func WithAPIToken(token string) HandlerOption {
+	return func(h *Handler) {
+		h.apiToken = token
+	}
+}
+
+// authCookieValue derives the cookie from the token, so the cookie doesn't
+// reveal it
+func authCookieValue(token string) string {
+	sum := sha256.Sum256([]byte("roji-dashboard:" + token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authorized reports whether a dashboard request carries the API token or
+// the login cookie
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.apiToken == "" {
+		return true
+	}
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(h.apiToken)) == 1 {
+			return true
+		}
+	}
+	if cookie, err := r.Cookie(authCookie); err == nil {
+		return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(authCookieValue(h.apiToken))) == 1
+	}
+	return false
+}
+
+// crossOrigin reports whether a browser sent a request from a page other than
+// the dashboard. Any page can make browsers post to /_api/* with the login
+// cookie; scripts and the CLI send neither Origin nor Sec-Fetch-Site and are
+// left to the API token.
+func (h *Handler) crossOrigin(r *http.Request) bool {
+	return notFromHost(r, h.dashboardHost)
+}
+
+// notFromHost reports whether a browser sent a request from a page on a
+// hostname other than host (see crossOrigin)
+func notFromHost(r *http.Request, host string) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		return err != nil || !strings.EqualFold(u.Hostname(), host)
+	}
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+		return false
+	}
+	return true
+}
+
+// guardDashboard handles the login form and rejects unauthorized dashboard
+// requests; it reports whether the request was handled
+func (h *Handler) guardDashboard(w http.ResponseWriter, r *http.Request) bool {
+	if h.apiToken == "" {
+		return false
+	}
+
+	failed := false
+	if r.URL.Path == LoginPath && r.Method == http.MethodPost {
+		if subtle.ConstantTimeCompare([]byte(r.FormValue("token")), []byte(h.apiToken)) == 1 {
+			http.SetCookie(w, &http.Cookie{
+				Name:     authCookie,
+				Value:    authCookieValue(h.apiToken),
+				Path:     "/",
+				Secure:   r.TLS != nil,
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+			slog.Info("dashboard login", "client", r.RemoteAddr)
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return true
+		}
+		slog.Warn("dashboard login failed", "client", r.RemoteAddr)
+		failed = true
+	} else if h.authorized(r) {
+		return false
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if strings.HasPrefix(r.URL.Path, "/_api/") {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="roji"`)
+		http.Error(w, "missing or invalid API token (set --api-token or ROJI_API_TOKEN)", http.StatusUnauthorized)
+		return true
+	}
+	renderPage(w, http.StatusUnauthorized, "login.html", struct{ Failed bool }{failed})
+	return true
+}
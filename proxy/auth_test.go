@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHandler_APIToken(t *testing.T) {
+	backend := newTestBackend(t, "app.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("app"))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithAPIToken("s3cret"))
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without the token: 401 for the API, the login form for the dashboard
+	w := serve(httptest.NewRequest("GET", "https://roji.localhost/_api/routes", nil))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("API without token = %d", w.Code)
+	}
+	w = serve(httptest.NewRequest("GET", "https://roji.localhost/", nil))
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `action="/_roji/login"`) {
+		t.Errorf("dashboard without token = %d, want the login form", w.Code)
+	}
+
+	// Bearer token
+	req := httptest.NewRequest("GET", "https://roji.localhost/_api/routes", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	if w := serve(req); w.Code != http.StatusOK {
+		t.Errorf("API with token = %d", w.Code)
+	}
+	req.Header.Set("Authorization", "Bearer wrong")
+	if w := serve(req); w.Code != http.StatusUnauthorized {
+		t.Errorf("API with wrong token = %d", w.Code)
+	}
+
+	// /healthz stays open; routes aren't affected
+	if w := serve(httptest.NewRequest("GET", "https://roji.localhost/healthz", nil)); w.Code != http.StatusOK {
+		t.Errorf("/healthz = %d", w.Code)
+	}
+	if w := serve(httptest.NewRequest("GET", "https://app.localhost/", nil)); w.Body.String() != "app" {
+		t.Errorf("route = %d %q", w.Code, w.Body)
+	}
+
+	// The login form sets a cookie that doesn't contain the token
+	login := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "https://roji.localhost"+LoginPath, strings.NewReader(url.Values{"token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return serve(req)
+	}
+	if w := login("wrong"); w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "wrong") {
+		t.Errorf("failed login = %d", w.Code)
+	}
+	w = login("s3cret")
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusSeeOther || len(cookies) != 1 || strings.Contains(cookies[0].Value, "s3cret") || !cookies[0].HttpOnly {
+		t.Fatalf("login = %d, cookies %v", w.Code, cookies)
+	}
+	req = httptest.NewRequest("GET", "https://roji.localhost/", nil)
+	req.AddCookie(cookies[0])
+	if w := serve(req); w.Code != http.StatusOK {
+		t.Errorf("dashboard with login cookie = %d", w.Code)
+	}
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/kan/roji/docker"
+)
+
+// bannerStyle pins the banner to the bottom of the viewport, above the page
+const bannerStyle = "position:fixed;left:0;right:0;bottom:0;z-index:2147483647;" +
+	"padding:4px 12px;background:#f59e0b;color:#111;" +
+	"font:13px/1.4 -apple-system,BlinkMacSystemFont,'Segoe UI',sans-serif;" +
+	"text-align:center;pointer-events:none"
+
+// environmentBanner is the --banner text for "true": which project, service
+// and container a tab is talking to
+const environmentBanner = "DEV — project: {project}, service: {service}, container: {container}"
+
+// WithBanner injects a banner into the HTML pages of every route without a
+// roji.banner label: "true" for the environment banner, or custom text
+func WithBanner(text string) HandlerOption {
+	return func(h *Handler) {
+		h.banner = text
+	}
+}
+
+// routeBanner returns the banner template for a route: its roji.banner label,
+// or the --banner default unless the label turns banners off
+func (h *Handler) routeBanner(b *docker.Backend) string {
+	switch b.Banner {
+	case "false":
+		return ""
+	case "":
+		if h.banner == "true" {
+			return environmentBanner
+		}
+		return h.banner
+	}
+	return b.Banner
+}
+
+// bannerText fills in a banner template for the backend serving the page.
+// "true" is the default "Dev preview" notice; {revision}, {project},
+// {service}, {container} and {hostname} are replaced in custom text.
+func bannerText(template string, b *docker.Backend) string {
+	revision := shortRevision(b.Image.Revision)
+	if template != "true" {
+		return strings.NewReplacer(
+			"{revision}", revision,
+			"{project}", b.ProjectName,
+			"{service}", b.ServiceName,
+			"{container}", shortContainerID(b.ContainerID),
+			"{hostname}", b.Hostname,
+		).Replace(template)
+	}
+	if revision == "" {
+		return "Dev preview — may be unstable"
+	}
+	return "Dev preview — build " + revision + ", may be unstable"
+}
+
+// injectBanner adds a banner to an HTML response, just before </body> (or at
+// the end of documents without one). Only whole pages get one (see fullPage).
+func injectBanner(text string, resp *http.Response) error {
+	if !fullPage(resp) {
+		return nil
+	}
+	banner := []byte(`<div id="roji-banner" role="status" style="` + bannerStyle + `">` +
+		html.EscapeString(text) + `</div>`)
+	return rewriteHTML(resp, func(body []byte) []byte {
+		return insertBeforeBodyEnd(body, banner)
+	})
+}
@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kan/roji/config"
+	"github.com/kan/roji/docker"
+)
+
+func TestBannerText(t *testing.T) {
+	tests := []struct {
+		banner   string
+		revision string
+		want     string
+	}{
+		{"true", "", "Dev preview — may be unstable"},
+		{"true", "abc1234", "Dev preview — build abc1234, may be unstable"},
+		{"Demo of {revision}", "abc1234", "Demo of abc1234"},
+		{environmentBanner, "", "DEV — project: shop, service: web, container: 0123456789ab"},
+	}
+	for _, tt := range tests {
+		b := &docker.Backend{
+			ContainerID: "0123456789abcdef",
+			ProjectName: "shop",
+			ServiceName: "web",
+			Image:       config.ImageInfo{Revision: tt.revision},
+		}
+		if got := bannerText(tt.banner, b); got != tt.want {
+			t.Errorf("bannerText(%q, %q) = %q, want %q", tt.banner, tt.revision, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_Banner(t *testing.T) {
+	backend := newTestBackend(t, "demo.localhost", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, "<html><BODY><p>hi</p></BODY></html>")
+		case "/api":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"ok":true}`)
+		}
+	})
+	backend.Banner = "<b>demo</b>"
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "https://demo.localhost"+path, nil)
+		req.Host = "demo.localhost"
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	body := get("/").Body.String()
+	if !strings.Contains(body, `&lt;b&gt;demo&lt;/b&gt;</div></BODY>`) {
+		t.Errorf("banner not escaped and injected before </body>: %q", body)
+	}
+	if body := get("/api").Body.String(); body != `{"ok":true}` {
+		t.Errorf("JSON body = %q, want it untouched", body)
+	}
+}
+
+func TestHandler_DefaultBanner(t *testing.T) {
+	html := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<body>page</body>")
+	}
+	web := newTestBackend(t, "web.localhost", html)
+	web.ContainerID, web.ProjectName, web.ServiceName = "0123456789abcdef", "shop", "web"
+	quiet := newTestBackend(t, "quiet.localhost", html)
+	quiet.Banner = "false"
+
+	router := NewRouter()
+	router.AddBackend(web)
+	router.AddBackend(quiet)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithBanner("true"))
+
+	get := func(host string) string {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "https://"+host+"/", nil))
+		return w.Body.String()
+	}
+	if body := get("web.localhost"); !strings.Contains(body, "DEV — project: shop, service: web, container: 0123456789ab</div></body>") {
+		t.Errorf("environment banner not injected: %q", body)
+	}
+	if body := get("quiet.localhost"); body != "<body>page</body>" {
+		t.Errorf("roji.banner=false body = %q, want it untouched", body)
+	}
+}
+
+func TestHandler_BannerGzip(t *testing.T) {
+	backend := newTestBackend(t, "demo.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		io.WriteString(zw, "<body>page</body>")
+		zw.Close()
+	})
+	backend.Banner = "demo"
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	req := httptest.NewRequest("GET", "https://demo.localhost/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", w.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("response is not gzip: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+	if !strings.Contains(string(body), "demo</div></body>") {
+		t.Errorf("banner not injected into gzipped page: %q", body)
+	}
+}
+
+func TestHandler_BannerOnlyOnFullPages(t *testing.T) {
+	backend := newTestBackend(t, "demo.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Encoding", "gzip")
+		switch r.URL.Path {
+		case "/cached":
+			w.WriteHeader(http.StatusNotModified)
+		case "/empty":
+			w.WriteHeader(http.StatusOK)
+		case "/range":
+			w.Header().Del("Content-Encoding")
+			w.Header().Set("Content-Range", "bytes 0-5/17")
+			w.WriteHeader(http.StatusPartialContent)
+			io.WriteString(w, "<body>")
+		default:
+			zw := gzip.NewWriter(w)
+			io.WriteString(zw, "<body>page</body>")
+			zw.Close()
+		}
+	})
+	backend.Banner = "demo"
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	for _, tt := range []struct{ method, path string }{
+		{"HEAD", "/"},
+		{"GET", "/cached"},
+		{"GET", "/empty"},
+	} {
+		req := httptest.NewRequest(tt.method, "https://demo.localhost"+tt.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code == http.StatusBadGateway || w.Body.Len() != 0 {
+			t.Errorf("%s %s: status = %d, body = %q", tt.method, tt.path, w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "https://demo.localhost/range", nil)
+	req.Header.Set("Range", "bytes=0-5")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "<body>" {
+		t.Errorf("partial content rewritten: status = %d, body = %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"gzip, deflate, br":  true,
+		"br;q=1, GZIP;q=0.5": true,
+		"gzip;q=0":           false,
+		"br":                 false,
+		"":                   false,
+	}
+	for value, want := range tests {
+		header := http.Header{"Accept-Encoding": {value}}
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
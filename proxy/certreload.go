@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CertReloader serves the certificate in the certificates directory and
+// picks up a new one when cert.pem or key.pem change (e.g., regenerated
+// with mkcert), without restarting roji
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	events   *EventHub // optional; receives cert_renewed
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time // newest modification time of the loaded files
+}
+
+// NewCertReloader loads cert.pem and key.pem from certsDir
+func NewCertReloader(certsDir string, events *EventHub) (*CertReloader, error) {
+	c := &CertReloader{
+		certFile: filepath.Join(certsDir, "cert.pem"),
+		keyFile:  filepath.Join(certsDir, "key.pem"),
+		events:   events,
+	}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate returns the current certificate (tls.Config.GetCertificate).
+// It is the default certificate too: clients that don't send SNI (legacy
+// devices, see roji.legacy) get the same wildcard certificate.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// Reload loads the certificate again if its files changed since the last
+// load, reporting whether it did. A broken pair keeps the current one.
+func (c *CertReloader) Reload() (bool, error) {
+	modTime, err := newestModTime(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+	c.mu.RLock()
+	unchanged := c.cert != nil && !modTime.After(c.modTime)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	renewed := c.cert != nil
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+
+	if renewed {
+		info := parseCertificate(c.certFile)
+		slog.Info("certificate reloaded", "subject", info.Subject, "valid_until", info.ValidUntil)
+		c.events.Publish(Event{Type: EventCertRenewed, Cert: info})
+	}
+	return true, nil
+}
+
+// Run checks the certificate files every interval until ctx is done
+func (c *CertReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Reload(); err != nil {
+				slog.Warn("keeping the current certificate", "error", err)
+			}
+		}
+	}
+}
+
+// newestModTime returns the latest modification time of the files
+func newestModTime(paths ...string) (time.Time, error) {
+	var newest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
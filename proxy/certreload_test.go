@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kan/roji/certgen"
+)
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	if err := certgen.NewGenerator(dir, "localhost").EnsureCerts(); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := NewEventHub()
+	ch := hub.subscribe(0)
+	defer hub.unsubscribe(ch)
+	certs, err := NewCertReloader(dir, hub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := certs.GetCertificate(nil)
+
+	if reloaded, err := certs.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() of unchanged files = %v, %v", reloaded, err)
+	}
+
+	// Issue a new server certificate from the same CA
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.Remove(certFile)
+	os.Remove(keyFile)
+	if err := certgen.NewGenerator(dir, "localhost").EnsureCerts(); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+
+	if reloaded, err := certs.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload() of a new certificate = %v, %v", reloaded, err)
+	}
+	second, _ := certs.GetCertificate(nil)
+	if bytes.Equal(first.Certificate[0], second.Certificate[0]) {
+		t.Error("GetCertificate still returns the old certificate")
+	}
+	if e := nextEvent(t, ch); e.Type != EventCertRenewed || e.Cert == nil || !e.Cert.Exists {
+		t.Errorf("event = %+v, want cert_renewed with the certificate", e)
+	}
+
+	// A broken pair keeps serving the current certificate
+	os.WriteFile(keyFile, []byte("not a key"), 0600)
+	evenLater := later.Add(time.Minute)
+	os.Chtimes(keyFile, evenLater, evenLater)
+	if _, err := certs.Reload(); err == nil {
+		t.Error("Reload() of a broken key succeeded")
+	}
+	if current, _ := certs.GetCertificate(nil); current != second {
+		t.Error("broken files replaced the certificate")
+	}
+}
+
+func TestCertReloader_NoSNI(t *testing.T) {
+	dir := t.TempDir()
+	if err := certgen.NewGenerator(dir, "localhost").EnsureCerts(); err != nil {
+		t.Fatal(err)
+	}
+	certs, err := NewCertReloader(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Legacy clients (roji.legacy) connect without a server name
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		defer serverConn.Close()
+		tls.Server(serverConn, &tls.Config{GetCertificate: certs.GetCertificate}).Handshake()
+	}()
+	client := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true})
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("handshake without SNI: %v", err)
+	}
+
+	want, _ := certs.GetCertificate(nil)
+	peer := client.ConnectionState().PeerCertificates
+	if len(peer) == 0 || !bytes.Equal(peer[0].Raw, want.Certificate[0]) {
+		t.Error("a client without SNI didn't get the default certificate")
+	}
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+// maxChangelogEntries is how many entries the dashboard timeline keeps
+const maxChangelogEntries = 20
+
+// ChangelogEntry describes a route whose image revision changed on (re)start
+type ChangelogEntry struct {
+	Hostname         string    `json:"hostname"`
+	PathPrefix       string    `json:"path_prefix,omitempty"`
+	ContainerName    string    `json:"container"`
+	Time             time.Time `json:"time"`
+	Revision         string    `json:"revision"`
+	PreviousRevision string    `json:"previous_revision,omitempty"`
+	Description      string    `json:"description,omitempty"`
+	CommitURL        string    `json:"commit_url,omitempty"`
+}
+
+// ShortRevision abbreviates commit hashes for display
+func (e ChangelogEntry) ShortRevision() string {
+	return shortRevision(e.Revision)
+}
+
+// ShortPreviousRevision abbreviates the previous commit hash for display
+func (e ChangelogEntry) ShortPreviousRevision() string {
+	return shortRevision(e.PreviousRevision)
+}
+
+// Changelog tracks the OCI image revision of each route and records an
+// entry whenever a container starts with a different revision.
+type Changelog struct {
+	mu        sync.Mutex
+	revisions map[string]string // key: hostname + path prefix
+	entries   []ChangelogEntry  // newest last
+}
+
+// NewChangelog creates an empty changelog
+func NewChangelog() *Changelog {
+	return &Changelog{
+		revisions: make(map[string]string),
+	}
+}
+
+// Baseline remembers the backend's revision without recording an entry.
+// Used for containers that were already running when roji started.
+func (c *Changelog) Baseline(backend *docker.Backend) {
+	if backend.Image.Revision == "" || backend.RedirectTo != "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.revisions[backend.Hostname+backend.PathPrefix] = backend.Image.Revision
+}
+
+// Record notes a container (re)start and adds an entry if the backend runs
+// a revision the route hasn't seen before
+func (c *Changelog) Record(backend *docker.Backend) {
+	if backend.Image.Revision == "" || backend.RedirectTo != "" {
+		return
+	}
+
+	key := backend.Hostname + backend.PathPrefix
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.revisions[key]
+	if previous == backend.Image.Revision {
+		return
+	}
+	c.revisions[key] = backend.Image.Revision
+
+	c.entries = append(c.entries, ChangelogEntry{
+		Hostname:         backend.Hostname,
+		PathPrefix:       backend.PathPrefix,
+		ContainerName:    backend.ContainerName,
+		Time:             time.Now(),
+		Revision:         backend.Image.Revision,
+		PreviousRevision: previous,
+		Description:      backend.Image.Description,
+		CommitURL:        commitURL(backend.Image.SourceURL, backend.Image.Revision),
+	})
+	if len(c.entries) > maxChangelogEntries {
+		c.entries = c.entries[len(c.entries)-maxChangelogEntries:]
+	}
+}
+
+// Entries returns recorded entries, newest first
+func (c *Changelog) Entries() []ChangelogEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]ChangelogEntry, len(c.entries))
+	for i, e := range c.entries {
+		result[len(c.entries)-1-i] = e
+	}
+	return result
+}
+
+// WithChangelog shows image revision changes on the dashboard timeline
+func WithChangelog(c *Changelog) HandlerOption {
+	return func(h *Handler) {
+		h.changelog = c
+	}
+}
+
+// commitURL links a revision to its commit page on GitHub-style hosts
+// (e.g., "https://github.com/kan/roji" -> ".../commit/<rev>")
+func commitURL(source, revision string) string {
+	if source == "" || revision == "" {
+		return ""
+	}
+
+	// git@github.com:kan/roji.git -> https://github.com/kan/roji.git
+	if strings.HasPrefix(source, "git@") {
+		host, path, ok := strings.Cut(strings.TrimPrefix(source, "git@"), ":")
+		if !ok {
+			return ""
+		}
+		source = "https://" + host + "/" + path
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ""
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git") + "/commit/" + url.PathEscape(revision)
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// shortRevision abbreviates long commit hashes to 7 characters
+func shortRevision(revision string) string {
+	if len(revision) <= 12 {
+		return revision
+	}
+	for _, r := range revision {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return revision
+		}
+	}
+	return revision[:7]
+}
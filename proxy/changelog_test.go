@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kan/roji/config"
+	"github.com/kan/roji/docker"
+)
+
+func TestChangelog_Record(t *testing.T) {
+	backend := func(revision string) *docker.Backend {
+		return &docker.Backend{
+			ContainerName: "app-web-1",
+			Hostname:      "web.app.localhost",
+			Image: config.ImageInfo{
+				Revision:    revision,
+				Description: "Web frontend",
+				SourceURL:   "https://github.com/kan/app.git",
+			},
+		}
+	}
+
+	c := NewChangelog()
+	c.Baseline(backend("1111111111111111111111111111111111111111"))
+
+	// Restart with the same revision records nothing
+	c.Record(backend("1111111111111111111111111111111111111111"))
+	if entries := c.Entries(); len(entries) != 0 {
+		t.Fatalf("expected no entries, got %d", len(entries))
+	}
+
+	c.Record(backend("2222222222222222222222222222222222222222"))
+	entries := c.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.PreviousRevision != "1111111111111111111111111111111111111111" {
+		t.Errorf("PreviousRevision = %q", e.PreviousRevision)
+	}
+	if e.ShortRevision() != "2222222" {
+		t.Errorf("ShortRevision() = %q, want 2222222", e.ShortRevision())
+	}
+	want := "https://github.com/kan/app/commit/2222222222222222222222222222222222222222"
+	if e.CommitURL != want {
+		t.Errorf("CommitURL = %q, want %q", e.CommitURL, want)
+	}
+
+	// Backends without a revision label are ignored
+	c.Record(backend(""))
+	if entries := c.Entries(); len(entries) != 1 {
+		t.Errorf("expected 1 entry, got %d", len(entries))
+	}
+}
+
+func TestCommitURL(t *testing.T) {
+	tests := []struct {
+		source   string
+		revision string
+		want     string
+	}{
+		{"https://github.com/kan/roji", "abc", "https://github.com/kan/roji/commit/abc"},
+		{"https://github.com/kan/roji.git", "abc", "https://github.com/kan/roji/commit/abc"},
+		{"https://github.com/kan/roji/", "abc", "https://github.com/kan/roji/commit/abc"},
+		{"git@github.com:kan/roji.git", "abc", "https://github.com/kan/roji/commit/abc"},
+		{"ftp://example.com/repo", "abc", ""},
+		{"not a url", "abc", ""},
+		{"https://github.com/kan/roji", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := commitURL(tt.source, tt.revision); got != tt.want {
+			t.Errorf("commitURL(%q, %q) = %q, want %q", tt.source, tt.revision, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_DashboardChangelog(t *testing.T) {
+	c := NewChangelog()
+	c.Record(&docker.Backend{
+		Hostname: "web.app.localhost",
+		Image: config.ImageInfo{
+			Revision:    "v1.2.0",
+			Description: "Web frontend",
+			SourceURL:   "https://github.com/kan/app",
+		},
+	})
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithChangelog(c))
+
+	req := httptest.NewRequest("GET", "https://roji.localhost/", nil)
+	req.Host = "roji.localhost"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"Recent changes", "Web frontend", "https://github.com/kan/app/commit/v1.2.0"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard missing %q", want)
+		}
+	}
+}
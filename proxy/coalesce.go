@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxCoalescedBody limits how much of a leader response is buffered for followers.
+// Larger responses are streamed to the leader only; followers fetch their own copy.
+const maxCoalescedBody = 8 << 20 // 8 MiB
+
+// coalescer collapses identical concurrent requests into a single upstream call
+type coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is an in-flight upstream request shared by several clients
+type coalescedCall struct {
+	done     chan struct{}
+	status   int
+	header   http.Header
+	body     []byte
+	overflow bool // response was too large (or streamed) to share
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{calls: make(map[string]*coalescedCall)}
+}
+
+// coalescable reports whether a request is safe to share with other clients
+func coalescable(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	// Never collapse upgrades (WebSocket) or event streams that never complete
+	if r.Header.Get("Upgrade") != "" || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return false
+	}
+	return true
+}
+
+// coalesceKey identifies requests that must receive the same response.
+// Credentials are part of the key so responses never leak across sessions.
+func coalesceKey(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteString(" ")
+	b.WriteString(strings.ToLower(r.Host))
+	b.WriteString(r.URL.RequestURI())
+	for _, name := range []string{"Accept", "Accept-Encoding", "Authorization", "Cookie", "Range"} {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(r.Header.Values(name), ", "))
+	}
+	return b.String()
+}
+
+// Do serves the request through serve, sharing the upstream response with any
+// identical request that arrives while it is in flight. It reports whether the
+// response was served from another client's upstream call.
+func (c *coalescer) Do(w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter)) bool {
+	key := coalesceKey(r)
+
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		return c.wait(call, w, r, serve)
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	tw := &teeWriter{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		call.status = tw.status
+		call.header = w.Header().Clone()
+		call.body = tw.buf.Bytes()
+		call.overflow = tw.overflow
+
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	serve(tw)
+	return false
+}
+
+// wait blocks until the leader finishes and replays its response
+func (c *coalescer) wait(call *coalescedCall, w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter)) bool {
+	select {
+	case <-call.done:
+	case <-r.Context().Done():
+		return true
+	}
+
+	if call.overflow {
+		serve(w)
+		return false
+	}
+
+	for k, v := range call.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("X-Roji-Coalesced", "true")
+	w.WriteHeader(call.status)
+	if r.Method != http.MethodHead {
+		w.Write(call.body)
+	}
+	return true
+}
+
+// teeWriter forwards a response to the client while buffering a copy for followers
+type teeWriter struct {
+	http.ResponseWriter
+	status   int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (t *teeWriter) WriteHeader(code int) {
+	t.status = code
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	if !t.overflow {
+		if t.buf.Len()+len(p) > maxCoalescedBody {
+			t.overflow = true
+			t.buf = bytes.Buffer{}
+		} else {
+			t.buf.Write(p)
+		}
+	}
+	return t.ResponseWriter.Write(p)
+}
+
+// Flush supports streaming responses (ReverseProxy flushes immediately)
+func (t *teeWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (t *teeWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescable(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		header   map[string]string
+		expected bool
+	}{
+		{"plain GET", "GET", nil, true},
+		{"HEAD", "HEAD", nil, true},
+		{"POST", "POST", nil, false},
+		{"websocket upgrade", "GET", map[string]string{"Upgrade": "websocket"}, false},
+		{"event stream", "GET", map[string]string{"Accept": "text/event-stream"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "https://web.localhost/", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			if got := coalescable(req); got != tt.expected {
+				t.Errorf("coalescable() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCoalesceKey_SeparatesCredentials(t *testing.T) {
+	a := httptest.NewRequest("GET", "https://web.localhost/data", nil)
+	a.Header.Set("Cookie", "session=alice")
+	b := httptest.NewRequest("GET", "https://web.localhost/data", nil)
+	b.Header.Set("Cookie", "session=bob")
+
+	if coalesceKey(a) == coalesceKey(b) {
+		t.Error("requests with different cookies must not share a key")
+	}
+}
+
+func TestCoalescer_Do(t *testing.T) {
+	c := newCoalescer()
+
+	var upstreamCalls atomic.Int32
+	release := make(chan struct{})
+	serve := func(w http.ResponseWriter) {
+		upstreamCalls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello"))
+	}
+
+	const clients = 5
+	recorders := make([]*httptest.ResponseRecorder, clients)
+	var wg sync.WaitGroup
+
+	// Start the leader first so followers find it in flight
+	recorders[0] = httptest.NewRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.Do(recorders[0], httptest.NewRequest("GET", "https://web.localhost/slow", nil), serve)
+	}()
+	for upstreamCalls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 1; i < clients; i++ {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			c.Do(rec, httptest.NewRequest("GET", "https://web.localhost/slow", nil), serve)
+		}(recorders[i])
+	}
+
+	// Give followers time to attach before releasing the leader
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := upstreamCalls.Load(); got != 1 {
+		t.Errorf("upstream calls = %d, want 1", got)
+	}
+	for i, rec := range recorders {
+		if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+			t.Errorf("client %d: got %d %q, want 200 \"hello\"", i, rec.Code, rec.Body.String())
+		}
+	}
+	if recorders[1].Header().Get("X-Roji-Coalesced") != "true" {
+		t.Error("follower responses should be marked as coalesced")
+	}
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/kan/roji/config"
+)
+
+// ForwardedPrefixHeader tells a mounted fragment which path it is served under
+const ForwardedPrefixHeader = "X-Forwarded-Prefix"
+
+// matchMount returns the mount serving path, if any. Mounts are ordered
+// longest first and match on path segment boundaries.
+func matchMount(mounts []config.Mount, path string) (config.Mount, bool) {
+	for _, m := range mounts {
+		if m.Path == "/" || path == m.Path || strings.HasPrefix(path, m.Path+"/") {
+			return m, true
+		}
+	}
+	return config.Mount{}, false
+}
+
+// resolveMount hands a request on a composite route (roji.compose) to the
+// route serving the mounted fragment. It returns the route to proxy to, the
+// mount path ("" when the composite route serves the request itself) and
+// whether the fragment's route exists.
+func (h *Handler) resolveMount(route *Route, path string) (*Route, string, bool) {
+	mount, ok := matchMount(route.Backend.Mounts, path)
+	if !ok {
+		return route, "", true
+	}
+
+	fragment := h.router.Lookup(mount.Host, path)
+	if fragment != nil && fragment.Backend.RedirectTo != "" {
+		// Follow aliases internally; the browser stays on the composite hostname
+		fragment = h.router.Lookup(fragment.Backend.RedirectTo, path)
+	}
+	if fragment == nil {
+		return nil, mount.Path, false
+	}
+	return fragment, mount.Path, true
+}
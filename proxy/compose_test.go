@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kan/roji/config"
+)
+
+func TestMatchMount(t *testing.T) {
+	mounts := []config.Mount{
+		{Path: "/checkout", Host: "checkout.localhost"},
+		{Path: "/account", Host: "account.localhost"},
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/checkout", "checkout.localhost"},
+		{"/checkout/cart", "checkout.localhost"},
+		{"/checkouts", ""},
+		{"/account/settings", "account.localhost"},
+		{"/", ""},
+	}
+	for _, tt := range tests {
+		got, _ := matchMount(mounts, tt.path)
+		if got.Host != tt.want {
+			t.Errorf("matchMount(%q) = %q, want %q", tt.path, got.Host, tt.want)
+		}
+	}
+}
+
+func TestHandler_CompositeRoute(t *testing.T) {
+	shell := newTestBackend(t, "shop.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("shell " + r.URL.Path))
+	})
+	shell.ContainerID = "shell1"
+	shell.Mounts = []config.Mount{
+		{Path: "/checkout", Host: "checkout.localhost"},
+		{Path: "/account", Host: "account.localhost"},
+	}
+	fragment := newTestBackend(t, "checkout.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("checkout " + r.Host + " " + r.URL.Path + " " + r.Header.Get(ForwardedPrefixHeader)))
+	})
+	fragment.ContainerID = "checkout1"
+
+	router := NewRouter()
+	router.AddBackend(shell)
+	router.AddBackend(fragment)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "https://shop.localhost"+path, nil)
+		req.Host = "shop.localhost"
+		req.Header.Set(ForwardedPrefixHeader, "/spoofed")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/"); w.Body.String() != "shell /" {
+		t.Errorf("GET / = %q, want served by the shell", w.Body.String())
+	}
+	// The fragment sees the composite hostname, so cookies are shared
+	if w := get("/checkout/cart"); w.Body.String() != "checkout shop.localhost /checkout/cart /checkout" {
+		t.Errorf("GET /checkout/cart = %q, want served by the checkout fragment", w.Body.String())
+	}
+	if w := get("/account"); w.Code != http.StatusBadGateway {
+		t.Errorf("GET /account status = %d, want %d for a missing fragment", w.Code, http.StatusBadGateway)
+	}
+}
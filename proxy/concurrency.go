@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+// concurrencyLimiter caps the requests proxied to each container at once
+// (roji.max-concurrent), so single-threaded dev servers aren't buried by a
+// browser's parallel requests. Excess requests queue for a slot for the
+// route's wait, then are shed.
+type concurrencyLimiter struct {
+	mu    sync.Mutex
+	slots map[string]*backendSlots // key: container ID and port
+}
+
+// backendSlots is the semaphore of one backend
+type backendSlots struct {
+	sem   chan struct{}
+	users int // Requests holding or waiting for a slot; the entry goes at 0
+}
+
+func newConcurrencyLimiter() *concurrencyLimiter {
+	return &concurrencyLimiter{slots: make(map[string]*backendSlots)}
+}
+
+// concurrencyLimited reports whether a request counts against
+// roji.max-concurrent. WebSockets and event streams stay open for the whole
+// session and would hold a slot forever.
+func concurrencyLimited(backend *docker.Backend, r *http.Request) bool {
+	if backend.MaxConcurrent <= 0 {
+		return false
+	}
+	return r.Header.Get("Upgrade") == "" && !strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// acquire takes a slot of the backend, waiting up to its MaxConcurrentWait.
+// It reports false when none freed up in time or ctx ended; otherwise the
+// caller must call release once the backend has answered.
+func (l *concurrencyLimiter) acquire(ctx context.Context, backend *docker.Backend) (release func(), ok bool) {
+	key := fmt.Sprintf("%s:%d", backend.ContainerID, backend.Port)
+	l.mu.Lock()
+	slots := l.slots[key]
+	if slots == nil {
+		slots = &backendSlots{sem: make(chan struct{}, backend.MaxConcurrent)}
+		l.slots[key] = slots
+	}
+	slots.users++
+	l.mu.Unlock()
+
+	leave := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		slots.users--
+		if slots.users == 0 {
+			delete(l.slots, key)
+		}
+	}
+	release = func() {
+		<-slots.sem
+		leave()
+	}
+
+	select {
+	case slots.sem <- struct{}{}:
+		return release, true
+	default:
+	}
+	if backend.MaxConcurrentWait <= 0 {
+		leave()
+		return nil, false
+	}
+
+	timer := time.NewTimer(backend.MaxConcurrentWait)
+	defer timer.Stop()
+	select {
+	case slots.sem <- struct{}{}:
+		return release, true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	leave()
+	return nil, false
+}
+
+// serveBusy answers a request that found no free slot of roji.max-concurrent
+func (h *Handler) serveBusy(w http.ResponseWriter, r *http.Request, route *Route, backend *docker.Backend, hostname string, startTime time.Time) {
+	if r.Context().Err() != nil {
+		return // The client gave up waiting
+	}
+	slog.Warn("backend busy, request shed",
+		"host", hostname,
+		"path", r.URL.Path,
+		"container", backend.ContainerName,
+		"max_concurrent", backend.MaxConcurrent)
+
+	duration := time.Since(startTime)
+	h.logRequest(route.Backend.AccessLog, r, accessLogEntry{
+		host:     hostname,
+		status:   http.StatusServiceUnavailable,
+		duration: duration,
+		target:   backend.ServiceName,
+	})
+	h.metrics.observe(route, r, http.StatusServiceUnavailable, duration)
+
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, fmt.Sprintf("%s is busy: %d requests are in flight (roji.max-concurrent)",
+		backend.ContainerName, backend.MaxConcurrent), http.StatusServiceUnavailable)
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+func TestConcurrencyLimited(t *testing.T) {
+	limited := &docker.Backend{MaxConcurrent: 1}
+	tests := []struct {
+		name    string
+		backend *docker.Backend
+		header  map[string]string
+		want    bool
+	}{
+		{"no limit", &docker.Backend{}, nil, false},
+		{"plain request", limited, nil, true},
+		{"websocket upgrade", limited, map[string]string{"Upgrade": "websocket"}, false},
+		{"event stream", limited, map[string]string{"Accept": "text/event-stream"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "https://web.localhost/", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			if got := concurrencyLimited(tt.backend, req); got != tt.want {
+				t.Errorf("concurrencyLimited() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimiter_Acquire(t *testing.T) {
+	l := newConcurrencyLimiter()
+	backend := &docker.Backend{ContainerID: "abc", Port: 5000, MaxConcurrent: 2}
+
+	releaseA, ok := l.acquire(context.Background(), backend)
+	if !ok {
+		t.Fatal("first request should get a slot")
+	}
+	releaseB, ok := l.acquire(context.Background(), backend)
+	if !ok {
+		t.Fatal("second request should get a slot")
+	}
+
+	// Without a wait, the third request is shed at once
+	if _, ok := l.acquire(context.Background(), backend); ok {
+		t.Fatal("third request should be shed")
+	}
+
+	// With a wait, it gets the slot another request frees
+	backend.MaxConcurrentWait = time.Second
+	acquired := make(chan func())
+	go func() {
+		release, ok := l.acquire(context.Background(), backend)
+		if !ok {
+			release = nil
+		}
+		acquired <- release
+	}()
+	time.Sleep(20 * time.Millisecond)
+	releaseA()
+	releaseC := <-acquired
+	if releaseC == nil {
+		t.Fatal("waiting request should get the freed slot")
+	}
+
+	// A wait that runs out sheds the request
+	backend.MaxConcurrentWait = 20 * time.Millisecond
+	if _, ok := l.acquire(context.Background(), backend); ok {
+		t.Error("request should be shed once the wait runs out")
+	}
+
+	releaseB()
+	releaseC()
+	if len(l.slots) != 0 {
+		t.Errorf("idle backends should be forgotten, got %d", len(l.slots))
+	}
+}
+
+func TestHandler_MaxConcurrent(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	unblock := make(chan struct{})
+	backend := newTestBackend(t, "flask.localhost", func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-unblock
+		w.Write([]byte("ok"))
+	})
+	backend.MaxConcurrent = 1
+	backend.MaxConcurrentWait = 5 * time.Second
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	const clients = 3
+	codes := make([]int, clients)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "https://flask.localhost/", nil))
+			codes[i] = w.Code
+		}()
+	}
+	for inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(unblock)
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d: status = %d, want %d", i, code, http.StatusOK)
+		}
+	}
+	if got := peak.Load(); got != 1 {
+		t.Errorf("backend saw %d requests at once, want 1", got)
+	}
+}
+
+func TestHandler_MaxConcurrentSheds(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	backend := newTestBackend(t, "flask.localhost", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-unblock
+	})
+	backend.MaxConcurrent = 1
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://flask.localhost/slow", nil))
+		close(done)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://flask.localhost/", nil))
+	close(unblock)
+	<-done
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kan/roji/config"
+)
+
+// rewriteCookies applies a route's cookie policy (roji.cookie-*) to every
+// Set-Cookie header of a response
+func rewriteCookies(resp *http.Response, policy config.CookiePolicy) {
+	cookies := resp.Header.Values("Set-Cookie")
+	if policy.IsZero() || len(cookies) == 0 {
+		return
+	}
+	resp.Header.Del("Set-Cookie")
+	for _, cookie := range cookies {
+		resp.Header.Add("Set-Cookie", rewriteCookie(cookie, policy))
+	}
+}
+
+// rewriteCookie rewrites the attributes of one Set-Cookie value. Domain is
+// only replaced where the backend set one; host-only cookies stay host-only.
+func rewriteCookie(cookie string, policy config.CookiePolicy) string {
+	parts := strings.Split(cookie, ";")
+	kept := parts[:1]
+	secure := false
+	for _, attr := range parts[1:] {
+		name, _, _ := strings.Cut(strings.TrimSpace(attr), "=")
+		switch {
+		case strings.EqualFold(name, "domain") && policy.Domain == config.CookieDomainStrip:
+			continue
+		case strings.EqualFold(name, "domain") && policy.Domain != "":
+			attr = " Domain=" + policy.Domain
+		case strings.EqualFold(name, "samesite") && policy.SameSite != "":
+			continue // replaced below
+		case strings.EqualFold(name, "secure"):
+			secure = true
+		}
+		kept = append(kept, attr)
+	}
+	if policy.Secure && !secure {
+		kept = append(kept, " Secure")
+	}
+	if policy.SameSite != "" {
+		kept = append(kept, " SameSite="+policy.SameSite)
+	}
+	return strings.Join(kept, ";")
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kan/roji/config"
+)
+
+func TestRewriteCookie(t *testing.T) {
+	tests := []struct {
+		cookie string
+		policy config.CookiePolicy
+		want   string
+	}{
+		{"a=1; Domain=.example.com; Path=/; Secure", config.CookiePolicy{Domain: "strip"}, "a=1; Path=/; Secure"},
+		{"a=1; domain=example.com", config.CookiePolicy{Domain: "strip"}, "a=1"},
+		{"a=1; Domain=.example.com; Path=/", config.CookiePolicy{Domain: ".shop.localhost"}, "a=1; Domain=.shop.localhost; Path=/"},
+		{"a=1; Path=/", config.CookiePolicy{Domain: ".shop.localhost"}, "a=1; Path=/"},
+		{"a=1; Path=/", config.CookiePolicy{Secure: true}, "a=1; Path=/; Secure"},
+		{"a=1; secure", config.CookiePolicy{Secure: true}, "a=1; secure"},
+		{"a=1; SameSite=Strict; HttpOnly", config.CookiePolicy{SameSite: "None", Secure: true}, "a=1; HttpOnly; Secure; SameSite=None"},
+	}
+	for _, tt := range tests {
+		if got := rewriteCookie(tt.cookie, tt.policy); got != tt.want {
+			t.Errorf("rewriteCookie(%q, %+v) = %q, want %q", tt.cookie, tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_CookiePolicy(t *testing.T) {
+	backend := newTestBackend(t, "shop.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "session=abc; Domain=.shop.example.com; Path=/")
+		w.Header().Add("Set-Cookie", "theme=dark; Path=/")
+	})
+	backend.Cookies = config.CookiePolicy{Domain: "strip", SameSite: "Lax"}
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://shop.localhost/", nil))
+	got := w.Header().Values("Set-Cookie")
+	want := []string{"session=abc; Path=/; SameSite=Lax", "theme=dark; Path=/; SameSite=Lax"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Set-Cookie = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sparkline size on the dashboard, in pixels
+const (
+	sparklineWidth  = 120
+	sparklineHeight = 24
+)
+
+// dashboardView is what dashboard.html renders
+type dashboardView struct {
+	Version string
+	Notice  string // startup notice (e.g., waiting for Docker); the page refreshes itself
+
+	Routes    []RouteInfo             // one per replica, with health when probed
+	Traffic   map[string]*trafficView // key: hostname + path prefix
+	CanRotate bool
+	Changes   []ChangelogEntry
+	History   []RouteChange
+	Requests  []RecordedExchange
+
+	LAN *lanView // nil outside LAN mode
+}
+
+// lanView is the pairing box of the dashboard in LAN mode
+type lanView struct {
+	PairingCode    string
+	PairingExpires time.Time
+	Devices        []PairedDevice
+}
+
+// trafficView is the sparkline of a route's last 5 minutes
+type trafficView struct {
+	Requests   string // polyline points, one per 10-second bucket
+	Errors     string // polyline points of 5xx responses; "" without any
+	Total      int
+	ErrorTotal int
+	Peak       int // most requests in one bucket
+}
+
+// newTrafficView scales a route's history to the sparkline. Requests and
+// errors share the scale, so a red line as high as the gray one means every
+// request failed.
+func newTrafficView(history []HistoryBucket) *trafficView {
+	view := &trafficView{}
+	for _, b := range history {
+		view.Total += b.Requests
+		view.ErrorTotal += b.Errors
+		view.Peak = max(view.Peak, b.Requests)
+	}
+	if view.Total == 0 {
+		return nil
+	}
+	view.Requests = sparklinePoints(history, view.Peak, func(b HistoryBucket) int { return b.Requests })
+	if view.ErrorTotal > 0 {
+		view.Errors = sparklinePoints(history, view.Peak, func(b HistoryBucket) int { return b.Errors })
+	}
+	return view
+}
+
+// sparklinePoints returns SVG polyline points for one series, leaving a
+// pixel of margin so the line isn't clipped
+func sparklinePoints(history []HistoryBucket, peak int, value func(HistoryBucket) int) string {
+	points := make([]string, len(history))
+	step := float64(sparklineWidth) / float64(max(len(history)-1, 1))
+	for i, b := range history {
+		y := float64(sparklineHeight-1) - float64(value(b))/float64(peak)*float64(sparklineHeight-2)
+		points[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*step, y)
+	}
+	return strings.Join(points, " ")
+}
+
+// notFoundView is what notfound.html renders
+type notFoundView struct {
+	Hostname      string
+	Routes        []RouteInfo
+	DashboardHost string
+	Notice        string
+}
+
+// dashboardView builds the dashboard from the router and the optional
+// features that contribute to it
+func (h *Handler) dashboardView() dashboardView {
+	view := dashboardView{
+		Version:   h.statusConfig.Version,
+		Notice:    h.StartupNotice(),
+		Routes:    h.routesWithState(),
+		CanRotate: h.rotator != nil,
+	}
+	view.Traffic = make(map[string]*trafficView)
+	for _, m := range h.metrics.snapshot(time.Now()).Routes {
+		if traffic := newTrafficView(m.History); traffic != nil {
+			view.Traffic[m.Hostname+m.PathPrefix] = traffic
+		}
+	}
+	if h.changelog != nil {
+		view.Changes = h.changelog.Entries()
+	}
+	if h.history != nil {
+		view.History = h.history.Entries("", dashboardHistory)
+	}
+	if h.recorder != nil {
+		view.Requests = h.recorder.Recent("", dashboardRequests)
+	}
+	if h.devices != nil {
+		lan := &lanView{Devices: h.devices.Devices()}
+		lan.PairingCode, lan.PairingExpires = h.devices.PairingCode()
+		view.LAN = lan
+	}
+	return view
+}
+
+// routesWithState lists the routes with their latest probe results and
+// maintenance mode
+func (h *Handler) routesWithState() []RouteInfo {
+	routes := h.router.ListRoutes()
+	for i := range routes {
+		if _, ok := h.maintenance.started(routes[i].Hostname); ok {
+			routes[i].Maintenance = true
+		}
+		if h.prober == nil {
+			continue
+		}
+		if result, ok := h.prober.Result(routes[i].Target); ok {
+			routes[i].Health = &result
+		}
+	}
+	return routes
+}
+
+func (h *Handler) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, http.StatusOK, "dashboard.html", h.dashboardView())
+}
+
+func (h *Handler) handleNotFound(w http.ResponseWriter, r *http.Request, hostname string) {
+	slog.Warn("no route found",
+		"hostname", hostname,
+		"path", r.URL.Path)
+
+	renderPage(w, http.StatusNotFound, "notfound.html", notFoundView{
+		Hostname:      hostname,
+		Routes:        h.router.ListRoutes(),
+		DashboardHost: h.dashboardHost,
+		Notice:        h.StartupNotice(),
+	})
+}
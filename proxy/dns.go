@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsTTL keeps resolvers asking again soon, e.g., after --domain changes
+const dnsTTL = 5
+
+// DNSServer answers the domains roji serves with this machine's loopback
+// address (--dns-port), for resolvers pointed at it like macOS's
+// /etc/resolver files (roji resolver). Queries for other names are refused,
+// so it can't stand in for a real resolver.
+type DNSServer struct {
+	domains []string
+}
+
+// NewDNSServer creates a server answering domains and their subdomains
+func NewDNSServer(domains ...string) *DNSServer {
+	var lower []string
+	for _, domain := range domains {
+		if domain = strings.ToLower(strings.TrimSuffix(domain, ".")); domain != "" && !slices.Contains(lower, domain) {
+			lower = append(lower, domain)
+		}
+	}
+	return &DNSServer{domains: lower}
+}
+
+// Serve answers queries on conn until it is closed
+func (s *DNSServer) Serve(conn net.PacketConn) error {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		response := s.answer(buf[:n])
+		if response == nil {
+			continue
+		}
+		if _, err := conn.WriteTo(response, addr); err != nil {
+			slog.Debug("failed to send DNS response", "to", addr, "error", err)
+		}
+	}
+}
+
+// serves reports whether name is one of the domains or under one
+func (s *DNSServer) serves(name string) bool {
+	for _, domain := range s.domains {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// answer builds the response to a query: the A record of 127.0.0.1 for
+// roji's names, no records of other types (roji may not listen on IPv6), and
+// REFUSED for the rest. It returns nil for packets that aren't queries.
+func (s *DNSServer) answer(query []byte) []byte {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil || header.Response {
+		return nil
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return nil
+	}
+
+	response := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               header.ID,
+			Response:         true,
+			Authoritative:    true,
+			RecursionDesired: header.RecursionDesired,
+		},
+		Questions: questions,
+	}
+	if header.OpCode != 0 || len(questions) != 1 {
+		response.RCode = dnsmessage.RCodeNotImplemented
+	} else {
+		q := questions[0]
+		name := strings.ToLower(strings.TrimSuffix(q.Name.String(), "."))
+		switch {
+		case !s.serves(name):
+			response.RCode = dnsmessage.RCodeRefused
+		case q.Class == dnsmessage.ClassINET && (q.Type == dnsmessage.TypeA || q.Type == dnsmessage.TypeALL):
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: dnsTTL},
+				Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+			})
+		}
+	}
+	packet, err := response.Pack()
+	if err != nil {
+		return nil
+	}
+	return packet
+}
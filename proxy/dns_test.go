@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSServer_Answer(t *testing.T) {
+	s := NewDNSServer("test", "Shop.Example.", "test")
+
+	tests := []struct {
+		name      string
+		qtype     dnsmessage.Type
+		wantRCode dnsmessage.RCode
+		wantA     bool
+	}{
+		{"test.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, true},
+		{"web.app.test.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, true},
+		{"api.shop.example.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, true},
+		{"web.app.test.", dnsmessage.TypeAAAA, dnsmessage.RCodeSuccess, false},
+		{"example.com.", dnsmessage.TypeA, dnsmessage.RCodeRefused, false},
+		{"nottest.", dnsmessage.TypeA, dnsmessage.RCodeRefused, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.qtype.String(), func(t *testing.T) {
+			query := dnsmessage.Message{
+				Header: dnsmessage.Header{ID: 42, RecursionDesired: true},
+				Questions: []dnsmessage.Question{
+					{Name: dnsmessage.MustNewName(tt.name), Type: tt.qtype, Class: dnsmessage.ClassINET},
+				},
+			}
+			packet, err := query.Pack()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var msg dnsmessage.Message
+			if err := msg.Unpack(s.answer(packet)); err != nil {
+				t.Fatal(err)
+			}
+			if msg.ID != 42 || !msg.Response || len(msg.Questions) != 1 {
+				t.Fatalf("unexpected response: %+v", msg)
+			}
+			if msg.RCode != tt.wantRCode {
+				t.Errorf("RCode = %v, want %v", msg.RCode, tt.wantRCode)
+			}
+			if got := len(msg.Answers) == 1; got != tt.wantA {
+				t.Fatalf("answers = %v", msg.Answers)
+			}
+			if tt.wantA {
+				a := msg.Answers[0].Body.(*dnsmessage.AResource)
+				if !net.IP(a.A[:]).IsLoopback() {
+					t.Errorf("A = %v, want 127.0.0.1", net.IP(a.A[:]))
+				}
+			}
+		})
+	}
+}
+
+func TestDNSServer_Serve(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go NewDNSServer("test").Serve(conn)
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp4", conn.LocalAddr().String())
+		},
+	}
+	addrs, err := resolver.LookupHost(t.Context(), "web.app.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "127.0.0.1" {
+		t.Errorf("addrs = %v, want [127.0.0.1]", addrs)
+	}
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DrainStatus describes in-flight requests and shutdown progress
+type DrainStatus struct {
+	Draining      bool           `json:"draining"`
+	Deadline      *time.Time     `json:"deadline,omitempty"`
+	RemainingSecs *float64       `json:"remaining_seconds,omitempty"`
+	InFlight      int            `json:"in_flight"`
+	PerRoute      map[string]int `json:"per_route,omitempty"`
+}
+
+// inflightTracker counts proxied requests currently being served, per route
+type inflightTracker struct {
+	mu       sync.Mutex
+	perRoute map[string]int
+	total    int
+	draining bool
+	deadline time.Time
+}
+
+func newInflightTracker() *inflightTracker {
+	return &inflightTracker{perRoute: make(map[string]int)}
+}
+
+// begin records the start of a request to hostname; call the returned func when done
+func (t *inflightTracker) begin(hostname string) func() {
+	t.mu.Lock()
+	t.perRoute[hostname]++
+	t.total++
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.total--
+		if t.perRoute[hostname]--; t.perRoute[hostname] <= 0 {
+			delete(t.perRoute, hostname)
+		}
+	}
+}
+
+// status returns a snapshot of the current in-flight state
+func (t *inflightTracker) status() DrainStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := DrainStatus{
+		Draining: t.draining,
+		InFlight: t.total,
+	}
+	if len(t.perRoute) > 0 {
+		status.PerRoute = make(map[string]int, len(t.perRoute))
+		for host, n := range t.perRoute {
+			status.PerRoute[host] = n
+		}
+	}
+	if t.draining {
+		deadline := t.deadline
+		remaining := time.Until(deadline).Seconds()
+		if remaining < 0 {
+			remaining = 0
+		}
+		status.Deadline = &deadline
+		status.RemainingSecs = &remaining
+	}
+	return status
+}
+
+// BeginDrain marks the handler as shutting down; in-flight requests are
+// reported against the given deadline from then on. Live reload and event
+// streams end right away.
+func (h *Handler) BeginDrain(deadline time.Time) {
+	if h.liveReload != nil {
+		h.liveReload.close()
+	}
+	if h.events != nil {
+		h.events.close()
+	}
+
+	h.inflight.mu.Lock()
+	defer h.inflight.mu.Unlock()
+
+	h.inflight.draining = true
+	h.inflight.deadline = deadline
+}
+
+// DrainStatus returns the current in-flight request counts and drain progress
+func (h *Handler) DrainStatus() DrainStatus {
+	return h.inflight.status()
+}
+
+// Hostnames returns the hostnames with in-flight requests, sorted
+func (s DrainStatus) Hostnames() []string {
+	hosts := make([]string, 0, len(s.PerRoute))
+	for host := range s.PerRoute {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
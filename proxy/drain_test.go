@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInflightTracker(t *testing.T) {
+	tracker := newInflightTracker()
+
+	doneA := tracker.begin("a.localhost")
+	doneB1 := tracker.begin("b.localhost")
+	doneB2 := tracker.begin("b.localhost")
+
+	status := tracker.status()
+	if status.InFlight != 3 {
+		t.Errorf("InFlight = %d, want 3", status.InFlight)
+	}
+	if status.PerRoute["b.localhost"] != 2 {
+		t.Errorf("PerRoute[b.localhost] = %d, want 2", status.PerRoute["b.localhost"])
+	}
+
+	doneA()
+	doneB1()
+	doneB2()
+
+	status = tracker.status()
+	if status.InFlight != 0 || len(status.PerRoute) != 0 {
+		t.Errorf("expected no in-flight requests, got %+v", status)
+	}
+}
+
+func TestHandler_DrainStatus(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig())
+	done := handler.inflight.begin("web.localhost")
+	defer done()
+
+	req := httptest.NewRequest("GET", "https://roji.localhost/_api/status", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var status StatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if status.Proxy.InFlightRequests != 1 {
+		t.Errorf("in_flight_requests = %d, want 1", status.Proxy.InFlightRequests)
+	}
+
+	handler.BeginDrain(time.Now().Add(5 * time.Second))
+	drain := handler.DrainStatus()
+	if !drain.Draining || drain.InFlight != 1 {
+		t.Fatalf("drain status = %+v, want draining with 1 in flight", drain)
+	}
+	if drain.RemainingSecs == nil || *drain.RemainingSecs <= 0 {
+		t.Errorf("expected positive remaining seconds, got %v", drain.RemainingSecs)
+	}
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kan/roji/config"
+)
+
+// EmbedHeader marks responses whose framing headers roji changed, so the
+// modification is visible in browser devtools
+const EmbedHeader = "X-Roji-Embed"
+
+// applyEmbedPolicy rewrites the headers that prevent framing (roji.embed).
+// "relax" lets routes under the base domain, and only them, frame the
+// response; "strip" removes CSP and X-Frame-Options altogether. Dev only:
+// never deploy this.
+func applyEmbedPolicy(policy, baseDomain string, header http.Header) {
+	switch policy {
+	case config.EmbedStrip:
+		header.Del("Content-Security-Policy")
+		header.Del("Content-Security-Policy-Report-Only")
+		header.Del("X-Frame-Options")
+	case config.EmbedRelax:
+		ancestors := "frame-ancestors 'self' https://" + baseDomain + " https://*." + baseDomain
+		limited := false
+		if csp := header.Values("Content-Security-Policy"); len(csp) > 0 {
+			header.Del("Content-Security-Policy")
+			for _, policy := range csp {
+				policy, replaced := withFrameAncestors(policy, ancestors)
+				header.Add("Content-Security-Policy", policy)
+				limited = limited || replaced
+			}
+		}
+		// X-Frame-Options can only name the page's own origin; its CSP
+		// equivalent keeps framing limited to the base domain
+		if header.Get("X-Frame-Options") != "" && !limited {
+			header.Add("Content-Security-Policy", ancestors)
+		}
+		header.Del("X-Frame-Options")
+	default:
+		return
+	}
+	header.Set(EmbedHeader, policy)
+}
+
+// withFrameAncestors replaces the frame-ancestors directive of a CSP,
+// reporting whether it had one. Policies without one don't restrict framing
+// and are returned unchanged.
+func withFrameAncestors(csp, ancestors string) (string, bool) {
+	var directives []string
+	replaced := false
+	for _, directive := range strings.Split(csp, ";") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(directive, " ")
+		if strings.EqualFold(name, "frame-ancestors") {
+			directive = ancestors
+			replaced = true
+		}
+		directives = append(directives, directive)
+	}
+	if !replaced {
+		return csp, false
+	}
+	return strings.Join(directives, "; "), true
+}
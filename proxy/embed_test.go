@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestApplyEmbedPolicy(t *testing.T) {
+	newHeader := func() http.Header {
+		h := http.Header{}
+		h.Set("X-Frame-Options", "DENY")
+		h.Add("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		h.Add("Content-Security-Policy", "script-src 'self'")
+		h.Set("Content-Security-Policy-Report-Only", "frame-ancestors 'none'")
+		return h
+	}
+
+	t.Run("relax", func(t *testing.T) {
+		h := newHeader()
+		applyEmbedPolicy("relax", "dev.localhost", h)
+
+		if h.Get("X-Frame-Options") != "" {
+			t.Error("X-Frame-Options should be removed")
+		}
+		csp := h.Values("Content-Security-Policy")
+		want := []string{
+			"default-src 'self'; frame-ancestors 'self' https://dev.localhost https://*.dev.localhost",
+			"script-src 'self'",
+		}
+		if len(csp) != 2 || csp[0] != want[0] || csp[1] != want[1] {
+			t.Errorf("Content-Security-Policy = %q, want %q", csp, want)
+		}
+		if h.Get(EmbedHeader) != "relax" {
+			t.Errorf("%s = %q, want relax", EmbedHeader, h.Get(EmbedHeader))
+		}
+	})
+
+	t.Run("strip", func(t *testing.T) {
+		h := newHeader()
+		applyEmbedPolicy("strip", "dev.localhost", h)
+
+		for _, name := range []string{"X-Frame-Options", "Content-Security-Policy", "Content-Security-Policy-Report-Only"} {
+			if h.Get(name) != "" {
+				t.Errorf("%s should be removed", name)
+			}
+		}
+		if h.Get(EmbedHeader) != "strip" {
+			t.Errorf("%s = %q, want strip", EmbedHeader, h.Get(EmbedHeader))
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		h := newHeader()
+		applyEmbedPolicy("", "dev.localhost", h)
+		if h.Get("X-Frame-Options") != "DENY" || h.Get(EmbedHeader) != "" {
+			t.Error("headers should be unchanged without roji.embed")
+		}
+	})
+}
+
+func TestHandler_EmbedPolicy(t *testing.T) {
+	backend := newTestBackend(t, "widget.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		w.Write([]byte("widget"))
+	})
+	backend.Embed = "relax"
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	req := httptest.NewRequest("GET", "https://widget.localhost/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("X-Frame-Options") != "" {
+		t.Error("X-Frame-Options should be removed for roji.embed=relax")
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); csp != "frame-ancestors 'self' https://localhost https://*.localhost" {
+		t.Errorf("Content-Security-Policy = %q, want framing limited to the base domain", csp)
+	}
+
+	// The dashboard badges modified routes
+	req = httptest.NewRequest("GET", "https://roji.localhost/", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "CSP relaxed") {
+		t.Error("dashboard should badge routes with roji.embed")
+	}
+}
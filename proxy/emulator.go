@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"strings"
+)
+
+// EmulatorBridge is the address an emulator uses to reach the host machine
+type EmulatorBridge struct {
+	Platform string
+	IP       string
+}
+
+// EmulatorBridges are the well-known host addresses of common emulators.
+// *.localhost resolves to the emulator itself, so routes are exposed under
+// <ip>.nip.io instead, which public DNS resolves to the bridge address.
+var EmulatorBridges = []EmulatorBridge{
+	{Platform: "Android Emulator", IP: "10.0.2.2"},
+	{Platform: "Genymotion", IP: "10.0.3.2"},
+	{Platform: "iOS Simulator", IP: "127.0.0.1"},
+}
+
+// EmulatorDomain returns the wildcard DNS domain that resolves to ip
+func EmulatorDomain(ip string) string {
+	return ip + ".nip.io"
+}
+
+// EmulatorHostname rewrites a route hostname for an emulator bridge
+// (e.g., web.app.dev.localhost -> web.app.10.0.2.2.nip.io)
+func EmulatorHostname(hostname, baseDomain, ip string) string {
+	domain := EmulatorDomain(ip)
+	if hostname == baseDomain {
+		return domain
+	}
+	return strings.TrimSuffix(hostname, "."+baseDomain) + "." + domain
+}
+
+// EmulatorCertNames returns the certificate names needed for emulator access
+func EmulatorCertNames() []string {
+	var names []string
+	for _, bridge := range EmulatorBridges {
+		domain := EmulatorDomain(bridge.IP)
+		names = append(names, domain, "*."+domain, "*.*."+domain, bridge.IP)
+	}
+	return names
+}
+
+// WithEmulatorAccess serves routes under the emulator bridge domains
+// (<route>.10.0.2.2.nip.io) and the dashboard on the bridge IPs
+func WithEmulatorAccess(enabled bool) HandlerOption {
+	return func(h *Handler) {
+		h.emulators = enabled
+	}
+}
+
+// fromEmulatorHostname maps an emulator or LAN hostname back to the route
+// hostname. Bridge IPs, the LAN address and its mDNS name, and bare bridge
+// domains map to the dashboard.
+func (h *Handler) fromEmulatorHostname(hostname string) string {
+	var ips []string
+	if h.emulators {
+		for _, bridge := range EmulatorBridges {
+			ips = append(ips, bridge.IP)
+		}
+	}
+	if h.lanIP != "" {
+		ips = append(ips, h.lanIP)
+	}
+	if h.lanName != "" && hostname == h.lanName {
+		return h.dashboardHost
+	}
+	baseDomain := h.statusConfig.BaseDomain
+
+	for _, ip := range ips {
+		domain := EmulatorDomain(ip)
+		switch {
+		case hostname == domain:
+			return baseDomain
+		case strings.HasSuffix(hostname, "."+domain):
+			return strings.TrimSuffix(hostname, "."+domain) + "." + baseDomain
+		case hostname == ip && ip != "127.0.0.1":
+			return h.dashboardHost
+		}
+	}
+	return hostname
+}
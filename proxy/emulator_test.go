@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmulatorHostname(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{"web.app.dev.localhost", "web.app.10.0.2.2.nip.io"},
+		{"dev.localhost", "10.0.2.2.nip.io"},
+	}
+	for _, tt := range tests {
+		if got := EmulatorHostname(tt.hostname, "dev.localhost", "10.0.2.2"); got != tt.want {
+			t.Errorf("EmulatorHostname(%q) = %q, want %q", tt.hostname, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_EmulatorAccess(t *testing.T) {
+	backend := newTestBackend(t, "web.app.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("web"))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+
+	tests := []struct {
+		name     string
+		host     string
+		enabled  bool
+		wantCode int
+		wantBody string
+	}{
+		{"android emulator", "web.app.10.0.2.2.nip.io", true, http.StatusOK, "web"},
+		{"genymotion", "web.app.10.0.3.2.nip.io", true, http.StatusOK, "web"},
+		{"ios simulator", "web.app.127.0.0.1.nip.io", true, http.StatusOK, "web"},
+		{"disabled", "web.app.10.0.2.2.nip.io", false, http.StatusMisdirectedRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithEmulatorAccess(tt.enabled))
+			req := httptest.NewRequest("GET", "https://"+tt.host+"/", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandler_EmulatorDashboard(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithEmulatorAccess(true))
+
+	req := httptest.NewRequest("GET", "https://10.0.2.2/_api/health", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("dashboard via bridge IP: status = %d, want 200", rec.Code)
+	}
+}
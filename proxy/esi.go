@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ESIHeader reports how many includes roji resolved in a response
+const ESIHeader = "X-Roji-ESI"
+
+const (
+	// esiMaxDepth bounds nested includes (fragments that include fragments)
+	esiMaxDepth = 3
+	// esiFragmentTimeout bounds each fragment fetch
+	esiFragmentTimeout = 10 * time.Second
+)
+
+var (
+	// <esi:include src="..." alt="..." onerror="continue"/>, self-closing or not
+	esiIncludeRe = regexp.MustCompile(`(?is)<esi:include\b([^>]*?)/?>(?:\s*</esi:include>)?`)
+	// <esi:remove>fallback for clients without ESI</esi:remove>
+	esiRemoveRe = regexp.MustCompile(`(?is)<esi:remove>.*?</esi:remove>`)
+	// <!--esi ...markup only shown when ESI is processed... -->
+	esiCommentRe = regexp.MustCompile(`(?s)<!--esi(.*?)-->`)
+	esiAttrRe    = regexp.MustCompile(`(?s)([\w-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// esiDepthKey carries the include depth of internal fragment requests
+type esiDepthKey struct{}
+
+// esiFragment reports whether r fetches a fragment for an ESI page. The
+// assembled page gets the markup roji adds, not each fragment in it.
+func esiFragment(r *http.Request) bool {
+	_, ok := r.Context().Value(esiDepthKey{}).(int)
+	return ok
+}
+
+// assembleESI replaces the body of an HTML response with its ESI tags
+// resolved. Fragments are fetched through the handler itself, so src may
+// name any route (https://header.localhost/) or a path on the same host.
+// Only whole pages are assembled (see fullPage).
+func (h *Handler) assembleESI(r *http.Request, resp *http.Response) error {
+	if !fullPage(resp) {
+		return nil
+	}
+	depth, _ := r.Context().Value(esiDepthKey{}).(int)
+	return rewriteHTML(resp, func(body []byte) []byte {
+		body, includes := h.processESI(r, body, depth)
+		resp.Header.Set(ESIHeader, strconv.Itoa(includes))
+		return body
+	})
+}
+
+// processESI resolves the ESI markup in body and returns the number of
+// include tags it processed
+func (h *Handler) processESI(r *http.Request, body []byte, depth int) ([]byte, int) {
+	body = esiRemoveRe.ReplaceAll(body, nil)
+	body = esiCommentRe.ReplaceAll(body, []byte("$1"))
+
+	includes := 0
+	body = esiIncludeRe.ReplaceAllFunc(body, func(tag []byte) []byte {
+		includes++
+		attrs := esiAttrs(esiIncludeRe.FindSubmatch(tag)[1])
+
+		if depth >= esiMaxDepth {
+			return esiComment("include depth exceeded for " + attrs["src"])
+		}
+		fragment, err := h.fetchFragment(r, attrs["src"], depth)
+		if err != nil && attrs["alt"] != "" {
+			fragment, err = h.fetchFragment(r, attrs["alt"], depth)
+		}
+		if err != nil {
+			slog.Warn("esi include failed", "page", r.Host+r.URL.Path, "src", attrs["src"], "error", err)
+			if strings.EqualFold(attrs["onerror"], "continue") {
+				return nil
+			}
+			return esiComment(err.Error())
+		}
+		return fragment
+	})
+	return body, includes
+}
+
+// fetchFragment serves src through the handler with the page's cookies and
+// credentials
+func (h *Handler) fetchFragment(page *http.Request, src string, depth int) ([]byte, error) {
+	if src == "" {
+		return nil, fmt.Errorf("esi:include without src")
+	}
+	base := &url.URL{Scheme: forwardedProto(page), Host: page.Host, Path: page.URL.Path}
+	target, err := base.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid src %q: %w", src, err)
+	}
+
+	ctx, cancel := context.WithTimeout(page.Context(), esiFragmentTimeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, esiDepthKey{}, depth+1)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = page.RemoteAddr
+	req.TLS = page.TLS
+	for _, name := range []string{"Cookie", "Authorization", TunnelSecretHeader} {
+		if value := page.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	req.Header.Set("User-Agent", page.Header.Get("User-Agent"))
+
+	w := &fragmentWriter{header: make(http.Header), status: http.StatusOK}
+	h.ServeHTTP(w, req)
+	if w.status != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", target, w.status)
+	}
+	return w.body.Bytes(), nil
+}
+
+// esiAttrs parses the attributes of an ESI tag
+func esiAttrs(raw []byte) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range esiAttrRe.FindAllSubmatch(raw, -1) {
+		value := m[2]
+		if value == nil {
+			value = m[3]
+		}
+		attrs[strings.ToLower(string(m[1]))] = html.UnescapeString(string(value))
+	}
+	return attrs
+}
+
+// esiComment reports a failed include in the page source
+func esiComment(msg string) []byte {
+	return []byte("<!-- roji esi: " + strings.ReplaceAll(msg, "--", "- -") + " -->")
+}
+
+// fragmentWriter buffers an internally served fragment
+type fragmentWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *fragmentWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *fragmentWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+func (w *fragmentWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Flush is a no-op; the fragment is inlined once complete
+func (w *fragmentWriter) Flush() {}
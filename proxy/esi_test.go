@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_ESI(t *testing.T) {
+	page := newTestBackend(t, "shop.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/":
+			io.WriteString(w, `<body>`+
+				`<esi:include src="https://header.localhost/fragment" />`+
+				`<esi:include src="/footer"></esi:include>`+
+				`<esi:include src="https://missing.localhost/" onerror="continue"/>`+
+				`<esi:include src="https://missing.localhost/" alt="/footer"/>`+
+				`<esi:remove>no esi</esi:remove>`+
+				`<!--esi <p>esi only</p> -->`+
+				`</body>`)
+		case "/footer":
+			io.WriteString(w, "<footer/>")
+		case "/loop":
+			io.WriteString(w, `<esi:include src="/loop"/>`)
+		}
+	})
+	page.ContainerID = "shop1"
+	page.ESI = true
+	header := newTestBackend(t, "header.localhost", func(w http.ResponseWriter, r *http.Request) {
+		cookie, _ := r.Cookie("session")
+		io.WriteString(w, "<header>"+cookie.Value+"</header>")
+	})
+	header.ContainerID = "header1"
+
+	router := NewRouter()
+	router.AddBackend(page)
+	router.AddBackend(header)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "https://shop.localhost"+path, nil)
+		req.Host = "shop.localhost"
+		req.Header.Set("Cookie", "session=abc")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/")
+	want := "<body><header>abc</header><footer/><footer/> <p>esi only</p> </body>"
+	if got := w.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	if got := w.Header().Get(ESIHeader); got != "4" {
+		t.Errorf("%s = %q, want 4", ESIHeader, got)
+	}
+
+	// Self-including fragments stop at the depth limit
+	w = get("/loop")
+	if got := w.Body.String(); !strings.Contains(got, "<!-- roji esi: include depth exceeded") {
+		t.Errorf("body = %q, want a depth-exceeded comment", got)
+	}
+}
+
+func TestHandler_ESI_FailedInclude(t *testing.T) {
+	page := newTestBackend(t, "shop.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<esi:include src="https://missing.localhost/"/>`)
+	})
+	page.ESI = true
+
+	router := NewRouter()
+	router.AddBackend(page)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	req := httptest.NewRequest("GET", "https://shop.localhost/", nil)
+	req.Host = "shop.localhost"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Body.String(); !strings.HasPrefix(got, "<!-- roji esi: https://missing.localhost/ returned 404") {
+		t.Errorf("body = %q, want an error comment", got)
+	}
+}
+
+func TestHandler_ESI_OnlyFullPages(t *testing.T) {
+	page := newTestBackend(t, "shop.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", "bytes 0-29/100")
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		io.WriteString(w, `<esi:include src="/footer"/>`)
+	})
+	page.ESI = true
+
+	router := NewRouter()
+	router.AddBackend(page)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	for _, method := range []string{"HEAD", "GET"} {
+		req := httptest.NewRequest(method, "https://shop.localhost/", nil)
+		req.Header.Set("Range", "bytes=0-29")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if got := w.Header().Get(ESIHeader); got != "" {
+			t.Errorf("%s: %s = %q, want partial content left alone", method, ESIHeader, got)
+		}
+	}
+}
+
+func TestHandler_ESI_LiveReloadOnce(t *testing.T) {
+	page := newTestBackend(t, "shop.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			io.WriteString(w, `<body><esi:include src="/header"/><esi:include src="/footer"/></body>`)
+			return
+		}
+		io.WriteString(w, "<body>"+r.URL.Path+"</body>")
+	})
+	page.ESI = true
+	page.LiveReload = true
+
+	router := NewRouter()
+	router.AddBackend(page)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithLiveReload(NewLiveReload()))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://shop.localhost/", nil))
+	if n := strings.Count(w.Body.String(), liveReloadScriptPath); n != 1 {
+		t.Errorf("page has %d reload scripts, want 1: %q", n, w.Body)
+	}
+}
+
+func TestHandler_ESI_BannerOnce(t *testing.T) {
+	page := newTestBackend(t, "shop.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			io.WriteString(w, `<body><esi:include src="/header"/><esi:include src="https://footer.localhost/"/></body>`)
+			return
+		}
+		io.WriteString(w, "<body>"+r.URL.Path+"</body>")
+	})
+	page.ESI = true
+	page.Banner = "Shop preview"
+	footer := newTestBackend(t, "footer.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<body>footer</body>")
+	})
+	footer.ContainerID = "footer1"
+
+	router := NewRouter()
+	router.AddBackend(page)
+	router.AddBackend(footer)
+	// --banner covers the footer route, roji.banner the page
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithBanner("true"))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://shop.localhost/", nil))
+	if n := strings.Count(w.Body.String(), `id="roji-banner"`); n != 1 {
+		t.Errorf("page has %d banners, want 1: %q", n, w.Body)
+	}
+}
+
+func TestRewritableHTML(t *testing.T) {
+	tests := []struct {
+		contentType string
+		encoding    string
+		want        bool
+	}{
+		{"text/html; charset=utf-8", "", true},
+		{"text/html", "gzip", true},
+		{"text/html", "br", false},
+		{"application/json", "", false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Content-Type", tt.contentType)
+		if tt.encoding != "" {
+			resp.Header.Set("Content-Encoding", tt.encoding)
+		}
+		if got := rewritableHTML(resp); got != tt.want {
+			t.Errorf("rewritableHTML(%q, %q) = %v, want %v", tt.contentType, tt.encoding, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types streamed by /_api/events
+const (
+	EventRouteAdded       = "route_added"
+	EventRouteUpdated     = "route_updated" // new target, or paused/unpaused
+	EventRouteRemoved     = "route_removed"
+	EventBackendUnhealthy = "backend_unhealthy"
+	EventBackendHealthy   = "backend_healthy" // recovered after backend_unhealthy
+	EventErrorRate        = "error_rate"      // error rate alert firing or resolved
+	EventCertRenewed      = "cert_renewed"
+)
+
+const (
+	// eventBacklog is how many past events are replayed to clients that
+	// reconnect with Last-Event-ID
+	eventBacklog = 256
+	// eventBuffer is how many events a slow subscriber may lag behind
+	// before it is disconnected
+	eventBuffer = 64
+	// eventKeepAlive keeps idle SSE streams open through other proxies
+	eventKeepAlive = 30 * time.Second
+)
+
+// Event is a change of roji's state, for editor extensions, status bars
+// and scripts
+type Event struct {
+	ID         uint64    `json:"id"`
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Hostname   string    `json:"hostname,omitempty"`
+	PathPrefix string    `json:"path_prefix,omitempty"`
+
+	Route    *RouteInfo      `json:"route,omitempty"`    // route_* events: the replica
+	Previous *RouteInfo      `json:"previous,omitempty"` // route_updated events: the replica before the change
+	Target   string          `json:"target,omitempty"`   // backend_* events: the probed target
+	Health   *BackendHealth  `json:"health,omitempty"`   // backend_* events
+	Alert    *ErrorRateAlert `json:"alert,omitempty"`    // error_rate events
+	Cert     *CertInfo       `json:"cert,omitempty"`     // cert_renewed events: the new server certificate
+}
+
+// EventHub fans events out to /_api/events streams and keeps a short
+// backlog for clients that reconnect
+type EventHub struct {
+	mu          sync.Mutex
+	nextID      uint64
+	backlog     []Event // ring buffer of the last eventBacklog events
+	subscribers map[chan Event]struct{}
+
+	closeOnce sync.Once
+	closed    chan struct{} // ends all streams on shutdown
+}
+
+// NewEventHub creates a hub without subscribers
+func NewEventHub() *EventHub {
+	return &EventHub{
+		nextID:      1,
+		subscribers: make(map[chan Event]struct{}),
+		closed:      make(chan struct{}),
+	}
+}
+
+// WithEvents serves /_api/events and publishes error rate alerts to the hub
+func WithEvents(hub *EventHub) HandlerOption {
+	return func(h *Handler) {
+		h.events = hub
+	}
+}
+
+// Publish stamps an event with an ID and time and sends it to every
+// stream. Subscribers too slow to keep up are dropped; they reconnect and
+// catch up from the backlog. A nil hub discards events.
+func (hub *EventHub) Publish(e Event) {
+	if hub == nil {
+		return
+	}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	e.ID = hub.nextID
+	hub.nextID++
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if len(hub.backlog) < eventBacklog {
+		hub.backlog = append(hub.backlog, e)
+	} else {
+		hub.backlog[(e.ID-1)%eventBacklog] = e
+	}
+
+	for ch := range hub.subscribers {
+		select {
+		case ch <- e:
+		default:
+			slog.Debug("dropping slow event subscriber")
+			delete(hub.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns a stream of events after lastID (0 = only new events)
+func (hub *EventHub) subscribe(lastID uint64) chan Event {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	ch := make(chan Event, eventBuffer+eventBacklog)
+	if lastID > 0 {
+		var missed []Event
+		for _, e := range hub.backlog {
+			if e.ID > lastID {
+				missed = append(missed, e)
+			}
+		}
+		slices.SortFunc(missed, func(a, b Event) int { return cmp.Compare(a.ID, b.ID) })
+		for _, e := range missed {
+			ch <- e
+		}
+	}
+	hub.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (hub *EventHub) unsubscribe(ch chan Event) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if _, ok := hub.subscribers[ch]; ok {
+		delete(hub.subscribers, ch)
+		close(ch)
+	}
+}
+
+// close ends all streams so they don't hold up a graceful shutdown
+func (hub *EventHub) close() {
+	hub.closeOnce.Do(func() {
+		close(hub.closed)
+	})
+}
+
+// WatchRoutes publishes route_added, route_updated and route_removed in the
+// background as the router changes, until ctx is done. Routes present when
+// it is called aren't reported.
+func (hub *EventHub) WatchRoutes(ctx context.Context, router *Router) {
+	changed := make(chan struct{}, 1)
+	router.OnChange(func() {
+		select {
+		case changed <- struct{}{}:
+		default: // a diff is already pending
+		}
+	})
+
+	routeKey := func(ri RouteInfo) string {
+		return ri.Hostname + ri.PathPrefix + " " + ri.ContainerName
+	}
+	known := make(map[string]RouteInfo)
+	for _, ri := range router.ListRoutes() {
+		known[routeKey(ri)] = ri
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+
+			current := make(map[string]RouteInfo)
+			for _, ri := range router.ListRoutes() {
+				key := routeKey(ri)
+				current[key] = ri
+				prev, ok := known[key]
+				switch {
+				case !ok:
+					hub.publishRoute(EventRouteAdded, ri)
+				case prev.Target != ri.Target || prev.Paused != ri.Paused || prev.RedirectTo != ri.RedirectTo:
+					hub.Publish(Event{Type: EventRouteUpdated, Hostname: ri.Hostname, PathPrefix: ri.PathPrefix, Route: &ri, Previous: &prev})
+				}
+			}
+			for key, ri := range known {
+				if _, ok := current[key]; !ok {
+					hub.publishRoute(EventRouteRemoved, ri)
+				}
+			}
+			known = current
+		}
+	}()
+}
+
+func (hub *EventHub) publishRoute(eventType string, ri RouteInfo) {
+	hub.Publish(Event{Type: eventType, Hostname: ri.Hostname, PathPrefix: ri.PathPrefix, Route: &ri})
+}
+
+// serveEventsAPI streams events (GET) as server-sent events, or as
+// newline-delimited JSON with ?format=ndjson or Accept: application/x-ndjson
+func (h *Handler) serveEventsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.events == nil {
+		http.Error(w, "events are not enabled", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ndjson := r.URL.Query().Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+
+	// EventSource sends Last-Event-ID when it reconnects
+	lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	if since := r.URL.Query().Get("since"); since != "" {
+		lastID, _ = strconv.ParseUint(since, 10, 64)
+	}
+	ch := h.events.subscribe(lastID)
+	defer h.events.unsubscribe(ch)
+
+	w.Header().Set("Cache-Control", "no-store")
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": connected\n\n")
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.events.closed:
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return // too slow; the client reconnects with Last-Event-ID
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				slog.Error("failed to encode event", "error", err)
+				continue
+			}
+			if ndjson {
+				fmt.Fprintf(w, "%s\n", data)
+			} else {
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if !ndjson {
+				fmt.Fprint(w, ": ping\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+// nextEvent waits for an event on a subscription
+func nextEvent(t *testing.T, ch chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return Event{}
+	}
+}
+
+func TestEventHub_Backlog(t *testing.T) {
+	hub := NewEventHub()
+	hub.Publish(Event{Type: EventRouteAdded, Hostname: "a.localhost"})
+	hub.Publish(Event{Type: EventRouteAdded, Hostname: "b.localhost"})
+	hub.Publish(Event{Type: EventRouteRemoved, Hostname: "a.localhost"})
+
+	// New subscribers only get new events
+	ch := hub.subscribe(0)
+	if len(ch) != 0 {
+		t.Errorf("fresh subscription has %d events, want 0", len(ch))
+	}
+	hub.unsubscribe(ch)
+
+	// Reconnecting clients catch up after the last event they saw
+	ch = hub.subscribe(1)
+	defer hub.unsubscribe(ch)
+	for _, want := range []uint64{2, 3} {
+		if e := nextEvent(t, ch); e.ID != want {
+			t.Errorf("replayed event ID = %d, want %d", e.ID, want)
+		}
+	}
+	hub.Publish(Event{Type: EventCertRenewed})
+	if e := nextEvent(t, ch); e.ID != 4 || e.Type != EventCertRenewed || e.Time.IsZero() {
+		t.Errorf("live event = %+v, want ID 4 cert_renewed with a time", e)
+	}
+}
+
+func TestEventHub_BacklogWraps(t *testing.T) {
+	hub := NewEventHub()
+	for range eventBacklog + 10 {
+		hub.Publish(Event{Type: EventRouteAdded})
+	}
+	ch := hub.subscribe(1)
+	defer hub.unsubscribe(ch)
+	if len(ch) != eventBacklog {
+		t.Fatalf("replayed %d events, want %d", len(ch), eventBacklog)
+	}
+	if e := nextEvent(t, ch); e.ID != 11 {
+		t.Errorf("oldest replayed ID = %d, want 11", e.ID)
+	}
+}
+
+func TestEventHub_WatchRoutes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "old1", ContainerName: "old", Hostname: "old.localhost", Host: "172.17.0.9", Port: 80})
+
+	hub := NewEventHub()
+	ch := hub.subscribe(0)
+	defer hub.unsubscribe(ch)
+	hub.WatchRoutes(ctx, router)
+
+	router.AddBackend(&docker.Backend{ContainerID: "web1", ContainerName: "web", Hostname: "web.localhost", Host: "172.17.0.2", Port: 80})
+	e := nextEvent(t, ch)
+	if e.Type != EventRouteAdded || e.Hostname != "web.localhost" || e.Route == nil || e.Route.Target != "172.17.0.2:80" {
+		t.Fatalf("event = %+v, want route_added for web.localhost", e)
+	}
+
+	router.ReplaceContainer("web1", []*docker.Backend{{ContainerID: "web1", ContainerName: "web", Hostname: "web.localhost", Host: "172.17.0.3", Port: 80}})
+	if e := nextEvent(t, ch); e.Type != EventRouteUpdated || e.Route.Target != "172.17.0.3:80" {
+		t.Errorf("event = %+v, want route_updated to 172.17.0.3:80", e)
+	}
+
+	router.RemoveBackend("old1")
+	if e := nextEvent(t, ch); e.Type != EventRouteRemoved || e.Hostname != "old.localhost" {
+		t.Errorf("event = %+v, want route_removed for old.localhost", e)
+	}
+}
+
+func TestHealthProber_PublishesHealthChanges(t *testing.T) {
+	// A port nothing listens on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "api1", Hostname: "api.localhost", Host: "127.0.0.1", Port: port})
+	hub := NewEventHub()
+	ch := hub.subscribe(0)
+	defer hub.unsubscribe(ch)
+	prober := NewHealthProber(router, 0)
+	prober.PublishTo(hub)
+
+	prober.ProbeAll(context.Background())
+	e := nextEvent(t, ch)
+	if e.Type != EventBackendUnhealthy || e.Hostname != "api.localhost" || e.Health == nil || e.Health.State != HealthDown {
+		t.Fatalf("event = %+v, want backend_unhealthy for api.localhost", e)
+	}
+
+	// Still down: nothing new
+	prober.ProbeAll(context.Background())
+	if len(ch) != 0 {
+		t.Errorf("unchanged health published %+v", <-ch)
+	}
+
+	ln, err = net.Listen("tcp", e.Target)
+	if err != nil {
+		t.Skipf("port %d was taken meanwhile: %v", port, err)
+	}
+	defer ln.Close()
+	prober.ProbeAll(context.Background())
+	if e := nextEvent(t, ch); e.Type != EventBackendHealthy {
+		t.Errorf("event = %+v, want backend_healthy", e)
+	}
+}
+
+func TestHandler_EventsAPI(t *testing.T) {
+	hub := NewEventHub()
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithEvents(hub))
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		hub.close() // ends the streams, like BeginDrain
+		server.Close()
+	})
+
+	stream := func(query, accept string) *bufio.Reader {
+		req, _ := http.NewRequest("GET", server.URL+"/_api/v1/events"+query, nil)
+		req.Host = "roji.localhost"
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d", resp.StatusCode)
+		}
+		return bufio.NewReader(resp.Body)
+	}
+	readLine := func(r *bufio.Reader) string {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSuffix(line, "\n")
+	}
+
+	sse := stream("", "")
+	ndjson := stream("?format=ndjson", "")
+	ndjsonAccept := stream("", "application/x-ndjson")
+	if line := readLine(sse); line != ": connected" {
+		t.Fatalf("first SSE line = %q", line)
+	}
+	readLine(sse)
+
+	// Streams are subscribed once the headers arrive
+	hub.Publish(Event{Type: EventRouteAdded, Hostname: "web.localhost"})
+
+	if line := readLine(sse); line != "id: 1" {
+		t.Errorf("SSE id line = %q", line)
+	}
+	if line := readLine(sse); line != "event: route_added" {
+		t.Errorf("SSE event line = %q", line)
+	}
+	if line := readLine(sse); !strings.HasPrefix(line, "data: ") || !strings.Contains(line, `"hostname":"web.localhost"`) {
+		t.Errorf("SSE data line = %q", line)
+	}
+
+	for _, r := range []*bufio.Reader{ndjson, ndjsonAccept} {
+		var e Event
+		if err := json.Unmarshal([]byte(readLine(r)), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.ID != 1 || e.Type != EventRouteAdded {
+			t.Errorf("NDJSON event = %+v", e)
+		}
+	}
+
+	// Catching up after a reconnect
+	hub.Publish(Event{Type: EventRouteRemoved, Hostname: "web.localhost"})
+	replay := stream("?format=ndjson&since=1", "")
+	var e Event
+	if err := json.Unmarshal([]byte(readLine(replay)), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 2 || e.Type != EventRouteRemoved {
+		t.Errorf("replayed event = %+v, want ID 2 route_removed", e)
+	}
+}
+
+func TestHandler_EventsAPIDisabled(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/events", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
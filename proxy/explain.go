@@ -0,0 +1,432 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+// Outcomes of an explained request: what roji would answer
+const (
+	OutcomeProxy        = "proxy"
+	OutcomeDashboard    = "dashboard"
+	OutcomeUnbrick      = "unbrick page"
+	OutcomeNotFound     = "not found"
+	OutcomeRedirect     = "redirect"
+	OutcomePairing      = "pairing page"
+	OutcomeRobots       = "robots.txt"
+	OutcomeUnauthorized = "unauthorized"
+	OutcomeBadGateway   = "bad gateway"
+	OutcomePaused       = "paused page"
+	OutcomeBlocked      = "blocked"
+	OutcomeStatic       = "static files"
+	OutcomeMock         = "mock response"
+	OutcomeOverride     = "local override"
+	OutcomeLiveReload   = "live reload"
+	OutcomeAsset        = "roji asset"
+	OutcomeMaintenance  = "maintenance page"
+)
+
+// ExplainRequest is the body of POST /_api/explain: a request to evaluate
+// against the live routing table without sending it
+type ExplainRequest struct {
+	Method  string            `json:"method,omitempty"` // default GET
+	URL     string            `json:"url"`              // e.g. "https://web.localhost/api?x=1"; scheme optional
+	Headers map[string]string `json:"headers,omitempty"`
+	Client  string            `json:"client,omitempty"` // client IP for LAN mode (default: the caller)
+}
+
+// Explanation describes how roji would handle a request
+type Explanation struct {
+	Hostname string     `json:"hostname"` // hostname used for the route lookup
+	Path     string     `json:"path"`
+	Outcome  string     `json:"outcome"`
+	Route    *RouteInfo `json:"route,omitempty"` // route that serves the request
+	Steps    []string   `json:"steps"`           // what would run, in order
+
+	// The request the backend would receive
+	Upstream        string            `json:"upstream,omitempty"`
+	UpstreamHeaders map[string]string `json:"upstream_headers,omitempty"`
+}
+
+func (e *Explanation) step(format string, args ...any) {
+	e.Steps = append(e.Steps, fmt.Sprintf(format, args...))
+}
+
+// Explain evaluates a request the way ServeHTTP would, without side effects:
+// share links aren't redeemed, replicas aren't rotated and nothing is sent
+func (h *Handler) Explain(r *http.Request) *Explanation {
+	e := &Explanation{Path: r.URL.Path}
+
+	if strings.HasPrefix(r.URL.Path, AssetsPath) {
+		e.Hostname = h.requestHostname(r)
+		e.Outcome = OutcomeAsset
+		e.step("%s is reserved for the stylesheets of roji's own pages", AssetsPath)
+		return e
+	}
+
+	if token := r.URL.Query().Get(ShareParam); token != "" && h.shares != nil {
+		if h.shares.Verify(h.requestHostname(r), token, time.Now()) {
+			e.Hostname = h.requestHostname(r)
+			e.Outcome = OutcomeRedirect
+			e.step("share link: valid token, sets the %s cookie and redirects to the URL without it", shareCookie)
+			return e
+		}
+		e.step("share link: invalid or expired token, ignored")
+	}
+
+	if h.devices != nil {
+		addr, ok := clientAddr(r)
+		switch {
+		case ok && h.devices.Allowed(addr):
+			e.step("LAN mode: client %s is trusted or paired", addr)
+		case h.sharedRequest(r):
+			e.step("LAN mode: client allowed by a share cookie")
+		default:
+			e.Hostname = h.requestHostname(r)
+			e.Outcome = OutcomePairing
+			e.step("LAN mode: client %s isn't paired", r.RemoteAddr)
+			return e
+		}
+	}
+
+	hostname := strings.ToLower(hostWithoutPort(r.Host))
+	if hostname == "" {
+		hostname = h.router.LegacyHostname()
+		e.step("no Host header: using the only hostname, %q", hostname)
+	}
+	if mapped := h.fromEmulatorHostname(hostname); mapped != hostname {
+		e.step("emulator or LAN hostname %s maps to %s", hostname, mapped)
+		hostname = mapped
+	}
+	e.Hostname = hostname
+
+	if h.dashboardHost != "" && hostname == h.dashboardHost {
+		e.Outcome = OutcomeDashboard
+		return e
+	}
+	if r.URL.Path == UnbrickPath {
+		e.Outcome = OutcomeUnbrick
+		return e
+	}
+	if h.liveReload != nil && (r.URL.Path == LiveReloadPath || r.URL.Path == liveReloadScriptPath) {
+		e.Outcome = OutcomeLiveReload
+		return e
+	}
+	route := h.router.Lookup(hostname, r.URL.Path)
+	var tunneled bool
+	if route != nil {
+		e.step("matched route %s%s (%s)", route.Hostname, route.PathPrefix, route.Backend.ServiceName)
+
+		if route.Backend.RedirectTo != "" {
+			info := newRouteInfo(route, route.Backend)
+			e.Route = &info
+			e.Outcome = OutcomeRedirect
+			e.step("alias: 301 to %s (roji.canonical-host)", route.Backend.RedirectTo)
+			return e
+		}
+
+		tunneled = h.isTunneled(r, route)
+		if tunneled {
+			e.step("tunnel: public request, responses get X-Robots-Tag: %s", noindex)
+		}
+		if outcome := h.explainTunnelGuard(e, r, route, tunneled); outcome != "" {
+			e.Outcome = outcome
+			return e
+		}
+	}
+
+	if m := h.mocks.Match(r.Method, hostname, r.URL.Path); m != nil {
+		if route == nil {
+			routes := h.router.HostnameRoutes(hostname)
+			for _, route := range routes {
+				if outcome := h.explainTunnelGuard(e, r, route, h.isTunneled(r, route)); outcome != "" {
+					e.Outcome = outcome
+					return e
+				}
+			}
+			if len(routes) == 0 && h.viaTunnel(r) {
+				e.Outcome = OutcomeNotFound
+				e.step("mock %s%s isn't served through a tunnel: %s has no route", m.Hostname, m.Path, hostname)
+				return e
+			}
+		}
+		e.Outcome = OutcomeMock
+		e.step("mock %s%s answers with status %d", m.Hostname, m.Path, m.Status)
+		return e
+	}
+	if route == nil {
+		e.Outcome = OutcomeNotFound
+		e.step("no route for %s%s", hostname, r.URL.Path)
+		return e
+	}
+
+	composite := route
+	route, mountPath, ok := h.resolveMount(route, r.URL.Path)
+	if !ok {
+		e.Outcome = OutcomeBadGateway
+		e.step("compose: %s is mounted from a route that isn't running", mountPath)
+		return e
+	}
+	if mountPath != "" {
+		e.step("compose: %s is served by %s, with %s: %s", mountPath, route.Hostname, ForwardedPrefixHeader, mountPath)
+	}
+
+	if since, ok := h.inMaintenance(composite, route); ok {
+		e.Outcome = OutcomeMaintenance
+		e.step("maintenance mode since %s: 503 without proxying", since.Format(time.TimeOnly))
+		return e
+	}
+	if route.Paused() {
+		e.Outcome = OutcomePaused
+		e.step("container is paused: 503 with Retry-After")
+		return e
+	}
+	if route.Backend.BlockServiceWorkers && isServiceWorkerScript(r) {
+		e.Outcome = OutcomeBlocked
+		e.step("service worker registration refused (roji.block-service-workers)")
+		return e
+	}
+	if route.Backend.Legacy {
+		e.step("legacy: Connection: close (roji.legacy)")
+	}
+
+	if route.Backend.StaticDir != "" {
+		info := newRouteInfo(route, route.Backend)
+		e.Route = &info
+		e.Outcome = OutcomeStatic
+		if route.Backend.StaticSPA {
+			e.step("static: serves %s, index.html for unknown paths without an extension", route.Backend.StaticDir)
+		} else {
+			e.step("static: serves %s", route.Backend.StaticDir)
+		}
+		return e
+	}
+
+	if file := h.overrideTarget(route, r); file != "" {
+		info := newRouteInfo(route, route.Backend)
+		e.Route = &info
+		e.Outcome = OutcomeOverride
+		e.step("override: serves %s instead of proxying (roji.overrides)", file)
+		return e
+	}
+
+	backend := h.peekBackend(e, route)
+	info := newRouteInfo(route, backend)
+	e.Route = &info
+	e.Outcome = OutcomeProxy
+
+	upstream := upstreamURL(backend)
+	upstream.Path = strings.TrimSuffix(upstream.Path, "/") + stripPathPrefix(r.URL.Path, route.PathPrefix)
+	upstream.RawQuery = r.URL.RawQuery
+	e.Upstream = upstream.String()
+	if route.PathPrefix != "" {
+		e.step("path: strips %s (roji.path)", route.PathPrefix)
+	}
+
+	e.UpstreamHeaders = map[string]string{
+		"X-Forwarded-Host":  r.Host,
+		"X-Forwarded-Proto": forwardedProto(r),
+	}
+	if r.RemoteAddr != "" {
+		e.UpstreamHeaders["X-Forwarded-For"] = hostWithoutPort(r.RemoteAddr)
+		e.UpstreamHeaders["X-Real-IP"] = hostWithoutPort(r.RemoteAddr)
+	}
+	if mountPath != "" {
+		e.UpstreamHeaders[ForwardedPrefixHeader] = mountPath
+	}
+	if backend.ExternalURL != "" {
+		e.UpstreamHeaders["Host"] = upstream.Host
+		e.step("external: Host and SNI are %s; redirects and cookies are kept on %s", upstream.Host, hostname)
+	}
+	if composite.Backend.TunnelAuth != "" && needsTunnelCredentials(r, tunneled) && r.Header.Get("Authorization") != "" {
+		e.step("tunnel: drops Authorization (roji's credentials, not the backend's)")
+	}
+	if h.rewritesHTML(route.Backend) {
+		if acceptsGzip(r.Header) {
+			e.step("limits Accept-Encoding to gzip so HTML can be rewritten")
+		} else {
+			e.step("drops Accept-Encoding so HTML can be rewritten")
+		}
+	}
+
+	if h.recorder != nil && route.Backend.Record {
+		what := "headers"
+		if route.Backend.RecordBodies {
+			what = "headers and bodies"
+		}
+		e.step("record: captures the %s of the request and response for /_api/requests, without credentials (roji.record)", what)
+	}
+	if !h.transports.keepAlive(backend) {
+		e.step("connection: a new one per request, closed after the response (keep-alive off)")
+	} else if lifetime := h.transports.connLifetime(backend); lifetime > 0 {
+		e.step("connection: not reused once older than %s", lifetime)
+	}
+	if concurrencyLimited(backend, r) {
+		e.step("limit: at most %d requests at once to %s; others wait up to %s, then 503 (roji.max-concurrent)",
+			backend.MaxConcurrent, backend.ContainerName, backend.MaxConcurrentWait)
+	}
+	if route.Backend.Coalesce && coalescable(r) {
+		e.step("coalesce: identical concurrent requests share one upstream call (roji.coalesce)")
+	}
+	if !backend.KeepRedirects {
+		e.step("response: points Location headers naming %s at %s",
+			strings.Join(describeInternalHosts(backend, upstreamURL(backend)), ", "), hostname)
+	}
+	if policy := backend.Cookies; !policy.IsZero() {
+		e.step("response: rewrites Set-Cookie (domain %q, secure %v, samesite %q)", policy.Domain, policy.Secure, policy.SameSite)
+	}
+	if !h.allowHSTS && isLocalhost(hostname) {
+		e.step("response: strips Strict-Transport-Security")
+	}
+	if route.Backend.Embed != "" {
+		e.step("response: %s CSP/X-Frame-Options for embedding (roji.embed)", route.Backend.Embed)
+	}
+	if route.Backend.ESI {
+		e.step("response: resolves <esi:include> tags in HTML (roji.esi)")
+	}
+	if banner := h.routeBanner(route.Backend); banner != "" {
+		e.step("response: injects the banner %q into HTML", bannerText(banner, backend))
+	}
+	if h.injectsLiveReload(route.Backend) {
+		e.step("response: injects the live reload script into HTML (roji.live-reload)")
+	}
+	return e
+}
+
+// explainTunnelGuard mirrors guardTunnel and returns the outcome when the
+// request would be answered there
+func (h *Handler) explainTunnelGuard(e *Explanation, r *http.Request, route *Route, tunneled bool) string {
+	if tunneled && r.URL.Path == "/robots.txt" {
+		e.step("tunnel: roji answers robots.txt with Disallow: /")
+		return OutcomeRobots
+	}
+
+	auth, secret := route.Backend.TunnelAuth, route.Backend.TunnelSecret
+	switch {
+	case auth == "" && secret == "":
+		return ""
+	case !needsTunnelCredentials(r, tunneled):
+		e.step("tunnel: credentials not needed from this machine")
+		return ""
+	case h.sharedRequest(r):
+		e.step("tunnel: credentials waived by a share cookie")
+		return ""
+	case secret != "" && secureEqual(r.Header.Get(TunnelSecretHeader), secret):
+		e.step("tunnel: %s accepted", TunnelSecretHeader)
+		return ""
+	}
+	if auth != "" {
+		if user, pass, ok := r.BasicAuth(); ok && secureEqual(user+":"+pass, auth) {
+			e.step("tunnel: basic auth accepted")
+			return ""
+		}
+	}
+	e.step("tunnel: credentials missing or wrong (roji.tunnel-auth / roji.tunnel-secret)")
+	return OutcomeUnauthorized
+}
+
+// peekBackend returns the replica selectBackend would pick, without
+// advancing load balancing
+func (h *Handler) peekBackend(e *Explanation, route *Route) *docker.Backend {
+	candidates := unpaused(route.Candidates())
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	ejected := 0
+	if h.outliers != nil {
+		for _, b := range candidates {
+			if h.outliers.IsEjected(b.ContainerID) {
+				ejected++
+			}
+		}
+	}
+	e.step("load balancing: %d replicas, %d ejected; showing the next one", len(candidates), ejected)
+	if h.outliers == nil {
+		return candidates[0]
+	}
+	return h.outliers.Select(candidates)
+}
+
+// NewExplainTarget builds the request an ExplainRequest describes
+func NewExplainTarget(req ExplainRequest) (*http.Request, error) {
+	raw := strings.TrimSpace(req.URL)
+	if raw == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	r, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		r.TLS = &tls.ConnectionState{}
+	}
+	for name, value := range req.Headers {
+		if strings.EqualFold(name, "Host") {
+			r.Host = value
+			continue
+		}
+		r.Header.Set(name, value)
+	}
+	if req.Client != "" {
+		r.RemoteAddr = net.JoinHostPort(req.Client, "0")
+	}
+	return r, nil
+}
+
+// serveExplainAPI evaluates a request against the routing table (POST,
+// JSON only, like /_api/share)
+func (h *Handler) serveExplainAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var req ExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	target, err := NewExplainTarget(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if target.RemoteAddr == "" {
+		target.RemoteAddr = r.RemoteAddr
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Explain(target)); err != nil {
+		slog.Error("failed to encode explanation", "error", err)
+	}
+}
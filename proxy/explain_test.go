@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kan/roji/config"
+	"github.com/kan/roji/docker"
+)
+
+func newExplainHandler() *Handler {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{
+		ContainerID: "web1", ServiceName: "web", Host: "172.18.0.2", Port: 3000,
+		Hostname: "shop.localhost",
+		Mounts:   []config.Mount{{Path: "/checkout", Host: "checkout.localhost"}},
+	})
+	router.AddBackend(&docker.Backend{
+		ContainerID: "api1", ServiceName: "api", Host: "172.18.0.3", Port: 8080,
+		Hostname: "shop.localhost", PathPrefix: "/api",
+	})
+	router.AddBackend(&docker.Backend{
+		ContainerID: "checkout1", ServiceName: "checkout", Host: "172.18.0.4", Port: 80,
+		Hostname: "checkout.localhost", Tunnel: true, TunnelSecret: "s3cret",
+	})
+	router.AddBackend(&docker.Backend{
+		ContainerID: "old1", ServiceName: "old", Hostname: "old.localhost", RedirectTo: "shop.localhost",
+	})
+	return NewHandler(router, "roji.localhost", testStatusConfig())
+}
+
+func TestHandler_Explain(t *testing.T) {
+	handler := newExplainHandler()
+
+	tests := []struct {
+		name     string
+		req      ExplainRequest
+		outcome  string
+		upstream string
+	}{
+		{"path prefix", ExplainRequest{URL: "shop.localhost/api/users?page=2"},
+			OutcomeProxy, "http://172.18.0.3:8080/users?page=2"},
+		{"default route", ExplainRequest{URL: "https://shop.localhost/cart"},
+			OutcomeProxy, "http://172.18.0.2:3000/cart"},
+		{"compose mount with secret", ExplainRequest{URL: "shop.localhost/checkout/pay", Headers: map[string]string{TunnelSecretHeader: "s3cret"}},
+			OutcomeProxy, "http://172.18.0.4:80/checkout/pay"},
+		{"tunnel without secret", ExplainRequest{URL: "checkout.localhost/"},
+			OutcomeUnauthorized, ""},
+		{"alias", ExplainRequest{URL: "old.localhost/"},
+			OutcomeRedirect, ""},
+		{"unknown host", ExplainRequest{URL: "nope.localhost/"},
+			OutcomeNotFound, ""},
+		{"dashboard", ExplainRequest{URL: "roji.localhost/"},
+			OutcomeDashboard, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewExplainTarget(tt.req)
+			if err != nil {
+				t.Fatalf("NewExplainTarget() error = %v", err)
+			}
+			e := handler.Explain(r)
+			if e.Outcome != tt.outcome || e.Upstream != tt.upstream {
+				t.Errorf("Explain() = %s %q, want %s %q (steps: %q)", e.Outcome, e.Upstream, tt.outcome, tt.upstream, e.Steps)
+			}
+		})
+	}
+}
+
+func TestHandler_ExplainAPI(t *testing.T) {
+	handler := newExplainHandler()
+
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "https://roji.localhost/_api/explain", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("application/json", `{"url":"https://shop.localhost/checkout","headers":{"X-Roji-Secret":"s3cret"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var e Explanation
+	if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Route == nil || e.Route.ServiceName != "checkout" {
+		t.Errorf("route = %+v, want checkout", e.Route)
+	}
+	if got := e.UpstreamHeaders[ForwardedPrefixHeader]; got != "/checkout" {
+		t.Errorf("%s = %q, want /checkout", ForwardedPrefixHeader, got)
+	}
+
+	if w := post("application/x-www-form-urlencoded", "url=shop.localhost"); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("form post: status = %d, want 415", w.Code)
+	}
+	if w := post("application/json", `{"url":""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("empty url: status = %d, want 400", w.Code)
+	}
+}
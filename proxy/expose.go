@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExposeLease is how long a `roji expose` route lives without renewal. The
+// CLI renews well within it, so a killed CLI leaves no stale route behind.
+const ExposeLease = 30 * time.Second
+
+// RouteExposer registers temporary routes to ports on the Docker host
+// (roji expose)
+type RouteExposer interface {
+	// Expose routes hostname to port on the host, replacing an earlier
+	// exposed route for the same hostname
+	Expose(hostname string, port int) error
+	// Unexpose removes an exposed route
+	Unexpose(hostname string)
+}
+
+// WithRouteExposer enables /_api/expose
+func WithRouteExposer(e RouteExposer) HandlerOption {
+	return func(h *Handler) {
+		h.exposer = e
+		h.exposeLeases = make(map[string]*time.Timer)
+	}
+}
+
+// exposeRequest is the body of POST /_api/expose
+type exposeRequest struct {
+	Hostname string `json:"hostname"` // a name without dots gets the base domain
+	Port     int    `json:"port"`
+}
+
+// exposeResponse describes an exposed route and its lease
+type exposeResponse struct {
+	Hostname string `json:"hostname"`
+	URL      string `json:"url"`
+	Lease    string `json:"lease"` // renew (POST again) within this duration
+}
+
+// serveExposeAPI creates or renews (POST) and removes (DELETE ?hostname=)
+// temporary routes
+func (h *Handler) serveExposeAPI(w http.ResponseWriter, r *http.Request) {
+	if h.exposer == nil {
+		http.Error(w, "roji expose is disabled", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		var req exposeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		h.exposeRoute(w, h.exposeHostname(req.Hostname), req.Port)
+
+	case http.MethodDelete:
+		hostname := h.exposeHostname(r.URL.Query().Get("hostname"))
+		if !h.releaseLease(hostname) {
+			http.Error(w, "no exposed route for "+hostname, http.StatusNotFound)
+			return
+		}
+		h.exposer.Unexpose(hostname)
+		slog.Info("exposed route removed", "hostname", hostname)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// exposeHostname normalizes a requested hostname, appending the base domain
+// to bare names
+func (h *Handler) exposeHostname(hostname string) string {
+	hostname = strings.ToLower(strings.TrimSpace(hostname))
+	if hostname != "" && !strings.Contains(hostname, ".") {
+		hostname += "." + h.statusConfig.BaseDomain
+	}
+	return hostname
+}
+
+func (h *Handler) exposeRoute(w http.ResponseWriter, hostname string, port int) {
+	if hostname == "" || strings.ContainsAny(hostname, "/:@ ") {
+		http.Error(w, "invalid hostname", http.StatusBadRequest)
+		return
+	}
+	if port < 1 || port > 65535 {
+		http.Error(w, "port must be between 1 and 65535", http.StatusBadRequest)
+		return
+	}
+
+	h.exposeMu.Lock()
+	_, renewal := h.exposeLeases[hostname]
+	h.exposeMu.Unlock()
+	if !renewal && (hostname == h.dashboardHost || h.hasRoute(hostname)) {
+		http.Error(w, hostname+" is already routed", http.StatusConflict)
+		return
+	}
+
+	if err := h.exposer.Expose(hostname, port); err != nil {
+		slog.Error("failed to expose route", "hostname", hostname, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.renewLease(hostname)
+	if !renewal {
+		slog.Info("route exposed", "hostname", hostname, "port", port)
+	}
+
+	host := hostname
+	if h.statusConfig.HTTPSPort != 443 {
+		host += ":" + strconv.Itoa(h.statusConfig.HTTPSPort)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(exposeResponse{
+		Hostname: hostname,
+		URL:      "https://" + host + "/",
+		Lease:    ExposeLease.String(),
+	}); err != nil {
+		slog.Error("failed to encode expose response", "error", err)
+	}
+}
+
+// renewLease (re)starts the timer that removes an exposed route
+func (h *Handler) renewLease(hostname string) {
+	h.exposeMu.Lock()
+	defer h.exposeMu.Unlock()
+
+	if timer, ok := h.exposeLeases[hostname]; ok {
+		timer.Reset(ExposeLease)
+		return
+	}
+	h.exposeLeases[hostname] = time.AfterFunc(ExposeLease, func() {
+		if h.releaseLease(hostname) {
+			h.exposer.Unexpose(hostname)
+			slog.Info("exposed route expired", "hostname", hostname)
+		}
+	})
+}
+
+// releaseLease stops an exposed route's lease and reports whether it existed
+func (h *Handler) releaseLease(hostname string) bool {
+	h.exposeMu.Lock()
+	defer h.exposeMu.Unlock()
+
+	timer, ok := h.exposeLeases[hostname]
+	if ok {
+		timer.Stop()
+		delete(h.exposeLeases, hostname)
+	}
+	return ok
+}
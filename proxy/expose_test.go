@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kan/roji/docker"
+)
+
+// fakeExposer routes exposed hostnames straight in the router
+type fakeExposer struct {
+	router *Router
+}
+
+func (e *fakeExposer) Expose(hostname string, port int) error {
+	e.router.ReplaceContainer("expose:"+hostname, []*docker.Backend{{
+		ContainerID: "expose:" + hostname, ServiceName: "expose",
+		Host: "host.docker.internal", Port: port, Hostname: hostname,
+	}})
+	return nil
+}
+
+func (e *fakeExposer) Unexpose(hostname string) {
+	e.router.RemoveBackend("expose:" + hostname)
+}
+
+func TestHandler_ExposeAPI(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "web1", ServiceName: "web", Host: "172.18.0.2", Port: 80, Hostname: "web.localhost"})
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithRouteExposer(&fakeExposer{router: router}))
+
+	call := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "https://roji.localhost"+target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call("POST", "/_api/expose", `{"hostname":"myapp","port":3000}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"url":"https://myapp.localhost/"`) {
+		t.Fatalf("expose: %d %s", w.Code, w.Body)
+	}
+	if route := router.Lookup("myapp.localhost", "/"); route == nil || route.Backend.Port != 3000 {
+		t.Fatalf("route after expose = %+v, want port 3000", route)
+	}
+
+	// Renewing may change the port
+	if w := call("POST", "/_api/expose", `{"hostname":"myapp.localhost","port":3001}`); w.Code != http.StatusOK {
+		t.Fatalf("renew: %d %s", w.Code, w.Body)
+	}
+	if route := router.Lookup("myapp.localhost", "/"); route == nil || route.Backend.Port != 3001 {
+		t.Errorf("route after renew = %+v, want port 3001", route)
+	}
+
+	// Existing routes and the dashboard can't be taken over
+	for _, hostname := range []string{"web.localhost", "roji.localhost"} {
+		if w := call("POST", "/_api/expose", `{"hostname":"`+hostname+`","port":3000}`); w.Code != http.StatusConflict {
+			t.Errorf("expose %s: status = %d, want 409", hostname, w.Code)
+		}
+	}
+	if w := call("POST", "/_api/expose", `{"hostname":"bad","port":0}`); w.Code != http.StatusBadRequest {
+		t.Errorf("port 0: status = %d, want 400", w.Code)
+	}
+
+	if w := call("DELETE", "/_api/expose?hostname=myapp.localhost", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+	if router.Lookup("myapp.localhost", "/") != nil {
+		t.Error("route still present after delete")
+	}
+	if w := call("DELETE", "/_api/expose?hostname=web.localhost", ""); w.Code != http.StatusNotFound {
+		t.Errorf("delete container route: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,18 @@
+package proxy
+
+import (
+	"net/url"
+
+	"github.com/kan/roji/docker"
+)
+
+// upstreamURL returns where a backend's requests go: the container address,
+// or the URL of an external route (--external-routes)
+func upstreamURL(b *docker.Backend) *url.URL {
+	if b.ExternalURL != "" {
+		if u, err := url.Parse(b.ExternalURL); err == nil {
+			return u
+		}
+	}
+	return &url.URL{Scheme: "http", Host: b.Address()}
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kan/roji/config"
+	"github.com/kan/roji/docker"
+)
+
+func TestHandler_ExternalRoute(t *testing.T) {
+	var upstream *httptest.Server
+	upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != upstream.Listener.Addr().String() {
+			t.Errorf("Host = %q, want the upstream host", r.Host)
+		}
+		switch r.URL.Path {
+		case "/v2/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Domain: "staging.example.com", Path: "/"})
+			http.Redirect(w, r, upstream.URL+"/v2/home?x=1", http.StatusFound)
+		default:
+			io.WriteString(w, r.URL.Path)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "external:api.localhost", ServiceName: "external", Hostname: "api.localhost", ExternalURL: upstream.URL + "/v2",
+		Cookies: config.CookiePolicy{Domain: config.CookieDomainStrip}})
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "https://api.localhost"+path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/users"); w.Body.String() != "/v2/users" {
+		t.Errorf("upstream path = %q, want /v2/users", w.Body)
+	}
+
+	w := get("/login")
+	if got := w.Header().Get("Location"); got != "https://api.localhost/home?x=1" {
+		t.Errorf("Location = %q, want https://api.localhost/home?x=1", got)
+	}
+	if got := w.Header().Get("Set-Cookie"); got != "session=abc; Path=/" {
+		t.Errorf("Set-Cookie = %q, want the Domain attribute dropped", got)
+	}
+}
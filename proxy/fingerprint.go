@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kan/roji/config"
+)
+
+// ConfigDocument is roji's effective configuration in canonical form:
+// settings from flags and environment, and the routes derived from labels.
+// Machine-specific values (container IPs and names, paths) are left out, so
+// teammates running the same projects get the same document.
+type ConfigDocument struct {
+	Settings map[string]string `json:"settings"`
+	Routes   []RouteSpec       `json:"routes"`
+}
+
+// RouteSpec is the declared shape of a route, without where it runs
+type RouteSpec struct {
+	Hostname   string         `json:"hostname"`
+	PathPrefix string         `json:"path_prefix,omitempty"`
+	Service    string         `json:"service"`
+	RedirectTo string         `json:"redirect_to,omitempty"`
+	Embed      string         `json:"embed,omitempty"`
+	Mounts     []config.Mount `json:"mounts,omitempty"`
+	Tunnel     bool           `json:"tunnel,omitempty"`
+
+	// Labels are the roji.* labels in effect, including the config file's
+	// middleware defaults; secrets are redacted
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func (s RouteSpec) key() string {
+	return s.Hostname + s.PathPrefix
+}
+
+// ConfigStatus is the configuration section of the status API
+type ConfigStatus struct {
+	Fingerprint string   `json:"fingerprint"`
+	Manifest    string   `json:"manifest,omitempty"` // fingerprint of the declared manifest
+	Drift       []string `json:"drift,omitempty"`    // differences from the manifest
+}
+
+// Fingerprint hashes the canonical JSON encoding of the document
+func (d ConfigDocument) Fingerprint() string {
+	data, _ := json.Marshal(d) // map keys are sorted; routes are sorted by ConfigDocument
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// Drift lists how the document differs from a declared manifest, one line
+// per setting or route
+func (d ConfigDocument) Drift(declared ConfigDocument) []string {
+	var drift []string
+
+	keys := make(map[string]bool)
+	for k := range d.Settings {
+		keys[k] = true
+	}
+	for k := range declared.Settings {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		want, declaredOK := declared.Settings[k]
+		got, runningOK := d.Settings[k]
+		switch {
+		case !declaredOK:
+			drift = append(drift, fmt.Sprintf("setting %s=%q is not declared", k, got))
+		case !runningOK:
+			drift = append(drift, fmt.Sprintf("setting %s is declared as %q but not set", k, want))
+		case got != want:
+			drift = append(drift, fmt.Sprintf("setting %s: declared %q, running %q", k, want, got))
+		}
+	}
+
+	running := make(map[string]RouteSpec)
+	for _, s := range d.Routes {
+		running[s.key()] = s
+	}
+	for _, want := range declared.Routes {
+		got, ok := running[want.key()]
+		delete(running, want.key())
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("route %s (%s) is declared but not running", want.key(), want.Service))
+		case !reflect.DeepEqual(got, want):
+			drift = append(drift, fmt.Sprintf("route %s differs: declared %s, running %s", want.key(), specJSON(want), specJSON(got)))
+		}
+	}
+	for _, s := range d.Routes {
+		if _, ok := running[s.key()]; ok {
+			drift = append(drift, fmt.Sprintf("route %s (%s) is not declared", s.key(), s.Service))
+		}
+	}
+	return drift
+}
+
+func specJSON(s RouteSpec) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LoadManifest reads a declared configuration saved from `roji config`
+func LoadManifest(path string) (*ConfigDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var doc ConfigDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if doc.Settings == nil {
+		doc.Settings = map[string]string{}
+	}
+	if doc.Routes == nil {
+		doc.Routes = []RouteSpec{}
+	}
+	doc.sortRoutes()
+	return &doc, nil
+}
+
+// WithSettings records the effective settings for /_api/config and the
+// configuration fingerprint
+func WithSettings(settings map[string]string) HandlerOption {
+	return func(h *Handler) {
+		h.settings = settings
+	}
+}
+
+// WithManifest enables drift detection against a declared configuration
+func WithManifest(manifest *ConfigDocument) HandlerOption {
+	return func(h *Handler) {
+		h.manifest = manifest
+	}
+}
+
+// ConfigDocument returns the running configuration
+func (h *Handler) ConfigDocument() ConfigDocument {
+	doc := ConfigDocument{Settings: h.settings, Routes: []RouteSpec{}}
+	if doc.Settings == nil {
+		doc.Settings = map[string]string{}
+	}
+
+	// The route table has each route's labels; ListRoutes doesn't
+	labels := make(map[string]map[string]string)
+	for _, host := range h.router.Table().Hosts {
+		for _, tr := range host.Routes {
+			if len(tr.Backends) > 0 {
+				labels[host.Hostname+tr.PathPrefix] = tr.Backends[0].Labels
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, ri := range h.router.ListRoutes() {
+		spec := RouteSpec{
+			Hostname:   ri.Hostname,
+			PathPrefix: ri.PathPrefix,
+			Service:    ri.ServiceName,
+			RedirectTo: ri.RedirectTo,
+			Embed:      ri.Embed,
+			Mounts:     ri.Mounts,
+			Tunnel:     ri.Tunnel,
+		}
+		spec.Labels = labels[spec.key()]
+		if seen[spec.key()] {
+			continue // replicas of a scaled service
+		}
+		seen[spec.key()] = true
+		doc.Routes = append(doc.Routes, spec)
+	}
+	doc.sortRoutes()
+	return doc
+}
+
+func (d *ConfigDocument) sortRoutes() {
+	slices.SortFunc(d.Routes, func(a, b RouteSpec) int {
+		return strings.Compare(a.key(), b.key())
+	})
+}
+
+// configStatus fingerprints the running configuration and compares it with
+// the manifest, if any
+func (h *Handler) configStatus() ConfigStatus {
+	doc := h.ConfigDocument()
+	status := ConfigStatus{Fingerprint: doc.Fingerprint()}
+	if h.manifest != nil {
+		status.Manifest = h.manifest.Fingerprint()
+		status.Drift = doc.Drift(*h.manifest)
+	}
+	return status
+}
+
+// CheckDrift logs a warning when the differences from the manifest change
+// and returns them
+func (h *Handler) CheckDrift() []string {
+	if h.manifest == nil {
+		return nil
+	}
+	drift := h.ConfigDocument().Drift(*h.manifest)
+
+	h.driftMu.Lock()
+	changed := !slices.Equal(drift, h.lastDrift)
+	h.lastDrift = drift
+	h.driftMu.Unlock()
+
+	if changed {
+		if len(drift) == 0 {
+			slog.Info("configuration matches the manifest", "fingerprint", h.manifest.Fingerprint())
+		} else {
+			slog.Warn("configuration drifted from the manifest", "differences", len(drift))
+			for _, d := range drift {
+				slog.Warn("drift", "difference", d)
+			}
+		}
+	}
+	return drift
+}
+
+// serveConfigAPI returns the running configuration document (GET)
+func (h *Handler) serveConfigAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	doc := h.ConfigDocument()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Roji-Fingerprint", doc.Fingerprint())
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		slog.Error("failed to encode config response", "error", err)
+	}
+}
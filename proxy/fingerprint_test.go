@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kan/roji/docker"
+)
+
+func fingerprintRouter(webIP string) *Router {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "api1", ContainerName: "a-api-1", ServiceName: "api", ProjectName: "a", Host: "172.18.0.9", Port: 80, Hostname: "api.localhost"})
+	router.AddBackend(&docker.Backend{ContainerID: "web1", ContainerName: "a-web-1", ServiceName: "web", ProjectName: "a", Host: webIP, Port: 80, Hostname: "web.localhost"})
+	router.AddBackend(&docker.Backend{ContainerID: "web2", ContainerName: "a-web-2", ServiceName: "web", ProjectName: "a", Host: "172.18.0.7", Port: 80, Hostname: "web.localhost"})
+	return router
+}
+
+func TestConfigDocument_Fingerprint(t *testing.T) {
+	settings := map[string]string{"domain": "localhost", "network": "roji"}
+	a := NewHandler(fingerprintRouter("172.18.0.2"), "roji.localhost", testStatusConfig(), WithSettings(settings)).ConfigDocument()
+	b := NewHandler(fingerprintRouter("10.0.0.5"), "roji.localhost", testStatusConfig(), WithSettings(settings)).ConfigDocument()
+
+	if len(a.Routes) != 2 {
+		t.Fatalf("routes = %+v, want one spec per route (replicas collapsed)", a.Routes)
+	}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("fingerprints differ across machines: %s vs %s", a.Fingerprint(), b.Fingerprint())
+	}
+
+	settings2 := map[string]string{"domain": "dev.localhost", "network": "roji"}
+	c := NewHandler(fingerprintRouter("172.18.0.2"), "roji.localhost", testStatusConfig(), WithSettings(settings2)).ConfigDocument()
+	if a.Fingerprint() == c.Fingerprint() {
+		t.Error("fingerprint unchanged after a setting changed")
+	}
+
+	// Labels, including the config file's middleware defaults, shape the route
+	router := fingerprintRouter("172.18.0.2")
+	router.AddBackend(&docker.Backend{ContainerID: "web3", ContainerName: "a-web-3", ServiceName: "web", ProjectName: "a", Host: "172.18.0.8", Port: 80, Hostname: "web.localhost",
+		Labels: map[string]string{"roji.coalesce": "true"}})
+	router.RemoveBackend("web1")
+	router.RemoveBackend("web2")
+	d := NewHandler(router, "roji.localhost", testStatusConfig(), WithSettings(settings)).ConfigDocument()
+	if a.Fingerprint() == d.Fingerprint() {
+		t.Error("fingerprint unchanged after a route's labels changed")
+	}
+}
+
+func TestConfigDocument_Drift(t *testing.T) {
+	declared := ConfigDocument{
+		Settings: map[string]string{"domain": "localhost", "lan": "false"},
+		Routes: []RouteSpec{
+			{Hostname: "api.localhost", Service: "api"},
+			{Hostname: "db.localhost", Service: "adminer"},
+			{Hostname: "web.localhost", Service: "web", Embed: "relax"},
+		},
+	}
+	running := ConfigDocument{
+		Settings: map[string]string{"domain": "localhost", "lan": "true", "emulator": "false"},
+		Routes: []RouteSpec{
+			{Hostname: "api.localhost", Service: "api"},
+			{Hostname: "web.localhost", Service: "web"},
+			{Hostname: "worker.localhost", Service: "worker"},
+		},
+	}
+
+	want := []string{
+		`setting emulator="false" is not declared`,
+		`setting lan: declared "false", running "true"`,
+		`route db.localhost (adminer) is declared but not running`,
+		`route web.localhost differs: declared {"hostname":"web.localhost","service":"web","embed":"relax"}, running {"hostname":"web.localhost","service":"web"}`,
+		`route worker.localhost (worker) is not declared`,
+	}
+	if got := running.Drift(declared); !reflect.DeepEqual(got, want) {
+		t.Errorf("Drift() =\n%q\nwant\n%q", got, want)
+	}
+	if got := running.Drift(running); len(got) != 0 {
+		t.Errorf("Drift(self) = %q, want none", got)
+	}
+}
+
+func TestHandler_ConfigStatus_Manifest(t *testing.T) {
+	settings := map[string]string{"domain": "localhost"}
+	handler := NewHandler(fingerprintRouter("172.18.0.2"), "roji.localhost", testStatusConfig(), WithSettings(settings))
+
+	// Save the running configuration as the manifest, like `roji config > roji.json`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/config", nil))
+	path := filepath.Join(t.TempDir(), "roji.json")
+	if err := os.WriteFile(path, w.Body.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if got, want := manifest.Fingerprint(), w.Header().Get("X-Roji-Fingerprint"); got != want {
+		t.Errorf("manifest fingerprint = %s, want %s", got, want)
+	}
+
+	router := fingerprintRouter("172.18.0.2")
+	handler = NewHandler(router, "roji.localhost", testStatusConfig(), WithSettings(settings), WithManifest(manifest))
+	if drift := handler.CheckDrift(); len(drift) != 0 {
+		t.Errorf("CheckDrift() = %q, want none", drift)
+	}
+
+	router.RemoveBackend("api1")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/status", nil))
+	var status StatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Config.Fingerprint == status.Config.Manifest || len(status.Config.Drift) != 1 {
+		t.Errorf("config status = %+v, want one difference", status.Config)
+	}
+}
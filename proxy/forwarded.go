@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// forwardingHeaders carry the client address and scheme proxies in front of
+// roji saw. X-Forwarded-Host stays: it marks tunneled requests (see tunnel.go).
+var forwardingHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Proto", "X-Real-IP"}
+
+// TrustForwardedProto serves plain HTTP requests from a TLS-terminating proxy
+// in front of roji (--no-tls) as the proxy received them: a request with
+// X-Forwarded-Proto: https gets a TLS connection state, so backends see https,
+// cookies stay Secure and redirects keep the scheme. Wrap it in
+// TrustProxies, so that only the proxy may send the header.
+func TrustForwardedProto(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Chained proxies append their own; the first one faced the client
+		proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		if r.TLS == nil && strings.EqualFold(strings.TrimSpace(proto), "https") {
+			r = r.Clone(r.Context())
+			r.TLS = &tls.ConnectionState{}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TrustProxies honors forwarding headers only from proxies in trusted
+// (--trusted-proxies). A request from one of them gets the client address
+// from Forwarded or X-Forwarded-For as its RemoteAddr, so access logs, LAN
+// mode and backends see the client. Requests from anyone else lose those
+// headers, including X-Forwarded-Proto for TrustForwardedProto.
+func TrustProxies(trusted []netip.Prefix, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.Clone(r.Context())
+		addr, ok := clientAddr(r)
+		if !ok || !prefixesContain(trusted, addr) {
+			for _, name := range forwardingHeaders {
+				r.Header.Del(name)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if client, ok := forwardedClient(r.Header, trusted); ok {
+			r.RemoteAddr = client.String()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClient walks the forwarded addresses from the nearest proxy back
+// and returns the first one that isn't a trusted proxy: the client, as far
+// as addresses that can't be spoofed go
+func forwardedClient(header http.Header, trusted []netip.Prefix) (netip.Addr, bool) {
+	hops := forwardedFor(header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			return netip.Addr{}, false // "unknown" or obfuscated; stop trusting the chain
+		}
+		addr = addr.Unmap()
+		if i == 0 || !prefixesContain(trusted, addr) {
+			return addr, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// forwardedFor lists the client and proxy addresses of Forwarded (RFC 7239)
+// for= parameters, or else of X-Forwarded-For, ports and brackets removed
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(key, "for") {
+					hops = append(hops, stripPort(strings.Trim(v, `"`)))
+				}
+			}
+		}
+	}
+	if len(hops) > 0 {
+		return hops
+	}
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, stripPort(hop))
+			}
+		}
+	}
+	return hops
+}
+
+// stripPort removes the port from "192.0.2.1:4711" or "[2001:db8::1]:4711"
+// but leaves bare IPv6 addresses alone
+func stripPort(hop string) string {
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]")
+}
+
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestTrustForwardedProto(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "http"},
+		{"http", "http"},
+		{"https", "https"},
+		{"HTTPS", "https"},
+		{"https, http", "https"},
+		{"http, https", "http"},
+	}
+	for _, tt := range tests {
+		var got string
+		handler := TrustForwardedProto(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = forwardedProto(r)
+		}))
+		req := httptest.NewRequest("GET", "http://app.localhost/", nil)
+		if tt.header != "" {
+			req.Header.Set("X-Forwarded-Proto", tt.header)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("X-Forwarded-Proto %q: forwardedProto() = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTrustForwardedProto_Backend(t *testing.T) {
+	backend := newTestBackend(t, "app.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Forwarded-Proto")))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := TrustForwardedProto(NewHandler(router, "roji.localhost", testStatusConfig()))
+
+	req := httptest.NewRequest("GET", "http://app.localhost/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Body.String(); got != "https" {
+		t.Errorf("backend got X-Forwarded-Proto %q, want https", got)
+	}
+}
+
+func TestTrustProxies(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("127.0.0.1/32")}
+	tests := []struct {
+		name      string
+		remote    string
+		header    string
+		value     string
+		want      string
+		wantProto string
+	}{
+		{"edge", "192.0.2.7:5000", "", "", "192.0.2.7:5000", ""},
+		{"spoofed", "192.0.2.7:5000", "X-Forwarded-For", "203.0.113.9", "192.0.2.7:5000", ""},
+		{"trusted proxy", "10.1.2.3:5000", "X-Forwarded-For", "203.0.113.9", "203.0.113.9", "https"},
+		{"chain", "10.1.2.3:5000", "X-Forwarded-For", "198.51.100.1, 203.0.113.9, 10.4.4.4", "203.0.113.9", "https"},
+		{"only proxies", "127.0.0.1:5000", "X-Forwarded-For", "10.9.9.9, 10.4.4.4", "10.9.9.9", "https"},
+		{"forwarded", "10.1.2.3:5000", "Forwarded", `for="[2001:db8::1]:4711";proto=https, for=10.4.4.4`, "2001:db8::1", "https"},
+		{"unknown", "10.1.2.3:5000", "Forwarded", "for=unknown", "10.1.2.3:5000", "https"},
+		{"no header", "10.1.2.3:5000", "", "", "10.1.2.3:5000", "https"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRemote, gotProto string
+			handler := TrustProxies(trusted, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRemote, gotProto = r.RemoteAddr, r.Header.Get("X-Forwarded-Proto")
+			}))
+			req := httptest.NewRequest("GET", "http://app.localhost/", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set("X-Forwarded-Proto", "https")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if gotRemote != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", gotRemote, tt.want)
+			}
+			if gotProto != tt.wantProto {
+				t.Errorf("X-Forwarded-Proto = %q, want %q", gotProto, tt.wantProto)
+			}
+		})
+	}
+}
+
+func TestTrustProxies_Backend(t *testing.T) {
+	backend := newTestBackend(t, "app.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Forwarded-For")))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := TrustProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		NewHandler(router, "roji.localhost", testStatusConfig()))
+
+	req := httptest.NewRequest("GET", "https://app.localhost/", nil)
+	req.RemoteAddr = "10.1.2.3:5000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Body.String(); got != "203.0.113.9" {
+		t.Errorf("backend got X-Forwarded-For %q, want 203.0.113.9", got)
+	}
+}
@@ -0,0 +1,275 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PACPath serves the proxy auto-config file on the HTTP port (--forward-proxy)
+const PACPath = "/proxy.pac"
+
+// ForwardProxy lets browsers use roji's HTTP port as a proxy for the
+// hostnames roji serves (--forward-proxy), so custom domains like *.test work
+// without DNS or hosts file changes. The PAC file sends only those hostnames
+// to roji. roji never dials the target of a CONNECT: tunnels are served by
+// roji's own servers in-process, keeping the client's address for LAN mode
+// and access logs, and other hostnames are refused, so it isn't an open proxy.
+type ForwardProxy struct {
+	handler  *Handler // knows the hostnames roji serves
+	httpPort int
+
+	tlsConns   *connListener // tunnels to serve as HTTPS (nil with --no-tls)
+	plainConns *connListener // tunnels to serve as HTTP (ws:// and the HTTP port)
+}
+
+// NewForwardProxy creates the forward proxy of handler's hostnames. Serve
+// TLSListener with the HTTPS server (unless TLS is terminated in front of
+// roji) and PlainListener with the HTTP server.
+func NewForwardProxy(handler *Handler) *ForwardProxy {
+	cfg := handler.statusConfig
+	p := &ForwardProxy{
+		handler:    handler,
+		httpPort:   cfg.HTTPPort,
+		plainConns: newConnListener(cfg.HTTPPort),
+	}
+	if !cfg.NoTLS {
+		p.tlsConns = newConnListener(cfg.HTTPSPort)
+	}
+	return p
+}
+
+// TLSListener accepts the tunnels to HTTPS ports; nil with --no-tls
+func (p *ForwardProxy) TLSListener() net.Listener {
+	if p.tlsConns == nil {
+		return nil
+	}
+	return p.tlsConns
+}
+
+// PlainListener accepts the tunnels to the HTTP port
+func (p *ForwardProxy) PlainListener() net.Listener {
+	return p.plainConns
+}
+
+// Wrap answers CONNECT requests and the PAC file before next sees them
+func (p *ForwardProxy) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			p.serveConnect(w, r)
+			return
+		}
+		if r.URL.Path == PACPath && p.pacHost(hostWithoutPort(r.Host)) {
+			p.servePAC(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pacHost reports whether the PAC file is served on a hostname: roji's
+// addresses and the dashboard, not routes, which may have their own
+func (p *ForwardProxy) pacHost(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	return hostname == "" || hostname == "localhost" || net.ParseIP(hostname) != nil ||
+		hostname == p.handler.dashboardHost || hostname == p.handler.lanName
+}
+
+// serveConnect tunnels a CONNECT to one of roji's hostnames into roji's own
+// HTTPS (or, for the HTTP port, HTTP) server
+func (p *ForwardProxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	hostname, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		http.Error(w, "CONNECT needs host:port", http.StatusBadRequest)
+		return
+	}
+	hostname = strings.ToLower(hostname)
+	if !p.handler.hostAllowed(hostname) {
+		slog.Warn("refused CONNECT to unknown host", "host", hostname, "client", r.RemoteAddr)
+		http.Error(w, "roji only proxies its own hostnames, not "+hostname, http.StatusForbidden)
+		return
+	}
+
+	target := p.tlsConns
+	if n, _ := strconv.Atoi(port); n == 80 || n == p.httpPort {
+		target = p.plainConns
+	}
+	if target == nil {
+		http.Error(w, "roji serves no HTTPS with --no-tls", http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "CONNECT is not supported over this protocol", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		slog.Warn("failed to take over CONNECT", "host", hostname, "error", err)
+		return
+	}
+	// The HTTP server's read timeout would cut the tunnel otherwise
+	conn.SetDeadline(time.Time{})
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		conn.Close()
+		return
+	}
+	slog.Debug("tunneling CONNECT", "host", r.Host, "client", r.RemoteAddr)
+	if !target.inject(&tunnelConn{Conn: conn, reader: buffered.Reader}) {
+		conn.Close()
+	}
+}
+
+// servePAC answers the proxy auto-config file. Browsers send roji's
+// hostnames to this address and port and everything else direct.
+func (p *ForwardProxy) servePAC(w http.ResponseWriter, r *http.Request) {
+	proxyHost := hostWithoutPort(r.Host)
+	if proxyHost == "" {
+		proxyHost = "127.0.0.1"
+	}
+	suffixes, hosts := p.handler.proxiedHostnames()
+
+	w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, pacFile(net.JoinHostPort(proxyHost, strconv.Itoa(p.httpPort)), suffixes, hosts))
+}
+
+// pacFile renders a PAC file proxying the hostnames under suffixes and the
+// exact hosts through proxyAddr
+func pacFile(proxyAddr string, suffixes, hosts []string) string {
+	quote := func(list []string) string {
+		if list == nil {
+			list = []string{} // null would break the loops
+		}
+		data, _ := json.Marshal(list)
+		return string(data)
+	}
+	return `// roji: send its hostnames to roji, everything else direct
+var suffixes = ` + quote(suffixes) + `;
+var hosts = ` + quote(hosts) + `;
+
+function FindProxyForURL(url, host) {
+  host = host.toLowerCase();
+  for (var i = 0; i < suffixes.length; i++) {
+    if (host == suffixes[i] || dnsDomainIs(host, "." + suffixes[i])) {
+      return "PROXY ` + proxyAddr + `";
+    }
+  }
+  for (var j = 0; j < hosts.length; j++) {
+    if (host == hosts[j]) {
+      return "PROXY ` + proxyAddr + `";
+    }
+  }
+  return "DIRECT";
+}
+`
+}
+
+// proxiedHostnames returns the domains roji serves every subdomain of (the
+// base domain and *.domain allowed hosts) and the other hostnames it serves:
+// the dashboard, routes outside those domains and exact allowed hosts
+func (h *Handler) proxiedHostnames() (suffixes, hosts []string) {
+	if base := h.statusConfig.BaseDomain; base != "" {
+		suffixes = append(suffixes, base)
+	}
+	for _, pattern := range h.allowedHosts {
+		if pattern == "*" {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			suffixes = append(suffixes, suffix)
+		} else {
+			hosts = append(hosts, pattern)
+		}
+	}
+
+	covered := func(hostname string) bool {
+		for _, suffix := range suffixes {
+			if hostname == suffix || strings.HasSuffix(hostname, "."+suffix) {
+				return true
+			}
+		}
+		return false
+	}
+	candidates := []string{h.dashboardHost}
+	for _, r := range h.router.ListRoutes() {
+		candidates = append(candidates, r.Hostname)
+	}
+	for _, hostname := range candidates {
+		if hostname != "" && !covered(hostname) && !slices.Contains(hosts, hostname) {
+			hosts = append(hosts, hostname)
+		}
+	}
+	slices.Sort(suffixes)
+	slices.Sort(hosts)
+	return slices.Compact(suffixes), slices.Compact(hosts)
+}
+
+// tunnelConn is a hijacked connection that first returns what the HTTP
+// server had already read from it
+type tunnelConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *tunnelConn) Read(b []byte) (int, error) {
+	if c.reader.Buffered() > 0 {
+		return c.reader.Read(b)
+	}
+	return c.Conn.Read(b)
+}
+
+// connListener is a net.Listener that accepts the connections handed to it
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+
+	once sync.Once
+	done chan struct{}
+}
+
+func newConnListener(port int) *connListener {
+	return &connListener{
+		addr:  &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port},
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// inject hands a connection to the server accepting on l. It reports false
+// once l is closed.
+func (l *connListener) inject(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
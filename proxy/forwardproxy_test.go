@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHandler_ProxiedHostnames(t *testing.T) {
+	statusConfig := testStatusConfig()
+	statusConfig.BaseDomain = "test"
+
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "web.app.test", nil))
+	router.AddBackend(newTestBackend(t, "printer.lan", nil))
+	handler := NewHandler(router, "roji.test", statusConfig,
+		WithAllowedHosts([]string{"*.corp.internal", "api.example.com", "*"}))
+
+	suffixes, hosts := handler.proxiedHostnames()
+	if want := []string{"corp.internal", "test"}; !slices.Equal(suffixes, want) {
+		t.Errorf("suffixes = %v, want %v", suffixes, want)
+	}
+	if want := []string{"api.example.com", "printer.lan"}; !slices.Equal(hosts, want) {
+		t.Errorf("hosts = %v, want %v", hosts, want)
+	}
+}
+
+func TestForwardProxy(t *testing.T) {
+	backend := newTestBackend(t, "web.app.test", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello from " + r.Host))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	statusConfig := testStatusConfig()
+	statusConfig.BaseDomain = "test"
+	handler := NewHandler(router, "roji.test", statusConfig)
+	forward := NewForwardProxy(handler)
+
+	// roji's HTTPS server for the tunnels, and its HTTP port
+	tunnels := httptest.NewUnstartedServer(handler)
+	tunnels.Listener.Close()
+	tunnels.Listener = forward.TLSListener()
+	tunnels.StartTLS()
+	defer tunnels.Close()
+	httpPort := httptest.NewServer(forward.Wrap(&RedirectHandler{HTTPSPort: 443}))
+	defer httpPort.Close()
+
+	proxyURL, _ := url.Parse(httpPort.URL)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	// A route is reached through the tunnel, by name only roji knows
+	resp, err := client.Get("https://web.app.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello from web.app.test" {
+		t.Errorf("status = %d, body = %q", resp.StatusCode, body)
+	}
+
+	// Other hostnames are refused, not dialed
+	if _, err := client.Get("https://example.com/"); err == nil || !strings.Contains(err.Error(), "Forbidden") {
+		t.Errorf("CONNECT to example.com: err = %v, want Forbidden", err)
+	}
+
+	// The PAC file sends roji's domain to the HTTP port
+	resp, err = http.Get(httpPort.URL + PACPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if ctype := resp.Header.Get("Content-Type"); ctype != "application/x-ns-proxy-autoconfig" {
+		t.Errorf("Content-Type = %q", ctype)
+	}
+	if !strings.Contains(string(body), `["test"]`) || !strings.Contains(string(body), "var hosts = [];") || !strings.Contains(string(body), "PROXY 127.0.0.1:80") {
+		t.Errorf("unexpected PAC file:\n%s", body)
+	}
+}
@@ -1,30 +1,30 @@
 package proxy
 
 import (
-	"embed"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/kan/roji/docker"
 )
 
-// sharedTransport is used for connection pooling across all proxied requests
+// sharedTransport pools connections of roji's own requests to backends
+// (snapshots); proxied requests use the Handler's transports
 var sharedTransport = &http.Transport{
 	MaxIdleConns:        100,
 	MaxIdleConnsPerHost: 10,
 	IdleConnTimeout:     90 * time.Second,
 }
 
-//go:embed templates/*.html
-var templateFS embed.FS
-
-var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
-
 // StatusConfig contains configuration for the status endpoint
 type StatusConfig struct {
 	Version       string
@@ -38,6 +38,7 @@ type StatusConfig struct {
 	BaseDomain    string
 	HTTPPort      int
 	HTTPSPort     int
+	NoTLS         bool // TLS is terminated in front of roji; there are no certificates
 }
 
 // Handler is the main HTTP handler for the reverse proxy
@@ -45,66 +46,390 @@ type Handler struct {
 	router        *Router
 	dashboardHost string // hostname for dashboard (e.g., "roji.localhost")
 	statusConfig  *StatusConfig
+	outliers      *OutlierDetector // optional; nil disables replica ejection
+	coalescer     *coalescer
+	limiter       *concurrencyLimiter
+	transports    *backendTransports
+	remapper      HostnameRemapper // optional; enables /_api/hostnames
+	rotator       HostnameRotator  // optional; enables /_api/rotate
+	allowHSTS     bool             // pass backend HSTS headers through for *.localhost
+	snapshots     *Snapshotter     // optional; enables /_api/snapshots
+	changelog     *Changelog       // optional; dashboard timeline of image revisions
+	devices       *DeviceAllowList // optional; LAN mode client allow list
+	emulators     bool             // serve routes under emulator bridge domains
+	shares        *ShareSigner     // optional; enables share links and /_api/share
+	inflight      *inflightTracker
+	metrics       *metrics
+	maintenance   *maintenanceModes // routes paused from the dashboard or API
+	prober        *HealthProber     // optional; backend health dots on the dashboard
+	apiToken      string            // optional; required for the dashboard and /_api/*
+	healthChecks  []HealthCheckFunc // deep checks for /_api/health?deep=true
+
+	accessLogFields []string      // request log fields of routes without roji.access-log-fields
+	slowRequest     time.Duration // warn about requests slower than this (0 = never)
+	errorAlerts     *errorAlerter // optional; alerts on routes answering mostly 5xx
+
+	startupNotice atomic.Pointer[string] // banner shown while roji is starting up
+
+	exposer      RouteExposer // optional; enables /_api/expose
+	exposeMu     sync.Mutex
+	exposeLeases map[string]*time.Timer // exposed hostname -> expiry
+
+	settings  map[string]string // effective flags, for /_api/config
+	manifest  *ConfigDocument   // optional; declared configuration for drift detection
+	driftMu   sync.Mutex
+	lastDrift []string
+
+	mocks *MockSet // optional; canned responses ahead of routing (--mocks)
+
+	banner string // default banner for routes without roji.banner (--banner)
+
+	liveReload *LiveReload // optional; reloads roji.live-reload pages on restarts
+
+	recorder *Recorder // optional; captures roji.record exchanges for /_api/requests
+
+	events  *EventHub     // optional; enables /_api/events
+	history *RouteHistory // optional; enables /_api/history
+
+	pprof http.Handler // optional; net/http/pprof at /debug/pprof/ (--enable-pprof)
+
+	allowedHosts []string // hostname patterns served besides roji's own (--allowed-hosts)
+
+	overridesDir      string   // root of roji.overrides files (--overrides-dir; "" = overrides off)
+	overridesExcluded []string // directories overrides never serve from (the certificates)
+
+	lanIP   string // LAN address routes are served under as <ip>.nip.io (--lan)
+	lanName string // mDNS name serving the dashboard (--lan)
+	mdns    bool   // serve routes under their advertised .local names (--mdns)
+
+	tailscale *TailscaleServe // optional; serves routes under their tailnet names (--tailscale)
+}
+
+// HandlerOption configures optional Handler behaviour
+type HandlerOption func(*Handler)
+
+// WithOutlierDetector enables ejection of misbehaving replicas on load-balanced routes
+func WithOutlierDetector(d *OutlierDetector) HandlerOption {
+	return func(h *Handler) {
+		h.outliers = d
+	}
 }
 
 // NewHandler creates a new proxy handler
-func NewHandler(router *Router, dashboardHost string, statusConfig *StatusConfig) *Handler {
-	return &Handler{
+func NewHandler(router *Router, dashboardHost string, statusConfig *StatusConfig, opts ...HandlerOption) *Handler {
+	h := &Handler{
 		router:        router,
 		dashboardHost: strings.ToLower(dashboardHost),
 		statusConfig:  statusConfig,
+		coalescer:     newCoalescer(),
+		limiter:       newConcurrencyLimiter(),
+		transports:    newBackendTransports(DefaultTransportConfig()),
+		inflight:      newInflightTracker(),
+		metrics:       newMetrics(),
+		maintenance:   newMaintenanceModes(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// selectBackend picks the replica that should serve this request
+func (h *Handler) selectBackend(route *Route) *docker.Backend {
+	candidates := unpaused(route.Candidates())
+	if h.outliers == nil {
+		return candidates[0]
 	}
+	return h.outliers.Select(candidates)
 }
 
 // ServeHTTP implements http.Handler
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
+	// Stylesheets of roji's own pages, on every hostname and before any
+	// guard, so the pairing and login pages are styled too
+	if strings.HasPrefix(r.URL.Path, AssetsPath) {
+		serveAsset(w, r)
+		return
+	}
+
+	// The CA certificate, for devices that don't trust roji yet
+	if r.URL.Path == CAPath {
+		h.serveCA(w, r)
+		return
+	}
+
+	// Share links (?roji_share=) become a cookie scoped to the hostname
+	if h.redeemShareLink(w, r) {
+		return
+	}
+
+	// LAN mode: unknown devices only see the pairing page
+	if h.guardLAN(w, r) {
+		return
+	}
+
 	// Extract hostname (remove port if present)
-	hostname := r.Host
-	if idx := strings.LastIndex(hostname, ":"); idx != -1 {
-		hostname = hostname[:idx]
+	hostname := strings.ToLower(hostWithoutPort(r.Host))
+
+	// HTTP/1.0 clients may omit the Host header entirely
+	if hostname == "" {
+		hostname = h.router.LegacyHostname()
+	}
+
+	// Emulators and LAN devices reach routes via <route>.10.0.2.2.nip.io
+	hostname = h.fromEmulatorHostname(hostname)
+	// Other devices reach routes via the .local names advertised over mDNS
+	hostname = h.fromMDNSName(hostname)
+	// Teammates reach routes via their Tailscale service names
+	hostname = h.fromTailscaleName(hostname)
+
+	// DNS rebinding: pages on other sites must not reach routes by name
+	if h.guardHost(w, r, hostname) {
+		return
 	}
-	hostname = strings.ToLower(hostname)
 
 	// Check if this is the dashboard
 	if h.dashboardHost != "" && hostname == h.dashboardHost {
-		// Health check endpoints
-		if r.URL.Path == "/_api/health" || r.URL.Path == "/healthz" {
+		// Container health checks stay open with --api-token
+		if r.URL.Path == "/healthz" {
+			h.serveHealth(w, r)
+			return
+		}
+		// --api-token: scripts send a bearer token, browsers log in
+		if h.guardDashboard(w, r) {
+			return
+		}
+		// Runtime profiles of roji itself (--enable-pprof)
+		if h.pprof != nil && strings.HasPrefix(r.URL.Path, PprofPath) {
+			h.pprof.ServeHTTP(w, r)
+			return
+		}
+		// Versioned API paths (/_api/v1/routes) name the same endpoints
+		path := apiPath(r.URL.Path)
+		// Specification of the management API
+		if path == OpenAPIPath {
+			serveOpenAPI(w, r)
+			return
+		}
+		// Health check endpoint
+		if path == "/_api/health" {
 			h.serveHealth(w, r)
 			return
 		}
 		// Status endpoint
-		if r.URL.Path == "/_api/status" {
+		if path == "/_api/status" {
 			h.serveStatus(w, r)
 			return
 		}
 		// API endpoint for route listing
-		if r.URL.Path == "/_api/routes" {
+		if path == "/_api/routes" {
 			h.serveRoutesAPI(w, r)
 			return
 		}
+		// Maintenance mode (/_api/routes/{host}/pause)
+		if rest, ok := strings.CutPrefix(path, "/_api/routes/"); ok {
+			h.serveRouteAPI(w, r, rest)
+			return
+		}
+		// Sticky hostname assignments
+		if path == "/_api/hostnames" {
+			h.serveHostnamesAPI(w, r)
+			return
+		}
+		// LAN mode paired devices
+		if path == "/_api/devices" {
+			h.serveDevicesAPI(w, r)
+			return
+		}
+		// Endpoint diffs across container restarts
+		if path == "/_api/snapshots" {
+			h.serveSnapshotsAPI(w, r)
+			return
+		}
+		// Expiring share links
+		if path == "/_api/share" {
+			h.serveShareAPI(w, r)
+			return
+		}
+		// Effective configuration (roji config)
+		if path == "/_api/config" {
+			h.serveConfigAPI(w, r)
+			return
+		}
+		// Temporary routes to host ports (roji expose)
+		if path == "/_api/expose" {
+			h.serveExposeAPI(w, r)
+			return
+		}
+		// Routing dry runs (roji debug route)
+		if path == "/_api/explain" {
+			h.serveExplainAPI(w, r)
+			return
+		}
+		// Route table dump and lookup traces (roji debug table)
+		if path == "/_api/debug" {
+			h.serveDebugAPI(w, r)
+			return
+		}
+		// Route, health and certificate changes for external tooling
+		if path == "/_api/events" {
+			h.serveEventsAPI(w, r)
+			return
+		}
+		// When routes appeared and went away (roji history)
+		if path == "/_api/history" {
+			h.serveHistoryAPI(w, r)
+			return
+		}
+		// Per-route traffic counters (roji top)
+		if path == "/_api/metrics" {
+			h.serveMetricsAPI(w, r)
+			return
+		}
+		// Captured requests of roji.record routes
+		if path == "/_api/requests" {
+			h.serveRequestsAPI(w, r)
+			return
+		}
+		// Device URLs of the routes (roji qr)
+		if path == "/_api/qr" {
+			h.serveQRAPI(w, r)
+			return
+		}
+		// Origin rotation (fresh cookies/storage)
+		if path == "/_api/rotate" {
+			h.serveRotateAPI(w, r)
+			return
+		}
+		if strings.HasPrefix(path, "/_api/") {
+			http.Error(w, "unknown API endpoint "+r.URL.Path+" (see "+OpenAPIPath+")", http.StatusNotFound)
+			return
+		}
+		// QR codes of the routes for phones
+		if path == QRPath {
+			h.serveQR(w, r)
+			return
+		}
 		h.serveDashboard(w, r)
 		return
 	}
 
-	// Look up route
+	// Browser cleanup helper, available even when the route is gone
+	if r.URL.Path == UnbrickPath {
+		h.serveUnbrick(w, r, hostname)
+		return
+	}
+
+	// Live reload stream and script, available while the container restarts
+	if h.liveReload != nil {
+		switch r.URL.Path {
+		case LiveReloadPath:
+			h.liveReload.serveLiveReload(w, r, hostname)
+			return
+		case liveReloadScriptPath:
+			serveLiveReloadScript(w)
+			return
+		}
+	}
+
+	// Look up route; mocks don't need one, but they sit behind the guards of
+	// the hostname's routes
 	route := h.router.Lookup(hostname, r.URL.Path)
+	var tunneled bool
+	if route != nil {
+		// Aliases redirect to the canonical hostname (roji.canonical-host)
+		if route.Backend.RedirectTo != "" {
+			redirectCanonical(w, r, route.Backend.RedirectTo)
+			return
+		}
+
+		// Routes shared through public tunnels are never indexed (roji.tunnel),
+		// and routes may require credentials from anything but this machine
+		// (roji.tunnel-auth, roji.tunnel-secret)
+		tunneled = h.isTunneled(r, route)
+		if h.guardTunnel(w, r, route, tunneled) {
+			return
+		}
+	}
+
+	// Canned responses for endpoints that don't exist yet (--mocks)
+	if m := h.mocks.Match(r.Method, hostname, r.URL.Path); m != nil {
+		if route == nil && h.guardUnroutedMock(w, r, hostname) {
+			return
+		}
+		h.serveMock(w, r, hostname, m)
+		return
+	}
 	if route == nil {
 		h.handleNotFound(w, r, hostname)
 		return
 	}
 
-	// Create reverse proxy for this request
-	targetURL := &url.URL{
-		Scheme: "http",
-		Host:   fmt.Sprintf("%s:%d", route.Backend.Host, route.Backend.Port),
+	// Composite routes hand mounted paths to fragment routes (roji.compose).
+	// The Host header is kept, so fragments share the composite origin's cookies.
+	composite := route
+	route, mountPath, ok := h.resolveMount(route, r.URL.Path)
+	if !ok {
+		slog.Warn("fragment route not found",
+			"hostname", hostname,
+			"path", r.URL.Path,
+			"mount", mountPath)
+		http.Error(w, fmt.Sprintf("%s is mounted from a route that isn't running (roji.compose on %s)",
+			mountPath, composite.Backend.ContainerName), http.StatusBadGateway)
+		return
+	}
+
+	// Routes in maintenance simulate an outage without stopping containers
+	if since, ok := h.inMaintenance(composite, route); ok {
+		h.serveMaintenance(w, r, route, since)
+		return
+	}
+
+	// Paused containers would hang the request (docker pause)
+	if route.Paused() {
+		h.servePaused(w, r, route)
+		return
+	}
+
+	// Refuse service worker registrations for routes that opt out
+	if route.Backend.BlockServiceWorkers && isServiceWorkerScript(r) {
+		http.Error(w, "service workers are blocked for this route (roji.block-service-workers)", http.StatusNotFound)
+		return
+	}
+
+	// Legacy clients: don't assume they handle keep-alive; close after each response
+	if route.Backend.Legacy {
+		w.Header().Set("Connection", "close")
+	}
+
+	// Track in-flight requests for shutdown drain reporting
+	defer h.inflight.begin(route.Hostname)()
+
+	// Static routes are served from a local directory (--static-routes)
+	if route.Backend.StaticDir != "" {
+		h.serveStatic(w, r, route)
+		return
 	}
 
+	// Local files replace some of the backend's responses (roji.overrides)
+	if file := h.overrideTarget(route, r); file != "" {
+		h.serveOverride(w, r, route, file)
+		return
+	}
+
+	// Pick a replica (round-robin, skipping ejected outliers)
+	backend := h.selectBackend(route)
+
+	// Create reverse proxy for this request
+	targetURL := upstreamURL(backend)
+
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
 
-	// Use shared transport for connection pooling
-	proxy.Transport = sharedTransport
+	// Pooled connections (roji.keep-alive, roji.max-idle-conns,
+	// roji.conn-lifetime)
+	proxy.Transport = h.transports.forBackend(backend)
 
 	// SSE support: flush responses immediately (disable buffering)
 	proxy.FlushInterval = -1
@@ -115,11 +440,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		originalDirector(req)
 
 		// Strip path prefix if configured
-		if route.PathPrefix != "" {
-			req.URL.Path = strings.TrimPrefix(req.URL.Path, route.PathPrefix)
-			if req.URL.Path == "" {
-				req.URL.Path = "/"
-			}
+		req.URL.Path = stripPathPrefix(req.URL.Path, route.PathPrefix)
+
+		// External hosts expect their own name in Host (and, via the URL, in SNI)
+		if backend.ExternalURL != "" {
+			req.Host = targetURL.Host
 		}
 
 		// Security: Remove existing X-Forwarded-* headers to prevent spoofing
@@ -128,16 +453,32 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		req.Header.Del("X-Forwarded-Host")
 		req.Header.Del("X-Forwarded-Proto")
 		req.Header.Del("X-Real-IP")
+		req.Header.Del(ForwardedPrefixHeader)
+		req.Header.Del(TunnelSecretHeader)
+		if composite.Backend.TunnelAuth != "" && needsTunnelCredentials(r, tunneled) {
+			req.Header.Del("Authorization") // roji's credentials, not the backend's
+		}
 
 		// Set X-Forwarded-* headers with trusted values
 		req.Header.Set("X-Forwarded-Host", r.Host)
-		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Proto", forwardedProto(r))
 		if r.RemoteAddr != "" {
-			if idx := strings.LastIndex(r.RemoteAddr, ":"); idx != -1 {
-				req.Header.Set("X-Forwarded-For", r.RemoteAddr[:idx])
-			}
+			req.Header.Set("X-Forwarded-For", hostWithoutPort(r.RemoteAddr))
 		}
 		req.Header.Set("X-Real-IP", req.Header.Get("X-Forwarded-For"))
+		if mountPath != "" {
+			req.Header.Set(ForwardedPrefixHeader, mountPath)
+		}
+
+		// ESI assembly and banners need a body roji can decode: gzip, which
+		// roji compresses again, for clients that take it; otherwise the
+		// transport negotiates gzip itself and decompresses transparently
+		if h.rewritesHTML(route.Backend) {
+			req.Header.Del("Accept-Encoding")
+			if acceptsGzip(r.Header) {
+				req.Header.Set("Accept-Encoding", "gzip")
+			}
+		}
 	}
 
 	// Error handler
@@ -147,45 +488,84 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			"path", r.URL.Path,
 			"target", targetURL.String(),
 			"error", err)
+		if h.outliers != nil {
+			h.outliers.Report(route, backend, true, time.Since(startTime))
+		}
+		h.metrics.observe(route, r, http.StatusBadGateway, time.Since(startTime))
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
 	}
 
 	// Log the request
 	proxy.ModifyResponse = func(resp *http.Response) error {
 		duration := time.Since(startTime)
-		slog.Info("request",
-			"method", r.Method,
-			"host", hostname,
-			"path", r.URL.Path,
-			"status", resp.StatusCode,
-			"duration", duration.Round(time.Millisecond),
-			"target", route.Backend.ServiceName)
+		h.logRequest(route.Backend.AccessLog, r, accessLogEntry{
+			host:     hostname,
+			status:   resp.StatusCode,
+			duration: duration,
+			target:   backend.ServiceName,
+		})
+		h.guardResponse(route, hostname, resp.Header)
+		rewriteLocations(resp, route, backend, targetURL, r)
+		rewriteCookies(resp, backend.Cookies)
+		if tunneled {
+			resp.Header.Set("X-Robots-Tag", noindex)
+		}
+		if route.Backend.ESI {
+			if err := h.assembleESI(r, resp); err != nil {
+				return err
+			}
+		}
+		if banner := h.routeBanner(route.Backend); banner != "" && !esiFragment(r) {
+			if err := injectBanner(bannerText(banner, backend), resp); err != nil {
+				return err
+			}
+		}
+		if h.injectsLiveReload(route.Backend) && !esiFragment(r) {
+			if err := injectLiveReload(resp); err != nil {
+				return err
+			}
+		}
+		if h.outliers != nil {
+			h.outliers.Report(route, backend, resp.StatusCode >= 500, duration)
+		}
+		h.metrics.observe(route, r, resp.StatusCode, duration)
 		return nil
 	}
 
-	proxy.ServeHTTP(w, r)
-}
-
-func (h *Handler) serveDashboard(w http.ResponseWriter, r *http.Request) {
-	routes := h.router.ListRoutes()
+	// Capture the exchange for the inspector (roji.record)
+	w, r, recording := h.startRecording(w, r, route, hostname)
+	if recording != nil {
+		defer recording.finish(backend.ContainerName)
+	}
 
-	data := struct {
-		Routes  []RouteInfo
-		Version string
-	}{
-		Routes:  routes,
-		Version: h.statusConfig.Version,
+	// Requests over roji.max-concurrent wait for a slot, or are shed
+	forward := func(w http.ResponseWriter) {
+		if concurrencyLimited(backend, r) {
+			release, ok := h.limiter.acquire(r.Context(), backend)
+			if !ok {
+				h.serveBusy(w, r, route, backend, hostname, startTime)
+				return
+			}
+			defer release()
+		}
+		proxy.ServeHTTP(w, r)
 	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := templates.ExecuteTemplate(w, "dashboard.html", data); err != nil {
-		slog.Error("failed to render dashboard template", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	// Collapse identical concurrent GETs into one upstream call (roji.coalesce)
+	if route.Backend.Coalesce && coalescable(r) {
+		if shared := h.coalescer.Do(w, r, forward); shared {
+			slog.Debug("request coalesced",
+				"host", hostname,
+				"path", r.URL.Path)
+		}
+		return
 	}
+
+	forward(w)
 }
 
 func (h *Handler) serveRoutesAPI(w http.ResponseWriter, r *http.Request) {
-	routes := h.router.ListRoutes()
+	routes := h.routesWithState()
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(routes); err != nil {
@@ -197,18 +577,27 @@ func (h *Handler) serveRoutesAPI(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) serveHealth(w http.ResponseWriter, r *http.Request) {
 	routes := h.router.ListRoutes()
 
-	health := struct {
-		Status string `json:"status"`
-		Routes int    `json:"routes"`
-	}{
+	health := HealthResponse{
 		Status: "healthy",
 		Routes: len(routes),
 	}
 
+	// Deep checks: Docker events, route consistency, certificates, listeners
+	code := http.StatusOK
+	if deep := r.URL.Query().Get("deep"); deep == "1" || deep == "true" {
+		health.Checks = h.runHealthChecks(r.Context())
+		for _, check := range health.Checks {
+			if !check.OK {
+				health.Status = "unhealthy"
+				code = http.StatusServiceUnavailable
+			}
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
 	if err := json.NewEncoder(w).Encode(health); err != nil {
 		slog.Error("failed to encode health response", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 }
@@ -237,8 +626,13 @@ func (h *Handler) serveStatus(w http.ResponseWriter, r *http.Request) {
 			HTTPPort:      h.statusConfig.HTTPPort,
 			HTTPSPort:     h.statusConfig.HTTPSPort,
 		},
+		Config: h.configStatus(),
 	}
 
+	// Requests being proxied; shutdown progress is only logged, since the
+	// listeners are closed by then
+	status.Proxy.InFlightRequests = h.DrainStatus().InFlight
+
 	// Determine overall health
 	status.Health = determineHealth(status)
 
@@ -250,48 +644,132 @@ func (h *Handler) serveStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handler) handleNotFound(w http.ResponseWriter, r *http.Request, hostname string) {
-	slog.Warn("no route found",
-		"hostname", hostname,
-		"path", r.URL.Path)
+// RedirectHandler redirects HTTP to HTTPS
+type RedirectHandler struct {
+	HTTPSPort int
+	// StatusCode is the redirect status (301 if zero); 307 and 308 keep the
+	// method and body, for clients that replay a POST as a GET
+	StatusCode int
+
+	// Optional: routes labeled roji.plain-http are proxied by Proxy instead of redirected
+	Router *Router
+	Proxy  http.Handler
+
+	// PlainHTTPPaths are path prefixes proxied on every hostname instead of
+	// redirected (--plain-http-paths), e.g. ACME challenges or webhooks
+	PlainHTTPPaths []string
+}
+
+// ServeHTTP implements http.Handler for HTTP->HTTPS redirect
+func (h *RedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.servePlainHTTP(w, r) {
+		return
+	}
 
-	routes := h.router.ListRoutes()
+	host := hostWithoutPort(r.Host)
 
-	data := struct {
-		Hostname      string
-		Routes        []RouteInfo
-		DashboardHost string
-	}{
-		Hostname:      hostname,
-		Routes:        routes,
-		DashboardHost: h.dashboardHost,
+	targetURL := "https://" + net.JoinHostPort(host, strconv.Itoa(h.HTTPSPort))
+	if h.HTTPSPort == 443 {
+		targetURL = "https://" + bracketIPv6(host)
 	}
+	targetURL += r.URL.RequestURI()
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusNotFound)
-	if err := templates.ExecuteTemplate(w, "notfound.html", data); err != nil {
-		slog.Error("failed to render notfound template", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	code := h.StatusCode
+	if code == 0 {
+		code = http.StatusMovedPermanently
 	}
+	http.Redirect(w, r, targetURL, code)
 }
 
-// RedirectHandler redirects HTTP to HTTPS
-type RedirectHandler struct {
-	HTTPSPort int
+// hostWithoutPort strips the port from a host or host:port, unwrapping
+// bracketed IPv6 literals ("[::1]:443" -> "::1")
+func hostWithoutPort(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]")
 }
 
-// ServeHTTP implements http.Handler for HTTP->HTTPS redirect
-func (h *RedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	host := r.Host
-	if idx := strings.LastIndex(host, ":"); idx != -1 {
-		host = host[:idx]
+// bracketIPv6 wraps IPv6 literals in brackets for use in URLs
+func bracketIPv6(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
 	}
+	return host
+}
 
-	targetURL := fmt.Sprintf("https://%s", host)
-	if h.HTTPSPort != 443 {
-		targetURL = fmt.Sprintf("https://%s:%d", host, h.HTTPSPort)
+// servePlainHTTP proxies requests for routes exposed over plain HTTP and
+// for the plain-HTTP paths. It reports whether the request was handled.
+func (h *RedirectHandler) servePlainHTTP(w http.ResponseWriter, r *http.Request) bool {
+	if h.Proxy == nil {
+		return false
+	}
+	// Match the cleaned path, so dot segments can't climb out of a prefix
+	// ("/.well-known/acme-challenge/../../admin")
+	cleaned := cleanPath(r.URL.Path)
+	for _, prefix := range h.PlainHTTPPaths {
+		switch {
+		case strings.HasPrefix(cleaned, prefix):
+			h.Proxy.ServeHTTP(w, r)
+			return true
+		case strings.HasPrefix(r.URL.Path, prefix):
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return true
+		}
+	}
+	if h.Router == nil {
+		return false
+	}
+
+	hostname := strings.ToLower(hostWithoutPort(r.Host))
+	if hostname == "" {
+		hostname = h.Router.LegacyHostname()
+	}
+	route := h.Router.Lookup(hostname, r.URL.Path)
+	if route == nil || !route.Backend.PlainHTTP {
+		return false
 	}
-	targetURL += r.URL.RequestURI()
 
-	http.Redirect(w, r, targetURL, http.StatusMovedPermanently)
+	h.Proxy.ServeHTTP(w, r)
+	return true
+}
+
+// cleanPath resolves dot segments and repeated slashes in a request path,
+// keeping a trailing slash
+func cleanPath(p string) string {
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// stripPathPrefix removes a route's path prefix (roji.path) before proxying
+func stripPathPrefix(path, prefix string) string {
+	if prefix == "" {
+		return path
+	}
+	path = strings.TrimPrefix(path, prefix)
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// forwardedProto returns the scheme the client used to reach roji
+func forwardedProto(r *http.Request) string {
+	if r.TLS == nil {
+		return "http"
+	}
+	return "https"
+}
+
+// redirectCanonical permanently redirects to the same URL on the canonical
+// hostname, keeping the scheme and port the client used
+func redirectCanonical(w http.ResponseWriter, r *http.Request, canonical string) {
+	host := bracketIPv6(canonical)
+	if _, port, err := net.SplitHostPort(r.Host); err == nil {
+		host = net.JoinHostPort(canonical, port)
+	}
+	http.Redirect(w, r, forwardedProto(r)+"://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
 }
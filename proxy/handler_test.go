@@ -1,6 +1,9 @@
 package proxy
 
 import (
+	"context"
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -182,6 +185,20 @@ func TestRedirectHandler(t *testing.T) {
 			path:        "/",
 			expectedURL: "https://api.localhost/",
 		},
+		{
+			name:        "IPv6 literal",
+			httpsPort:   443,
+			host:        "[::1]:80",
+			path:        "/",
+			expectedURL: "https://[::1]/",
+		},
+		{
+			name:        "IPv6 literal with custom port",
+			httpsPort:   8443,
+			host:        "[::1]",
+			path:        "/",
+			expectedURL: "https://[::1]:8443/",
+		},
 	}
 
 	for _, tt := range tests {
@@ -205,3 +222,230 @@ func TestRedirectHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_DeepHealth(t *testing.T) {
+	failing := func(ctx context.Context) HealthCheck {
+		return HealthCheck{Name: CheckDockerEvents, Message: "disconnected"}
+	}
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithHealthChecks(failing))
+
+	// Shallow health ignores deep checks
+	req := httptest.NewRequest("GET", "https://roji.localhost/_api/health", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("shallow status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	req = httptest.NewRequest("GET", "https://roji.localhost/_api/health?deep=true", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("deep status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	var health HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("failed to decode health: %v", err)
+	}
+	if health.Status != "unhealthy" {
+		t.Errorf("status = %q, want unhealthy", health.Status)
+	}
+	// Registered checks come first, followed by the built-in certificate check
+	if len(health.Checks) != 2 || health.Checks[0].Name != CheckDockerEvents || health.Checks[1].Name != CheckCertificates {
+		t.Errorf("checks = %+v", health.Checks)
+	}
+}
+
+func TestHostWithoutPort(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"api.localhost", "api.localhost"},
+		{"api.localhost:443", "api.localhost"},
+		{"[::1]:443", "::1"},
+		{"[fd00::2]", "fd00::2"},
+		{"192.0.2.1:51234", "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		if got := hostWithoutPort(tt.in); got != tt.want {
+			t.Errorf("hostWithoutPort(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// newTestBackend starts an upstream server and returns a backend pointing at it
+func newTestBackend(t *testing.T, hostname string, handler http.HandlerFunc) *docker.Backend {
+	t.Helper()
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	addr := upstream.Listener.Addr().(*net.TCPAddr)
+	return &docker.Backend{
+		ContainerID: "legacy1",
+		ServiceName: "legacy",
+		Host:        addr.IP.String(),
+		Port:        addr.Port,
+		Hostname:    hostname,
+	}
+}
+
+func TestHandler_LegacyRoute(t *testing.T) {
+	backend := newTestBackend(t, "printer.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Forwarded-Proto")))
+	})
+	backend.Legacy = true
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	// HTTP/1.0 request without a Host header
+	req := httptest.NewRequest("GET", "https://printer.localhost/", nil)
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/1.0", 1, 0
+	req.Host = ""
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Connection"); got != "close" {
+		t.Errorf("Connection = %q, want close", got)
+	}
+	if got := w.Body.String(); got != "https" {
+		t.Errorf("X-Forwarded-Proto = %q, want https", got)
+	}
+}
+
+func TestRedirectHandler_StatusCode(t *testing.T) {
+	for _, code := range []int{http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect} {
+		handler := &RedirectHandler{HTTPSPort: 443, StatusCode: code}
+
+		req := httptest.NewRequest("POST", "http://api.localhost/users", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != code {
+			t.Errorf("status = %d, want %d", w.Code, code)
+		}
+		if got := w.Header().Get("Location"); got != "https://api.localhost/users" {
+			t.Errorf("Location = %q, want https://api.localhost/users", got)
+		}
+	}
+}
+
+func TestRedirectHandler_PlainHTTP(t *testing.T) {
+	backend := newTestBackend(t, "printer.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Forwarded-Proto")))
+	})
+	backend.PlainHTTP = true
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	router.AddBackend(&docker.Backend{ContainerID: "web1", Hostname: "web.localhost", Host: "127.0.0.1", Port: 1})
+	redirect := &RedirectHandler{
+		HTTPSPort: 443,
+		Router:    router,
+		Proxy:     NewHandler(router, "roji.localhost", testStatusConfig()),
+	}
+
+	// Plain-HTTP routes are proxied
+	req := httptest.NewRequest("GET", "http://printer.localhost/", nil)
+	w := httptest.NewRecorder()
+	redirect.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Body.String(); got != "http" {
+		t.Errorf("X-Forwarded-Proto = %q, want http", got)
+	}
+
+	// Other routes are still redirected
+	req = httptest.NewRequest("GET", "http://web.localhost/", nil)
+	w = httptest.NewRecorder()
+	redirect.ServeHTTP(w, req)
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusMovedPermanently)
+	}
+}
+
+func TestRedirectHandler_PlainHTTPPaths(t *testing.T) {
+	backend := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	redirect := &RedirectHandler{
+		HTTPSPort:      443,
+		Router:         router,
+		Proxy:          NewHandler(router, "roji.localhost", testStatusConfig()),
+		PlainHTTPPaths: []string{"/.well-known/acme-challenge/", "/hooks/"},
+	}
+
+	// Listed paths are proxied
+	req := httptest.NewRequest("GET", "http://web.localhost/.well-known/acme-challenge/token", nil)
+	w := httptest.NewRecorder()
+	redirect.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Body.String(); got != "/.well-known/acme-challenge/token" {
+		t.Errorf("backend got path %q", got)
+	}
+
+	// Other paths are still redirected
+	req = httptest.NewRequest("POST", "http://web.localhost/api/hooks/", nil)
+	w = httptest.NewRecorder()
+	redirect.ServeHTTP(w, req)
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusMovedPermanently)
+	}
+
+	// Dot segments can't climb out of a listed prefix
+	req = httptest.NewRequest("GET", "http://web.localhost/hooks/../admin", nil)
+	w = httptest.NewRecorder()
+	redirect.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("traversal: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	req = httptest.NewRequest("GET", "http://web.localhost/api/../hooks/github", nil)
+	w = httptest.NewRecorder()
+	redirect.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("cleaned into a prefix: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestHandler_CanonicalHostRedirect(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "web1", Hostname: "shop.localhost", Host: "127.0.0.1", Port: 1})
+	router.AddBackend(&docker.Backend{ContainerID: "web1", Hostname: "web.shop.localhost", Host: "127.0.0.1", Port: 1, RedirectTo: "shop.localhost"})
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"web.shop.localhost", "https://shop.localhost/cart?id=1"},
+		{"web.shop.localhost:8443", "https://shop.localhost:8443/cart?id=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "https://"+tt.host+"/cart?id=1", nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusMovedPermanently {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusMovedPermanently)
+			}
+			if got := w.Header().Get("Location"); got != tt.expected {
+				t.Errorf("Location = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
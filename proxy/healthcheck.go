@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Deep health check names, in the order they are reported
+const (
+	CheckDockerEvents = "docker_events"
+	CheckRoutes       = "routes"
+	CheckCertificates = "certificates"
+	CheckListeners    = "listeners"
+)
+
+// HealthCheck is the result of a single deep health check
+type HealthCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthCheckFunc performs one deep health check
+type HealthCheckFunc func(ctx context.Context) HealthCheck
+
+// HealthResponse is returned by /_api/health (checks only with ?deep=true)
+type HealthResponse struct {
+	Status string        `json:"status"`
+	Routes int           `json:"routes"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// WithHealthChecks registers additional checks for /_api/health?deep=true.
+// The certificate check is built in (skipped with StatusConfig.NoTLS).
+func WithHealthChecks(checks ...HealthCheckFunc) HandlerOption {
+	return func(h *Handler) {
+		h.healthChecks = append(h.healthChecks, checks...)
+	}
+}
+
+// runHealthChecks runs all registered checks plus the certificate check,
+// unless roji serves plain HTTP
+func (h *Handler) runHealthChecks(ctx context.Context) []HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var results []HealthCheck
+	for _, check := range h.healthChecks {
+		results = append(results, check(ctx))
+	}
+	if !h.statusConfig.NoTLS {
+		results = append(results, certificatesCheck(h.statusConfig.CertsDir))
+	}
+	return results
+}
+
+// certificatesCheck verifies that the CA and server certificates exist and are unexpired
+func certificatesCheck(certsDir string) HealthCheck {
+	status := getCertificateStatus(certsDir, false)
+
+	for _, cert := range []struct {
+		name string
+		info *CertInfo
+	}{{"server", status.Server}, {"CA", status.CA}} {
+		if cert.info == nil || !cert.info.Exists {
+			if cert.name == "CA" {
+				// External certificates (e.g., mkcert) have no CA in the certs dir
+				continue
+			}
+			return HealthCheck{Name: CheckCertificates, Message: fmt.Sprintf("%s certificate not found in %s", cert.name, certsDir)}
+		}
+		if cert.info.ValidUntil != nil && time.Now().After(*cert.info.ValidUntil) {
+			return HealthCheck{Name: CheckCertificates, Message: fmt.Sprintf("%s certificate expired on %s", cert.name, cert.info.ValidUntil.Format(time.DateOnly))}
+		}
+	}
+
+	return HealthCheck{Name: CheckCertificates, OK: true}
+}
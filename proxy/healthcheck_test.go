@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCertificatesCheck_MissingServerCert(t *testing.T) {
+	check := certificatesCheck(t.TempDir())
+	if check.OK {
+		t.Error("certificatesCheck() OK = true for an empty certs dir")
+	}
+	if check.Name != CheckCertificates {
+		t.Errorf("Name = %q, want %q", check.Name, CheckCertificates)
+	}
+}
+
+func TestRunHealthChecks_NoTLS(t *testing.T) {
+	h := NewHandler(NewRouter(), "roji.localhost", &StatusConfig{CertsDir: t.TempDir(), NoTLS: true})
+	for _, check := range h.runHealthChecks(context.Background()) {
+		if check.Name == CheckCertificates {
+			t.Errorf("runHealthChecks() ran the certificate check without TLS: %+v", check)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxHistoryEntries is how many route changes are kept in memory (and
+	// loaded from --history-file at startup)
+	maxHistoryEntries = 1000
+	// historyFileLines is how long --history-file grows before it is
+	// rewritten with only the latest maxHistoryEntries changes
+	historyFileLines = 2 * maxHistoryEntries
+	// dashboardHistory is how many route changes the dashboard lists
+	dashboardHistory = 20
+)
+
+// RouteChange is one entry of the route history: a replica of a route
+// appearing, changing or going away
+type RouteChange struct {
+	Time           time.Time `json:"time"`
+	Type           string    `json:"type"` // EventRouteAdded, EventRouteUpdated or EventRouteRemoved
+	Hostname       string    `json:"hostname"`
+	PathPrefix     string    `json:"path_prefix,omitempty"`
+	ContainerID    string    `json:"container_id,omitempty"`
+	ContainerName  string    `json:"container,omitempty"`
+	Target         string    `json:"target,omitempty"`
+	PreviousTarget string    `json:"previous_target,omitempty"` // route_updated only
+}
+
+// Action describes the change for the dashboard: "added", "updated", "removed"
+func (c RouteChange) Action() string {
+	return strings.TrimPrefix(c.Type, "route_")
+}
+
+// ShortContainerID abbreviates the container ID like `docker ps`
+func (c RouteChange) ShortContainerID() string {
+	return c.ContainerID[:min(len(c.ContainerID), 12)]
+}
+
+// RouteHistory records route changes published to the event hub, so
+// "when did api.localhost stop existing?" has an answer. With a path, the
+// changes are also appended to that file as JSON lines and survive restarts;
+// the file is compacted to the latest entries as it grows.
+type RouteHistory struct {
+	path string // optional
+
+	mu      sync.Mutex
+	entries []RouteChange // oldest first
+	lines   int           // lines in the file at path
+}
+
+// NewRouteHistory creates a history, loading the latest entries from path
+// if it is set and exists
+func NewRouteHistory(path string) (*RouteHistory, error) {
+	h := &RouteHistory{path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open route history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var c RouteChange
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid route history entry: %w", path, line, err)
+		}
+		h.appendLocked(c)
+		h.lines = line
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read route history: %w", err)
+	}
+	return h, nil
+}
+
+// Record adds a change to the history and the history file
+func (h *RouteHistory) Record(c RouteChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.appendLocked(c)
+	if h.path == "" {
+		return
+	}
+	data, err := json.Marshal(c)
+	if err == nil {
+		var f *os.File
+		f, err = os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, err = f.Write(append(data, '\n'))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if err != nil {
+		slog.Warn("failed to save route history", "path", h.path, "error", err)
+		return
+	}
+	h.lines++
+	if h.lines > historyFileLines {
+		if err := h.compactLocked(); err != nil {
+			slog.Warn("failed to compact route history", "path", h.path, "error", err)
+		}
+	}
+}
+
+// compactLocked rewrites the history file with the entries kept in memory.
+// The new file replaces the old one by rename, so a crash leaves either.
+func (h *RouteHistory) compactLocked() error {
+	f, err := os.CreateTemp(filepath.Dir(h.path), ".roji-history-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // gone after the rename
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, c := range h.entries {
+		if err = enc.Encode(c); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Chmod(0o644)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), h.path); err != nil {
+		return err
+	}
+	h.lines = len(h.entries)
+	return nil
+}
+
+func (h *RouteHistory) appendLocked(c RouteChange) {
+	h.entries = append(h.entries, c)
+	if len(h.entries) > maxHistoryEntries {
+		h.entries = h.entries[len(h.entries)-maxHistoryEntries:]
+	}
+}
+
+// Entries returns up to limit changes of a hostname ("" = all, limit <= 0 =
+// no limit), newest first
+func (h *RouteHistory) Entries(hostname string, limit int) []RouteChange {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var result []RouteChange
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		if hostname == "" || h.entries[i].Hostname == hostname {
+			result = append(result, h.entries[i])
+		}
+	}
+	return result
+}
+
+// Follow records the route events published to the hub from now on, in
+// the background until ctx is done or the hub closes
+func (h *RouteHistory) Follow(ctx context.Context, hub *EventHub) {
+	ch := hub.subscribe(0)
+	go func() {
+		var lastID uint64
+		for {
+			select {
+			case <-ctx.Done():
+				hub.unsubscribe(ch)
+				return
+			case <-hub.closed:
+				hub.unsubscribe(ch)
+				return
+			case e, ok := <-ch:
+				if !ok {
+					// Dropped for falling behind; catch up from the backlog
+					ch = hub.subscribe(lastID)
+					continue
+				}
+				lastID = e.ID
+				if c, ok := routeChange(e); ok {
+					h.Record(c)
+				}
+			}
+		}
+	}()
+}
+
+// routeChange converts a route_* event to a history entry
+func routeChange(e Event) (RouteChange, bool) {
+	switch e.Type {
+	case EventRouteAdded, EventRouteUpdated, EventRouteRemoved:
+	default:
+		return RouteChange{}, false
+	}
+	c := RouteChange{
+		Time:       e.Time,
+		Type:       e.Type,
+		Hostname:   e.Hostname,
+		PathPrefix: e.PathPrefix,
+	}
+	if e.Route != nil {
+		c.ContainerID = e.Route.ContainerID
+		c.ContainerName = e.Route.ContainerName
+		c.Target = e.Route.Target
+	}
+	if e.Previous != nil && e.Previous.Target != c.Target {
+		c.PreviousTarget = e.Previous.Target
+	}
+	return c, true
+}
+
+// WithRouteHistory serves /_api/history and lists recent route changes on
+// the dashboard
+func WithRouteHistory(history *RouteHistory) HandlerOption {
+	return func(h *Handler) {
+		h.history = history
+	}
+}
+
+// serveHistoryAPI lists route changes (GET), newest first, optionally
+// filtered with ?hostname= and capped with ?limit=
+func (h *Handler) serveHistoryAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.history == nil {
+		http.Error(w, "route history is disabled", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	changes := h.history.Entries(strings.ToLower(query.Get("hostname")), limit)
+	if changes == nil {
+		changes = []RouteChange{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(changes); err != nil {
+		slog.Error("failed to encode history response", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
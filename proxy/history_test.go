@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+func TestRouteHistory_Follow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	router := NewRouter()
+	hub := NewEventHub()
+	history, _ := NewRouteHistory("")
+	history.Follow(ctx, hub)
+	hub.WatchRoutes(ctx, router)
+
+	// Route changes are diffed in the background; wait for each one
+	waitFor := func(n int) []RouteChange {
+		t.Helper()
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if changes := history.Entries("", 0); len(changes) >= n {
+				return changes
+			}
+		}
+		t.Fatalf("history = %+v, want %d changes", history.Entries("", 0), n)
+		return nil
+	}
+
+	router.AddBackend(&docker.Backend{ContainerID: "0123456789abcdef", ContainerName: "api", Hostname: "api.localhost", Host: "172.17.0.2", Port: 80})
+	waitFor(1)
+	router.ReplaceContainer("0123456789abcdef", []*docker.Backend{{ContainerID: "0123456789abcdef", ContainerName: "api", Hostname: "api.localhost", Host: "172.17.0.3", Port: 80}})
+	waitFor(2)
+	hub.Publish(Event{Type: EventCertRenewed}) // not a route change
+	router.RemoveBackend("0123456789abcdef")
+	changes := waitFor(3)
+
+	// Newest first
+	if c := changes[0]; c.Type != EventRouteRemoved || c.Hostname != "api.localhost" || c.ContainerID != "0123456789abcdef" {
+		t.Errorf("changes[0] = %+v, want route_removed of api.localhost by the container", c)
+	}
+	if c := changes[1]; c.Type != EventRouteUpdated || c.PreviousTarget != "172.17.0.2:80" || c.Target != "172.17.0.3:80" {
+		t.Errorf("changes[1] = %+v, want route_updated from 172.17.0.2:80 to 172.17.0.3:80", c)
+	}
+	if c := changes[2]; c.Action() != "added" || c.ShortContainerID() != "0123456789ab" {
+		t.Errorf("changes[2] = %+v, want added by 0123456789ab", c)
+	}
+}
+
+func TestRouteHistory_Persisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history, err := NewRouteHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history.Record(RouteChange{Type: EventRouteAdded, Hostname: "api.localhost", Time: time.Now()})
+	history.Record(RouteChange{Type: EventRouteAdded, Hostname: "web.localhost", Time: time.Now()})
+	history.Record(RouteChange{Type: EventRouteRemoved, Hostname: "api.localhost", Time: time.Now()})
+
+	// A restart loads what was recorded
+	history, err = NewRouteHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := history.Entries("api.localhost", 0)
+	if len(changes) != 2 || changes[0].Type != EventRouteRemoved || changes[1].Type != EventRouteAdded {
+		t.Errorf("api.localhost history = %+v, want removed then added", changes)
+	}
+	if changes := history.Entries("", 1); len(changes) != 1 || changes[0].Hostname != "api.localhost" {
+		t.Errorf("latest change = %+v, want api.localhost", changes)
+	}
+
+	os.WriteFile(path, []byte("not json\n"), 0o644)
+	if _, err := NewRouteHistory(path); err == nil {
+		t.Error("NewRouteHistory() of a corrupt file succeeded")
+	}
+}
+
+func TestRouteHistory_Compacted(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.jsonl")
+	history, err := NewRouteHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range historyFileLines + 1 {
+		history.Record(RouteChange{Type: EventRouteAdded, Hostname: fmt.Sprintf("app%d.localhost", i), Time: time.Now()})
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != maxHistoryEntries {
+		t.Errorf("history file has %d lines after compaction, want %d", lines, maxHistoryEntries)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("compaction left %d files, want 1", len(entries))
+	}
+
+	history, err = NewRouteHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("app%d.localhost", historyFileLines)
+	if changes := history.Entries("", 1); len(changes) != 1 || changes[0].Hostname != want {
+		t.Errorf("latest change = %+v, want %s", changes, want)
+	}
+}
+
+func TestHandler_HistoryAPI(t *testing.T) {
+	history, _ := NewRouteHistory("")
+	history.Record(RouteChange{Type: EventRouteAdded, Hostname: "api.localhost", Time: time.Now()})
+	history.Record(RouteChange{Type: EventRouteAdded, Hostname: "web.localhost", Time: time.Now()})
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithRouteHistory(history))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/v1/history?hostname=API.localhost", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var changes []RouteChange
+	if err := json.NewDecoder(w.Body).Decode(&changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Hostname != "api.localhost" {
+		t.Errorf("changes = %+v, want api.localhost only", changes)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/history?hostname=gone.localhost", nil))
+	if body := w.Body.String(); body != "[]\n" {
+		t.Errorf("body without changes = %q, want []", body)
+	}
+
+	// Listed on the dashboard
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/", nil))
+	if body := w.Body.String(); !strings.Contains(body, "Route history") || !strings.Contains(body, "web.localhost") {
+		t.Error("dashboard doesn't list the route history")
+	}
+}
+
+func TestHandler_HistoryAPIDisabled(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/history", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
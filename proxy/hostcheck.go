@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WithAllowedHosts lets requests through for hostnames matching patterns
+// besides roji's own (--allowed-hosts): exact names, "*.example.test" for
+// subdomains, or "*" to turn the Host check off
+func WithAllowedHosts(patterns []string) HandlerOption {
+	return func(h *Handler) {
+		h.allowedHosts = append(h.allowedHosts, patterns...)
+	}
+}
+
+// hostAllowed reports whether roji serves a hostname: the dashboard, a
+// route, the base domain, *.localhost, an IP, or an allowed pattern. Any
+// other Host comes from a name roji doesn't own, e.g. a DNS rebinding
+// attack letting a web page reach local services through the proxy.
+func (h *Handler) hostAllowed(hostname string) bool {
+	base := h.statusConfig.BaseDomain
+	switch {
+	case hostname == "", hostname == h.dashboardHost, isLocalhost(hostname), net.ParseIP(hostname) != nil:
+		return true
+	case base != "" && (hostname == base || strings.HasSuffix(hostname, "."+base)):
+		return true
+	case h.router.HasHostname(hostname):
+		return true
+	}
+	for _, pattern := range h.allowedHosts {
+		if matchHostPattern(pattern, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHostPattern matches exact hostnames, "*.suffix" subdomains and "*".
+// A "*" anywhere else matches nothing, so "*example.test" can't admit
+// evilexample.test.
+func matchHostPattern(pattern, hostname string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(hostname, "."+suffix)
+	}
+	return pattern == hostname && !strings.Contains(pattern, "*")
+}
+
+// guardHost answers requests for hostnames roji doesn't serve with 421
+// Misdirected Request, before any route, mock or roji page sees them. It
+// reports whether the request was handled.
+func (h *Handler) guardHost(w http.ResponseWriter, r *http.Request, hostname string) bool {
+	if h.hostAllowed(hostname) {
+		return false
+	}
+	slog.Warn("rejected request for unknown host", "host", hostname, "client", r.RemoteAddr, "path", r.URL.Path)
+	http.Error(w, "roji doesn't serve "+hostname+" (allow it with --allowed-hosts)", http.StatusMisdirectedRequest)
+	return true
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kan/roji/docker"
+)
+
+func TestHandler_HostCheck(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "web1", Hostname: "shop.example.test", Host: "127.0.0.1", Port: 1})
+	router.AddBackend(&docker.Backend{ContainerID: "api1", Hostname: "api.example.test", PathPrefix: "/v1", Host: "127.0.0.1", Port: 1})
+	cfg := testStatusConfig()
+	cfg.BaseDomain = "dev.localhost"
+
+	tests := []struct {
+		host    string
+		allowed []string
+		want    bool
+	}{
+		{"roji.example.test", nil, true}, // dashboard
+		{"shop.example.test", nil, true}, // route
+		{"api.example.test", nil, true},  // path route
+		{"new.dev.localhost", nil, true}, // base domain, route not up yet
+		{"app.localhost", nil, true},
+		{"127.0.0.1", nil, true},
+		{"[::1]:8443", nil, true},
+		{"attacker.example.com", nil, false},
+		{"rebind.attacker.example.com:443", nil, false},
+		{"dev.localhost.attacker.example.com", nil, false},
+		{"app.corp.internal", []string{"*.corp.internal"}, true},
+		{"corp.internal", []string{"*.corp.internal"}, false},
+		{"evilcorp.internal", []string{"*.corp.internal"}, false},
+		{"evilexample.test", []string{"*example.test"}, false},
+		{"app.example.test", []string{"*example.test"}, false},
+		{"tools.lan", []string{"tools.lan"}, true},
+		{"anything.example.com", []string{"*"}, true},
+	}
+	for _, tt := range tests {
+		handler := NewHandler(router, "roji.example.test", cfg, WithAllowedHosts(tt.allowed))
+		req := httptest.NewRequest("GET", "https://placeholder/", nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if rejected := w.Code == http.StatusMisdirectedRequest; rejected == tt.want {
+			t.Errorf("Host %q (allowed %v): status = %d, want allowed = %v", tt.host, tt.allowed, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/kan/roji/config"
+)
+
+// HostnameRemapper manages sticky hostname assignments for the management API
+type HostnameRemapper interface {
+	// PinnedHostnames returns the current assignments keyed by service
+	// ("project/service" for compose services, container name otherwise)
+	PinnedHostnames() map[string]string
+	// RemapHostname pins a service to a new hostname and updates its routes
+	RemapHostname(ctx context.Context, service, hostname string) error
+}
+
+// WithHostnameRemapper enables the /_api/hostnames endpoint for sticky hostnames
+func WithHostnameRemapper(m HostnameRemapper) HandlerOption {
+	return func(h *Handler) {
+		h.remapper = m
+	}
+}
+
+// hostnameRemapRequest is the body of PUT /_api/hostnames
+type hostnameRemapRequest struct {
+	Service  string `json:"service"`
+	Hostname string `json:"hostname"`
+}
+
+// serveHostnamesAPI lists (GET) or remaps (PUT/POST) sticky hostnames. New
+// hostnames must be under the base domain (see config.ValidateHostname).
+func (h *Handler) serveHostnamesAPI(w http.ResponseWriter, r *http.Request) {
+	if h.remapper == nil {
+		http.Error(w, "hostname pinning is disabled (start roji with --hostname-stability=sticky)", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.remapper.PinnedHostnames()); err != nil {
+			slog.Error("failed to encode hostnames response", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+
+	case http.MethodPut, http.MethodPost:
+		// Browsers can be made to post here from any page
+		if h.crossOrigin(r) {
+			http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+			return
+		}
+		var req hostnameRemapRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		req.Service = strings.TrimSpace(req.Service)
+		req.Hostname = strings.ToLower(strings.TrimSpace(req.Hostname))
+		if req.Service == "" || req.Hostname == "" {
+			http.Error(w, "service and hostname are required", http.StatusBadRequest)
+			return
+		}
+		if err := config.ValidateHostname(req.Hostname, h.statusConfig.BaseDomain); err != nil {
+			http.Error(w, "invalid hostname: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.remapper.RemapHostname(r.Context(), req.Service, req.Hostname); err != nil {
+			slog.Error("failed to remap hostname", "service", req.Service, "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info("hostname remapped", "service", req.Service, "hostname", req.Hostname)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeRemapper struct {
+	pins map[string]string
+}
+
+func (f *fakeRemapper) PinnedHostnames() map[string]string { return f.pins }
+
+func (f *fakeRemapper) RemapHostname(ctx context.Context, service, hostname string) error {
+	f.pins[service] = hostname
+	return nil
+}
+
+func TestHandler_HostnamesAPI(t *testing.T) {
+	remapper := &fakeRemapper{pins: map[string]string{"shop/web": "shop.localhost"}}
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithHostnameRemapper(remapper))
+
+	req := httptest.NewRequest("GET", "https://roji.localhost/_api/hostnames", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "shop.localhost") {
+		t.Errorf("GET: status = %d, body = %q", w.Code, w.Body.String())
+	}
+
+	body := strings.NewReader(`{"service":"shop/web","hostname":"Web.Shop.localhost"}`)
+	req = httptest.NewRequest("PUT", "https://roji.localhost/_api/hostnames", body)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PUT: status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if remapper.pins["shop/web"] != "web.shop.localhost" {
+		t.Errorf("remapped hostname = %q, want lowercase %q", remapper.pins["shop/web"], "web.shop.localhost")
+	}
+}
+
+func TestHandler_HostnamesAPI_Rejects(t *testing.T) {
+	remapper := &fakeRemapper{pins: map[string]string{"shop/web": "shop.localhost"}}
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithHostnameRemapper(remapper))
+
+	for _, hostname := range []string{"bank.example.com", "*.shop.localhost", "web..localhost", "localhost"} {
+		body := strings.NewReader(`{"service":"shop/web","hostname":"` + hostname + `"}`)
+		req := httptest.NewRequest("PUT", "https://roji.localhost/_api/hostnames", body)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", hostname, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	body := strings.NewReader(`{"service":"shop/web","hostname":"web.shop.localhost"}`)
+	req := httptest.NewRequest("POST", "https://roji.localhost/_api/hostnames", body)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("cross-origin post: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if remapper.pins["shop/web"] != "shop.localhost" {
+		t.Errorf("pin changed to %q", remapper.pins["shop/web"])
+	}
+}
+
+func TestHandler_HostnamesAPI_Disabled(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig())
+
+	req := httptest.NewRequest("GET", "https://roji.localhost/_api/hostnames", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d when sticky mode is off", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,225 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// The lines around the block roji keeps in the hosts file (--sync-hosts)
+const (
+	hostsBlockBegin = "# BEGIN roji (managed; removed when roji stops)"
+	hostsBlockEnd   = "# END roji"
+)
+
+// HostsFileSync keeps a block in the hosts file mapping the route hostnames
+// to 127.0.0.1 (--sync-hosts), for tools and systems that don't resolve
+// *.localhost or a custom domain. The rest of the file is left alone.
+type HostsFileSync struct {
+	path   string
+	file   *os.File // opened before roji drops root (--user)
+	router *Router
+	extra  []string // hostnames listed besides the routes (the dashboard)
+
+	mu      sync.Mutex
+	written []string // hostnames of the block in the file
+}
+
+// NewHostsFileSync creates a sync of router's hostnames, plus extra, into
+// file, which must be open for reading and writing
+func NewHostsFileSync(file *os.File, router *Router, extra ...string) *HostsFileSync {
+	return &HostsFileSync{path: file.Name(), file: file, router: router, extra: extra}
+}
+
+// Watch writes the block now and again whenever the routes change, until
+// ctx is done. It doesn't remove the block; see Remove.
+func (s *HostsFileSync) Watch(ctx context.Context) {
+	changed := make(chan struct{}, 1)
+	s.router.OnChange(func() {
+		select {
+		case changed <- struct{}{}:
+		default: // a sync is already pending
+		}
+	})
+	s.sync()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+				s.sync()
+			}
+		}
+	}()
+}
+
+// Remove takes the block out of the hosts file, on shutdown
+func (s *HostsFileSync) Remove() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(nil); err != nil {
+		slog.Warn("failed to clean up the hosts file", "path", s.path, "error", err)
+		return
+	}
+	s.written = nil
+}
+
+// hostnames lists the names to map: every route hostname and the extra ones
+func (s *HostsFileSync) hostnames() []string {
+	names := slices.Clone(s.extra)
+	for _, r := range s.router.ListRoutes() {
+		names = append(names, r.Hostname)
+	}
+	// Wildcards and addresses can't go in a hosts file
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return name == "" || strings.Contains(name, "*") || net.ParseIP(name) != nil
+	})
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// sync rewrites the block if the hostnames changed
+func (s *HostsFileSync) sync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := s.hostnames()
+	if s.written != nil && slices.Equal(names, s.written) {
+		return
+	}
+	if err := s.write(names); err != nil {
+		slog.Warn("failed to update the hosts file", "path", s.path, "error", err)
+		return
+	}
+	s.written = names
+	slog.Debug("updated the hosts file", "path", s.path, "hostnames", len(names))
+}
+
+// write replaces the block in the file with one for names; nil removes it.
+// The new content is written to a temporary file renamed over the old one,
+// so a failed write can't leave the file truncated. Bind-mounted files (a
+// container managing the host's file) can't be replaced, and once roji drops
+// root it can't create files next to the hosts file; those are rewritten in
+// place.
+func (s *HostsFileSync) write(names []string) error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(s.file)
+	if err != nil {
+		return err
+	}
+	updated := withHostsBlock(string(data), names)
+	if updated == string(data) {
+		return nil
+	}
+	err = s.replace([]byte(updated))
+	if err == nil {
+		return nil
+	}
+	slog.Debug("can't replace the hosts file; rewriting it in place", "path", s.path, "error", err)
+
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.file.WriteAt([]byte(updated), 0); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// replace renames a temporary file with data over the hosts file and keeps
+// it open for the next write
+func (s *HostsFileSync) replace(data []byte) error {
+	info, err := os.Lstat(s.path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return errors.New("the hosts file is a symlink") // renaming would replace the link
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".roji-hosts-*")
+	if err != nil {
+		return err
+	}
+	renamed := false
+	defer func() {
+		if !renamed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err // EBUSY on a bind mount
+	}
+	renamed = true
+	s.file.Close()
+	s.file = tmp
+	return nil
+}
+
+// withHostsBlock returns the hosts file content with roji's block replaced by
+// one mapping names to 127.0.0.1, at the end; no names drop it. The file's
+// line endings (CRLF on Windows) are kept.
+func withHostsBlock(content string, names []string) string {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+
+	// A block is only dropped once its END is found: the lines after a BEGIN
+	// without one (an edit gone wrong) are kept
+	var lines, block []string
+	inBlock := false
+	for _, line := range strings.Split(strings.TrimSuffix(content, newline), newline) {
+		switch {
+		case strings.TrimSpace(line) == hostsBlockBegin:
+			lines = append(lines, block...) // an earlier BEGIN had no END
+			block = []string{line}
+			inBlock = true
+		case inBlock && strings.TrimSpace(line) == hostsBlockEnd:
+			block = nil
+			inBlock = false
+		case inBlock:
+			block = append(block, line)
+		default:
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, block...)
+	if len(lines) == 1 && lines[0] == "" {
+		lines = nil
+	}
+
+	if len(names) > 0 {
+		lines = append(lines, hostsBlockBegin)
+		for _, name := range names {
+			lines = append(lines, "127.0.0.1 "+name)
+		}
+		lines = append(lines, hostsBlockEnd)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, newline) + newline
+}
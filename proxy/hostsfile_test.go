@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithHostsBlock(t *testing.T) {
+	block := hostsBlockBegin + "\n127.0.0.1 a.test\n127.0.0.1 b.test\n" + hostsBlockEnd + "\n"
+
+	tests := []struct {
+		name    string
+		content string
+		names   []string
+		want    string
+	}{
+		{"added at the end", "127.0.0.1 localhost\n", []string{"a.test", "b.test"},
+			"127.0.0.1 localhost\n" + block},
+		{"missing final newline", "127.0.0.1 localhost", []string{"a.test", "b.test"},
+			"127.0.0.1 localhost\n" + block},
+		{"replaced in place", "127.0.0.1 localhost\n" + hostsBlockBegin + "\n127.0.0.1 old.test\n" + hostsBlockEnd + "\n::1 localhost\n",
+			[]string{"a.test", "b.test"}, "127.0.0.1 localhost\n::1 localhost\n" + block},
+		{"removed", "127.0.0.1 localhost\n" + block, nil, "127.0.0.1 localhost\n"},
+		{"empty file", "", []string{"a.test", "b.test"}, block},
+		{"BEGIN without END kept", "127.0.0.1 localhost\n" + hostsBlockBegin + "\n10.0.0.1 nas\n",
+			[]string{"a.test", "b.test"}, "127.0.0.1 localhost\n" + hostsBlockBegin + "\n10.0.0.1 nas\n" + block},
+		{"BEGIN without END before the block", "127.0.0.1 localhost\n" + hostsBlockBegin + "\n10.0.0.1 nas\n" + block,
+			nil, "127.0.0.1 localhost\n" + hostsBlockBegin + "\n10.0.0.1 nas\n"},
+		{"CRLF kept", "127.0.0.1 localhost\r\n", []string{"a.test"},
+			"127.0.0.1 localhost\r\n" + hostsBlockBegin + "\r\n127.0.0.1 a.test\r\n" + hostsBlockEnd + "\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withHostsBlock(tt.content, tt.names); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostsFileSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("127.0.0.1 localhost\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "web.app.dev.localhost", nil))
+	s := NewHostsFileSync(file, router, "dev.localhost")
+	s.Watch(t.Context())
+
+	read := func() string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if got := read(); !strings.Contains(got, "127.0.0.1 dev.localhost\n127.0.0.1 web.app.dev.localhost\n") {
+		t.Fatalf("hosts file lacks the routes:\n%s", got)
+	}
+
+	api := newTestBackend(t, "api.app.dev.localhost", nil)
+	api.ContainerID = "api1"
+	router.AddBackend(api)
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(read(), "127.0.0.1 api.app.dev.localhost\n") {
+		if time.Now().After(deadline) {
+			t.Fatalf("new route not synced:\n%s", read())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Remove()
+	if got := read(); got != "127.0.0.1 localhost\n" {
+		t.Errorf("after Remove:\n%s", got)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o644 {
+		t.Errorf("hosts file mode = %v, want 0644", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
@@ -0,0 +1,296 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PairPath accepts pairing codes from unknown devices on every hostname
+const PairPath = "/_roji/pair"
+
+const (
+	// pairingCodeTTL is how long a pairing code stays valid
+	pairingCodeTTL = 5 * time.Minute
+	// maxPairingFailures invalidates the current code after this many wrong guesses
+	maxPairingFailures = 5
+)
+
+// PairedDevice is a client allowed to use roji in LAN mode
+type PairedDevice struct {
+	IP       string    `json:"ip"`
+	Name     string    `json:"name,omitempty"`
+	PairedAt time.Time `json:"paired_at"`
+}
+
+// DeviceAllowList restricts LAN mode to known clients. Loopback and trusted
+// prefixes are always allowed; other devices must pair with a one-time code
+// shown on the dashboard.
+type DeviceAllowList struct {
+	path string // persisted paired devices ("" = memory only)
+
+	mu          sync.Mutex
+	trusted     []netip.Prefix
+	devices     map[string]PairedDevice // key: IP
+	code        string
+	codeExpires time.Time
+	failures    int
+}
+
+// NewDeviceAllowList creates an allow list that trusts the given prefixes and
+// persists paired devices to path (if non-empty)
+func NewDeviceAllowList(trusted []netip.Prefix, path string) (*DeviceAllowList, error) {
+	l := &DeviceAllowList{
+		trusted: trusted,
+		path:    path,
+		devices: make(map[string]PairedDevice),
+	}
+	if path == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paired devices: %w", err)
+	}
+	var devices []PairedDevice
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("failed to parse paired devices: %w", err)
+	}
+	for _, d := range devices {
+		l.devices[d.IP] = d
+	}
+	return l, nil
+}
+
+// Trust adds prefixes that are always allowed (e.g., docker bridge
+// gateways found once the daemon is reachable)
+func (l *DeviceAllowList) Trust(prefixes ...netip.Prefix) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.trusted = append(l.trusted, prefixes...)
+}
+
+// Allowed reports whether the client at addr may use roji
+func (l *DeviceAllowList) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, prefix := range l.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	_, ok := l.devices[addr.String()]
+	return ok
+}
+
+// PairingCode returns the current one-time code, generating a new one when
+// none is active
+func (l *DeviceAllowList) PairingCode() (string, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.code == "" || time.Now().After(l.codeExpires) {
+		l.resetCodeLocked()
+	}
+	return l.code, l.codeExpires
+}
+
+// Pair adds the device at addr if code matches the active pairing code.
+// Codes are single-use and are invalidated after repeated wrong guesses.
+func (l *DeviceAllowList) Pair(addr netip.Addr, code, name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.code == "" || time.Now().After(l.codeExpires) {
+		return false
+	}
+	if strings.TrimSpace(code) != l.code {
+		l.failures++
+		if l.failures >= maxPairingFailures {
+			slog.Warn("too many wrong pairing codes, code invalidated", "client", addr)
+			l.code = ""
+		}
+		return false
+	}
+
+	ip := addr.Unmap().String()
+	l.devices[ip] = PairedDevice{IP: ip, Name: strings.TrimSpace(name), PairedAt: time.Now()}
+	l.code = ""
+	l.saveLocked()
+	slog.Info("device paired", "client", ip, "name", name)
+	return true
+}
+
+// Unpair removes a paired device
+func (l *DeviceAllowList) Unpair(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.devices[ip]; !ok {
+		return false
+	}
+	delete(l.devices, ip)
+	l.saveLocked()
+	return true
+}
+
+// Devices returns the paired devices, ordered by IP
+func (l *DeviceAllowList) Devices() []PairedDevice {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	devices := make([]PairedDevice, 0, len(l.devices))
+	for _, d := range l.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].IP < devices[j].IP })
+	return devices
+}
+
+// resetCodeLocked generates a new 6-digit pairing code
+func (l *DeviceAllowList) resetCodeLocked() {
+	n, _ := rand.Int(rand.Reader, big.NewInt(1_000_000)) // crypto/rand doesn't fail since Go 1.24
+	l.code = fmt.Sprintf("%06d", n.Int64())
+	l.codeExpires = time.Now().Add(pairingCodeTTL)
+	l.failures = 0
+}
+
+// saveLocked persists the paired devices; failures are logged, pairing
+// still lasts until restart
+func (l *DeviceAllowList) saveLocked() {
+	if l.path == "" {
+		return
+	}
+	devices := make([]PairedDevice, 0, len(l.devices))
+	for _, d := range l.devices {
+		devices = append(devices, d)
+	}
+	data, err := json.MarshalIndent(devices, "", "  ")
+	if err == nil {
+		err = os.WriteFile(l.path, data, 0o600)
+	}
+	if err != nil {
+		slog.Warn("failed to save paired devices", "path", l.path, "error", err)
+	}
+}
+
+// WithLANAccess denies clients that are not on the allow list (LAN mode)
+func WithLANAccess(l *DeviceAllowList) HandlerOption {
+	return func(h *Handler) {
+		h.devices = l
+	}
+}
+
+// WithLANHostnames serves routes to other devices under <route>.<ip>.nip.io
+// for the machine's LAN address, and the dashboard on the address itself and
+// on its mDNS name (e.g., "laptop.local"; "" = none)
+func WithLANHostnames(ip, mdnsName string) HandlerOption {
+	return func(h *Handler) {
+		h.lanIP = ip
+		h.lanName = strings.ToLower(mdnsName)
+	}
+}
+
+// LANCertNames returns the certificate names needed for LAN access
+func LANCertNames(ip, mdnsName string) []string {
+	domain := EmulatorDomain(ip)
+	names := []string{domain, "*." + domain, "*.*." + domain, ip}
+	if mdnsName != "" {
+		names = append(names, mdnsName)
+	}
+	return names
+}
+
+// clientAddr returns the address of the connecting client
+func clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// guardLAN serves the pairing flow to unknown clients and reports whether
+// the request was handled
+func (h *Handler) guardLAN(w http.ResponseWriter, r *http.Request) bool {
+	if h.devices == nil {
+		return false
+	}
+	addr, ok := clientAddr(r)
+	if ok && h.devices.Allowed(addr) {
+		return false
+	}
+	if h.sharedRequest(r) {
+		return false
+	}
+
+	data := struct {
+		Client string
+		Failed bool
+	}{}
+	if ok {
+		data.Client = addr.String()
+	}
+
+	if ok && r.URL.Path == PairPath && r.Method == http.MethodPost {
+		if h.devices.Pair(addr, r.FormValue("code"), r.FormValue("name")) {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return true
+		}
+		data.Failed = true
+	}
+
+	slog.Debug("denied unpaired client", "client", r.RemoteAddr, "host", r.Host)
+	w.Header().Set("Cache-Control", "no-store")
+	renderPage(w, http.StatusForbidden, "pair.html", data)
+	return true
+}
+
+// serveDevicesAPI lists paired devices (GET) or unpairs one (DELETE ?ip=)
+func (h *Handler) serveDevicesAPI(w http.ResponseWriter, r *http.Request) {
+	if h.devices == nil {
+		http.Error(w, "LAN mode is disabled", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.devices.Devices()); err != nil {
+			slog.Error("failed to encode devices response", "error", err)
+		}
+	case http.MethodDelete:
+		if !h.devices.Unpair(r.URL.Query().Get("ip")) {
+			http.Error(w, "device not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
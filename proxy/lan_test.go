@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDeviceAllowList_Allowed(t *testing.T) {
+	l, err := NewDeviceAllowList([]netip.Prefix{netip.MustParsePrefix("172.17.0.1/32")}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"172.17.0.1", true},
+		{"192.168.1.20", false},
+	}
+	for _, tt := range tests {
+		if got := l.Allowed(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Allowed(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestDeviceAllowList_Pair(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paired-devices.json")
+	l, err := NewDeviceAllowList(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone := netip.MustParseAddr("192.168.1.20")
+
+	// No code has been shown yet
+	if l.Pair(phone, "000000", "") {
+		t.Fatal("Pair() should fail without an active code")
+	}
+
+	code, _ := l.PairingCode()
+	if len(code) != 6 {
+		t.Fatalf("PairingCode() = %q, want 6 digits", code)
+	}
+	if l.Pair(phone, "wrong", "") {
+		t.Fatal("Pair() should reject a wrong code")
+	}
+	if !l.Pair(phone, code, "Pixel") {
+		t.Fatal("Pair() should accept the active code")
+	}
+	if !l.Allowed(phone) {
+		t.Error("paired device should be allowed")
+	}
+
+	// Codes are single-use
+	if l.Pair(netip.MustParseAddr("192.168.1.21"), code, "") {
+		t.Error("Pair() should not accept a used code")
+	}
+
+	// Paired devices survive a restart
+	reloaded, err := NewDeviceAllowList(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := reloaded.Devices()
+	if len(devices) != 1 || devices[0].IP != "192.168.1.20" || devices[0].Name != "Pixel" {
+		t.Errorf("Devices() after reload = %+v", devices)
+	}
+
+	if !reloaded.Unpair("192.168.1.20") || reloaded.Allowed(phone) {
+		t.Error("Unpair() should remove the device")
+	}
+}
+
+func TestDeviceAllowList_TooManyFailures(t *testing.T) {
+	l, _ := NewDeviceAllowList(nil, "")
+	phone := netip.MustParseAddr("192.168.1.20")
+
+	code, _ := l.PairingCode()
+	for i := 0; i < maxPairingFailures; i++ {
+		l.Pair(phone, "x", "")
+	}
+	if l.Pair(phone, code, "") {
+		t.Error("code should be invalidated after too many failures")
+	}
+	if next, _ := l.PairingCode(); next == "" {
+		t.Error("PairingCode() should issue a new code")
+	}
+}
+
+func TestHandler_LANMode(t *testing.T) {
+	backend := newTestBackend(t, "app.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("app"))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+
+	devices, _ := NewDeviceAllowList(nil, "")
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithLANAccess(devices))
+
+	request := func(method, target, remoteAddr string, form url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if form != nil {
+			req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, target, nil)
+		}
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Unknown devices get the pairing page instead of the app
+	rec := request("GET", "https://app.localhost/", "192.168.1.20:50000", nil)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "Pair this device") {
+		t.Fatalf("unknown device: status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+
+	// The dashboard, seen from the host, shows the pairing code
+	rec = request("GET", "https://roji.localhost/", "127.0.0.1:50000", nil)
+	code, _ := devices.PairingCode()
+	if !strings.Contains(rec.Body.String(), code) {
+		t.Fatalf("dashboard should show pairing code %s", code)
+	}
+
+	rec = request("POST", "https://app.localhost"+PairPath, "192.168.1.20:50000", url.Values{"code": {code}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("pair: status = %d, want 303", rec.Code)
+	}
+
+	rec = request("GET", "https://app.localhost/", "192.168.1.20:50001", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "app" {
+		t.Errorf("paired device: status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandler_LANHostnames(t *testing.T) {
+	backend := newTestBackend(t, "web.app.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("web"))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithLANHostnames("192.168.1.20", "Laptop.local"))
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		wantBody string
+	}{
+		{"route", "https://web.app.192.168.1.20.nip.io/", http.StatusOK, "web"},
+		{"dashboard by IP", "https://192.168.1.20/_api/health", http.StatusOK, ""},
+		{"dashboard by mDNS name", "https://laptop.local/_api/health", http.StatusOK, ""},
+		{"other address", "https://web.app.192.168.1.99.nip.io/", http.StatusMisdirectedRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLANCertNames(t *testing.T) {
+	want := []string{"192.168.1.20.nip.io", "*.192.168.1.20.nip.io", "*.*.192.168.1.20.nip.io", "192.168.1.20", "laptop.local"}
+	if got := LANCertNames("192.168.1.20", "laptop.local"); !slices.Equal(got, want) {
+		t.Errorf("LANCertNames() = %v, want %v", got, want)
+	}
+}
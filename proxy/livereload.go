@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+const (
+	// LiveReloadPath streams reload events for the hostname (server-sent
+	// events). Like UnbrickPath it is served on every hostname, so pages
+	// stay subscribed while their container is down.
+	LiveReloadPath = "/_roji/live-reload"
+	// liveReloadScriptPath serves the script injected into HTML pages;
+	// same-origin, so CSPs allowing 'self' scripts accept it
+	liveReloadScriptPath = "/_roji/live-reload.js"
+
+	// liveReloadReadyTimeout bounds the wait for a restarted container to
+	// accept connections before pages reload anyway
+	liveReloadReadyTimeout = 30 * time.Second
+	// liveReloadKeepAlive keeps idle streams open through other proxies
+	liveReloadKeepAlive = 30 * time.Second
+)
+
+// liveReloadScript reloads the page on a "reload" event. EventSource
+// reconnects by itself while roji restarts.
+const liveReloadScript = `(function () {
+  if (!window.EventSource) return;
+  new EventSource("` + LiveReloadPath + `").addEventListener("reload", function () {
+    location.reload();
+  });
+})();
+`
+
+// LiveReload tells pages of roji.live-reload routes to reload when their
+// container restarts (e.g., after docker compose up --build)
+type LiveReload struct {
+	dialTimeout time.Duration
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{} // hostname -> streams
+
+	closeOnce sync.Once
+	closed    chan struct{} // ends all streams on shutdown
+}
+
+// NewLiveReload creates an empty live reload hub
+func NewLiveReload() *LiveReload {
+	return &LiveReload{
+		dialTimeout: time.Second,
+		subscribers: make(map[string]map[chan struct{}]struct{}),
+		closed:      make(chan struct{}),
+	}
+}
+
+// close ends all streams so they don't hold up a graceful shutdown; pages
+// reconnect to the next roji
+func (lr *LiveReload) close() {
+	lr.closeOnce.Do(func() {
+		close(lr.closed)
+	})
+}
+
+// WithLiveReload serves LiveReloadPath and injects the reload script into
+// the HTML pages of roji.live-reload routes
+func WithLiveReload(lr *LiveReload) HandlerOption {
+	return func(h *Handler) {
+		h.liveReload = lr
+	}
+}
+
+// injectsLiveReload reports whether a route's HTML pages get the reload script
+func (h *Handler) injectsLiveReload(b *docker.Backend) bool {
+	return h.liveReload != nil && b.LiveReload
+}
+
+// Started reloads the pages of a backend's hostname once it accepts
+// connections, so pages don't reload into a 502 while the app boots
+func (lr *LiveReload) Started(ctx context.Context, backend *docker.Backend) {
+	if !backend.LiveReload {
+		return
+	}
+	addr := net.JoinHostPort(backend.Host, strconv.Itoa(backend.Port))
+	deadline := time.Now().Add(liveReloadReadyTimeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", addr, lr.dialTimeout)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+	lr.Notify(backend.Hostname)
+}
+
+// Notify sends a reload event to every page open on hostname
+func (lr *LiveReload) Notify(hostname string) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	for ch := range lr.subscribers[hostname] {
+		select {
+		case ch <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+	if n := len(lr.subscribers[hostname]); n > 0 {
+		slog.Info("reloading pages", "hostname", hostname, "pages", n)
+	}
+}
+
+func (lr *LiveReload) subscribe(hostname string) chan struct{} {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	if lr.subscribers[hostname] == nil {
+		lr.subscribers[hostname] = make(map[chan struct{}]struct{})
+	}
+	lr.subscribers[hostname][ch] = struct{}{}
+	return ch
+}
+
+func (lr *LiveReload) unsubscribe(hostname string, ch chan struct{}) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	delete(lr.subscribers[hostname], ch)
+	if len(lr.subscribers[hostname]) == 0 {
+		delete(lr.subscribers, hostname)
+	}
+}
+
+// serveLiveReload streams reload events for hostname until the page goes away
+func (lr *LiveReload) serveLiveReload(w http.ResponseWriter, r *http.Request, hostname string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := lr.subscribe(hostname)
+	defer lr.unsubscribe(hostname, ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(liveReloadKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-lr.closed:
+			return
+		case <-ch:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// serveLiveReloadScript serves the script injected into HTML pages
+func serveLiveReloadScript(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, liveReloadScript)
+}
+
+// injectLiveReload adds the reload script to an HTML response, just before
+// </body> (or at the end of documents without one). Only whole pages get it
+// (see fullPage).
+func injectLiveReload(resp *http.Response) error {
+	if !fullPage(resp) {
+		return nil
+	}
+	tag := []byte(`<script src="` + liveReloadScriptPath + `" defer></script>`)
+	return rewriteHTML(resp, func(body []byte) []byte {
+		return insertBeforeBodyEnd(body, tag)
+	})
+}
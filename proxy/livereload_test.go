@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_LiveReloadInjection(t *testing.T) {
+	html := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/cached":
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case "/range":
+			w.Header().Set("Content-Range", "bytes 0-4/17")
+			w.WriteHeader(http.StatusPartialContent)
+			io.WriteString(w, "<html")
+			return
+		}
+		io.WriteString(w, "<body>page</body>")
+	}
+	live := newTestBackend(t, "web.localhost", html)
+	live.LiveReload = true
+	plain := newTestBackend(t, "plain.localhost", html)
+
+	router := NewRouter()
+	router.AddBackend(live)
+	router.AddBackend(plain)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithLiveReload(NewLiveReload()))
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+	if body := get("https://web.localhost/").Body.String(); body != `<body>page<script src="/_roji/live-reload.js" defer></script></body>` {
+		t.Errorf("roji.live-reload body = %q, want the script injected", body)
+	}
+	if body := get("https://plain.localhost/").Body.String(); body != "<body>page</body>" {
+		t.Errorf("plain body = %q, want it untouched", body)
+	}
+
+	// Only whole pages get the script
+	if w := get("https://web.localhost/range"); w.Code != http.StatusPartialContent || w.Body.String() != "<html" {
+		t.Errorf("partial content = %d %q, want it untouched", w.Code, w.Body)
+	}
+	if w := get("https://web.localhost/cached"); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("not modified = %d %q, want no body", w.Code, w.Body)
+	}
+
+	// The script is served on every hostname, even without a route
+	w := get("https://gone.localhost/_roji/live-reload.js")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), LiveReloadPath) {
+		t.Errorf("script = %d %q, want the EventSource script", w.Code, w.Body)
+	}
+}
+
+func TestLiveReload_Started(t *testing.T) {
+	lr := NewLiveReload()
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithLiveReload(lr))
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+LiveReloadPath, nil)
+	req.Host = "web.localhost"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	events := bufio.NewReader(resp.Body)
+	if line, _ := events.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("first line = %q, want the connected comment", line)
+	}
+
+	// The backend accepts connections, so pages reload right away
+	backend := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {})
+	backend.LiveReload = true
+	go lr.Started(ctx, backend)
+
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended before a reload event: %v", err)
+		}
+		if line == "event: reload\n" {
+			return
+		}
+	}
+}
+
+func TestLiveReload_StartedWaitsForBackend(t *testing.T) {
+	lr := NewLiveReload()
+	ch := lr.subscribe("web.localhost")
+
+	// Reserve a port, then free it: the "container" is still booting
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	backend := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {})
+	backend.Host, backend.Port, backend.LiveReload = "127.0.0.1", addr.Port, true
+	go lr.Started(context.Background(), backend)
+
+	select {
+	case <-ch:
+		t.Fatal("reloaded before the backend accepted connections")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	ln, err = net.Listen("tcp", addr.String())
+	if err != nil {
+		t.Skipf("port was taken meanwhile: %v", err)
+	}
+	defer ln.Close()
+	select {
+	case <-ch:
+	case <-time.After(3 * time.Second):
+		t.Fatal("no reload after the backend came up")
+	}
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// LoopbackOnly wraps a listener so it closes connections from non-loopback
+// addresses as soon as they are accepted (--local-only), whatever address it
+// is bound to. Clients on the network see the connection reset before any
+// TLS or HTTP is spoken.
+func LoopbackOnly(ln net.Listener) net.Listener {
+	return &loopbackListener{Listener: ln}
+}
+
+type loopbackListener struct {
+	net.Listener
+	warned sync.Map // remote IPs already logged at warn level
+}
+
+func (l *loopbackListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if isLoopbackAddr(conn.RemoteAddr()) {
+			return conn, nil
+		}
+
+		ip := conn.RemoteAddr().String()
+		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+			ip = addr.IP.String()
+		}
+		if _, seen := l.warned.LoadOrStore(ip, true); seen {
+			slog.Debug("rejected connection from non-loopback address", "client", ip)
+		} else {
+			slog.Warn("rejected connection from non-loopback address (--local-only)", "client", ip)
+		}
+		conn.Close()
+	}
+}
+
+// isLoopbackAddr reports whether a TCP address is on this machine's
+// loopback interface, including IPv4-mapped IPv6 (::ffff:127.0.0.1)
+func isLoopbackAddr(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	return tcp.AddrPort().Addr().Unmap().IsLoopback()
+}
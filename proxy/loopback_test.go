@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// fakeConn is a connection from a given remote address
+type fakeConn struct {
+	net.Conn
+	remote net.Addr
+	closed bool
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr { return c.remote }
+func (c *fakeConn) Close() error         { c.closed = true; return nil }
+
+// fakeListener accepts the given connections, then fails
+type fakeListener struct {
+	net.Listener
+	conns []*fakeConn
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	if len(l.conns) == 0 {
+		return nil, net.ErrClosed
+	}
+	conn := l.conns[0]
+	l.conns = l.conns[1:]
+	return conn, nil
+}
+
+func TestLoopbackOnly(t *testing.T) {
+	tcp := func(ip string) net.Addr { return &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000} }
+	lan := &fakeConn{remote: tcp("192.168.1.20")}
+	v4 := &fakeConn{remote: tcp("127.0.0.1")}
+	lanV6 := &fakeConn{remote: tcp("fe80::1")}
+	mapped := &fakeConn{remote: tcp("::ffff:127.0.0.1")}
+	v6 := &fakeConn{remote: tcp("::1")}
+	ln := LoopbackOnly(&fakeListener{conns: []*fakeConn{lan, v4, lanV6, lan, mapped, v6}})
+
+	for _, want := range []*fakeConn{v4, mapped, v6} {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatalf("Accept() error = %v", err)
+		}
+		if conn != want {
+			t.Errorf("Accept() = connection from %s, want %s", conn.RemoteAddr(), want.RemoteAddr())
+		}
+	}
+	if _, err := ln.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept() error = %v, want the listener's error", err)
+	}
+
+	for _, conn := range []*fakeConn{lan, lanV6} {
+		if !conn.closed {
+			t.Errorf("connection from %s was not closed", conn.RemoteAddr())
+		}
+	}
+	if v4.closed || mapped.closed || v6.closed {
+		t.Error("loopback connection was closed")
+	}
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maintenanceModes holds the routes put in maintenance from the dashboard or
+// /_api/routes/{host}/pause. They stay registered but answer 503 until
+// resumed; the mode is kept across container restarts but not roji's.
+type maintenanceModes struct {
+	mu    sync.Mutex
+	since map[string]time.Time // hostname -> when maintenance started
+}
+
+func newMaintenanceModes() *maintenanceModes {
+	return &maintenanceModes{since: make(map[string]time.Time)}
+}
+
+// set turns maintenance on or off, reporting whether it changed
+func (m *maintenanceModes) set(hostname string, on bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, was := m.since[hostname]
+	if on && !was {
+		m.since[hostname] = time.Now()
+	} else if !on {
+		delete(m.since, hostname)
+	}
+	return on != was
+}
+
+// started returns when maintenance started for a hostname
+func (m *maintenanceModes) started(hostname string) (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since, ok := m.since[hostname]
+	return since, ok
+}
+
+// inMaintenance reports whether a request is held by maintenance mode: of
+// the requested route, or of the fragment serving a mounted path
+func (h *Handler) inMaintenance(composite, route *Route) (time.Time, bool) {
+	if since, ok := h.maintenance.started(composite.Hostname); ok {
+		return since, true
+	}
+	return h.maintenance.started(route.Hostname)
+}
+
+// serveMaintenance answers for a route in maintenance
+func (h *Handler) serveMaintenance(w http.ResponseWriter, r *http.Request, route *Route, since time.Time) {
+	data := struct {
+		Hostname      string
+		Since         time.Time
+		DashboardHost string
+	}{
+		Hostname:      route.Hostname,
+		Since:         since,
+		DashboardHost: h.dashboardHost,
+	}
+
+	slog.Debug("route is in maintenance", "hostname", route.Hostname, "path", r.URL.Path)
+	w.Header().Set("Cache-Control", "no-store")
+	renderPage(w, http.StatusServiceUnavailable, "maintenance.html", data)
+}
+
+// serveRouteAPI handles /_api/routes/{host}/pause: PUT starts maintenance
+// and DELETE ends it. The dashboard toggle posts a form with paused=true or
+// paused=false and is redirected back.
+func (h *Handler) serveRouteAPI(w http.ResponseWriter, r *http.Request, rest string) {
+	hostname, action, _ := strings.Cut(rest, "/")
+	if action != "pause" {
+		http.Error(w, "unknown API endpoint "+r.URL.Path+" (see "+OpenAPIPath+")", http.StatusNotFound)
+		return
+	}
+	hostname = strings.ToLower(hostname)
+
+	var on bool
+	fromDashboard := false
+	switch r.Method {
+	case http.MethodPut:
+		on = true
+	case http.MethodDelete:
+		on = false
+	case http.MethodPost:
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			http.Error(w, "use PUT to pause and DELETE to resume", http.StatusUnsupportedMediaType)
+			return
+		}
+		// Form posts can be triggered cross-site; only accept them from the dashboard itself
+		if h.crossOrigin(r) {
+			http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+			return
+		}
+		on, fromDashboard = r.PostFormValue("paused") == "true", true
+	default:
+		w.Header().Set("Allow", "PUT, DELETE, POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.hasRoute(hostname) {
+		http.Error(w, "no route for hostname "+hostname, http.StatusNotFound)
+		return
+	}
+	if h.maintenance.set(hostname, on) {
+		if on {
+			slog.Info("route in maintenance", "hostname", hostname)
+		} else {
+			slog.Info("route resumed", "hostname", hostname)
+		}
+	}
+
+	if fromDashboard {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
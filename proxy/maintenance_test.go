@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHandler_Maintenance(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	api := func(method, host string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "https://roji.localhost/_api/v1/routes/"+host+"/pause", nil))
+		return w.Code
+	}
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "https://web.localhost/", nil))
+		return w
+	}
+
+	if code := api("PUT", "Web.localhost"); code != http.StatusNoContent {
+		t.Fatalf("PUT pause = %d", code)
+	}
+	w := get()
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "web.localhost is in maintenance") {
+		t.Errorf("in maintenance: %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/routes", nil))
+	var routes []RouteInfo
+	if err := json.NewDecoder(w.Body).Decode(&routes); err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || !routes[0].Maintenance {
+		t.Errorf("routes = %+v, want the route in maintenance", routes)
+	}
+	explained := handler.Explain(httptest.NewRequest("GET", "https://web.localhost/", nil))
+	if explained.Outcome != OutcomeMaintenance {
+		t.Errorf("explain outcome = %q", explained.Outcome)
+	}
+
+	if code := api("DELETE", "web.localhost"); code != http.StatusNoContent {
+		t.Fatalf("DELETE pause = %d", code)
+	}
+	if w := get(); w.Code != http.StatusOK {
+		t.Errorf("after resuming: %d", w.Code)
+	}
+
+	if code := api("PUT", "nope.localhost"); code != http.StatusNotFound {
+		t.Errorf("unknown route: %d, want 404", code)
+	}
+	if code := api("GET", "web.localhost"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: %d, want 405", code)
+	}
+}
+
+func TestHandler_MaintenanceDashboardToggle(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {}))
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	toggle := func(paused, origin string) *httptest.ResponseRecorder {
+		form := url.Values{"paused": {paused}}.Encode()
+		req := httptest.NewRequest("POST", "https://roji.localhost/_api/v1/routes/web.localhost/pause", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := toggle("true", "https://evil.example"); w.Code != http.StatusForbidden {
+		t.Errorf("cross-origin toggle: %d, want 403", w.Code)
+	}
+	if w := toggle("true", "https://roji.localhost"); w.Code != http.StatusSeeOther {
+		t.Fatalf("toggle: %d, want a redirect to the dashboard", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/", nil))
+	if body := w.Body.String(); !strings.Contains(body, "🚧 maintenance") || !strings.Contains(body, `name="paused" value="false"`) {
+		t.Error("dashboard doesn't offer to resume the route")
+	}
+
+	toggle("false", "https://roji.localhost")
+	if _, ok := handler.maintenance.started("web.localhost"); ok {
+		t.Error("route still in maintenance")
+	}
+}
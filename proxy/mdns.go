@@ -0,0 +1,419 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// MDNSDomain is the link-local domain of multicast DNS names
+const MDNSDomain = "local"
+
+const (
+	mdnsTTL       = 120 * time.Second // RFC 6762 10: TTL of address records
+	mdnsLegacyTTL = 10 * time.Second  // RFC 6762 6.7: at most 10s for one-shot queries
+
+	// RFC 6762 8.1: three probes 250ms apart before claiming a name
+	mdnsProbes        = 3
+	mdnsProbeInterval = 250 * time.Millisecond
+
+	// The top bit of the class: "unicast response" in questions, "cache
+	// flush" in answers (RFC 6762 5.4 and 10.2)
+	mdnsClassFlag = 1 << 15
+)
+
+var mdnsGroup = netip.MustParseAddrPort("224.0.0.251:5353")
+
+// MDNSName returns the .local name a route is advertised as (--mdns):
+// web.myproject.dev.localhost -> web.myproject.local. Routes already named
+// .local keep their name; other hostnames outside the base domain, and the
+// base domain itself, aren't advertised ("").
+func MDNSName(hostname, baseDomain string) string {
+	if strings.HasSuffix(hostname, "."+MDNSDomain) {
+		return hostname
+	}
+	name, ok := strings.CutSuffix(hostname, "."+baseDomain)
+	if !ok || name == "" {
+		return ""
+	}
+	return name + "." + MDNSDomain
+}
+
+// MDNSCertNames returns the certificate names needed for the .local names
+func MDNSCertNames() []string {
+	return []string{"*." + MDNSDomain, "*.*." + MDNSDomain}
+}
+
+// WithMDNSHostnames serves routes under the .local names they are
+// advertised as (see MDNSResponder)
+func WithMDNSHostnames(enabled bool) HandlerOption {
+	return func(h *Handler) {
+		h.mdns = enabled
+	}
+}
+
+// fromMDNSName maps an advertised .local name back to the route hostname
+func (h *Handler) fromMDNSName(hostname string) string {
+	if !h.mdns || hostname == h.lanName || h.router.HasHostname(hostname) {
+		return hostname
+	}
+	if name, ok := strings.CutSuffix(hostname, "."+MDNSDomain); ok {
+		return name + "." + h.statusConfig.BaseDomain
+	}
+	return hostname
+}
+
+// MDNSResponder advertises the routes as .local names resolving to the LAN
+// address, so other devices on the network reach them without DNS setup.
+// It probes for each name first and leaves names another device answers
+// for alone, then answers queries for the names and announces them as routes
+// come and go. The machine's own name (e.g., "laptop.local") is left to the
+// OS.
+type MDNSResponder struct {
+	router     *Router
+	baseDomain string
+	ip         netip.Addr
+	skip       string // the machine's own mDNS name
+
+	mu        sync.Mutex
+	names     map[string]bool // claimed: answered and announced
+	probing   map[string]bool // waiting out the probes
+	conflicts map[string]bool // in use by another device; retried when the route comes back
+
+	handoff atomic.Bool // exiting for an upgrade; see Handoff
+}
+
+// NewMDNSResponder creates a responder advertising router's routes on ip.
+// skip is the machine's own mDNS name ("" = none).
+func NewMDNSResponder(router *Router, baseDomain string, ip netip.Addr, skip string) *MDNSResponder {
+	return &MDNSResponder{
+		router:     router,
+		baseDomain: baseDomain,
+		ip:         ip,
+		skip:       strings.ToLower(skip),
+		names:      make(map[string]bool),
+		probing:    make(map[string]bool),
+		conflicts:  make(map[string]bool),
+	}
+}
+
+// Handoff keeps the names advertised when Run exits, for the new process of
+// an upgrade to take over. Call it before cancelling Run's context.
+func (m *MDNSResponder) Handoff() {
+	m.handoff.Store(true)
+}
+
+// Run answers mDNS queries until ctx is done, then withdraws the names
+// unless handing off (see Handoff). The OS responder (Bonjour, Avahi) shares
+// the port.
+func (m *MDNSResponder) Run(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", m.iface(), net.UDPAddrFromAddrPort(mdnsGroup))
+	if err != nil {
+		return fmt.Errorf("failed to join the mDNS group: %w", err)
+	}
+	defer conn.Close()
+
+	changed := make(chan struct{}, 1)
+	m.router.OnChange(func() {
+		select {
+		case changed <- struct{}{}:
+		default: // an update is already pending
+		}
+	})
+	send := func(packet []byte, dst netip.AddrPort) {
+		if _, err := conn.WriteToUDPAddrPort(packet, dst); err != nil {
+			slog.Debug("failed to send mDNS response", "to", dst, "error", err)
+		}
+	}
+
+	go func() {
+		announce := func() {
+			added, removed := m.update()
+			if len(removed) > 0 {
+				send(m.records(removed, 0), mdnsGroup) // goodbye
+			}
+			if len(added) == 0 || !m.probe(ctx, added, send) {
+				return
+			}
+			added = m.claim(added)
+			if len(added) == 0 {
+				return
+			}
+			slog.Info("advertising routes over mDNS", "names", strings.Join(added, ", "))
+			// RFC 6762 8.3: announce twice, a second apart
+			packet := m.records(added, mdnsTTL)
+			send(packet, mdnsGroup)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+				send(packet, mdnsGroup)
+			}
+		}
+		announce()
+		for {
+			select {
+			case <-ctx.Done():
+				m.mu.Lock()
+				names := slices.Sorted(maps.Keys(m.names))
+				m.mu.Unlock()
+				if len(names) > 0 && !m.handoff.Load() {
+					send(m.records(names, 0), mdnsGroup)
+				}
+				conn.Close()
+				return
+			case <-changed:
+				announce()
+			}
+		}
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		// RFC 6762 6.7: queries from other ports are one-shot resolvers
+		// expecting a plain unicast DNS answer
+		legacy := src.Port() != mdnsGroup.Port()
+		if !legacy {
+			m.observe(buf[:n])
+		}
+		response, unicast := m.answer(buf[:n], legacy)
+		if response == nil {
+			continue
+		}
+		if unicast || legacy {
+			send(response, src)
+		} else {
+			send(response, mdnsGroup)
+		}
+	}
+}
+
+// iface returns the interface with the LAN address, or nil (the default)
+// when it isn't on this machine, e.g., in a container with --lan-ip
+func (m *MDNSResponder) iface() *net.Interface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range ifaces {
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			if prefix, err := netip.ParsePrefix(a.String()); err == nil && prefix.Addr() == m.ip {
+				return &iface
+			}
+		}
+	}
+	return nil
+}
+
+// update recomputes the names from the routes and returns the ones that
+// appeared, to probe for, and the claimed ones that went away
+func (m *MDNSResponder) update() (added, removed []string) {
+	current := make(map[string]bool)
+	for _, r := range m.router.ListRoutes() {
+		if name := MDNSName(r.Hostname, m.baseDomain); name != "" && name != m.skip {
+			current[name] = true
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range current {
+		if !m.names[name] && !m.probing[name] && !m.conflicts[name] {
+			added = append(added, name)
+			m.probing[name] = true
+		}
+	}
+	for name := range m.names {
+		if !current[name] {
+			removed = append(removed, name)
+			delete(m.names, name)
+		}
+	}
+	maps.DeleteFunc(m.probing, func(name string, _ bool) bool { return !current[name] })
+	maps.DeleteFunc(m.conflicts, func(name string, _ bool) bool { return !current[name] })
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed
+}
+
+// probe asks whether another device uses names before they are claimed (RFC
+// 6762 8.1); answers arrive through observe. It reports false when ctx is
+// done first.
+func (m *MDNSResponder) probe(ctx context.Context, names []string, send func([]byte, netip.AddrPort)) bool {
+	wait := rand.N(mdnsProbeInterval) // spreads out devices starting together
+	for i := range mdnsProbes + 1 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(wait):
+		}
+		if i < mdnsProbes {
+			send(m.probeQuery(names, i == 0), mdnsGroup)
+		}
+		wait = mdnsProbeInterval
+	}
+	return true
+}
+
+// probeQuery asks for any record of names, with the records we propose in
+// the authority section (RFC 6762 8.1). The first probe asks for unicast
+// answers.
+func (m *MDNSResponder) probeQuery(names []string, unicast bool) []byte {
+	class := dnsmessage.ClassINET
+	if unicast {
+		class |= mdnsClassFlag
+	}
+	var query dnsmessage.Message
+	for _, name := range names {
+		n, err := dnsmessage.NewName(name + ".")
+		if err != nil {
+			continue
+		}
+		query.Questions = append(query.Questions, dnsmessage.Question{Name: n, Type: dnsmessage.TypeALL, Class: class})
+		query.Authorities = append(query.Authorities, m.record(n, dnsmessage.ClassINET, mdnsTTL))
+	}
+	packet, _ := query.Pack()
+	return packet
+}
+
+// claim takes the probed names no other device answered for, and returns them
+func (m *MDNSResponder) claim(names []string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var claimed []string
+	for _, name := range names {
+		if m.probing[name] {
+			delete(m.probing, name)
+			m.names[name] = true
+			claimed = append(claimed, name)
+		}
+	}
+	return claimed
+}
+
+// observe looks for answers about names being probed in a response from
+// the network. Any record but our own address means another device has the
+// name: it's given up, and not advertised while its route stays.
+func (m *MDNSResponder) observe(packet []byte) {
+	var p dnsmessage.Parser
+	header, err := p.Start(packet)
+	if err != nil || !header.Response {
+		return
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, answer := range answers {
+		name := strings.ToLower(strings.TrimSuffix(answer.Header.Name.String(), "."))
+		if !m.probing[name] {
+			continue
+		}
+		if a, ok := answer.Body.(*dnsmessage.AResource); ok && netip.AddrFrom4(a.A) == m.ip {
+			continue
+		}
+		delete(m.probing, name)
+		m.conflicts[name] = true
+		slog.Warn("another device on the network uses an mDNS name; not advertising it", "name", name)
+	}
+}
+
+// answer builds the response to a query, and reports whether it was asked
+// to be sent by unicast. It returns nil when no question is about our names.
+func (m *MDNSResponder) answer(query []byte, legacy bool) ([]byte, bool) {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil || header.Response {
+		return nil, false
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return nil, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var asked []dnsmessage.Question
+	unicast := true
+	for _, q := range questions {
+		name := strings.ToLower(strings.TrimSuffix(q.Name.String(), "."))
+		if !m.names[name] || q.Class&^mdnsClassFlag != dnsmessage.ClassINET ||
+			(q.Type != dnsmessage.TypeA && q.Type != dnsmessage.TypeALL) {
+			continue
+		}
+		asked = append(asked, q)
+		unicast = unicast && q.Class&mdnsClassFlag != 0
+	}
+	if len(asked) == 0 {
+		return nil, false
+	}
+
+	response := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+	}
+	ttl, class := mdnsTTL, dnsmessage.ClassINET|mdnsClassFlag
+	if legacy {
+		// Plain DNS: echo the ID and question, no cache flush bit
+		response.ID = header.ID
+		ttl, class = mdnsLegacyTTL, dnsmessage.ClassINET
+	}
+	for _, q := range asked {
+		if legacy {
+			response.Questions = append(response.Questions, dnsmessage.Question{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET})
+		}
+		response.Answers = append(response.Answers, m.record(q.Name, class, ttl))
+	}
+	packet, err := response.Pack()
+	if err != nil {
+		return nil, false
+	}
+	return packet, unicast
+}
+
+// records builds an unsolicited response with the address of names; a TTL
+// of 0 withdraws them
+func (m *MDNSResponder) records(names []string, ttl time.Duration) []byte {
+	response := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+	}
+	for _, name := range names {
+		n, err := dnsmessage.NewName(name + ".")
+		if err != nil {
+			continue
+		}
+		response.Answers = append(response.Answers, m.record(n, dnsmessage.ClassINET|mdnsClassFlag, ttl))
+	}
+	packet, _ := response.Pack()
+	return packet
+}
+
+func (m *MDNSResponder) record(name dnsmessage.Name, class dnsmessage.Class, ttl time.Duration) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: name, Class: class, TTL: uint32(ttl / time.Second)},
+		Body:   &dnsmessage.AResource{A: m.ip.As4()},
+	}
+}
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestMDNSName(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{"web.myproject.dev.localhost", "web.myproject.local"},
+		{"myproject.dev.localhost", "myproject.local"},
+		{"printer.local", "printer.local"},
+		{"dev.localhost", ""},
+		{"api.example.com", ""},
+	}
+	for _, tt := range tests {
+		if got := MDNSName(tt.hostname, "dev.localhost"); got != tt.want {
+			t.Errorf("MDNSName(%q) = %q, want %q", tt.hostname, got, tt.want)
+		}
+	}
+}
+
+// mdnsQuery packs a query for name's address; unicast sets the QU bit
+func mdnsQuery(t *testing.T, id uint16, name string, unicast bool) []byte {
+	t.Helper()
+	class := dnsmessage.ClassINET
+	if unicast {
+		class |= mdnsClassFlag
+	}
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id},
+		Questions: []dnsmessage.Question{
+			{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeA, Class: class},
+		},
+	}
+	packet, err := query.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+func TestMDNSResponder_Answer(t *testing.T) {
+	web := newTestBackend(t, "web.myproject.dev.localhost", nil)
+	web.ContainerID = "web1"
+	router := NewRouter()
+	router.AddBackend(web)
+	router.AddBackend(newTestBackend(t, "laptop.dev.localhost", nil))
+	m := NewMDNSResponder(router, "dev.localhost", netip.MustParseAddr("192.168.1.20"), "laptop.local")
+
+	added, removed := m.update()
+	if len(added) != 1 || added[0] != "web.myproject.local" || len(removed) != 0 {
+		t.Fatalf("update() = %v, %v; want [web.myproject.local], []", added, removed)
+	}
+	if response, _ := m.answer(mdnsQuery(t, 0, "web.myproject.local.", false), false); response != nil {
+		t.Fatal("answered for a name still being probed")
+	}
+	m.claim(added)
+
+	tests := []struct {
+		name        string
+		query       string
+		unicast     bool
+		legacy      bool
+		wantAnswer  bool
+		wantUnicast bool
+		wantID      uint16
+		wantTTL     uint32
+	}{
+		{"multicast", "web.myproject.local.", false, false, true, false, 0, 120},
+		{"case-insensitive", "Web.MyProject.local.", false, false, true, false, 0, 120},
+		{"QU bit", "web.myproject.local.", true, false, true, true, 0, 120},
+		{"one-shot resolver", "web.myproject.local.", false, true, true, false, 7, 10},
+		{"unknown name", "other.local.", false, false, false, false, 0, 0},
+		{"the machine's own name", "laptop.local.", false, false, false, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, unicast := m.answer(mdnsQuery(t, 7, tt.query, tt.unicast), tt.legacy)
+			if !tt.wantAnswer {
+				if response != nil {
+					t.Fatal("answered a query about another name")
+				}
+				return
+			}
+			if unicast != tt.wantUnicast {
+				t.Errorf("unicast = %v, want %v", unicast, tt.wantUnicast)
+			}
+
+			var msg dnsmessage.Message
+			if err := msg.Unpack(response); err != nil {
+				t.Fatal(err)
+			}
+			if msg.ID != tt.wantID || !msg.Response || len(msg.Answers) != 1 {
+				t.Fatalf("unexpected response: %+v", msg)
+			}
+			answer := msg.Answers[0]
+			a, ok := answer.Body.(*dnsmessage.AResource)
+			if !ok || netip.AddrFrom4(a.A) != netip.MustParseAddr("192.168.1.20") {
+				t.Errorf("answer = %v, want A 192.168.1.20", answer.Body)
+			}
+			if answer.Header.TTL != tt.wantTTL {
+				t.Errorf("TTL = %d, want %d", answer.Header.TTL, tt.wantTTL)
+			}
+		})
+	}
+
+	router.RemoveBackend("web1")
+	if added, removed := m.update(); len(added) != 0 || len(removed) != 1 {
+		t.Errorf("update() after removal = %v, %v; want [], [web.myproject.local]", added, removed)
+	}
+}
+
+func TestMDNSResponder_ProbeConflict(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "web.myproject.dev.localhost", nil))
+	m := NewMDNSResponder(router, "dev.localhost", netip.MustParseAddr("192.168.1.20"), "")
+
+	added, _ := m.update()
+	other := NewMDNSResponder(NewRouter(), "dev.localhost", netip.MustParseAddr("192.168.1.30"), "")
+	m.observe(m.records(added, mdnsTTL)) // our own announcement, looped back
+	m.observe(other.records([]string{"printer.local"}, mdnsTTL))
+	if !m.probing["web.myproject.local"] {
+		t.Fatal("gave up a name on an unrelated answer")
+	}
+	m.observe(other.records(added, mdnsTTL))
+	if claimed := m.claim(added); len(claimed) != 0 {
+		t.Errorf("claimed %v although another device answered", claimed)
+	}
+	if response, _ := m.answer(mdnsQuery(t, 0, "web.myproject.local.", false), false); response != nil {
+		t.Error("answered for a name another device has")
+	}
+	if added, _ := m.update(); len(added) != 0 {
+		t.Errorf("probed again for %v while the route stayed", added)
+	}
+}
+
+func TestHandler_MDNSHostnames(t *testing.T) {
+	backend := newTestBackend(t, "web.myproject.dev.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	statusConfig := testStatusConfig()
+	statusConfig.BaseDomain = "dev.localhost"
+	handler := NewHandler(router, "dev.localhost", statusConfig, WithMDNSHostnames(true))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://web.myproject.local/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,280 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyWindow is the period p95 latency is computed over
+	latencyWindow = time.Minute
+	// maxLatencySamples bounds the samples kept per route
+	maxLatencySamples = 1024
+	// maxRequestLog is how many recent requests (all routes) are kept
+	maxRequestLog = 200
+
+	// historyBuckets of historyBucketSize make up the traffic history
+	// charted on the dashboard (5 minutes)
+	historyBuckets    = 30
+	historyBucketSize = 10 * time.Second
+)
+
+// latencyBounds are the upper bounds of the latency histogram buckets; a
+// last bucket counts everything slower
+var latencyBounds = []time.Duration{
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// RouteMetrics are the traffic counters of one route since roji started
+type RouteMetrics struct {
+	Hostname   string           `json:"hostname"`
+	PathPrefix string           `json:"path_prefix,omitempty"`
+	Requests   int64            `json:"requests"`
+	Status     map[string]int64 `json:"status"`           // "2xx" -> count
+	P95        float64          `json:"p95_ms,omitempty"` // over the last minute
+	Recent     int              `json:"recent"`           // requests in the last minute
+
+	// History counts requests in 10-second buckets over the last 5
+	// minutes, oldest first
+	History []HistoryBucket `json:"history"`
+
+	// Latency is the distribution of every request's latency since roji
+	// started; Slow counts those over the --slow-request threshold
+	Latency []LatencyBucket `json:"latency"`
+	Slow    int64           `json:"slow"`
+}
+
+// LatencyBucket counts the requests of a route at most LE slow (and slower
+// than the previous bucket). The last bucket has no bound.
+type LatencyBucket struct {
+	LE    float64 `json:"le_ms,omitempty"`
+	Count int64   `json:"count"`
+}
+
+// HistoryBucket counts the requests of a route in one 10-second interval
+type HistoryBucket struct {
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"` // 5xx responses and proxy errors
+}
+
+// RequestLogEntry summarizes one request for roji tui
+type RequestLogEntry struct {
+	Time     time.Time     `json:"time"`
+	Method   string        `json:"method"`
+	Hostname string        `json:"hostname"`
+	Path     string        `json:"path"`
+	Status   int           `json:"status"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// MetricsSnapshot is the response of /_api/metrics. Clients derive rates
+// from the change in Requests between snapshots.
+type MetricsSnapshot struct {
+	Time   time.Time         `json:"time"`
+	Routes []RouteMetrics    `json:"routes"`
+	Log    []RequestLogEntry `json:"log,omitempty"` // newest first, with ?log=N
+}
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+type routeCounters struct {
+	hostname   string
+	pathPrefix string
+	requests   int64
+	status     map[string]int64
+	samples    []latencySample // ring buffer
+	next       int
+	history    [historyBuckets]historySlot
+	latency    []int64 // per latencyBounds bucket, plus one for slower
+	slow       int64
+}
+
+// historySlot is a HistoryBucket of the ring, tagged with its interval
+type historySlot struct {
+	interval int64 // Unix time / historyBucketSize
+	HistoryBucket
+}
+
+// metrics counts proxied requests per route for /_api/metrics (roji top)
+type metrics struct {
+	mu     sync.Mutex
+	routes map[string]*routeCounters // key: hostname + path prefix
+	log    []RequestLogEntry         // ring buffer
+	next   int
+	slow   time.Duration // requests slower than this count as slow (0 = never)
+}
+
+func newMetrics() *metrics {
+	return &metrics{routes: make(map[string]*routeCounters)}
+}
+
+// observe records a response (or proxy error) for a route
+func (m *metrics) observe(route *Route, r *http.Request, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := route.Hostname + route.PathPrefix
+	c, ok := m.routes[key]
+	if !ok {
+		c = &routeCounters{
+			hostname:   route.Hostname,
+			pathPrefix: route.PathPrefix,
+			status:     make(map[string]int64),
+			latency:    make([]int64, len(latencyBounds)+1),
+		}
+		m.routes[key] = c
+	}
+	c.requests++
+	c.status[statusClass(status)]++
+	bucket, _ := slices.BinarySearch(latencyBounds, duration)
+	c.latency[bucket]++
+	if m.slow > 0 && duration > m.slow {
+		c.slow++
+	}
+
+	now := time.Now()
+	interval := now.UnixNano() / int64(historyBucketSize)
+	slot := &c.history[interval%historyBuckets]
+	if slot.interval != interval {
+		*slot = historySlot{interval: interval}
+	}
+	slot.Requests++
+	if status >= 500 {
+		slot.Errors++
+	}
+
+	sample := latencySample{at: now, duration: duration}
+	if len(c.samples) < maxLatencySamples {
+		c.samples = append(c.samples, sample)
+	} else {
+		c.samples[c.next] = sample
+		c.next = (c.next + 1) % maxLatencySamples
+	}
+
+	entry := RequestLogEntry{
+		Time:     now,
+		Method:   r.Method,
+		Hostname: route.Hostname,
+		Path:     r.URL.Path,
+		Status:   status,
+		Duration: duration,
+	}
+	if len(m.log) < maxRequestLog {
+		m.log = append(m.log, entry)
+	} else {
+		m.log[m.next] = entry
+		m.next = (m.next + 1) % maxRequestLog
+	}
+}
+
+// recentRequests returns up to limit logged requests, newest first
+func (m *metrics) recentRequests(limit int) []RequestLogEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]RequestLogEntry, 0, min(limit, len(m.log)))
+	for i := 1; i <= len(m.log) && len(entries) < limit; i++ {
+		// m.next is the oldest entry once the ring is full
+		entries = append(entries, m.log[(m.next-i+len(m.log))%len(m.log)])
+	}
+	return entries
+}
+
+// snapshot returns the counters of every route that served a request
+func (m *metrics) snapshot(now time.Time) MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snap := MetricsSnapshot{Time: now, Routes: make([]RouteMetrics, 0, len(m.routes))}
+	for _, c := range m.routes {
+		rm := RouteMetrics{
+			Hostname:   c.hostname,
+			PathPrefix: c.pathPrefix,
+			Requests:   c.requests,
+			Status:     make(map[string]int64, len(c.status)),
+		}
+		for class, n := range c.status {
+			rm.Status[class] = n
+		}
+		rm.Latency = make([]LatencyBucket, len(c.latency))
+		for i, n := range c.latency {
+			rm.Latency[i].Count = n
+			if i < len(latencyBounds) {
+				rm.Latency[i].LE = float64(latencyBounds[i].Milliseconds())
+			}
+		}
+		rm.Slow = c.slow
+
+		var recent []time.Duration
+		for _, s := range c.samples {
+			if now.Sub(s.at) <= latencyWindow {
+				recent = append(recent, s.duration)
+			}
+		}
+		rm.Recent = len(recent)
+		rm.History = c.historyAt(now)
+		if len(recent) > 0 {
+			slices.Sort(recent)
+			p95 := recent[(len(recent)*95+99)/100-1]
+			rm.P95 = float64(p95.Microseconds()) / 1000
+		}
+		snap.Routes = append(snap.Routes, rm)
+	}
+	sort.Slice(snap.Routes, func(i, j int) bool {
+		a, b := snap.Routes[i], snap.Routes[j]
+		return a.Hostname+a.PathPrefix < b.Hostname+b.PathPrefix
+	})
+	return snap
+}
+
+// historyAt returns the buckets of the last 5 minutes before now, oldest
+// first; intervals without requests are zero
+func (c *routeCounters) historyAt(now time.Time) []HistoryBucket {
+	current := now.UnixNano() / int64(historyBucketSize)
+	history := make([]HistoryBucket, historyBuckets)
+	for i := range history {
+		interval := current - int64(historyBuckets-1-i)
+		if slot := c.history[interval%historyBuckets]; slot.interval == interval {
+			history[i] = slot.HistoryBucket
+		}
+	}
+	return history
+}
+
+// statusClass groups status codes: 200 -> "2xx"
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "other"
+	}
+	return string(rune('0'+status/100)) + "xx"
+}
+
+// serveMetricsAPI returns per-route traffic counters (GET), and the most
+// recent requests with ?log=N
+func (h *Handler) serveMetricsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	snap := h.metrics.snapshot(time.Now())
+	if limit, err := strconv.Atoi(r.URL.Query().Get("log")); err == nil && limit > 0 {
+		snap.Log = h.metrics.recentRequests(limit)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		slog.Error("failed to encode metrics response", "error", err)
+	}
+}
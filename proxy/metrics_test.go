@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_MetricsAPI(t *testing.T) {
+	backend := newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	for _, path := range []string{"/", "/", "/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://api.localhost"+path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/metrics", nil))
+	var snap MetricsSnapshot
+	if err := json.NewDecoder(w.Body).Decode(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Routes) != 1 {
+		t.Fatalf("metrics for %d routes, want 1", len(snap.Routes))
+	}
+	m := snap.Routes[0]
+	if m.Hostname != "api.localhost" || m.Requests != 3 || m.Recent != 3 {
+		t.Errorf("metrics = %+v", m)
+	}
+	if m.Status["2xx"] != 2 || m.Status["4xx"] != 1 {
+		t.Errorf("status = %v, want 2xx:2 4xx:1", m.Status)
+	}
+	if len(snap.Log) != 0 {
+		t.Errorf("log included without ?log=")
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/metrics?log=2", nil))
+	if err := json.NewDecoder(w.Body).Decode(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Log) != 2 || snap.Log[0].Path != "/missing" || snap.Log[0].Status != http.StatusNotFound {
+		t.Errorf("log = %+v, want the 2 newest requests", snap.Log)
+	}
+}
+
+func TestMetrics_P95(t *testing.T) {
+	m := newMetrics()
+	route := &Route{Hostname: "api.localhost"}
+	for i := 1; i <= 100; i++ {
+		m.observe(route, httptest.NewRequest("GET", "/", nil), http.StatusOK, time.Duration(i)*time.Millisecond)
+	}
+
+	snap := m.snapshot(time.Now())
+	if got := snap.Routes[0].P95; got != 95 {
+		t.Errorf("p95 = %vms, want 95ms", got)
+	}
+
+	// Samples older than the window no longer count
+	snap = m.snapshot(time.Now().Add(2 * latencyWindow))
+	if got := snap.Routes[0]; got.P95 != 0 || got.Recent != 0 || got.Requests != 100 {
+		t.Errorf("after the window: %+v", got)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	tests := map[int]string{200: "2xx", 204: "2xx", 301: "3xx", 404: "4xx", 502: "5xx", 0: "other"}
+	for status, want := range tests {
+		if got := statusClass(status); got != want {
+			t.Errorf("statusClass(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestMetrics_RequestLog(t *testing.T) {
+	m := newMetrics()
+	route := &Route{Hostname: "api.localhost"}
+	for i := range maxRequestLog + 5 {
+		m.observe(route, httptest.NewRequest("GET", "/"+strconv.Itoa(i), nil), http.StatusOK, time.Millisecond)
+	}
+
+	log := m.recentRequests(3)
+	if len(log) != 3 {
+		t.Fatalf("got %d entries, want 3", len(log))
+	}
+	for i, want := range []string{"/204", "/203", "/202"} {
+		if log[i].Path != want {
+			t.Errorf("log[%d] = %s, want %s (newest first)", i, log[i].Path, want)
+		}
+	}
+	if got := len(m.recentRequests(1000)); got != maxRequestLog {
+		t.Errorf("kept %d entries, want %d", got, maxRequestLog)
+	}
+}
+
+func TestMetrics_History(t *testing.T) {
+	m := newMetrics()
+	route := &Route{Hostname: "api.localhost"}
+	for _, status := range []int{http.StatusOK, http.StatusOK, http.StatusInternalServerError, http.StatusBadGateway, http.StatusNotFound} {
+		m.observe(route, httptest.NewRequest("GET", "/", nil), status, time.Millisecond)
+	}
+
+	history := m.snapshot(time.Now()).Routes[0].History
+	if len(history) != historyBuckets {
+		t.Fatalf("got %d buckets, want %d", len(history), historyBuckets)
+	}
+	if got := history[historyBuckets-1]; got != (HistoryBucket{Requests: 5, Errors: 2}) {
+		t.Errorf("current bucket = %+v, want 5 requests and 2 errors", got)
+	}
+
+	// The bucket moves left as time passes, then falls off
+	later := m.snapshot(time.Now().Add(3 * historyBucketSize)).Routes[0].History
+	if got := later[historyBuckets-4]; got.Requests != 5 {
+		t.Errorf("30s later, bucket = %+v, want the 5 requests", got)
+	}
+	gone := m.snapshot(time.Now().Add(historyBuckets * historyBucketSize)).Routes[0].History
+	for i, b := range gone {
+		if b != (HistoryBucket{}) {
+			t.Errorf("after 5 minutes, bucket %d = %+v, want empty", i, b)
+		}
+	}
+}
+
+func TestNewTrafficView(t *testing.T) {
+	if view := newTrafficView(make([]HistoryBucket, historyBuckets)); view != nil {
+		t.Errorf("idle route has a sparkline: %+v", view)
+	}
+
+	history := make([]HistoryBucket, historyBuckets)
+	history[0] = HistoryBucket{Requests: 4}
+	history[historyBuckets-1] = HistoryBucket{Requests: 2, Errors: 2}
+	view := newTrafficView(history)
+	if view.Total != 6 || view.ErrorTotal != 2 || view.Peak != 4 {
+		t.Errorf("view = %+v, want 6 requests, 2 errors, peak 4", view)
+	}
+	points := strings.Fields(view.Requests)
+	if len(points) != historyBuckets || points[0] != "0.0,1.0" || points[historyBuckets-1] != "120.0,12.0" {
+		t.Errorf("request points = %q", view.Requests)
+	}
+	if !strings.HasSuffix(view.Errors, " 120.0,12.0") {
+		t.Errorf("error points = %q, want errors on the request scale", view.Errors)
+	}
+}
+
+func TestHandler_DashboardSparklines(t *testing.T) {
+	backend := newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/boom" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	for _, path := range []string{"/", "/boom"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://api.localhost"+path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/", nil))
+	body := w.Body.String()
+	for _, want := range []string{`class="sparkline"`, `class="spark-errors"`, "1 × 5xx"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard missing %s", want)
+		}
+	}
+}
+
+func TestMetrics_LatencyHistogram(t *testing.T) {
+	m := newMetrics()
+	m.slow = time.Second
+	route := &Route{Hostname: "api.localhost"}
+	for _, d := range []time.Duration{time.Millisecond, 10 * time.Millisecond, 11 * time.Millisecond, time.Second, 3 * time.Second, time.Minute} {
+		m.observe(route, httptest.NewRequest("GET", "/", nil), http.StatusOK, d)
+	}
+
+	rm := m.snapshot(time.Now()).Routes[0]
+	if len(rm.Latency) != len(latencyBounds)+1 {
+		t.Fatalf("got %d buckets, want %d", len(rm.Latency), len(latencyBounds)+1)
+	}
+	want := map[float64]int64{10: 2, 25: 1, 1000: 1, 5000: 1, 0: 1}
+	for _, b := range rm.Latency {
+		if b.Count != want[b.LE] {
+			t.Errorf("bucket le %vms = %d, want %d", b.LE, b.Count, want[b.LE])
+		}
+	}
+	if rm.Slow != 2 {
+		t.Errorf("slow = %d, want 2 (over 1s)", rm.Slow)
+	}
+}
@@ -0,0 +1,209 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kan/roji/config"
+)
+
+// Mock is a canned response for requests to a hostname and path. Mocks are
+// matched before routes, so they also work for hostnames nothing serves yet.
+type Mock struct {
+	Hostname string            `json:"hostname"`         // a name without dots gets the base domain
+	Path     string            `json:"path"`             // exact path, or a prefix ending in "*"
+	Method   string            `json:"method,omitempty"` // default: any method
+	Status   int               `json:"status,omitempty"` // default 200
+	Headers  map[string]string `json:"headers,omitempty"`
+
+	// At most one body source
+	Body string          `json:"body,omitempty"`
+	JSON json.RawMessage `json:"json,omitempty"` // served as application/json
+	File string          `json:"file,omitempty"` // relative to the mocks file; read on every request
+}
+
+func (m *Mock) matches(method, hostname, path string) bool {
+	if m.Hostname != hostname || (m.Method != "" && m.Method != method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(m.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return m.Path == path
+}
+
+// MockSet holds the mocks from a JSON file (--mocks). The file is reloaded
+// when it changes (see Run), so mocks can be edited without restarting roji.
+type MockSet struct {
+	path       string
+	baseDomain string
+
+	mu      sync.RWMutex
+	modTime time.Time // of the loaded file
+	mocks   []Mock
+}
+
+// LoadMocks reads a JSON array of mocks
+func LoadMocks(path, baseDomain string) (*MockSet, error) {
+	s := &MockSet{path: path, baseDomain: baseDomain}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the file if it was modified since the last load. A file
+// that fails to load keeps the current mocks.
+func (s *MockSet) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read mocks: %w", err)
+	}
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read mocks: %w", err)
+	}
+	var mocks []Mock
+	if err := json.Unmarshal(data, &mocks); err != nil {
+		return fmt.Errorf("invalid mocks %s: %w", s.path, err)
+	}
+	for i := range mocks {
+		if err := s.normalize(&mocks[i]); err != nil {
+			return fmt.Errorf("invalid mock #%d in %s: %w", i+1, s.path, err)
+		}
+	}
+	s.mu.Lock()
+	s.mocks = mocks
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+// Run checks the file for changes every interval until ctx is done
+func (s *MockSet) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				slog.Warn("keeping previous mocks", "error", err)
+			}
+		}
+	}
+}
+
+func (s *MockSet) normalize(m *Mock) error {
+	m.Hostname = strings.ToLower(strings.TrimSpace(m.Hostname))
+	if m.Hostname == "" {
+		return fmt.Errorf("hostname is required")
+	}
+	if !strings.Contains(m.Hostname, ".") {
+		m.Hostname = config.DefaultHostname(m.Hostname, s.baseDomain)
+	}
+	if !strings.HasPrefix(m.Path, "/") {
+		return fmt.Errorf("path %q must start with /", m.Path)
+	}
+	m.Method = strings.ToUpper(m.Method)
+	if m.Status == 0 {
+		m.Status = http.StatusOK
+	}
+	if m.Status < 100 || m.Status > 599 {
+		return fmt.Errorf("invalid status %d", m.Status)
+	}
+
+	sources := 0
+	for _, set := range []bool{m.Body != "", len(m.JSON) > 0, m.File != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("%s%s: set only one of body, json and file", m.Hostname, m.Path)
+	}
+	if m.File != "" && !filepath.IsAbs(m.File) {
+		m.File = filepath.Join(filepath.Dir(s.path), m.File)
+	}
+	return nil
+}
+
+// Match returns the first mock for the request, or nil
+func (s *MockSet) Match(method, hostname, path string) *Mock {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.mocks {
+		if s.mocks[i].matches(method, hostname, path) {
+			m := s.mocks[i]
+			return &m
+		}
+	}
+	return nil
+}
+
+// WithMocks serves canned responses ahead of routing
+func WithMocks(s *MockSet) HandlerOption {
+	return func(h *Handler) {
+		h.mocks = s
+	}
+}
+
+// serveMock writes a mock's canned response
+func (h *Handler) serveMock(w http.ResponseWriter, r *http.Request, hostname string, m *Mock) {
+	startTime := time.Now()
+	body := []byte(m.Body)
+	contentType := "text/plain; charset=utf-8"
+	switch {
+	case len(m.JSON) > 0:
+		body, contentType = m.JSON, "application/json"
+	case m.File != "":
+		data, err := os.ReadFile(m.File)
+		if err != nil {
+			slog.Error("failed to read mock body", "hostname", hostname, "path", r.URL.Path, "error", err)
+			http.Error(w, "mock body is unavailable: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body = data
+		if t := mime.TypeByExtension(filepath.Ext(m.File)); t != "" {
+			contentType = t
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	for name, value := range m.Headers {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set("X-Roji-Mock", "true")
+	w.WriteHeader(m.Status)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+
+	h.logRequest(config.AccessLogPolicy{}, r, accessLogEntry{
+		host:     hostname,
+		status:   m.Status,
+		duration: time.Since(startTime),
+		target:   "mock",
+	})
+}
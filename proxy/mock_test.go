@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeMocks(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "mocks.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHandler_Mocks(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "orders.json"), []byte(`[{"id":1}]`), 0o644)
+	path := writeMocks(t, dir, `[
+		{"hostname": "api", "path": "/v2/orders", "method": "get", "file": "orders.json"},
+		{"hostname": "api.localhost", "path": "/v2/payments/*", "status": 503, "headers": {"Retry-After": "5"}, "body": "down"},
+		{"hostname": "web.localhost", "path": "/api/flags", "json": {"beta": true}}
+	]`)
+	mocks, err := LoadMocks(path, "localhost")
+	if err != nil {
+		t.Fatalf("LoadMocks() error = %v", err)
+	}
+
+	backend := newTestBackend(t, "web.localhost", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backend"))
+	}))
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithMocks(mocks))
+
+	tests := []struct {
+		method      string
+		host        string
+		path        string
+		status      int
+		body        string
+		contentType string
+	}{
+		{"GET", "api.localhost", "/v2/orders", http.StatusOK, `[{"id":1}]`, "application/json"},
+		{"POST", "api.localhost", "/v2/orders", http.StatusNotFound, "", ""},
+		{"POST", "api.localhost", "/v2/payments/42", http.StatusServiceUnavailable, "down", "text/plain; charset=utf-8"},
+		{"GET", "web.localhost", "/api/flags", http.StatusOK, `{"beta": true}`, "application/json"},
+		{"GET", "web.localhost", "/api/users", http.StatusOK, "backend", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "https://"+tt.host+tt.path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.status || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s %s%s = %d %q, want %d %q", tt.method, tt.host, tt.path, w.Code, w.Body, tt.status, tt.body)
+		}
+		if tt.contentType != "" && w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s%s Content-Type = %q, want %q", tt.host, tt.path, w.Header().Get("Content-Type"), tt.contentType)
+		}
+	}
+}
+
+func TestMockSet_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeMocks(t, dir, `[{"hostname": "api.localhost", "path": "/a", "body": "one"}]`)
+	mocks, err := LoadMocks(path, "localhost")
+	if err != nil {
+		t.Fatalf("LoadMocks() error = %v", err)
+	}
+
+	writeMocks(t, dir, `[{"hostname": "api.localhost", "path": "/a", "body": "two"}]`)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	if err := mocks.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if m := mocks.Match("GET", "api.localhost", "/a"); m == nil || m.Body != "two" {
+		t.Errorf("Match() after edit = %+v, want body two", m)
+	}
+
+	// A broken edit keeps the previous mocks
+	writeMocks(t, dir, `[{"hostname": "api.localhost"`)
+	os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second))
+	if err := mocks.Reload(); err == nil {
+		t.Error("Reload() of a broken file error = nil")
+	}
+	if m := mocks.Match("GET", "api.localhost", "/a"); m == nil || m.Body != "two" {
+		t.Errorf("Match() after broken edit = %+v, want body two", m)
+	}
+}
+
+func TestHandler_MocksBehindTunnelAuth(t *testing.T) {
+	path := writeMocks(t, t.TempDir(), `[{"hostname": "web.localhost", "path": "/api/flags", "body": "mocked"}]`)
+	mocks, err := LoadMocks(path, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := newTestBackend(t, "web.localhost", nil)
+	backend.TunnelAuth = "user:pass"
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithMocks(mocks))
+
+	req := httptest.NewRequest("GET", "https://web.localhost/api/flags", nil)
+	req.RemoteAddr = "192.0.2.1:50000"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401 before the mock", w.Code)
+	}
+
+	req.SetBasicAuth("user", "pass")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Body.String() != "mocked" {
+		t.Errorf("body = %q, want the mock", w.Body.String())
+	}
+}
+
+func TestHandler_MocksWithoutRouteBehindTunnelAuth(t *testing.T) {
+	path := writeMocks(t, t.TempDir(), `[
+		{"hostname": "web.localhost", "path": "/api/flags", "body": "mocked"},
+		{"hostname": "api.localhost", "path": "/v1/orders", "body": "mocked"}
+	]`)
+	mocks, err := LoadMocks(path, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No route covers /api/flags, and api.localhost has no route at all
+	backend := newTestBackend(t, "web.localhost", nil)
+	backend.PathPrefix = "/app"
+	backend.TunnelAuth = "user:pass"
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithMocks(mocks))
+
+	get := func(url string, setup func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		setup(req)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	if w := get("https://web.localhost/api/flags", func(r *http.Request) { r.RemoteAddr = "192.0.2.1:50000" }); w.Code != http.StatusUnauthorized {
+		t.Errorf("unrouted path from another machine: status = %d, want 401", w.Code)
+	}
+	if w := get("https://web.localhost/api/flags", func(r *http.Request) {
+		r.RemoteAddr = "192.0.2.1:50000"
+		r.SetBasicAuth("user", "pass")
+	}); w.Body.String() != "mocked" {
+		t.Errorf("unrouted path with credentials: body = %q, want the mock", w.Body.String())
+	}
+	if w := get("https://api.localhost/v1/orders", func(r *http.Request) { r.Header.Set("Cf-Ray", "8a1b2c3d4e5f-NRT") }); w.Code != http.StatusNotFound {
+		t.Errorf("hostname without a route through a tunnel: status = %d, want 404", w.Code)
+	}
+	if w := get("https://api.localhost/v1/orders", func(*http.Request) {}); w.Body.String() != "mocked" {
+		t.Errorf("hostname without a route from this machine: body = %q, want the mock", w.Body.String())
+	}
+}
+
+func TestLoadMocks_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"missing hostname": `[{"path": "/a"}]`,
+		"relative path":    `[{"hostname": "api", "path": "a"}]`,
+		"bad status":       `[{"hostname": "api", "path": "/a", "status": 42}]`,
+		"two bodies":       `[{"hostname": "api", "path": "/a", "body": "x", "file": "x.json"}]`,
+		"not an array":     `{"hostname": "api"}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadMocks(writeMocks(t, t.TempDir(), content), "localhost"); err == nil {
+				t.Error("LoadMocks() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestExplain_Mock(t *testing.T) {
+	path := writeMocks(t, t.TempDir(), `[{"hostname": "api.localhost", "path": "/a", "body": "x"}]`)
+	mocks, err := LoadMocks(path, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithMocks(mocks))
+
+	e := handler.Explain(httptest.NewRequest("GET", "https://api.localhost/a", nil))
+	if e.Outcome != OutcomeMock {
+		t.Errorf("Outcome = %q, want %q", e.Outcome, OutcomeMock)
+	}
+}
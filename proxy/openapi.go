@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+)
+
+const (
+	// APIPrefix is the current version of the management API. The
+	// unversioned /_api/ paths stay as aliases of it for existing scripts.
+	APIPrefix = "/_api/v1"
+	// OpenAPIPath serves the OpenAPI 3 specification of the management API
+	OpenAPIPath = "/_api/openapi.json"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// apiPath maps a versioned API path to the endpoint it names:
+// /_api/v1/routes -> /_api/routes
+func apiPath(path string) string {
+	if endpoint, ok := strings.CutPrefix(path, APIPrefix+"/"); ok {
+		return "/_api/" + endpoint
+	}
+	return path
+}
+
+// serveOpenAPI returns the specification (GET)
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(openAPISpec)
+}
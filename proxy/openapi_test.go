@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_OpenAPISpec(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/openapi.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Servers []struct{ URL string }                `json:"servers"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") || len(spec.Servers) != 1 || spec.Servers[0].URL != APIPrefix {
+		t.Errorf("openapi %q, servers %+v", spec.OpenAPI, spec.Servers)
+	}
+
+	// Every documented operation reaches its endpoint: anything but the
+	// unknown endpoint 404 or 405 (disabled features answer 404 in text)
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(strings.ToUpper(method), "https://roji.localhost"+APIPrefix+path, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(w, req)
+			if w.Code == http.StatusMethodNotAllowed || strings.Contains(w.Body.String(), "unknown API endpoint") {
+				t.Errorf("%s %s: %d %s", strings.ToUpper(method), path, w.Code, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestHandler_APIVersions(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {}))
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost"+path, nil))
+		return w
+	}
+
+	// Unversioned paths are aliases of v1
+	v1, alias := get("/_api/v1/routes"), get("/_api/routes")
+	if v1.Code != http.StatusOK || v1.Body.String() != alias.Body.String() {
+		t.Errorf("v1 = %d %q, alias = %q", v1.Code, v1.Body.String(), alias.Body.String())
+	}
+	if w := get("/_api/v1/openapi.json"); w.Code != http.StatusOK {
+		t.Errorf("versioned spec: %d", w.Code)
+	}
+
+	// Unknown versions and endpoints are API errors, not the dashboard
+	for _, path := range []string{"/_api/v2/routes", "/_api/nope", "/_api/v1/"} {
+		if w := get(path); w.Code != http.StatusNotFound || strings.Contains(w.Header().Get("Content-Type"), "html") {
+			t.Errorf("%s: %d %s", path, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+	if w := get("/"); w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Type"), "html") {
+		t.Errorf("dashboard: %d", w.Code)
+	}
+}
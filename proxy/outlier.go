@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+// OutlierConfig controls when replicas of a load-balanced route are ejected
+type OutlierConfig struct {
+	ConsecutiveFailures int           // Failures in a row before a replica is ejected
+	LatencyThreshold    time.Duration // Responses slower than this count as failures (0 = disabled)
+	BaseEjectionTime    time.Duration // First ejection duration; doubles on each repeated ejection
+	MaxEjectionTime     time.Duration // Upper bound for the ejection duration
+	MaxEjectionPercent  int           // Maximum share of a route's replicas that may be ejected at once
+}
+
+// DefaultOutlierConfig returns conservative defaults suitable for local development
+func DefaultOutlierConfig() OutlierConfig {
+	return OutlierConfig{
+		ConsecutiveFailures: 5,
+		BaseEjectionTime:    30 * time.Second,
+		MaxEjectionTime:     5 * time.Minute,
+		MaxEjectionPercent:  50,
+	}
+}
+
+// replicaState tracks the recent behaviour of a single replica
+type replicaState struct {
+	consecutiveFailures int
+	ejections           int // Number of times the replica has been ejected in a row
+	ejectedUntil        time.Time
+}
+
+// OutlierDetector ejects misbehaving replicas from load-balanced routes
+// and re-admits them once their ejection period expires.
+type OutlierDetector struct {
+	cfg OutlierConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	replicas map[string]*replicaState // key: container ID
+}
+
+// NewOutlierDetector creates a new outlier detector
+func NewOutlierDetector(cfg OutlierConfig) *OutlierDetector {
+	return &OutlierDetector{
+		cfg:      cfg,
+		now:      time.Now,
+		replicas: make(map[string]*replicaState),
+	}
+}
+
+// Watch forgets the replicas that leave the route table whenever it
+// changes, until ctx is done
+func (d *OutlierDetector) Watch(ctx context.Context, router *Router) {
+	changed := make(chan struct{}, 1)
+	router.OnChange(func() {
+		select {
+		case changed <- struct{}{}:
+		default: // a prune is already pending
+		}
+	})
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+				d.prune(router)
+			}
+		}
+	}()
+}
+
+// prune drops the state of containers no route has anymore
+func (d *OutlierDetector) prune(router *Router) {
+	current := make(map[string]bool)
+	for _, ri := range router.ListRoutes() {
+		current[ri.ContainerID] = true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id := range d.replicas {
+		if !current[id] {
+			delete(d.replicas, id)
+		}
+	}
+}
+
+// IsEjected reports whether the replica is currently ejected
+func (d *OutlierDetector) IsEjected(containerID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.replicas[containerID]
+	return ok && d.now().Before(state.ejectedUntil)
+}
+
+// Select returns the first candidate that is not ejected.
+// If every candidate is ejected, the first one is returned so the route keeps serving.
+func (d *OutlierDetector) Select(candidates []*docker.Backend) *docker.Backend {
+	if len(candidates) == 0 {
+		return nil
+	}
+	for _, b := range candidates {
+		if !d.IsEjected(b.ContainerID) {
+			return b
+		}
+	}
+	return candidates[0]
+}
+
+// Report records the outcome of a request to a replica of the route.
+// failed should be true for connection errors and 5xx responses.
+func (d *OutlierDetector) Report(route *Route, backend *docker.Backend, failed bool, latency time.Duration) {
+	// Outlier detection only makes sense when there is another replica to fall back to
+	if len(route.Replicas) < 2 {
+		return
+	}
+
+	slow := d.cfg.LatencyThreshold > 0 && latency > d.cfg.LatencyThreshold
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	state, ok := d.replicas[backend.ContainerID]
+	if !ok {
+		state = &replicaState{}
+		d.replicas[backend.ContainerID] = state
+	}
+
+	if !failed && !slow {
+		if state.ejections > 0 && !now.Before(state.ejectedUntil) {
+			slog.Info("outlier re-admitted",
+				"hostname", route.Hostname,
+				"container", backend.ContainerName)
+			state.ejections = 0
+		}
+		state.consecutiveFailures = 0
+		return
+	}
+
+	state.consecutiveFailures++
+	if state.consecutiveFailures < d.cfg.ConsecutiveFailures || now.Before(state.ejectedUntil) {
+		return
+	}
+
+	// Respect the ejection cap so a route never loses all of its capacity
+	ejected := 0
+	for _, b := range route.Replicas {
+		if s, ok := d.replicas[b.ContainerID]; ok && now.Before(s.ejectedUntil) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > d.cfg.MaxEjectionPercent*len(route.Replicas) {
+		slog.Warn("outlier ejection skipped (max ejection percent reached)",
+			"hostname", route.Hostname,
+			"container", backend.ContainerName,
+			"ejected", ejected,
+			"replicas", len(route.Replicas))
+		return
+	}
+
+	duration := d.cfg.BaseEjectionTime << state.ejections
+	if d.cfg.MaxEjectionTime > 0 && (duration > d.cfg.MaxEjectionTime || duration <= 0) {
+		duration = d.cfg.MaxEjectionTime
+	}
+	state.ejections++
+	state.consecutiveFailures = 0
+	state.ejectedUntil = now.Add(duration)
+
+	reason := "errors"
+	if slow && !failed {
+		reason = "latency"
+	}
+	slog.Warn("outlier ejected",
+		"hostname", route.Hostname,
+		"container", backend.ContainerName,
+		"reason", reason,
+		"duration", duration,
+		"ejections", state.ejections)
+}
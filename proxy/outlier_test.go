@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+func newReplicaRoute(n int) *Route {
+	var replicas []*docker.Backend
+	for i := 0; i < n; i++ {
+		replicas = append(replicas, &docker.Backend{
+			ContainerID:   string(rune('a' + i)),
+			ContainerName: "web-" + string(rune('1'+i)),
+			ServiceName:   "web",
+		})
+	}
+	return newRoute("web.localhost", "", replicas)
+}
+
+func TestOutlierDetector_EjectsAfterConsecutiveFailures(t *testing.T) {
+	cfg := DefaultOutlierConfig()
+	cfg.ConsecutiveFailures = 3
+	d := NewOutlierDetector(cfg)
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	route := newReplicaRoute(2)
+	bad := route.Replicas[0]
+
+	for i := 0; i < 2; i++ {
+		d.Report(route, bad, true, 0)
+	}
+	if d.IsEjected(bad.ContainerID) {
+		t.Fatal("replica ejected before reaching the failure threshold")
+	}
+
+	d.Report(route, bad, true, 0)
+	if !d.IsEjected(bad.ContainerID) {
+		t.Fatal("expected replica to be ejected")
+	}
+
+	// Selection skips the ejected replica
+	if got := d.Select(route.Replicas); got.ContainerID != route.Replicas[1].ContainerID {
+		t.Errorf("Select() = %q, want healthy replica %q", got.ContainerID, route.Replicas[1].ContainerID)
+	}
+
+	// Re-admitted after the ejection time passes
+	now = now.Add(cfg.BaseEjectionTime + time.Second)
+	if d.IsEjected(bad.ContainerID) {
+		t.Error("expected replica to be re-admitted after the ejection period")
+	}
+}
+
+func TestOutlierDetector_SuccessResetsFailures(t *testing.T) {
+	cfg := DefaultOutlierConfig()
+	cfg.ConsecutiveFailures = 2
+	d := NewOutlierDetector(cfg)
+
+	route := newReplicaRoute(2)
+	b := route.Replicas[0]
+
+	d.Report(route, b, true, 0)
+	d.Report(route, b, false, 0)
+	d.Report(route, b, true, 0)
+	if d.IsEjected(b.ContainerID) {
+		t.Error("non-consecutive failures should not eject")
+	}
+}
+
+func TestOutlierDetector_Latency(t *testing.T) {
+	cfg := DefaultOutlierConfig()
+	cfg.ConsecutiveFailures = 1
+	cfg.LatencyThreshold = 100 * time.Millisecond
+	d := NewOutlierDetector(cfg)
+
+	route := newReplicaRoute(2)
+	b := route.Replicas[0]
+
+	d.Report(route, b, false, 50*time.Millisecond)
+	if d.IsEjected(b.ContainerID) {
+		t.Fatal("fast response should not eject")
+	}
+	d.Report(route, b, false, time.Second)
+	if !d.IsEjected(b.ContainerID) {
+		t.Error("slow response should eject when latency threshold is set")
+	}
+}
+
+func TestOutlierDetector_MaxEjectionPercent(t *testing.T) {
+	cfg := DefaultOutlierConfig()
+	cfg.ConsecutiveFailures = 1
+	d := NewOutlierDetector(cfg)
+
+	route := newReplicaRoute(2)
+	d.Report(route, route.Replicas[0], true, 0)
+	d.Report(route, route.Replicas[1], true, 0)
+
+	if !d.IsEjected(route.Replicas[0].ContainerID) {
+		t.Error("expected first replica to be ejected")
+	}
+	if d.IsEjected(route.Replicas[1].ContainerID) {
+		t.Error("second replica should not be ejected beyond the 50% cap")
+	}
+}
+
+func TestOutlierDetector_SingleReplicaNeverEjected(t *testing.T) {
+	cfg := DefaultOutlierConfig()
+	cfg.ConsecutiveFailures = 1
+	d := NewOutlierDetector(cfg)
+
+	route := newReplicaRoute(1)
+	d.Report(route, route.Replicas[0], true, 0)
+	if d.IsEjected(route.Replicas[0].ContainerID) {
+		t.Error("single replica routes should never eject")
+	}
+}
+
+func TestOutlierDetector_ForgetsRemovedReplicas(t *testing.T) {
+	route := newReplicaRoute(2)
+	router := NewRouter()
+	for _, b := range route.Replicas {
+		b.Hostname, b.Host, b.Port = "web.localhost", "127.0.0.1", 1
+		router.AddBackend(b)
+	}
+	d := NewOutlierDetector(DefaultOutlierConfig())
+	d.Watch(t.Context(), router)
+	for _, b := range route.Replicas {
+		d.Report(route, b, true, 0)
+	}
+
+	count := func() int {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.replicas)
+	}
+	router.RemoveBackend(route.Replicas[0].ContainerID)
+	deadline := time.Now().Add(2 * time.Second)
+	for count() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("tracking %d replicas after one was removed, want 1", count())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
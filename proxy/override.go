@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kan/roji/config"
+)
+
+// overrideFile returns the local file overriding a request path
+// (roji.overrides). Paths are matched as the browser sees them, before any
+// route prefix is stripped.
+func overrideFile(overrides []config.Override, urlPath string) (string, bool) {
+	for _, o := range overrides {
+		if prefix, ok := strings.CutSuffix(o.Path, "*"); ok {
+			if rest, ok := strings.CutPrefix(urlPath, prefix); ok {
+				return filepath.Join(o.File, filepath.FromSlash(path.Clean("/"+rest))), true
+			}
+			continue
+		}
+		if o.Path == urlPath {
+			return o.File, true
+		}
+	}
+	return "", false
+}
+
+// WithOverridesDir allows roji.overrides files below dir (--overrides-dir),
+// except below the excluded directories (the certificates). Without it,
+// overrides are off: a label could otherwise serve any file roji can read.
+func WithOverridesDir(dir string, excluded ...string) HandlerOption {
+	return func(h *Handler) {
+		h.overridesDir = resolvePath(dir)
+		for _, e := range excluded {
+			h.overridesExcluded = append(h.overridesExcluded, resolvePath(e))
+		}
+	}
+}
+
+// resolvePath makes path absolute and follows its symlinks, as far as they
+// exist, so containment checks see the real file
+func resolvePath(path string) string {
+	if path == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// withinDir reports whether path is dir or below it
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// overrideTarget returns the existing local file that replaces the backend's
+// response, or "". Missing files fall through to the backend, so a directory
+// override only replaces the files it contains. Files outside --overrides-dir
+// (after following symlinks) are refused.
+func (h *Handler) overrideTarget(route *Route, r *http.Request) string {
+	if len(route.Backend.Overrides) == 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return ""
+	}
+	file, ok := overrideFile(route.Backend.Overrides, r.URL.Path)
+	if !ok {
+		return ""
+	}
+	if h.overridesDir == "" {
+		slog.Debug("roji.overrides ignored: no --overrides-dir", "host", route.Hostname)
+		return ""
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(h.overridesDir, file)
+	}
+	real := resolvePath(file)
+	if real == "" || !withinDir(real, h.overridesDir) {
+		slog.Warn("override outside --overrides-dir refused", "host", route.Hostname, "file", file)
+		return ""
+	}
+	for _, excluded := range h.overridesExcluded {
+		if excluded != "" && withinDir(real, excluded) {
+			slog.Warn("override of a certificate file refused", "host", route.Hostname, "file", file)
+			return ""
+		}
+	}
+	if info, err := os.Stat(real); err != nil || info.IsDir() {
+		return ""
+	}
+	return real
+}
+
+// serveOverride serves a local file in place of the backend, revalidated on
+// every request so local rebuilds show up on reload. X-Roji-Override names
+// the file relative to --overrides-dir: responses may go out through a
+// tunnel, and the absolute path would show the machine's directory layout.
+func (h *Handler) serveOverride(w http.ResponseWriter, r *http.Request, route *Route, file string) {
+	startTime := time.Now()
+
+	f, err := os.Open(file)
+	if err != nil {
+		slog.Error("failed to open override", "file", file, "error", err)
+		http.Error(w, "override is unavailable: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "override is unavailable: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if rel, err := filepath.Rel(h.overridesDir, file); err == nil {
+		w.Header().Set("X-Roji-Override", filepath.ToSlash(rel))
+	}
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	http.ServeContent(rec, r, info.Name(), info.ModTime(), f)
+
+	h.logRequest(route.Backend.AccessLog, r, accessLogEntry{
+		host:     route.Hostname,
+		status:   rec.status,
+		duration: time.Since(startTime),
+		target:   file,
+	})
+	h.metrics.observe(route, r, rec.status, time.Since(startTime))
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kan/roji/config"
+)
+
+func TestHandler_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.js"), []byte("local main"), 0o644)
+	os.MkdirAll(filepath.Join(dir, "css"), 0o755)
+	os.WriteFile(filepath.Join(dir, "css", "app.css"), []byte("local css"), 0o644)
+
+	backend := newTestBackend(t, "shop.localhost", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backend " + r.URL.Path))
+	}))
+	backend.Overrides = []config.Override{
+		{Path: "/assets/main.js", File: filepath.Join(dir, "main.js")},
+		{Path: "/assets/css/*", File: filepath.Join(dir, "css")},
+		{Path: "/assets/gone.js", File: filepath.Join(dir, "gone.js")},
+	}
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithOverridesDir(dir))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", "/assets/main.js", "local main"},
+		{"GET", "/assets/css/app.css", "local css"},
+		{"GET", "/assets/css/../../main.js", "backend /assets/css/../../main.js"},
+		{"GET", "/assets/css/other.css", "backend /assets/css/other.css"},
+		{"GET", "/assets/gone.js", "backend /assets/gone.js"},
+		{"POST", "/assets/main.js", "backend /assets/main.js"},
+		{"GET", "/", "backend /"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "https://shop.localhost/", nil)
+		req.URL.Path = tt.path
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Body.String() != tt.body {
+			t.Errorf("%s %s = %q, want %q", tt.method, tt.path, w.Body, tt.body)
+		}
+	}
+
+	// The header doesn't reveal where --overrides-dir is
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://shop.localhost/assets/css/app.css", nil))
+	if got := w.Header().Get("X-Roji-Override"); got != "css/app.css" {
+		t.Errorf("X-Roji-Override = %q, want css/app.css", got)
+	}
+}
+
+func TestHandler_OverridesConfined(t *testing.T) {
+	dir := t.TempDir()
+	certs := filepath.Join(dir, "certs")
+	os.MkdirAll(certs, 0o755)
+	os.WriteFile(filepath.Join(certs, "ca-key.pem"), []byte("secret"), 0o600)
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("local app"), 0o644)
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "passwd"), []byte("secret"), 0o644)
+	os.Symlink(filepath.Join(outside, "passwd"), filepath.Join(dir, "link"))
+
+	backend := newTestBackend(t, "shop.localhost", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backend"))
+	}))
+	backend.Overrides = []config.Override{
+		{Path: "/key", File: filepath.Join(certs, "ca-key.pem")},
+		{Path: "/passwd", File: filepath.Join(outside, "passwd")},
+		{Path: "/link", File: filepath.Join(dir, "link")},
+		{Path: "/app.js", File: "app.js"},
+	}
+	router := NewRouter()
+	router.AddBackend(backend)
+
+	tests := []struct {
+		name string
+		opts []HandlerOption
+		path string
+		body string
+	}{
+		{"no --overrides-dir", nil, "/app.js", "backend"},
+		{"relative to the dir", []HandlerOption{WithOverridesDir(dir, certs)}, "/app.js", "local app"},
+		{"certs dir", []HandlerOption{WithOverridesDir(dir, certs)}, "/key", "backend"},
+		{"outside the dir", []HandlerOption{WithOverridesDir(dir, certs)}, "/passwd", "backend"},
+		{"symlink out of the dir", []HandlerOption{WithOverridesDir(dir, certs)}, "/link", "backend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(router, "roji.localhost", testStatusConfig(), tt.opts...)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "https://shop.localhost"+tt.path, nil))
+			if w.Body.String() != tt.body {
+				t.Errorf("GET %s = %q, want %q", tt.path, w.Body, tt.body)
+			}
+		})
+	}
+}
+
+func TestOverrideFile(t *testing.T) {
+	overrides := []config.Override{
+		{Path: "/app.js", File: "/o/app.js"},
+		{Path: "/static/*", File: "/o/static"},
+	}
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"/app.js", "/o/app.js", true},
+		{"/app.js.map", "", false},
+		{"/static/js/x.js", "/o/static/js/x.js", true},
+		{"/static/../../etc/passwd", "/o/static/etc/passwd", true},
+		{"/other", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := overrideFile(overrides, tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("overrideFile(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHandler_ServesAssets(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithAPIToken("s3cret"))
+
+	// On any hostname, without the API token
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://app.localhost/_roji/assets/page.css", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("page.css = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+
+	req := httptest.NewRequest("GET", "https://roji.localhost/_roji/assets/page.css", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("revalidation = %d, want 304", w.Code)
+	}
+
+	for _, p := range []string{"/_roji/assets/missing.css", "/_roji/assets/../templates/dashboard.html", "/_roji/assets/"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost"+p, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s = %d, want 404", p, w.Code)
+		}
+	}
+}
+
+func TestTemplates_AssetsExist(t *testing.T) {
+	ref := regexp.MustCompile(`href="/_roji/assets/([^"]+)"`)
+	pages, err := fs.Glob(templateFS, "templates/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range pages {
+		data, err := fs.ReadFile(templateFS, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs := ref.FindAllStringSubmatch(string(data), -1)
+		if len(refs) == 0 {
+			t.Errorf("%s links no stylesheet", page)
+		}
+		for _, m := range refs {
+			if _, err := fs.Stat(assetFS, path.Join("assets", m[1])); err != nil {
+				t.Errorf("%s links missing asset %s", page, m[1])
+			}
+		}
+	}
+}
+
+func TestHandler_DashboardView(t *testing.T) {
+	backend := newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {})
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	view := handler.dashboardView()
+	if len(view.Routes) != 1 || view.Routes[0].Hostname != "api.localhost" || view.LAN != nil || view.CanRotate {
+		t.Errorf("view = %+v", view)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "https://api.localhost") || strings.Contains(w.Body.String(), "LAN mode") {
+		t.Errorf("dashboard = %d", w.Code)
+	}
+}
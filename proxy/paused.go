@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/kan/roji/docker"
+)
+
+// Paused reports whether every replica of the route is paused (docker pause)
+func (rt *Route) Paused() bool {
+	for _, b := range rt.Replicas {
+		if !b.Paused {
+			return false
+		}
+	}
+	return true
+}
+
+// unpaused returns the candidates that can answer requests
+func unpaused(candidates []*docker.Backend) []*docker.Backend {
+	active := candidates[:0:0]
+	for _, b := range candidates {
+		if !b.Paused {
+			active = append(active, b)
+		}
+	}
+	return active
+}
+
+// servePaused answers for a paused route instead of letting the request hang
+func (h *Handler) servePaused(w http.ResponseWriter, r *http.Request, route *Route) {
+	data := struct {
+		Hostname      string
+		ContainerName string
+		DashboardHost string
+	}{
+		Hostname:      route.Hostname,
+		ContainerName: route.Backend.ContainerName,
+		DashboardHost: h.dashboardHost,
+	}
+
+	slog.Debug("route is paused", "hostname", route.Hostname, "path", r.URL.Path)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", "5")
+	renderPage(w, http.StatusServiceUnavailable, "paused.html", data)
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kan/roji/docker"
+)
+
+func TestHandler_PausedRoute(t *testing.T) {
+	backend := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	backend.ContainerName = "web-1"
+	backend.Paused = true
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "https://web.localhost/", nil)
+		req.Host = "web.localhost"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get()
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if !strings.Contains(w.Body.String(), "docker unpause web-1") {
+		t.Errorf("body should explain how to unpause, got %q", w.Body.String())
+	}
+	if routes := router.ListRoutes(); len(routes) != 1 || !routes[0].Paused {
+		t.Errorf("ListRoutes() = %+v, want the route marked paused", routes)
+	}
+
+	// Unpause: the refreshed backend serves again
+	unpaused := *backend
+	unpaused.Paused = false
+	router.ReplaceContainer(backend.ContainerID, []*docker.Backend{&unpaused})
+	if w := get(); w.Code != http.StatusOK {
+		t.Errorf("status after unpause = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestHandler_PausedReplicaSkipped(t *testing.T) {
+	paused := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("paused"))
+	})
+	paused.ContainerID, paused.ContainerName, paused.Paused = "web1", "app-web-1", true
+	active := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("active"))
+	})
+	active.ContainerID, active.ContainerName = "web2", "app-web-2"
+
+	router := NewRouter()
+	router.AddBackend(paused)
+	router.AddBackend(active)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	for range 3 {
+		req := httptest.NewRequest("GET", "https://web.localhost/", nil)
+		req.Host = "web.localhost"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if got := w.Body.String(); got != "active" {
+			t.Fatalf("body = %q, want the unpaused replica", got)
+		}
+	}
+}
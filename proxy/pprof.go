@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// PprofPath serves Go runtime profiles of roji itself with --enable-pprof
+const PprofPath = "/debug/pprof/"
+
+// WithPprof serves net/http/pprof on the dashboard host, behind --api-token
+// like the dashboard, to profile roji under heavy WebSocket or file
+// transfer load:
+//
+//	go tool pprof https://dev.localhost/debug/pprof/profile?seconds=30
+//
+// The CPU profile and the execution trace slow roji down while they run, so
+// they are only served with --api-token.
+func WithPprof() HandlerOption {
+	return func(h *Handler) {
+		mux := http.NewServeMux()
+		mux.HandleFunc(PprofPath, pprof.Index)
+		mux.HandleFunc(PprofPath+"cmdline", pprof.Cmdline)
+		mux.HandleFunc(PprofPath+"profile", h.needsAPIToken(pprof.Profile))
+		mux.HandleFunc(PprofPath+"symbol", pprof.Symbol)
+		mux.HandleFunc(PprofPath+"trace", h.needsAPIToken(pprof.Trace))
+		h.pprof = mux
+	}
+}
+
+// needsAPIToken refuses requests unless --api-token is set; the dashboard
+// guard has checked the token by then
+func (h *Handler) needsAPIToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.apiToken == "" {
+			http.Error(w, "the CPU profile and the trace need --api-token (ROJI_API_TOKEN)", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
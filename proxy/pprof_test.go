@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_Pprof(t *testing.T) {
+	router := NewRouter()
+
+	// Off by default: the path falls through to the dashboard
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/debug/pprof/", nil))
+	if strings.Contains(w.Body.String(), "goroutine") {
+		t.Error("pprof index served without WithPprof")
+	}
+
+	handler = NewHandler(router, "roji.localhost", testStatusConfig(), WithPprof())
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/debug/pprof/", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("index = %d, want 200 listing profiles", w.Code)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/debug/pprof/goroutine?debug=1", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine profile") {
+		t.Errorf("goroutine profile = %d: %.80s", w.Code, w.Body)
+	}
+
+	// The CPU profile and the trace need a token
+	for _, path := range []string{"profile?seconds=1", "trace?seconds=0.01"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/debug/pprof/"+path, nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s without --api-token = %d, want 403", path, w.Code)
+		}
+	}
+
+	// Only on the dashboard host
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://web.localhost/debug/pprof/", nil))
+	if strings.Contains(w.Body.String(), "goroutine") {
+		t.Error("pprof served on a route hostname")
+	}
+
+	// Behind the API token like the dashboard
+	handler = NewHandler(router, "roji.localhost", testStatusConfig(), WithPprof(), WithAPIToken("s3cret"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/debug/pprof/", nil))
+	if strings.Contains(w.Body.String(), "goroutine") {
+		t.Error("pprof served without the API token")
+	}
+	req := httptest.NewRequest("GET", "https://roji.localhost/debug/pprof/", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("index with token = %d, want 200", w.Code)
+	}
+	req = httptest.NewRequest("GET", "https://roji.localhost/debug/pprof/trace?seconds=0.01", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("trace with token = %d, want 200", w.Code)
+	}
+}
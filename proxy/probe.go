@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+// Backend health states shown as the dashboard's status dots
+const (
+	HealthUp       = "up"       // green
+	HealthDegraded = "degraded" // yellow: slow, or an error status from roji.health-path
+	HealthDown     = "down"     // red: unreachable
+)
+
+const (
+	// probeTimeout bounds each probe; slower backends are down
+	probeTimeout = 5 * time.Second
+	// probeSlow is the latency above which an answering backend is degraded
+	probeSlow = time.Second
+)
+
+// BackendHealth is the result of the latest probe of a route target
+type BackendHealth struct {
+	State   string        `json:"state"`
+	Checked time.Time     `json:"checked"`
+	Latency time.Duration `json:"latency_ns"`
+	Probe   string        `json:"probe"` // e.g., "tcp 172.18.0.5:3000" or "GET http://172.18.0.5:3000/healthz"
+	Error   string        `json:"error,omitempty"`
+}
+
+// LatencyText rounds the latency for the dashboard
+func (bh BackendHealth) LatencyText() string {
+	return bh.Latency.Round(time.Millisecond).String()
+}
+
+// HealthProber periodically checks every route target: a TCP connect, or a
+// GET of roji.health-path. Static routes check their directory.
+type HealthProber struct {
+	router   *Router
+	interval time.Duration
+	client   *http.Client
+	events   *EventHub // optional; receives backend_unhealthy and backend_healthy
+
+	mu      sync.Mutex
+	results map[string]BackendHealth // key: RouteInfo.Target
+}
+
+// NewHealthProber creates a prober for the router's backends
+func NewHealthProber(router *Router, interval time.Duration) *HealthProber {
+	return &HealthProber{
+		router:   router,
+		interval: interval,
+		client: &http.Client{
+			Timeout: probeTimeout,
+			// A redirect (e.g., to a login page) means the app is up
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		results: make(map[string]BackendHealth),
+	}
+}
+
+// WithHealthProber shows probe results on the dashboard and /_api/routes
+func WithHealthProber(p *HealthProber) HandlerOption {
+	return func(h *Handler) {
+		h.prober = p
+	}
+}
+
+// PublishTo sends health changes of backends to the event hub
+func (p *HealthProber) PublishTo(hub *EventHub) {
+	p.events = hub
+}
+
+// Run probes all backends now and every interval until ctx is done
+func (p *HealthProber) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.ProbeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeAll checks every route target once, concurrently. Results of targets
+// that are no longer routed are dropped.
+func (p *HealthProber) ProbeAll(ctx context.Context) {
+	targets := make(map[string]*docker.Backend)
+	for _, b := range p.router.Backends() {
+		if b.RedirectTo != "" || b.Paused {
+			continue // aliases aren't dialed; paused containers have a badge
+		}
+		targets[routeTarget(b)] = b
+	}
+
+	results := make(map[string]BackendHealth, len(targets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for target, b := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := p.probe(ctx, b)
+			mu.Lock()
+			results[target] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var events []Event
+	p.mu.Lock()
+	for target, result := range results {
+		prev, ok := p.results[target]
+		if ok && prev.State != result.State {
+			slog.Info("backend health changed", "target", target, "from", prev.State, "to", result.State, "error", result.Error)
+		}
+		// A backend that is down from its first probe is reported too
+		wasUp := !ok || prev.State == HealthUp
+		if wasUp != (result.State == HealthUp) {
+			event := Event{Type: EventBackendHealthy, Hostname: targets[target].Hostname, PathPrefix: targets[target].PathPrefix, Target: target, Health: &result}
+			if result.State != HealthUp {
+				event.Type = EventBackendUnhealthy
+			}
+			events = append(events, event)
+		}
+	}
+	p.results = results
+	p.mu.Unlock()
+
+	for _, e := range events {
+		p.events.Publish(e)
+	}
+}
+
+// Result returns the latest probe of a route target
+func (p *HealthProber) Result(target string) (BackendHealth, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result, ok := p.results[target]
+	return result, ok
+}
+
+// probe checks one backend
+func (p *HealthProber) probe(ctx context.Context, b *docker.Backend) BackendHealth {
+	start := time.Now()
+	result := BackendHealth{State: HealthUp, Checked: start}
+
+	switch {
+	case b.StaticDir != "":
+		result.Probe = "stat " + b.StaticDir
+		if info, err := os.Stat(b.StaticDir); err != nil {
+			result.State, result.Error = HealthDown, err.Error()
+		} else if !info.IsDir() {
+			result.State, result.Error = HealthDown, "not a directory"
+		}
+
+	case b.HealthPath != "":
+		u := upstreamURL(b).JoinPath(b.HealthPath)
+		result.Probe = "GET " + u.String()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			result.State, result.Error = HealthDown, err.Error()
+			break
+		}
+		if b.ExternalURL == "" {
+			req.Host = b.Hostname
+		}
+		req.Header.Set("User-Agent", "roji-health-check")
+		resp, err := p.client.Do(req)
+		if err != nil {
+			result.State, result.Error = HealthDown, err.Error()
+			break
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			result.State, result.Error = HealthDegraded, resp.Status
+		}
+
+	default:
+		u := upstreamURL(b)
+		addr := net.JoinHostPort(u.Hostname(), strconv.Itoa(urlPort(u)))
+		result.Probe = "tcp " + addr
+		dialer := net.Dialer{Timeout: probeTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			result.State, result.Error = HealthDown, err.Error()
+			break
+		}
+		conn.Close()
+	}
+
+	result.Latency = time.Since(start)
+	if result.State == HealthUp && result.Latency > probeSlow {
+		result.State, result.Error = HealthDegraded, fmt.Sprintf("slow: %s", result.LatencyText())
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kan/roji/docker"
+)
+
+func TestHealthProber_States(t *testing.T) {
+	healthy := newTestBackend(t, "healthy.localhost", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || r.Host != "healthy.localhost" {
+			http.NotFound(w, r)
+		}
+	})
+	healthy.ContainerID, healthy.HealthPath = "healthy1", "/healthz"
+
+	failing := newTestBackend(t, "failing.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	failing.ContainerID, failing.HealthPath = "failing1", "/healthz"
+
+	listening := newTestBackend(t, "tcp.localhost", func(w http.ResponseWriter, r *http.Request) {})
+	listening.ContainerID = "tcp1"
+
+	// A port nothing listens on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	stopped := &docker.Backend{ContainerID: "stopped1", Hostname: "stopped.localhost", Host: "127.0.0.1", Port: closedPort}
+
+	static := &docker.Backend{ContainerID: "static:site", Hostname: "site.localhost", StaticDir: t.TempDir()}
+	missing := &docker.Backend{ContainerID: "static:gone", Hostname: "gone.localhost", StaticDir: filepath.Join(t.TempDir(), "gone")}
+
+	router := NewRouter()
+	for _, b := range []*docker.Backend{healthy, failing, listening, stopped, static, missing} {
+		router.AddBackend(b)
+	}
+	prober := NewHealthProber(router, 0)
+	prober.ProbeAll(context.Background())
+
+	tests := []struct {
+		backend *docker.Backend
+		state   string
+		probe   string
+	}{
+		{healthy, HealthUp, "GET http://"},
+		{failing, HealthDegraded, "GET http://"},
+		{listening, HealthUp, "tcp "},
+		{stopped, HealthDown, "tcp "},
+		{static, HealthUp, "stat "},
+		{missing, HealthDown, "stat "},
+	}
+	for _, tt := range tests {
+		result, ok := prober.Result(routeTarget(tt.backend))
+		if !ok {
+			t.Errorf("%s: not probed", tt.backend.Hostname)
+			continue
+		}
+		if result.State != tt.state || !strings.HasPrefix(result.Probe, tt.probe) {
+			t.Errorf("%s: %s via %q (%s), want %s via %s...", tt.backend.Hostname, result.State, result.Probe, result.Error, tt.state, tt.probe)
+		}
+		if (result.State == HealthUp) != (result.Error == "") {
+			t.Errorf("%s: state %s with error %q", tt.backend.Hostname, result.State, result.Error)
+		}
+	}
+
+	// Removed routes drop out on the next round
+	router.RemoveBackend("stopped1")
+	prober.ProbeAll(context.Background())
+	if _, ok := prober.Result(routeTarget(stopped)); ok {
+		t.Error("removed backend still has a result")
+	}
+}
+
+func TestHandler_RoutesIncludeHealth(t *testing.T) {
+	backend := newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {})
+	router := NewRouter()
+	router.AddBackend(backend)
+	prober := NewHealthProber(router, 0)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithHealthProber(prober))
+
+	// Not probed yet: no health
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/routes", nil))
+	var routes []RouteInfo
+	if err := json.NewDecoder(w.Body).Decode(&routes); err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0].Health != nil {
+		t.Fatalf("routes before probing = %+v", routes)
+	}
+
+	prober.ProbeAll(context.Background())
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/routes", nil))
+	if err := json.NewDecoder(w.Body).Decode(&routes); err != nil {
+		t.Fatal(err)
+	}
+	if routes[0].Health == nil || routes[0].Health.State != HealthUp {
+		t.Errorf("health = %+v, want up", routes[0].Health)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/", nil))
+	if !strings.Contains(w.Body.String(), `class="health health-up"`) {
+		t.Error("dashboard doesn't show the health dot")
+	}
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// QRPath is the dashboard page with a QR code per route, for opening routes
+// on a phone
+const QRPath = "/qr"
+
+// CAPath serves the CA certificate on every hostname, so devices can install
+// it before they trust roji (or are paired in LAN mode)
+const CAPath = "/_roji/ca.crt"
+
+// qrQuietZone is the blank border scanners need around a code, in modules
+const qrQuietZone = 4
+
+// DeviceLinks are the URLs other devices open routes on: under the LAN
+// address in LAN mode, otherwise the routes' own hostnames
+type DeviceLinks struct {
+	Routes []DeviceLink `json:"routes"`
+	CAURL  string       `json:"ca_url,omitempty"` // "" without a generated CA
+}
+
+// DeviceLink is the URL of one route on another device
+type DeviceLink struct {
+	Hostname    string `json:"hostname"`
+	PathPrefix  string `json:"path_prefix,omitempty"`
+	ServiceName string `json:"service_name,omitempty"`
+	URL         string `json:"url"`
+}
+
+// qrView is what qr.html renders
+type qrView struct {
+	Routes []qrCode
+	CA     *qrCode // nil without a generated CA
+	LAN    bool    // URLs use the LAN address
+}
+
+// qrCode is one scannable link
+type qrCode struct {
+	Label string
+	URL   string
+	SVG   template.HTML
+}
+
+// deviceLinks lists the URLs of the routes as other devices reach them.
+// Aliases are left out; they redirect to a listed route.
+func (h *Handler) deviceLinks() DeviceLinks {
+	links := DeviceLinks{Routes: []DeviceLink{}}
+	seen := make(map[string]bool)
+	for _, r := range h.router.ListRoutes() {
+		if r.RedirectTo != "" || seen[r.Hostname+r.PathPrefix] {
+			continue
+		}
+		seen[r.Hostname+r.PathPrefix] = true
+		links.Routes = append(links.Routes, DeviceLink{
+			Hostname:    r.Hostname,
+			PathPrefix:  r.PathPrefix,
+			ServiceName: r.ServiceName,
+			URL:         h.deviceURL(h.deviceHostname(r.Hostname)) + r.PathPrefix + "/",
+		})
+	}
+	if h.hasCA() {
+		dashboard := h.dashboardHost
+		if h.lanIP != "" {
+			dashboard = h.lanIP
+		}
+		links.CAURL = h.deviceURL(dashboard) + CAPath
+	}
+	return links
+}
+
+// deviceHostname returns the hostname other devices reach a route on: its
+// nip.io name under the LAN address in LAN mode (see WithLANHostnames)
+func (h *Handler) deviceHostname(hostname string) string {
+	if h.lanIP == "" {
+		return hostname
+	}
+	return EmulatorHostname(hostname, h.statusConfig.BaseDomain, h.lanIP)
+}
+
+// deviceURL returns the origin of a hostname on roji's public port
+func (h *Handler) deviceURL(hostname string) string {
+	scheme, port, defaultPort := "https", h.statusConfig.HTTPSPort, 443
+	if h.statusConfig.NoTLS {
+		scheme, port, defaultPort = "http", h.statusConfig.HTTPPort, 80
+	}
+	if port != 0 && port != defaultPort {
+		hostname += ":" + strconv.Itoa(port)
+	}
+	return scheme + "://" + hostname
+}
+
+// hasCA reports whether roji generated a CA that devices can install
+func (h *Handler) hasCA() bool {
+	if h.statusConfig.NoTLS || !h.statusConfig.AutoGenerated {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(h.statusConfig.CertsDir, "ca.crt"))
+	return err == nil
+}
+
+// serveQR renders the QR code page of the dashboard
+func (h *Handler) serveQR(w http.ResponseWriter, r *http.Request) {
+	links := h.deviceLinks()
+	view := qrView{LAN: h.lanIP != ""}
+	for _, link := range links.Routes {
+		code, err := newQRCode(link.Hostname+link.PathPrefix, link.URL)
+		if err != nil {
+			slog.Warn("failed to encode QR code", "url", link.URL, "error", err)
+			continue
+		}
+		view.Routes = append(view.Routes, code)
+	}
+	if links.CAURL != "" {
+		if code, err := newQRCode("roji CA certificate", links.CAURL); err == nil {
+			view.CA = &code
+		}
+	}
+	renderPage(w, http.StatusOK, "qr.html", view)
+}
+
+// serveQRAPI returns the device URLs behind the QR codes (roji qr)
+func (h *Handler) serveQRAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.deviceLinks()); err != nil {
+		slog.Error("failed to encode QR links", "error", err)
+	}
+}
+
+// serveCA offers the CA certificate for download. It is public; only the
+// key (ca-key.pem) must stay on this machine.
+func (h *Handler) serveCA(w http.ResponseWriter, r *http.Request) {
+	if !h.hasCA() {
+		http.Error(w, "roji has no generated CA certificate", http.StatusNotFound)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(h.statusConfig.CertsDir, "ca.crt"))
+	if err != nil {
+		slog.Warn("failed to read CA certificate", "error", err)
+		http.Error(w, "failed to read the CA certificate", http.StatusInternalServerError)
+		return
+	}
+	// DER with this type opens the certificate installer on iOS and Android
+	w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+	w.Header().Set("Content-Disposition", `attachment; filename="roji-ca.crt"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// newQRCode encodes url as an SVG QR code
+func newQRCode(label, url string) (qrCode, error) {
+	svg, err := qrSVG(url)
+	if err != nil {
+		return qrCode{}, err
+	}
+	return qrCode{Label: label, URL: url, SVG: svg}, nil
+}
+
+// qrSVG draws text as a QR code, one path segment per dark module, with the
+// quiet zone around it
+func qrSVG(text string) (template.HTML, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return "", err
+	}
+	size := code.Size + 2*qrQuietZone
+
+	var path strings.Builder
+	for y := range code.Size {
+		for x := range code.Size {
+			if code.Black(x, y) {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+qrQuietZone, y+qrQuietZone)
+			}
+		}
+	}
+	return template.HTML(fmt.Sprintf(
+		`<svg class="qr" viewBox="0 0 %d %d" shape-rendering="crispEdges" role="img"><rect width="%d" height="%d" fill="#fff"/><path d="%s" fill="#000"/></svg>`,
+		size, size, size, size, path.String())), nil
+}
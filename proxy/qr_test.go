@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandler_DeviceLinks(t *testing.T) {
+	certsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(certsDir, "ca.crt"), []byte("der"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	statusConfig := testStatusConfig()
+	statusConfig.CertsDir = certsDir
+	statusConfig.HTTPSPort = 8443
+
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "web.app.localhost", nil))
+
+	tests := []struct {
+		name      string
+		opts      []HandlerOption
+		wantRoute string
+		wantCA    string
+	}{
+		{"route hostnames", nil, "https://web.app.localhost:8443/", "https://roji.localhost:8443/_roji/ca.crt"},
+		{"LAN address", []HandlerOption{WithLANHostnames("192.168.1.20", "laptop.local")},
+			"https://web.app.192.168.1.20.nip.io:8443/", "https://192.168.1.20:8443/_roji/ca.crt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(router, "roji.localhost", statusConfig, tt.opts...)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/v1/qr", nil))
+			var links DeviceLinks
+			if err := json.NewDecoder(w.Body).Decode(&links); err != nil {
+				t.Fatal(err)
+			}
+			if len(links.Routes) != 1 || links.Routes[0].URL != tt.wantRoute {
+				t.Errorf("routes = %+v, want %s", links.Routes, tt.wantRoute)
+			}
+			if links.CAURL != tt.wantCA {
+				t.Errorf("CA URL = %q, want %q", links.CAURL, tt.wantCA)
+			}
+		})
+	}
+}
+
+func TestHandler_QRPage(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "web.app.localhost", nil))
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/qr", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `<svg class="qr"`) || !strings.Contains(body, "https://web.app.localhost/") {
+		t.Errorf("page lacks the route's QR code:\n%s", body)
+	}
+}
+
+func TestHandler_ServeCA(t *testing.T) {
+	certsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(certsDir, "ca.crt"), []byte("der"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	statusConfig := testStatusConfig()
+	statusConfig.CertsDir = certsDir
+
+	devices, err := NewDeviceAllowList(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(NewRouter(), "roji.localhost", statusConfig, WithLANAccess(devices))
+
+	// Unpaired devices can download it from any hostname
+	req := httptest.NewRequest("GET", "https://web.192.168.1.20.nip.io/_roji/ca.crt", nil)
+	req.RemoteAddr = "192.168.1.50:51000"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "der" {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/x-x509-ca-cert" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	// Without a generated CA there is nothing to download
+	statusConfig.AutoGenerated = false
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_roji/ca.crt", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestQRSVG(t *testing.T) {
+	svg, err := qrSVG("https://web.app.localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Version 2 (25 modules) plus the quiet zone on both sides
+	if !strings.Contains(string(svg), `viewBox="0 0 33 33"`) {
+		t.Errorf("unexpected size: %s", svg)
+	}
+}
@@ -0,0 +1,366 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kan/roji/config"
+)
+
+const (
+	// maxRecordedExchanges is how many requests each hostname keeps
+	maxRecordedExchanges = 100
+	// maxRecordedBody limits how much of each body is kept (roji.record=bodies)
+	maxRecordedBody = 64 << 10 // 64 KiB
+	// dashboardRequests is how many exchanges the dashboard inspector lists
+	dashboardRequests = 20
+)
+
+// RecordedBody is a captured request or response body
+type RecordedBody struct {
+	Data      string `json:"data,omitempty"`
+	Encoding  string `json:"encoding,omitempty"` // "base64" for binary or compressed bodies
+	Size      int64  `json:"size"`               // bytes seen, including those not kept
+	Truncated bool   `json:"truncated,omitempty"`
+	Omitted   bool   `json:"omitted,omitempty"` // not kept (roji.record without bodies)
+}
+
+// redactedHeaders carry credentials, which recordings don't keep
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", TunnelSecretHeader}
+
+// redactHeaders returns a copy of header with credential values replaced
+func redactHeaders(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range redactedHeaders {
+		if values := header[name]; len(values) > 0 {
+			header[name] = []string{config.RedactedValue}
+		}
+	}
+	return header
+}
+
+// RecordedExchange is one request and response of a roji.record route
+type RecordedExchange struct {
+	ID         uint64        `json:"id"`
+	Time       time.Time     `json:"time"`
+	Hostname   string        `json:"hostname"`
+	Method     string        `json:"method"`
+	URL        string        `json:"url"` // path and query
+	Status     int           `json:"status"`
+	Duration   time.Duration `json:"duration_ns"`
+	Target     string        `json:"target"` // container that answered
+	ClientAddr string        `json:"client"`
+
+	RequestHeaders  http.Header  `json:"request_headers"`
+	RequestBody     RecordedBody `json:"request_body"`
+	ResponseHeaders http.Header  `json:"response_headers"`
+	ResponseBody    RecordedBody `json:"response_body"`
+}
+
+// RequestHead formats the request line and headers for the inspector
+func (e RecordedExchange) RequestHead() string {
+	return e.Method + " " + e.URL + "\n" + formatHeaders(e.RequestHeaders)
+}
+
+// ResponseHead formats the status line and headers for the inspector
+func (e RecordedExchange) ResponseHead() string {
+	return strconv.Itoa(e.Status) + " " + http.StatusText(e.Status) + "\n" + formatHeaders(e.ResponseHeaders)
+}
+
+// Text returns the body for the inspector; binary bodies are summarized
+func (b RecordedBody) Text() string {
+	switch {
+	case b.Size == 0:
+		return ""
+	case b.Omitted:
+		return fmt.Sprintf("(%d bytes, not recorded; roji.record=bodies keeps them)", b.Size)
+	case b.Encoding != "":
+		return fmt.Sprintf("(%d bytes, binary or compressed)", b.Size)
+	case b.Truncated:
+		return b.Data + fmt.Sprintf("\n… (%d of %d bytes)", len(b.Data), b.Size)
+	}
+	return b.Data
+}
+
+// DurationText rounds the duration for the inspector
+func (e RecordedExchange) DurationText() string {
+	return e.Duration.Round(time.Millisecond).String()
+}
+
+func formatHeaders(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(&b, "%s: %s\n", name, value)
+		}
+	}
+	return b.String()
+}
+
+// Recorder keeps the most recent exchanges of roji.record routes, per
+// hostname, in memory
+type Recorder struct {
+	mu        sync.Mutex
+	nextID    uint64
+	exchanges map[string][]RecordedExchange // hostname -> newest last
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{exchanges: make(map[string][]RecordedExchange)}
+}
+
+// WithRecorder enables capture on roji.record routes and /_api/requests
+func WithRecorder(rec *Recorder) HandlerOption {
+	return func(h *Handler) {
+		h.recorder = rec
+	}
+}
+
+func (rec *Recorder) add(e RecordedExchange) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.nextID++
+	e.ID = rec.nextID
+	list := append(rec.exchanges[e.Hostname], e)
+	if len(list) > maxRecordedExchanges {
+		list = list[len(list)-maxRecordedExchanges:]
+	}
+	rec.exchanges[e.Hostname] = list
+}
+
+// Recent returns up to limit exchanges, newest first, for one hostname or
+// all of them ("")
+func (rec *Recorder) Recent(hostname string, limit int) []RecordedExchange {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	var all []RecordedExchange
+	for host, list := range rec.exchanges {
+		if hostname == "" || host == hostname {
+			all = append(all, list...)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+	if limit > 0 && len(all) > limit {
+		all = all[:limit]
+	}
+	return all
+}
+
+// Get returns one exchange by ID
+func (rec *Recorder) Get(id uint64) (RecordedExchange, bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	for _, list := range rec.exchanges {
+		for _, e := range list {
+			if e.ID == id {
+				return e, true
+			}
+		}
+	}
+	return RecordedExchange{}, false
+}
+
+// Clear forgets the exchanges of one hostname or all of them ("")
+func (rec *Recorder) Clear(hostname string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if hostname == "" {
+		clear(rec.exchanges)
+		return
+	}
+	delete(rec.exchanges, hostname)
+}
+
+// recording captures one exchange while it is proxied
+type recording struct {
+	rec      *Recorder
+	exchange RecordedExchange
+	start    time.Time
+	reqBody  *bodyCapture
+	writer   *recordingWriter
+}
+
+// startRecording wraps the request body and response writer of a
+// roji.record route; finish stores the exchange
+func (h *Handler) startRecording(w http.ResponseWriter, r *http.Request, route *Route, hostname string) (http.ResponseWriter, *http.Request, *recording) {
+	if h.recorder == nil || !route.Backend.Record {
+		return w, r, nil
+	}
+	rc := &recording{
+		rec:   h.recorder,
+		start: time.Now(),
+		exchange: RecordedExchange{
+			Time:           time.Now(),
+			Hostname:       hostname,
+			Method:         r.Method,
+			URL:            r.URL.RequestURI(),
+			ClientAddr:     hostWithoutPort(r.RemoteAddr),
+			RequestHeaders: redactHeaders(r.Header),
+		},
+	}
+	limit := 0
+	if route.Backend.RecordBodies {
+		limit = maxRecordedBody
+	}
+	rc.reqBody = &bodyCapture{limit: limit}
+	if r.Body != nil && r.Body != http.NoBody {
+		r = r.Clone(r.Context())
+		r.Body = &teeBody{ReadCloser: r.Body, capture: rc.reqBody}
+	}
+	rc.writer = &recordingWriter{ResponseWriter: w, status: http.StatusOK, body: bodyCapture{limit: limit}}
+	return rc.writer, r, rc
+}
+
+// finish stores the exchange once the response is written
+func (rc *recording) finish(target string) {
+	e := rc.exchange
+	e.Duration = time.Since(rc.start)
+	e.Target = target
+	e.Status = rc.writer.status
+	e.ResponseHeaders = redactHeaders(rc.writer.Header())
+	e.RequestBody = rc.reqBody.recorded(e.RequestHeaders.Get("Content-Encoding"))
+	e.ResponseBody = rc.writer.body.recorded(e.ResponseHeaders.Get("Content-Encoding"))
+	rc.rec.add(e)
+}
+
+// bodyCapture keeps the first limit bytes of a body and counts the rest. The
+// transport may still be reading a request body when the response is done.
+type bodyCapture struct {
+	limit int
+
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	size int64
+}
+
+func (c *bodyCapture) Write(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.size += int64(len(p))
+	if room := c.limit - c.buf.Len(); room > 0 {
+		c.buf.Write(p[:min(len(p), room)])
+	}
+}
+
+func (c *bodyCapture) recorded(contentEncoding string) RecordedBody {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	body := RecordedBody{Size: c.size}
+	if c.limit == 0 {
+		body.Omitted = c.size > 0
+		return body
+	}
+	body.Truncated = c.size > int64(c.buf.Len())
+	data := c.buf.Bytes()
+	if contentEncoding == "" && utf8.Valid(data) {
+		body.Data = string(data)
+	} else if len(data) > 0 {
+		body.Data = base64.StdEncoding.EncodeToString(data)
+		body.Encoding = "base64"
+	}
+	return body
+}
+
+// teeBody captures a request body as the backend reads it
+type teeBody struct {
+	io.ReadCloser
+	capture *bodyCapture
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	t.capture.Write(p[:n])
+	return n, err
+}
+
+// recordingWriter captures the status and body of a response
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bodyCapture
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// serveRequestsAPI lists recorded exchanges (GET ?hostname=&limit=), returns
+// one (GET ?id=) or clears them (DELETE ?hostname=)
+func (h *Handler) serveRequestsAPI(w http.ResponseWriter, r *http.Request) {
+	if h.recorder == nil {
+		http.Error(w, "request recording is disabled", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		var result any
+		if idParam := query.Get("id"); idParam != "" {
+			id, err := strconv.ParseUint(idParam, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			e, ok := h.recorder.Get(id)
+			if !ok {
+				http.Error(w, "no recorded request "+idParam, http.StatusNotFound)
+				return
+			}
+			result = e
+		} else {
+			limit, _ := strconv.Atoi(query.Get("limit"))
+			exchanges := h.recorder.Recent(strings.ToLower(query.Get("hostname")), limit)
+			if exchanges == nil {
+				exchanges = []RecordedExchange{}
+			}
+			result = exchanges
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.Error("failed to encode requests response", "error", err)
+		}
+
+	case http.MethodDelete:
+		h.recorder.Clear(strings.ToLower(query.Get("hostname")))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_RecordsExchanges(t *testing.T) {
+	backend := newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"echo":` + string(body) + `}`))
+	})
+	backend.Record, backend.RecordBodies = true, true
+	quiet := newTestBackend(t, "quiet.localhost", func(w http.ResponseWriter, r *http.Request) {})
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	router.AddBackend(quiet)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithRecorder(NewRecorder()))
+
+	req := httptest.NewRequest("POST", "https://api.localhost/orders?x=1", strings.NewReader(`{"n":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || w.Body.String() != `{"echo":{"n":1}}` {
+		t.Fatalf("proxied response = %d %q", w.Code, w.Body)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://quiet.localhost/", nil))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/requests", nil))
+	var exchanges []RecordedExchange
+	if err := json.NewDecoder(w.Body).Decode(&exchanges); err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != 1 {
+		t.Fatalf("recorded %d exchanges, want only the roji.record route's", len(exchanges))
+	}
+	e := exchanges[0]
+	if e.Method != "POST" || e.URL != "/orders?x=1" || e.Status != http.StatusCreated {
+		t.Errorf("exchange = %s %s %d", e.Method, e.URL, e.Status)
+	}
+	if e.RequestBody.Data != `{"n":1}` || e.ResponseBody.Data != `{"echo":{"n":1}}` {
+		t.Errorf("bodies = %q, %q", e.RequestBody.Data, e.ResponseBody.Data)
+	}
+	if e.RequestHeaders.Get("Content-Type") != "application/json" || e.ResponseHeaders.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v, %v", e.RequestHeaders, e.ResponseHeaders)
+	}
+
+	// The dashboard lists it
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/", nil))
+	if !strings.Contains(w.Body.String(), "POST api.localhost/orders?x=1") {
+		t.Error("dashboard doesn't show the recorded request")
+	}
+
+	// DELETE clears
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "https://roji.localhost/_api/requests", nil))
+	if got := handler.recorder.Recent("", 0); len(got) != 0 {
+		t.Errorf("after DELETE, %d exchanges remain", len(got))
+	}
+}
+
+func TestHandler_RecordRedactsCredentials(t *testing.T) {
+	backend := newTestBackend(t, "api.localhost", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret"})
+		w.Write([]byte("private"))
+	})
+	backend.Record = true
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithRecorder(NewRecorder()))
+
+	req := httptest.NewRequest("POST", "https://api.localhost/login", strings.NewReader("password=hunter2"))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Cookie", "session=old")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	exchanges := handler.recorder.Recent("", 0)
+	if len(exchanges) != 1 {
+		t.Fatalf("recorded %d exchanges, want 1", len(exchanges))
+	}
+	e := exchanges[0]
+	for _, value := range []string{e.RequestHeaders.Get("Authorization"), e.RequestHeaders.Get("Cookie"), e.ResponseHeaders.Get("Set-Cookie")} {
+		if value != "(set)" {
+			t.Errorf("credential header recorded as %q", value)
+		}
+	}
+	if req.Header.Get("Authorization") != "Bearer token" {
+		t.Error("redaction changed the proxied request")
+	}
+	// Without roji.record=bodies, only sizes are kept
+	if e.RequestBody.Data != "" || !e.RequestBody.Omitted || e.ResponseBody.Data != "" || e.ResponseBody.Size != int64(len("private")) {
+		t.Errorf("bodies = %+v, %+v, want sizes only", e.RequestBody, e.ResponseBody)
+	}
+}
+
+func TestRecorder_Bounds(t *testing.T) {
+	rec := NewRecorder()
+	for range maxRecordedExchanges + 5 {
+		rec.add(RecordedExchange{Hostname: "a.localhost"})
+	}
+	rec.add(RecordedExchange{Hostname: "b.localhost"})
+
+	if got := len(rec.Recent("a.localhost", 0)); got != maxRecordedExchanges {
+		t.Errorf("a.localhost keeps %d exchanges, want %d", got, maxRecordedExchanges)
+	}
+	recent := rec.Recent("", 2)
+	if len(recent) != 2 || recent[0].Hostname != "b.localhost" {
+		t.Errorf("Recent(\"\", 2) = %+v, want newest first", recent)
+	}
+	if _, ok := rec.Get(recent[1].ID); !ok {
+		t.Error("Get() can't find a listed exchange")
+	}
+
+	c := bodyCapture{limit: maxRecordedBody}
+	c.Write(make([]byte, maxRecordedBody+10))
+	if body := c.recorded(""); !body.Truncated || body.Size != maxRecordedBody+10 || len(body.Data) != maxRecordedBody {
+		t.Errorf("large body: truncated %v, size %d, kept %d", body.Truncated, body.Size, len(body.Data))
+	}
+	gz := bodyCapture{limit: maxRecordedBody}
+	gz.Write([]byte("hello"))
+	if body := gz.recorded("gzip"); body.Encoding != "base64" {
+		t.Errorf("compressed body encoding = %q, want base64", body.Encoding)
+	}
+}
@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kan/roji/docker"
+)
+
+// locationHeaders name URLs the browser follows or resolves against
+var locationHeaders = []string{"Location", "Content-Location"}
+
+// rewriteLocations points Location and Content-Location headers that name
+// the backend's internal address (http://web:3000/..., http://172.18.0.5/...)
+// at the public hostname, and upgrades ones naming the public hostname over
+// plain HTTP on HTTPS requests. Apps that ignore X-Forwarded-* emit both.
+func rewriteLocations(resp *http.Response, route *Route, backend *docker.Backend, upstream *url.URL, r *http.Request) {
+	if backend.KeepRedirects {
+		return
+	}
+	for _, name := range locationHeaders {
+		value := resp.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" {
+			continue // relative URLs already resolve against the public hostname
+		}
+
+		switch {
+		case isInternalHost(u, backend, upstream):
+			// The backend saw the path without the route's prefix (and an
+			// external route's URL path)
+			path := u.Path
+			if prefix := strings.TrimSuffix(upstream.Path, "/"); prefix != "" {
+				path = stripPathPrefix(path, prefix)
+			}
+			if route.PathPrefix != "" {
+				path = strings.TrimSuffix(route.PathPrefix, "/") + path
+			}
+			u.Path, u.RawPath = path, ""
+		case u.Scheme == "http" && r.TLS != nil && strings.EqualFold(u.Hostname(), hostWithoutPort(r.Host)):
+		default:
+			continue
+		}
+		u.Scheme = forwardedProto(r)
+		u.Host = r.Host
+		resp.Header.Set(name, u.String())
+	}
+}
+
+// isInternalHost reports whether a URL names the backend the way it sees
+// itself: its upstream address, container or service name, or localhost on
+// its own port
+func isInternalHost(u *url.URL, backend *docker.Backend, upstream *url.URL) bool {
+	if strings.EqualFold(u.Host, upstream.Host) {
+		return true
+	}
+	if backend.ExternalURL != "" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	switch host {
+	case "localhost", "127.0.0.1", "::1", "0.0.0.0":
+		return urlPort(u) == backend.Port
+	case strings.ToLower(backend.Host), strings.ToLower(backend.ContainerName), strings.ToLower(backend.ServiceName):
+		return host != ""
+	}
+	return false
+}
+
+// urlPort returns the port of a URL, defaulting by scheme
+func urlPort(u *url.URL) int {
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		return port
+	}
+	if u.Scheme == "https" {
+		return 443
+	}
+	return 80
+}
+
+// describeInternalHosts lists the hosts rewriteLocations recognises, for
+// roji debug route
+func describeInternalHosts(backend *docker.Backend, upstream *url.URL) []string {
+	hosts := []string{upstream.Host}
+	if backend.ExternalURL != "" {
+		return hosts
+	}
+	for _, name := range []string{backend.ContainerName, backend.ServiceName} {
+		if name != "" && name != backend.Host {
+			hosts = append(hosts, name)
+		}
+	}
+	return append(hosts, net.JoinHostPort("localhost", strconv.Itoa(backend.Port)))
+}
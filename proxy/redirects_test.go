@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kan/roji/docker"
+)
+
+func TestRewriteLocations(t *testing.T) {
+	backend := &docker.Backend{
+		ContainerName: "shop-web-1",
+		ServiceName:   "web",
+		Host:          "172.18.0.5",
+		Port:          3000,
+		Hostname:      "shop.localhost",
+	}
+	tests := []struct {
+		name     string
+		location string
+		prefix   string
+		want     string
+	}{
+		{"container address", "http://172.18.0.5:3000/login?next=%2F", "", "https://shop.localhost/login?next=%2F"},
+		{"service name", "http://web/login", "", "https://shop.localhost/login"},
+		{"container name", "http://shop-web-1:3000/", "", "https://shop.localhost/"},
+		{"localhost on the backend port", "http://localhost:3000/a", "", "https://shop.localhost/a"},
+		{"public hostname over http", "http://shop.localhost/a", "", "https://shop.localhost/a"},
+		{"stripped route prefix", "http://web:3000/login", "/api", "https://shop.localhost/api/login"},
+		{"relative", "/login", "", "/login"},
+		{"other localhost port", "http://localhost:5173/", "", "http://localhost:5173/"},
+		{"elsewhere", "https://accounts.example.com/oauth", "", "https://accounts.example.com/oauth"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "https://shop.localhost/", nil)
+			r.TLS = &tls.ConnectionState{}
+			resp := &http.Response{Header: http.Header{}}
+			resp.Header.Set("Location", tt.location)
+			resp.Header.Set("Content-Location", tt.location)
+			route := &Route{Hostname: "shop.localhost", PathPrefix: tt.prefix, Backend: backend}
+
+			rewriteLocations(resp, route, backend, upstreamURL(backend), r)
+			for _, name := range locationHeaders {
+				if got := resp.Header.Get(name); got != tt.want {
+					t.Errorf("%s = %q, want %q", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteLocations_Kept(t *testing.T) {
+	backend := &docker.Backend{ServiceName: "web", Host: "172.18.0.5", Port: 3000, KeepRedirects: true}
+	resp := &http.Response{Header: http.Header{"Location": {"http://web/login"}}}
+	rewriteLocations(resp, &Route{Backend: backend}, backend, upstreamURL(backend),
+		httptest.NewRequest("GET", "https://shop.localhost/", nil))
+	if got := resp.Header.Get("Location"); got != "http://web/login" {
+		t.Errorf("Location = %q, want it untouched (roji.rewrite-redirects=false)", got)
+	}
+}
+
+func TestHandler_RewritesBackendRedirects(t *testing.T) {
+	var self *url.URL
+	backend := newTestBackend(t, "shop.localhost", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+self.Host+"/login", http.StatusFound)
+	})
+	self = upstreamURL(backend)
+
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	req := httptest.NewRequest("GET", "https://shop.localhost/account", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get("Location"); got != "https://shop.localhost/login" {
+		t.Errorf("Location = %q, want https://shop.localhost/login", got)
+	}
+}
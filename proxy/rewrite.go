@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kan/roji/docker"
+)
+
+// rewritesHTML reports whether roji edits the backend's HTML responses
+// (roji.esi, roji.banner, --banner, roji.live-reload); the Director then
+// asks for bodies roji can decode
+func (h *Handler) rewritesHTML(b *docker.Backend) bool {
+	return b.ESI || h.routeBanner(b) != "" || h.injectsLiveReload(b)
+}
+
+// acceptsGzip reports whether the client's Accept-Encoding allows gzip
+func acceptsGzip(header http.Header) bool {
+	for _, coding := range strings.Split(header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// rewritableHTML reports whether a response body can be edited: HTML that
+// is uncompressed or gzipped
+func rewritableHTML(resp *http.Response) bool {
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "", "identity", "gzip":
+	default:
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/html"
+}
+
+// bodyless reports whether a response has no body by definition: one to a
+// HEAD request, 1xx, 204 No Content or 304 Not Modified. Its headers (e.g.,
+// Content-Length, Content-Encoding) describe a body that isn't sent.
+func bodyless(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return true
+	}
+	return resp.StatusCode < 200 || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified
+}
+
+// fullPage reports whether a response carries a whole page: a 200 with a
+// body. Partial content (206) and bodyless responses mustn't get markup
+// added to them.
+func fullPage(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusOK && !bodyless(resp)
+}
+
+// rewriteHTML replaces the body of an HTML response with rewrite(body).
+// Gzipped bodies are decompressed for rewrite and compressed again; other
+// responses, bodyless ones and empty bodies are left untouched.
+func rewriteHTML(resp *http.Response, rewrite func([]byte) []byte) error {
+	if !rewritableHTML(resp) || bodyless(resp) {
+		return nil
+	}
+	gzipped := strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip")
+
+	raw, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if len(raw) == 0 {
+		resp.Body = http.NoBody
+		return nil
+	}
+	body := raw
+	if gzipped {
+		zr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return fmt.Errorf("failed to decompress response body: %w", err)
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return fmt.Errorf("failed to decompress response body: %w", err)
+		}
+	}
+
+	body = rewrite(body)
+	if gzipped {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to compress response body: %w", err)
+		}
+		body = buf.Bytes()
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// insertBeforeBodyEnd inserts markup just before the last </body>, or at the
+// end of documents without one
+func insertBeforeBodyEnd(body, markup []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(body), []byte("</body"))
+	if i < 0 {
+		return append(body, markup...)
+	}
+	out := make([]byte, 0, len(body)+len(markup))
+	out = append(out, body[:i]...)
+	out = append(out, markup...)
+	return append(out, body[i:]...)
+}
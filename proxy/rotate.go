@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// HostnameRotator moves routes to fresh origins for the management API
+type HostnameRotator interface {
+	// RotateHostname moves the route at hostname to a new hostname
+	// (e.g., web.app.localhost -> web-g1.app.localhost) and returns it
+	RotateHostname(ctx context.Context, hostname string) (string, error)
+}
+
+// WithHostnameRotator enables the /_api/rotate endpoint and the dashboard's
+// "new origin" action
+func WithHostnameRotator(rot HostnameRotator) HandlerOption {
+	return func(h *Handler) {
+		h.rotator = rot
+	}
+}
+
+// rotateRequest is the body of POST /_api/rotate
+type rotateRequest struct {
+	Hostname string `json:"hostname"`
+}
+
+// rotateResponse reports the route's new hostname
+type rotateResponse struct {
+	Hostname    string `json:"hostname"`
+	NewHostname string `json:"new_hostname"`
+}
+
+// serveRotateAPI rotates a route's hostname to force a clean browser origin.
+// It accepts a JSON body, or a form post from the dashboard (which is
+// redirected back to the dashboard); browsers may only post from the
+// dashboard (see crossOrigin).
+func (h *Handler) serveRotateAPI(w http.ResponseWriter, r *http.Request) {
+	if h.rotator == nil {
+		http.Error(w, "hostname rotation is disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Browsers can be made to post here from any page; only accept them from
+	// the dashboard itself
+	if h.crossOrigin(r) {
+		http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+		return
+	}
+
+	fromDashboard := strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+
+	var req rotateRequest
+	if fromDashboard {
+		req.Hostname = r.PostFormValue("hostname")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	hostname := strings.ToLower(strings.TrimSpace(req.Hostname))
+	if hostname == "" {
+		http.Error(w, "hostname is required", http.StatusBadRequest)
+		return
+	}
+	if !h.hasRoute(hostname) {
+		http.Error(w, "no route for hostname "+hostname, http.StatusNotFound)
+		return
+	}
+
+	newHostname, err := h.rotator.RotateHostname(r.Context(), hostname)
+	if err != nil {
+		slog.Error("failed to rotate hostname", "hostname", hostname, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("hostname rotated", "hostname", hostname, "new_hostname", newHostname)
+
+	if fromDashboard {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rotateResponse{Hostname: hostname, NewHostname: newHostname}); err != nil {
+		slog.Error("failed to encode rotate response", "error", err)
+	}
+}
+
+// hasRoute reports whether any route is served on hostname
+func (h *Handler) hasRoute(hostname string) bool {
+	for _, route := range h.router.ListRoutes() {
+		if route.Hostname == hostname {
+			return true
+		}
+	}
+	return false
+}
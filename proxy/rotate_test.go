@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kan/roji/docker"
+)
+
+type fakeRotator struct {
+	rotated []string
+}
+
+func (f *fakeRotator) RotateHostname(ctx context.Context, hostname string) (string, error) {
+	f.rotated = append(f.rotated, hostname)
+	return "web-g1.localhost", nil
+}
+
+func newRotateTestHandler(rot HostnameRotator) *Handler {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "web1", Hostname: "web.localhost", Host: "172.18.0.2", Port: 80})
+	return NewHandler(router, "roji.localhost", testStatusConfig(), WithHostnameRotator(rot))
+}
+
+func TestHandler_RotateAPI(t *testing.T) {
+	rot := &fakeRotator{}
+	handler := newRotateTestHandler(rot)
+
+	req := httptest.NewRequest("POST", "https://roji.localhost/_api/rotate", strings.NewReader(`{"hostname":"Web.localhost"}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var resp rotateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Hostname != "web.localhost" || resp.NewHostname != "web-g1.localhost" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	// Unknown hostnames are rejected
+	req = httptest.NewRequest("POST", "https://roji.localhost/_api/rotate", strings.NewReader(`{"hostname":"nope.localhost"}`))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d for unknown hostname, want %d", w.Code, http.StatusNotFound)
+	}
+	if len(rot.rotated) != 1 {
+		t.Errorf("rotated = %v, want one rotation", rot.rotated)
+	}
+}
+
+func TestHandler_RotateAPI_DashboardForm(t *testing.T) {
+	rot := &fakeRotator{}
+	handler := newRotateTestHandler(rot)
+
+	form := url.Values{"hostname": {"web.localhost"}}.Encode()
+
+	req := httptest.NewRequest("POST", "https://roji.localhost/_api/rotate", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "https://roji.localhost")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+
+	// Cross-site form posts are rejected
+	req = httptest.NewRequest("POST", "https://roji.localhost/_api/rotate", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d for cross-origin post, want %d", w.Code, http.StatusForbidden)
+	}
+	if len(rot.rotated) != 1 {
+		t.Errorf("rotated = %v, want one rotation", rot.rotated)
+	}
+}
+
+func TestHandler_RotateAPI_CrossSiteJSON(t *testing.T) {
+	rot := &fakeRotator{}
+	handler := newRotateTestHandler(rot)
+
+	// A cross-site page can post a JSON body as text/plain without a preflight
+	for _, header := range [][2]string{
+		{"Origin", "https://evil.example"},
+		{"Origin", "null"},
+		{"Sec-Fetch-Site", "cross-site"},
+	} {
+		req := httptest.NewRequest("POST", "https://roji.localhost/_api/rotate", strings.NewReader(`{"hostname":"web.localhost"}`))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set(header[0], header[1])
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: %s: status = %d, want %d", header[0], header[1], w.Code, http.StatusForbidden)
+		}
+	}
+	if len(rot.rotated) != 0 {
+		t.Errorf("rotated = %v, want none", rot.rotated)
+	}
+}
+
+func TestHandler_RotateAPI_Disabled(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig())
+
+	req := httptest.NewRequest("POST", "https://roji.localhost/_api/rotate", strings.NewReader(`{"hostname":"web.localhost"}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -3,10 +3,13 @@ package proxy
 import (
 	"fmt"
 	"log/slog"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 
+	"github.com/kan/roji/config"
 	"github.com/kan/roji/docker"
 )
 
@@ -14,7 +17,88 @@ import (
 type Route struct {
 	Hostname   string
 	PathPrefix string
-	Backend    *docker.Backend
+	Backend    *docker.Backend   // Primary backend (first replica)
+	Replicas   []*docker.Backend // All backends serving this route, including Backend
+
+	next *atomic.Uint64 // Round-robin cursor shared across replica selections
+}
+
+// newRoute creates a route serving the given replicas
+func newRoute(hostname, pathPrefix string, replicas []*docker.Backend) *Route {
+	return &Route{
+		Hostname:   hostname,
+		PathPrefix: pathPrefix,
+		Backend:    replicas[0],
+		Replicas:   replicas,
+		next:       new(atomic.Uint64),
+	}
+}
+
+// Candidates returns the replicas in round-robin order for this request.
+// Callers should try them in order, skipping any that are unavailable.
+func (rt *Route) Candidates() []*docker.Backend {
+	if len(rt.Replicas) <= 1 || rt.next == nil {
+		return rt.Replicas
+	}
+	start := int(rt.next.Add(1)-1) % len(rt.Replicas)
+	ordered := make([]*docker.Backend, 0, len(rt.Replicas))
+	ordered = append(ordered, rt.Replicas[start:]...)
+	ordered = append(ordered, rt.Replicas[:start]...)
+	return ordered
+}
+
+// isReplicaOf reports whether two backends are replicas of the same scaled service
+func isReplicaOf(a, b *docker.Backend) bool {
+	return a.ServiceName != "" && a.ServiceName == b.ServiceName && a.ProjectName == b.ProjectName
+}
+
+// withBackend returns a copy of the route with the backend added as a replica.
+// A backend for the same container replaces the existing replica entry.
+func (rt *Route) withBackend(backend *docker.Backend) *Route {
+	replicas := make([]*docker.Backend, 0, len(rt.Replicas)+1)
+	for _, b := range rt.Replicas {
+		if b.ContainerID != backend.ContainerID {
+			replicas = append(replicas, b)
+		}
+	}
+	replicas = append(replicas, backend)
+	sort.SliceStable(replicas, func(i, j int) bool {
+		return replicas[i].ContainerName < replicas[j].ContainerName
+	})
+	return newRoute(rt.Hostname, rt.PathPrefix, replicas)
+}
+
+// withoutContainer returns a copy of the route without the given container,
+// or nil if no replicas remain
+func (rt *Route) withoutContainer(containerID string) *Route {
+	var replicas []*docker.Backend
+	for _, b := range rt.Replicas {
+		if b.ContainerID != containerID {
+			replicas = append(replicas, b)
+		}
+	}
+	if len(replicas) == 0 {
+		return nil
+	}
+	return newRoute(rt.Hostname, rt.PathPrefix, replicas)
+}
+
+// hasContainer reports whether the container is one of the route's replicas
+func (rt *Route) hasContainer(containerID string) bool {
+	for _, b := range rt.Replicas {
+		if b.ContainerID == containerID {
+			return true
+		}
+	}
+	return false
+}
+
+// shortContainerID returns a shortened container ID for logging
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
 }
 
 // Router manages routes and provides thread-safe access
@@ -24,6 +108,8 @@ type Router struct {
 
 	// For path-based routing: hostname -> []*Route (sorted by path length desc)
 	pathRoutes map[string][]*Route
+
+	onChange []func() // called with r.mu held after every change
 }
 
 // NewRouter creates a new route manager
@@ -34,35 +120,84 @@ func NewRouter() *Router {
 	}
 }
 
+// OnChange registers fn to be called after every change of the route
+// table. fn runs with the router locked, so it must not block or call back
+// into the router; signal a goroutine instead.
+func (r *Router) OnChange(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onChange = append(r.onChange, fn)
+}
+
+// changedLocked reports a change of the route table; r.mu must be held
+func (r *Router) changedLocked() {
+	for _, fn := range r.onChange {
+		fn()
+	}
+}
+
 // AddBackend adds or updates a route for a backend
 func (r *Router) AddBackend(backend *docker.Backend) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	hostname := strings.ToLower(backend.Hostname)
-	route := &Route{
-		Hostname:   hostname,
-		PathPrefix: backend.PathPrefix,
-		Backend:    backend,
+	r.addBackendLocked(backend)
+	r.changedLocked()
+
+	slog.Info("route added",
+		"hostname", backend.Hostname,
+		"path", backend.PathPrefix,
+		"target", backend.Address(),
+		"container", backend.ContainerName)
+}
+
+// SetBackends atomically replaces the whole route table
+func (r *Router) SetBackends(backends []*docker.Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes = make(map[string]*Route)
+	r.pathRoutes = make(map[string][]*Route)
+	for _, backend := range backends {
+		r.addBackendLocked(backend)
 	}
+	r.changedLocked()
+
+	slog.Debug("route table replaced", "backends", len(backends))
+}
+
+// addBackendLocked adds a backend to the route table; r.mu must be held
+func (r *Router) addBackendLocked(backend *docker.Backend) {
+	hostname := strings.ToLower(backend.Hostname)
+	route := newRoute(hostname, backend.PathPrefix, []*docker.Backend{backend})
 
 	if backend.PathPrefix != "" {
 		// Path-based routing
-		r.pathRoutes[hostname] = append(r.pathRoutes[hostname], route)
+		routes := r.pathRoutes[hostname]
+		replaced := false
+		for i, existing := range routes {
+			if existing.PathPrefix == backend.PathPrefix && (existing.hasContainer(backend.ContainerID) || isReplicaOf(existing.Backend, backend)) {
+				routes[i] = existing.withBackend(backend)
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			routes = append(routes, route)
+		}
 		// Sort by path length descending (longest match first)
-		sort.Slice(r.pathRoutes[hostname], func(i, j int) bool {
-			return len(r.pathRoutes[hostname][i].PathPrefix) > len(r.pathRoutes[hostname][j].PathPrefix)
+		sort.Slice(routes, func(i, j int) bool {
+			return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
 		})
+		r.pathRoutes[hostname] = routes
 	} else {
-		// Simple hostname routing
+		// Simple hostname routing; replicas of the same service share the route
+		if existing, ok := r.routes[hostname]; ok && (existing.hasContainer(backend.ContainerID) || isReplicaOf(existing.Backend, backend)) {
+			route = existing.withBackend(backend)
+		}
 		r.routes[hostname] = route
 	}
-
-	slog.Info("route added",
-		"hostname", backend.Hostname,
-		"path", backend.PathPrefix,
-		"target", fmt.Sprintf("%s:%d", backend.Host, backend.Port),
-		"container", backend.ContainerName)
 }
 
 // RemoveBackend removes routes for a container
@@ -70,22 +205,62 @@ func (r *Router) RemoveBackend(containerID string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.removeBackendLocked(containerID)
+	r.changedLocked()
+}
+
+// ReplaceContainer atomically replaces a container's routes with backends
+// (e.g., after a restart changed its address). No backends removes them.
+func (r *Router) ReplaceContainer(containerID string, backends []*docker.Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeBackendLocked(containerID)
+	for _, backend := range backends {
+		r.addBackendLocked(backend)
+		slog.Info("route refreshed",
+			"hostname", backend.Hostname,
+			"path", backend.PathPrefix,
+			"target", backend.Address(),
+			"container", backend.ContainerName)
+	}
+	r.changedLocked()
+}
+
+// removeBackendLocked removes routes for a container; r.mu must be held
+func (r *Router) removeBackendLocked(containerID string) {
 	// Remove from simple routes
 	for hostname, route := range r.routes {
-		if route.Backend.ContainerID == containerID {
-			delete(r.routes, hostname)
-			slog.Info("route removed",
+		if !route.hasContainer(containerID) {
+			continue
+		}
+		if remaining := route.withoutContainer(containerID); remaining != nil {
+			r.routes[hostname] = remaining
+			slog.Info("replica removed",
 				"hostname", route.Hostname,
-				"container", route.Backend.ContainerName)
+				"container", shortContainerID(containerID),
+				"remaining", len(remaining.Replicas))
+			continue
 		}
+		delete(r.routes, hostname)
+		slog.Info("route removed",
+			"hostname", route.Hostname,
+			"container", route.Backend.ContainerName)
 	}
 
 	// Remove from path routes
 	for hostname, routes := range r.pathRoutes {
 		filtered := routes[:0]
 		for _, route := range routes {
-			if route.Backend.ContainerID != containerID {
+			if !route.hasContainer(containerID) {
 				filtered = append(filtered, route)
+			} else if remaining := route.withoutContainer(containerID); remaining != nil {
+				filtered = append(filtered, remaining)
+				slog.Info("replica removed",
+					"hostname", route.Hostname,
+					"path", route.PathPrefix,
+					"container", shortContainerID(containerID),
+					"remaining", len(remaining.Replicas))
 			} else {
 				slog.Info("route removed",
 					"hostname", route.Hostname,
@@ -106,6 +281,64 @@ func (r *Router) RemoveProject(projectName string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.removeProjectLocked(projectName)
+	r.changedLocked()
+}
+
+// ReplaceProject atomically replaces all routes of a project with backends.
+// Requests never observe the project half-updated, and only hostnames that
+// actually appeared or disappeared are logged.
+func (r *Router) ReplaceProject(projectName string, backends []*docker.Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := r.projectRoutesLocked(projectName)
+	r.removeProjectLocked(projectName)
+	for _, backend := range backends {
+		r.addBackendLocked(backend)
+	}
+	after := r.projectRoutesLocked(projectName)
+	r.changedLocked()
+
+	for key, route := range after {
+		if _, ok := before[key]; !ok {
+			slog.Info("route added",
+				"hostname", route.Hostname,
+				"path", route.PathPrefix,
+				"target", route.Backend.Address(),
+				"container", route.Backend.ContainerName)
+		}
+	}
+	for key, route := range before {
+		if _, ok := after[key]; !ok {
+			slog.Info("route removed",
+				"hostname", route.Hostname,
+				"path", route.PathPrefix,
+				"container", route.Backend.ContainerName)
+		}
+	}
+}
+
+// projectRoutesLocked returns the project's routes keyed by hostname and path; r.mu must be held
+func (r *Router) projectRoutesLocked(projectName string) map[string]*Route {
+	routes := make(map[string]*Route)
+	for hostname, route := range r.routes {
+		if route.Backend.ProjectName == projectName {
+			routes[hostname] = route
+		}
+	}
+	for hostname, pathRoutes := range r.pathRoutes {
+		for _, route := range pathRoutes {
+			if route.Backend.ProjectName == projectName {
+				routes[hostname+route.PathPrefix] = route
+			}
+		}
+	}
+	return routes
+}
+
+// removeProjectLocked removes all routes for a project; r.mu must be held
+func (r *Router) removeProjectLocked(projectName string) {
 	// Remove from simple routes
 	for hostname, route := range r.routes {
 		if route.Backend.ProjectName == projectName {
@@ -152,6 +385,102 @@ func (r *Router) Lookup(hostname, path string) *Route {
 	return r.routes[hostname]
 }
 
+// HasHostname reports whether any route, with or without a path prefix,
+// serves a hostname
+func (r *Router) HasHostname(hostname string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hostname = strings.ToLower(hostname)
+	_, ok := r.routes[hostname]
+	return ok || len(r.pathRoutes[hostname]) > 0
+}
+
+// HostnameRoutes returns every route of a hostname, with or without a path
+// prefix
+func (r *Router) HostnameRoutes(hostname string) []*Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hostname = strings.ToLower(hostname)
+	routes := slices.Clone(r.pathRoutes[hostname])
+	if route, ok := r.routes[hostname]; ok {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// ContainerBackends returns every routed backend of a container
+func (r *Router) ContainerBackends(containerID string) []*docker.Backend {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var backends []*docker.Backend
+	collect := func(route *Route) {
+		for _, b := range route.Replicas {
+			if b.ContainerID == containerID {
+				backends = append(backends, b)
+			}
+		}
+	}
+	for _, route := range r.routes {
+		collect(route)
+	}
+	for _, routes := range r.pathRoutes {
+		for _, route := range routes {
+			collect(route)
+		}
+	}
+	return backends
+}
+
+// Backends returns every routed backend (each replica of every route)
+func (r *Router) Backends() []*docker.Backend {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var backends []*docker.Backend
+	for _, route := range r.routes {
+		backends = append(backends, route.Replicas...)
+	}
+	for _, routes := range r.pathRoutes {
+		for _, route := range routes {
+			backends = append(backends, route.Replicas...)
+		}
+	}
+	return backends
+}
+
+// LegacyHostname returns the hostname that requests without a Host header
+// (HTTP/1.0 clients) are routed to: the only hostname with a roji.legacy
+// route. It returns "" when there is no such route or the choice is ambiguous.
+func (r *Router) LegacyHostname() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hostnames := make(map[string]bool)
+	for hostname, route := range r.routes {
+		if route.Backend.Legacy {
+			hostnames[hostname] = true
+		}
+	}
+	for hostname, routes := range r.pathRoutes {
+		for _, route := range routes {
+			if route.Backend.Legacy {
+				hostnames[hostname] = true
+			}
+		}
+	}
+
+	if len(hostnames) != 1 {
+		return ""
+	}
+	for hostname := range hostnames {
+		return hostname
+	}
+	return ""
+}
+
 // ListRoutes returns all current routes for display
 func (r *Router) ListRoutes() []RouteInfo {
 	r.mu.RLock()
@@ -159,25 +488,20 @@ func (r *Router) ListRoutes() []RouteInfo {
 
 	var infos []RouteInfo
 
+	// One entry per replica so scaled services show every target
+	addInfos := func(route *Route) {
+		for _, b := range route.Replicas {
+			infos = append(infos, newRouteInfo(route, b))
+		}
+	}
+
 	for _, route := range r.routes {
-		infos = append(infos, RouteInfo{
-			Hostname:      route.Hostname,
-			PathPrefix:    route.PathPrefix,
-			Target:        fmt.Sprintf("%s:%d", route.Backend.Host, route.Backend.Port),
-			ContainerName: route.Backend.ContainerName,
-			ServiceName:   route.Backend.ServiceName,
-		})
+		addInfos(route)
 	}
 
 	for _, routes := range r.pathRoutes {
 		for _, route := range routes {
-			infos = append(infos, RouteInfo{
-				Hostname:      route.Hostname,
-				PathPrefix:    route.PathPrefix,
-				Target:        fmt.Sprintf("%s:%d", route.Backend.Host, route.Backend.Port),
-				ContainerName: route.Backend.ContainerName,
-				ServiceName:   route.Backend.ServiceName,
-			})
+			addInfos(route)
 		}
 	}
 
@@ -186,7 +510,10 @@ func (r *Router) ListRoutes() []RouteInfo {
 		if infos[i].Hostname != infos[j].Hostname {
 			return infos[i].Hostname < infos[j].Hostname
 		}
-		return infos[i].PathPrefix < infos[j].PathPrefix
+		if infos[i].PathPrefix != infos[j].PathPrefix {
+			return infos[i].PathPrefix < infos[j].PathPrefix
+		}
+		return infos[i].ContainerName < infos[j].ContainerName
 	})
 
 	return infos
@@ -197,8 +524,50 @@ type RouteInfo struct {
 	Hostname      string
 	PathPrefix    string
 	Target        string
+	RedirectTo    string // Canonical hostname for alias routes
 	ContainerName string
+	ContainerID   string `json:",omitempty"`
 	ServiceName   string
+	ProjectName   string `json:",omitempty"` // docker compose project
+	Embed         string `json:",omitempty"` // roji.embed policy ("relax" or "strip")
+
+	Mounts []config.Mount `json:",omitempty"` // Fragment routes served under path prefixes (roji.compose)
+	Paused bool           `json:",omitempty"` // Container is paused (docker pause)
+	Tunnel bool           `json:",omitempty"` // Shared through a public tunnel (roji.tunnel)
+
+	Maintenance bool `json:",omitempty"` // Answers 503 until resumed (/_api/routes/{host}/pause)
+
+	Health *BackendHealth `json:",omitempty"` // Latest probe of Target (see HealthProber)
+}
+
+// routeTarget is where a backend's requests go: its address, directory or URL
+func routeTarget(b *docker.Backend) string {
+	switch {
+	case b.StaticDir != "":
+		return b.StaticDir
+	case b.ExternalURL != "":
+		return b.ExternalURL
+	}
+	return b.Address()
+}
+
+// newRouteInfo describes one replica of a route
+func newRouteInfo(route *Route, b *docker.Backend) RouteInfo {
+	return RouteInfo{
+		Hostname:      route.Hostname,
+		PathPrefix:    route.PathPrefix,
+		Target:        routeTarget(b),
+		RedirectTo:    b.RedirectTo,
+		ContainerName: b.ContainerName,
+		ContainerID:   b.ContainerID,
+		ServiceName:   b.ServiceName,
+		ProjectName:   b.ProjectName,
+		Embed:         b.Embed,
+
+		Mounts: b.Mounts,
+		Paused: b.Paused,
+		Tunnel: b.Tunnel,
+	}
 }
 
 func (ri RouteInfo) String() string {
@@ -206,6 +575,10 @@ func (ri RouteInfo) String() string {
 	if path == "" {
 		path = "/"
 	}
+	if ri.RedirectTo != "" {
+		return fmt.Sprintf("https://%s%s -> https://%s%s (redirect)",
+			ri.Hostname, path, ri.RedirectTo, path)
+	}
 	return fmt.Sprintf("https://%s%s -> %s (%s)",
 		ri.Hostname, path, ri.Target, ri.ServiceName)
 }
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/kan/roji/docker"
@@ -132,6 +133,51 @@ func TestRouter_RemoveBackend(t *testing.T) {
 	}
 }
 
+func TestRouter_ReplaceContainer(t *testing.T) {
+	router := NewRouter()
+
+	router.AddBackend(&docker.Backend{
+		ContainerID:   "abc123",
+		ContainerName: "web",
+		Host:          "172.17.0.2",
+		Port:          80,
+		Hostname:      "web.localhost",
+	})
+	router.AddBackend(&docker.Backend{
+		ContainerID:   "abc123",
+		ContainerName: "web",
+		Host:          "172.17.0.2",
+		Port:          80,
+		Hostname:      "web.localhost",
+		PathPrefix:    "/api",
+	})
+
+	// Restarted with a new IP and without the path route
+	router.ReplaceContainer("abc123", []*docker.Backend{{
+		ContainerID:   "abc123",
+		ContainerName: "web",
+		Host:          "172.17.0.5",
+		Port:          80,
+		Hostname:      "web.localhost",
+	}})
+
+	route := router.Lookup("web.localhost", "/")
+	if route == nil {
+		t.Fatal("expected route after replace")
+	}
+	if route.Backend.Host != "172.17.0.5" || len(route.Replicas) != 1 {
+		t.Errorf("route backend = %s (%d replicas), want 172.17.0.5 (1 replica)", route.Backend.Host, len(route.Replicas))
+	}
+	if route := router.Lookup("web.localhost", "/api/users"); route != nil && route.PathPrefix == "/api" {
+		t.Error("stale path route should be removed")
+	}
+
+	router.ReplaceContainer("abc123", nil)
+	if route := router.Lookup("web.localhost", "/"); route != nil {
+		t.Error("expected routes to be removed")
+	}
+}
+
 func TestRouter_RemoveProject(t *testing.T) {
 	router := NewRouter()
 
@@ -261,3 +307,133 @@ func TestRouteInfo_String(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_Replicas(t *testing.T) {
+	router := NewRouter()
+
+	for i, ip := range []string{"172.17.0.2", "172.17.0.3"} {
+		router.AddBackend(&docker.Backend{
+			ContainerID:   fmt.Sprintf("web%d", i+1),
+			ContainerName: fmt.Sprintf("shop-web-%d", i+1),
+			ServiceName:   "web",
+			ProjectName:   "shop",
+			Host:          ip,
+			Port:          80,
+			Hostname:      "shop.localhost",
+		})
+	}
+
+	route := router.Lookup("shop.localhost", "/")
+	if route == nil {
+		t.Fatal("expected route, got nil")
+	}
+	if len(route.Replicas) != 2 {
+		t.Fatalf("replicas = %d, want 2", len(route.Replicas))
+	}
+
+	// Round-robin should alternate the first candidate
+	first := route.Candidates()[0].ContainerID
+	second := route.Candidates()[0].ContainerID
+	if first == second {
+		t.Errorf("expected round-robin to alternate replicas, got %q twice", first)
+	}
+
+	if got := len(router.ListRoutes()); got != 2 {
+		t.Errorf("ListRoutes() = %d entries, want one per replica (2)", got)
+	}
+
+	// Removing one replica keeps the route alive
+	router.RemoveBackend("web1")
+	route = router.Lookup("shop.localhost", "/")
+	if route == nil {
+		t.Fatal("expected route to survive removal of one replica")
+	}
+	if len(route.Replicas) != 1 || route.Backend.ContainerID != "web2" {
+		t.Errorf("remaining replicas = %v, want only web2", route.Replicas)
+	}
+
+	router.RemoveBackend("web2")
+	if route := router.Lookup("shop.localhost", "/"); route != nil {
+		t.Error("expected route to be removed with its last replica")
+	}
+}
+
+func TestRouter_DifferentServicesSameHostnameReplace(t *testing.T) {
+	router := NewRouter()
+
+	router.AddBackend(&docker.Backend{
+		ContainerID: "a", ServiceName: "web", Host: "172.17.0.2", Port: 80, Hostname: "app.localhost",
+	})
+	router.AddBackend(&docker.Backend{
+		ContainerID: "b", ServiceName: "other", Host: "172.17.0.3", Port: 80, Hostname: "app.localhost",
+	})
+
+	route := router.Lookup("app.localhost", "/")
+	if route == nil {
+		t.Fatal("expected route, got nil")
+	}
+	if len(route.Replicas) != 1 || route.Backend.ContainerID != "b" {
+		t.Errorf("expected unrelated service to replace the route, got %v", route.Replicas)
+	}
+}
+
+func TestRouter_SetBackends(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "old", Host: "172.17.0.2", Port: 80, Hostname: "old.localhost"})
+
+	router.SetBackends([]*docker.Backend{
+		{ContainerID: "web", Host: "172.17.0.3", Port: 80, Hostname: "web.localhost"},
+		{ContainerID: "api", Host: "172.17.0.4", Port: 80, Hostname: "web.localhost", PathPrefix: "/api"},
+	})
+
+	if route := router.Lookup("old.localhost", "/"); route != nil {
+		t.Error("expected previous routes to be replaced")
+	}
+	if route := router.Lookup("web.localhost", "/api/x"); route == nil || route.Backend.ContainerID != "api" {
+		t.Errorf("expected /api path route after SetBackends, got %v", route)
+	}
+}
+
+func TestRouter_LegacyHostname(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "a", Hostname: "web.localhost", Host: "172.18.0.2", Port: 80})
+	if got := router.LegacyHostname(); got != "" {
+		t.Errorf("LegacyHostname() = %q, want empty without legacy routes", got)
+	}
+
+	router.AddBackend(&docker.Backend{ContainerID: "b", Hostname: "printer.localhost", Host: "172.18.0.3", Port: 80, Legacy: true})
+	if got := router.LegacyHostname(); got != "printer.localhost" {
+		t.Errorf("LegacyHostname() = %q, want %q", got, "printer.localhost")
+	}
+
+	// Two legacy hostnames are ambiguous
+	router.AddBackend(&docker.Backend{ContainerID: "c", Hostname: "nas.localhost", Host: "172.18.0.4", Port: 80, Legacy: true})
+	if got := router.LegacyHostname(); got != "" {
+		t.Errorf("LegacyHostname() = %q, want empty when ambiguous", got)
+	}
+}
+
+func TestRouter_ReplaceProject(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "web1", ServiceName: "web", ProjectName: "shop", Hostname: "shop.localhost", Host: "172.18.0.2", Port: 80})
+	router.AddBackend(&docker.Backend{ContainerID: "other1", ServiceName: "other", ProjectName: "other", Hostname: "other.localhost", Host: "172.18.0.9", Port: 80})
+
+	// A second service joins: hostnames change shape in one update
+	router.ReplaceProject("shop", []*docker.Backend{
+		{ContainerID: "web1", ServiceName: "web", ProjectName: "shop", Hostname: "web.shop.localhost", Host: "172.18.0.2", Port: 80},
+		{ContainerID: "api1", ServiceName: "api", ProjectName: "shop", Hostname: "api.shop.localhost", Host: "172.18.0.3", Port: 80, PathPrefix: "/v1"},
+	})
+
+	if router.Lookup("shop.localhost", "/") != nil {
+		t.Error("old project hostname should be removed")
+	}
+	if router.Lookup("web.shop.localhost", "/") == nil {
+		t.Error("web.shop.localhost should be routed")
+	}
+	if router.Lookup("api.shop.localhost", "/v1/users") == nil {
+		t.Error("api.shop.localhost/v1 should be routed")
+	}
+	if router.Lookup("other.localhost", "/") == nil {
+		t.Error("other projects must be untouched")
+	}
+}
@@ -0,0 +1,264 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/kan/roji/docker"
+)
+
+// RouteTable is the router's internal state (GET /_api/debug)
+type RouteTable struct {
+	Hosts []HostRoutes `json:"hosts"`
+}
+
+// HostRoutes are the routes of one hostname in the order Lookup tries them:
+// path routes, longest prefix first, then the hostname route
+type HostRoutes struct {
+	Hostname string       `json:"hostname"`
+	Routes   []TableRoute `json:"routes"`
+}
+
+// TableRoute is one entry of the route table
+type TableRoute struct {
+	Priority   int            `json:"priority"`    // order Lookup tries the route in (1 first)
+	PathPrefix string         `json:"path_prefix"` // "" for the hostname route
+	Backends   []TableBackend `json:"backends"`    // replicas, served round-robin
+}
+
+// TableBackend is one replica of a route
+type TableBackend struct {
+	Container   string `json:"container"`
+	ContainerID string `json:"container_id"`
+	Service     string `json:"service,omitempty"`
+	Project     string `json:"project,omitempty"`
+	Address     string `json:"address"` // dialed address, static directory or external URL
+	RedirectTo  string `json:"redirect_to,omitempty"`
+	Paused      bool   `json:"paused,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"` // roji.* labels of the container
+}
+
+// LookupCheck is one route Lookup considered for a path
+type LookupCheck struct {
+	Priority   int    `json:"priority"`
+	PathPrefix string `json:"path_prefix"`
+	Matched    bool   `json:"matched"`
+	Reason     string `json:"reason"`
+}
+
+// LookupTrace explains why Lookup picked a route for a hostname and path,
+// or why it found none
+type LookupTrace struct {
+	Hostname string        `json:"hostname"`
+	Path     string        `json:"path"`
+	Result   string        `json:"result"`
+	Checks   []LookupCheck `json:"checks"`
+	Match    *TableRoute   `json:"match,omitempty"`
+	Similar  []string      `json:"similar,omitempty"` // routed hostnames close to an unknown one
+}
+
+// DebugExplain is the response of GET /_api/debug?explain=URL: the route
+// lookup in detail, and everything the handler would do around it
+type DebugExplain struct {
+	Lookup      LookupTrace  `json:"lookup"`
+	Explanation *Explanation `json:"explanation"`
+}
+
+// newTableRoute describes a route with its lookup priority
+func newTableRoute(route *Route, priority int) TableRoute {
+	tr := TableRoute{Priority: priority, PathPrefix: route.PathPrefix}
+	for _, b := range route.Replicas {
+		tr.Backends = append(tr.Backends, newTableBackend(b))
+	}
+	return tr
+}
+
+func newTableBackend(b *docker.Backend) TableBackend {
+	return TableBackend{
+		Container:   b.ContainerName,
+		ContainerID: shortContainerID(b.ContainerID),
+		Service:     b.ServiceName,
+		Project:     b.ProjectName,
+		Address:     routeTarget(b),
+		RedirectTo:  b.RedirectTo,
+		Paused:      b.Paused,
+
+		Labels: b.Labels,
+	}
+}
+
+// hostRoutesLocked returns a hostname's routes in lookup order; r.mu must be held
+func (r *Router) hostRoutesLocked(hostname string) []*Route {
+	routes := append([]*Route(nil), r.pathRoutes[hostname]...)
+	if route, ok := r.routes[hostname]; ok {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// Table returns every hostname's routes in lookup order, sorted by hostname
+func (r *Router) Table() RouteTable {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hostnames := make(map[string]bool)
+	for hostname := range r.routes {
+		hostnames[hostname] = true
+	}
+	for hostname := range r.pathRoutes {
+		hostnames[hostname] = true
+	}
+
+	table := RouteTable{Hosts: make([]HostRoutes, 0, len(hostnames))}
+	for hostname := range hostnames {
+		host := HostRoutes{Hostname: hostname}
+		for i, route := range r.hostRoutesLocked(hostname) {
+			host.Routes = append(host.Routes, newTableRoute(route, i+1))
+		}
+		table.Hosts = append(table.Hosts, host)
+	}
+	sort.Slice(table.Hosts, func(i, j int) bool {
+		return table.Hosts[i].Hostname < table.Hosts[j].Hostname
+	})
+	return table
+}
+
+// Trace runs Lookup step by step, recording why each route matched or not
+func (r *Router) Trace(hostname, path string) LookupTrace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hostname = strings.ToLower(hostname)
+	trace := LookupTrace{Hostname: hostname, Path: path, Checks: []LookupCheck{}}
+
+	routes := r.hostRoutesLocked(hostname)
+	if len(routes) == 0 {
+		trace.Result = fmt.Sprintf("no routes for hostname %q", hostname)
+		trace.Similar = r.similarHostnamesLocked(hostname)
+		return trace
+	}
+
+	for i, route := range routes {
+		check := LookupCheck{Priority: i + 1, PathPrefix: route.PathPrefix}
+		switch {
+		case trace.Match != nil:
+			check.Reason = fmt.Sprintf("not tried: priority %d matched first", trace.Match.Priority)
+		case route.PathPrefix == "":
+			check.Matched = true
+			check.Reason = "hostname route: serves every path no path route matched"
+		case strings.HasPrefix(path, route.PathPrefix):
+			check.Matched = true
+			check.Reason = fmt.Sprintf("%q starts with %q", path, route.PathPrefix)
+			if rest := path[len(route.PathPrefix):]; rest != "" && rest[0] != '/' && !strings.HasSuffix(route.PathPrefix, "/") {
+				check.Reason += fmt.Sprintf(" (prefixes match as plain strings, not path segments: %q also matches %q)", route.PathPrefix, route.PathPrefix+rest)
+			}
+		default:
+			check.Reason = fmt.Sprintf("%q doesn't start with %q", path, route.PathPrefix)
+		}
+		if check.Matched && trace.Match == nil {
+			match := newTableRoute(route, i+1)
+			trace.Match = &match
+		}
+		trace.Checks = append(trace.Checks, check)
+	}
+
+	switch {
+	case trace.Match == nil:
+		trace.Result = fmt.Sprintf("no path route of %s matches %q, and the hostname has no route without a path", hostname, path)
+	case trace.Match.PathPrefix == "":
+		trace.Result = fmt.Sprintf("matched the hostname route of %s (priority %d)", hostname, trace.Match.Priority)
+	default:
+		trace.Result = fmt.Sprintf("matched path route %s (priority %d)", trace.Match.PathPrefix, trace.Match.Priority)
+	}
+	return trace
+}
+
+// similarHostnamesLocked returns routed hostnames that look like typos of
+// hostname, or share its first label; r.mu must be held
+func (r *Router) similarHostnamesLocked(hostname string) []string {
+	first, _, _ := strings.Cut(hostname, ".")
+	seen := make(map[string]bool)
+	var similar []string
+	consider := func(candidate string) {
+		if seen[candidate] {
+			return
+		}
+		seen[candidate] = true
+		label, _, _ := strings.Cut(candidate, ".")
+		if label == first || editDistance(candidate, hostname) <= 2 {
+			similar = append(similar, candidate)
+		}
+	}
+	for candidate := range r.routes {
+		consider(candidate)
+	}
+	for candidate := range r.pathRoutes {
+		consider(candidate)
+	}
+	sort.Strings(similar)
+	return similar
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// serveDebugAPI dumps the route table (GET), or traces the lookup of a URL
+// (GET ?explain=URL)
+func (h *Handler) serveDebugAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var result any
+	if raw := r.URL.Query().Get("explain"); raw != "" {
+		target, err := NewExplainTarget(ExplainRequest{URL: raw})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		target.RemoteAddr = r.RemoteAddr
+		explanation := h.Explain(target)
+		hostname := explanation.Hostname
+		if hostname == "" {
+			hostname = h.requestHostname(target)
+		}
+		result = DebugExplain{
+			Lookup:      h.router.Trace(hostname, target.URL.Path),
+			Explanation: explanation,
+		}
+	} else {
+		result = h.router.Table()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		slog.Error("failed to encode debug response", "error", err)
+	}
+}
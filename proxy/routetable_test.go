@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kan/roji/docker"
+)
+
+func newTableTestRouter() *Router {
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{
+		ContainerID: "web123", ContainerName: "web", ServiceName: "web",
+		Host: "172.17.0.2", Port: 80, Hostname: "app.localhost",
+	})
+	router.AddBackend(&docker.Backend{
+		ContainerID: "api123", ContainerName: "api", ServiceName: "api",
+		Host: "172.17.0.3", Port: 8080, Hostname: "app.localhost", PathPrefix: "/api",
+	})
+	router.AddBackend(&docker.Backend{
+		ContainerID: "apiv2123", ContainerName: "api-v2", ServiceName: "api-v2",
+		Host: "172.17.0.4", Port: 8080, Hostname: "app.localhost", PathPrefix: "/api/v2",
+		Labels: map[string]string{"roji.host": "app.localhost", "roji.path": "/api/v2"},
+	})
+	router.AddBackend(&docker.Backend{
+		ContainerID: "docs123", ContainerName: "docs", ServiceName: "docs",
+		Host: "172.17.0.5", Port: 3000, Hostname: "docs.localhost", PathPrefix: "/docs",
+	})
+	return router
+}
+
+func TestRouter_Table(t *testing.T) {
+	table := newTableTestRouter().Table()
+
+	var hostnames []string
+	for _, host := range table.Hosts {
+		hostnames = append(hostnames, host.Hostname)
+	}
+	if want := []string{"app.localhost", "docs.localhost"}; !reflect.DeepEqual(hostnames, want) {
+		t.Fatalf("hostnames = %v, want %v", hostnames, want)
+	}
+
+	app := table.Hosts[0]
+	var order []string
+	for i, route := range app.Routes {
+		if route.Priority != i+1 {
+			t.Errorf("route %q priority = %d, want %d", route.PathPrefix, route.Priority, i+1)
+		}
+		order = append(order, route.PathPrefix)
+	}
+	if want := []string{"/api/v2", "/api", ""}; !reflect.DeepEqual(order, want) {
+		t.Errorf("lookup order = %q, want %q", order, want)
+	}
+	backend := app.Routes[0].Backends[0]
+	if backend.Container != "api-v2" || backend.Address != "172.17.0.4:8080" {
+		t.Errorf("backend = %+v, want api-v2 at 172.17.0.4:8080", backend)
+	}
+	if backend.Labels["roji.path"] != "/api/v2" {
+		t.Errorf("backend labels = %v, want roji.path=/api/v2", backend.Labels)
+	}
+}
+
+func TestRouter_Trace(t *testing.T) {
+	router := newTableTestRouter()
+
+	tests := []struct {
+		hostname, path string
+		wantPrefix     string // "" is the hostname route
+		wantMatch      bool
+		wantInReason   string
+	}{
+		{"app.localhost", "/api/v2/users", "/api/v2", true, ""},
+		{"app.localhost", "/api/users", "/api", true, ""},
+		{"app.localhost", "/apis", "/api", true, "plain strings"},
+		{"app.localhost", "/", "", true, ""},
+		{"APP.localhost", "/", "", true, ""},
+		{"docs.localhost", "/blog", "", false, ""},
+		{"nope.localhost", "/", "", false, ""},
+	}
+	for _, tt := range tests {
+		trace := router.Trace(tt.hostname, tt.path)
+
+		// The trace must agree with the real lookup
+		route := router.Lookup(tt.hostname, tt.path)
+		if (route != nil) != tt.wantMatch || (trace.Match != nil) != tt.wantMatch {
+			t.Fatalf("%s%s: lookup = %v, trace match = %v, want match %v", tt.hostname, tt.path, route, trace.Match, tt.wantMatch)
+		}
+		if !tt.wantMatch {
+			continue
+		}
+		if route.PathPrefix != tt.wantPrefix || trace.Match.PathPrefix != tt.wantPrefix {
+			t.Errorf("%s%s: lookup = %q, trace = %q, want %q", tt.hostname, tt.path, route.PathPrefix, trace.Match.PathPrefix, tt.wantPrefix)
+		}
+
+		matched := 0
+		for _, check := range trace.Checks {
+			if check.Matched {
+				matched++
+				if !strings.Contains(check.Reason, tt.wantInReason) {
+					t.Errorf("%s%s: reason %q doesn't mention %q", tt.hostname, tt.path, check.Reason, tt.wantInReason)
+				}
+			}
+		}
+		if matched != 1 {
+			t.Errorf("%s%s: %d checks matched, want 1: %+v", tt.hostname, tt.path, matched, trace.Checks)
+		}
+	}
+}
+
+func TestRouter_TraceSuggestsHostnames(t *testing.T) {
+	router := newTableTestRouter()
+
+	trace := router.Trace("ap.localhost", "/")
+	if !reflect.DeepEqual(trace.Similar, []string{"app.localhost"}) {
+		t.Errorf("similar to a typo = %v, want [app.localhost]", trace.Similar)
+	}
+	trace = router.Trace("docs.app.localhost", "/")
+	if !reflect.DeepEqual(trace.Similar, []string{"docs.localhost"}) {
+		t.Errorf("similar by first label = %v, want [docs.localhost]", trace.Similar)
+	}
+	if trace := router.Trace("unrelated.test", "/"); len(trace.Similar) != 0 {
+		t.Errorf("similar to an unrelated hostname = %v, want none", trace.Similar)
+	}
+}
+
+func TestHandler_DebugAPI(t *testing.T) {
+	handler := NewHandler(newTableTestRouter(), "roji.localhost", testStatusConfig())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost/_api/v1/debug", nil))
+	if w.Code != 200 {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var table RouteTable
+	if err := json.NewDecoder(w.Body).Decode(&table); err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Hosts) != 2 {
+		t.Errorf("hosts = %d, want 2", len(table.Hosts))
+	}
+
+	w = httptest.NewRecorder()
+	target := "/_api/debug?explain=" + url.QueryEscape("app.localhost/api/v2/users")
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://roji.localhost"+target, nil))
+	if w.Code != 200 {
+		t.Fatalf("explain status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var d DebugExplain
+	if err := json.NewDecoder(w.Body).Decode(&d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Lookup.Match == nil || d.Lookup.Match.PathPrefix != "/api/v2" {
+		t.Errorf("lookup match = %+v, want /api/v2", d.Lookup.Match)
+	}
+	if d.Explanation == nil || d.Explanation.Outcome != OutcomeProxy {
+		t.Errorf("explanation = %+v, want outcome %q", d.Explanation, OutcomeProxy)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "https://roji.localhost/_api/debug", nil))
+	if w.Code != 405 {
+		t.Errorf("POST status = %d, want 405", w.Code)
+	}
+}
@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ShareParam carries a share token in a share link's query string
+	ShareParam = "roji_share"
+	// shareCookie keeps a redeemed share token for the rest of the visit
+	shareCookie = "roji_share"
+	// MaxShareDuration caps how long a share link stays valid
+	MaxShareDuration = 7 * 24 * time.Hour
+)
+
+// ShareSigner mints and verifies share tokens: expiring, HMAC-signed grants
+// to view one hostname. A token is "<unix expiry>.<signature>".
+type ShareSigner struct {
+	key []byte
+}
+
+// NewShareSigner creates a signer; tokens survive restarts as long as the
+// key does
+func NewShareSigner(key []byte) *ShareSigner {
+	return &ShareSigner{key: key}
+}
+
+// Sign returns a token granting access to hostname until expires
+func (s *ShareSigner) Sign(hostname string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + s.signature(hostname, exp)
+}
+
+// Verify reports whether token grants access to hostname at now
+func (s *ShareSigner) Verify(hostname, token string, now time.Time) bool {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || now.After(time.Unix(unix, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.signature(hostname, exp)))
+}
+
+func (s *ShareSigner) signature(hostname, exp string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(strings.ToLower(hostname) + "\n" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// WithShareLinks enables /_api/share and lets valid share links through the
+// LAN allow list and tunnel credentials
+func WithShareLinks(s *ShareSigner) HandlerOption {
+	return func(h *Handler) {
+		h.shares = s
+	}
+}
+
+// requestHostname returns the route hostname a request is for
+func (h *Handler) requestHostname(r *http.Request) string {
+	return h.fromEmulatorHostname(strings.ToLower(hostWithoutPort(r.Host)))
+}
+
+// redeemShareLink turns a valid ?roji_share= token into a cookie and
+// redirects to the clean URL. It reports whether the request was handled.
+func (h *Handler) redeemShareLink(w http.ResponseWriter, r *http.Request) bool {
+	if h.shares == nil {
+		return false
+	}
+	query := r.URL.Query()
+	token := query.Get(ShareParam)
+	if token == "" {
+		return false
+	}
+	hostname := h.requestHostname(r)
+	if !h.shares.Verify(hostname, token, time.Now()) {
+		slog.Warn("invalid or expired share link", "hostname", hostname, "client", r.RemoteAddr)
+		return false
+	}
+
+	exp, _, _ := strings.Cut(token, ".")
+	unix, _ := strconv.ParseInt(exp, 10, 64)
+	http.SetCookie(w, &http.Cookie{
+		Name:     shareCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Unix(unix, 0),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query.Del(ShareParam)
+	clean := *r.URL
+	clean.RawQuery = query.Encode()
+	slog.Info("share link redeemed", "hostname", hostname, "client", r.RemoteAddr, "expires", time.Unix(unix, 0))
+	http.Redirect(w, r, clean.RequestURI(), http.StatusSeeOther)
+	return true
+}
+
+// sharedRequest reports whether the request carries a valid share cookie
+// for its hostname
+func (h *Handler) sharedRequest(r *http.Request) bool {
+	if h.shares == nil {
+		return false
+	}
+	cookie, err := r.Cookie(shareCookie)
+	if err != nil {
+		return false
+	}
+	return h.shares.Verify(h.requestHostname(r), cookie.Value, time.Now())
+}
+
+// shareRequest is the body of POST /_api/share
+type shareRequest struct {
+	Hostname string `json:"hostname"`
+	Expires  string `json:"expires"` // duration, e.g. "2h"
+}
+
+// shareResponse describes a minted share link
+type shareResponse struct {
+	Hostname  string    `json:"hostname"`
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// serveShareAPI mints a share link for a route (POST, JSON only so pages
+// can't mint links cross-site with a form)
+func (h *Handler) serveShareAPI(w http.ResponseWriter, r *http.Request) {
+	if h.shares == nil {
+		http.Error(w, "share links are disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var req shareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	hostname := strings.ToLower(strings.TrimSpace(req.Hostname))
+	if !h.hasRoute(hostname) {
+		http.Error(w, "no route for hostname "+hostname, http.StatusNotFound)
+		return
+	}
+	ttl, err := time.ParseDuration(req.Expires)
+	if err != nil || ttl <= 0 || ttl > MaxShareDuration {
+		http.Error(w, "expires must be a duration between 1s and "+MaxShareDuration.String(), http.StatusBadRequest)
+		return
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	token := h.shares.Sign(hostname, expires)
+	host := hostname
+	if h.statusConfig.HTTPSPort != 443 {
+		host += ":" + strconv.Itoa(h.statusConfig.HTTPSPort)
+	}
+	slog.Info("share link created", "hostname", hostname, "expires", expires)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(shareResponse{
+		Hostname:  hostname,
+		Token:     token,
+		URL:       "https://" + host + "/?" + ShareParam + "=" + token,
+		ExpiresAt: expires,
+	}); err != nil {
+		slog.Error("failed to encode share response", "error", err)
+	}
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareSigner(t *testing.T) {
+	s := NewShareSigner([]byte("0123456789abcdef0123456789abcdef"))
+	now := time.Now()
+	token := s.Sign("web.localhost", now.Add(time.Hour))
+
+	tests := []struct {
+		name     string
+		hostname string
+		token    string
+		at       time.Time
+		want     bool
+	}{
+		{"valid", "web.localhost", token, now, true},
+		{"case-insensitive hostname", "Web.Localhost", token, now, true},
+		{"other hostname", "api.localhost", token, now, false},
+		{"expired", "web.localhost", token, now.Add(2 * time.Hour), false},
+		{"tampered expiry", "web.localhost", "9999999999" + token[strings.Index(token, "."):], now, false},
+		{"malformed", "web.localhost", "garbage", now, false},
+	}
+	for _, tt := range tests {
+		if got := s.Verify(tt.hostname, tt.token, tt.at); got != tt.want {
+			t.Errorf("%s: Verify() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_ShareLink(t *testing.T) {
+	backend := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+
+	devices, err := NewDeviceAllowList(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewShareSigner([]byte("0123456789abcdef0123456789abcdef"))
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(),
+		WithLANAccess(devices), WithShareLinks(signer))
+
+	get := func(target string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = "192.168.1.20:50000" // unpaired LAN device
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("https://web.localhost/", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("unpaired device: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	token := signer.Sign("web.localhost", time.Now().Add(time.Hour))
+	w := get("https://web.localhost/page?a=1&"+ShareParam+"="+token, nil)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/page?a=1" {
+		t.Fatalf("redeem: status = %d, Location = %q; want 303 to /page?a=1", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("redeem should set one HttpOnly cookie, got %+v", cookies)
+	}
+
+	if w := get("https://web.localhost/page?a=1", cookies[0]); w.Code != http.StatusOK {
+		t.Errorf("with share cookie: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	// The grant is scoped to the shared hostname
+	other := signer.Sign("api.localhost", time.Now().Add(time.Hour))
+	if w := get("https://web.localhost/?"+ShareParam+"="+other, nil); w.Code != http.StatusForbidden {
+		t.Errorf("token for another hostname: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestHandler_ShareAPI(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {}))
+	signer := NewShareSigner([]byte("0123456789abcdef0123456789abcdef"))
+	handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithShareLinks(signer))
+
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "https://roji.localhost/_api/share", strings.NewReader(body))
+		req.Host = "roji.localhost"
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("application/json", `{"hostname":"web.localhost","expires":"2h"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var resp shareResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !signer.Verify("web.localhost", resp.Token, time.Now().Add(119*time.Minute)) {
+		t.Error("minted token should be valid for 2h")
+	}
+	if !strings.HasPrefix(resp.URL, "https://web.localhost/?"+ShareParam+"=") {
+		t.Errorf("URL = %q", resp.URL)
+	}
+
+	if w := post("text/plain", `{"hostname":"web.localhost","expires":"2h"}`); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("non-JSON post: status = %d, want %d", w.Code, http.StatusUnsupportedMediaType)
+	}
+	if w := post("application/json", `{"hostname":"web.localhost","expires":"720h"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("too long: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := post("application/json", `{"hostname":"nope.localhost","expires":"1h"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown hostname: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
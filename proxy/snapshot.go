@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+const (
+	// maxSnapshotBody limits how much of each response is hashed
+	maxSnapshotBody = 10 << 20 // 10 MiB
+	// maxSnapshotDiffs is how many diffs are kept for the API
+	maxSnapshotDiffs = 50
+)
+
+// EndpointSnapshot is the observed response of one endpoint
+type EndpointSnapshot struct {
+	Path     string `json:"path"`
+	Status   int    `json:"status"`
+	BodyHash string `json:"body_hash,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// EndpointChange describes an endpoint whose response changed across a restart
+type EndpointChange struct {
+	Path   string           `json:"path"`
+	Before EndpointSnapshot `json:"before"`
+	After  EndpointSnapshot `json:"after"`
+}
+
+// SnapshotDiff lists the endpoints of a route that changed after a restart
+type SnapshotDiff struct {
+	Hostname      string           `json:"hostname"`
+	ContainerName string           `json:"container"`
+	Time          time.Time        `json:"time"`
+	Changes       []EndpointChange `json:"changes"`
+}
+
+// Snapshotter records the responses of a route's roji.snapshot endpoints
+// each time its container starts and reports what changed since the
+// previous start, e.g. "the new image broke this endpoint".
+type Snapshotter struct {
+	client *http.Client
+	delay  time.Duration // Wait before capturing, so the app can finish booting
+
+	mu    sync.Mutex
+	last  map[string][]EndpointSnapshot // key: hostname + path prefix
+	diffs []SnapshotDiff                // newest last
+}
+
+// NewSnapshotter creates a snapshotter that captures delay after a container starts
+func NewSnapshotter(delay time.Duration) *Snapshotter {
+	return &Snapshotter{
+		client: &http.Client{Transport: sharedTransport, Timeout: 10 * time.Second},
+		delay:  delay,
+		last:   make(map[string][]EndpointSnapshot),
+	}
+}
+
+// Capture snapshots the backend's endpoints and records a diff against the
+// previous capture of the same route. It is a no-op without snapshot paths.
+func (s *Snapshotter) Capture(ctx context.Context, backend *docker.Backend) {
+	if len(backend.SnapshotPaths) == 0 || backend.RedirectTo != "" {
+		return
+	}
+
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return
+	}
+
+	current := make([]EndpointSnapshot, 0, len(backend.SnapshotPaths))
+	for _, path := range backend.SnapshotPaths {
+		current = append(current, s.fetch(ctx, backend, path))
+	}
+
+	key := backend.Hostname + backend.PathPrefix
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.last[key]
+	s.last[key] = current
+	if !ok {
+		return
+	}
+
+	changes := compareSnapshots(previous, current)
+	if len(changes) == 0 {
+		slog.Debug("snapshot unchanged after restart", "hostname", backend.Hostname)
+		return
+	}
+
+	for _, c := range changes {
+		slog.Warn("endpoint changed after restart",
+			"hostname", backend.Hostname,
+			"path", c.Path,
+			"status_before", c.Before.Status,
+			"status_after", c.After.Status,
+			"body_changed", c.Before.BodyHash != c.After.BodyHash)
+	}
+
+	s.diffs = append(s.diffs, SnapshotDiff{
+		Hostname:      backend.Hostname,
+		ContainerName: backend.ContainerName,
+		Time:          time.Now(),
+		Changes:       changes,
+	})
+	if len(s.diffs) > maxSnapshotDiffs {
+		s.diffs = s.diffs[len(s.diffs)-maxSnapshotDiffs:]
+	}
+}
+
+// Diffs returns recorded diffs, newest first
+func (s *Snapshotter) Diffs() []SnapshotDiff {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]SnapshotDiff, len(s.diffs))
+	for i, d := range s.diffs {
+		result[len(s.diffs)-1-i] = d
+	}
+	return result
+}
+
+// fetch requests one endpoint directly from the backend
+func (s *Snapshotter) fetch(ctx context.Context, backend *docker.Backend, path string) EndpointSnapshot {
+	snap := EndpointSnapshot{Path: path}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+backend.Address()+path, nil)
+	if err != nil {
+		snap.Error = err.Error()
+		return snap
+	}
+	req.Host = backend.Hostname
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		snap.Error = err.Error()
+		return snap
+	}
+	defer resp.Body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.LimitReader(resp.Body, maxSnapshotBody)); err != nil {
+		snap.Error = err.Error()
+	}
+	snap.Status = resp.StatusCode
+	snap.BodyHash = hex.EncodeToString(hash.Sum(nil))
+	return snap
+}
+
+// compareSnapshots returns the endpoints whose status, body or error changed
+func compareSnapshots(before, after []EndpointSnapshot) []EndpointChange {
+	previous := make(map[string]EndpointSnapshot, len(before))
+	for _, snap := range before {
+		previous[snap.Path] = snap
+	}
+
+	var changes []EndpointChange
+	for _, snap := range after {
+		old, ok := previous[snap.Path]
+		if !ok || old == snap {
+			continue
+		}
+		changes = append(changes, EndpointChange{Path: snap.Path, Before: old, After: snap})
+	}
+	return changes
+}
+
+// WithSnapshots enables the /_api/snapshots endpoint
+func WithSnapshots(s *Snapshotter) HandlerOption {
+	return func(h *Handler) {
+		h.snapshots = s
+	}
+}
+
+// serveSnapshotsAPI lists endpoint diffs recorded across container restarts
+func (h *Handler) serveSnapshotsAPI(w http.ResponseWriter, r *http.Request) {
+	diffs := []SnapshotDiff{}
+	if h.snapshots != nil {
+		diffs = h.snapshots.Diffs()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diffs); err != nil {
+		slog.Error("failed to encode snapshots response", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
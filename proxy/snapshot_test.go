@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSnapshotter_Capture(t *testing.T) {
+	version := "v1"
+	backend := newTestBackend(t, "app.localhost", func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "app.localhost" {
+			t.Errorf("Host = %q, want app.localhost", r.Host)
+		}
+		switch r.URL.Path {
+		case "/version":
+			w.Write([]byte(version))
+		default:
+			w.Write([]byte("ok"))
+		}
+	})
+	backend.SnapshotPaths = []string{"/health", "/version"}
+
+	s := NewSnapshotter(0)
+	ctx := context.Background()
+
+	// First capture is the baseline
+	s.Capture(ctx, backend)
+	if diffs := s.Diffs(); len(diffs) != 0 {
+		t.Fatalf("expected no diffs after baseline, got %d", len(diffs))
+	}
+
+	// Unchanged responses record nothing
+	s.Capture(ctx, backend)
+	if diffs := s.Diffs(); len(diffs) != 0 {
+		t.Fatalf("expected no diffs for unchanged responses, got %d", len(diffs))
+	}
+
+	version = "v2"
+	s.Capture(ctx, backend)
+	diffs := s.Diffs()
+	if len(diffs) != 1 {
+		t.Fatalf("expected 1 diff, got %d", len(diffs))
+	}
+	if diffs[0].Hostname != "app.localhost" {
+		t.Errorf("Hostname = %q, want app.localhost", diffs[0].Hostname)
+	}
+	if len(diffs[0].Changes) != 1 || diffs[0].Changes[0].Path != "/version" {
+		t.Fatalf("Changes = %+v, want only /version", diffs[0].Changes)
+	}
+	if diffs[0].Changes[0].Before.BodyHash == diffs[0].Changes[0].After.BodyHash {
+		t.Error("body hash should differ")
+	}
+}
+
+func TestSnapshotter_StatusChange(t *testing.T) {
+	status := http.StatusOK
+	backend := newTestBackend(t, "app.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	backend.SnapshotPaths = []string{"/"}
+
+	s := NewSnapshotter(0)
+	s.Capture(context.Background(), backend)
+	status = http.StatusInternalServerError
+	s.Capture(context.Background(), backend)
+
+	diffs := s.Diffs()
+	if len(diffs) != 1 {
+		t.Fatalf("expected 1 diff, got %d", len(diffs))
+	}
+	change := diffs[0].Changes[0]
+	if change.Before.Status != http.StatusOK || change.After.Status != http.StatusInternalServerError {
+		t.Errorf("status %d -> %d, want 200 -> 500", change.Before.Status, change.After.Status)
+	}
+}
+
+func TestHandler_SnapshotsAPI(t *testing.T) {
+	s := NewSnapshotter(0)
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig(), WithSnapshots(s))
+
+	req := httptest.NewRequest("GET", "https://roji.localhost/_api/snapshots", nil)
+	req.Host = "roji.localhost"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var diffs []SnapshotDiff
+	if err := json.Unmarshal(rec.Body.Bytes(), &diffs); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package proxy
+
+// SetStartupNotice shows a banner on the dashboard and not-found pages
+// (e.g., while waiting for the Docker daemon). An empty notice clears it.
+func (h *Handler) SetStartupNotice(notice string) {
+	h.startupNotice.Store(&notice)
+}
+
+// StartupNotice returns the current startup banner, or ""
+func (h *Handler) StartupNotice() string {
+	if notice := h.startupNotice.Load(); notice != nil {
+		return *notice
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_StartupNotice(t *testing.T) {
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig())
+	notice := "Waiting for the Docker daemon"
+
+	get := func(host string) string {
+		req := httptest.NewRequest("GET", "https://"+host+"/", nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	handler.SetStartupNotice(notice)
+	if got := handler.StartupNotice(); got != notice {
+		t.Errorf("StartupNotice() = %q, want %q", got, notice)
+	}
+	for _, host := range []string{"roji.localhost", "unknown.localhost"} {
+		if body := get(host); !strings.Contains(body, notice) {
+			t.Errorf("%s should show the startup notice", host)
+		}
+	}
+
+	handler.SetStartupNotice("")
+	for _, host := range []string{"roji.localhost", "unknown.localhost"} {
+		if body := get(host); strings.Contains(body, notice) {
+			t.Errorf("%s should not show a cleared startup notice", host)
+		}
+	}
+}
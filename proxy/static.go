@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// serveStatic serves a static route's directory (--static-routes). Files are
+// revalidated on every request, so a rebuilt frontend shows up on reload.
+// Dotfiles and directory listings aren't served (see staticFS).
+func (h *Handler) serveStatic(w http.ResponseWriter, r *http.Request, route *Route) {
+	startTime := time.Now()
+	dir := staticFS{http.Dir(route.Backend.StaticDir)}
+
+	// Single-page apps: unknown paths without an extension get index.html
+	// (client-side routes); missing assets still 404
+	req := r
+	if route.Backend.StaticSPA && path.Ext(r.URL.Path) == "" {
+		if f, err := dir.Open(path.Clean("/" + r.URL.Path)); errors.Is(err, fs.ErrNotExist) {
+			req = r.Clone(r.Context())
+			req.URL.Path = "/"
+		} else if err == nil {
+			f.Close()
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	http.FileServer(dir).ServeHTTP(rec, req)
+
+	h.logRequest(route.Backend.AccessLog, r, accessLogEntry{
+		host:     route.Hostname,
+		status:   rec.status,
+		duration: time.Since(startTime),
+		target:   route.Backend.StaticDir,
+	})
+	h.metrics.observe(route, r, rec.status, time.Since(startTime))
+}
+
+// staticFS is a static route's directory as http.FileServer sees it:
+// dotfiles (.env, .git) and directories without an index.html don't exist,
+// so secrets aren't served and nothing is listed
+type staticFS struct {
+	dir http.Dir
+}
+
+func (s staticFS) Open(name string) (http.File, error) {
+	for part := range strings.SplitSeq(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return nil, fs.ErrNotExist
+		}
+	}
+	f, err := s.dir.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		index, err := s.dir.Open(path.Join(name, "index.html"))
+		if err != nil {
+			f.Close()
+			return nil, fs.ErrNotExist
+		}
+		index.Close()
+	}
+	return f, nil
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
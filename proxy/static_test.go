@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kan/roji/docker"
+)
+
+func TestHandler_StaticRoute(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>app</h1>"), 0o644)
+	os.MkdirAll(filepath.Join(dir, "assets"), 0o755)
+	os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("console.log(1)"), 0o644)
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1"), 0o644)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".git", "config"), []byte("[core]"), 0o644)
+
+	router := NewRouter()
+	router.AddBackend(&docker.Backend{ContainerID: "static:docs.localhost", ServiceName: "static", Hostname: "docs.localhost", StaticDir: dir})
+	router.AddBackend(&docker.Backend{ContainerID: "static:app.localhost", ServiceName: "static", Hostname: "app.localhost", StaticDir: dir, StaticSPA: true})
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	tests := []struct {
+		host   string
+		path   string
+		status int
+		body   string
+	}{
+		{"docs.localhost", "/", http.StatusOK, "<h1>app</h1>"},
+		{"docs.localhost", "/assets/app.js", http.StatusOK, "console.log(1)"},
+		{"docs.localhost", "/settings", http.StatusNotFound, ""},
+		{"docs.localhost", "/../../etc/passwd", http.StatusNotFound, ""},
+		{"docs.localhost", "/.env", http.StatusNotFound, ""},
+		{"docs.localhost", "/.git/config", http.StatusNotFound, ""},
+		{"docs.localhost", "/assets/", http.StatusNotFound, ""}, // no listing
+		{"app.localhost", "/.env", http.StatusNotFound, ""},
+		{"app.localhost", "/assets/", http.StatusOK, "<h1>app</h1>"},
+		{"app.localhost", "/settings/profile", http.StatusOK, "<h1>app</h1>"},
+		{"app.localhost", "/assets/missing.js", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "https://"+tt.host+"/", nil)
+		req.URL.Path = tt.path
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.status || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s%s = %d %q, want %d %q", tt.host, tt.path, w.Code, w.Body, tt.status, tt.body)
+		}
+	}
+}
@@ -22,6 +22,7 @@ type StatusResponse struct {
 	Certificates  CertificateStatus `json:"certificates"`
 	Docker        DockerStatus      `json:"docker"`
 	Proxy         ProxyStatus       `json:"proxy"`
+	Config        ConfigStatus      `json:"config"`
 	Health        string            `json:"health"`
 }
 
@@ -56,6 +57,8 @@ type ProxyStatus struct {
 	BaseDomain    string `json:"base_domain"`
 	HTTPPort      int    `json:"http_port"`
 	HTTPSPort     int    `json:"https_port"`
+
+	InFlightRequests int `json:"in_flight_requests"`
 }
 
 // parseCertificate reads and parses a certificate file
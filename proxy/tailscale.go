@@ -0,0 +1,261 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tailscaleTimeout bounds each tailscale CLI call
+const tailscaleTimeout = 30 * time.Second
+
+// tailscaleServiceLabel is what Tailscale accepts as a service name
+var tailscaleServiceLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// TailscaleServiceName returns the name of the Tailscale service a route is
+// served on (--tailscale): myproject.dev.localhost -> myproject,
+// web.myproject.dev.localhost -> web-myproject. Hostnames outside the base
+// domain, the base domain itself and names Tailscale rejects get "".
+func TailscaleServiceName(hostname, baseDomain string) string {
+	name, ok := strings.CutSuffix(hostname, "."+baseDomain)
+	if !ok {
+		return ""
+	}
+	name = strings.ReplaceAll(name, ".", "-")
+	if !tailscaleServiceLabel.MatchString(name) {
+		return ""
+	}
+	return name
+}
+
+// TailnetDomain returns the MagicDNS suffix of the tailnet this machine is
+// on (e.g., "tail1234.ts.net"), from the tailscale CLI
+func TailnetDomain(ctx context.Context) (string, error) {
+	out, err := runTailscale(ctx, "status", "--json")
+	if err != nil {
+		return "", err
+	}
+	return parseTailscaleStatus(out)
+}
+
+// parseTailscaleStatus picks the MagicDNS suffix from tailscale status --json
+func parseTailscaleStatus(data []byte) (string, error) {
+	var status struct {
+		BackendState   string
+		CurrentTailnet *struct {
+			MagicDNSSuffix  string
+			MagicDNSEnabled bool
+		}
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return "", fmt.Errorf("unexpected tailscale status: %w", err)
+	}
+	if status.BackendState != "Running" {
+		return "", fmt.Errorf("tailscale isn't connected (%s); run tailscale up", status.BackendState)
+	}
+	if status.CurrentTailnet == nil || !status.CurrentTailnet.MagicDNSEnabled || status.CurrentTailnet.MagicDNSSuffix == "" {
+		return "", errors.New("the tailnet has MagicDNS off; turn it on in the Tailscale admin console (DNS)")
+	}
+	return strings.TrimSuffix(status.CurrentTailnet.MagicDNSSuffix, "."), nil
+}
+
+// runTailscale runs the tailscale CLI and returns its output
+func runTailscale(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, tailscaleTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "tailscale", args...)
+	if cmd.Err != nil {
+		return nil, fmt.Errorf("--tailscale needs the tailscale command: %w", cmd.Err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("tailscale %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("tailscale %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// TailscaleServe serves the routes to the tailnet as Tailscale services
+// (--tailscale), at https://<service>.<tailnet>.ts.net. tailscaled
+// terminates TLS with a certificate Tailscale issues for the name and proxies
+// to roji on this machine, keeping the Host header; the handler maps it back
+// to the route (see RouteHostname).
+type TailscaleServe struct {
+	router     *Router
+	baseDomain string
+	tailnet    string // MagicDNS suffix, e.g. "tail1234.ts.net"
+	origin     string // where tailscaled reaches roji, e.g. "https+insecure://127.0.0.1:443"
+
+	// tailscale runs the CLI; replaced in tests
+	tailscale func(ctx context.Context, args ...string) ([]byte, error)
+
+	syncMu sync.Mutex        // serializes syncs; the CLI can take seconds
+	failed map[string]bool   // services tailscale refused, not retried
+	mu     sync.RWMutex      // guards served, which requests read
+	served map[string]string // service name -> route hostname
+}
+
+// NewTailscaleServe creates the services of router's routes on the tailnet,
+// proxying to origin
+func NewTailscaleServe(router *Router, baseDomain, tailnet, origin string) *TailscaleServe {
+	return &TailscaleServe{
+		router:     router,
+		baseDomain: baseDomain,
+		tailnet:    tailnet,
+		origin:     origin,
+		tailscale:  runTailscale,
+		served:     make(map[string]string),
+		failed:     make(map[string]bool),
+	}
+}
+
+// Tailnet returns the MagicDNS suffix the routes are served under
+func (t *TailscaleServe) Tailnet() string {
+	return t.tailnet
+}
+
+// Watch serves the routes now and again whenever they change, until ctx is
+// done. It doesn't take the services down; see Remove.
+func (t *TailscaleServe) Watch(ctx context.Context) {
+	changed := make(chan struct{}, 1)
+	t.router.OnChange(func() {
+		select {
+		case changed <- struct{}{}:
+		default: // a sync is already pending
+		}
+	})
+	t.sync(ctx)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+				t.sync(ctx)
+			}
+		}
+	}()
+}
+
+// Remove stops serving every service, on shutdown
+func (t *TailscaleServe) Remove() {
+	t.syncMu.Lock()
+	defer t.syncMu.Unlock()
+
+	for name := range t.served {
+		t.stop(context.Background(), name)
+	}
+}
+
+// RouteHostname maps a service's hostname back to the route it serves, or
+// returns hostname unchanged
+func (t *TailscaleServe) RouteHostname(hostname string) string {
+	name, ok := strings.CutSuffix(hostname, "."+t.tailnet)
+	if !ok {
+		return hostname
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if route, ok := t.served[name]; ok {
+		return route
+	}
+	return hostname
+}
+
+// services maps the service names of the current routes to their hostnames.
+// When two hostnames give the same name, the first one sorted gets it.
+func (t *TailscaleServe) services() map[string]string {
+	services := make(map[string]string)
+	for _, r := range t.router.ListRoutes() {
+		name := TailscaleServiceName(r.Hostname, t.baseDomain)
+		if name == "" {
+			continue
+		}
+		if other, ok := services[name]; ok && other != r.Hostname {
+			slog.Warn("hostnames share a Tailscale service name; serving the first",
+				"service", name, "hostname", other, "skipped", r.Hostname)
+			continue
+		}
+		services[name] = r.Hostname
+	}
+	return services
+}
+
+// sync starts services for new routes and stops the ones of removed routes
+func (t *TailscaleServe) sync(ctx context.Context) {
+	t.syncMu.Lock()
+	defer t.syncMu.Unlock()
+
+	want := t.services()
+	for name := range t.served {
+		if _, ok := want[name]; !ok {
+			t.stop(ctx, name)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(want)) {
+		hostname := want[name]
+		if _, ok := t.served[name]; ok {
+			t.setServed(name, hostname)
+			continue
+		}
+		if t.failed[name] {
+			continue
+		}
+		if _, err := t.tailscale(ctx, "serve", "--service=svc:"+name, "--https=443", t.origin); err != nil {
+			// Usually a service the tailnet doesn't define or approve
+			slog.Warn("failed to serve a route on the tailnet", "service", "svc:"+name, "hostname", hostname, "error", err)
+			t.failed[name] = true
+			continue
+		}
+		t.setServed(name, hostname)
+		slog.Info("serving route on the tailnet", "url", "https://"+name+"."+t.tailnet, "hostname", hostname)
+	}
+}
+
+// stop takes a service down; t.syncMu is held
+func (t *TailscaleServe) stop(ctx context.Context, name string) {
+	if _, err := t.tailscale(ctx, "serve", "--service=svc:"+name, "--https=443", "off"); err != nil {
+		slog.Warn("failed to stop serving a route on the tailnet", "service", "svc:"+name, "error", err)
+	}
+	t.mu.Lock()
+	delete(t.served, name)
+	t.mu.Unlock()
+}
+
+// setServed records the route a service serves; t.syncMu is held
+func (t *TailscaleServe) setServed(name, hostname string) {
+	t.mu.Lock()
+	t.served[name] = hostname
+	t.mu.Unlock()
+}
+
+// WithTailscale serves routes under their Tailscale service names (see
+// TailscaleServe)
+func WithTailscale(t *TailscaleServe) HandlerOption {
+	return func(h *Handler) {
+		h.tailscale = t
+	}
+}
+
+// fromTailscaleName maps a Tailscale service name back to the route hostname
+func (h *Handler) fromTailscaleName(hostname string) string {
+	if h.tailscale == nil {
+		return hostname
+	}
+	return h.tailscale.RouteHostname(hostname)
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTailscaleServiceName(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{"myproject.dev.localhost", "myproject"},
+		{"web.myproject.dev.localhost", "web-myproject"},
+		{"api-v2.shop.dev.localhost", "api-v2-shop"},
+		{"dev.localhost", ""},
+		{"shop.example", ""},
+		{"*.myproject.dev.localhost", ""},
+		{strings.Repeat("a", 64) + ".dev.localhost", ""},
+	}
+	for _, tt := range tests {
+		if got := TailscaleServiceName(tt.hostname, "dev.localhost"); got != tt.want {
+			t.Errorf("TailscaleServiceName(%q) = %q, want %q", tt.hostname, got, tt.want)
+		}
+	}
+}
+
+func TestParseTailscaleStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		want    string
+		wantErr string
+	}{
+		{"running", `{"BackendState":"Running","CurrentTailnet":{"Name":"me@example.com","MagicDNSSuffix":"tail1234.ts.net","MagicDNSEnabled":true}}`,
+			"tail1234.ts.net", ""},
+		{"logged out", `{"BackendState":"NeedsLogin","CurrentTailnet":null}`, "", "tailscale up"},
+		{"no MagicDNS", `{"BackendState":"Running","CurrentTailnet":{"MagicDNSSuffix":"tail1234.ts.net","MagicDNSEnabled":false}}`,
+			"", "MagicDNS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTailscaleStatus([]byte(tt.status))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// fakeTailscale records tailscale CLI calls and refuses the services in refuse
+type fakeTailscale struct {
+	mu     sync.Mutex
+	calls  []string
+	refuse string
+}
+
+func (f *fakeTailscale) run(ctx context.Context, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	call := strings.Join(args, " ")
+	f.calls = append(f.calls, call)
+	if f.refuse != "" && strings.Contains(call, "svc:"+f.refuse+" ") {
+		return nil, errors.New("service not found")
+	}
+	return nil, nil
+}
+
+func (f *fakeTailscale) called(call string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Contains(f.calls, call)
+}
+
+func TestTailscaleServe(t *testing.T) {
+	router := NewRouter()
+	router.AddBackend(newTestBackend(t, "web.myproject.dev.localhost", nil))
+	refused := newTestBackend(t, "admin.myproject.dev.localhost", nil)
+	refused.ContainerID = "admin1"
+	router.AddBackend(refused)
+
+	fake := &fakeTailscale{refuse: "admin-myproject"}
+	ts := NewTailscaleServe(router, "dev.localhost", "tail1234.ts.net", "https+insecure://127.0.0.1:443")
+	ts.tailscale = fake.run
+	ts.Watch(t.Context())
+
+	if !fake.called("serve --service=svc:web-myproject --https=443 https+insecure://127.0.0.1:443") {
+		t.Fatalf("route not served: %v", fake.calls)
+	}
+	if got := ts.RouteHostname("web-myproject.tail1234.ts.net"); got != "web.myproject.dev.localhost" {
+		t.Errorf("RouteHostname = %q", got)
+	}
+	if got := ts.RouteHostname("admin-myproject.tail1234.ts.net"); got != "admin-myproject.tail1234.ts.net" {
+		t.Errorf("refused service mapped to %q", got)
+	}
+
+	api := newTestBackend(t, "api.myproject.dev.localhost", nil)
+	api.ContainerID = "api1"
+	router.AddBackend(api)
+	router.RemoveBackend("legacy1")
+	deadline := time.Now().Add(2 * time.Second)
+	for !fake.called("serve --service=svc:web-myproject --https=443 off") ||
+		!fake.called("serve --service=svc:api-myproject --https=443 https+insecure://127.0.0.1:443") {
+		if time.Now().After(deadline) {
+			t.Fatalf("route changes not synced: %v", fake.calls)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ts.Remove()
+	if !fake.called("serve --service=svc:api-myproject --https=443 off") {
+		t.Errorf("Remove left the service: %v", fake.calls)
+	}
+	if n := strings.Count(strings.Join(fake.calls, "\n"), "svc:admin-myproject"); n != 1 {
+		t.Errorf("refused service tried %d times, want once", n)
+	}
+}
+
+func TestHandler_TailscaleHostnames(t *testing.T) {
+	backend := newTestBackend(t, "web.myproject.dev.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	backend.TunnelAuth = "user:pass"
+	router := NewRouter()
+	router.AddBackend(backend)
+	ts := NewTailscaleServe(router, "dev.localhost", "tail1234.ts.net", "https+insecure://127.0.0.1:443")
+	ts.tailscale = (&fakeTailscale{}).run
+	ts.Watch(t.Context())
+
+	statusConfig := testStatusConfig()
+	statusConfig.BaseDomain = "dev.localhost"
+	handler := NewHandler(router, "dev.localhost", statusConfig, WithTailscale(ts))
+
+	// tailscale serve keeps the Host and adds X-Forwarded-Host; the tailnet
+	// isn't a public tunnel, so roji.tunnel-auth doesn't apply
+	r := httptest.NewRequest("GET", "https://web-myproject.tail1234.ts.net/", nil)
+	r.Header.Set("X-Forwarded-Host", "web-myproject.tail1234.ts.net")
+	r.RemoteAddr = "127.0.0.1:50000" // tailscaled
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+}
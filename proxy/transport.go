@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kan/roji/docker"
+)
+
+// TransportConfig controls the connections roji keeps to backends
+type TransportConfig struct {
+	DisableKeepAlives   bool          // Open a connection per request
+	MaxIdleConnsPerHost int           // Idle connections kept per backend
+	IdleConnTimeout     time.Duration // Idle connections are closed after this long
+	MaxConnLifetime     time.Duration // Connections aren't reused once this old (0 = no limit)
+}
+
+// DefaultTransportConfig returns the pooling roji used before it was configurable
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// WithBackendTransport replaces the default connection pooling to backends
+func WithBackendTransport(cfg TransportConfig) HandlerOption {
+	return func(h *Handler) {
+		h.transports = newBackendTransports(cfg)
+	}
+}
+
+// backendTransports hands out a transport per pooling policy, so
+// roji.keep-alive, roji.max-idle-conns and roji.conn-lifetime get their own
+// pools
+type backendTransports struct {
+	cfg TransportConfig
+
+	mu       sync.Mutex
+	byPolicy map[transportPolicy]*http.Transport
+}
+
+// transportPolicy is what sets the transports of backends apart
+type transportPolicy struct {
+	keepAlive bool
+	idleCap   int
+	lifetime  time.Duration
+}
+
+func newBackendTransports(cfg TransportConfig) *backendTransports {
+	return &backendTransports{cfg: cfg, byPolicy: make(map[transportPolicy]*http.Transport)}
+}
+
+// forBackend returns the transport to reach a backend with
+func (t *backendTransports) forBackend(backend *docker.Backend) *http.Transport {
+	policy := transportPolicy{keepAlive: t.keepAlive(backend), idleCap: t.cfg.MaxIdleConnsPerHost}
+	if backend.MaxIdleConns > 0 {
+		policy.idleCap = backend.MaxIdleConns
+	}
+	if policy.keepAlive {
+		policy.lifetime = t.connLifetime(backend)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if transport, ok := t.byPolicy[policy]; ok {
+		return transport
+	}
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   true, // A custom dialer turns it off otherwise (external HTTPS upstreams)
+		DisableKeepAlives:   !policy.keepAlive,
+		MaxIdleConns:        max(100, policy.idleCap),
+		MaxIdleConnsPerHost: policy.idleCap,
+		IdleConnTimeout:     t.cfg.IdleConnTimeout,
+	}
+	if lifetime := policy.lifetime; lifetime > 0 {
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return newAgedConn(conn, lifetime), nil
+		}
+		// agedConn has to see the requests in the clear, so it goes on top of
+		// TLS; this keeps external HTTPS upstreams on HTTP/1.1
+		transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			host, _, _ := net.SplitHostPort(addr)
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: host, NextProtos: []string{"http/1.1"}})
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return newAgedConn(tlsConn, lifetime), nil
+		}
+	}
+	t.byPolicy[policy] = transport
+	return transport
+}
+
+// keepAlive reports whether connections to the backend may be reused
+func (t *backendTransports) keepAlive(backend *docker.Backend) bool {
+	return !t.cfg.DisableKeepAlives && !backend.NoKeepAlive
+}
+
+// connLifetime returns how long connections to the backend are reused
+func (t *backendTransports) connLifetime(backend *docker.Backend) time.Duration {
+	if backend.ConnLifetime > 0 {
+		return backend.ConnLifetime
+	}
+	return t.cfg.MaxConnLifetime
+}
+
+// connectionClose is added to the last request sent on an agedConn
+var connectionClose = []byte("Connection: close\r\n")
+
+// agedConn is a backend connection that retires once older than lifetime:
+// the next request sent on it asks the backend to close the connection
+// afterwards (Connection: close), so the transport doesn't reuse it and a
+// request in flight is never cut off
+type agedConn struct {
+	net.Conn
+	lifetime time.Duration
+	opened   time.Time
+
+	mu       sync.Mutex
+	read     bool // a response came in since the last write, so the next write starts a request
+	retiring bool // Connection: close was sent
+}
+
+func newAgedConn(conn net.Conn, lifetime time.Duration) *agedConn {
+	return &agedConn{Conn: conn, lifetime: lifetime, opened: time.Now()}
+}
+
+func (c *agedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.mu.Lock()
+		c.read = true
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *agedConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	end := 0
+	if c.read && !c.retiring && time.Since(c.opened) >= c.lifetime {
+		end = requestLineEnd(p)
+		c.retiring = end > 0
+	}
+	c.read = false
+	c.mu.Unlock()
+	if end == 0 {
+		return c.Conn.Write(p)
+	}
+
+	// Insert the header after the request line, and count what was written
+	// of p only
+	buf := make([]byte, 0, len(p)+len(connectionClose))
+	buf = append(append(append(buf, p[:end]...), connectionClose...), p[end:]...)
+	n, err := c.Conn.Write(buf)
+	switch {
+	case n <= end:
+		return n, err
+	case n <= end+len(connectionClose):
+		return end, err
+	default:
+		return n - len(connectionClose), err
+	}
+}
+
+// requestLineEnd returns where the HTTP/1.1 request line p starts with ends,
+// or 0 when p doesn't start with one (such as the body after a 100 Continue)
+func requestLineEnd(p []byte) int {
+	line, _, ok := bytes.Cut(p, []byte("\r\n"))
+	if !ok || !bytes.HasSuffix(line, []byte(" HTTP/1.1")) {
+		return 0
+	}
+	return len(line) + 2
+}
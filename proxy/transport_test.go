@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// connCounter is a backend that counts the connections roji opened to it
+type connCounter struct {
+	mu    sync.Mutex
+	peers map[string]bool
+}
+
+func (c *connCounter) handle(w http.ResponseWriter, r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	c.mu.Lock()
+	c.peers[r.RemoteAddr] = true
+	c.mu.Unlock()
+	w.Write([]byte("ok"))
+}
+
+func (c *connCounter) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.peers)
+}
+
+func TestHandler_BackendKeepAlive(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         TransportConfig
+		noKeepAlive bool
+		want        int
+	}{
+		{"reused by default", DefaultTransportConfig(), false, 1},
+		{"route turns keep-alive off", DefaultTransportConfig(), true, 3},
+		{"global keep-alive off", TransportConfig{DisableKeepAlives: true, MaxIdleConnsPerHost: 10, IdleConnTimeout: time.Minute}, false, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := &connCounter{peers: make(map[string]bool)}
+			backend := newTestBackend(t, "webpack.localhost", counter.handle)
+			backend.NoKeepAlive = tt.noKeepAlive
+
+			router := NewRouter()
+			router.AddBackend(backend)
+			handler := NewHandler(router, "roji.localhost", testStatusConfig(), WithBackendTransport(tt.cfg))
+
+			for range 3 {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest("GET", "https://webpack.localhost/", nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+				}
+			}
+			if got := counter.count(); got != tt.want {
+				t.Errorf("connections = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_BackendConnLifetime(t *testing.T) {
+	for _, method := range []string{"GET", "POST"} {
+		t.Run(method, func(t *testing.T) {
+			counter := &connCounter{peers: make(map[string]bool)}
+			backend := newTestBackend(t, "webpack.localhost", counter.handle)
+			backend.ConnLifetime = 100 * time.Millisecond
+
+			router := NewRouter()
+			router.AddBackend(backend)
+			handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+			send := func() {
+				var body io.Reader
+				if method == "POST" {
+					body = strings.NewReader("payload")
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(method, "https://webpack.localhost/", body))
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+				}
+			}
+
+			// Young connections are reused
+			send()
+			send()
+			if got := counter.count(); got != 1 {
+				t.Fatalf("connections = %d, want 1", got)
+			}
+
+			// An old one serves one last request, then a new one is opened
+			time.Sleep(150 * time.Millisecond)
+			send()
+			send()
+			if got := counter.count(); got != 2 {
+				t.Errorf("connections = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestBackendTransports_ForBackend(t *testing.T) {
+	transports := newBackendTransports(DefaultTransportConfig())
+	a := newTestBackend(t, "a.localhost", nil)
+	b := newTestBackend(t, "b.localhost", nil)
+	capped := newTestBackend(t, "c.localhost", nil)
+	capped.MaxIdleConns = 2
+
+	if transports.forBackend(a) != transports.forBackend(b) {
+		t.Error("backends with the default idle cap should share a transport")
+	}
+	transport := transports.forBackend(capped)
+	if transport == transports.forBackend(a) {
+		t.Error("roji.max-idle-conns should get its own transport")
+	}
+	if transport.MaxIdleConnsPerHost != 2 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 2", transport.MaxIdleConnsPerHost)
+	}
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// TunnelSecretHeader carries the shared secret for roji.tunnel-secret routes
+const TunnelSecretHeader = "X-Roji-Secret"
+
+// noindex keeps search engines away from half-finished work
+const noindex = "noindex, nofollow, noarchive"
+
+// tunnelHeaders are set by public tunnels in front of roji
+var tunnelHeaders = []string{
+	"Cf-Ray",                   // Cloudflare Tunnel
+	"Tailscale-Funnel-Request", // Tailscale Funnel
+	"Ngrok-Trace-Id",           // ngrok
+}
+
+// isTunneled reports whether a request reached roji through a public
+// tunnel: the route is labeled roji.tunnel, a tunnel header is present, or
+// a forwarding proxy names a public hostname
+func (h *Handler) isTunneled(r *http.Request, route *Route) bool {
+	return route.Backend.Tunnel || h.viaTunnel(r)
+}
+
+// viaTunnel reports whether a request carries the marks of a public tunnel
+// (see isTunneled), whatever route it is for
+func (h *Handler) viaTunnel(r *http.Request) bool {
+	for _, name := range tunnelHeaders {
+		if r.Header.Get(name) != "" {
+			return true
+		}
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host := strings.ToLower(hostWithoutPort(forwarded))
+		base := h.statusConfig.BaseDomain
+		if h.tailscale != nil && strings.HasSuffix(host, "."+h.tailscale.Tailnet()) {
+			return false // tailscale serve: only the tailnet reaches it
+		}
+		return !isLocalhost(host) && host != base && !strings.HasSuffix(host, "."+base)
+	}
+	return false
+}
+
+// needsTunnelCredentials reports whether roji.tunnel-auth and
+// roji.tunnel-secret apply to a request: for every client but this machine,
+// however it got here, and for tunneled requests, which tunnel agents on
+// this machine (cloudflared, ngrok) send from loopback
+func needsTunnelCredentials(r *http.Request, tunneled bool) bool {
+	if tunneled {
+		return true
+	}
+	addr, ok := clientAddr(r)
+	return !ok || !addr.IsLoopback()
+}
+
+// guardTunnel answers robots.txt for tunneled requests and enforces
+// roji.tunnel-auth / roji.tunnel-secret where they apply (see
+// needsTunnelCredentials). It reports whether the request was handled;
+// allowed tunneled requests still get X-Robots-Tag (see ServeHTTP).
+func (h *Handler) guardTunnel(w http.ResponseWriter, r *http.Request, route *Route, tunneled bool) bool {
+	if tunneled && r.URL.Path == "/robots.txt" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Robots-Tag", noindex)
+		io.WriteString(w, "User-agent: *\nDisallow: /\n")
+		return true
+	}
+
+	auth, secret := route.Backend.TunnelAuth, route.Backend.TunnelSecret
+	if (auth == "" && secret == "") || !needsTunnelCredentials(r, tunneled) {
+		return false
+	}
+	if h.sharedRequest(r) {
+		return false
+	}
+	if secret != "" && secureEqual(r.Header.Get(TunnelSecretHeader), secret) {
+		return false
+	}
+	if auth != "" {
+		if user, pass, ok := r.BasicAuth(); ok && secureEqual(user+":"+pass, auth) {
+			return false
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="roji", charset="UTF-8"`)
+	}
+
+	slog.Warn("denied request without tunnel credentials",
+		"hostname", route.Hostname,
+		"path", r.URL.Path,
+		"client", r.RemoteAddr)
+	w.Header().Set("X-Robots-Tag", noindex)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return true
+}
+
+// guardUnroutedMock guards a mock on a path no route of its hostname covers
+// (--mocks) like guardTunnel: with the roji.tunnel-auth and
+// roji.tunnel-secret of every route on the hostname, and through a public
+// tunnel not at all when the hostname has no route. It reports whether the
+// request was handled.
+func (h *Handler) guardUnroutedMock(w http.ResponseWriter, r *http.Request, hostname string) bool {
+	routes := h.router.HostnameRoutes(hostname)
+	for _, route := range routes {
+		if h.guardTunnel(w, r, route, h.isTunneled(r, route)) {
+			return true
+		}
+	}
+	if len(routes) > 0 || !h.viaTunnel(r) {
+		return false
+	}
+	slog.Warn("denied mock through a tunnel: its hostname has no route",
+		"hostname", hostname,
+		"path", r.URL.Path,
+		"client", r.RemoteAddr)
+	w.Header().Set("X-Robots-Tag", noindex)
+	http.Error(w, "Not Found", http.StatusNotFound)
+	return true
+}
+
+// secureEqual compares credentials in constant time
+func secureEqual(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
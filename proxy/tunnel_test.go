@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler_TunnelDetection(t *testing.T) {
+	backend := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   bool
+	}{
+		{"local", "", "", false},
+		{"cloudflare", "Cf-Ray", "8a1b2c3d4e5f-NRT", true},
+		{"funnel", "Tailscale-Funnel-Request", "?1", true},
+		{"public forwarded host", "X-Forwarded-Host", "abc.trycloudflare.com", true},
+		{"local forwarded host", "X-Forwarded-Host", "web.localhost", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "https://web.localhost/", nil)
+			req.Host = "web.localhost"
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if got := w.Header().Get("X-Robots-Tag") != ""; got != tt.want {
+				t.Errorf("X-Robots-Tag set = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_TunnelRobots(t *testing.T) {
+	backend := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nAllow: /\n"))
+	})
+	backend.Tunnel = true
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	req := httptest.NewRequest("GET", "https://web.localhost/robots.txt", nil)
+	req.Host = "web.localhost"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Body.String(); got != "User-agent: *\nDisallow: /\n" {
+		t.Errorf("robots.txt = %q, want everything disallowed", got)
+	}
+}
+
+func TestHandler_TunnelAuth(t *testing.T) {
+	backend := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || r.Header.Get(TunnelSecretHeader) != "" {
+			t.Error("roji credentials should not reach the backend")
+		}
+		w.Write([]byte("ok"))
+	})
+	backend.TunnelAuth = "demo:hunter2"
+	backend.TunnelSecret = "s3cret"
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	get := func(setup func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "https://web.localhost/", nil)
+		req.Host = "web.localhost"
+		req.RemoteAddr = "127.0.0.1:50000" // cloudflared on this machine
+		req.Header.Set("Cf-Ray", "8a1b2c3d4e5f-NRT")
+		setup(req)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(func(*http.Request) {})
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("no credentials: status = %d, want 401 with a basic auth challenge", w.Code)
+	}
+	if w := get(func(r *http.Request) { r.SetBasicAuth("demo", "wrong") }); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status = %d, want 401", w.Code)
+	}
+	if w := get(func(r *http.Request) { r.SetBasicAuth("demo", "hunter2") }); w.Code != http.StatusOK {
+		t.Errorf("basic auth: status = %d, want 200", w.Code)
+	}
+	if w := get(func(r *http.Request) { r.Header.Set(TunnelSecretHeader, "s3cret") }); w.Code != http.StatusOK {
+		t.Errorf("shared secret: status = %d, want 200", w.Code)
+	}
+
+	// Other machines are challenged without tunnel headers too (a port
+	// forward, another proxy); this machine isn't
+	for _, tt := range []struct {
+		remote string
+		want   int
+	}{
+		{"192.0.2.1:50000", http.StatusUnauthorized},
+		{"127.0.0.1:50000", http.StatusOK},
+		{"[::1]:50000", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "https://web.localhost/", nil)
+		req.Host = "web.localhost"
+		req.RemoteAddr = tt.remote
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("request from %s: status = %d, want %d", tt.remote, w.Code, tt.want)
+		}
+	}
+}
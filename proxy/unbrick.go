@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// UnbrickPath serves a cleanup page on every hostname. It must be same-origin
+// with the broken site: only then can it clear storage and unregister
+// service workers.
+const UnbrickPath = "/_roji/unbrick"
+
+// WithHSTS allows backends to send Strict-Transport-Security for *.localhost.
+// By default the header is stripped: a pinned HSTS policy outlives the dev
+// server and breaks plain-HTTP tools on the same hostname.
+func WithHSTS(allowed bool) HandlerOption {
+	return func(h *Handler) {
+		h.allowHSTS = allowed
+	}
+}
+
+// isLocalhost reports whether hostname is localhost or a subdomain of it
+func isLocalhost(hostname string) bool {
+	return hostname == "localhost" || strings.HasSuffix(hostname, ".localhost")
+}
+
+// guardResponse removes headers that leave browsers stuck after the dev
+// server goes away (HSTS on *.localhost, widened service worker scopes)
+func (h *Handler) guardResponse(route *Route, hostname string, header http.Header) {
+	if !h.allowHSTS && isLocalhost(hostname) && header.Get("Strict-Transport-Security") != "" {
+		header.Del("Strict-Transport-Security")
+		slog.Debug("stripped HSTS header", "host", hostname)
+	}
+	if route.Backend.BlockServiceWorkers {
+		header.Del("Service-Worker-Allowed")
+	}
+	applyEmbedPolicy(route.Backend.Embed, h.statusConfig.BaseDomain, header)
+}
+
+// isServiceWorkerScript reports whether the browser is fetching a service
+// worker script (sent with "Service-Worker: script" on registration and updates)
+func isServiceWorkerScript(r *http.Request) bool {
+	return r.Header.Get("Service-Worker") == "script"
+}
+
+// serveUnbrick explains how to reset the origin (GET), clears its browser
+// state when the page's form is posted (POST), and explains how to remove
+// what a page cannot clear by itself (HSTS pins). Only a post from the origin
+// itself clears anything, so other sites can't wipe it by linking here.
+func (h *Handler) serveUnbrick(w http.ResponseWriter, r *http.Request, hostname string) {
+	data := struct {
+		Hostname      string
+		DashboardHost string
+		Cleared       bool
+	}{
+		Hostname:      hostname,
+		DashboardHost: h.dashboardHost,
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		if notFromHost(r, hostname) {
+			http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+			return
+		}
+		// Supporting browsers drop caches, cookies, storage and service workers for the origin
+		w.Header().Set("Clear-Site-Data", `"cache", "cookies", "storage"`)
+		data.Cleared = true
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	renderPage(w, http.StatusOK, "unbrick.html", data)
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsLocalhost(t *testing.T) {
+	tests := map[string]bool{
+		"localhost":         true,
+		"web.app.localhost": true,
+		"example.com":       false,
+		"localhost.example": false,
+	}
+	for hostname, expected := range tests {
+		if got := isLocalhost(hostname); got != expected {
+			t.Errorf("isLocalhost(%q) = %v, want %v", hostname, got, expected)
+		}
+	}
+}
+
+func TestHandler_StripsHSTS(t *testing.T) {
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		w.Header().Set("Service-Worker-Allowed", "/")
+	}
+
+	tests := []struct {
+		name      string
+		opts      []HandlerOption
+		blockSW   bool
+		wantHSTS  bool
+		wantScope bool
+	}{
+		{"default", nil, false, false, true},
+		{"HSTS allowed", []HandlerOption{WithHSTS(true)}, false, true, true},
+		{"service workers blocked", nil, true, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, "web.localhost", upstream)
+			backend.BlockServiceWorkers = tt.blockSW
+			router := NewRouter()
+			router.AddBackend(backend)
+			handler := NewHandler(router, "roji.localhost", testStatusConfig(), tt.opts...)
+
+			req := httptest.NewRequest("GET", "https://web.localhost/", nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Strict-Transport-Security") != ""; got != tt.wantHSTS {
+				t.Errorf("HSTS present = %v, want %v", got, tt.wantHSTS)
+			}
+			if got := w.Header().Get("Service-Worker-Allowed") != ""; got != tt.wantScope {
+				t.Errorf("Service-Worker-Allowed present = %v, want %v", got, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestHandler_BlocksServiceWorkerScripts(t *testing.T) {
+	backend := newTestBackend(t, "web.localhost", func(w http.ResponseWriter, r *http.Request) {})
+	backend.BlockServiceWorkers = true
+	router := NewRouter()
+	router.AddBackend(backend)
+	handler := NewHandler(router, "roji.localhost", testStatusConfig())
+
+	req := httptest.NewRequest("GET", "https://web.localhost/sw.js", nil)
+	req.Header.Set("Service-Worker", "script")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandler_UnbrickPage(t *testing.T) {
+	// Available even without a route for the hostname
+	handler := NewHandler(NewRouter(), "roji.localhost", testStatusConfig())
+
+	req := httptest.NewRequest("GET", "https://gone.localhost"+UnbrickPath, nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Clear-Site-Data"); got != "" {
+		t.Errorf("GET sent Clear-Site-Data = %q; only the form post may clear", got)
+	}
+	if !strings.Contains(w.Body.String(), "gone.localhost") || !strings.Contains(w.Body.String(), `method="post"`) {
+		t.Error("page should mention the hostname and offer the reset form")
+	}
+
+	// The page's own form clears the origin
+	req = httptest.NewRequest("POST", "https://gone.localhost"+UnbrickPath, nil)
+	req.Header.Set("Origin", "https://gone.localhost")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get("Clear-Site-Data"); !strings.Contains(got, `"storage"`) {
+		t.Errorf("Clear-Site-Data = %q, want storage to be cleared", got)
+	}
+
+	// Other sites can't wipe it
+	req = httptest.NewRequest("POST", "https://gone.localhost"+UnbrickPath, nil)
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || w.Header().Get("Clear-Site-Data") != "" {
+		t.Errorf("cross-origin post: status = %d, Clear-Site-Data = %q", w.Code, w.Header().Get("Clear-Site-Data"))
+	}
+}